@@ -13,14 +13,18 @@ import (
 	"log"
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/google/shlex"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
 // An adapter satisfies SSH requests (from an Ansible client) by delegating SSH
-// exec and subsystem commands to a packersdk.Communicator.
+// exec and subsystem commands to a packersdk.Communicator. The connections of
+// direct-tcpip channels are forwarded when the communicator is a
+// packersdk.PortForwarder.
 type Adapter struct {
 	done    <-chan struct{}
 	l       net.Listener
@@ -28,16 +32,33 @@ type Adapter struct {
 	sftpCmd string
 	ui      packersdk.Ui
 	comm    packersdk.Communicator
+
+	forwardsMu sync.Mutex
+	forwards   map[string]packersdk.PortForward
 }
 
+// InternalSFTP is the sftpCmd of the adapters serving the SFTP subsystem
+// with the communicator, instead of running an SFTP server on the guest. It
+// is named after the in-process SFTP server of sshd_config(5).
+const InternalSFTP = "internal-sftp"
+
+// defaultSFTPCmd is the SFTP server run on the guest when sftpCmd is empty.
+const defaultSFTPCmd = "/usr/lib/sftp-server -e"
+
+// NewAdapter returns an adapter serving the connections of l until done is
+// closed. The SFTP subsystem runs sftpCmd on the guest as the SFTP server,
+// /usr/lib/sftp-server when it is empty, or is served with the communicator
+// when it is InternalSFTP. config is usually created by
+// ServerConfig.SSHConfig.
 func NewAdapter(done <-chan struct{}, l net.Listener, config *ssh.ServerConfig, sftpCmd string, ui packersdk.Ui, comm packersdk.Communicator) *Adapter {
 	return &Adapter{
-		done:    done,
-		l:       l,
-		config:  config,
-		sftpCmd: sftpCmd,
-		ui:      ui,
-		comm:    comm,
+		done:     done,
+		l:        l,
+		config:   config,
+		sftpCmd:  sftpCmd,
+		ui:       ui,
+		comm:     comm,
+		forwards: map[string]packersdk.PortForward{},
 	}
 }
 
@@ -78,13 +99,19 @@ func (c *Adapter) Handle(conn net.Conn, ui packersdk.Ui) error {
 
 	// Service the incoming NewChannels
 	for newChannel := range chans {
-		if newChannel.ChannelType() != "session" {
+		var handle func(ssh.NewChannel) error
+		switch newChannel.ChannelType() {
+		case "session":
+			handle = c.handleSession
+		case "direct-tcpip":
+			handle = c.handleDirectTCPIP
+		default:
 			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
 			continue
 		}
 
 		go func(ch ssh.NewChannel) {
-			if err := c.handleSession(ch); err != nil {
+			if err := handle(ch); err != nil {
 				c.ui.Error(err.Error())
 			}
 		}(newChannel)
@@ -160,12 +187,18 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 				case "sftp":
 					sftpCmd := c.sftpCmd
 					if len(sftpCmd) == 0 {
-						sftpCmd = "/usr/lib/sftp-server -e"
+						sftpCmd = defaultSFTPCmd
 					}
 
 					log.Print("starting sftp subsystem")
 					go func() {
-						_ = c.remoteExec(sftpCmd, channel, channel, channel.Stderr())
+						if sftpCmd == InternalSFTP {
+							if err := c.serveSFTP(channel); err != nil {
+								c.ui.Error(err.Error())
+							}
+						} else {
+							_ = c.remoteExec(sftpCmd, channel, channel, channel.Stderr())
+						}
 						close(done)
 					}()
 					req.Reply(true, nil)
@@ -186,6 +219,7 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 
 func (c *Adapter) Shutdown() {
 	c.l.Close()
+	c.closeForwards()
 }
 
 func (c *Adapter) exec(command string, in io.Reader, out io.Writer, err io.Writer) int {
@@ -228,6 +262,28 @@ func (c *Adapter) scpExec(args string, in io.Reader, out io.Writer) error {
 	return errors.New("no scp mode specified")
 }
 
+// serveSFTP serves the SFTP subsystem with the communicator.
+func (c *Adapter) serveSFTP(channel ssh.Channel) error {
+	h, err := newSFTPHandler(c.comm)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	server := sftp.NewRequestServer(channel, sftp.Handlers{
+		FileGet:  h,
+		FilePut:  h,
+		FileCmd:  h,
+		FileList: h,
+	})
+	defer server.Close()
+
+	if err := server.Serve(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
 func (c *Adapter) remoteExec(command string, in io.Reader, out io.Writer, err io.Writer) int {
 	cmd := &packersdk.RemoteCmd{
 		Stdin:   in,
@@ -45,6 +45,32 @@ func TestAdapter_Serve(t *testing.T) {
 	sut.Serve()
 }
 
+// newTestClient returns a client connected to an adapter of comm.
+func newTestClient(t *testing.T, comm packersdk.Communicator) *ssh.Client {
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(newTestSigner(t))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	a := NewAdapter(done, l, config, InternalSFTP, packersdk.TestUi(t), comm)
+	go a.Serve()
+	t.Cleanup(a.Shutdown)
+
+	client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "packer",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
 type listener struct {
 	done    chan struct{}
 	acceptC chan<- struct{}
@@ -12,5 +12,8 @@ use this package to proxy communicator calls.
 You may want to use this adapter if you are writing a provisioner that wraps a
 tool which under normal usage would be run locally and form a connection to the
 remote instance itself.
+
+Files are transferred with either scp or the SFTP subsystem, both served with
+the Upload and Download methods of the communicator.
 */
 package adapter
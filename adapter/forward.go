@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+)
+
+// directTCPIPPayload is the payload of the direct-tcpip channels, see RFC
+// 4254, section 7.2.
+type directTCPIPPayload struct {
+	Host           string
+	Port           uint32
+	OriginatorIP   string
+	OriginatorPort uint32
+}
+
+// handleDirectTCPIP forwards a direct-tcpip channel to its address, dialed
+// from the guest with a port forward of the communicator. The forwards are
+// opened on the first connection to each address, and closed when the
+// adapter shuts down.
+func (c *Adapter) handleDirectTCPIP(newChannel ssh.NewChannel) error {
+	pf, ok := c.comm.(packersdk.PortForwarder)
+	if !ok {
+		newChannel.Reject(ssh.Prohibited, "the communicator can't forward ports")
+		return nil
+	}
+
+	var p directTCPIPPayload
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &p); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid direct-tcpip payload")
+		return err
+	}
+	addr := net.JoinHostPort(p.Host, strconv.Itoa(int(p.Port)))
+	log.Printf("SSH proxy: forwarding a connection to %s", addr)
+
+	conn, err := c.dialGuest(pf, addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return err
+	}
+	defer conn.Close()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(channel, conn)
+		channel.CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(conn, channel)
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+	}()
+	wg.Wait()
+	return nil
+}
+
+// dialGuest connects to addr through the port forward of addr, opening it
+// if needed. A forward that fails, because the communicator reconnected for
+// example, is opened again.
+func (c *Adapter) dialGuest(pf packersdk.PortForwarder, addr string) (net.Conn, error) {
+	c.forwardsMu.Lock()
+	defer c.forwardsMu.Unlock()
+
+	if f, ok := c.forwards[addr]; ok {
+		conn, err := net.Dial("tcp", f.Addr().String())
+		if err == nil {
+			return conn, nil
+		}
+		f.Close()
+		delete(c.forwards, addr)
+	}
+
+	f, err := pf.ForwardLocal("127.0.0.1:0", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error forwarding %s: %s", addr, err)
+	}
+	conn, err := net.Dial("tcp", f.Addr().String())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	c.forwards[addr] = f
+	return conn, nil
+}
+
+// closeForwards closes the port forwards of the adapter.
+func (c *Adapter) closeForwards() {
+	c.forwardsMu.Lock()
+	defer c.forwardsMu.Unlock()
+	for addr, f := range c.forwards {
+		f.Close()
+		delete(c.forwards, addr)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// forwardCommunicator forwards ports to local addresses.
+type forwardCommunicator struct {
+	communicator
+
+	mu       sync.Mutex
+	forwards int
+}
+
+type localForward struct {
+	l net.Listener
+}
+
+func (f localForward) Addr() net.Addr { return f.l.Addr() }
+func (f localForward) Close() error   { return f.l.Close() }
+
+func (c *forwardCommunicator) ForwardLocal(localAddr string, remoteAddr string) (packersdk.PortForward, error) {
+	l, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.forwards++
+	c.mu.Unlock()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				remote, err := net.Dial("tcp", remoteAddr)
+				if err != nil {
+					return
+				}
+				defer remote.Close()
+				go io.Copy(remote, conn)
+				io.Copy(conn, remote)
+			}()
+		}
+	}()
+	return localForward{l}, nil
+}
+
+func (c *forwardCommunicator) ForwardRemote(string, string) (packersdk.PortForward, error) {
+	panic("not implemented")
+}
+
+// startEchoServer returns the address of a server writing back what it
+// reads.
+func startEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestAdapter_directTCPIP(t *testing.T) {
+	addr := startEchoServer(t)
+	comm := &forwardCommunicator{}
+	client := newTestClient(t, comm)
+
+	for i := 0; i < 2; i++ {
+		conn, err := client.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("error dialing through the adapter: %s", err)
+		}
+		if _, err := conn.Write([]byte("hello")); err != nil {
+			t.Fatalf("error writing: %s", err)
+		}
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			t.Fatalf("error reading: %s", err)
+		}
+		if string(b) != "hello" {
+			t.Fatalf("expected hello, got %q", b)
+		}
+		conn.Close()
+	}
+
+	comm.mu.Lock()
+	defer comm.mu.Unlock()
+	if comm.forwards != 1 {
+		t.Fatalf("expected the forward to be reused, got %d forwards", comm.forwards)
+	}
+}
+
+func TestAdapter_directTCPIPUnsupported(t *testing.T) {
+	addr := startEchoServer(t)
+	client := newTestClient(t, communicator{})
+
+	conn, err := client.Dial("tcp", addr)
+	if err == nil {
+		ioutil.ReadAll(conn)
+		t.Fatal("expected the communicator to refuse forwarding ports")
+	}
+}
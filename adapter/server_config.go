@@ -0,0 +1,216 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	"golang.org/x/crypto/ssh"
+)
+
+// ServerConfig is the configuration of the SSH server of an adapter: how its
+// clients authenticate, and the host keys it offers. It lets tools other than
+// Ansible, or ssh itself, connect to the adapter.
+type ServerConfig struct {
+	// User is the name clients must log in with. Any name is accepted when
+	// it's empty.
+	User string
+	// AuthorizedKeys are the public keys clients can authenticate with.
+	AuthorizedKeys []ssh.PublicKey
+	// Password enables password authentication, with this password.
+	Password string
+	// KeyboardInteractive enables keyboard-interactive authentication, which
+	// asks for the Password.
+	KeyboardInteractive bool
+
+	// HostKeys are the host keys of the server. When there are none, a key
+	// is generated for the HostKeyAlgorithms, or an ED25519 and an RSA key
+	// when they aren't set.
+	HostKeys []ssh.Signer
+	// HostKeyAlgorithms are the host key algorithms offered to the clients,
+	// such as ssh-ed25519 or rsa-sha2-256; the host keys of other algorithms
+	// aren't offered. ssh-rsa is always offered with the SHA-2 algorithms of
+	// the RSA keys. All the algorithms of the host keys are offered when it's
+	// empty.
+	HostKeyAlgorithms []string
+}
+
+// hostKeyAlgorithms are the host key algorithms of each type of key.
+var hostKeyAlgorithms = map[string][]string{
+	ssh.KeyAlgoRSA:      {ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA},
+	ssh.KeyAlgoED25519:  {ssh.KeyAlgoED25519},
+	ssh.KeyAlgoECDSA256: {ssh.KeyAlgoECDSA256},
+	ssh.KeyAlgoECDSA384: {ssh.KeyAlgoECDSA384},
+	ssh.KeyAlgoECDSA521: {ssh.KeyAlgoECDSA521},
+}
+
+// SSHConfig returns the ssh.ServerConfig of an adapter.
+func (c *ServerConfig) SSHConfig() (*ssh.ServerConfig, error) {
+	if len(c.AuthorizedKeys) == 0 && c.Password == "" {
+		return nil, errors.New("an authorized key or a password is required")
+	}
+	if c.KeyboardInteractive && c.Password == "" {
+		return nil, errors.New("keyboard-interactive authentication requires a password")
+	}
+
+	config := &ssh.ServerConfig{}
+	if len(c.AuthorizedKeys) > 0 {
+		config.PublicKeyCallback = c.publicKeyCallback
+	}
+	if c.Password != "" {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return c.checkPassword(conn, string(password))
+		}
+	}
+	if c.KeyboardInteractive {
+		config.KeyboardInteractiveCallback = c.keyboardInteractiveCallback
+	}
+
+	keys, err := c.hostKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		config.AddHostKey(k)
+	}
+	return config, nil
+}
+
+func (c *ServerConfig) checkUser(conn ssh.ConnMetadata) error {
+	if c.User != "" && conn.User() != c.User {
+		return fmt.Errorf("unknown user %q", conn.User())
+	}
+	return nil
+}
+
+func (c *ServerConfig) publicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	if err := c.checkUser(conn); err != nil {
+		return nil, err
+	}
+	for _, k := range c.AuthorizedKeys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return nil, nil
+		}
+	}
+	return nil, errors.New("authentication failed")
+}
+
+func (c *ServerConfig) checkPassword(conn ssh.ConnMetadata, password string) (*ssh.Permissions, error) {
+	if err := c.checkUser(conn); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(c.Password)) != 1 {
+		return nil, errors.New("authentication failed")
+	}
+	return nil, nil
+}
+
+func (c *ServerConfig) keyboardInteractiveCallback(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	answers, err := challenge(conn.User(), "", []string{"Password: "}, []bool{false})
+	if err != nil {
+		return nil, err
+	}
+	if len(answers) != 1 {
+		return nil, errors.New("authentication failed")
+	}
+	return c.checkPassword(conn, answers[0])
+}
+
+// hostKeys returns the host keys offered for the HostKeyAlgorithms,
+// generating them if needed.
+func (c *ServerConfig) hostKeys() ([]ssh.Signer, error) {
+	keys := c.HostKeys
+	if len(keys) == 0 {
+		var err error
+		if keys, err = generateHostKeys(c.HostKeyAlgorithms); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.HostKeyAlgorithms) == 0 {
+		return keys, nil
+	}
+
+	allowed := map[string]bool{}
+	for _, a := range c.HostKeyAlgorithms {
+		allowed[a] = true
+	}
+	var offered []ssh.Signer
+	for _, k := range keys {
+		keyType := k.PublicKey().Type()
+		switch {
+		case keyType == ssh.KeyAlgoRSA && !allowed[ssh.KeyAlgoRSASHA256] && !allowed[ssh.KeyAlgoRSASHA512]:
+			// Without its AlgorithmSigner methods, the key is only offered
+			// for ssh-rsa.
+			if allowed[ssh.KeyAlgoRSA] {
+				offered = append(offered, legacyRSASigner{k})
+			}
+		case allowed[keyType] || keyType == ssh.KeyAlgoRSA:
+			offered = append(offered, k)
+		}
+	}
+	if len(offered) == 0 {
+		return nil, fmt.Errorf("no host key for the algorithms %v", c.HostKeyAlgorithms)
+	}
+	return offered, nil
+}
+
+// legacyRSASigner hides the SHA-2 signature algorithms of an RSA key.
+type legacyRSASigner struct {
+	ssh.Signer
+}
+
+// generateHostKeys generates a host key for each type of key of algorithms.
+func generateHostKeys(algorithms []string) ([]ssh.Signer, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{ssh.KeyAlgoED25519, ssh.KeyAlgoRSASHA256}
+	}
+
+	var keys []ssh.Signer
+	generated := map[string]bool{}
+	for _, a := range algorithms {
+		keyType := ""
+		for t, algorithms := range hostKeyAlgorithms {
+			for _, ta := range algorithms {
+				if ta == a {
+					keyType = t
+				}
+			}
+		}
+		if keyType == "" {
+			return nil, fmt.Errorf("unsupported host key algorithm %q", a)
+		}
+		if generated[keyType] {
+			continue
+		}
+		generated[keyType] = true
+
+		var pair *sshkey.Pair
+		var err error
+		switch keyType {
+		case ssh.KeyAlgoRSA:
+			pair, err = sshkey.GeneratePair(sshkey.RSA, nil, 3072)
+		case ssh.KeyAlgoED25519:
+			pair, err = sshkey.GeneratePair(sshkey.ED25519, nil, 0)
+		case ssh.KeyAlgoECDSA256:
+			pair, err = sshkey.GeneratePair(sshkey.ECDSA, nil, 256)
+		case ssh.KeyAlgoECDSA384:
+			pair, err = sshkey.GeneratePair(sshkey.ECDSA, nil, 384)
+		case ssh.KeyAlgoECDSA521:
+			pair, err = sshkey.GeneratePair(sshkey.ECDSA, nil, 521)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error generating the %s host key: %s", keyType, err)
+		}
+		signer, err := ssh.NewSignerFromKey(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, signer)
+	}
+	return keys, nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// dialTestServer runs an SSH handshake between a server of config and a
+// client of clientConfig.
+func dialTestServer(t *testing.T, config *ssh.ServerConfig, clientConfig *ssh.ClientConfig) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conn, chans, reqs, err := ssh.NewServerConn(c, config)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go ssh.DiscardRequests(reqs)
+		for range chans {
+		}
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if clientConfig.HostKeyCallback == nil {
+		clientConfig.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+	conn, _, _, err := ssh.NewClientConn(c, "test", clientConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestServerConfig_auth(t *testing.T) {
+	authorized, other := newTestSigner(t), newTestSigner(t)
+	hostKey := newTestSigner(t)
+
+	keyboardInteractive := func(answer string) ssh.AuthMethod {
+		return ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			return []string{answer}, nil
+		})
+	}
+
+	for _, tc := range []struct {
+		name   string
+		config ServerConfig
+		user   string
+		auth   ssh.AuthMethod
+		ok     bool
+	}{
+		{
+			name:   "authorized key",
+			config: ServerConfig{AuthorizedKeys: []ssh.PublicKey{other.PublicKey(), authorized.PublicKey()}},
+			auth:   ssh.PublicKeys(authorized),
+			ok:     true,
+		},
+		{
+			name:   "unauthorized key",
+			config: ServerConfig{AuthorizedKeys: []ssh.PublicKey{authorized.PublicKey()}},
+			auth:   ssh.PublicKeys(other),
+		},
+		{
+			name:   "password",
+			config: ServerConfig{Password: "secret"},
+			auth:   ssh.Password("secret"),
+			ok:     true,
+		},
+		{
+			name:   "wrong password",
+			config: ServerConfig{Password: "secret"},
+			auth:   ssh.Password("guess"),
+		},
+		{
+			name:   "password of an unknown user",
+			config: ServerConfig{User: "packer", Password: "secret"},
+			user:   "root",
+			auth:   ssh.Password("secret"),
+		},
+		{
+			name:   "password with keys",
+			config: ServerConfig{AuthorizedKeys: []ssh.PublicKey{authorized.PublicKey()}},
+			auth:   ssh.Password("secret"),
+		},
+		{
+			name:   "keyboard-interactive",
+			config: ServerConfig{User: "packer", Password: "secret", KeyboardInteractive: true},
+			user:   "packer",
+			auth:   keyboardInteractive("secret"),
+			ok:     true,
+		},
+		{
+			name:   "keyboard-interactive disabled",
+			config: ServerConfig{Password: "secret"},
+			auth:   keyboardInteractive("secret"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.HostKeys = []ssh.Signer{hostKey}
+			config, err := tc.config.SSHConfig()
+			if err != nil {
+				t.Fatalf("error creating the SSH config: %s", err)
+			}
+			user := tc.user
+			if user == "" {
+				user = "packer"
+			}
+			err = dialTestServer(t, config, &ssh.ClientConfig{User: user, Auth: []ssh.AuthMethod{tc.auth}})
+			if tc.ok && err != nil {
+				t.Fatalf("expected the client to authenticate, got %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected the authentication to fail")
+			}
+		})
+	}
+}
+
+func TestServerConfig_invalid(t *testing.T) {
+	for _, config := range []ServerConfig{
+		{},
+		{KeyboardInteractive: true, AuthorizedKeys: []ssh.PublicKey{newTestSigner(t).PublicKey()}},
+		{Password: "secret", HostKeyAlgorithms: []string{"ssh-dss"}},
+		{Password: "secret", HostKeys: []ssh.Signer{newTestSigner(t)}, HostKeyAlgorithms: []string{ssh.KeyAlgoRSASHA256}},
+	} {
+		if _, err := config.SSHConfig(); err == nil {
+			t.Errorf("expected an error for %#v", config)
+		}
+	}
+}
+
+func TestServerConfig_hostKeyAlgorithms(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		server    []string
+		client    []string
+		algorithm string
+	}{
+		{
+			name:      "default",
+			client:    []string{ssh.KeyAlgoED25519},
+			algorithm: ssh.KeyAlgoED25519,
+		},
+		{
+			name:      "ecdsa",
+			server:    []string{ssh.KeyAlgoECDSA256},
+			algorithm: ssh.KeyAlgoECDSA256,
+		},
+		{
+			name:      "rsa",
+			server:    []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoED25519},
+			client:    []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoED25519},
+			algorithm: ssh.KeyAlgoRSASHA256,
+		},
+		{
+			name:      "legacy rsa",
+			server:    []string{ssh.KeyAlgoRSA},
+			algorithm: ssh.KeyAlgoRSA,
+		},
+		{
+			name:   "unsupported by the client",
+			server: []string{ssh.KeyAlgoECDSA256},
+			client: []string{ssh.KeyAlgoED25519},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := ServerConfig{Password: "secret", HostKeyAlgorithms: tc.server}
+			config, err := sc.SSHConfig()
+			if err != nil {
+				t.Fatalf("error creating the SSH config: %s", err)
+			}
+
+			var algorithm string
+			err = dialTestServer(t, config, &ssh.ClientConfig{
+				User:              "packer",
+				Auth:              []ssh.AuthMethod{ssh.Password("secret")},
+				HostKeyAlgorithms: tc.client,
+				HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+					algorithm = key.Type()
+					return nil
+				},
+			})
+			if tc.algorithm == "" {
+				if err == nil {
+					t.Fatal("expected the handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error connecting: %s", err)
+			}
+			// The host key callback only tells the type of the key.
+			if keyType := hostKeyType(tc.algorithm); algorithm != keyType {
+				t.Fatalf("expected a %s host key, got %s", keyType, algorithm)
+			}
+		})
+	}
+}
+
+func hostKeyType(algorithm string) string {
+	for t, algorithms := range hostKeyAlgorithms {
+		for _, a := range algorithms {
+			if a == algorithm {
+				return t
+			}
+		}
+	}
+	return ""
+}
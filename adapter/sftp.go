@@ -0,0 +1,256 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+	"github.com/pkg/sftp"
+)
+
+// sftpFileMode is the mode of the files uploaded without permissions, and of
+// the files reported by stat requests, as the communicator doesn't tell the
+// mode of the remote files.
+const sftpFileMode os.FileMode = 0644
+
+/*
+The SFTP subsystem serves the requests of the client with the communicator,
+which only transfers whole files. The files are spooled to a temporary
+directory: uploads are written there, then uploaded when they are closed, and
+downloads are downloaded there before they are read.
+
+The SFTP server resolves relative paths from "/", and the communicator has no
+way to list directories or to stat files, so only the operations needed to
+put and get files are supported. That's what Ansible does with SFTP: it runs
+commands through exec requests for everything else.
+*/
+
+// sftpHandler handles the SFTP requests of a session.
+type sftpHandler struct {
+	comm packersdk.Communicator
+	dir  string
+
+	mu sync.Mutex
+	// downloads are the files downloaded by stat requests, which are read by
+	// the get requests that usually follow.
+	downloads map[string]string
+	// uploads are the open uploads, whose attributes are set by setstat
+	// requests.
+	uploads map[string]*sftpUpload
+	n       int
+}
+
+func newSFTPHandler(comm packersdk.Communicator) (*sftpHandler, error) {
+	d, err := tmp.Dir("ansible-sftp")
+	if err != nil {
+		return nil, err
+	}
+	return &sftpHandler{
+		comm:      comm,
+		dir:       d,
+		downloads: map[string]string{},
+		uploads:   map[string]*sftpUpload{},
+	}, nil
+}
+
+func (h *sftpHandler) Close() error {
+	return os.RemoveAll(h.dir)
+}
+
+// windowsPathRe matches the paths of a Windows drive, which the SFTP server
+// prefixes with a slash.
+var windowsPathRe = regexp.MustCompile(`^/[A-Za-z]:`)
+
+// remotePath returns the path on the guest of a request.
+func remotePath(r *sftp.Request) string {
+	if windowsPathRe.MatchString(r.Filepath) {
+		return r.Filepath[1:]
+	}
+	return r.Filepath
+}
+
+// tempFile creates a new file in the temporary directory.
+func (h *sftpHandler) tempFile() (*os.File, error) {
+	h.mu.Lock()
+	h.n++
+	name := filepath.Join(h.dir, strconv.Itoa(h.n))
+	h.mu.Unlock()
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+}
+
+// download downloads the file at path to the temporary directory, unless
+// it was already downloaded, and returns its local path.
+func (h *sftpHandler) download(path string) (string, error) {
+	h.mu.Lock()
+	name, ok := h.downloads[path]
+	h.mu.Unlock()
+	if ok {
+		return name, nil
+	}
+
+	f, err := h.tempFile()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := h.comm.Download(path, f); err != nil {
+		// The communicators don't tell missing files apart from other
+		// errors.
+		log.Printf("SFTP: download of %s failed: %s", path, err)
+		os.Remove(f.Name())
+		return "", os.ErrNotExist
+	}
+
+	h.mu.Lock()
+	h.downloads[path] = f.Name()
+	h.mu.Unlock()
+	return f.Name(), nil
+}
+
+// Fileread downloads the file and returns it.
+func (h *sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	name, err := h.download(remotePath(r))
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+// Filewrite returns a temporary file, uploaded when it's closed.
+func (h *sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	f, err := h.tempFile()
+	if err != nil {
+		return nil, err
+	}
+
+	path := remotePath(r)
+	u := &sftpUpload{
+		File: f,
+		h:    h,
+		path: path,
+		fi:   fileInfo{name: guestBase(path), mode: sftpFileMode},
+	}
+
+	h.mu.Lock()
+	delete(h.downloads, path)
+	h.uploads[path] = u
+	h.mu.Unlock()
+	return u, nil
+}
+
+// Filecmd only sets the permissions and times of open uploads.
+func (h *sftpHandler) Filecmd(r *sftp.Request) error {
+	if r.Method != "Setstat" {
+		return sftp.ErrSSHFxOpUnsupported
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u, ok := h.uploads[remotePath(r)]
+	if !ok {
+		return sftp.ErrSSHFxOpUnsupported
+	}
+	flags, attrs := r.AttrFlags(), r.Attributes()
+	if flags.Permissions {
+		u.fi.mode = attrs.FileMode().Perm()
+	}
+	if flags.Acmodtime {
+		u.fi.mtime = time.Unix(int64(attrs.Mtime), 0)
+	}
+	return nil
+}
+
+// Filelist only stats files, by downloading them.
+func (h *sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if r.Method != "Stat" {
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+
+	path := remotePath(r)
+	name, err := h.download(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return listerAt{fileInfo{
+		name:  guestBase(path),
+		size:  info.Size(),
+		mode:  sftpFileMode,
+		mtime: info.ModTime(),
+	}}, nil
+}
+
+// guestBase returns the last element of a path of the guest, which may
+// use either separator.
+func guestBase(p string) string {
+	return path.Base(strings.ReplaceAll(p, `\`, "/"))
+}
+
+// sftpUpload is a file being uploaded.
+type sftpUpload struct {
+	*os.File
+	h    *sftpHandler
+	path string
+	fi   fileInfo
+	err  error
+}
+
+// TransferError is called when the session ends with the file open, which
+// is then not uploaded.
+func (u *sftpUpload) TransferError(err error) {
+	u.err = err
+}
+
+// Close uploads the file.
+func (u *sftpUpload) Close() error {
+	defer os.Remove(u.Name())
+	defer u.File.Close()
+
+	u.h.mu.Lock()
+	delete(u.h.uploads, u.path)
+	fi := u.fi
+	u.h.mu.Unlock()
+
+	if u.err != nil {
+		return u.err
+	}
+	info, err := u.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := u.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	fi.size = info.Size()
+	var upload os.FileInfo = fi
+	return u.h.comm.Upload(u.path, u.File, &upload)
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
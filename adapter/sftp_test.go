@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/pkg/sftp"
+)
+
+// fileCommunicator transfers files to and from memory.
+type fileCommunicator struct {
+	communicator
+
+	mu    sync.Mutex
+	files map[string][]byte
+	modes map[string]os.FileMode
+}
+
+func (c *fileCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[dst] = b
+	c.modes[dst] = (*fi).Mode()
+	return nil
+}
+
+func (c *fileCommunicator) Download(src string, w io.Writer) error {
+	c.mu.Lock()
+	b, ok := c.files[src]
+	c.mu.Unlock()
+	if !ok {
+		return errors.New("no such file")
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func newSFTPClient(t *testing.T, comm packersdk.Communicator) *sftp.Client {
+	c, err := sftp.NewClient(newTestClient(t, comm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestAdapter_sftp(t *testing.T) {
+	comm := &fileCommunicator{files: map[string][]byte{}, modes: map[string]os.FileMode{}}
+	c := newSFTPClient(t, comm)
+
+	content := bytes.Repeat([]byte("stuff"), 100000)
+	f, err := c.Create("/tmp/file.txt")
+	if err != nil {
+		t.Fatalf("error creating file: %s", err)
+	}
+	if _, err := f.ReadFrom(bytes.NewReader(content)); err != nil {
+		t.Fatalf("error writing file: %s", err)
+	}
+	if err := f.Chmod(0755); err != nil {
+		t.Fatalf("error setting the mode of the file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("error closing file: %s", err)
+	}
+	if !bytes.Equal(comm.files["/tmp/file.txt"], content) {
+		t.Fatalf("uploaded %d bytes, expected %d", len(comm.files["/tmp/file.txt"]), len(content))
+	}
+	if comm.modes["/tmp/file.txt"] != 0755 {
+		t.Fatalf("expected mode 0755, got %s", comm.modes["/tmp/file.txt"])
+	}
+
+	info, err := c.Stat("/tmp/file.txt")
+	if err != nil {
+		t.Fatalf("error getting file info: %s", err)
+	}
+	if info.Size() != int64(len(content)) || info.Name() != "file.txt" {
+		t.Fatalf("unexpected file info %s %d", info.Name(), info.Size())
+	}
+
+	f, err = c.Open("/tmp/file.txt")
+	if err != nil {
+		t.Fatalf("error opening file: %s", err)
+	}
+	var b bytes.Buffer
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatalf("error reading file: %s", err)
+	}
+	f.Close()
+	if !bytes.Equal(b.Bytes(), content) {
+		t.Fatalf("downloaded %d bytes, expected %d", b.Len(), len(content))
+	}
+
+	if _, err := c.Stat("/tmp/missing.txt"); !os.IsNotExist(err) {
+		t.Fatalf("expected a missing file, got %v", err)
+	}
+	if err := c.Remove("/tmp/file.txt"); err == nil {
+		t.Fatal("expected an error removing a file")
+	}
+}
+
+func TestAdapter_sftpWindowsPath(t *testing.T) {
+	comm := &fileCommunicator{files: map[string][]byte{}, modes: map[string]os.FileMode{}}
+	c := newSFTPClient(t, comm)
+
+	f, err := c.Create("C:/Windows/Temp/file.txt")
+	if err != nil {
+		t.Fatalf("error creating file: %s", err)
+	}
+	f.Write([]byte("stuff"))
+	if err := f.Close(); err != nil {
+		t.Fatalf("error closing file: %s", err)
+	}
+	if string(comm.files["C:/Windows/Temp/file.txt"]) != "stuff" {
+		t.Fatalf("expected the file at C:/Windows/Temp/file.txt, got %v", comm.files)
+	}
+	if comm.modes["C:/Windows/Temp/file.txt"] != sftpFileMode {
+		t.Fatalf("expected the default mode, got %s", comm.modes["C:/Windows/Temp/file.txt"])
+	}
+}
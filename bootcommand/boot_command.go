@@ -62,18 +62,38 @@ var g = &grammar{
 							alternatives: []interface{}{
 								&ruleRefExpr{
 									pos:  position{line: 10, col: 13, offset: 87},
+									name: "Group",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 21, offset: 95},
 									name: "Wait",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 20, offset: 94},
+									pos:  position{line: 10, col: 28, offset: 102},
+									name: "WaitForText",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 42, offset: 116},
+									name: "WaitForPort",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 56, offset: 130},
+									name: "WaitForHTTP",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 70, offset: 144},
+									name: "Macro",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 78, offset: 152},
 									name: "CharToggle",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 33, offset: 107},
+									pos:  position{line: 10, col: 91, offset: 165},
 									name: "Special",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 43, offset: 117},
+									pos:  position{line: 10, col: 101, offset: 175},
 									name: "Literal",
 								},
 							},
@@ -82,39 +102,177 @@ var g = &grammar{
 				},
 			},
 		},
+		{
+			name: "Group",
+			pos:  position{line: 14, col: 1, offset: 208},
+			expr: &actionExpr{
+				pos: position{line: 14, col: 9, offset: 216},
+				run: (*parser).callonGroup1,
+				expr: &seqExpr{
+					pos: position{line: 14, col: 9, offset: 216},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 14, col: 9, offset: 216},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 14, col: 19, offset: 226},
+							val:        "group",
+							ignoreCase: false,
+							want:       "\"group\"",
+						},
+						&labeledExpr{
+							pos:   position{line: 14, col: 27, offset: 234},
+							label: "count",
+							expr: &ruleRefExpr{
+								pos:  position{line: 14, col: 33, offset: 240},
+								name: "Integer",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 14, col: 41, offset: 248},
+							name: "ExprEnd",
+						},
+						&labeledExpr{
+							pos:   position{line: 14, col: 49, offset: 256},
+							label: "body",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 14, col: 54, offset: 261},
+								expr: &ruleRefExpr{
+									pos:  position{line: 14, col: 54, offset: 261},
+									name: "GroupBody",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 14, col: 65, offset: 272},
+							name: "GroupEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "GroupBody",
+			pos:  position{line: 20, col: 1, offset: 402},
+			expr: &actionExpr{
+				pos: position{line: 20, col: 13, offset: 414},
+				run: (*parser).callonGroupBody1,
+				expr: &seqExpr{
+					pos: position{line: 20, col: 13, offset: 414},
+					exprs: []interface{}{
+						&notExpr{
+							pos: position{line: 20, col: 13, offset: 414},
+							expr: &ruleRefExpr{
+								pos:  position{line: 20, col: 14, offset: 415},
+								name: "GroupEnd",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 20, col: 23, offset: 424},
+							label: "e",
+							expr: &choiceExpr{
+								pos: position{line: 20, col: 27, offset: 428},
+								alternatives: []interface{}{
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 27, offset: 428},
+										name: "Group",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 35, offset: 436},
+										name: "Wait",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 42, offset: 443},
+										name: "WaitForText",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 56, offset: 457},
+										name: "WaitForPort",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 70, offset: 471},
+										name: "WaitForHTTP",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 84, offset: 485},
+										name: "Macro",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 92, offset: 493},
+										name: "CharToggle",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 105, offset: 506},
+										name: "Special",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 20, col: 115, offset: 516},
+										name: "Literal",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "GroupEnd",
+			pos:  position{line: 24, col: 1, offset: 549},
+			expr: &seqExpr{
+				pos: position{line: 24, col: 12, offset: 560},
+				exprs: []interface{}{
+					&ruleRefExpr{
+						pos:  position{line: 24, col: 12, offset: 560},
+						name: "ExprStart",
+					},
+					&litMatcher{
+						pos:        position{line: 24, col: 22, offset: 570},
+						val:        "/group",
+						ignoreCase: false,
+						want:       "\"/group\"",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 24, col: 31, offset: 579},
+						name: "ExprEnd",
+					},
+				},
+			},
+		},
 		{
 			name: "Wait",
-			pos:  position{line: 14, col: 1, offset: 150},
+			pos:  position{line: 26, col: 1, offset: 588},
 			expr: &actionExpr{
-				pos: position{line: 14, col: 8, offset: 157},
+				pos: position{line: 26, col: 8, offset: 595},
 				run: (*parser).callonWait1,
 				expr: &seqExpr{
-					pos: position{line: 14, col: 8, offset: 157},
+					pos: position{line: 26, col: 8, offset: 595},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 14, col: 8, offset: 157},
+							pos:  position{line: 26, col: 8, offset: 595},
 							name: "ExprStart",
 						},
 						&litMatcher{
-							pos:        position{line: 14, col: 18, offset: 167},
+							pos:        position{line: 26, col: 18, offset: 605},
 							val:        "wait",
 							ignoreCase: false,
 							want:       "\"wait\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 14, col: 25, offset: 174},
+							pos:   position{line: 26, col: 25, offset: 612},
 							label: "duration",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 14, col: 34, offset: 183},
+								pos: position{line: 26, col: 34, offset: 621},
 								expr: &choiceExpr{
-									pos: position{line: 14, col: 36, offset: 185},
+									pos: position{line: 26, col: 36, offset: 623},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 14, col: 36, offset: 185},
+											pos:  position{line: 26, col: 36, offset: 623},
 											name: "Duration",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 14, col: 47, offset: 196},
+											pos:  position{line: 26, col: 47, offset: 634},
 											name: "Integer",
 										},
 									},
@@ -122,53 +280,339 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 14, col: 58, offset: 207},
+							pos:  position{line: 26, col: 58, offset: 645},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "WaitForText",
+			pos:  position{line: 39, col: 1, offset: 891},
+			expr: &actionExpr{
+				pos: position{line: 39, col: 15, offset: 905},
+				run: (*parser).callonWaitForText1,
+				expr: &seqExpr{
+					pos: position{line: 39, col: 15, offset: 905},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 39, col: 15, offset: 905},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 39, col: 25, offset: 915},
+							val:        "waitForText",
+							ignoreCase: false,
+							want:       "\"waitForText\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 39, col: 39, offset: 929},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 39, col: 41, offset: 931},
+							label: "text",
+							expr: &ruleRefExpr{
+								pos:  position{line: 39, col: 46, offset: 936},
+								name: "QuotedText",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 39, col: 57, offset: 947},
+							label: "timeout",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 39, col: 65, offset: 955},
+								expr: &ruleRefExpr{
+									pos:  position{line: 39, col: 65, offset: 955},
+									name: "WaitForTimeout",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 39, col: 81, offset: 971},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "WaitForTimeout",
+			pos:  position{line: 47, col: 1, offset: 1138},
+			expr: &actionExpr{
+				pos: position{line: 47, col: 18, offset: 1155},
+				run: (*parser).callonWaitForTimeout1,
+				expr: &seqExpr{
+					pos: position{line: 47, col: 18, offset: 1155},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 47, col: 18, offset: 1155},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 47, col: 20, offset: 1157},
+							label: "d",
+							expr: &ruleRefExpr{
+								pos:  position{line: 47, col: 22, offset: 1159},
+								name: "Duration",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "QuotedText",
+			pos:  position{line: 51, col: 1, offset: 1191},
+			expr: &actionExpr{
+				pos: position{line: 51, col: 14, offset: 1204},
+				run: (*parser).callonQuotedText1,
+				expr: &seqExpr{
+					pos: position{line: 51, col: 14, offset: 1204},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 51, col: 14, offset: 1204},
+							val:        "'",
+							ignoreCase: false,
+							want:       "\"'\"",
+						},
+						&oneOrMoreExpr{
+							pos: position{line: 51, col: 18, offset: 1208},
+							expr: &charClassMatcher{
+								pos:        position{line: 51, col: 18, offset: 1208},
+								val:        "[^']",
+								chars:      []rune{'\''},
+								ignoreCase: false,
+								inverted:   true,
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 51, col: 24, offset: 1214},
+							val:        "'",
+							ignoreCase: false,
+							want:       "\"'\"",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "WaitForPort",
+			pos:  position{line: 55, col: 1, offset: 1273},
+			expr: &actionExpr{
+				pos: position{line: 55, col: 15, offset: 1287},
+				run: (*parser).callonWaitForPort1,
+				expr: &seqExpr{
+					pos: position{line: 55, col: 15, offset: 1287},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 55, col: 15, offset: 1287},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 55, col: 25, offset: 1297},
+							val:        "waitForPort",
+							ignoreCase: false,
+							want:       "\"waitForPort\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 55, col: 39, offset: 1311},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 55, col: 41, offset: 1313},
+							label: "port",
+							expr: &ruleRefExpr{
+								pos:  position{line: 55, col: 46, offset: 1318},
+								name: "Integer",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 55, col: 54, offset: 1326},
+							label: "timeout",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 55, col: 62, offset: 1334},
+								expr: &ruleRefExpr{
+									pos:  position{line: 55, col: 62, offset: 1334},
+									name: "WaitForTimeout",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 55, col: 78, offset: 1350},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "WaitForHTTP",
+			pos:  position{line: 63, col: 1, offset: 1521},
+			expr: &actionExpr{
+				pos: position{line: 63, col: 15, offset: 1535},
+				run: (*parser).callonWaitForHTTP1,
+				expr: &seqExpr{
+					pos: position{line: 63, col: 15, offset: 1535},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 63, col: 15, offset: 1535},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 63, col: 25, offset: 1545},
+							val:        "waitForHTTP",
+							ignoreCase: false,
+							want:       "\"waitForHTTP\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 63, col: 39, offset: 1559},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 63, col: 41, offset: 1561},
+							label: "path",
+							expr: &ruleRefExpr{
+								pos:  position{line: 63, col: 46, offset: 1566},
+								name: "HTTPPath",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 63, col: 55, offset: 1575},
+							label: "timeout",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 63, col: 63, offset: 1583},
+								expr: &ruleRefExpr{
+									pos:  position{line: 63, col: 63, offset: 1583},
+									name: "WaitForTimeout",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 63, col: 79, offset: 1599},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "HTTPPath",
+			pos:  position{line: 71, col: 1, offset: 1766},
+			expr: &actionExpr{
+				pos: position{line: 71, col: 12, offset: 1777},
+				run: (*parser).callonHTTPPath1,
+				expr: &oneOrMoreExpr{
+					pos: position{line: 71, col: 12, offset: 1777},
+					expr: &charClassMatcher{
+						pos:        position{line: 71, col: 12, offset: 1777},
+						val:        "[^ \\t>]",
+						chars:      []rune{' ', '\t', '>'},
+						ignoreCase: false,
+						inverted:   true,
+					},
+				},
+			},
+		},
+		{
+			name: "Macro",
+			pos:  position{line: 75, col: 1, offset: 1822},
+			expr: &actionExpr{
+				pos: position{line: 75, col: 9, offset: 1830},
+				run: (*parser).callonMacro1,
+				expr: &seqExpr{
+					pos: position{line: 75, col: 9, offset: 1830},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 75, col: 9, offset: 1830},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 75, col: 19, offset: 1840},
+							val:        "macro",
+							ignoreCase: false,
+							want:       "\"macro\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 75, col: 27, offset: 1848},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 75, col: 29, offset: 1850},
+							label: "name",
+							expr: &ruleRefExpr{
+								pos:  position{line: 75, col: 34, offset: 1855},
+								name: "MacroName",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 75, col: 44, offset: 1865},
 							name: "ExprEnd",
 						},
 					},
 				},
 			},
 		},
+		{
+			name: "MacroName",
+			pos:  position{line: 79, col: 1, offset: 1931},
+			expr: &actionExpr{
+				pos: position{line: 79, col: 13, offset: 1943},
+				run: (*parser).callonMacroName1,
+				expr: &oneOrMoreExpr{
+					pos: position{line: 79, col: 13, offset: 1943},
+					expr: &charClassMatcher{
+						pos:        position{line: 79, col: 13, offset: 1943},
+						val:        "[a-zA-Z0-9_.-]",
+						chars:      []rune{'_', '.', '-'},
+						ranges:     []rune{'a', 'z', 'A', 'Z', '0', '9'},
+						ignoreCase: false,
+						inverted:   false,
+					},
+				},
+			},
+		},
 		{
 			name: "CharToggle",
-			pos:  position{line: 27, col: 1, offset: 453},
+			pos:  position{line: 83, col: 1, offset: 1995},
 			expr: &actionExpr{
-				pos: position{line: 27, col: 14, offset: 466},
+				pos: position{line: 83, col: 14, offset: 2008},
 				run: (*parser).callonCharToggle1,
 				expr: &seqExpr{
-					pos: position{line: 27, col: 14, offset: 466},
+					pos: position{line: 83, col: 14, offset: 2008},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 27, col: 14, offset: 466},
+							pos:  position{line: 83, col: 14, offset: 2008},
 							name: "ExprStart",
 						},
 						&labeledExpr{
-							pos:   position{line: 27, col: 24, offset: 476},
+							pos:   position{line: 83, col: 24, offset: 2018},
 							label: "lit",
 							expr: &ruleRefExpr{
-								pos:  position{line: 27, col: 29, offset: 481},
+								pos:  position{line: 83, col: 29, offset: 2023},
 								name: "Literal",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 27, col: 38, offset: 490},
+							pos:   position{line: 83, col: 38, offset: 2032},
 							label: "t",
 							expr: &choiceExpr{
-								pos: position{line: 27, col: 41, offset: 493},
+								pos: position{line: 83, col: 41, offset: 2035},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 27, col: 41, offset: 493},
+										pos:  position{line: 83, col: 41, offset: 2035},
 										name: "On",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 27, col: 46, offset: 498},
+										pos:  position{line: 83, col: 46, offset: 2040},
 										name: "Off",
 									},
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 27, col: 51, offset: 503},
+							pos:  position{line: 83, col: 51, offset: 2045},
 							name: "ExprEnd",
 						},
 					},
@@ -177,39 +621,39 @@ var g = &grammar{
 		},
 		{
 			name: "Special",
-			pos:  position{line: 31, col: 1, offset: 574},
+			pos:  position{line: 87, col: 1, offset: 2116},
 			expr: &actionExpr{
-				pos: position{line: 31, col: 11, offset: 584},
+				pos: position{line: 87, col: 11, offset: 2126},
 				run: (*parser).callonSpecial1,
 				expr: &seqExpr{
-					pos: position{line: 31, col: 11, offset: 584},
+					pos: position{line: 87, col: 11, offset: 2126},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 31, col: 11, offset: 584},
+							pos:  position{line: 87, col: 11, offset: 2126},
 							name: "ExprStart",
 						},
 						&labeledExpr{
-							pos:   position{line: 31, col: 21, offset: 594},
+							pos:   position{line: 87, col: 21, offset: 2136},
 							label: "s",
 							expr: &ruleRefExpr{
-								pos:  position{line: 31, col: 24, offset: 597},
+								pos:  position{line: 87, col: 24, offset: 2139},
 								name: "SpecialKey",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 31, col: 36, offset: 609},
+							pos:   position{line: 87, col: 36, offset: 2151},
 							label: "t",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 31, col: 38, offset: 611},
+								pos: position{line: 87, col: 38, offset: 2153},
 								expr: &choiceExpr{
-									pos: position{line: 31, col: 39, offset: 612},
+									pos: position{line: 87, col: 39, offset: 2154},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 31, col: 39, offset: 612},
+											pos:  position{line: 87, col: 39, offset: 2154},
 											name: "On",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 31, col: 44, offset: 617},
+											pos:  position{line: 87, col: 44, offset: 2159},
 											name: "Off",
 										},
 									},
@@ -217,7 +661,7 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 31, col: 50, offset: 623},
+							pos:  position{line: 87, col: 50, offset: 2165},
 							name: "ExprEnd",
 						},
 					},
@@ -226,41 +670,41 @@ var g = &grammar{
 		},
 		{
 			name: "Number",
-			pos:  position{line: 39, col: 1, offset: 810},
+			pos:  position{line: 95, col: 1, offset: 2352},
 			expr: &actionExpr{
-				pos: position{line: 39, col: 10, offset: 819},
+				pos: position{line: 95, col: 10, offset: 2361},
 				run: (*parser).callonNumber1,
 				expr: &seqExpr{
-					pos: position{line: 39, col: 10, offset: 819},
+					pos: position{line: 95, col: 10, offset: 2361},
 					exprs: []interface{}{
 						&zeroOrOneExpr{
-							pos: position{line: 39, col: 10, offset: 819},
+							pos: position{line: 95, col: 10, offset: 2361},
 							expr: &litMatcher{
-								pos:        position{line: 39, col: 10, offset: 819},
+								pos:        position{line: 95, col: 10, offset: 2361},
 								val:        "-",
 								ignoreCase: false,
 								want:       "\"-\"",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 39, col: 15, offset: 824},
+							pos:  position{line: 95, col: 15, offset: 2366},
 							name: "Integer",
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 39, col: 23, offset: 832},
+							pos: position{line: 95, col: 23, offset: 2374},
 							expr: &seqExpr{
-								pos: position{line: 39, col: 25, offset: 834},
+								pos: position{line: 95, col: 25, offset: 2376},
 								exprs: []interface{}{
 									&litMatcher{
-										pos:        position{line: 39, col: 25, offset: 834},
+										pos:        position{line: 95, col: 25, offset: 2376},
 										val:        ".",
 										ignoreCase: false,
 										want:       "\".\"",
 									},
 									&oneOrMoreExpr{
-										pos: position{line: 39, col: 29, offset: 838},
+										pos: position{line: 95, col: 29, offset: 2380},
 										expr: &ruleRefExpr{
-											pos:  position{line: 39, col: 29, offset: 838},
+											pos:  position{line: 95, col: 29, offset: 2380},
 											name: "Digit",
 										},
 									},
@@ -273,30 +717,30 @@ var g = &grammar{
 		},
 		{
 			name: "Integer",
-			pos:  position{line: 43, col: 1, offset: 884},
+			pos:  position{line: 99, col: 1, offset: 2426},
 			expr: &choiceExpr{
-				pos: position{line: 43, col: 11, offset: 894},
+				pos: position{line: 99, col: 11, offset: 2436},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 43, col: 11, offset: 894},
+						pos:        position{line: 99, col: 11, offset: 2436},
 						val:        "0",
 						ignoreCase: false,
 						want:       "\"0\"",
 					},
 					&actionExpr{
-						pos: position{line: 43, col: 17, offset: 900},
+						pos: position{line: 99, col: 17, offset: 2442},
 						run: (*parser).callonInteger3,
 						expr: &seqExpr{
-							pos: position{line: 43, col: 17, offset: 900},
+							pos: position{line: 99, col: 17, offset: 2442},
 							exprs: []interface{}{
 								&ruleRefExpr{
-									pos:  position{line: 43, col: 17, offset: 900},
+									pos:  position{line: 99, col: 17, offset: 2442},
 									name: "NonZeroDigit",
 								},
 								&zeroOrMoreExpr{
-									pos: position{line: 43, col: 30, offset: 913},
+									pos: position{line: 99, col: 30, offset: 2455},
 									expr: &ruleRefExpr{
-										pos:  position{line: 43, col: 30, offset: 913},
+										pos:  position{line: 99, col: 30, offset: 2455},
 										name: "Digit",
 									},
 								},
@@ -308,21 +752,21 @@ var g = &grammar{
 		},
 		{
 			name: "Duration",
-			pos:  position{line: 47, col: 1, offset: 977},
+			pos:  position{line: 103, col: 1, offset: 2519},
 			expr: &actionExpr{
-				pos: position{line: 47, col: 12, offset: 988},
+				pos: position{line: 103, col: 12, offset: 2530},
 				run: (*parser).callonDuration1,
 				expr: &oneOrMoreExpr{
-					pos: position{line: 47, col: 12, offset: 988},
+					pos: position{line: 103, col: 12, offset: 2530},
 					expr: &seqExpr{
-						pos: position{line: 47, col: 14, offset: 990},
+						pos: position{line: 103, col: 14, offset: 2532},
 						exprs: []interface{}{
 							&ruleRefExpr{
-								pos:  position{line: 47, col: 14, offset: 990},
+								pos:  position{line: 103, col: 14, offset: 2532},
 								name: "Number",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 47, col: 21, offset: 997},
+								pos:  position{line: 103, col: 21, offset: 2539},
 								name: "TimeUnit",
 							},
 						},
@@ -332,12 +776,12 @@ var g = &grammar{
 		},
 		{
 			name: "On",
-			pos:  position{line: 51, col: 1, offset: 1060},
+			pos:  position{line: 107, col: 1, offset: 2602},
 			expr: &actionExpr{
-				pos: position{line: 51, col: 6, offset: 1065},
+				pos: position{line: 107, col: 6, offset: 2607},
 				run: (*parser).callonOn1,
 				expr: &litMatcher{
-					pos:        position{line: 51, col: 6, offset: 1065},
+					pos:        position{line: 107, col: 6, offset: 2607},
 					val:        "on",
 					ignoreCase: true,
 					want:       "\"on\"i",
@@ -346,12 +790,12 @@ var g = &grammar{
 		},
 		{
 			name: "Off",
-			pos:  position{line: 55, col: 1, offset: 1098},
+			pos:  position{line: 111, col: 1, offset: 2640},
 			expr: &actionExpr{
-				pos: position{line: 55, col: 7, offset: 1104},
+				pos: position{line: 111, col: 7, offset: 2646},
 				run: (*parser).callonOff1,
 				expr: &litMatcher{
-					pos:        position{line: 55, col: 7, offset: 1104},
+					pos:        position{line: 111, col: 7, offset: 2646},
 					val:        "off",
 					ignoreCase: true,
 					want:       "\"off\"i",
@@ -360,20 +804,20 @@ var g = &grammar{
 		},
 		{
 			name: "Literal",
-			pos:  position{line: 59, col: 1, offset: 1139},
+			pos:  position{line: 115, col: 1, offset: 2681},
 			expr: &actionExpr{
-				pos: position{line: 59, col: 11, offset: 1149},
+				pos: position{line: 115, col: 11, offset: 2691},
 				run: (*parser).callonLiteral1,
 				expr: &anyMatcher{
-					line: 59, col: 11, offset: 1149,
+					line: 115, col: 11, offset: 2691,
 				},
 			},
 		},
 		{
 			name: "ExprEnd",
-			pos:  position{line: 64, col: 1, offset: 1230},
+			pos:  position{line: 120, col: 1, offset: 2772},
 			expr: &litMatcher{
-				pos:        position{line: 64, col: 11, offset: 1240},
+				pos:        position{line: 120, col: 11, offset: 2782},
 				val:        ">",
 				ignoreCase: false,
 				want:       "\">\"",
@@ -381,9 +825,9 @@ var g = &grammar{
 		},
 		{
 			name: "ExprStart",
-			pos:  position{line: 65, col: 1, offset: 1244},
+			pos:  position{line: 121, col: 1, offset: 2786},
 			expr: &litMatcher{
-				pos:        position{line: 65, col: 13, offset: 1256},
+				pos:        position{line: 121, col: 13, offset: 2798},
 				val:        "<",
 				ignoreCase: false,
 				want:       "\"<\"",
@@ -391,222 +835,450 @@ var g = &grammar{
 		},
 		{
 			name: "SpecialKey",
-			pos:  position{line: 66, col: 1, offset: 1260},
+			pos:  position{line: 122, col: 1, offset: 2802},
 			expr: &choiceExpr{
-				pos: position{line: 66, col: 14, offset: 1273},
+				pos: position{line: 122, col: 14, offset: 2815},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 66, col: 14, offset: 1273},
+						pos:        position{line: 122, col: 14, offset: 2815},
 						val:        "bs",
 						ignoreCase: true,
 						want:       "\"bs\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 22, offset: 1281},
+						pos:        position{line: 122, col: 22, offset: 2823},
 						val:        "del",
 						ignoreCase: true,
 						want:       "\"del\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 31, offset: 1290},
+						pos:        position{line: 122, col: 31, offset: 2832},
 						val:        "enter",
 						ignoreCase: true,
 						want:       "\"enter\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 42, offset: 1301},
+						pos:        position{line: 122, col: 42, offset: 2843},
 						val:        "esc",
 						ignoreCase: true,
 						want:       "\"esc\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 51, offset: 1310},
+						pos:        position{line: 123, col: 11, offset: 2860},
 						val:        "f10",
 						ignoreCase: true,
 						want:       "\"f10\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 60, offset: 1319},
+						pos:        position{line: 123, col: 20, offset: 2869},
 						val:        "f11",
 						ignoreCase: true,
 						want:       "\"f11\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 69, offset: 1328},
+						pos:        position{line: 123, col: 29, offset: 2878},
 						val:        "f12",
 						ignoreCase: true,
 						want:       "\"f12\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 11, offset: 1345},
+						pos:        position{line: 123, col: 38, offset: 2887},
+						val:        "f13",
+						ignoreCase: true,
+						want:       "\"f13\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 123, col: 47, offset: 2896},
+						val:        "f14",
+						ignoreCase: true,
+						want:       "\"f14\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 123, col: 56, offset: 2905},
+						val:        "f15",
+						ignoreCase: true,
+						want:       "\"f15\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 123, col: 65, offset: 2914},
+						val:        "f16",
+						ignoreCase: true,
+						want:       "\"f16\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 11, offset: 2931},
+						val:        "f17",
+						ignoreCase: true,
+						want:       "\"f17\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 20, offset: 2940},
+						val:        "f18",
+						ignoreCase: true,
+						want:       "\"f18\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 29, offset: 2949},
+						val:        "f19",
+						ignoreCase: true,
+						want:       "\"f19\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 38, offset: 2958},
+						val:        "f20",
+						ignoreCase: true,
+						want:       "\"f20\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 47, offset: 2967},
+						val:        "f21",
+						ignoreCase: true,
+						want:       "\"f21\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 56, offset: 2976},
+						val:        "f22",
+						ignoreCase: true,
+						want:       "\"f22\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 65, offset: 2985},
+						val:        "f23",
+						ignoreCase: true,
+						want:       "\"f23\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 124, col: 74, offset: 2994},
+						val:        "f24",
+						ignoreCase: true,
+						want:       "\"f24\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 125, col: 11, offset: 3011},
 						val:        "f1",
 						ignoreCase: true,
 						want:       "\"f1\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 19, offset: 1353},
+						pos:        position{line: 125, col: 19, offset: 3019},
 						val:        "f2",
 						ignoreCase: true,
 						want:       "\"f2\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 27, offset: 1361},
+						pos:        position{line: 125, col: 27, offset: 3027},
 						val:        "f3",
 						ignoreCase: true,
 						want:       "\"f3\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 35, offset: 1369},
+						pos:        position{line: 125, col: 35, offset: 3035},
 						val:        "f4",
 						ignoreCase: true,
 						want:       "\"f4\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 43, offset: 1377},
+						pos:        position{line: 125, col: 43, offset: 3043},
 						val:        "f5",
 						ignoreCase: true,
 						want:       "\"f5\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 51, offset: 1385},
+						pos:        position{line: 125, col: 51, offset: 3051},
 						val:        "f6",
 						ignoreCase: true,
 						want:       "\"f6\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 59, offset: 1393},
+						pos:        position{line: 125, col: 59, offset: 3059},
 						val:        "f7",
 						ignoreCase: true,
 						want:       "\"f7\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 67, offset: 1401},
+						pos:        position{line: 125, col: 67, offset: 3067},
 						val:        "f8",
 						ignoreCase: true,
 						want:       "\"f8\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 75, offset: 1409},
+						pos:        position{line: 125, col: 75, offset: 3075},
 						val:        "f9",
 						ignoreCase: true,
 						want:       "\"f9\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 12, offset: 1426},
+						pos:        position{line: 126, col: 11, offset: 3091},
 						val:        "return",
 						ignoreCase: true,
 						want:       "\"return\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 24, offset: 1438},
+						pos:        position{line: 126, col: 23, offset: 3103},
 						val:        "tab",
 						ignoreCase: true,
 						want:       "\"tab\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 33, offset: 1447},
+						pos:        position{line: 126, col: 32, offset: 3112},
 						val:        "up",
 						ignoreCase: true,
 						want:       "\"up\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 41, offset: 1455},
+						pos:        position{line: 126, col: 40, offset: 3120},
 						val:        "down",
 						ignoreCase: true,
 						want:       "\"down\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 51, offset: 1465},
+						pos:        position{line: 126, col: 50, offset: 3130},
 						val:        "spacebar",
 						ignoreCase: true,
 						want:       "\"spacebar\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 65, offset: 1479},
+						pos:        position{line: 126, col: 64, offset: 3144},
 						val:        "insert",
 						ignoreCase: true,
 						want:       "\"insert\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 77, offset: 1491},
+						pos:        position{line: 126, col: 76, offset: 3156},
 						val:        "home",
 						ignoreCase: true,
 						want:       "\"home\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 11, offset: 1509},
+						pos:        position{line: 127, col: 11, offset: 3174},
 						val:        "end",
 						ignoreCase: true,
 						want:       "\"end\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 20, offset: 1518},
+						pos:        position{line: 127, col: 20, offset: 3183},
 						val:        "pageup",
 						ignoreCase: true,
 						want:       "\"pageUp\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 32, offset: 1530},
+						pos:        position{line: 127, col: 32, offset: 3195},
 						val:        "pagedown",
 						ignoreCase: true,
 						want:       "\"pageDown\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 46, offset: 1544},
+						pos:        position{line: 127, col: 46, offset: 3209},
 						val:        "leftalt",
 						ignoreCase: true,
 						want:       "\"leftAlt\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 59, offset: 1557},
+						pos:        position{line: 127, col: 59, offset: 3222},
 						val:        "leftctrl",
 						ignoreCase: true,
 						want:       "\"leftCtrl\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 73, offset: 1571},
+						pos:        position{line: 127, col: 73, offset: 3236},
 						val:        "leftshift",
 						ignoreCase: true,
 						want:       "\"leftShift\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 11, offset: 1594},
+						pos:        position{line: 128, col: 11, offset: 3259},
 						val:        "rightalt",
 						ignoreCase: true,
 						want:       "\"rightAlt\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 25, offset: 1608},
+						pos:        position{line: 128, col: 25, offset: 3273},
 						val:        "rightctrl",
 						ignoreCase: true,
 						want:       "\"rightCtrl\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 40, offset: 1623},
+						pos:        position{line: 128, col: 40, offset: 3288},
 						val:        "rightshift",
 						ignoreCase: true,
 						want:       "\"rightShift\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 56, offset: 1639},
+						pos:        position{line: 128, col: 56, offset: 3304},
 						val:        "leftsuper",
 						ignoreCase: true,
 						want:       "\"leftSuper\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 71, offset: 1654},
+						pos:        position{line: 128, col: 71, offset: 3319},
 						val:        "rightsuper",
 						ignoreCase: true,
 						want:       "\"rightSuper\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 11, offset: 1678},
+						pos:        position{line: 129, col: 11, offset: 3343},
+						val:        "altgr",
+						ignoreCase: true,
+						want:       "\"altGr\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 129, col: 22, offset: 3354},
+						val:        "menu",
+						ignoreCase: true,
+						want:       "\"menu\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 129, col: 32, offset: 3364},
+						val:        "printscreen",
+						ignoreCase: true,
+						want:       "\"printScreen\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 129, col: 49, offset: 3381},
+						val:        "pause",
+						ignoreCase: true,
+						want:       "\"pause\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 129, col: 60, offset: 3392},
+						val:        "capslock",
+						ignoreCase: true,
+						want:       "\"capsLock\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 129, col: 74, offset: 3406},
+						val:        "numlock",
+						ignoreCase: true,
+						want:       "\"numLock\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 129, col: 87, offset: 3419},
+						val:        "scrolllock",
+						ignoreCase: true,
+						want:       "\"scrollLock\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 11, offset: 3443},
+						val:        "kp0",
+						ignoreCase: true,
+						want:       "\"kp0\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 20, offset: 3452},
+						val:        "kp1",
+						ignoreCase: true,
+						want:       "\"kp1\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 29, offset: 3461},
+						val:        "kp2",
+						ignoreCase: true,
+						want:       "\"kp2\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 38, offset: 3470},
+						val:        "kp3",
+						ignoreCase: true,
+						want:       "\"kp3\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 47, offset: 3479},
+						val:        "kp4",
+						ignoreCase: true,
+						want:       "\"kp4\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 56, offset: 3488},
+						val:        "kp5",
+						ignoreCase: true,
+						want:       "\"kp5\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 65, offset: 3497},
+						val:        "kp6",
+						ignoreCase: true,
+						want:       "\"kp6\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 74, offset: 3506},
+						val:        "kp7",
+						ignoreCase: true,
+						want:       "\"kp7\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 83, offset: 3515},
+						val:        "kp8",
+						ignoreCase: true,
+						want:       "\"kp8\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 130, col: 92, offset: 3524},
+						val:        "kp9",
+						ignoreCase: true,
+						want:       "\"kp9\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 131, col: 11, offset: 3541},
+						val:        "kpdecimal",
+						ignoreCase: true,
+						want:       "\"kpDecimal\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 131, col: 26, offset: 3556},
+						val:        "kpdivide",
+						ignoreCase: true,
+						want:       "\"kpDivide\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 131, col: 40, offset: 3570},
+						val:        "kpmultiply",
+						ignoreCase: true,
+						want:       "\"kpMultiply\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 131, col: 56, offset: 3586},
+						val:        "kpminus",
+						ignoreCase: true,
+						want:       "\"kpMinus\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 131, col: 69, offset: 3599},
+						val:        "kpplus",
+						ignoreCase: true,
+						want:       "\"kpPlus\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 131, col: 81, offset: 3611},
+						val:        "kpenter",
+						ignoreCase: true,
+						want:       "\"kpEnter\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 132, col: 11, offset: 3632},
+						val:        "mute",
+						ignoreCase: true,
+						want:       "\"mute\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 132, col: 21, offset: 3642},
+						val:        "volumedown",
+						ignoreCase: true,
+						want:       "\"volumeDown\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 132, col: 37, offset: 3658},
+						val:        "volumeup",
+						ignoreCase: true,
+						want:       "\"volumeUp\"i",
+					},
+					&litMatcher{
+						pos:        position{line: 133, col: 11, offset: 3680},
 						val:        "left",
 						ignoreCase: true,
 						want:       "\"left\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 21, offset: 1688},
+						pos:        position{line: 133, col: 21, offset: 3690},
 						val:        "right",
 						ignoreCase: true,
 						want:       "\"right\"i",
@@ -616,9 +1288,9 @@ var g = &grammar{
 		},
 		{
 			name: "NonZeroDigit",
-			pos:  position{line: 73, col: 1, offset: 1698},
+			pos:  position{line: 135, col: 1, offset: 3700},
 			expr: &charClassMatcher{
-				pos:        position{line: 73, col: 16, offset: 1713},
+				pos:        position{line: 135, col: 16, offset: 3715},
 				val:        "[1-9]",
 				ranges:     []rune{'1', '9'},
 				ignoreCase: false,
@@ -627,9 +1299,9 @@ var g = &grammar{
 		},
 		{
 			name: "Digit",
-			pos:  position{line: 74, col: 1, offset: 1719},
+			pos:  position{line: 136, col: 1, offset: 3721},
 			expr: &charClassMatcher{
-				pos:        position{line: 74, col: 9, offset: 1727},
+				pos:        position{line: 136, col: 9, offset: 3729},
 				val:        "[0-9]",
 				ranges:     []rune{'0', '9'},
 				ignoreCase: false,
@@ -638,48 +1310,48 @@ var g = &grammar{
 		},
 		{
 			name: "TimeUnit",
-			pos:  position{line: 75, col: 1, offset: 1733},
+			pos:  position{line: 137, col: 1, offset: 3735},
 			expr: &choiceExpr{
-				pos: position{line: 75, col: 13, offset: 1745},
+				pos: position{line: 137, col: 13, offset: 3747},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 75, col: 13, offset: 1745},
+						pos:        position{line: 137, col: 13, offset: 3747},
 						val:        "ns",
 						ignoreCase: false,
 						want:       "\"ns\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 20, offset: 1752},
+						pos:        position{line: 137, col: 20, offset: 3754},
 						val:        "us",
 						ignoreCase: false,
 						want:       "\"us\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 27, offset: 1759},
+						pos:        position{line: 137, col: 27, offset: 3761},
 						val:        "µs",
 						ignoreCase: false,
 						want:       "\"µs\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 34, offset: 1767},
+						pos:        position{line: 137, col: 34, offset: 3769},
 						val:        "ms",
 						ignoreCase: false,
 						want:       "\"ms\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 41, offset: 1774},
+						pos:        position{line: 137, col: 41, offset: 3776},
 						val:        "s",
 						ignoreCase: false,
 						want:       "\"s\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 47, offset: 1780},
+						pos:        position{line: 137, col: 47, offset: 3782},
 						val:        "m",
 						ignoreCase: false,
 						want:       "\"m\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 53, offset: 1786},
+						pos:        position{line: 137, col: 53, offset: 3788},
 						val:        "h",
 						ignoreCase: false,
 						want:       "\"h\"",
@@ -690,11 +1362,11 @@ var g = &grammar{
 		{
 			name:        "_",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 77, col: 1, offset: 1792},
+			pos:         position{line: 139, col: 1, offset: 3794},
 			expr: &zeroOrMoreExpr{
-				pos: position{line: 77, col: 19, offset: 1810},
+				pos: position{line: 139, col: 19, offset: 3812},
 				expr: &charClassMatcher{
-					pos:        position{line: 77, col: 19, offset: 1810},
+					pos:        position{line: 139, col: 19, offset: 3812},
 					val:        "[ \\n\\t\\r]",
 					chars:      []rune{' ', '\n', '\t', '\r'},
 					ignoreCase: false,
@@ -704,11 +1376,11 @@ var g = &grammar{
 		},
 		{
 			name: "EOF",
-			pos:  position{line: 79, col: 1, offset: 1822},
+			pos:  position{line: 141, col: 1, offset: 3824},
 			expr: &notExpr{
-				pos: position{line: 79, col: 8, offset: 1829},
+				pos: position{line: 141, col: 8, offset: 3831},
 				expr: &anyMatcher{
-					line: 79, col: 9, offset: 1830,
+					line: 141, col: 9, offset: 3832,
 				},
 			},
 		},
@@ -735,6 +1407,28 @@ func (p *parser) callonExpr1() (interface{}, error) {
 	return p.cur.onExpr1(stack["l"])
 }
 
+func (c *current) onGroup1(count, body interface{}) (interface{}, error) {
+	// Integer leaves 0 unconverted.
+	n, _ := count.(int64)
+	return repeatGroup(int(n), body.([]interface{}))
+}
+
+func (p *parser) callonGroup1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onGroup1(stack["count"], stack["body"])
+}
+
+func (c *current) onGroupBody1(e interface{}) (interface{}, error) {
+	return e, nil
+}
+
+func (p *parser) callonGroupBody1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onGroupBody1(stack["e"])
+}
+
 func (c *current) onWait1(duration interface{}) (interface{}, error) {
 	var d time.Duration
 	switch t := duration.(type) {
@@ -754,6 +1448,98 @@ func (p *parser) callonWait1() (interface{}, error) {
 	return p.cur.onWait1(stack["duration"])
 }
 
+func (c *current) onWaitForText1(text, timeout interface{}) (interface{}, error) {
+	d := DefaultWaitForTimeout
+	if timeout != nil {
+		d = timeout.(time.Duration)
+	}
+	return &waitForTextExpression{text.(string), d}, nil
+}
+
+func (p *parser) callonWaitForText1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWaitForText1(stack["text"], stack["timeout"])
+}
+
+func (c *current) onWaitForTimeout1(d interface{}) (interface{}, error) {
+	return d, nil
+}
+
+func (p *parser) callonWaitForTimeout1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWaitForTimeout1(stack["d"])
+}
+
+func (c *current) onQuotedText1() (interface{}, error) {
+	return string(c.text[1 : len(c.text)-1]), nil
+}
+
+func (p *parser) callonQuotedText1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onQuotedText1()
+}
+
+func (c *current) onWaitForPort1(port, timeout interface{}) (interface{}, error) {
+	d := DefaultWaitForTimeout
+	if timeout != nil {
+		d = timeout.(time.Duration)
+	}
+	return &waitForPortExpression{int(port.(int64)), d}, nil
+}
+
+func (p *parser) callonWaitForPort1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWaitForPort1(stack["port"], stack["timeout"])
+}
+
+func (c *current) onWaitForHTTP1(path, timeout interface{}) (interface{}, error) {
+	d := DefaultWaitForTimeout
+	if timeout != nil {
+		d = timeout.(time.Duration)
+	}
+	return &waitForHTTPExpression{path.(string), d}, nil
+}
+
+func (p *parser) callonWaitForHTTP1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWaitForHTTP1(stack["path"], stack["timeout"])
+}
+
+func (c *current) onHTTPPath1() (interface{}, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonHTTPPath1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onHTTPPath1()
+}
+
+func (c *current) onMacro1(name interface{}) (interface{}, error) {
+	return expandMacro(c.globalStore, name.(string))
+}
+
+func (p *parser) callonMacro1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMacro1(stack["name"])
+}
+
+func (c *current) onMacroName1() (interface{}, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonMacroName1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMacroName1()
+}
+
 func (c *current) onCharToggle1(lit, t interface{}) (interface{}, error) {
 	return &literal{lit.(*literal).s, t.(KeyAction)}, nil
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -54,17 +55,50 @@ func (s expressionSequence) Do(ctx context.Context, b BCDriver) error {
 		return fmt.Errorf("Found an invalid boot command. This is likely an error in Packer, so please open a ticket.")
 	}
 
-	for _, exp := range s {
+	sender, _ := findDriver(b, func(d BCDriver) bool {
+		_, ok := d.(TextSender)
+		return ok
+	}).(TextSender)
+
+	for i := 0; i < len(s); i++ {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if err := exp.Do(ctx, b); err != nil {
+		if sender != nil {
+			if text, n := s.textRun(i); n > 0 {
+				if err := b.Flush(); err != nil {
+					return err
+				}
+				log.Printf("[INFO] Sending %d chars at once", n)
+				if err := sender.SendText(text); err != nil {
+					return err
+				}
+				i += n - 1
+				continue
+			}
+		}
+		if err := s[i].Do(ctx, b); err != nil {
 			return err
 		}
 	}
 	return b.Flush()
 }
 
+// textRun returns the characters pressed by the literals from i, and their
+// number.
+func (s expressionSequence) textRun(i int) (string, int) {
+	var text strings.Builder
+	n := 0
+	for ; i+n < len(s); n++ {
+		l, ok := s[i+n].(*literal)
+		if !ok || l.action != KeyPress {
+			break
+		}
+		text.WriteRune(l.s)
+	}
+	return text.String(), n
+}
+
 // Validate tells us if every expression in the sequence is valid.
 func (s expressionSequence) Validate() (errs []error) {
 	for _, exp := range s {
@@ -78,17 +112,158 @@ func (s expressionSequence) Validate() (errs []error) {
 // GenerateExpressionSequence generates a sequence of expressions from the
 // given command. This is the primary entry point to the boot command parser.
 func GenerateExpressionSequence(command string) (expressionSequence, error) {
+	return GenerateExpressionSequenceWithMacros(command, nil)
+}
+
+// GenerateExpressionSequenceWithMacros generates a sequence of expressions
+// from the given command like GenerateExpressionSequence, expanding the
+// `<macro name>` expressions to the fragments of macros, or of the macros
+// registered with RegisterMacro. Builders pass the BootCommandMacros of their
+// BootConfig, rendered with the template engine like the boot command.
+func GenerateExpressionSequenceWithMacros(command string, macros map[string]string) (expressionSequence, error) {
+	return parseExpressionSequence(command, &macroExpansion{macros: macros})
+}
+
+func parseExpressionSequence(command string, m *macroExpansion) (expressionSequence, error) {
 	seq := expressionSequence{}
 	if command == "" {
 		return seq, nil
 	}
-	got, err := ParseReader("", strings.NewReader(command))
+	got, err := ParseReader("", strings.NewReader(command), GlobalStore(macroExpansionKey, m))
 	if err != nil {
 		return nil, err
 	}
-	for _, exp := range got.([]interface{}) {
+	return flattenExpressions(seq, got.([]interface{})), nil
+}
+
+// flattenExpressions appends the expressions parsed to seq, with the
+// sequences of the macros and groups expanded in place.
+func flattenExpressions(seq expressionSequence, parsed []interface{}) expressionSequence {
+	for _, exp := range parsed {
+		if expanded, ok := exp.(expressionSequence); ok {
+			seq = append(seq, expanded...)
+			continue
+		}
 		seq = append(seq, exp.(expression))
 	}
+	return seq
+}
+
+// maxGroupExpressions bounds the expressions of a repeated group, nested
+// groups included, so that a typo in a count can't exhaust the memory. It
+// bounds the expressions the macros of a boot command expand to as well.
+const maxGroupExpressions = 100000
+
+var errMacrosTooLarge = fmt.Errorf("Boot command macros expand to more than %d expressions", maxGroupExpressions)
+
+// repeatGroup returns the sequence of the `<groupN>...</group>` expressions,
+// the expressions of body repeated count times.
+func repeatGroup(count int, body []interface{}) (expressionSequence, error) {
+	once := flattenExpressions(nil, body)
+	if count > 0 && len(once) > maxGroupExpressions/count {
+		return nil, fmt.Errorf("Boot command group repeats more than %d expressions", maxGroupExpressions)
+	}
+	seq := make(expressionSequence, 0, count*len(once))
+	for i := 0; i < count; i++ {
+		seq = append(seq, once...)
+	}
+	return seq, nil
+}
+
+var (
+	registeredMacrosMu sync.RWMutex
+	registeredMacros   = map[string]string{}
+)
+
+// RegisterMacro registers the boot command fragment command as the macro
+// name, referenced from the boot commands with `<macro name>`, so that
+// builders and plugins can share long sequences, like the ones starting the
+// OS installers. The macros of the boot configuration override them.
+func RegisterMacro(name, command string) {
+	registeredMacrosMu.Lock()
+	defer registeredMacrosMu.Unlock()
+	registeredMacros[name] = command
+}
+
+// macroExpansionKey is the key of the macroExpansion in the global store of
+// the parser.
+const macroExpansionKey = "macros"
+
+// macroExpansion holds the macros expanded while parsing a boot command, and
+// the ones being expanded, so that a macro can't reference itself.
+type macroExpansion struct {
+	macros    map[string]string
+	expanding []string
+	expanded  *macroExpansions
+}
+
+// macroExpansions are the sequences of the macros expanded while parsing a
+// boot command, shared by the nested expansions, so that each macro is only
+// parsed once. The expressions of every reference to a macro are counted, so
+// that macros referencing each other can't grow exponentially.
+type macroExpansions struct {
+	seqs  map[string]expressionSequence
+	count int
+}
+
+func (m *macroExpansion) lookup(name string) (string, bool) {
+	if command, ok := m.macros[name]; ok {
+		return command, true
+	}
+	registeredMacrosMu.RLock()
+	defer registeredMacrosMu.RUnlock()
+	command, ok := registeredMacros[name]
+	return command, ok
+}
+
+// parseMacro is parseExpressionSequence, set in init to break the
+// initialization cycle between the grammar and its actions.
+var parseMacro func(string, *macroExpansion) (expressionSequence, error)
+
+func init() {
+	parseMacro = parseExpressionSequence
+}
+
+// expandMacro parses the fragment of the macro name.
+func expandMacro(store storeDict, name string) (expressionSequence, error) {
+	m, _ := store[macroExpansionKey].(*macroExpansion)
+	if m == nil {
+		m = &macroExpansion{}
+	}
+	command, ok := m.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown boot command macro %q", name)
+	}
+	for _, expanding := range m.expanding {
+		if expanding == name {
+			return nil, fmt.Errorf("Boot command macro %q references itself: %s -> %s",
+				name, strings.Join(m.expanding, " -> "), name)
+		}
+	}
+	if m.expanded == nil {
+		m.expanded = &macroExpansions{seqs: map[string]expressionSequence{}}
+	}
+	if m.expanded.count > maxGroupExpressions {
+		// An expansion already failed, failing its references too.
+		return nil, errMacrosTooLarge
+	}
+	seq, ok := m.expanded.seqs[name]
+	if !ok {
+		var err error
+		seq, err = parseMacro(command, &macroExpansion{
+			macros:    m.macros,
+			expanding: append(m.expanding[:len(m.expanding):len(m.expanding)], name),
+			expanded:  m.expanded,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error in boot command macro %q: %s", name, err)
+		}
+		m.expanded.seqs[name] = seq
+	}
+	m.expanded.count += len(seq)
+	if m.expanded.count > maxGroupExpressions {
+		return nil, errMacrosTooLarge
+	}
 	return seq, nil
 }
 
@@ -121,6 +296,139 @@ func (w *waitExpression) String() string {
 	return fmt.Sprintf("Wait<%s>", w.d)
 }
 
+// DefaultWaitForTimeout is how long the `<waitForText 'text'>`,
+// `<waitForPort port>` and `<waitForHTTP path>` expressions wait for their
+// condition without a timeout, like `<waitForText 'text' 5m>`.
+const DefaultWaitForTimeout = 10 * time.Minute
+
+// WaitForInterval is the interval at which the `<waitForText 'text'>`,
+// `<waitForPort port>` and `<waitForHTTP path>` expressions check their
+// condition.
+var WaitForInterval = time.Second
+
+// waitFor flushes the driver, then calls check every WaitForInterval until
+// it returns true, for at most timeout. It is cancellable through the
+// context.
+func waitFor(ctx context.Context, driver BCDriver, timeout time.Duration, what string, check func(context.Context) (bool, error)) error {
+	if err := driver.Flush(); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Waiting up to %s for %s", timeout, what)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		ok, err := check(waitCtx)
+		if err != nil && waitCtx.Err() == nil {
+			return fmt.Errorf("Error waiting for %s: %s", what, err)
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-time.After(WaitForInterval):
+		case <-waitCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("Timeout waiting %s for %s.", timeout, what)
+		}
+	}
+}
+
+func validateWaitForTimeout(timeout time.Duration, what string) error {
+	if timeout <= 0 {
+		return fmt.Errorf("Expecting a positive timeout waiting for %s. Got %s", what, timeout)
+	}
+	return nil
+}
+
+type waitForTextExpression struct {
+	text    string
+	timeout time.Duration
+}
+
+// Do waits for the text of the expression to be on the screen of the VM,
+// matched by the driver when it implements ScreenMatcher.
+func (w *waitForTextExpression) Do(ctx context.Context, driver BCDriver) error {
+	matcher, ok := findDriver(driver, func(d BCDriver) bool {
+		_, ok := d.(ScreenMatcher)
+		return ok
+	}).(ScreenMatcher)
+	if !ok {
+		return fmt.Errorf("Can't wait for %q: this builder can't read the screen of the VM.", w.text)
+	}
+	return waitFor(ctx, driver, w.timeout, fmt.Sprintf("%q on the screen", w.text), func(ctx context.Context) (bool, error) {
+		return matcher.MatchText(ctx, w.text)
+	})
+}
+
+// Validate returns an error if the timeout is <= 0
+func (w *waitForTextExpression) Validate() error {
+	return validateWaitForTimeout(w.timeout, fmt.Sprintf("%q", w.text))
+}
+
+func (w *waitForTextExpression) String() string {
+	return fmt.Sprintf("WaitForText<%q, %s>", w.text, w.timeout)
+}
+
+type waitForPortExpression struct {
+	port    int
+	timeout time.Duration
+}
+
+// Do waits for the guest to listen on the port of the expression, checked
+// by the PortOpen condition of the driver, see WithWaitConditions.
+func (w *waitForPortExpression) Do(ctx context.Context, driver BCDriver) error {
+	conditions := driverWaitConditions(driver)
+	if conditions.PortOpen == nil {
+		return fmt.Errorf("Can't wait for port %d: this builder can't check the ports of the VM.", w.port)
+	}
+	return waitFor(ctx, driver, w.timeout, fmt.Sprintf("port %d to open", w.port), func(ctx context.Context) (bool, error) {
+		return conditions.PortOpen(ctx, w.port)
+	})
+}
+
+// Validate returns an error if the port isn't a TCP port or the timeout is
+// <= 0
+func (w *waitForPortExpression) Validate() error {
+	if w.port <= 0 || w.port > 65535 {
+		return fmt.Errorf("Expecting a TCP port to wait for. Got %d", w.port)
+	}
+	return validateWaitForTimeout(w.timeout, fmt.Sprintf("port %d", w.port))
+}
+
+func (w *waitForPortExpression) String() string {
+	return fmt.Sprintf("WaitForPort<%d, %s>", w.port, w.timeout)
+}
+
+type waitForHTTPExpression struct {
+	path    string
+	timeout time.Duration
+}
+
+// Do waits for the guest to request the path of the expression from the
+// HTTP server of the builder, checked by the HTTPRequested condition of the
+// driver, see WithWaitConditions.
+func (w *waitForHTTPExpression) Do(ctx context.Context, driver BCDriver) error {
+	conditions := driverWaitConditions(driver)
+	if conditions.HTTPRequested == nil {
+		return fmt.Errorf("Can't wait for a request to %s: this builder doesn't serve HTTP to the VM.", w.path)
+	}
+	return waitFor(ctx, driver, w.timeout, fmt.Sprintf("a request to %s", w.path), func(ctx context.Context) (bool, error) {
+		return conditions.HTTPRequested(ctx, w.path)
+	})
+}
+
+// Validate returns an error if the timeout is <= 0
+func (w *waitForHTTPExpression) Validate() error {
+	return validateWaitForTimeout(w.timeout, fmt.Sprintf("a request to %s", w.path))
+}
+
+func (w *waitForHTTPExpression) String() string {
+	return fmt.Sprintf("WaitForHTTP<%s, %s>", w.path, w.timeout)
+}
+
 type specialExpression struct {
 	s      string
 	action KeyAction
@@ -4,9 +4,13 @@
 package bootcommand
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -80,6 +84,22 @@ func Test_special(t *testing.T) {
 			"<enteroff><enterOFF><eNtErOfF><ENTEROFF>",
 			"Spec-Off(enter)",
 		},
+		{
+			"<f13><F13>",
+			"Spec-Press(f13)",
+		},
+		{
+			"<kpEnter><kpenter><KPENTER>",
+			"Spec-Press(kpenter)",
+		},
+		{
+			"<printScreenOn><PRINTSCREENON>",
+			"Spec-On(printscreen)",
+		},
+		{
+			"<altGrOff><altgroff>",
+			"Spec-Off(altgr)",
+		},
 	}
 	for _, tt := range specials {
 		seq, err := GenerateExpressionSequence(tt.in)
@@ -92,6 +112,28 @@ func Test_special(t *testing.T) {
 	}
 }
 
+func Test_specialKeysDrivers(t *testing.T) {
+	var keys []string
+	for _, r := range g.rules {
+		if r.name != "SpecialKey" {
+			continue
+		}
+		for _, alt := range r.expr.(*choiceExpr).alternatives {
+			keys = append(keys, alt.(*litMatcher).val)
+		}
+	}
+	assert.NotEmpty(t, keys)
+
+	pcxt := NewPCXTDriver(func([]string) error { return nil }, -1, 0)
+	vnc := NewVNCDriver(&sender{}, 0)
+	usb := NewUSBDriver(nil, 0)
+	for _, key := range keys {
+		assert.Contains(t, pcxt.specialMap, key, "PC-XT driver")
+		assert.Contains(t, vnc.specialMap, key, "VNC driver")
+		assert.Contains(t, usb.specialMap, key, "USB driver")
+	}
+}
+
 func Test_validation(t *testing.T) {
 	var expressions = []struct {
 		in    string
@@ -109,6 +151,14 @@ func Test_validation(t *testing.T) {
 			"<f1>",
 			true,
 		},
+		{
+			"<waitForText 'GRUB' 2m>",
+			true,
+		},
+		{
+			"<waitForText 'GRUB' 0s>",
+			false,
+		},
 		{
 			"<",
 			true,
@@ -135,3 +185,196 @@ func Test_empty(t *testing.T) {
 	assert.NoError(t, err, "should have parsed an empty input okay.")
 	assert.Len(t, exp, 0)
 }
+
+func Test_waitForText(t *testing.T) {
+	seq, err := GenerateExpressionSequence("<waitForText 'boot: '><waitForText 'GRUB loader' 2m>a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`WaitForText<"boot: ", 10m0s>`,
+		`WaitForText<"GRUB loader", 2m0s>`,
+		"LIT-Press(a)",
+	}, []string{fmt.Sprint(seq[0]), fmt.Sprint(seq[1]), fmt.Sprint(seq[2])})
+
+	defer func(d time.Duration) { WaitForInterval = d }(WaitForInterval)
+	WaitForInterval = time.Millisecond
+
+	// The text shows up on the third screen.
+	screens := 0
+	var codes []string
+	driver := WithScreenMatcher(
+		NewPCXTDriver(func(c []string) error {
+			codes = append(codes, c...)
+			return nil
+		}, -1, time.Duration(0)),
+		ScreenTextFunc(func(context.Context) (string, error) {
+			screens++
+			return strings.Repeat("GRUB loader boot: ", screens/3), nil
+		}),
+	)
+	seq, err = GenerateExpressionSequence("a<waitForText 'boot: '>b")
+	assert.NoError(t, err)
+	assert.NoError(t, seq.Do(context.Background(), driver))
+	assert.Equal(t, 3, screens)
+	assert.Equal(t, []string{"1e", "9e", "30", "b0"}, codes)
+
+	seq, err = GenerateExpressionSequence("<waitForText 'login:' 10ms>")
+	assert.NoError(t, err)
+	err = seq.Do(context.Background(), driver)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Timeout")
+
+	// The drivers not reading the screen can't wait for text.
+	err = seq.Do(context.Background(), NewPCXTDriver(nil, -1, time.Duration(0)))
+	assert.Error(t, err)
+}
+
+func Test_macro(t *testing.T) {
+	RegisterMacro("test_esc", "<esc><wait>")
+	macros := map[string]string{
+		"greet":  "<macro test_esc>hi",
+		"loop":   "a<macro loop2>",
+		"loop2":  "<macro loop>",
+		"custom": "<f1>",
+	}
+
+	seq, err := GenerateExpressionSequenceWithMacros("<macro greet><enter><macro custom>", macros)
+	assert.NoError(t, err)
+	var actual []string
+	for _, exp := range seq {
+		actual = append(actual, fmt.Sprint(exp))
+	}
+	assert.Equal(t, []string{
+		"Spec-Press(esc)",
+		"Wait<1s>",
+		"LIT-Press(h)",
+		"LIT-Press(i)",
+		"Spec-Press(enter)",
+		"Spec-Press(f1)",
+	}, actual)
+
+	// The registered macros are known without the macros of the config.
+	seq, err = GenerateExpressionSequence("<macro test_esc>")
+	assert.NoError(t, err)
+	assert.Len(t, seq, 2)
+
+	_, err = GenerateExpressionSequenceWithMacros("<macro unknown>", macros)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown boot command macro")
+
+	_, err = GenerateExpressionSequenceWithMacros("<macro loop>", macros)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "loop -> loop2 -> loop")
+
+	// Each macro doubles the previous one.
+	macros = map[string]string{"m0": "a"}
+	for i := 1; i <= 40; i++ {
+		macros[fmt.Sprintf("m%d", i)] = fmt.Sprintf("<macro m%d><macro m%d>", i-1, i-1)
+	}
+	_, err = GenerateExpressionSequenceWithMacros("<macro m40>", macros)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "more than")
+	seq, err = GenerateExpressionSequenceWithMacros("<macro m10>", macros)
+	assert.NoError(t, err)
+	assert.Len(t, seq, 1024)
+}
+
+func Test_group(t *testing.T) {
+	macros := map[string]string{"next": "<group2><down></group>"}
+	seq, err := GenerateExpressionSequenceWithMacros(
+		"<group2>a<group3><tab></group><wait1s></group><group0>x</group><macro next></group>", macros)
+	assert.NoError(t, err)
+	var actual []string
+	for _, exp := range seq {
+		actual = append(actual, fmt.Sprint(exp))
+	}
+	assert.Equal(t, []string{
+		"LIT-Press(a)",
+		"Spec-Press(tab)",
+		"Spec-Press(tab)",
+		"Spec-Press(tab)",
+		"Wait<1s>",
+		"LIT-Press(a)",
+		"Spec-Press(tab)",
+		"Spec-Press(tab)",
+		"Spec-Press(tab)",
+		"Wait<1s>",
+		"Spec-Press(down)",
+		"Spec-Press(down)",
+		"LIT-Press(<)",
+		"LIT-Press(/)",
+		"LIT-Press(g)",
+		"LIT-Press(r)",
+		"LIT-Press(o)",
+		"LIT-Press(u)",
+		"LIT-Press(p)",
+		"LIT-Press(>)",
+	}, actual)
+
+	_, err = GenerateExpressionSequence("<group1000><group1000>ab</group></group>")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "more than")
+}
+
+func Test_waitForConditions(t *testing.T) {
+	seq, err := GenerateExpressionSequence("<waitForPort 22><waitForHTTP /phone-home?id=1 30s>")
+	assert.NoError(t, err)
+	assert.Equal(t, "WaitForPort<22, 10m0s>", fmt.Sprint(seq[0]))
+	assert.Equal(t, "WaitForHTTP</phone-home?id=1, 30s>", fmt.Sprint(seq[1]))
+
+	seq, err = GenerateExpressionSequence("<waitForPort 65536>")
+	assert.NoError(t, err)
+	assert.Error(t, seq[0].Validate())
+
+	defer func(d time.Duration) { WaitForInterval = d }(WaitForInterval)
+	WaitForInterval = time.Millisecond
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	requests := 0
+	driver := WithScreenMatcher(WithWaitConditions(NewPCXTDriver(func([]string) error { return nil }, -1, time.Duration(0)), WaitConditions{
+		PortOpen: TCPPortOpen("127.0.0.1"),
+		HTTPRequested: func(_ context.Context, path string) (bool, error) {
+			requests++
+			return path == "/phone-home" && requests > 2, nil
+		},
+	}), ScreenTextFunc(func(context.Context) (string, error) { return "login:", nil }))
+
+	seq, err = GenerateExpressionSequence(fmt.Sprintf("<waitForPort %d><waitForHTTP /phone-home><waitForText 'login:'>", port))
+	assert.NoError(t, err)
+	assert.NoError(t, seq.Do(context.Background(), driver))
+	assert.Equal(t, 3, requests)
+
+	l.Close()
+	seq, err = GenerateExpressionSequence(fmt.Sprintf("<waitForPort %d 20ms>", port))
+	assert.NoError(t, err)
+	err = seq.Do(context.Background(), driver)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Timeout")
+
+	// The drivers without conditions can't wait for them.
+	err = seq.Do(context.Background(), NewPCXTDriver(nil, -1, time.Duration(0)))
+	assert.Error(t, err)
+}
+
+func Test_textSender(t *testing.T) {
+	var sent []string
+	var codes []string
+	driver := WithTextSender(NewPCXTDriver(func(c []string) error {
+		codes = append(codes, c...)
+		return nil
+	}, -1, time.Duration(0)), TextSenderFunc(func(text string) error {
+		sent = append(sent, text)
+		return nil
+	}))
+
+	seq, err := GenerateExpressionSequence("<esc>user-data: é<enter><aOn>bc<aOff>")
+	assert.NoError(t, err)
+	assert.NoError(t, seq.Do(context.Background(), driver))
+	assert.Equal(t, []string{"user-data: é", "bc"}, sent)
+	assert.Equal(t, []string{"01", "81", "1c", "9c", "1e", "9e"}, codes)
+}
@@ -7,6 +7,7 @@ package bootcommand
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -43,7 +44,7 @@ const PackerKeyDefault = 100 * time.Millisecond
 //
 // -   `<tab>` - Simulates pressing the tab key.
 //
-// -   `<f1> - <f12>` - Simulates pressing a function key.
+// -   `<f1> - <f24>` - Simulates pressing a function key.
 //
 // -   `<up> <down> <left> <right>` - Simulates pressing an arrow key.
 //
@@ -58,8 +59,21 @@ const PackerKeyDefault = 100 * time.Millisecond
 //
 // -   `<menu>` - Simulates pressing the Menu key.
 //
+// -   `<printScreen> <pause>` - Simulates pressing the print screen and pause
+//     keys.
+//
+// -   `<capsLock> <numLock> <scrollLock>` - Simulates pressing a lock key.
+//
+// -   `<kp0> - <kp9> <kpDecimal> <kpDivide> <kpMultiply> <kpMinus> <kpPlus>
+//     <kpEnter>` - Simulates pressing a key of the numeric keypad.
+//
+// -   `<mute> <volumeDown> <volumeUp>` - Simulates pressing a media key.
+//
 // -   `<leftAlt> <rightAlt>` - Simulates pressing the alt key.
 //
+// -   `<altGr>` - Simulates pressing the AltGr key, typing the third
+//     characters of the keys of the international keyboards.
+//
 // -   `<leftCtrl> <rightCtrl>` - Simulates pressing the ctrl key.
 //
 // -   `<leftShift> <rightShift>` - Simulates pressing the shift key.
@@ -76,6 +90,20 @@ const PackerKeyDefault = 100 * time.Millisecond
 //     Valid time units are `ns`, `us` (or `µs`), `ms`, `s`, `m`, `h`. For
 //     example `<wait10m>` or `<wait1m20s>`.
 //
+// -   `<waitForText 'XX'>` - Wait for the text `XX` to be on the screen of the
+//     machine before sending any additional keys, for the builders able to
+//     read it, for example from screenshots or a serial console. A timeout
+//     can follow the text, like `<waitForText 'GRUB' 2m>`, the default is
+//     10 minutes.
+//
+// -   `<waitForPort XX>` - Wait for the machine to listen on the TCP port
+//     `XX`, like `<waitForPort 22>`, for the builders able to check it. A
+//     timeout can follow the port, like with `<waitForText>`.
+//
+// -   `<waitForHTTP XX>` - Wait for the machine to request the path `XX` from
+//     the HTTP server of the builder, like `<waitForHTTP /phone-home>`. A
+//     timeout can follow the path, like with `<waitForText>`.
+//
 // -   `<XXXOn> <XXXOff>` - Any printable keyboard character, and of these
 //      "special" expressions, with the exception of the `<wait>` types, can
 //      also be toggled on or off. For example, to simulate ctrl+c, use
@@ -83,6 +111,13 @@ const PackerKeyDefault = 100 * time.Millisecond
 //      will be held down until the machine reboots. To hold the `c` key down,
 //      you would use `<cOn>`. Likewise, `<cOff>` to release.
 //
+// -   `<macro XX>` - Types the boot command fragment of the macro `XX`, set in
+//     `boot_command_macros` or provided by the builder.
+//
+// -   `<groupXX> ... </group>` - Types the expressions of the group `XX`
+//     times, for example `<group12><down><wait></group>` to go down a menu of
+//     twelve entries. Groups can be nested.
+//
 // -   `{{ .HTTPIP }} {{ .HTTPPort }}` - The IP and port, respectively of an
 //     HTTP server that is started serving the directory specified by the
 //     `http_directory` configuration parameter. If `http_directory` isn't
@@ -168,6 +203,34 @@ type BootConfig struct {
 	// well, and are covered in the section below on the boot command. If this
 	// is not specified, it is assumed the installer will start itself.
 	BootCommand []string `mapstructure:"boot_command"`
+	// Named fragments of boot commands, typed where the `boot_command`
+	// references them with `<macro name>`, so that long sequences can be
+	// shared across templates and builders. Macros can reference other
+	// macros, and override the ones the builder provides. The names are made
+	// of letters, digits, `_`, `.` and `-`.
+	BootCommandMacros map[string]string `mapstructure:"boot_command_macros"`
+	// The maximum random duration added to, or removed from, the interval
+	// between the key events typed over VNC or USB, since some firmware and
+	// installer combinations drop keys when they arrive with perfectly
+	// uniform timing. Defaults to `0s`.
+	BootKeyJitter time.Duration `mapstructure:"boot_key_jitter"`
+	// How long the keys typed over VNC are held down before being released.
+	// Defaults to `0s`.
+	BootKeyHold time.Duration `mapstructure:"boot_key_hold"`
+	// Pause typing over VNC or USB for `boot_pause_duration` every
+	// `boot_pause_every` characters. Defaults to `0`, not pausing.
+	BootPauseEvery int `mapstructure:"boot_pause_every"`
+	// How long to pause typing every `boot_pause_every` characters. Defaults
+	// to `1s` when `boot_pause_every` is set.
+	BootPauseDuration time.Duration `mapstructure:"boot_pause_duration"`
+	// The keyboard layout the guest is configured for, so that the
+	// characters of the `boot_command` are typed with the keys of that
+	// layout by the builders sending PC-XT scancodes. One of `us`, `uk`,
+	// `de`, `fr` and `jp`. The characters typed with dead keys, like `^` on
+	// the `de` and `fr` layouts, are followed by a space, and the accented
+	// characters no key types are composed with them, like `é` with `´` then
+	// `e` on the `de` layout. Defaults to `us`.
+	BootKeyboardLayout string `mapstructure:"boot_keyboard_layout"`
 }
 
 // The boot command "typed" character for character over a VNC connection to
@@ -191,8 +254,33 @@ func (c *BootConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 		c.BootWait = 10 * time.Second
 	}
 
+	if c.BootKeyboardLayout != "" {
+		if _, err := lookupKeyboardLayout(c.BootKeyboardLayout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.BootKeyJitter < 0 {
+		errs = append(errs, fmt.Errorf("boot_key_jitter must be positive"))
+	}
+	if c.BootKeyHold < 0 {
+		errs = append(errs, fmt.Errorf("boot_key_hold must be positive"))
+	}
+	if c.BootPauseEvery < 0 {
+		errs = append(errs, fmt.Errorf("boot_pause_every must be positive"))
+	}
+	if c.BootPauseEvery > 0 && c.BootPauseDuration == 0 {
+		c.BootPauseDuration = time.Second
+	}
+
+	for name := range c.BootCommandMacros {
+		if !macroNameRe.MatchString(name) {
+			errs = append(errs, fmt.Errorf("Invalid boot command macro name %q", name))
+		}
+	}
+
 	if c.BootCommand != nil {
-		expSeq, err := GenerateExpressionSequence(c.FlatBootCommand())
+		expSeq, err := GenerateExpressionSequenceWithMacros(c.FlatBootCommand(), c.BootCommandMacros)
 		if err != nil {
 			errs = append(errs, err)
 		} else if vErrs := expSeq.Validate(); vErrs != nil {
@@ -203,6 +291,19 @@ func (c *BootConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 	return
 }
 
+// KeyPacing returns the pacing to set, with SetKeyPacing, on the drivers
+// typing the boot command over VNC or USB.
+func (c *BootConfig) KeyPacing() KeyPacing {
+	return KeyPacing{
+		Jitter:     c.BootKeyJitter,
+		Hold:       c.BootKeyHold,
+		PauseEvery: c.BootPauseEvery,
+		Pause:      c.BootPauseDuration,
+	}
+}
+
+var macroNameRe = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
 func (c *BootConfig) FlatBootCommand() string {
 	return strings.Join(c.BootCommand, "")
 }
@@ -31,6 +31,36 @@ func TestConfigPrepare(t *testing.T) {
 	if len(errs) > 0 {
 		t.Fatalf("bad: %#v", errs)
 	}
+
+	// Test a keyboard layout
+	c = new(BootConfig)
+	c.BootKeyboardLayout = "de"
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	// Test macros
+	c = new(BootConfig)
+	c.BootCommand = []string{"<macro install><enter>"}
+	c.BootCommandMacros = map[string]string{"install": "<esc>linux<wait>"}
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	c.BootCommandMacros = map[string]string{"install ks": "<esc>"}
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) != 2 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	// Test an unknown keyboard layout
+	c = new(BootConfig)
+	c.BootKeyboardLayout = "xx"
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
 }
 
 func TestVNCConfigPrepare(t *testing.T) {
@@ -3,6 +3,14 @@
 
 package bootcommand
 
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
 const shiftedChars = "~!@#$%^&*()_+{}|:\"<>?"
 
 // BCDriver is our access to the VM we want to type boot commands to
@@ -12,3 +20,146 @@ type BCDriver interface {
 	// Flush will be called when we want to send scancodes to the VM.
 	Flush() error
 }
+
+// ScreenMatcher is implemented by the drivers able to read the screen of the
+// VM, from screenshots passed to OCR or from the text of a serial console,
+// to wait for the guest in the `<waitForText 'text'>` expressions.
+type ScreenMatcher interface {
+	// MatchText returns whether text is on the screen of the VM.
+	MatchText(ctx context.Context, text string) (bool, error)
+}
+
+// ScreenTextFunc is a ScreenMatcher returning the text on the screen of the
+// VM, in which the expected text is looked for.
+type ScreenTextFunc func(ctx context.Context) (string, error)
+
+// MatchText returns whether text is in the screen text returned by f.
+func (f ScreenTextFunc) MatchText(ctx context.Context, text string) (bool, error) {
+	screen, err := f(ctx)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(screen, text), nil
+}
+
+// WithScreenMatcher returns driver matching the screen text with matcher,
+// for the builders capturing the console of VMs driven by another driver,
+// like one sending PC-XT scancodes.
+func WithScreenMatcher(driver BCDriver, matcher ScreenMatcher) BCDriver {
+	return &screenMatcherDriver{driver, matcher}
+}
+
+type screenMatcherDriver struct {
+	BCDriver
+	ScreenMatcher
+}
+
+func (d *screenMatcherDriver) unwrap() BCDriver { return d.BCDriver }
+
+// TextSender is implemented by the drivers of the hypervisors supporting
+// guest clipboard or string injection APIs: the runs of characters of the
+// boot commands, like cloud-init user-data or kickstart URLs, are sent with
+// SendText in one operation instead of typed key by key.
+type TextSender interface {
+	SendText(text string) error
+}
+
+// TextSenderFunc is a TextSender sending text with f.
+type TextSenderFunc func(text string) error
+
+// SendText sends text with f.
+func (f TextSenderFunc) SendText(text string) error {
+	return f(text)
+}
+
+// WithTextSender returns driver sending the runs of characters with sender,
+// for the builders whose hypervisor injects text in VMs driven by another
+// driver.
+func WithTextSender(driver BCDriver, sender TextSender) BCDriver {
+	return &textSenderDriver{driver, sender}
+}
+
+type textSenderDriver struct {
+	BCDriver
+	TextSender
+}
+
+func (d *textSenderDriver) unwrap() BCDriver { return d.BCDriver }
+
+// WaitConditions are the callbacks checking the conditions of the
+// `<waitForPort port>` and `<waitForHTTP path>` expressions, usually from
+// the state of the builder. The nil ones can't be waited for.
+type WaitConditions struct {
+	// PortOpen returns whether the guest listens on the TCP port.
+	PortOpen PortOpenFunc
+	// HTTPRequested returns whether the guest requested path from the HTTP
+	// server of the builder.
+	HTTPRequested HTTPRequestedFunc
+}
+
+// PortOpenFunc returns whether the guest listens on the TCP port, see
+// TCPPortOpen.
+type PortOpenFunc func(ctx context.Context, port int) (bool, error)
+
+// HTTPRequestedFunc returns whether the guest requested path from the HTTP
+// server of the builder, like the one of commonsteps.StepHTTPServer.
+type HTTPRequestedFunc func(ctx context.Context, path string) (bool, error)
+
+// TCPPortOpen returns a PortOpenFunc connecting to the ports of host, the
+// address of the guest.
+func TCPPortOpen(host string) PortOpenFunc {
+	return func(ctx context.Context, port int) (bool, error) {
+		ctx, cancel := context.WithTimeout(ctx, WaitForInterval)
+		defer cancel()
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			log.Printf("[DEBUG] Port %d of %s not open: %s", port, host, err)
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	}
+}
+
+// WithWaitConditions returns driver checking the conditions of the
+// `<waitForPort port>` and `<waitForHTTP path>` expressions with conditions.
+func WithWaitConditions(driver BCDriver, conditions WaitConditions) BCDriver {
+	return &waitConditionsDriver{driver, conditions}
+}
+
+type waitConditionsDriver struct {
+	BCDriver
+	conditions WaitConditions
+}
+
+func (d *waitConditionsDriver) unwrap() BCDriver { return d.BCDriver }
+
+// driverWaitConditions returns the conditions the driver was set with
+// WithWaitConditions.
+func driverWaitConditions(driver BCDriver) WaitConditions {
+	if d, ok := findDriver(driver, func(d BCDriver) bool {
+		_, ok := d.(*waitConditionsDriver)
+		return ok
+	}).(*waitConditionsDriver); ok {
+		return d.conditions
+	}
+	return WaitConditions{}
+}
+
+// findDriver returns the first of driver and the drivers it wraps, with
+// WithScreenMatcher, WithTextSender or WithWaitConditions, matching match,
+// nil if none does.
+func findDriver(driver BCDriver, match func(BCDriver) bool) BCDriver {
+	for driver != nil {
+		if match(driver) {
+			return driver
+		}
+		w, ok := driver.(interface{ unwrap() BCDriver })
+		if !ok {
+			return nil
+		}
+		driver = w.unwrap()
+	}
+	return nil
+}
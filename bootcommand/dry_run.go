@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// The kinds of the events of the timelines.
+const (
+	// TimelineType is the typing of characters.
+	TimelineType = "type"
+	// TimelinePress is the press of a special key, like <enter>.
+	TimelinePress = "press"
+	// TimelineHold and TimelineRelease are the <XXXOn> and <XXXOff>
+	// expressions.
+	TimelineHold    = "hold"
+	TimelineRelease = "release"
+	// TimelineWait is a <wait> expression.
+	TimelineWait = "wait"
+	// TimelineWaitFor is a <waitForText>, <waitForPort> or <waitForHTTP>
+	// expression.
+	TimelineWaitFor = "waitFor"
+)
+
+// TimelineEvent is an event of the timeline of a boot command.
+type TimelineEvent struct {
+	// At is the time of the event from the start of the boot command.
+	At time.Duration
+	// Kind is the kind of the event, like TimelineType.
+	Kind string
+	// Text is the characters typed, the key pressed, or the condition
+	// waited for.
+	Text string
+	// Duration is how long the event takes, at most for TimelineWaitFor.
+	Duration time.Duration
+}
+
+func (e TimelineEvent) String() string {
+	var detail string
+	switch e.Kind {
+	case TimelineType:
+		detail = fmt.Sprintf("%q (%s)", e.Text, e.Duration)
+	case TimelineWait:
+		detail = e.Duration.String()
+	case TimelineWaitFor:
+		detail = fmt.Sprintf("%s (up to %s)", e.Text, e.Duration)
+	default:
+		detail = e.Text
+	}
+	return fmt.Sprintf("%10s  %-7s  %s", e.At.Round(time.Millisecond), e.Kind, detail)
+}
+
+// Timeline is the timeline of the typing of a boot command, see DryRun.
+type Timeline []TimelineEvent
+
+// String renders the timeline one event per line.
+func (t Timeline) String() string {
+	var b strings.Builder
+	for _, e := range t {
+		b.WriteString(e.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Duration is how long typing the boot command takes, with the conditional
+// waits met immediately.
+func (t Timeline) Duration() time.Duration {
+	if len(t) == 0 {
+		return 0
+	}
+	last := t[len(t)-1]
+	if last.Kind == TimelineWaitFor {
+		return last.At
+	}
+	return last.At + last.Duration
+}
+
+// DryRun parses the boot command command, expanding the macros, validates
+// all of its expressions and returns the timeline of its typing, without
+// typing it: template authors can lint complex sequences, and tests assert
+// on them. Every key takes keyInterval, PackerKeyDefault when 0, and the
+// conditional waits are counted as met immediately.
+func DryRun(command string, macros map[string]string, keyInterval time.Duration) (Timeline, error) {
+	if keyInterval <= 0 {
+		keyInterval = PackerKeyDefault
+	}
+	seq, err := GenerateExpressionSequenceWithMacros(command, macros)
+	if err != nil {
+		return nil, err
+	}
+	if errs := seq.Validate(); len(errs) > 0 {
+		return nil, multierror.Append(nil, errs...)
+	}
+
+	var timeline Timeline
+	var at time.Duration
+	add := func(kind, text string, d time.Duration) {
+		timeline = append(timeline, TimelineEvent{At: at, Kind: kind, Text: text, Duration: d})
+		if kind != TimelineWaitFor {
+			at += d
+		}
+	}
+	for _, exp := range seq {
+		switch e := exp.(type) {
+		case *literal:
+			switch e.action {
+			case KeyOn:
+				add(TimelineHold, string(e.s), keyInterval)
+			case KeyOff:
+				add(TimelineRelease, string(e.s), keyInterval)
+			default:
+				if last := len(timeline) - 1; last >= 0 && timeline[last].Kind == TimelineType {
+					timeline[last].Text += string(e.s)
+					timeline[last].Duration += keyInterval
+					at += keyInterval
+					continue
+				}
+				add(TimelineType, string(e.s), keyInterval)
+			}
+		case *specialExpression:
+			key := fmt.Sprintf("<%s>", e.s)
+			switch e.action {
+			case KeyOn:
+				add(TimelineHold, key, keyInterval)
+			case KeyOff:
+				add(TimelineRelease, key, keyInterval)
+			default:
+				add(TimelinePress, key, keyInterval)
+			}
+		case *waitExpression:
+			add(TimelineWait, "", e.d)
+		case *waitForTextExpression:
+			add(TimelineWaitFor, fmt.Sprintf("%q on the screen", e.text), e.timeout)
+		case *waitForPortExpression:
+			add(TimelineWaitFor, fmt.Sprintf("port %d", e.port), e.timeout)
+		case *waitForHTTPExpression:
+			add(TimelineWaitFor, fmt.Sprintf("a request to %s", e.path), e.timeout)
+		default:
+			return nil, fmt.Errorf("unexpected boot command expression %s", exp)
+		}
+	}
+	return timeline, nil
+}
@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	ms := time.Millisecond
+	timeline, err := DryRun("<esc><wait5>linux <macro ks><enter><leftCtrlOn>c<leftCtrlOff><waitForPort 22 1m>",
+		map[string]string{"ks": "ks=cfg"}, 10*ms)
+	assert.NoError(t, err)
+	assert.Equal(t, Timeline{
+		{At: 0, Kind: TimelinePress, Text: "<esc>", Duration: 10 * ms},
+		{At: 10 * ms, Kind: TimelineWait, Duration: 5 * time.Second},
+		{At: 5010 * ms, Kind: TimelineType, Text: "linux ks=cfg", Duration: 120 * ms},
+		{At: 5130 * ms, Kind: TimelinePress, Text: "<enter>", Duration: 10 * ms},
+		{At: 5140 * ms, Kind: TimelineHold, Text: "<leftctrl>", Duration: 10 * ms},
+		{At: 5150 * ms, Kind: TimelineType, Text: "c", Duration: 10 * ms},
+		{At: 5160 * ms, Kind: TimelineRelease, Text: "<leftctrl>", Duration: 10 * ms},
+		{At: 5170 * ms, Kind: TimelineWaitFor, Text: "port 22", Duration: time.Minute},
+	}, timeline)
+	assert.Equal(t, 5170*ms, timeline.Duration())
+	assert.Contains(t, timeline.String(), `   5.01s  type     "linux ks=cfg" (120ms)`)
+	assert.Contains(t, timeline.String(), "port 22 (up to 1m0s)")
+
+	_, err = DryRun("<wait-1s>a<waitForText 'x' 0s>", nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "2 errors") {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	_, err = DryRun("<macro unknown>", nil, 0)
+	assert.Error(t, err)
+}
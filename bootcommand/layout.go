@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultKeyboardLayout is the keyboard layout the scancodes are sent for
+// when the boot configuration doesn't set one.
+const DefaultKeyboardLayout = "us"
+
+// KeyboardLayout maps the characters of the boot commands to the keys typing
+// them on a keyboard, for the guests configured for another keyboard layout
+// than the US one: typing "y" on a German keyboard is pressing the key
+// typing "z" on a US one.
+type KeyboardLayout struct {
+	Name string
+	keys map[rune]layoutKey
+}
+
+// layoutKey is a key, by its PC-XT scancode, with the modifiers held to type
+// a character. A dead key types its character once followed by a space.
+type layoutKey struct {
+	code  byte
+	shift bool
+	altGr bool
+	dead  bool
+}
+
+// layoutRow is a row of keys of consecutive scancodes from start, with the
+// characters they type alone, with shift and with AltGr. The keys typing
+// nothing are NUL characters.
+type layoutRow struct {
+	start                 byte
+	plain, shifted, altGr string
+}
+
+// newKeyboardLayout returns the layout name of rows, in which the characters
+// of dead are typed with dead keys. When several keys type a character, the
+// first one is used.
+func newKeyboardLayout(name string, dead string, rows ...layoutRow) *KeyboardLayout {
+	l := &KeyboardLayout{Name: name, keys: make(map[rune]layoutKey)}
+	for _, row := range rows {
+		for _, level := range []struct {
+			chars        string
+			shift, altGr bool
+		}{
+			{row.plain, false, false},
+			{row.shifted, true, false},
+			{row.altGr, false, true},
+		} {
+			code := row.start
+			for _, r := range level.chars {
+				if _, found := l.keys[r]; !found && r != 0 {
+					l.keys[r] = layoutKey{
+						code:  code,
+						shift: level.shift,
+						altGr: level.altGr,
+						dead:  strings.ContainsRune(dead, r),
+					}
+				}
+				code++
+			}
+		}
+	}
+	return l
+}
+
+// deadKeyMarks maps the combining marks to the characters of the dead keys
+// adding them to the next character typed.
+var deadKeyMarks = map[rune]rune{
+	'\u0300': '`',
+	'\u0301': '´',
+	'\u0302': '^',
+	'\u0303': '~',
+	'\u0308': '¨',
+}
+
+// typeKeys returns the keys typing r: the key typing it, or the dead key of
+// its accent followed by the key of its letter, like ´ then e typing é on a
+// German keyboard.
+func (l *KeyboardLayout) typeKeys(r rune) ([]layoutKey, bool) {
+	if k, ok := l.keys[r]; ok {
+		return []layoutKey{k}, true
+	}
+	decomposed := []rune(norm.NFD.String(string(r)))
+	if len(decomposed) != 2 {
+		return nil, false
+	}
+	letter, ok := l.keys[decomposed[0]]
+	if !ok || letter.dead {
+		return nil, false
+	}
+	dead, ok := l.keys[deadKeyMarks[decomposed[1]]]
+	if !ok || !dead.dead {
+		return nil, false
+	}
+	// The dead key isn't followed by a space to type its own character.
+	dead.dead = false
+	return []layoutKey{dead, letter}, true
+}
+
+var keyboardLayouts = map[string]*KeyboardLayout{}
+
+func registerKeyboardLayout(l *KeyboardLayout) {
+	keyboardLayouts[l.Name] = l
+}
+
+// KeyboardLayouts returns the names of the keyboard layouts, sorted.
+func KeyboardLayouts() []string {
+	names := make([]string, 0, len(keyboardLayouts))
+	for name := range keyboardLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupKeyboardLayout returns the layout name, the DefaultKeyboardLayout
+// when name is empty.
+func lookupKeyboardLayout(name string) (*KeyboardLayout, error) {
+	if name == "" {
+		name = DefaultKeyboardLayout
+	}
+	l, ok := keyboardLayouts[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout %q, must be one of %s",
+			name, strings.Join(KeyboardLayouts(), ", "))
+	}
+	return l, nil
+}
+
+// Scancodes reference: https://www.win.tue.nl/~aeb/linux/kbd/scancodes-1.html
+func init() {
+	registerKeyboardLayout(newKeyboardLayout("us", "",
+		layoutRow{start: 0x02, plain: "1234567890-=", shifted: "!@#$%^&*()_+"},
+		layoutRow{start: 0x10, plain: "qwertyuiop[]", shifted: "QWERTYUIOP{}"},
+		layoutRow{start: 0x1e, plain: "asdfghjkl;'`", shifted: `ASDFGHJKL:"~`},
+		layoutRow{start: 0x2b, plain: `\zxcvbnm,./`, shifted: "|ZXCVBNM<>?"},
+		layoutRow{start: 0x39, plain: " "},
+	))
+	registerKeyboardLayout(newKeyboardLayout("uk", "",
+		layoutRow{start: 0x02, plain: "1234567890-=", shifted: "!\"£$%^&*()_+"},
+		layoutRow{start: 0x10, plain: "qwertyuiop[]", shifted: "QWERTYUIOP{}"},
+		layoutRow{start: 0x1e, plain: "asdfghjkl;'`", shifted: "ASDFGHJKL:@¬"},
+		layoutRow{start: 0x2b, plain: "#zxcvbnm,./", shifted: "~ZXCVBNM<>?"},
+		layoutRow{start: 0x39, plain: " "},
+		layoutRow{start: 0x56, plain: `\`, shifted: "|"},
+	))
+	registerKeyboardLayout(newKeyboardLayout("de", "^´`",
+		layoutRow{start: 0x02, plain: "1234567890ß´", shifted: "!\"§$%&/()=?`", altGr: "\x00²³\x00\x00\x00{[]}\\"},
+		layoutRow{start: 0x10, plain: "qwertzuiopü+", shifted: "QWERTZUIOPÜ*", altGr: "@\x00€\x00\x00\x00\x00\x00\x00\x00\x00~"},
+		layoutRow{start: 0x1e, plain: "asdfghjklöä^", shifted: "ASDFGHJKLÖÄ°"},
+		layoutRow{start: 0x2b, plain: "#yxcvbnm,.-", shifted: "'YXCVBNM;:_", altGr: "\x00\x00\x00\x00\x00\x00\x00µ"},
+		layoutRow{start: 0x39, plain: " "},
+		layoutRow{start: 0x56, plain: "<", shifted: ">", altGr: "|"},
+	))
+	registerKeyboardLayout(newKeyboardLayout("fr", "^¨",
+		layoutRow{start: 0x02, plain: "&é\"'(-è_çà)=", shifted: "1234567890°+", altGr: "\x00~#{[|`\\\x00@]}"},
+		layoutRow{start: 0x10, plain: "azertyuiop^$", shifted: "AZERTYUIOP¨£", altGr: "\x00\x00€\x00\x00\x00\x00\x00\x00\x00\x00¤"},
+		layoutRow{start: 0x1e, plain: "qsdfghjklmù²", shifted: "QSDFGHJKLM%"},
+		layoutRow{start: 0x2b, plain: "*wxcvbn,;:!", shifted: "µWXCVBN?./§"},
+		layoutRow{start: 0x39, plain: " "},
+		layoutRow{start: 0x56, plain: "<", shifted: ">"},
+	))
+	registerKeyboardLayout(newKeyboardLayout("jp", "",
+		layoutRow{start: 0x02, plain: "1234567890-^", shifted: "!\"#$%&'()\x00=~"},
+		layoutRow{start: 0x10, plain: "qwertyuiop@[", shifted: "QWERTYUIOP`{"},
+		layoutRow{start: 0x1e, plain: "asdfghjkl;:", shifted: "ASDFGHJKL+*"},
+		layoutRow{start: 0x2b, plain: "]zxcvbnm,./", shifted: "}ZXCVBNM<>?"},
+		layoutRow{start: 0x39, plain: " "},
+		// The Ro and Yen keys of the Japanese keyboards.
+		layoutRow{start: 0x73, plain: `\`, shifted: "_"},
+		layoutRow{start: 0x7d, plain: "¥", shifted: "|"},
+	))
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"math/rand"
+	"time"
+)
+
+// KeyPacing humanizes the typing of the drivers sending key events one by
+// one: some firmware and installer combinations drop keys when they arrive
+// with perfectly uniform timing.
+type KeyPacing struct {
+	// Jitter is the maximum random duration added to, or removed from, the
+	// interval between key events.
+	Jitter time.Duration
+	// Hold is how long the keys pressed are held down before being released,
+	// by the drivers sending the key downs and ups separately.
+	Hold time.Duration
+	// Pause is how long typing pauses every PauseEvery characters.
+	PauseEvery int
+	Pause      time.Duration
+}
+
+// SetKeyPacing sets p on driver, or on the driver it wraps with
+// WithScreenMatcher, WithTextSender or WithWaitConditions, when it has a
+// SetKeyPacing method, like the VNC and USB drivers. It returns whether one
+// has.
+func SetKeyPacing(driver BCDriver, p KeyPacing) bool {
+	d, ok := findDriver(driver, func(d BCDriver) bool {
+		_, ok := d.(interface{ SetKeyPacing(KeyPacing) })
+		return ok
+	}).(interface{ SetKeyPacing(KeyPacing) })
+	if ok {
+		d.SetKeyPacing(p)
+	}
+	return ok
+}
+
+// keyPacer paces the key events of a driver.
+type keyPacer struct {
+	KeyPacing
+	interval time.Duration
+	chars    int
+	rand     *rand.Rand
+	// sleep is time.Sleep, replaced in tests.
+	sleep func(time.Duration)
+}
+
+func newKeyPacer(interval time.Duration) keyPacer {
+	return keyPacer{
+		interval: interval,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		sleep:    time.Sleep,
+	}
+}
+
+// wait waits the interval between key events, with jitter.
+func (p *keyPacer) wait() {
+	d := p.interval
+	if p.Jitter > 0 {
+		d += time.Duration(p.rand.Int63n(int64(2*p.Jitter)+1)) - p.Jitter
+	}
+	if d > 0 {
+		p.sleep(d)
+	}
+}
+
+// hold waits while a pressed key is held down.
+func (p *keyPacer) hold() {
+	if p.Hold > 0 {
+		p.sleep(p.Hold)
+	}
+}
+
+// typed counts a character typed, pausing every PauseEvery characters.
+func (p *keyPacer) typed() {
+	if p.PauseEvery <= 0 || p.Pause <= 0 {
+		return
+	}
+	p.chars++
+	if p.chars%p.PauseEvery == 0 {
+		p.sleep(p.Pause)
+	}
+}
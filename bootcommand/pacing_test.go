@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mobile/event/key"
+)
+
+func Test_keyPacerJitter(t *testing.T) {
+	p := newKeyPacer(100 * time.Millisecond)
+	p.Jitter = 20 * time.Millisecond
+	var slept []time.Duration
+	p.sleep = func(d time.Duration) { slept = append(slept, d) }
+	for i := 0; i < 100; i++ {
+		p.wait()
+	}
+	assert.Len(t, slept, 100)
+	uniform := true
+	for _, d := range slept {
+		assert.True(t, d >= 80*time.Millisecond && d <= 120*time.Millisecond, "interval %s", d)
+		uniform = uniform && d == slept[0]
+	}
+	assert.False(t, uniform, "the intervals should vary")
+}
+
+func Test_vncKeyPacing(t *testing.T) {
+	s := &sender{}
+	d := NewVNCDriver(s, time.Millisecond)
+	d.SetKeyPacing(KeyPacing{Hold: 50 * time.Millisecond, PauseEvery: 2, Pause: time.Second})
+	var slept []time.Duration
+	d.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	seq, err := GenerateExpressionSequence("abc")
+	assert.NoError(t, err)
+	assert.NoError(t, seq.Do(context.Background(), d))
+	ms := time.Millisecond
+	assert.Equal(t, []time.Duration{
+		ms, 50 * ms, ms, // a
+		ms, 50 * ms, ms, time.Second, // b
+		ms, 50 * ms, ms, // c
+	}, slept)
+}
+
+func Test_usbKeyPacing(t *testing.T) {
+	d := NewUSBDriver(func(key.Code, bool) error { return nil }, time.Millisecond)
+	d.SetKeyPacing(KeyPacing{Hold: 50 * time.Millisecond, PauseEvery: 3, Pause: time.Second})
+	var slept []time.Duration
+	d.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	seq, err := GenerateExpressionSequence("abc")
+	assert.NoError(t, err)
+	assert.NoError(t, seq.Do(context.Background(), d))
+	ms := time.Millisecond
+	assert.Equal(t, []time.Duration{ms, ms, ms, time.Second}, slept)
+}
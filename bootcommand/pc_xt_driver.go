@@ -10,7 +10,6 @@ import (
 	"strings"
 	"time"
 	"unicode"
-	"unicode/utf8"
 )
 
 // SendCodeFunc will be called to send codes to the VM
@@ -18,11 +17,11 @@ type SendCodeFunc func([]string) error
 type scMap map[string]*scancode
 
 type pcXTDriver struct {
-	interval    time.Duration
-	sendImpl    SendCodeFunc
-	specialMap  scMap
-	scancodeMap map[rune]byte
-	buffer      [][]string
+	interval   time.Duration
+	sendImpl   SendCodeFunc
+	specialMap scMap
+	layout     *KeyboardLayout
+	buffer     [][]string
 	// TODO: set from env
 	scancodeChunkSize int
 }
@@ -40,6 +39,18 @@ func (sc *scancode) makeBreak() []string {
 // keyboard codes. `send` should send its argument to the VM. `chunkSize` should
 // be the maximum number of keyboard codes to send to `send` at one time.
 func NewPCXTDriver(send SendCodeFunc, chunkSize int, interval time.Duration) *pcXTDriver {
+	d, _ := NewPCXTDriverWithLayout(send, chunkSize, interval, DefaultKeyboardLayout)
+	return d
+}
+
+// NewPCXTDriverWithLayout creates a new boot command driver like
+// NewPCXTDriver, typing the characters with the keys of the keyboard layout
+// named `layout`, see BootConfig.BootKeyboardLayout.
+func NewPCXTDriverWithLayout(send SendCodeFunc, chunkSize int, interval time.Duration, layout string) (*pcXTDriver, error) {
+	keyboardLayout, err := lookupKeyboardLayout(layout)
+	if err != nil {
+		return nil, err
+	}
 	// We delay (default 100ms) between each input event to allow for CPU or
 	// network latency. See PackerKeyEnv for tuning.
 	keyInterval := PackerKeyDefault
@@ -57,7 +68,9 @@ func NewPCXTDriver(send SendCodeFunc, chunkSize int, interval time.Duration) *pc
 	// the key press and the second entry represents the key release and is
 	// derived from the first by the addition of 0x80.
 	sMap := make(scMap)
+	sMap["altgr"] = &scancode{[]string{"e0", "38"}, []string{"e0", "b8"}}
 	sMap["bs"] = &scancode{[]string{"0e"}, []string{"8e"}}
+	sMap["capslock"] = &scancode{[]string{"3a"}, []string{"ba"}}
 	sMap["del"] = &scancode{[]string{"e0", "53"}, []string{"e0", "d3"}}
 	sMap["down"] = &scancode{[]string{"e0", "50"}, []string{"e0", "d0"}}
 	sMap["end"] = &scancode{[]string{"e0", "4f"}, []string{"e0", "cf"}}
@@ -75,55 +88,69 @@ func NewPCXTDriver(send SendCodeFunc, chunkSize int, interval time.Duration) *pc
 	sMap["f10"] = &scancode{[]string{"44"}, []string{"c4"}}
 	sMap["f11"] = &scancode{[]string{"57"}, []string{"d7"}}
 	sMap["f12"] = &scancode{[]string{"58"}, []string{"d8"}}
+	sMap["f13"] = &scancode{[]string{"64"}, []string{"e4"}}
+	sMap["f14"] = &scancode{[]string{"65"}, []string{"e5"}}
+	sMap["f15"] = &scancode{[]string{"66"}, []string{"e6"}}
+	sMap["f16"] = &scancode{[]string{"67"}, []string{"e7"}}
+	sMap["f17"] = &scancode{[]string{"68"}, []string{"e8"}}
+	sMap["f18"] = &scancode{[]string{"69"}, []string{"e9"}}
+	sMap["f19"] = &scancode{[]string{"6a"}, []string{"ea"}}
+	sMap["f20"] = &scancode{[]string{"6b"}, []string{"eb"}}
+	sMap["f21"] = &scancode{[]string{"6c"}, []string{"ec"}}
+	sMap["f22"] = &scancode{[]string{"6d"}, []string{"ed"}}
+	sMap["f23"] = &scancode{[]string{"6e"}, []string{"ee"}}
+	sMap["f24"] = &scancode{[]string{"76"}, []string{"f6"}}
 	sMap["home"] = &scancode{[]string{"e0", "47"}, []string{"e0", "c7"}}
 	sMap["insert"] = &scancode{[]string{"e0", "52"}, []string{"e0", "d2"}}
+	sMap["kp0"] = &scancode{[]string{"52"}, []string{"d2"}}
+	sMap["kp1"] = &scancode{[]string{"4f"}, []string{"cf"}}
+	sMap["kp2"] = &scancode{[]string{"50"}, []string{"d0"}}
+	sMap["kp3"] = &scancode{[]string{"51"}, []string{"d1"}}
+	sMap["kp4"] = &scancode{[]string{"4b"}, []string{"cb"}}
+	sMap["kp5"] = &scancode{[]string{"4c"}, []string{"cc"}}
+	sMap["kp6"] = &scancode{[]string{"4d"}, []string{"cd"}}
+	sMap["kp7"] = &scancode{[]string{"47"}, []string{"c7"}}
+	sMap["kp8"] = &scancode{[]string{"48"}, []string{"c8"}}
+	sMap["kp9"] = &scancode{[]string{"49"}, []string{"c9"}}
+	sMap["kpdecimal"] = &scancode{[]string{"53"}, []string{"d3"}}
+	sMap["kpdivide"] = &scancode{[]string{"e0", "35"}, []string{"e0", "b5"}}
+	sMap["kpenter"] = &scancode{[]string{"e0", "1c"}, []string{"e0", "9c"}}
+	sMap["kpminus"] = &scancode{[]string{"4a"}, []string{"ca"}}
+	sMap["kpmultiply"] = &scancode{[]string{"37"}, []string{"b7"}}
+	sMap["kpplus"] = &scancode{[]string{"4e"}, []string{"ce"}}
 	sMap["left"] = &scancode{[]string{"e0", "4b"}, []string{"e0", "cb"}}
 	sMap["leftalt"] = &scancode{[]string{"38"}, []string{"b8"}}
 	sMap["leftctrl"] = &scancode{[]string{"1d"}, []string{"9d"}}
 	sMap["leftshift"] = &scancode{[]string{"2a"}, []string{"aa"}}
 	sMap["leftsuper"] = &scancode{[]string{"e0", "5b"}, []string{"e0", "db"}}
 	sMap["menu"] = &scancode{[]string{"e0", "5d"}, []string{"e0", "dd"}}
+	sMap["mute"] = &scancode{[]string{"e0", "20"}, []string{"e0", "a0"}}
+	sMap["numlock"] = &scancode{[]string{"45"}, []string{"c5"}}
 	sMap["pagedown"] = &scancode{[]string{"e0", "51"}, []string{"e0", "d1"}}
 	sMap["pageup"] = &scancode{[]string{"e0", "49"}, []string{"e0", "c9"}}
+	// Pause has no break code, its make code releases it.
+	sMap["pause"] = &scancode{[]string{"e1", "1d", "45", "e1", "9d", "c5"}, []string{}}
+	sMap["printscreen"] = &scancode{[]string{"e0", "2a", "e0", "37"}, []string{"e0", "b7", "e0", "aa"}}
 	sMap["return"] = &scancode{[]string{"1c"}, []string{"9c"}}
 	sMap["right"] = &scancode{[]string{"e0", "4d"}, []string{"e0", "cd"}}
 	sMap["rightalt"] = &scancode{[]string{"e0", "38"}, []string{"e0", "b8"}}
 	sMap["rightctrl"] = &scancode{[]string{"e0", "1d"}, []string{"e0", "9d"}}
 	sMap["rightshift"] = &scancode{[]string{"36"}, []string{"b6"}}
 	sMap["rightsuper"] = &scancode{[]string{"e0", "5c"}, []string{"e0", "dc"}}
+	sMap["scrolllock"] = &scancode{[]string{"46"}, []string{"c6"}}
 	sMap["spacebar"] = &scancode{[]string{"39"}, []string{"b9"}}
 	sMap["tab"] = &scancode{[]string{"0f"}, []string{"8f"}}
 	sMap["up"] = &scancode{[]string{"e0", "48"}, []string{"e0", "c8"}}
-
-	scancodeIndex := make(map[string]byte)
-	scancodeIndex["1234567890-="] = 0x02
-	scancodeIndex["!@#$%^&*()_+"] = 0x02
-	scancodeIndex["qwertyuiop[]"] = 0x10
-	scancodeIndex["QWERTYUIOP{}"] = 0x10
-	scancodeIndex["asdfghjkl;'`"] = 0x1e
-	scancodeIndex[`ASDFGHJKL:"~`] = 0x1e
-	scancodeIndex[`\zxcvbnm,./`] = 0x2b
-	scancodeIndex["|ZXCVBNM<>?"] = 0x2b
-	scancodeIndex[" "] = 0x39
-
-	scancodeMap := make(map[rune]byte)
-	for chars, start := range scancodeIndex {
-		var i byte = 0
-		for len(chars) > 0 {
-			r, size := utf8.DecodeRuneInString(chars)
-			chars = chars[size:]
-			scancodeMap[r] = start + i
-			i += 1
-		}
-	}
+	sMap["volumedown"] = &scancode{[]string{"e0", "2e"}, []string{"e0", "ae"}}
+	sMap["volumeup"] = &scancode{[]string{"e0", "30"}, []string{"e0", "b0"}}
 
 	return &pcXTDriver{
 		interval:          keyInterval,
 		sendImpl:          send,
 		specialMap:        sMap,
-		scancodeMap:       scancodeMap,
+		layout:            keyboardLayout,
 		scancodeChunkSize: chunkSize,
-	}
+	}, nil
 }
 
 // Flush send all scanecodes.
@@ -145,31 +172,63 @@ func (d *pcXTDriver) Flush() error {
 }
 
 func (d *pcXTDriver) SendKey(key rune, action KeyAction) error {
-	keyShift := unicode.IsUpper(key) || strings.ContainsRune(shiftedChars, key)
+	keys, ok := d.layout.typeKeys(key)
+	if !ok {
+		if unicode.IsControl(key) {
+			log.Printf("[WARN] Skipping control char %U", key)
+			return nil
+		}
+		return fmt.Errorf("Can't type char '%c' with the %s keyboard layout.", key, d.layout.Name)
+	}
+
+	// The keys composing the char, like dead keys, are pressed with the
+	// first action typing it.
+	var sc []string
+	for i, k := range keys {
+		keyAction := action
+		if i < len(keys)-1 {
+			if action&(KeyOn|KeyPress) == 0 {
+				continue
+			}
+			keyAction = KeyPress
+		}
+		sc = append(sc, k.scancodes(keyAction)...)
+	}
+
+	log.Printf("Sending char '%c', code '%s'", key, strings.Join(sc, ""))
+
+	d.send(sc)
+	return nil
+}
 
+// scancodes returns the scancodes of action on k, with its modifiers.
+func (k layoutKey) scancodes(action KeyAction) []string {
 	var sc []string
 
 	if action&(KeyOn|KeyPress) != 0 {
-		scInt := d.scancodeMap[key]
-		if keyShift {
+		if k.shift {
 			sc = append(sc, "2a")
 		}
-		sc = append(sc, fmt.Sprintf("%02x", scInt))
+		if k.altGr {
+			sc = append(sc, "e0", "38")
+		}
+		sc = append(sc, fmt.Sprintf("%02x", k.code))
 	}
 
 	if action&(KeyOff|KeyPress) != 0 {
-		scInt := d.scancodeMap[key] + 0x80
-		sc = append(sc, fmt.Sprintf("%02x", scInt))
-		if keyShift {
+		sc = append(sc, fmt.Sprintf("%02x", k.code+0x80))
+		if k.altGr {
+			sc = append(sc, "e0", "b8")
+		}
+		if k.shift {
 			sc = append(sc, "aa")
 		}
+		if k.dead {
+			// A dead key types its character once followed by a space.
+			sc = append(sc, "39", "b9")
+		}
 	}
-
-	log.Printf("Sending char '%c', code '%s', shift %v",
-		key, strings.Join(sc, ""), keyShift)
-
-	d.send(sc)
-	return nil
+	return sc
 }
 
 func (d *pcXTDriver) SendSpecial(special string, action KeyAction) error {
@@ -107,6 +107,27 @@ func Test_pcxtSpecial(t *testing.T) {
 	assert.Equal(t, expected, codes)
 }
 
+func Test_pcxtExtendedSpecial(t *testing.T) {
+	in := "<f24><kpDivide><printScreen><pause>"
+	expected := []string{
+		"76", "f6",
+		"e0", "35", "e0", "b5",
+		"e0", "2a", "e0", "37", "e0", "b7", "e0", "aa",
+		"e1", "1d", "45", "e1", "9d", "c5",
+	}
+	var codes []string
+	sendCodes := func(c []string) error {
+		codes = append(codes, c...)
+		return nil
+	}
+	d := NewPCXTDriver(sendCodes, -1, time.Duration(0))
+	seq, err := GenerateExpressionSequence(in)
+	assert.NoError(t, err)
+	err = seq.Do(context.Background(), d)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, codes)
+}
+
 func Test_pcxtShift(t *testing.T) {
 	in := "AbC"
 	expected := []string{"2a", "1e", "9e", "aa", "30", "b0", "2a", "2e", "ae", "aa"}
@@ -151,3 +172,47 @@ func Test_KeyIntervalGiven(t *testing.T) {
 	d := NewPCXTDriver(nil, -1, time.Duration(5000)*time.Millisecond)
 	assert.Equal(t, d.interval, time.Duration(5000)*time.Millisecond)
 }
+
+func Test_pcxtLayout(t *testing.T) {
+	var layouttests = []struct {
+		layout   string
+		in       string
+		expected []string
+	}{
+		// Y and Z are swapped on German keyboards, @ is AltGr+q.
+		{"de", "zY@", []string{"15", "95", "2a", "2c", "ac", "aa", "e0", "38", "10", "90", "e0", "b8"}},
+		// The dead circumflex key is followed by a space.
+		{"de", "^", []string{"29", "a9", "39", "b9"}},
+		// The digits are shifted on French keyboards.
+		{"fr", "a1", []string{"10", "90", "2a", "02", "82", "aa"}},
+		{"jp", "@:\\", []string{"1a", "9a", "28", "a8", "73", "f3"}},
+		// The accented characters are composed with dead keys.
+		{"de", "éÂ", []string{"0d", "8d", "12", "92", "29", "a9", "2a", "1e", "9e", "aa"}},
+		{"fr", "ê", []string{"1a", "9a", "12", "92"}},
+		// Control characters are skipped.
+		{"us", "\n", nil},
+	}
+
+	for _, tt := range layouttests {
+		var codes []string
+		sendCodes := func(c []string) error {
+			codes = c
+			return nil
+		}
+		d, err := NewPCXTDriverWithLayout(sendCodes, -1, time.Duration(0), tt.layout)
+		assert.NoError(t, err)
+		seq, err := GenerateExpressionSequence(tt.in)
+		assert.NoError(t, err)
+		err = seq.Do(context.Background(), d)
+		assert.NoError(t, err)
+		assert.Equalf(t, tt.expected, codes, "typing %q with the %s layout", tt.in, tt.layout)
+	}
+
+	_, err := NewPCXTDriverWithLayout(nil, -1, time.Duration(0), "xx")
+	assert.Error(t, err)
+
+	// The characters no keys type can't be typed.
+	d := NewPCXTDriver(nil, -1, time.Duration(0))
+	assert.Error(t, d.SendKey('é', KeyPress))
+	assert.Error(t, d.SendKey('ñ', KeyPress))
+}
@@ -18,8 +18,8 @@ import (
 type SendUsbScanCodes func(k key.Code, down bool) error
 
 type usbDriver struct {
+	keyPacer
 	sendImpl    SendUsbScanCodes
-	interval    time.Duration
 	specialMap  map[string]key.Code
 	scancodeMap map[rune]key.Code
 }
@@ -55,6 +55,18 @@ func NewUSBDriver(send SendUsbScanCodes, interval time.Duration) *usbDriver {
 		"f10":        key.CodeF10,
 		"f11":        key.CodeF11,
 		"f12":        key.CodeF12,
+		"f13":        key.CodeF13,
+		"f14":        key.CodeF14,
+		"f15":        key.CodeF15,
+		"f16":        key.CodeF16,
+		"f17":        key.CodeF17,
+		"f18":        key.CodeF18,
+		"f19":        key.CodeF19,
+		"f20":        key.CodeF20,
+		"f21":        key.CodeF21,
+		"f22":        key.CodeF22,
+		"f23":        key.CodeF23,
+		"f24":        key.CodeF24,
 		"insert":     key.CodeInsert,
 		"home":       key.CodeHome,
 		"end":        key.CodeEnd,
@@ -73,6 +85,33 @@ func NewUSBDriver(send SendUsbScanCodes, interval time.Duration) *usbDriver {
 		"leftsuper":  key.CodeLeftGUI,
 		"rightsuper": key.CodeRightGUI,
 		"spacebar":   key.CodeSpacebar,
+		"altgr":      key.CodeRightAlt,
+		"capslock":   key.CodeCapsLock,
+		"numlock":    key.CodeKeypadNumLock,
+		"pause":      key.CodePause,
+		// The HID usages of the keys x/mobile has no code for.
+		"printscreen": key.Code(70),
+		"scrolllock":  key.Code(71),
+		"menu":        key.Code(101),
+		"kp0":         key.CodeKeypad0,
+		"kp1":         key.CodeKeypad1,
+		"kp2":         key.CodeKeypad2,
+		"kp3":         key.CodeKeypad3,
+		"kp4":         key.CodeKeypad4,
+		"kp5":         key.CodeKeypad5,
+		"kp6":         key.CodeKeypad6,
+		"kp7":         key.CodeKeypad7,
+		"kp8":         key.CodeKeypad8,
+		"kp9":         key.CodeKeypad9,
+		"kpdecimal":   key.CodeKeypadFullStop,
+		"kpdivide":    key.CodeKeypadSlash,
+		"kpmultiply":  key.CodeKeypadAsterisk,
+		"kpminus":     key.CodeKeypadHyphenMinus,
+		"kpplus":      key.CodeKeypadPlusSign,
+		"kpenter":     key.CodeKeypadEnter,
+		"mute":        key.CodeMute,
+		"volumeup":    key.CodeVolumeUp,
+		"volumedown":  key.CodeVolumeDown,
 	}
 
 	scancodeIndex := make(map[string]key.Code)
@@ -94,18 +133,25 @@ func NewUSBDriver(send SendUsbScanCodes, interval time.Duration) *usbDriver {
 	}
 
 	return &usbDriver{
+		keyPacer:    newKeyPacer(keyInterval),
 		sendImpl:    send,
 		specialMap:  special,
-		interval:    keyInterval,
 		scancodeMap: scancodeMap,
 	}
 }
 
+// SetKeyPacing humanizes the typing of the driver with p. The keys are
+// pressed and released by the send function at once, so that the driver
+// doesn't hold them down.
+func (d *usbDriver) SetKeyPacing(p KeyPacing) {
+	d.KeyPacing = p
+}
+
 func (d *usbDriver) keyEvent(k key.Code, down bool) error {
 	if err := d.sendImpl(k, down); err != nil {
 		return err
 	}
-	time.Sleep(d.interval)
+	d.wait()
 	return nil
 }
 
@@ -115,9 +161,20 @@ func (d *usbDriver) Flush() error {
 
 func (d *usbDriver) SendKey(k rune, action KeyAction) error {
 	keyShift := unicode.IsUpper(k) || strings.ContainsRune(shiftedChars, k)
-	keyCode := d.scancodeMap[k]
+	keyCode, ok := d.scancodeMap[k]
+	if !ok {
+		if unicode.IsControl(k) {
+			log.Printf("[WARN] Skipping control char %U", k)
+			return nil
+		}
+		return fmt.Errorf("Can't type char '%c' over USB.", k)
+	}
 	log.Printf("Sending char '%c', code %s, shift %v", k, keyCode, keyShift)
-	return d.keyEvent(keyCode, keyShift)
+	if err := d.keyEvent(keyCode, keyShift); err != nil {
+		return err
+	}
+	d.typed()
+	return nil
 }
 
 func (d *usbDriver) SendSpecial(special string, action KeyAction) (err error) {
@@ -32,6 +32,21 @@ func TestUSBDriver(t *testing.T) {
 			key.CodePageDown,
 			false,
 		},
+		{
+			"<f24>",
+			key.CodeF24,
+			false,
+		},
+		{
+			"<kpEnter>",
+			key.CodeKeypadEnter,
+			false,
+		},
+		{
+			"<capsLock>",
+			key.CodeCapsLock,
+			false,
+		},
 		{
 			"<leftShiftOff>",
 			key.CodeLeftShift,
@@ -114,3 +129,16 @@ func TestUSBDriver_KeyIntervalGiven(t *testing.T) {
 		t.Fatal("not expected key interval")
 	}
 }
+
+func TestUSBDriver_unknownChar(t *testing.T) {
+	d := NewUSBDriver(func(key.Code, bool) error {
+		t.Fatal("nothing should be sent")
+		return nil
+	}, time.Millisecond)
+	if err := d.SendKey('é', KeyPress); err == nil {
+		t.Fatal("should not type é")
+	}
+	if err := d.SendKey('\n', KeyPress); err != nil {
+		t.Fatalf("should skip control chars: %s", err)
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"context"
+	"crypto/des"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// VNC security types, see RFC 6143 section 7.2.
+const (
+	vncSecurityNone = 1
+	vncSecurityVNC  = 2
+)
+
+// maxVNCString is the length of the longest desktop name or failure reason
+// read from the servers, so that a misbehaving one can't make the client
+// allocate gigabytes.
+const maxVNCString = 64 * 1024
+
+// VNCDialConfig configures the connections to the VNC servers opened with
+// DialVNC.
+type VNCDialConfig struct {
+	// Address is the address of the VNC server: host:port for the VNC
+	// connections over TCP, or the ws:// or wss:// URL of a websocket
+	// endpoint, as exposed by several hypervisor APIs.
+	Address string
+	// TLSConfig wraps the TCP connections in TLS when set. It configures the
+	// wss:// connections too.
+	TLSConfig *tls.Config
+	// Header is sent with the websocket handshakes, for example to pass the
+	// tickets or cookies authenticating with the hypervisor APIs.
+	Header http.Header
+	// Origin is the origin of the websocket handshakes. It defaults to the
+	// http:// or https:// URL of the endpoint.
+	Origin string
+	// Password authenticates with the VNC servers requiring VNC
+	// authentication.
+	Password string
+}
+
+// VNCConn is a connection to a VNC server sending the key events of a VNC
+// driver, see NewVNCDriver.
+type VNCConn struct {
+	conn net.Conn
+	l    sync.Mutex
+	// Name is the name of the desktop sent by the server.
+	Name string
+}
+
+// DialVNC connects to the VNC server of config, over TCP, TLS or websocket,
+// and authenticates with it.
+func DialVNC(ctx context.Context, config VNCDialConfig) (*VNCConn, error) {
+	var u *url.URL
+	addr := config.Address
+	if strings.HasPrefix(config.Address, "ws://") || strings.HasPrefix(config.Address, "wss://") {
+		var err error
+		u, err = url.Parse(config.Address)
+		if err != nil {
+			return nil, err
+		}
+		addr = u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+			if u.Scheme == "wss" {
+				addr = net.JoinHostPort(u.Hostname(), "443")
+			}
+		}
+	}
+
+	var d net.Dialer
+	raw, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Abort the handshakes, of the transport and of VNC, when the context is
+	// done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			raw.Close()
+		case <-done:
+		}
+	}()
+
+	conn := raw
+	if u != nil {
+		conn, err = vncWebsocket(ctx, raw, u, addr, config)
+	} else if config.TLSConfig != nil {
+		conn, err = vncTLS(ctx, raw, config.TLSConfig, addr)
+	}
+	if err != nil {
+		raw.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	c := &VNCConn{conn: conn}
+	if err := c.handshake(config.Password); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("VNC handshake with %s: %s", config.Address, err)
+	}
+
+	// The server isn't asked for framebuffer updates: discard the messages
+	// it sends anyway, like bells, so that it never blocks writing them.
+	go io.Copy(ioutil.Discard, conn)
+	return c, nil
+}
+
+// vncTLS runs the TLS handshake with the server addr over conn.
+func vncTLS(ctx context.Context, conn net.Conn, config *tls.Config, addr string) (net.Conn, error) {
+	tlsConn := tls.Client(conn, tlsConfigFor(config, addr))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// vncWebsocket runs the websocket handshake with the server of u, at addr,
+// over conn, after the TLS one for wss:// URLs. The websocket handshake
+// isn't bound to ctx: DialVNC closes conn when ctx is done.
+func vncWebsocket(ctx context.Context, conn net.Conn, u *url.URL, addr string, config VNCDialConfig) (net.Conn, error) {
+	origin := config.Origin
+	if origin == "" {
+		origin = "http://" + u.Host
+		if u.Scheme == "wss" {
+			origin = "https://" + u.Host
+		}
+	}
+	wsConfig, err := websocket.NewConfig(config.Address, origin)
+	if err != nil {
+		return nil, err
+	}
+	// noVNC and most of the websocket proxies expect the binary protocol.
+	wsConfig.Protocol = []string{"binary"}
+	wsConfig.Header = config.Header
+
+	if u.Scheme == "wss" {
+		conn, err = vncTLS(ctx, conn, config.TLSConfig, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ws, err := websocket.NewClient(wsConfig, conn)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
+
+// tlsConfigFor returns config, verifying the host of addr when it doesn't
+// set a ServerName.
+func tlsConfigFor(config *tls.Config, addr string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName != "" || config.InsecureSkipVerify {
+		return config
+	}
+	config = config.Clone()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	config.ServerName = host
+	return config
+}
+
+// handshake runs the handshake of RFC 6143 section 7.1, up to the
+// ServerInit message.
+func (c *VNCConn) handshake(password string) error {
+	var version [12]byte
+	if _, err := io.ReadFull(c.conn, version[:]); err != nil {
+		return err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor); err != nil {
+		return fmt.Errorf("unexpected protocol version %q", version)
+	}
+	if major != 3 || minor < 3 {
+		return fmt.Errorf("unsupported protocol version %d.%d", major, minor)
+	}
+	if minor >= 8 {
+		minor = 8
+	} else if minor >= 7 {
+		minor = 7
+	} else {
+		minor = 3
+	}
+	if _, err := fmt.Fprintf(c.conn, "RFB 003.%03d\n", minor); err != nil {
+		return err
+	}
+
+	var security uint32
+	if minor == 3 {
+		if err := binary.Read(c.conn, binary.BigEndian, &security); err != nil {
+			return err
+		}
+		if security == 0 {
+			return c.readFailure()
+		}
+	} else {
+		var n uint8
+		if err := binary.Read(c.conn, binary.BigEndian, &n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return c.readFailure()
+		}
+		types := make([]byte, n)
+		if _, err := io.ReadFull(c.conn, types); err != nil {
+			return err
+		}
+		for _, t := range types {
+			if t == vncSecurityVNC && password != "" ||
+				t == vncSecurityNone && security != vncSecurityVNC {
+				security = uint32(t)
+			}
+		}
+		if security == 0 {
+			return fmt.Errorf("no supported security type in %v", types)
+		}
+		if _, err := c.conn.Write([]byte{byte(security)}); err != nil {
+			return err
+		}
+	}
+
+	switch security {
+	case vncSecurityNone:
+	case vncSecurityVNC:
+		var challenge [16]byte
+		if _, err := io.ReadFull(c.conn, challenge[:]); err != nil {
+			return err
+		}
+		response, err := vncAuthResponse(password, challenge[:])
+		if err != nil {
+			return err
+		}
+		if _, err := c.conn.Write(response); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported security type %d", security)
+	}
+
+	// The 3.3 and 3.7 servers don't send the result without authentication.
+	if security == vncSecurityVNC || minor == 8 {
+		var result uint32
+		if err := binary.Read(c.conn, binary.BigEndian, &result); err != nil {
+			return err
+		}
+		if result != 0 {
+			if minor == 8 {
+				return c.readFailure()
+			}
+			return fmt.Errorf("authentication failed")
+		}
+	}
+
+	// ClientInit, sharing the desktop with the other clients.
+	if _, err := c.conn.Write([]byte{1}); err != nil {
+		return err
+	}
+	// ServerInit: width, height, pixel format and name.
+	var serverInit [24]byte
+	if _, err := io.ReadFull(c.conn, serverInit[:]); err != nil {
+		return err
+	}
+	name, err := c.readString(binary.BigEndian.Uint32(serverInit[20:]))
+	if err != nil {
+		return fmt.Errorf("desktop name: %s", err)
+	}
+	c.Name = name
+	return nil
+}
+
+// readFailure returns the reason of a failure sent by the server.
+func (c *VNCConn) readFailure() error {
+	var n uint32
+	if err := binary.Read(c.conn, binary.BigEndian, &n); err != nil {
+		return err
+	}
+	reason, err := c.readString(n)
+	if err != nil {
+		return fmt.Errorf("failure reason: %s", err)
+	}
+	return fmt.Errorf("server error: %s", reason)
+}
+
+// readString reads a string of n bytes, at most maxVNCString.
+func (c *VNCConn) readString(n uint32) (string, error) {
+	if n > maxVNCString {
+		return "", fmt.Errorf("%d bytes is longer than the limit of %d", n, maxVNCString)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// vncAuthResponse encrypts the challenge with DES, keyed with the 8 first
+// bytes of the password with their bits reversed, see RFC 6143 section 7.2.2.
+func vncAuthResponse(password string, challenge []byte) ([]byte, error) {
+	var key [8]byte
+	copy(key[:], password)
+	for i, b := range key {
+		var r byte
+		for j := 0; j < 8; j++ {
+			r = r<<1 | b>>j&1
+		}
+		key[i] = r
+	}
+	block, err := des.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	response := make([]byte, len(challenge))
+	for i := 0; i+des.BlockSize <= len(challenge); i += des.BlockSize {
+		block.Encrypt(response[i:], challenge[i:])
+	}
+	return response, nil
+}
+
+// KeyEvent sends the press, or the release, of the key keysym.
+func (c *VNCConn) KeyEvent(keysym uint32, down bool) error {
+	msg := [8]byte{4}
+	if down {
+		msg[1] = 1
+	}
+	binary.BigEndian.PutUint32(msg[4:], keysym)
+	c.l.Lock()
+	defer c.l.Unlock()
+	_, err := c.conn.Write(msg[:])
+	return err
+}
+
+// Close closes the connection to the server.
+func (c *VNCConn) Close() error {
+	return c.conn.Close()
+}
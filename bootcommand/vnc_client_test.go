@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// serveTestVNC runs the handshake of an RFB 3.8 server requiring password,
+// then sends the key events received to events.
+func serveTestVNC(t *testing.T, conn io.ReadWriteCloser, password string, events chan<- event) {
+	defer conn.Close()
+	io.WriteString(conn, "RFB 003.008\n")
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(conn, version); err != nil || string(version) != "RFB 003.008\n" {
+		t.Errorf("bad version %q: %v", version, err)
+		return
+	}
+	conn.Write([]byte{1, vncSecurityVNC})
+	security := make([]byte, 1)
+	io.ReadFull(conn, security)
+	challenge := []byte("0123456789abcdef")
+	conn.Write(challenge)
+	response := make([]byte, 16)
+	io.ReadFull(conn, response)
+	expected, _ := vncAuthResponse(password, challenge)
+	if !bytes.Equal(response, expected) {
+		reason := "bad password"
+		binary.Write(conn, binary.BigEndian, []uint32{1, uint32(len(reason))})
+		io.WriteString(conn, reason)
+		return
+	}
+	binary.Write(conn, binary.BigEndian, uint32(0))
+	io.ReadFull(conn, make([]byte, 1))
+	name := "test desktop"
+	serverInit := make([]byte, 24)
+	binary.BigEndian.PutUint32(serverInit[20:], uint32(len(name)))
+	conn.Write(append(serverInit, name...))
+
+	for {
+		msg := make([]byte, 8)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			close(events)
+			return
+		}
+		events <- event{binary.BigEndian.Uint32(msg[4:]), msg[1] == 1}
+	}
+}
+
+func testVNCDriverTyping(t *testing.T, config VNCDialConfig, events chan event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialVNC(ctx, config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "test desktop", c.Name)
+
+	seq, err := GenerateExpressionSequence("a<enter>")
+	assert.NoError(t, err)
+	assert.NoError(t, seq.Do(ctx, NewVNCDriver(c, time.Millisecond)))
+	c.Close()
+	var actual []event
+	for e := range events {
+		actual = append(actual, e)
+	}
+	assert.Equal(t, []event{{'a', true}, {'a', false}, {0xFF0D, true}, {0xFF0D, false}}, actual)
+}
+
+func TestDialVNC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	events := make(chan event, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			serveTestVNC(t, conn, "secret", events)
+		}
+	}()
+	testVNCDriverTyping(t, VNCDialConfig{Address: l.Addr().String(), Password: "secret"}, events)
+
+	// A bad password
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			serveTestVNC(t, conn, "secret", make(chan event))
+		}
+	}()
+	_, err = DialVNC(context.Background(), VNCDialConfig{Address: l.Addr().String(), Password: "guess"})
+	if err == nil || !strings.Contains(err.Error(), "bad password") {
+		t.Fatalf("expected a bad password error, got %v", err)
+	}
+
+	// A failure reason too long to be read
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.WriteString(conn, "RFB 003.008\n")
+			io.ReadFull(conn, make([]byte, 12))
+			conn.Write([]byte{0})
+			binary.Write(conn, binary.BigEndian, uint32(0xFFFFFFFF))
+		}
+	}()
+	_, err = DialVNC(context.Background(), VNCDialConfig{Address: l.Addr().String()})
+	if err == nil || !strings.Contains(err.Error(), "longer than the limit") {
+		t.Fatalf("expected a too long reason error, got %v", err)
+	}
+}
+
+func TestDialVNC_tlsCancel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	// The server never answers the TLS handshake.
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = DialVNC(ctx, VNCDialConfig{
+		Address:   l.Addr().String(),
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestDialVNC_websocket(t *testing.T) {
+	events := make(chan event, 10)
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		if ws.Request().Header.Get("Authorization") != "ticket" {
+			t.Error("missing header")
+		}
+		ws.PayloadType = websocket.BinaryFrame
+		serveTestVNC(t, ws, "secret", events)
+	}))
+	defer server.Close()
+
+	testVNCDriverTyping(t, VNCDialConfig{
+		Address:  "ws" + strings.TrimPrefix(server.URL, "http") + "/vnc",
+		Header:   map[string][]string{"Authorization": {"ticket"}},
+		Password: "secret",
+	}, events)
+}
+
+func TestDialVNC_websocketTLS(t *testing.T) {
+	events := make(chan event, 10)
+	server := httptest.NewTLSServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		serveTestVNC(t, ws, "secret", events)
+	}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	testVNCDriverTyping(t, VNCDialConfig{
+		Address:   "wss" + strings.TrimPrefix(server.URL, "https"),
+		TLSConfig: &tls.Config{RootCAs: roots},
+		Password:  "secret",
+	}, events)
+}
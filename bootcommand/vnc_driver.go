@@ -19,8 +19,8 @@ type VNCKeyEvent interface {
 }
 
 type vncDriver struct {
+	keyPacer
 	c          VNCKeyEvent
-	interval   time.Duration
 	specialMap map[string]uint32
 	// keyEvent can set this error which will prevent it from continuing
 	err error
@@ -40,7 +40,9 @@ func NewVNCDriver(c VNCKeyEvent, interval time.Duration) *vncDriver {
 
 	// Scancodes reference: https://github.com/qemu/qemu/blob/master/ui/vnc_keysym.h
 	sMap := make(map[string]uint32)
+	sMap["altgr"] = 0xFE03
 	sMap["bs"] = 0xFF08
+	sMap["capslock"] = 0xFFE5
 	sMap["del"] = 0xFFFF
 	sMap["down"] = 0xFF54
 	sMap["end"] = 0xFF57
@@ -58,33 +60,73 @@ func NewVNCDriver(c VNCKeyEvent, interval time.Duration) *vncDriver {
 	sMap["f10"] = 0xFFC7
 	sMap["f11"] = 0xFFC8
 	sMap["f12"] = 0xFFC9
+	sMap["f13"] = 0xFFCA
+	sMap["f14"] = 0xFFCB
+	sMap["f15"] = 0xFFCC
+	sMap["f16"] = 0xFFCD
+	sMap["f17"] = 0xFFCE
+	sMap["f18"] = 0xFFCF
+	sMap["f19"] = 0xFFD0
+	sMap["f20"] = 0xFFD1
+	sMap["f21"] = 0xFFD2
+	sMap["f22"] = 0xFFD3
+	sMap["f23"] = 0xFFD4
+	sMap["f24"] = 0xFFD5
 	sMap["home"] = 0xFF50
 	sMap["insert"] = 0xFF63
+	sMap["kp0"] = 0xFFB0
+	sMap["kp1"] = 0xFFB1
+	sMap["kp2"] = 0xFFB2
+	sMap["kp3"] = 0xFFB3
+	sMap["kp4"] = 0xFFB4
+	sMap["kp5"] = 0xFFB5
+	sMap["kp6"] = 0xFFB6
+	sMap["kp7"] = 0xFFB7
+	sMap["kp8"] = 0xFFB8
+	sMap["kp9"] = 0xFFB9
+	sMap["kpdecimal"] = 0xFFAE
+	sMap["kpdivide"] = 0xFFAF
+	sMap["kpenter"] = 0xFF8D
+	sMap["kpminus"] = 0xFFAD
+	sMap["kpmultiply"] = 0xFFAA
+	sMap["kpplus"] = 0xFFAB
 	sMap["left"] = 0xFF51
 	sMap["leftalt"] = 0xFFE9
 	sMap["leftctrl"] = 0xFFE3
 	sMap["leftshift"] = 0xFFE1
 	sMap["leftsuper"] = 0xFFEB
 	sMap["menu"] = 0xFF67
+	sMap["mute"] = 0x1008FF12
+	sMap["numlock"] = 0xFF7F
 	sMap["pagedown"] = 0xFF56
 	sMap["pageup"] = 0xFF55
+	sMap["pause"] = 0xFF13
+	sMap["printscreen"] = 0xFF61
 	sMap["return"] = 0xFF0D
 	sMap["right"] = 0xFF53
 	sMap["rightalt"] = 0xFFEA
 	sMap["rightctrl"] = 0xFFE4
 	sMap["rightshift"] = 0xFFE2
 	sMap["rightsuper"] = 0xFFEC
+	sMap["scrolllock"] = 0xFF14
 	sMap["spacebar"] = 0x020
 	sMap["tab"] = 0xFF09
 	sMap["up"] = 0xFF52
+	sMap["volumedown"] = 0x1008FF11
+	sMap["volumeup"] = 0x1008FF13
 
 	return &vncDriver{
+		keyPacer:   newKeyPacer(keyInterval),
 		c:          c,
-		interval:   keyInterval,
 		specialMap: sMap,
 	}
 }
 
+// SetKeyPacing humanizes the typing of the driver with p.
+func (d *vncDriver) SetKeyPacing(p KeyPacing) {
+	d.KeyPacing = p
+}
+
 func (d *vncDriver) keyEvent(k uint32, down bool) error {
 	if d.err != nil {
 		return nil
@@ -93,7 +135,7 @@ func (d *vncDriver) keyEvent(k uint32, down bool) error {
 		d.err = err
 		return err
 	}
-	time.Sleep(d.interval)
+	d.wait()
 	return nil
 }
 
@@ -105,6 +147,11 @@ func (d *vncDriver) Flush() error {
 func (d *vncDriver) SendKey(key rune, action KeyAction) error {
 	keyShift := unicode.IsUpper(key) || strings.ContainsRune(shiftedChars, key)
 	keyCode := uint32(key)
+	if key > 0xFF {
+		// The keysyms of the chars out of Latin-1 are their code points
+		// with 0x01000000, the servers inject them whatever the keyboard.
+		keyCode |= 0x01000000
+	}
 	log.Printf("Sending char '%c', code 0x%X, shift %v", key, keyCode, keyShift)
 
 	switch action {
@@ -118,15 +165,18 @@ func (d *vncDriver) SendKey(key rune, action KeyAction) error {
 		if keyShift {
 			d.keyEvent(KeyLeftShift, false)
 		}
+		d.typed()
 	case KeyPress:
 		if keyShift {
 			d.keyEvent(KeyLeftShift, true)
 		}
 		d.keyEvent(keyCode, true)
+		d.hold()
 		d.keyEvent(keyCode, false)
 		if keyShift {
 			d.keyEvent(KeyLeftShift, false)
 		}
+		d.typed()
 	}
 	return d.err
 }
@@ -145,6 +195,7 @@ func (d *vncDriver) SendSpecial(special string, action KeyAction) error {
 		d.keyEvent(keyCode, false)
 	case KeyPress:
 		d.keyEvent(keyCode, true)
+		d.hold()
 		d.keyEvent(keyCode, false)
 	}
 
@@ -42,6 +42,26 @@ func Test_vncSpecialLookup(t *testing.T) {
 	assert.Equal(t, expected, s.e)
 }
 
+func Test_vncExtendedSpecial(t *testing.T) {
+	in := "<f13><kp0><altGrOn><volumeUp>"
+	expected := []event{
+		{0xFFCA, true},
+		{0xFFCA, false},
+		{0xFFB0, true},
+		{0xFFB0, false},
+		{0xFE03, true},
+		{0x1008FF13, true},
+		{0x1008FF13, false},
+	}
+	s := &sender{}
+	d := NewVNCDriver(s, time.Duration(0))
+	seq, err := GenerateExpressionSequence(in)
+	assert.NoError(t, err)
+	err = seq.Do(context.Background(), d)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, s.e)
+}
+
 func Test_vncShiftSequence(t *testing.T) {
 	in := "AbC"
 	expected := []event{
@@ -93,3 +113,20 @@ func Test_vncIntervalGiven(t *testing.T) {
 	d := NewVNCDriver(s, time.Duration(5000)*time.Millisecond)
 	assert.Equal(t, d.interval, time.Duration(5000)*time.Millisecond)
 }
+
+func Test_vncUnicode(t *testing.T) {
+	in := "é€"
+	expected := []event{
+		{0x0E9, true},
+		{0x0E9, false},
+		{0x10020AC, true},
+		{0x10020AC, false},
+	}
+	s := &sender{}
+	d := NewVNCDriver(s, time.Millisecond)
+	seq, err := GenerateExpressionSequence(in)
+	assert.NoError(t, err)
+	err = seq.Do(context.Background(), d)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, s.e)
+}
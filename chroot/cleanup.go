@@ -4,6 +4,12 @@
 package chroot
 
 import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
 
@@ -11,3 +17,136 @@ import (
 type Cleanup interface {
 	CleanupFunc(multistep.StateBag) error
 }
+
+// signalCleanups holds the cleanups of the builds in progress, which must run
+// if the process receives an interrupt or termination signal.
+var signalCleanups = &signalCleanupRegistry{}
+
+// RegisterSignalCleanup makes the build of state halt if the process
+// receives SIGINT or SIGTERM before the CleanupFunc of c has been run with
+// RunCleanup. This makes sure mounts, device-mapper entries, loop devices and
+// the like are released even when the build is interrupted mid-step.
+//
+// The cleanups aren't run by the signal handler, as the step running may
+// still use what they release: the state is marked cancelled, like when the
+// context of the runner is, and the runner halts once the step returns, the
+// Cleanup methods of the steps running the cleanups in the reverse order of
+// the steps. The cancellation only takes effect after the running step
+// returns, so a step blocked on a command that ignores the signal delays it.
+// The signals are only caught while cleanups are registered; the default
+// handling is restored once the last one has been run. Registering the same
+// cleanup more than once has no effect.
+func RegisterSignalCleanup(state multistep.StateBag, c Cleanup) {
+	signalCleanups.register(state, c)
+}
+
+// RunCleanup runs the CleanupFunc of c and removes it from the cleanups of
+// its build.
+func RunCleanup(state multistep.StateBag, c Cleanup) error {
+	return signalCleanups.run(state, c)
+}
+
+type signalCleanupEntry struct {
+	state   multistep.StateBag
+	cleanup Cleanup
+}
+
+type signalCleanupRegistry struct {
+	mu      sync.Mutex
+	entries []signalCleanupEntry
+	// signals receives the signals while there are entries.
+	signals chan os.Signal
+	// notify relays the signals to a channel, signal.Notify when nil.
+	notify func(chan<- os.Signal, ...os.Signal)
+}
+
+func (r *signalCleanupRegistry) register(state multistep.StateBag, c Cleanup) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.cleanup == c {
+			return
+		}
+	}
+	r.entries = append(r.entries, signalCleanupEntry{state: state, cleanup: c})
+	if r.signals == nil {
+		r.listen()
+	}
+}
+
+// listen must be called with the lock held.
+func (r *signalCleanupRegistry) listen() {
+	notify := r.notify
+	if notify == nil {
+		notify = signal.Notify
+	}
+	ch := make(chan os.Signal, 1)
+	notify(ch, os.Interrupt, syscall.SIGTERM)
+	r.signals = ch
+	go func() {
+		for sig := range ch {
+			if !r.handle(sig) {
+				raise(sig)
+			}
+		}
+	}()
+}
+
+func (r *signalCleanupRegistry) run(state multistep.StateBag, c Cleanup) error {
+	r.mu.Lock()
+	r.remove(c)
+	r.mu.Unlock()
+	return c.CleanupFunc(state)
+}
+
+// remove must be called with the lock held.
+func (r *signalCleanupRegistry) remove(c Cleanup) {
+	for i, e := range r.entries {
+		if e.cleanup == c {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			if len(r.entries) == 0 && r.signals != nil {
+				// Restore the default handling of the signals.
+				signal.Stop(r.signals)
+				close(r.signals)
+				r.signals = nil
+			}
+			return
+		}
+	}
+}
+
+// handle cancels the builds with cleanups left to run, for their runners to
+// halt and run the Cleanup methods of their steps. It returns false when
+// there was no build to cancel, the signal having been received as the last
+// cleanup was run.
+func (r *signalCleanupRegistry) handle(sig os.Signal) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 {
+		return false
+	}
+	log.Printf("Received %s, halting the builds with %d chroot cleanup(s) left", sig, len(r.entries))
+	cancelled := map[multistep.StateBag]bool{}
+	for _, e := range r.entries {
+		if cancelled[e.state] {
+			continue
+		}
+		cancelled[e.state] = true
+		e.state.Put(multistep.StateCancelCause, &multistep.CancelCause{Reason: multistep.CancelReasonUser})
+		e.state.Put(multistep.StateCancelled, true)
+	}
+	return true
+}
+
+// raise sends sig to the process again, for its default handling, the
+// handler having been stopped.
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		log.Printf("Error raising %s again: %s", sig, err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+type testCleanup struct {
+	name string
+	ran  *[]string
+}
+
+func (c *testCleanup) CleanupFunc(multistep.StateBag) error {
+	*c.ran = append(*c.ran, c.name)
+	return nil
+}
+
+func TestSignalCleanupRegistry(t *testing.T) {
+	var ran []string
+	first := &testCleanup{name: "first", ran: &ran}
+	second := &testCleanup{name: "second", ran: &ran}
+
+	r := &signalCleanupRegistry{notify: func(chan<- os.Signal, ...os.Signal) {}}
+	state := new(multistep.BasicStateBag)
+	other := new(multistep.BasicStateBag)
+	r.register(state, first)
+	r.register(state, second)
+	r.register(state, second)
+	otherCleanup := &testCleanup{name: "other", ran: &ran}
+	r.register(other, otherCleanup)
+
+	if err := r.run(state, second); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !r.handle(os.Interrupt) {
+		t.Fatal("the signal should be handled")
+	}
+
+	// The cleanups are left to the runners.
+	if !reflect.DeepEqual(ran, []string{"second"}) {
+		t.Fatalf("expected only the second cleanup to run, got %v", ran)
+	}
+	for _, s := range []multistep.StateBag{state, other} {
+		if _, ok := s.GetOk(multistep.StateCancelled); !ok {
+			t.Fatal("the builds should be cancelled")
+		}
+		cause, _ := s.Get(multistep.StateCancelCause).(*multistep.CancelCause)
+		if cause == nil || cause.Reason != multistep.CancelReasonUser {
+			t.Fatalf("bad cause %#v", cause)
+		}
+	}
+
+	// Once their cleanups ran, the builds are left alone.
+	r.run(state, first)
+	r.run(other, otherCleanup)
+	state.Remove(multistep.StateCancelled)
+	if r.signals != nil {
+		t.Fatal("the signals should no longer be caught")
+	}
+	if r.handle(os.Interrupt) {
+		t.Fatal("the signal should be left to its default handling")
+	}
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		t.Fatal("the build without cleanups left should not be cancelled")
+	}
+}
+
+// signalStep registers its cleanup, then receives a signal while running.
+type signalStep struct {
+	cleanup *testCleanup
+	signal  bool
+}
+
+func (s *signalStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	RegisterSignalCleanup(state, s.cleanup)
+	if s.signal {
+		signalCleanups.handle(os.Interrupt)
+		// The step still uses what its cleanup releases.
+		if len(*s.cleanup.ran) != 0 {
+			panic("cleanup ran during the step")
+		}
+	}
+	return multistep.ActionContinue
+}
+
+func (s *signalStep) Cleanup(state multistep.StateBag) {
+	RunCleanup(state, s.cleanup)
+}
+
+func TestRegisterSignalCleanup_runner(t *testing.T) {
+	defer func(r *signalCleanupRegistry) { signalCleanups = r }(signalCleanups)
+	signalCleanups = &signalCleanupRegistry{notify: func(chan<- os.Signal, ...os.Signal) {}}
+
+	var ran []string
+	last := &testCleanup{name: "last", ran: &ran}
+	runner := &multistep.BasicRunner{Steps: []multistep.Step{
+		&signalStep{cleanup: &testCleanup{name: "mount", ran: &ran}},
+		&signalStep{cleanup: &testCleanup{name: "copy", ran: &ran}, signal: true},
+		&signalStep{cleanup: last},
+	}}
+	state := new(multistep.BasicStateBag)
+	runner.Run(context.Background(), state)
+
+	if !reflect.DeepEqual(ran, []string{"copy", "mount"}) {
+		t.Fatalf("expected the cleanups of the steps run, in reverse, got %v", ran)
+	}
+	if _, ok := state.GetOk(multistep.StateCancelled); !ok {
+		t.Fatal("the build should be cancelled")
+	}
+}
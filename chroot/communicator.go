@@ -4,7 +4,6 @@
 package chroot
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -26,6 +25,10 @@ import (
 type Communicator struct {
 	Chroot     string
 	CmdWrapper common.CommandWrapper
+
+	// SymlinkPolicy controls how symbolic links are handled by UploadDir,
+	// DownloadDir and directory downloads. Links are preserved by default.
+	SymlinkPolicy SymlinkPolicy
 }
 
 func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
@@ -91,45 +94,25 @@ func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
 }
 
 func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
-	// If src ends with a trailing "/", copy from "src/." so that
-	// directory contents (including hidden files) are copied, but the
-	// directory "src" is omitted.  BSD does this automatically when
-	// the source contains a trailing slash, but linux does not.
-	if src[len(src)-1] == '/' {
-		src = src + "."
-	}
-
-	// TODO: remove any file copied if it appears in `exclude`
 	chrootDest := filepath.Join(c.Chroot, dst)
 
 	log.Printf("Uploading directory '%s' to '%s'", src, chrootDest)
-	cpCmd, err := c.CmdWrapper(fmt.Sprintf("cp -R '%s' %s", src, chrootDest))
-	if err != nil {
-		return err
-	}
-
-	var stderr bytes.Buffer
-	cmd := common.ShellCommand(cpCmd)
-	cmd.Env = append(cmd.Env, "LANG=C")
-	cmd.Env = append(cmd.Env, os.Environ()...)
-	cmd.Stderr = &stderr
-	err = cmd.Run()
-	if err == nil {
-		return err
-	}
-
-	if strings.Contains(stderr.String(), "No such file") {
-		// This just means that the directory was empty. Just ignore it.
-		return nil
-	}
-
-	return err
+	return c.copyTree(src, chrootDest, exclude)
 }
 
 func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
-	return fmt.Errorf("DownloadDir is not implemented for amazon-chroot")
+	// Keep the trailing slash, which filepath.Join would otherwise strip.
+	chrootSrc := filepath.Join(c.Chroot, src)
+	if strings.HasSuffix(src, "/") {
+		chrootSrc += "/"
+	}
+
+	log.Printf("Downloading directory '%s' to '%s'", chrootSrc, dst)
+	return c.copyTree(chrootSrc, dst, exclude)
 }
 
+// Download copies the file at src to w. If src is a directory, a tar archive
+// of its contents is written instead.
 func (c *Communicator) Download(src string, w io.Writer) error {
 	src = filepath.Join(c.Chroot, src)
 	log.Printf("Downloading from chroot dir: %s", src)
@@ -139,6 +122,14 @@ func (c *Communicator) Download(src string, w io.Writer) error {
 	}
 	defer f.Close()
 
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return c.archiveTree(src+"/", nil, w)
+	}
+
 	if _, err := io.Copy(w, f); err != nil {
 		return err
 	}
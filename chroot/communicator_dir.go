@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/pathmatch"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// SymlinkPolicy controls how symbolic links are handled when directories are
+// copied in or out of the chroot.
+type SymlinkPolicy string
+
+const (
+	// SymlinkPreserve copies symbolic links as links. This is the default.
+	SymlinkPreserve SymlinkPolicy = "preserve"
+	// SymlinkFollow copies the file or directory a symbolic link points to.
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkSkip leaves symbolic links out of the copy.
+	SymlinkSkip SymlinkPolicy = "skip"
+)
+
+// copyTree copies the directory src to the directory dst, both being host
+// paths. Following rsync(1), the directory src itself is created within dst
+// unless src has a trailing slash.
+func (c *Communicator) copyTree(src string, dst string, exclude []string) error {
+	archive, err := tmp.File("packer-chroot-dir")
+	if err != nil {
+		return fmt.Errorf("Error preparing archive: %s", err)
+	}
+	defer os.Remove(archive.Name())
+
+	err = c.archiveTree(src, exclude, archive)
+	archive.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := c.runWrapped(fmt.Sprintf("mkdir -p '%s'", dst), nil); err != nil {
+		return err
+	}
+	return c.runWrapped(fmt.Sprintf(
+		"tar -x --no-same-owner -f '%s' -C '%s'", archive.Name(), dst), nil)
+}
+
+// archiveTree writes a tar archive of the directory src to w.
+func (c *Communicator) archiveTree(src string, exclude []string, w io.Writer) error {
+	base, root := filepath.Dir(src), filepath.Base(src)
+	if strings.HasSuffix(src, "/") {
+		base, root = src, "."
+	}
+
+	entries, err := c.treeEntries(base, root, exclude)
+	if err != nil {
+		return err
+	}
+
+	list, err := tmp.File("packer-chroot-list")
+	if err != nil {
+		return fmt.Errorf("Error preparing file list: %s", err)
+	}
+	defer os.Remove(list.Name())
+	_, err = list.WriteString(strings.Join(entries, "\x00"))
+	list.Close()
+	if err != nil {
+		return err
+	}
+
+	flags := ""
+	if c.SymlinkPolicy == SymlinkFollow {
+		flags = " -h"
+	}
+	log.Printf("Archiving %d entries from '%s'", len(entries), src)
+	return c.runWrapped(fmt.Sprintf(
+		"tar -c%s -f - -C '%s' --no-recursion --null -T '%s'", flags, base, list.Name()), w)
+}
+
+// treeEntries lists the paths, relative to base, of root and everything
+// below it that is not excluded and allowed by the symlink policy.
+func (c *Communicator) treeEntries(base string, root string, exclude []string) ([]string, error) {
+	var entries []string
+	visited := make(map[string]bool)
+
+	var visit func(rel string) error
+	visit = func(rel string) error {
+		path := filepath.Join(base, rel)
+		fi, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			switch c.SymlinkPolicy {
+			case SymlinkSkip:
+				log.Printf("Skipping symlink: %s", path)
+				return nil
+			case SymlinkFollow:
+				if fi, err = os.Stat(path); err != nil {
+					return err
+				}
+			default:
+				entries = append(entries, rel)
+				return nil
+			}
+		}
+		entries = append(entries, rel)
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		// Guard against symlink loops when following links.
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		if visited[real] {
+			return nil
+		}
+		visited[real] = true
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			child := filepath.Join(rel, name)
+			relRoot, err := filepath.Rel(root, child)
+			if err != nil {
+				return err
+			}
+			if pathmatch.Excluded(relRoot, exclude) {
+				log.Printf("Excluding: %s", filepath.Join(base, child))
+				continue
+			}
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// runWrapped runs a host command through the command wrapper, sending its
+// standard output to stdout when it is not nil.
+func (c *Communicator) runWrapped(command string, stdout io.Writer) error {
+	wrapped, err := c.CmdWrapper(command)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := common.ShellCommand(wrapped)
+	cmd.Env = append(cmd.Env, "LANG=C")
+	cmd.Env = append(cmd.Env, os.Environ()...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error running %q: %s\nStderr: %s", wrapped, err, stderr.String())
+	}
+	return nil
+}
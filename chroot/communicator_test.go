@@ -4,6 +4,13 @@
 package chroot
 
 import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -17,3 +24,148 @@ func TestCommunicator_ImplementsCommunicator(t *testing.T) {
 		t.Fatalf("Communicator should be a communicator")
 	}
 }
+
+func testTree(t *testing.T) string {
+	src := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "a",
+		"skip.log":      "log",
+		"sub/b.txt":     "b",
+		"sub/c.log":     "log",
+		"cache/big.bin": "big",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("sub", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestCommunicator_UploadDir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("tar behavior is only tested on linux")
+	}
+
+	tests := []struct {
+		name     string
+		policy   SymlinkPolicy
+		present  []string
+		missing  []string
+		linkKind os.FileMode
+	}{
+		{
+			name:     "preserve",
+			present:  []string{"src/a.txt", "src/sub/b.txt", "src/link"},
+			missing:  []string{"src/skip.log", "src/sub/c.log", "src/cache"},
+			linkKind: os.ModeSymlink,
+		},
+		{
+			name:     "follow",
+			policy:   SymlinkFollow,
+			present:  []string{"src/link/b.txt"},
+			missing:  []string{"src/link/c.log"},
+			linkKind: os.ModeDir,
+		},
+		{
+			name:    "skip",
+			policy:  SymlinkSkip,
+			present: []string{"src/a.txt"},
+			missing: []string{"src/link"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			src := filepath.Join(base, "src")
+			if err := os.Rename(testTree(t), src); err != nil {
+				t.Fatal(err)
+			}
+			chroot := t.TempDir()
+			comm := &Communicator{
+				Chroot:        chroot,
+				CmdWrapper:    func(s string) (string, error) { return s, nil },
+				SymlinkPolicy: tt.policy,
+			}
+
+			if err := comm.UploadDir("/dest", src, []string{"*.log", "cache/"}); err != nil {
+				t.Fatalf("UploadDir: %s", err)
+			}
+
+			for _, p := range tt.present {
+				if _, err := os.Lstat(filepath.Join(chroot, "dest", p)); err != nil {
+					t.Errorf("expected %s to be uploaded: %s", p, err)
+				}
+			}
+			for _, p := range tt.missing {
+				if _, err := os.Lstat(filepath.Join(chroot, "dest", p)); err == nil {
+					t.Errorf("expected %s to be excluded", p)
+				}
+			}
+			if tt.linkKind != 0 {
+				fi, err := os.Lstat(filepath.Join(chroot, "dest", "src", "link"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode()&tt.linkKind == 0 {
+					t.Errorf("unexpected mode for link: %s", fi.Mode())
+				}
+			}
+		})
+	}
+}
+
+func TestCommunicator_DownloadDir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("tar behavior is only tested on linux")
+	}
+
+	chroot := t.TempDir()
+	if err := os.Rename(testTree(t), filepath.Join(chroot, "src")); err != nil {
+		t.Fatal(err)
+	}
+	comm := &Communicator{
+		Chroot:     chroot,
+		CmdWrapper: func(s string) (string, error) { return s, nil },
+	}
+
+	dst := t.TempDir()
+	if err := comm.DownloadDir("/src/", dst, []string{"sub"}); err != nil {
+		t.Fatalf("DownloadDir: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil {
+		t.Errorf("expected a.txt to be downloaded: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sub")); err == nil {
+		t.Errorf("expected sub to be excluded")
+	}
+
+	var buf bytes.Buffer
+	if err := comm.Download("/src", &buf); err != nil {
+		t.Fatalf("Download: %s", err)
+	}
+	tr := tar.NewReader(&buf)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name == "sub/b.txt" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected directory download to contain sub/b.txt")
+	}
+}
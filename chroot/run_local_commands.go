@@ -4,6 +4,7 @@
 package chroot
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 
@@ -43,3 +44,24 @@ func RunLocalCommands(commands []string, wrappedCommand common.CommandWrapper, i
 	}
 	return nil
 }
+
+// runWrappedCommand runs a single host command through the command wrapper
+// and returns its standard output. Standard error is included in the returned
+// error when the command fails.
+func runWrappedCommand(wrappedCommand common.CommandWrapper, command string) (string, error) {
+	wrapped, err := wrappedCommand(command)
+	if err != nil {
+		return "", fmt.Errorf("Error wrapping command: %s", err)
+	}
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd := common.ShellCommand(wrapped)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf(
+			"Error running %q: %s\nStderr: %s", wrapped, err, stderr.String())
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepBtrfsSnapshot creates a writable btrfs snapshot of a source subvolume
+// and uses it as the chroot, instead of attaching and mounting a device. The
+// snapshot is deleted when the build fails or is cancelled, and also when it
+// succeeds unless Keep is set.
+//
+// Produces:
+//
+//	mount_path string - The path of the snapshot.
+type StepBtrfsSnapshot struct {
+	// Source is the path of the subvolume to snapshot.
+	Source string
+	// Destination is the path where the snapshot will be created.
+	Destination string
+	// Keep leaves the snapshot in place after a successful build.
+	Keep bool
+
+	snapshot string
+}
+
+func (s *StepBtrfsSnapshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	ui.Say(fmt.Sprintf("Creating btrfs snapshot of %s...", s.Source))
+	_, err := runWrappedCommand(wrappedCommand, fmt.Sprintf(
+		"btrfs subvolume snapshot %s %s", s.Source, s.Destination))
+	if err != nil {
+		err := fmt.Errorf("Error creating snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.snapshot = s.Destination
+	state.Put("mount_path", s.snapshot)
+	RegisterSignalCleanup(state, s)
+	return multistep.ActionContinue
+}
+
+func (s *StepBtrfsSnapshot) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if s.Keep && !cancelled && !halted {
+		log.Printf("Keeping btrfs snapshot: %s", s.snapshot)
+		s.snapshot = ""
+	}
+
+	if err := RunCleanup(state, s); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+// CleanupFunc deletes the snapshot.
+func (s *StepBtrfsSnapshot) CleanupFunc(state multistep.StateBag) error {
+	if s.snapshot == "" {
+		return nil
+	}
+
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+	log.Printf("Deleting btrfs snapshot: %s", s.snapshot)
+	if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf(
+		"btrfs subvolume delete %s", s.snapshot)); err != nil {
+		return fmt.Errorf("Error deleting snapshot: %s", err)
+	}
+
+	s.snapshot = ""
+	return nil
+}
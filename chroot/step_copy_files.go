@@ -74,12 +74,13 @@ func (s *StepCopyFiles) Run(ctx context.Context, state multistep.StateBag) multi
 	}
 
 	state.Put("copy_files_cleanup", s)
+	RegisterSignalCleanup(state, s)
 	return multistep.ActionContinue
 }
 
 func (s *StepCopyFiles) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packersdk.Ui)
-	if err := s.CleanupFunc(state); err != nil {
+	if err := RunCleanup(state, s); err != nil {
 		ui.Error(err.Error())
 	}
 }
@@ -28,7 +28,7 @@ func (s *StepEarlyCleanup) Run(ctx context.Context, state multistep.StateBag) mu
 	for _, key := range cleanupKeys {
 		c := state.Get(key).(Cleanup)
 		log.Printf("Running cleanup func: %s", key)
-		if err := c.CleanupFunc(state); err != nil {
+		if err := RunCleanup(state, c); err != nil {
 			err := fmt.Errorf("Error cleaning up: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
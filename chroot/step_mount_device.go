@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type mountPathData struct {
+	Device string
+}
+
+// StepMountDevice mounts the attached device.
+//
+// The device to mount is read from the root_device state key when present
+// (see StepProbePartitions), and otherwise built from the device state key and
+// MountPartition.
+//
+// Produces:
+//
+//	mount_path string - The location where the volume was mounted.
+//	mount_device_cleanup CleanupFunc - To perform early cleanup
+type StepMountDevice struct {
+	// MountPath is the path where the device will be mounted. It is
+	// interpolated with {{.Device}} set to the base name of the device.
+	MountPath string
+	// MountOptions are passed to mount, each with its own -o flag.
+	MountOptions []string
+	// MountPartition is the partition number appended to the device. Empty
+	// or "0" mounts the whole device.
+	MountPartition string
+	// RetryConfig controls retrying the mount, for devices that take a while
+	// to become ready. A nil RetryConfig tries only once.
+	RetryConfig *retry.Config
+	// GeneratedData, when set, receives the MountPath so that it can be used
+	// by provisioners and post-processors.
+	GeneratedData *packerbuilderdata.GeneratedData
+
+	mountPath string
+}
+
+func (s *StepMountDevice) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(interpolateContextProvider)
+	ui := state.Get("ui").(packersdk.Ui)
+	device := state.Get("device").(string)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	ictx := config.GetContext()
+	ictx.Data = &mountPathData{Device: filepath.Base(device)}
+	mountPath, err := interpolate.Render(s.MountPath, &ictx)
+	if err != nil {
+		err := fmt.Errorf("Error preparing mount directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	mountPath, err = filepath.Abs(mountPath)
+	if err != nil {
+		err := fmt.Errorf("Error preparing mount directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("Mount path: %s", mountPath)
+
+	if err := os.MkdirAll(mountPath, 0755); err != nil {
+		err := fmt.Errorf("Error creating mount directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	deviceMount := device
+	if rootDevice, ok := state.GetOk("root_device"); ok {
+		deviceMount = rootDevice.(string)
+	} else if s.MountPartition != "" && s.MountPartition != "0" {
+		deviceMount = fmt.Sprintf("%s%s", device, s.MountPartition)
+	}
+	state.Put("deviceMount", deviceMount)
+
+	// build mount options from mount_options config, useful for nouuid options
+	// or other specific device type settings for mount
+	opts := ""
+	if len(s.MountOptions) > 0 {
+		opts = "-o " + strings.Join(s.MountOptions, " -o ")
+	}
+	mountCommand, err := wrappedCommand(
+		fmt.Sprintf("mount %s %s %s", opts, deviceMount, mountPath))
+	if err != nil {
+		err := fmt.Errorf("Error creating mount command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	log.Printf("[DEBUG] (step mount) mount command is %s", mountCommand)
+
+	ui.Say("Mounting the root device...")
+	mount := func(context.Context) error {
+		stderr := new(bytes.Buffer)
+		cmd := common.ShellCommand(mountCommand)
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf(
+				"Error mounting root volume: %s\nStderr: %s", err, stderr.String())
+		}
+		return nil
+	}
+	if s.RetryConfig != nil {
+		err = s.RetryConfig.Run(ctx, mount)
+	} else {
+		err = mount(ctx)
+	}
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Set the mount path so we remember to unmount it later
+	s.mountPath = mountPath
+	state.Put("mount_path", s.mountPath)
+	if s.GeneratedData != nil {
+		s.GeneratedData.Put("MountPath", s.mountPath)
+	}
+	state.Put("mount_device_cleanup", s)
+	RegisterSignalCleanup(state, s)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepMountDevice) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := RunCleanup(state, s); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepMountDevice) CleanupFunc(state multistep.StateBag) error {
+	if s.mountPath == "" {
+		return nil
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	ui.Say("Unmounting the root device...")
+	unmountCommand, err := wrappedCommand(fmt.Sprintf("umount %s", s.mountPath))
+	if err != nil {
+		return fmt.Errorf("Error creating unmount command: %s", err)
+	}
+
+	stderr := new(bytes.Buffer)
+	cmd := common.ShellCommand(unmountCommand)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf(
+			"Error unmounting root device: %s\nStderr: %s", err, stderr.String())
+	}
+
+	s.mountPath = ""
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type testInterpolateContextProvider struct{}
+
+func (testInterpolateContextProvider) GetContext() interpolate.Context {
+	return interpolate.Context{}
+}
+
+func TestMountDeviceCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepMountDevice)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestMountDevice_Run(t *testing.T) {
+	tests := []struct {
+		name           string
+		mountPartition string
+		rootDevice     string
+		expected       string
+	}{
+		{"whole device", "", "", "/dev/xvdf"},
+		{"partition zero", "0", "", "/dev/xvdf"},
+		{"partition", "1", "", "/dev/xvdf1"},
+		{"probed root device", "1", "/dev/mapper/loop0p3", "/dev/mapper/loop0p3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mountPath := filepath.Join(t.TempDir(), "{{.Device}}")
+			step := &StepMountDevice{
+				MountPath:      mountPath,
+				MountOptions:   []string{"nouuid"},
+				MountPartition: tt.mountPartition,
+			}
+
+			var gotCommand string
+			var wrapper common.CommandWrapper = func(ran string) (string, error) {
+				gotCommand = ran
+				return "true", nil
+			}
+
+			ui, getErrs := testUI()
+			state := new(multistep.BasicStateBag)
+			state.Put("config", testInterpolateContextProvider{})
+			state.Put("ui", ui)
+			state.Put("device", "/dev/xvdf")
+			state.Put("wrappedCommand", wrapper)
+			if tt.rootDevice != "" {
+				state.Put("root_device", tt.rootDevice)
+			}
+
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("unexpected action %v: %s", action, getErrs())
+			}
+
+			expectedPath := filepath.Join(filepath.Dir(mountPath), "xvdf")
+			expectedCommand := fmt.Sprintf("mount -o nouuid %s %s", tt.expected, expectedPath)
+			if gotCommand != expectedCommand {
+				t.Fatalf("expected command %q, got %q", expectedCommand, gotCommand)
+			}
+			if got := state.Get("mount_path").(string); got != expectedPath {
+				t.Fatalf("expected mount_path %q, got %q", expectedPath, got)
+			}
+
+			if err := step.CleanupFunc(state); err != nil {
+				t.Fatalf("unexpected cleanup error: %s", err)
+			}
+			if gotCommand != "umount "+expectedPath {
+				t.Fatalf("unexpected cleanup command %q", gotCommand)
+			}
+		})
+	}
+}
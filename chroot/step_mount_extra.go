@@ -76,13 +76,14 @@ func (s *StepMountExtra) Run(ctx context.Context, state multistep.StateBag) mult
 	}
 
 	state.Put("mount_extra_cleanup", s)
+	RegisterSignalCleanup(state, s)
 	return multistep.ActionContinue
 }
 
 func (s *StepMountExtra) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packersdk.Ui)
 
-	if err := s.CleanupFunc(state); err != nil {
+	if err := RunCleanup(state, s); err != nil {
 		ui.Error(err.Error())
 		return
 	}
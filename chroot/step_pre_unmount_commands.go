@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type preUnmountCommandsData struct {
+	Device    string
+	MountPath string
+}
+
+// StepPreUnmountCommands allows running arbitrary commands after provisioning
+// has finished, but right before the filesystems are unmounted. This is useful
+// for things like trimming or zero-filling free space, or regenerating an
+// initramfs. It should be placed before StepEarlyCleanup in the step sequence.
+type StepPreUnmountCommands struct {
+	Commands []string
+}
+
+func (s *StepPreUnmountCommands) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(interpolateContextProvider)
+	device := state.Get("device").(string)
+	mountPath := state.Get("mount_path").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	if len(s.Commands) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ictx := config.GetContext()
+	ictx.Data = &preUnmountCommandsData{
+		Device:    device,
+		MountPath: mountPath,
+	}
+
+	ui.Say("Running pre-unmount commands...")
+	if err := RunLocalCommands(s.Commands, wrappedCommand, ictx, ui); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepPreUnmountCommands) Cleanup(state multistep.StateBag) {}
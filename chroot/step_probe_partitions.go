@@ -0,0 +1,291 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// partition describes a single partition found on an attached block device.
+type partition struct {
+	Device string
+	Label  string
+	UUID   string
+	FSType string
+}
+
+// Number returns the partition number, taken from the trailing digits of the
+// device path, or an empty string if there are none.
+func (p partition) Number() string {
+	return partitionNumberRe.FindString(p.Device)
+}
+
+var (
+	partitionNumberRe = regexp.MustCompile(`[0-9]+$`)
+	kpartxMapRe       = regexp.MustCompile(`^add map (\S+) `)
+)
+
+// StepProbePartitions inspects the attached block device for partitions and
+// selects the one holding the root filesystem. When the kernel has not created
+// partition devices (for example on loop devices attached without partition
+// scanning), device-mapper entries are created with kpartx.
+//
+// The root partition is selected, in order of preference, by MountPartition,
+// RootLabel, RootUUID, being the only partition on the device, or by being
+// the first partition containing an /etc/fstab. A device without any
+// partitions is used as is.
+//
+// Produces:
+//
+//	root_device string - The device path of the root filesystem
+//	partitions []string - The device paths of all partitions found
+//	probe_partitions_cleanup CleanupFunc - To remove device-mapper entries early
+type StepProbePartitions struct {
+	// The partition number to use as the root partition. "0" uses the whole
+	// device and skips probing entirely.
+	MountPartition string
+	// The filesystem label of the root partition.
+	RootLabel string
+	// The filesystem UUID of the root partition.
+	RootUUID string
+
+	mappedDevice string
+}
+
+func (s *StepProbePartitions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	device := state.Get("device").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	state.Put("probe_partitions_cleanup", s)
+
+	if s.MountPartition == "0" {
+		state.Put("root_device", device)
+		state.Put("partitions", []string{})
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Probing device for partitions...")
+	partitions, err := s.probe(device, wrappedCommand)
+	RegisterSignalCleanup(state, s)
+	if err != nil {
+		err := fmt.Errorf("Error probing partitions: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	names := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		names = append(names, p.Device)
+	}
+	state.Put("partitions", names)
+
+	rootDevice := device
+	if len(partitions) > 0 {
+		root, err := s.selectRoot(partitions, func(p partition) bool {
+			return hasFstab(p, wrappedCommand)
+		})
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		rootDevice = root.Device
+	}
+
+	ui.Message(fmt.Sprintf("Root device: %s", rootDevice))
+	state.Put("root_device", rootDevice)
+	return multistep.ActionContinue
+}
+
+func (s *StepProbePartitions) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := RunCleanup(state, s); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepProbePartitions) CleanupFunc(state multistep.StateBag) error {
+	if s.mappedDevice == "" {
+		return nil
+	}
+
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+	log.Printf("Removing device-mapper entries for %s", s.mappedDevice)
+	if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("kpartx -d %s", s.mappedDevice)); err != nil {
+		return fmt.Errorf("Error removing partition mappings: %s", err)
+	}
+
+	s.mappedDevice = ""
+	return nil
+}
+
+// probe lists the partitions of device, creating device-mapper entries for
+// them if the kernel did not.
+func (s *StepProbePartitions) probe(device string, wrappedCommand common.CommandWrapper) ([]partition, error) {
+	out, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("lsblk -lnpo NAME,TYPE %s", device))
+	if err != nil {
+		return nil, err
+	}
+	devices := parseLsblkPartitions(out)
+
+	if len(devices) == 0 {
+		// partx exits non-zero when there is no partition table at all, in
+		// which case the device holds a bare filesystem.
+		out, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("partx -g -o NR %s", device))
+		if err != nil || strings.TrimSpace(out) == "" {
+			log.Printf("No partition table found on %s", device)
+			return nil, nil
+		}
+
+		log.Printf("Creating device-mapper entries for %s", device)
+		out, err = runWrappedCommand(wrappedCommand, fmt.Sprintf("kpartx -avs %s", device))
+		if err != nil {
+			return nil, err
+		}
+		s.mappedDevice = device
+		devices = parseKpartxMappings(out)
+	}
+
+	partitions := make([]partition, 0, len(devices))
+	for _, dev := range devices {
+		// blkid exits non-zero for partitions without a recognizable
+		// filesystem, which are still worth listing.
+		out, _ := runWrappedCommand(wrappedCommand, fmt.Sprintf("blkid -o export %s", dev))
+		p := parseBlkidExport(out)
+		p.Device = dev
+		partitions = append(partitions, p)
+	}
+	return partitions, nil
+}
+
+// selectRoot picks the root partition. isRoot is only consulted when none of
+// the configured selectors apply and the device has several partitions.
+func (s *StepProbePartitions) selectRoot(partitions []partition, isRoot func(partition) bool) (partition, error) {
+	switch {
+	case s.MountPartition != "":
+		for _, p := range partitions {
+			if p.Number() == s.MountPartition {
+				return p, nil
+			}
+		}
+		return partition{}, fmt.Errorf("Partition %s not found on device", s.MountPartition)
+	case s.RootLabel != "":
+		for _, p := range partitions {
+			if p.Label == s.RootLabel {
+				return p, nil
+			}
+		}
+		return partition{}, fmt.Errorf("No partition with label %q found on device", s.RootLabel)
+	case s.RootUUID != "":
+		for _, p := range partitions {
+			if strings.EqualFold(p.UUID, s.RootUUID) {
+				return p, nil
+			}
+		}
+		return partition{}, fmt.Errorf("No partition with UUID %q found on device", s.RootUUID)
+	}
+
+	if len(partitions) == 1 {
+		return partitions[0], nil
+	}
+
+	for _, p := range partitions {
+		if p.FSType == "" || p.FSType == "swap" {
+			continue
+		}
+		if isRoot(p) {
+			return p, nil
+		}
+	}
+	return partition{}, fmt.Errorf(
+		"Unable to identify the root partition among %d partitions; "+
+			"set the partition, label or UUID explicitly", len(partitions))
+}
+
+// hasFstab mounts the partition read-only in a temporary directory and
+// reports whether it contains an /etc/fstab.
+func hasFstab(p partition, wrappedCommand common.CommandWrapper) bool {
+	dir, err := tmp.Dir("packer-chroot-probe")
+	if err != nil {
+		log.Printf("Error creating probe directory: %s", err)
+		return false
+	}
+	defer os.Remove(dir)
+
+	if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("mount -o ro %s %s", p.Device, dir)); err != nil {
+		log.Printf("Unable to mount %s for probing: %s", p.Device, err)
+		return false
+	}
+	defer func() {
+		if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("umount %s", dir)); err != nil {
+			log.Printf("Error unmounting probe directory: %s", err)
+		}
+	}()
+
+	_, err = os.Stat(filepath.Join(dir, "etc", "fstab"))
+	return err == nil
+}
+
+// parseLsblkPartitions returns the partition device paths from the output of
+// `lsblk -lnpo NAME,TYPE`.
+func parseLsblkPartitions(out string) []string {
+	var devices []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == "part" {
+			devices = append(devices, fields[0])
+		}
+	}
+	return devices
+}
+
+// parseKpartxMappings returns the device-mapper paths from the output of
+// `kpartx -av`.
+func parseKpartxMappings(out string) []string {
+	var devices []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if m := kpartxMapRe.FindStringSubmatch(scanner.Text()); m != nil {
+			devices = append(devices, "/dev/mapper/"+m[1])
+		}
+	}
+	return devices
+}
+
+// parseBlkidExport reads the label, UUID and filesystem type from the output
+// of `blkid -o export`.
+func parseBlkidExport(out string) partition {
+	var p partition
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "LABEL":
+			p.Label = kv[1]
+		case "UUID":
+			p.UUID = kv[1]
+		case "TYPE":
+			p.FSType = kv[1]
+		}
+	}
+	return p
+}
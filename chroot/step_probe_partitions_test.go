@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProbePartitionsCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepProbePartitions)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestParseLsblkPartitions(t *testing.T) {
+	out := "/dev/xvdf disk\n/dev/xvdf1 part\n/dev/xvdf14 part\n/dev/mapper/vg-root lvm\n"
+	expected := []string{"/dev/xvdf1", "/dev/xvdf14"}
+	if got := parseLsblkPartitions(out); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseKpartxMappings(t *testing.T) {
+	out := "add map loop0p1 (253:0): 0 204800 linear 7:0 2048\n" +
+		"add map loop0p2 (253:1): 0 8181760 linear 7:0 206848\n"
+	expected := []string{"/dev/mapper/loop0p1", "/dev/mapper/loop0p2"}
+	if got := parseKpartxMappings(out); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseBlkidExport(t *testing.T) {
+	out := "DEVNAME=/dev/xvdf1\nLABEL=cloudimg-rootfs\nUUID=0f1e2d3c\nTYPE=ext4\n"
+	expected := partition{Label: "cloudimg-rootfs", UUID: "0f1e2d3c", FSType: "ext4"}
+	if got := parseBlkidExport(out); got != expected {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+}
+
+func TestProbePartitions_selectRoot(t *testing.T) {
+	partitions := []partition{
+		{Device: "/dev/xvdf1", Label: "EFI", UUID: "AAAA-BBBB", FSType: "vfat"},
+		{Device: "/dev/xvdf2", FSType: "swap"},
+		{Device: "/dev/xvdf3", Label: "root", UUID: "0f1e2d3c", FSType: "xfs"},
+	}
+	fstabOn := func(device string) func(partition) bool {
+		return func(p partition) bool { return p.Device == device }
+	}
+
+	tests := []struct {
+		name     string
+		step     StepProbePartitions
+		isRoot   func(partition) bool
+		expected string
+		wantErr  bool
+	}{
+		{"partition", StepProbePartitions{MountPartition: "3"}, nil, "/dev/xvdf3", false},
+		{"missing partition", StepProbePartitions{MountPartition: "4"}, nil, "", true},
+		{"label", StepProbePartitions{RootLabel: "EFI"}, nil, "/dev/xvdf1", false},
+		{"uuid", StepProbePartitions{RootUUID: "0F1E2D3C"}, nil, "/dev/xvdf3", false},
+		{"fstab", StepProbePartitions{}, fstabOn("/dev/xvdf3"), "/dev/xvdf3", false},
+		{"no fstab", StepProbePartitions{}, fstabOn("/dev/xvdf2"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.step.selectRoot(partitions, tt.isRoot)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if got.Device != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got.Device)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// StepZFSClone snapshots a source ZFS dataset, clones the snapshot to a new
+// dataset mounted at MountPath, and uses it as the chroot instead of
+// attaching and mounting a device.
+//
+// When the build succeeds and Promote is set, the clone is promoted so that
+// it no longer depends on the source dataset. Otherwise the clone and the
+// snapshot are destroyed.
+//
+// Produces:
+//
+//	mount_path string - The mount point of the clone.
+type StepZFSClone struct {
+	// Source is the name of the dataset to clone, e.g. "tank/images/base".
+	Source string
+	// Clone is the name of the dataset to create, e.g. "tank/images/build".
+	Clone string
+	// MountPath is where the clone will be mounted.
+	MountPath string
+	// Promote promotes the clone after a successful build instead of
+	// destroying it.
+	Promote bool
+
+	snapshot string
+	clone    string
+}
+
+func (s *StepZFSClone) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	snapshot := fmt.Sprintf("%s@packer-%s", s.Source, uuid.TimeOrderedUUID())
+	ui.Say(fmt.Sprintf("Creating ZFS snapshot %s...", snapshot))
+	if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("zfs snapshot %s", snapshot)); err != nil {
+		err := fmt.Errorf("Error creating snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.snapshot = snapshot
+	RegisterSignalCleanup(state, s)
+
+	ui.Say(fmt.Sprintf("Cloning snapshot to %s...", s.Clone))
+	if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf(
+		"zfs clone -o mountpoint=%s %s %s", s.MountPath, snapshot, s.Clone)); err != nil {
+		err := fmt.Errorf("Error cloning snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.clone = s.Clone
+
+	state.Put("mount_path", s.MountPath)
+	return multistep.ActionContinue
+}
+
+func (s *StepZFSClone) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if s.Promote && s.clone != "" && !cancelled && !halted {
+		if err := s.promote(state); err != nil {
+			ui.Error(err.Error())
+			return
+		}
+	}
+
+	if err := RunCleanup(state, s); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+// promote makes the clone independent of the source dataset. The snapshot
+// moves to the clone in the process and is kept.
+func (s *StepZFSClone) promote(state multistep.StateBag) error {
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say(fmt.Sprintf("Promoting ZFS clone %s...", s.clone))
+	if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("zfs promote %s", s.clone)); err != nil {
+		return fmt.Errorf("Error promoting clone: %s", err)
+	}
+
+	s.clone = ""
+	s.snapshot = ""
+	return nil
+}
+
+// CleanupFunc destroys the clone and the snapshot it was created from.
+func (s *StepZFSClone) CleanupFunc(state multistep.StateBag) error {
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	if s.clone != "" {
+		log.Printf("Destroying ZFS clone: %s", s.clone)
+		if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("zfs destroy %s", s.clone)); err != nil {
+			return fmt.Errorf("Error destroying clone: %s", err)
+		}
+		s.clone = ""
+	}
+
+	if s.snapshot != "" {
+		log.Printf("Destroying ZFS snapshot: %s", s.snapshot)
+		if _, err := runWrappedCommand(wrappedCommand, fmt.Sprintf("zfs destroy %s", s.snapshot)); err != nil {
+			return fmt.Errorf("Error destroying snapshot: %s", err)
+		}
+		s.snapshot = ""
+	}
+	return nil
+}
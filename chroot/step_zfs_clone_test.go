@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestZFSCloneCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepZFSClone)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestZFSClone(t *testing.T) {
+	tests := []struct {
+		name     string
+		promote  bool
+		halted   bool
+		expected []string
+	}{
+		{
+			name: "destroy",
+			expected: []string{
+				`^zfs destroy tank/build$`,
+				`^zfs destroy tank/base@packer-\S+$`,
+			},
+		},
+		{
+			name:     "promote",
+			promote:  true,
+			expected: []string{`^zfs promote tank/build$`},
+		},
+		{
+			name:    "halted",
+			promote: true,
+			halted:  true,
+			expected: []string{
+				`^zfs destroy tank/build$`,
+				`^zfs destroy tank/base@packer-\S+$`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := &StepZFSClone{
+				Source:    "tank/base",
+				Clone:     "tank/build",
+				MountPath: "/mnt/build",
+				Promote:   tt.promote,
+			}
+
+			var commands []string
+			var wrapper common.CommandWrapper = func(ran string) (string, error) {
+				commands = append(commands, ran)
+				return "true", nil
+			}
+
+			ui, getErrs := testUI()
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", ui)
+			state.Put("wrappedCommand", wrapper)
+
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("unexpected action %v: %s", action, getErrs())
+			}
+			if got := state.Get("mount_path").(string); got != "/mnt/build" {
+				t.Fatalf("unexpected mount_path %q", got)
+			}
+			if len(commands) != 2 {
+				t.Fatalf("expected snapshot and clone commands, got %v", commands)
+			}
+			if !regexp.MustCompile(`^zfs clone -o mountpoint=/mnt/build tank/base@packer-\S+ tank/build$`).MatchString(commands[1]) {
+				t.Fatalf("unexpected clone command %q", commands[1])
+			}
+
+			if tt.halted {
+				state.Put(multistep.StateHalted, true)
+			}
+			commands = nil
+			step.Cleanup(state)
+			if errs := getErrs(); errs != "" {
+				t.Fatalf("unexpected cleanup errors: %s", errs)
+			}
+			if len(commands) != len(tt.expected) {
+				t.Fatalf("expected %d cleanup commands, got %v", len(tt.expected), commands)
+			}
+			for i, expr := range tt.expected {
+				if !regexp.MustCompile(expr).MatchString(commands[i]) {
+					t.Fatalf("expected command matching %q, got %q", expr, commands[i])
+				}
+			}
+		})
+	}
+}
@@ -2,29 +2,39 @@
 // SPDX-License-Identifier: MPL-2.0
 
 //go:generate packer-sdc struct-markdown
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,SSH,WinRM,SSHTemporaryKeyPair
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,SSH,WinRM,SSHTemporaryKeyPair,SSHJumpHost
 
 package communicator
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	packerssh "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	sdkwinrm "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/winrm"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/masterzen/winrm"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/http/httpproxy"
 )
 
 // Config is the common configuration a builder uses to define and configure a Packer
@@ -44,7 +54,8 @@ type Config struct {
 	// In addition to the above, some builders have custom communicators they
 	// can use. For example, the Docker builder has a "docker" communicator
 	// that uses `docker exec` and `docker cp` to execute scripts and copy
-	// files.
+	// files. Plugins can also provide communicators of their own, which
+	// are selected by the name they are registered with.
 	Type string `mapstructure:"communicator"`
 	// We recommend that you enable SSH or WinRM as the very last step in your
 	// guest's bootstrap script, but sometimes you may have a race condition
@@ -66,7 +77,9 @@ type Config struct {
 // The SSH config defines configuration for the SSH communicator.
 type SSH struct {
 	// The address to SSH to. This usually is automatically configured by the
-	// builder.
+	// builder. IPv6 addresses may be given with or without brackets, and host
+	// names resolving to both IPv4 and IPv6 addresses are connected to over
+	// whichever answers first.
 	SSHHost string `mapstructure:"ssh_host"`
 	// The port to connect to SSH. This defaults to `22`.
 	SSHPort int `mapstructure:"ssh_port"`
@@ -74,6 +87,26 @@ type SSH struct {
 	SSHUsername string `mapstructure:"ssh_username"`
 	// A plaintext password to use to authenticate with SSH.
 	SSHPassword string `mapstructure:"ssh_password"`
+	// Answers to the questions asked by servers using keyboard-interactive
+	// authentication, such as appliances or servers requiring a second
+	// factor. Each question gets the answer of the first block whose
+	// `prompt` regular expression matches it. When
+	// [`ssh_password`](#ssh_password) is set, it answers the questions
+	// starting with "password" that no block matches.
+	//
+	// ```hcl
+	//   ssh_keyboard_interactive_answer {
+	//     prompt = "(?i)^verification code"
+	//     answer = "123456"
+	//   }
+	// ```
+	SSHKeyboardInteractiveAnswers []SSHKeyboardInteractiveAnswer `mapstructure:"ssh_keyboard_interactive_answer"`
+	// If `true`, keyboard-interactive questions that no
+	// [`ssh_keyboard_interactive_answer`](#ssh_keyboard_interactive_answer)
+	// matches are asked to the user running Packer, for example to enter a
+	// one-time password. This requires Packer to run in a terminal, and the
+	// answer is echoed as it is typed. Defaults to `false`.
+	SSHKeyboardInteractivePrompt bool `mapstructure:"ssh_keyboard_interactive_prompt"`
 	// If specified, this is the key that will be used for SSH with the
 	// machine. The key must match a key pair name loaded up into the remote.
 	// By default, this is blank, and Packer will generate a temporary keypair
@@ -112,17 +145,80 @@ type SSH struct {
 	// "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
 	// "diffie-hellman-group14-sha1", and "diffie-hellman-group1-sha1".
 	SSHKEXAlgos []string `mapstructure:"ssh_key_exchange_algorithms"`
+	// If set, Packer will override the value of MAC algorithms supported by
+	// default by Golang. Acceptable values include:
+	// "hmac-sha2-256-etm@openssh.com", "hmac-sha2-256", "hmac-sha1", and
+	// "hmac-sha1-96".
+	SSHMACs []string `mapstructure:"ssh_mac_algorithms"`
+	// If set, Packer will override the list of host key algorithms it accepts
+	// from the server. Acceptable values include: "ssh-ed25519",
+	// "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521",
+	// "rsa-sha2-512", "rsa-sha2-256", "ssh-rsa", "ssh-dss", and their
+	// certificate variants.
+	SSHHostKeyAlgos []string `mapstructure:"ssh_host_key_algorithms"`
+	// A set of algorithms to offer to the server, for servers that don't
+	// accept the defaults. Algorithms set explicitly with
+	// [`ssh_ciphers`](#ssh_ciphers),
+	// [`ssh_key_exchange_algorithms`](#ssh_key_exchange_algorithms),
+	// [`ssh_mac_algorithms`](#ssh_mac_algorithms) and
+	// [`ssh_host_key_algorithms`](#ssh_host_key_algorithms) take precedence.
+	// Acceptable values are:
+	//
+	// - `default` - The defaults of Golang. This is the default.
+	// - `fips` - Only algorithms approved by FIPS 140-2, for servers running
+	//   in FIPS mode.
+	// - `legacy` - The defaults, followed by older algorithms such as
+	//   `aes128-cbc`, `diffie-hellman-group1-sha1` and `ssh-dss`, for old
+	//   servers and network equipment.
+	SSHAlgorithmPreset string `mapstructure:"ssh_algorithm_preset"`
+	// How the host key of the machine is verified. Acceptable values are:
+	//
+	// - `off` - The host key is not verified. This is the default.
+	// - `known_hosts` - The host key must be listed in
+	//   [`ssh_known_hosts_file`](#ssh_known_hosts_file).
+	// - `fingerprint` - The host key must match
+	//   [`ssh_host_key_fingerprint`](#ssh_host_key_fingerprint).
+	// - `accept-new` - The host key offered on the first connection is
+	//   trusted, and later connections must offer the same key. The learned
+	//   key is recorded in the `ssh_host_key` state entry, in
+	//   `authorized_keys` format, for builders to surface in their artifacts.
+	SSHHostKeyChecking string `mapstructure:"ssh_host_key_checking"`
+	// Path to the known_hosts file used when
+	// [`ssh_host_key_checking`](#ssh_host_key_checking) is `known_hosts`.
+	// The `~` can be used in path and will be expanded to the home directory
+	// of current user. Defaults to `~/.ssh/known_hosts`.
+	SSHKnownHostsFile string `mapstructure:"ssh_known_hosts_file"`
+	// The SHA256 fingerprint of the host key, as printed by `ssh-keygen -l`,
+	// for example `SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8`. Setting
+	// it implies `ssh_host_key_checking = "fingerprint"`.
+	SSHHostKeyFingerprint string `mapstructure:"ssh_host_key_fingerprint"`
 	// Path to a PEM encoded private key file to use to authenticate with SSH.
 	// The `~` can be used in path and will be expanded to the home directory
-	// of current user.
+	// of current user. FIDO2 security keys (`ed25519-sk` and `ecdsa-sk`) are
+	// supported as long as the key is loaded in the SSH agent, which then
+	// handles touch and PIN prompts.
 	SSHPrivateKeyFile string `mapstructure:"ssh_private_key_file" undocumented:"true"`
 	// Path to user certificate used to authenticate with SSH.
 	// The `~` can be used in path and will be expanded to the
-	// home directory of current user.
+	// home directory of current user. The certificate is used with the
+	// private key it was issued for, which can be
+	// [`ssh_private_key_file`](#ssh_private_key_file), the temporary key pair
+	// generated by the builder or, with
+	// [`ssh_agent_auth`](#ssh_agent_auth), a key held by the SSH agent.
+	// Certificates already loaded in the SSH agent are used without setting
+	// this option.
 	SSHCertificateFile string `mapstructure:"ssh_certificate_file"`
 	// If `true`, a PTY will be requested for the SSH connection. This defaults
-	// to `false`.
+	// to `false`. Provisioners may request a PTY, or run without one, for
+	// specific commands regardless of this setting.
 	SSHPty bool `mapstructure:"ssh_pty"`
+	// The terminal type of the PTY, set as `TERM` for the remote commands.
+	// This defaults to `xterm`.
+	SSHPtyTerm string `mapstructure:"ssh_pty_term"`
+	// The width of the PTY, in characters. This defaults to `80`.
+	SSHPtyWidth int `mapstructure:"ssh_pty_width"`
+	// The height of the PTY, in characters. This defaults to `40`.
+	SSHPtyHeight int `mapstructure:"ssh_pty_height"`
 	// The time to wait for SSH to become available. Packer uses this to
 	// determine when the machine has booted so this is usually quite long.
 	// Example value: `10m`.
@@ -140,7 +236,10 @@ type SSH struct {
 	// If true, SSH agent forwarding will be disabled. Defaults to `false`.
 	SSHDisableAgentForwarding bool `mapstructure:"ssh_disable_agent_forwarding"`
 	// The number of handshakes to attempt with SSH once it can connect.
-	// This defaults to `10`, unless a `ssh_timeout` is set.
+	// Only failures that are unlikely to go away by themselves, such as
+	// authentication failures, count as attempts: network errors and
+	// timeouts are retried until [`ssh_timeout`](#ssh_timeout). This
+	// defaults to `10`.
 	SSHHandshakeAttempts int `mapstructure:"ssh_handshake_attempts"`
 	// A bastion host to use for the actual SSH connection.
 	SSHBastionHost string `mapstructure:"ssh_bastion_host"`
@@ -163,6 +262,38 @@ type SSH struct {
 	// The `~` can be used in path and will be expanded to the
 	//home directory of current user.
 	SSHBastionCertificateFile string `mapstructure:"ssh_bastion_certificate_file"`
+	// A chain of jump hosts to go through to reach the machine, equivalent to
+	// OpenSSH's `ProxyJump host1,host2`. Each host is reached through the
+	// previous one, and has its own authentication settings. If
+	// [`ssh_bastion_host`](#ssh_bastion_host) is also set, it is used as the
+	// first hop of the chain.
+	//
+	// HCL2 example:
+	//
+	// ```hcl
+	//   ssh_jump_host {
+	//     host             = "bastion.example.com"
+	//     username         = "jump"
+	//     private_key_file = "~/.ssh/bastion"
+	//   }
+	//   ssh_jump_host {
+	//     host       = "10.1.0.4"
+	//     username   = "jump"
+	//     agent_auth = true
+	//   }
+	// ```
+	SSHJumpHosts []SSHJumpHost `mapstructure:"ssh_jump_host"`
+	// Path to an OpenSSH client configuration file, usually `~/.ssh/config`,
+	// to read settings for the host from, so that the configuration operators
+	// already use with `ssh` applies to Packer as well. The `HostName`,
+	// `User`, `Port`, `IdentityFile` and `ProxyJump` keywords of the `Host`
+	// blocks matching [`ssh_host`](#ssh_host) are used, along with `Include`
+	// directives. Settings of the template take precedence: `User` is only
+	// used when [`ssh_username`](#ssh_username) is not set, `Port` when
+	// [`ssh_port`](#ssh_port) is not set, `IdentityFile` when no private key
+	// is configured, and `ProxyJump` when no bastion, jump or proxy host is
+	// configured.
+	SSHConfigFile string `mapstructure:"ssh_config_file"`
 	// `scp` or `sftp` - How to transfer files, Secure copy (default) or SSH
 	// File Transfer Protocol.
 	//
@@ -171,9 +302,54 @@ type SSH struct {
 	// cannot be set, which cause any file transfer to fail. As a workaround you can override the transfer protocol
 	// with SFTP instead `ssh_file_transfer_protocol = "sftp"`.
 	SSHFileTransferMethod string `mapstructure:"ssh_file_transfer_method"`
-	// A SOCKS proxy host to use for SSH connection
+	// The size in bytes of the data packets used by SFTP transfers. Defaults
+	// to `32768`, the largest size all servers must accept; OpenSSH servers
+	// accept up to `262144`, which can speed up large transfers.
+	SSHSFTPMaxPacketSize int `mapstructure:"ssh_sftp_max_packet_size"`
+	// The number of SFTP requests in flight for a single file. Values above
+	// `1` write files concurrently, which greatly speeds up uploads over high
+	// latency links. Defaults to `1`.
+	SSHSFTPConcurrency int `mapstructure:"ssh_sftp_concurrency"`
+	// If `true`, SFTP uploads of files that were partially uploaded before,
+	// for example by a build that was interrupted, resume where they stopped
+	// instead of starting over. The partial file is read back, and the upload
+	// only resumes when it matches the start of the local file. Concurrent
+	// writes can leave gaps in interrupted uploads, so this can't be used
+	// along with [`ssh_sftp_concurrency`](#ssh_sftp_concurrency). Defaults to
+	// `false`.
+	SSHSFTPResume bool `mapstructure:"ssh_sftp_resume"`
+	// If `true`, directories are uploaded and downloaded with rsync, which
+	// only transfers the differences with the destination. This requires
+	// rsync on both the local and the remote host, and isn't supported on
+	// Windows hosts; the transfer falls back to
+	// [`ssh_file_transfer_method`](#ssh_file_transfer_method) when rsync is
+	// unavailable. Defaults to `false`.
+	SSHRsync bool `mapstructure:"ssh_rsync"`
+	// If `true`, files in the destination directory that aren't in the
+	// source directory are deleted by rsync transfers. Defaults to `false`.
+	SSHRsyncDelete bool `mapstructure:"ssh_rsync_delete"`
+	// Limits file uploads and downloads to this many bytes per second, for
+	// builds running over constrained or shared links. The limit is shared
+	// by all the transfers of the communicator. Defaults to `0`, which
+	// doesn't limit transfers.
+	SSHTransferRateLimit int64 `mapstructure:"ssh_transfer_rate_limit"`
+	// How symbolic links are handled when uploading directories. Acceptable
+	// values are:
+	//
+	// - `follow` - The file or directory a link points to is uploaded, and
+	//   links whose target doesn't exist are skipped. This is the default.
+	// - `preserve` - Links are uploaded as links. This requires the `sftp`
+	//   [`ssh_file_transfer_method`](#ssh_file_transfer_method) or
+	//   [`ssh_rsync`](#ssh_rsync); scp follows links instead.
+	// - `skip` - Links are left out of the upload.
+	SSHSymlinkPolicy string `mapstructure:"ssh_symlink_policy"`
+	// A proxy host to use for SSH connection
 	SSHProxyHost string `mapstructure:"ssh_proxy_host"`
-	// A port of the SOCKS proxy. Defaults to `1080`.
+	// The type of the proxy, either `socks5` (default) or `http`. HTTP
+	// proxies must allow the `CONNECT` method to the SSH port.
+	SSHProxyType string `mapstructure:"ssh_proxy_type"`
+	// A port of the proxy. Defaults to `1080` for SOCKS proxies and `8080` for
+	// HTTP proxies.
 	SSHProxyPort int `mapstructure:"ssh_proxy_port"`
 	// The optional username to authenticate with the proxy server.
 	SSHProxyUsername string `mapstructure:"ssh_proxy_username"`
@@ -182,6 +358,19 @@ type SSH struct {
 	// How often to send "keep alive" messages to the server. Set to a negative
 	// value (`-1s`) to disable. Example value: `10s`. Defaults to `5s`.
 	SSHKeepAliveInterval time.Duration `mapstructure:"ssh_keep_alive_interval"`
+	// How many "keep alive" messages can go unanswered before the connection
+	// is considered dead and closed, instead of hanging until the operating
+	// system gives up on it. Defaults to `0`, which never closes the
+	// connection.
+	SSHKeepAliveCountMax int `mapstructure:"ssh_keep_alive_count_max"`
+	// Close the connection when nothing was received from the server for this
+	// long. Replies to "keep alive" messages count as activity, so unless
+	// they are disabled this only happens when the server stopped answering.
+	// Example: `2m`. Disabled by default.
+	SSHInactivityTimeout time.Duration `mapstructure:"ssh_inactivity_timeout"`
+	// The number of bytes after which the connection keys are renegotiated.
+	// Defaults to `0`, which uses a limit based on the negotiated cipher.
+	SSHRekeyThreshold int64 `mapstructure:"ssh_rekey_threshold"`
 	// The amount of time to wait for a remote command to end. This might be
 	// useful if, for example, packer hangs on a connection after a reboot.
 	// Example: `5m`. Disabled by default.
@@ -197,6 +386,13 @@ type SSH struct {
 	// SSH Internals
 	SSHPublicKey  []byte `mapstructure:"ssh_public_key" undocumented:"true"`
 	SSHPrivateKey []byte `mapstructure:"ssh_private_key" undocumented:"true"`
+
+	// sshPortDefaulted is set when ssh_port was not configured, in which
+	// case the port of the ssh_config file applies.
+	sshPortDefaulted bool
+	// sshHostConfig holds the ssh_config settings of the host being
+	// connected to.
+	sshHostConfig *sshHostConfig
 }
 
 // When no ssh credentials are specified, Packer will generate a temporary SSH
@@ -219,13 +415,48 @@ type SSHTemporaryKeyPair struct {
 	SSHTemporaryKeyPairBits int `mapstructure:"temporary_key_pair_bits"`
 }
 
+// SSHJumpHost defines one of the hosts in a chain of SSH jump hosts.
+type SSHJumpHost struct {
+	// The address of the jump host.
+	Host string `mapstructure:"host" required:"true"`
+	// The port of the jump host. Defaults to `22`.
+	Port int `mapstructure:"port"`
+	// The username to connect to the jump host. Defaults to
+	// [`ssh_bastion_username`](#ssh_bastion_username) when set, or to
+	// [`ssh_username`](#ssh_username) otherwise.
+	Username string `mapstructure:"username"`
+	// The password to use to authenticate with the jump host.
+	Password string `mapstructure:"password"`
+	// Path to a PEM encoded private key file to use to authenticate with the
+	// jump host. The `~` can be used in path and will be expanded to the
+	// home directory of current user. Defaults to
+	// [`ssh_private_key_file`](#ssh_private_key_file).
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	// Path to user certificate used to authenticate with the jump host.
+	// The `~` can be used in path and will be expanded to the
+	// home directory of current user.
+	CertificateFile string `mapstructure:"certificate_file"`
+	// If `true`, the local SSH agent will be used to authenticate with the
+	// jump host. Defaults to `false`.
+	AgentAuth bool `mapstructure:"agent_auth"`
+}
+
+// SSHKeyboardInteractiveAnswer answers keyboard-interactive questions.
+type SSHKeyboardInteractiveAnswer struct {
+	// A regular expression matched against the questions.
+	Prompt string `mapstructure:"prompt" required:"true"`
+	// The answer to the matching questions.
+	Answer string `mapstructure:"answer" required:"true"`
+}
+
 // The WinRM config defines configuration for the WinRM communicator.
 type WinRM struct {
 	// The username to use to connect to WinRM.
 	WinRMUser string `mapstructure:"winrm_username"`
 	// The password to use to connect to WinRM.
 	WinRMPassword string `mapstructure:"winrm_password"`
-	// The address for WinRM to connect to.
+	// The address for WinRM to connect to. IPv6 addresses may be given with
+	// or without brackets.
 	//
 	// NOTE: If using an Amazon EBS builder, you can specify the interface
 	// WinRM connects to via
@@ -236,6 +467,23 @@ type WinRM struct {
 	// bypassing any configured proxies when connecting to the remote host.
 	// Default to `false`.
 	WinRMNoProxy bool `mapstructure:"winrm_no_proxy"`
+	// An HTTP proxy to connect to WinRM through, rather than the proxies of
+	// the `HTTP_PROXY`, `HTTPS_PROXY` and `NO_PROXY` environment variables.
+	// HTTPS connections are tunneled through the proxy with `CONNECT`.
+	WinRMProxyHost string `mapstructure:"winrm_proxy_host"`
+	// The port of [`winrm_proxy_host`](#winrm_proxy_host). Defaults to
+	// `8080`.
+	WinRMProxyPort int `mapstructure:"winrm_proxy_port"`
+	// The optional username to authenticate with the proxy, with basic
+	// authentication.
+	WinRMProxyUsername string `mapstructure:"winrm_proxy_username"`
+	// The optional password to authenticate with the proxy.
+	WinRMProxyPassword string `mapstructure:"winrm_proxy_password"`
+	// The hosts reached without [`winrm_proxy_host`](#winrm_proxy_host), in
+	// the format of the `NO_PROXY` environment variable: host names, domain
+	// names such as `.example.com`, IP addresses and CIDR ranges, with an
+	// optional port.
+	WinRMProxyBypass []string `mapstructure:"winrm_proxy_bypass"`
 	// The WinRM port to connect to. This defaults to `5985` for plain
 	// unencrypted connection and `5986` for SSL when `winrm_use_ssl` is set to
 	// true.
@@ -247,12 +495,93 @@ type WinRM struct {
 	WinRMUseSSL bool `mapstructure:"winrm_use_ssl"`
 	// If `true`, do not check server certificate chain and host name.
 	WinRMInsecure bool `mapstructure:"winrm_insecure"`
+	// Limits file uploads and downloads to this many bytes per second, for
+	// builds running over constrained or shared links. The limit is shared
+	// by all the transfers of the communicator. Defaults to `0`, which
+	// doesn't limit transfers.
+	WinRMTransferRateLimit int64 `mapstructure:"winrm_transfer_rate_limit"`
+	// The number of bytes of a file sent in each WinRM request of an
+	// upload. Uploads run a single PowerShell command per file, sending the
+	// file on its standard input. Defaults to `0`, which uses the largest
+	// chunks fitting in the default envelope size of WinRM, of about 83KB.
+	// Larger chunks make fewer requests, but require raising the
+	// `MaxEnvelopeSizekb` setting of WinRM on the guest.
+	WinRMUploadChunkSize int `mapstructure:"winrm_upload_chunk_size"`
+	// If `true`, files are compressed with gzip before being uploaded, and
+	// decompressed on the guest. This speeds up the uploads of compressible
+	// files, such as scripts and logs, over slow links.
+	WinRMUploadCompression bool `mapstructure:"winrm_upload_compression"`
+	// The PowerShell executable running the scripts of the WinRM
+	// communicator, such as the file transfers. Defaults to `powershell.exe`,
+	// Windows PowerShell 5.1. Set it to `pwsh.exe` to use PowerShell 7, or
+	// to the path of another PowerShell executable. It isn't used with
+	// [`winrm_use_psrp`](#winrm_use_psrp).
+	WinRMShell string `mapstructure:"winrm_shell"`
+	// If `true`, the commands are run in a 64-bit process on 64-bit Windows,
+	// even when [`winrm_shell`](#winrm_shell) is a 32-bit PowerShell. 32-bit
+	// processes see the `SysWOW64` folder as `System32`, and the 32-bit view
+	// of the registry, which breaks many installers.
+	WinRMForce64Bit bool `mapstructure:"winrm_force_64bit"`
+	// If `true`, the commands are run as the local `SYSTEM` account, in a
+	// scheduled task, rather than as [`winrm_username`](#winrm_username).
+	// Their output is written to a file, which is streamed back as the
+	// commands run. They always run in a 64-bit process on 64-bit Windows.
+	// Neither option can be used with [`winrm_use_psrp`](#winrm_use_psrp).
+	WinRMRunAsSystem bool `mapstructure:"winrm_run_as_system"`
 	// If `true`, NTLMv2 authentication (with session security) will be used
 	// for WinRM, rather than default (basic authentication), removing the
 	// requirement for basic authentication to be enabled within the target
 	// guest. Further reading for remote connection authentication can be found
 	// [here](https://msdn.microsoft.com/en-us/library/aa384295(v=vs.85).aspx).
-	WinRMUseNTLM            bool `mapstructure:"winrm_use_ntlm"`
+	WinRMUseNTLM bool `mapstructure:"winrm_use_ntlm"`
+	// If `true`, Kerberos authentication (over SPNEGO) will be used for
+	// WinRM, for domain-joined hosts where NTLM is disabled. Credentials are
+	// read from [`winrm_kerberos_keytab`](#winrm_kerberos_keytab) when set,
+	// then from `winrm_password`, and otherwise from the Kerberos credential
+	// cache. Messages aren't encrypted at the WinRM level, so the host must
+	// be reached with `winrm_use_ssl` or allow unencrypted traffic.
+	WinRMUseKerberos bool `mapstructure:"winrm_use_kerberos"`
+	// The Kerberos realm of `winrm_username`. Defaults to the default realm
+	// of the Kerberos configuration.
+	WinRMKerberosRealm string `mapstructure:"winrm_kerberos_realm"`
+	// The path to a keytab holding the keys of `winrm_username`.
+	WinRMKerberosKeytab string `mapstructure:"winrm_kerberos_keytab"`
+	// The path to the Kerberos credential cache used when neither a keytab
+	// nor a password are given, as populated by `kinit`. Defaults to the
+	// `KRB5CCNAME` environment variable, then to the default cache of the
+	// current user. The tickets of the cache are not renewed.
+	WinRMKerberosCCache string `mapstructure:"winrm_kerberos_ccache"`
+	// The path to the Kerberos configuration. Defaults to the `KRB5_CONFIG`
+	// environment variable, then to `/etc/krb5.conf`.
+	WinRMKerberosConfig string `mapstructure:"winrm_kerberos_config"`
+	// The service principal name of the WinRM service. Defaults to
+	// `HTTP/<host>`, where the host is resolved to its canonical name.
+	WinRMKerberosSPN string `mapstructure:"winrm_kerberos_spn"`
+	// If `true`, CredSSP authentication will be used for WinRM. CredSSP
+	// delegates `winrm_username` and `winrm_password` to the guest, so that
+	// the provisioners can reach other hosts of the domain, such as file
+	// shares, as that user. It requires `winrm_use_ssl`, and CredSSP must be
+	// enabled on the guest with `Enable-WSManCredSSP -Role Server`.
+	WinRMUseCredSSP bool `mapstructure:"winrm_use_credssp"`
+	// The path to a PEM encoded client certificate, to authenticate with
+	// the certificate rather than a password. The certificate must be
+	// mapped to a local user of the guest with `New-Item
+	// WSMan:\localhost\ClientCertificate`, and certificate authentication
+	// enabled. It requires `winrm_use_ssl` and
+	// [`winrm_client_key`](#winrm_client_key). `winrm_username` is then
+	// optional, and `winrm_password` isn't used.
+	WinRMClientCert string `mapstructure:"winrm_client_cert"`
+	// The path to the PEM encoded, unencrypted, private key of
+	// [`winrm_client_cert`](#winrm_client_cert).
+	WinRMClientKey string `mapstructure:"winrm_client_key"`
+	// If `true`, commands are run and files transferred over the PowerShell
+	// Remoting Protocol (PSRP), rather than in WinRM command shells. Files
+	// are streamed in large fragments, downloads included, and the output,
+	// error, warning and verbose records of PowerShell are streamed as they
+	// are written. PowerShell remoting must be enabled on the guest, with
+	// `Enable-PSRemoting`. Downloading directories isn't supported.
+	WinRMUsePSRP bool `mapstructure:"winrm_use_psrp"`
+
 	WinRMTransportDecorator func() winrm.Transporter
 }
 
@@ -324,30 +653,26 @@ func (c *Config) ReadSSHPrivateKeyFile() ([]byte, error) {
 // or password.
 func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, error) {
 	return func(state multistep.StateBag) (*ssh.ClientConfig, error) {
+		hostKeyCallback, err := c.sshHostKeyCallback(state)
+		if err != nil {
+			return nil, err
+		}
 		sshConfig := &ssh.ClientConfig{
 			User:            c.SSHUsername,
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		}
-		if len(c.SSHCiphers) != 0 {
-			sshConfig.Config.Ciphers = c.SSHCiphers
-		}
-
-		if len(c.SSHKEXAlgos) != 0 {
-			sshConfig.Config.KeyExchanges = c.SSHKEXAlgos
+			HostKeyCallback: hostKeyCallback,
 		}
+		algos := c.sshAlgorithms()
+		sshConfig.Config.Ciphers = algos.Ciphers
+		sshConfig.Config.KeyExchanges = algos.KeyExchanges
+		sshConfig.Config.MACs = algos.MACs
+		sshConfig.HostKeyAlgorithms = algos.HostKeyAlgorithms
 
 		if c.SSHAgentAuth {
-			authSock := os.Getenv("SSH_AUTH_SOCK")
-			if authSock == "" {
-				return nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
-			}
-
-			sshAgent, err := net.Dial("unix", authSock)
+			agentAuth, err := sshAgentAuth(c.SSHCertificateFile)
 			if err != nil {
-				return nil, fmt.Errorf("Cannot connect to SSH Agent socket %q: %s", authSock, err)
+				return nil, err
 			}
-
-			sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers))
+			sshConfig.Auth = append(sshConfig.Auth, agentAuth)
 		}
 
 		var privateKeys [][]byte
@@ -381,7 +706,17 @@ func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, er
 
 			signer, err := ssh.ParsePrivateKey(key)
 			if err != nil {
-				return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
+				pub, skErr := sshkey.SecurityKeyPublicKey(key)
+				if skErr != nil {
+					return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
+				}
+				// Security keys can only be used through the SSH agent
+				agentAuth, err := sshSecurityKeyAuth(pub, certPath)
+				if err != nil {
+					return nil, err
+				}
+				sshConfig.Auth = append(sshConfig.Auth, agentAuth)
+				continue
 			}
 
 			if certPath != "" {
@@ -394,16 +729,106 @@ func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, er
 			sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signer))
 		}
 
+		// Identity files of the ssh_config file are only used when no key
+		// is configured, and are skipped when they can't be used, like
+		// OpenSSH does.
+		if len(privateKeys) == 0 && c.sshHostConfig != nil {
+			if signers := sshIdentitySigners(c.sshHostConfig.IdentityFiles); len(signers) > 0 {
+				sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signers...))
+			}
+		}
+
 		if c.SSHPassword != "" {
+			sshConfig.Auth = append(sshConfig.Auth, ssh.Password(c.SSHPassword))
+		}
+		// Only the first keyboard-interactive method would be tried, so a
+		// single one answers both the scripted questions and the password.
+		if len(c.SSHKeyboardInteractiveAnswers) > 0 || c.SSHKeyboardInteractivePrompt {
+			challenge, err := c.sshKeyboardInteractive(state)
+			if err != nil {
+				return nil, err
+			}
+			sshConfig.Auth = append(sshConfig.Auth, ssh.KeyboardInteractive(challenge))
+		} else if c.SSHPassword != "" {
 			sshConfig.Auth = append(sshConfig.Auth,
-				ssh.Password(c.SSHPassword),
-				ssh.KeyboardInteractive(packerssh.PasswordKeyboardInteractive(c.SSHPassword)),
-			)
+				ssh.KeyboardInteractive(packerssh.PasswordKeyboardInteractive(c.SSHPassword)))
 		}
 		return sshConfig, nil
 	}
 }
 
+// sshKeyboardInteractive returns the keyboard-interactive challenge answering
+// with ssh_keyboard_interactive_answer, then ssh_password, and then asking
+// the Ui when ssh_keyboard_interactive_prompt is set.
+func (c *Config) sshKeyboardInteractive(state multistep.StateBag) (ssh.KeyboardInteractiveChallenge, error) {
+	answers := make([]packerssh.KeyboardInteractiveAnswer, 0, len(c.SSHKeyboardInteractiveAnswers)+1)
+	for _, a := range c.SSHKeyboardInteractiveAnswers {
+		prompt, err := regexp.Compile(a.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, packerssh.KeyboardInteractiveAnswer{Prompt: prompt, Answer: a.Answer})
+	}
+	if c.SSHPassword != "" {
+		answers = append(answers, packerssh.KeyboardInteractiveAnswer{
+			Prompt: regexp.MustCompile(`(?i)^\s*password`),
+			Answer: c.SSHPassword,
+		})
+	}
+
+	var ask func(string) (string, error)
+	if c.SSHKeyboardInteractivePrompt {
+		ui, ok := state.Get("ui").(packersdk.Ui)
+		if !ok {
+			return nil, errors.New("ssh_keyboard_interactive_prompt requires a Ui")
+		}
+		ask = ui.Ask
+	}
+	return packerssh.ScriptedKeyboardInteractive(answers, ask), nil
+}
+
+// sshHostKeyCallback returns the host key callback matching
+// ssh_host_key_checking.
+func (c *Config) sshHostKeyCallback(state multistep.StateBag) (ssh.HostKeyCallback, error) {
+	switch c.SSHHostKeyChecking {
+	case "known_hosts":
+		path, err := pathing.ExpandUser(c.SSHKnownHostsFile)
+		if err != nil {
+			return nil, err
+		}
+		callback, err := knownhosts.New(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading known_hosts file: %s", err)
+		}
+		return callback, nil
+	case "fingerprint":
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if fp := ssh.FingerprintSHA256(key); fp != c.SSHHostKeyFingerprint {
+				return fmt.Errorf("host key fingerprint mismatch for %s: expected %s, got %s",
+					hostname, c.SSHHostKeyFingerprint, fp)
+			}
+			return nil
+		}, nil
+	case "accept-new":
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			authorizedKey := string(ssh.MarshalAuthorizedKey(key))
+			if known, ok := state.GetOk("ssh_host_key"); ok {
+				if known.(string) != authorizedKey {
+					return fmt.Errorf("host key for %s changed since the first connection: got %s",
+						hostname, ssh.FingerprintSHA256(key))
+				}
+				return nil
+			}
+			log.Printf("[INFO] trusting host key %s for %s on first use",
+				ssh.FingerprintSHA256(key), hostname)
+			state.Put("ssh_host_key", authorizedKey)
+			return nil
+		}, nil
+	default:
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+}
+
 // Port returns the port that will be used for access based on config.
 func (c *Config) Port() int {
 	switch c.Type {
@@ -470,7 +895,9 @@ func (c *Config) Prepare(ctx *interpolate.Context) []error {
 	case "docker", "dockerWindowsContainer", "none":
 		break
 	default:
-		return []error{fmt.Errorf("Communicator type %s is invalid", c.Type)}
+		if _, ok := lookupCustom(c.Type); !ok {
+			return []error{fmt.Errorf("Communicator type %s is invalid", c.Type)}
+		}
 	}
 
 	return errs
@@ -479,11 +906,15 @@ func (c *Config) Prepare(ctx *interpolate.Context) []error {
 func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 	if c.SSHPort == 0 {
 		c.SSHPort = 22
+		c.sshPortDefaulted = true
 	}
 
-	// Only set default values when neither are set
+	// Only wait for a limited time when the number of attempts isn't set
+	// either
 	if c.SSHTimeout == 0 && c.SSHHandshakeAttempts == 0 {
 		c.SSHTimeout = 5 * time.Minute
+	}
+	if c.SSHHandshakeAttempts == 0 {
 		c.SSHHandshakeAttempts = 10
 	}
 
@@ -506,16 +937,81 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 
 	}
 
+	for i := range c.SSHJumpHosts {
+		jh := &c.SSHJumpHosts[i]
+		if jh.Port == 0 {
+			jh.Port = 22
+		}
+
+		if jh.Username == "" {
+			jh.Username = c.SSHBastionUsername
+		}
+		if jh.Username == "" {
+			jh.Username = c.SSHUsername
+		}
+
+		if jh.PrivateKeyFile == "" && c.SSHPrivateKeyFile != "" {
+			jh.PrivateKeyFile = c.SSHPrivateKeyFile
+		}
+	}
+
 	if c.SSHProxyHost != "" {
+		if c.SSHProxyType == "" {
+			c.SSHProxyType = "socks5"
+		}
+
 		if c.SSHProxyPort == 0 {
 			c.SSHProxyPort = 1080
+			if c.SSHProxyType == "http" {
+				c.SSHProxyPort = 8080
+			}
 		}
 	}
 
+	if c.SSHPtyTerm == "" {
+		c.SSHPtyTerm = "xterm"
+	}
+
+	if c.SSHPtyWidth == 0 {
+		c.SSHPtyWidth = 80
+	}
+
+	if c.SSHPtyHeight == 0 {
+		c.SSHPtyHeight = 40
+	}
+
+	if c.SSHAlgorithmPreset == "" {
+		c.SSHAlgorithmPreset = "default"
+	}
+
+	if c.SSHSymlinkPolicy == "" {
+		c.SSHSymlinkPolicy = "follow"
+	}
+
 	if c.SSHFileTransferMethod == "" {
 		c.SSHFileTransferMethod = "scp"
 	}
 
+	if c.SSHSFTPConcurrency == 0 {
+		c.SSHSFTPConcurrency = 1
+	}
+
+	if c.SSHHostKeyChecking == "" {
+		c.SSHHostKeyChecking = "off"
+		if c.SSHHostKeyFingerprint != "" {
+			c.SSHHostKeyChecking = "fingerprint"
+		}
+	}
+
+	if c.SSHKnownHostsFile == "" {
+		c.SSHKnownHostsFile = "~/.ssh/known_hosts"
+	}
+
+	if c.SSHHostKeyFingerprint != "" && !strings.HasPrefix(c.SSHHostKeyFingerprint, "SHA256:") {
+		c.SSHHostKeyFingerprint = "SHA256:" + c.SSHHostKeyFingerprint
+	}
+	c.SSHHostKeyFingerprint = strings.TrimRight(c.SSHHostKeyFingerprint, "=")
+
 	// Backwards compatibility
 	if c.SSHWaitTimeout != 0 {
 		c.SSHTimeout = c.SSHWaitTimeout
@@ -523,10 +1019,21 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 
 	// Validation
 	var errs []error
-	if c.SSHUsername == "" {
+	// With an ssh_config file, the username may come from the file, which is
+	// only read when connecting.
+	if c.SSHUsername == "" && c.SSHConfigFile == "" {
 		errs = append(errs, errors.New("An ssh_username must be specified\n  Note: some builders used to default ssh_username to \"root\"."))
 	}
 
+	if c.SSHConfigFile != "" {
+		path, err := pathing.ExpandUser(c.SSHConfigFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ssh_config_file is invalid: %s", err))
+		} else if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_config_file is invalid: %s", err))
+		}
+	}
+
 	if c.SSHPrivateKeyFile != "" {
 		path, err := pathing.ExpandUser(c.SSHPrivateKeyFile)
 		if err != nil {
@@ -535,11 +1042,13 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		} else if _, err := os.Stat(path); err != nil {
 			errs = append(errs, fmt.Errorf(
 				"ssh_private_key_file is invalid: %s", err))
-		} else {
+		} else if !isSecurityKeyFile(path) {
+			// Security keys are skipped: they are used through the SSH agent,
+			// which is only reachable when connecting.
 			if c.SSHCertificateFile != "" {
 				certPath, err := pathing.ExpandUser(c.SSHCertificateFile)
 				if err != nil {
-					errs = append(errs, fmt.Errorf("invalid identity certificate: #{err}"))
+					errs = append(errs, fmt.Errorf("invalid identity certificate: %s", err))
 				}
 
 				if _, err := helperssh.FileSignerWithCert(path, certPath); err != nil {
@@ -553,6 +1062,14 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 				}
 			}
 		}
+	} else if c.SSHCertificateFile != "" && c.SSHAgentAuth {
+		certPath, err := pathing.ExpandUser(c.SSHCertificateFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid identity certificate: %s", err))
+		} else if _, err := helperssh.ReadCertificateFile(certPath); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"ssh_certificate_file is invalid: %s", err))
+		}
 	}
 
 	if c.SSHBastionHost != "" {
@@ -571,7 +1088,7 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 				if c.SSHBastionCertificateFile != "" {
 					certPath, err := pathing.ExpandUser(c.SSHBastionCertificateFile)
 					if err != nil {
-						errs = append(errs, fmt.Errorf("invalid identity certificate: #{err}"))
+						errs = append(errs, fmt.Errorf("invalid identity certificate: %s", err))
 					}
 					if _, err := helperssh.FileSignerWithCert(path, certPath); err != nil {
 						errs = append(errs, fmt.Errorf(
@@ -587,16 +1104,119 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		}
 	}
 
+	for i, a := range c.SSHKeyboardInteractiveAnswers {
+		if a.Prompt == "" {
+			errs = append(errs, fmt.Errorf("ssh_keyboard_interactive_answer %d: prompt must be specified", i))
+		} else if _, err := regexp.Compile(a.Prompt); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_keyboard_interactive_answer %d: prompt is invalid: %s", i, err))
+		}
+	}
+
+	for i, jh := range c.SSHJumpHosts {
+		if jh.Host == "" {
+			errs = append(errs, fmt.Errorf("ssh_jump_host %d: host must be specified", i))
+		}
+		if jh.Password == "" && jh.PrivateKeyFile == "" && !jh.AgentAuth {
+			errs = append(errs, fmt.Errorf(
+				"ssh_jump_host %d: password, private_key_file or agent_auth must be specified", i))
+		} else if jh.PrivateKeyFile != "" {
+			if _, err := jumpHostSigner(jh); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"ssh_jump_host %d: private_key_file is invalid: %s", i, err))
+			}
+		}
+	}
+
+	if _, ok := sshAlgorithmPresets[c.SSHAlgorithmPreset]; !ok {
+		errs = append(errs, fmt.Errorf(
+			"ssh_algorithm_preset ('%s') is invalid, valid presets: default, fips, legacy",
+			c.SSHAlgorithmPreset))
+	}
+
+	switch c.SSHSymlinkPolicy {
+	case "follow", "preserve", "skip":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"ssh_symlink_policy ('%s') is invalid, valid policies: follow, preserve, skip",
+			c.SSHSymlinkPolicy))
+	}
+
 	if c.SSHFileTransferMethod != "scp" && c.SSHFileTransferMethod != "sftp" {
 		errs = append(errs, fmt.Errorf(
 			"ssh_file_transfer_method ('%s') is invalid, valid methods: sftp, scp",
 			c.SSHFileTransferMethod))
 	}
 
+	switch c.SSHHostKeyChecking {
+	case "off", "accept-new":
+	case "known_hosts":
+		path, err := pathing.ExpandUser(c.SSHKnownHostsFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"ssh_known_hosts_file is invalid: %s", err))
+		} else if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"ssh_known_hosts_file is invalid: %s", err))
+		}
+	case "fingerprint":
+		if c.SSHHostKeyFingerprint == "" {
+			errs = append(errs, errors.New(
+				"ssh_host_key_fingerprint must be specified when ssh_host_key_checking is fingerprint"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(
+			"ssh_host_key_checking ('%s') is invalid, valid values: off, known_hosts, fingerprint, accept-new",
+			c.SSHHostKeyChecking))
+	}
+
+	if c.SSHPtyWidth < 0 {
+		errs = append(errs, errors.New("ssh_pty_width must be positive"))
+	}
+
+	if c.SSHPtyHeight < 0 {
+		errs = append(errs, errors.New("ssh_pty_height must be positive"))
+	}
+
+	if c.SSHKeepAliveCountMax < 0 {
+		errs = append(errs, errors.New("ssh_keep_alive_count_max must be positive"))
+	}
+
+	if c.SSHInactivityTimeout < 0 {
+		errs = append(errs, errors.New("ssh_inactivity_timeout must be positive"))
+	}
+
+	if c.SSHRekeyThreshold < 0 {
+		errs = append(errs, errors.New("ssh_rekey_threshold must be positive"))
+	}
+
+	if c.SSHSFTPMaxPacketSize < 0 {
+		errs = append(errs, errors.New("ssh_sftp_max_packet_size must be positive"))
+	}
+
+	if c.SSHTransferRateLimit < 0 {
+		errs = append(errs, errors.New("ssh_transfer_rate_limit must be positive"))
+	}
+
+	if c.SSHSFTPConcurrency < 1 {
+		errs = append(errs, errors.New("ssh_sftp_concurrency must be at least 1"))
+	} else if c.SSHSFTPConcurrency > 1 && c.SSHSFTPResume {
+		errs = append(errs, errors.New("ssh_sftp_resume can't be used with ssh_sftp_concurrency above 1"))
+	}
+
+	if c.SSHProxyHost != "" && c.SSHProxyType != "socks5" && c.SSHProxyType != "http" {
+		errs = append(errs, fmt.Errorf(
+			"ssh_proxy_type ('%s') is invalid, valid types: socks5, http",
+			c.SSHProxyType))
+	}
+
 	if c.SSHBastionHost != "" && c.SSHProxyHost != "" {
 		errs = append(errs, errors.New("please specify either ssh_bastion_host or ssh_proxy_host, not both"))
 	}
 
+	if len(c.SSHJumpHosts) > 0 && c.SSHProxyHost != "" {
+		errs = append(errs, errors.New("please specify either ssh_jump_host or ssh_proxy_host, not both"))
+	}
+
 	for _, v := range c.SSHLocalTunnels {
 		_, err := helperssh.ParseTunnelArgument(v, packerssh.UnsetTunnel)
 		if err != nil {
@@ -616,6 +1236,35 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 	return errs
 }
 
+// isSecurityKeyFile reports whether path is the private key file of a FIDO2
+// security key, which can't be parsed as a signer: it only holds a handle to
+// the key stored on the hardware token, and an SSH agent signs with it.
+func isSecurityKeyFile(path string) bool {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	_, err = sshkey.SecurityKeyPublicKey(key)
+	return err == nil
+}
+
+// jumpHostSigner returns the signer for the private key file, and optional
+// certificate, of a jump host.
+func jumpHostSigner(jh SSHJumpHost) (ssh.Signer, error) {
+	path, err := pathing.ExpandUser(jh.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if jh.CertificateFile != "" {
+		certPath, err := pathing.ExpandUser(jh.CertificateFile)
+		if err != nil {
+			return nil, err
+		}
+		return helperssh.FileSignerWithCert(path, certPath)
+	}
+	return helperssh.FileSigner(path)
+}
+
 func (c *Config) prepareWinRM(ctx *interpolate.Context) (errs []error) {
 	if c.WinRMPort == 0 && c.WinRMUseSSL {
 		c.WinRMPort = 5986
@@ -631,9 +1280,154 @@ func (c *Config) prepareWinRM(ctx *interpolate.Context) (errs []error) {
 		c.WinRMTransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
 	}
 
-	if c.WinRMUser == "" {
+	if c.WinRMProxyHost != "" {
+		if c.WinRMProxyPort == 0 {
+			c.WinRMProxyPort = 8080
+		}
+		proxy := c.winRMProxyFunc()
+		if c.WinRMUseNTLM {
+			c.WinRMTransportDecorator = func() winrm.Transporter { return winrm.NewClientNTLMWithProxyFunc(proxy) }
+		} else {
+			c.WinRMTransportDecorator = func() winrm.Transporter { return winrm.NewClientWithProxyFunc(proxy) }
+		}
+		if c.WinRMNoProxy {
+			errs = append(errs, errors.New("winrm_no_proxy and winrm_proxy_host can't be used together"))
+		}
+	}
+
+	if c.WinRMUseKerberos {
+		c.WinRMTransportDecorator = func() winrm.Transporter {
+			return sdkwinrm.NewClientKerberos(c.winRMKerberosSettings())
+		}
+		if c.WinRMUseNTLM {
+			errs = append(errs, errors.New("winrm_use_kerberos and winrm_use_ntlm can't be used together"))
+		}
+	}
+
+	if c.WinRMUseCredSSP {
+		c.WinRMTransportDecorator = func() winrm.Transporter {
+			return sdkwinrm.NewClientCredSSP(c.winRMCredSSPSettings())
+		}
+		if c.WinRMUseNTLM || c.WinRMUseKerberos {
+			errs = append(errs, errors.New("winrm_use_credssp can't be used with winrm_use_ntlm or winrm_use_kerberos"))
+		}
+		if !c.WinRMUseSSL {
+			errs = append(errs, errors.New("winrm_use_credssp requires winrm_use_ssl"))
+		}
+	}
+
+	if c.WinRMClientCert != "" || c.WinRMClientKey != "" {
+		if c.WinRMClientCert == "" || c.WinRMClientKey == "" {
+			errs = append(errs, errors.New("winrm_client_cert and winrm_client_key must be specified together"))
+		} else if settings, err := c.winRMCertificateSettings(); err != nil {
+			errs = append(errs, err)
+		} else {
+			c.WinRMTransportDecorator = func() winrm.Transporter {
+				return sdkwinrm.NewClientCertificate(settings)
+			}
+		}
+		if c.WinRMUseNTLM || c.WinRMUseKerberos || c.WinRMUseCredSSP {
+			errs = append(errs, errors.New("winrm_client_cert can't be used with winrm_use_ntlm, winrm_use_kerberos or winrm_use_credssp"))
+		}
+		if !c.WinRMUseSSL {
+			errs = append(errs, errors.New("winrm_client_cert requires winrm_use_ssl"))
+		}
+	}
+
+	// A Kerberos credential cache holds the user name.
+	kerberosCCache := c.WinRMUseKerberos && c.WinRMKerberosKeytab == "" && c.WinRMPassword == ""
+	// The guest maps client certificates to their user.
+	if c.WinRMUser == "" && !kerberosCCache && c.WinRMClientCert == "" {
 		errs = append(errs, errors.New("winrm_username must be specified."))
 	}
 
+	if c.WinRMTransferRateLimit < 0 {
+		errs = append(errs, errors.New("winrm_transfer_rate_limit must be positive"))
+	}
+
+	if c.WinRMUploadChunkSize < 0 {
+		errs = append(errs, errors.New("winrm_upload_chunk_size must be positive"))
+	}
+
+	if c.WinRMUsePSRP && (c.WinRMForce64Bit || c.WinRMRunAsSystem) {
+		errs = append(errs, errors.New("winrm_force_64bit and winrm_run_as_system can't be used with winrm_use_psrp"))
+	}
+
 	return errs
 }
+
+// winRMKerberosSettings returns the Kerberos settings of the WinRM
+// communicator.
+func (c *Config) winRMKerberosSettings() *sdkwinrm.KerberosSettings {
+	return &sdkwinrm.KerberosSettings{
+		Username:     c.WinRMUser,
+		Realm:        c.WinRMKerberosRealm,
+		Password:     c.WinRMPassword,
+		KeytabPath:   c.WinRMKerberosKeytab,
+		CCachePath:   c.WinRMKerberosCCache,
+		Krb5ConfPath: c.WinRMKerberosConfig,
+		SPN:          c.WinRMKerberosSPN,
+		Proxy:        c.winRMProxyFunc(),
+	}
+}
+
+// winRMCredSSPSettings returns the CredSSP settings of the WinRM
+// communicator.
+func (c *Config) winRMCredSSPSettings() *sdkwinrm.CredSSPSettings {
+	return &sdkwinrm.CredSSPSettings{
+		Username: c.WinRMUser,
+		Password: c.WinRMPassword,
+		Proxy:    c.winRMProxyFunc(),
+	}
+}
+
+// winRMCertificateSettings returns the client certificate settings of the
+// WinRM communicator, reading the certificate and its key.
+func (c *Config) winRMCertificateSettings() (*sdkwinrm.CertificateSettings, error) {
+	var settings sdkwinrm.CertificateSettings
+	for _, f := range []struct {
+		path string
+		dst  *[]byte
+	}{
+		{c.WinRMClientCert, &settings.Cert},
+		{c.WinRMClientKey, &settings.Key},
+	} {
+		path, err := pathing.ExpandUser(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("Error expanding path for WinRM client certificate: %s", err)
+		}
+		if *f.dst, err = ioutil.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("Error on reading WinRM client certificate: %s", err)
+		}
+	}
+	if _, err := tls.X509KeyPair(settings.Cert, settings.Key); err != nil {
+		return nil, fmt.Errorf("Invalid WinRM client certificate: %s", err)
+	}
+	settings.Proxy = c.winRMProxyFunc()
+	return &settings, nil
+}
+
+// winRMProxyFunc returns the proxy of the WinRM requests: winrm_proxy_host
+// when set, otherwise the proxies of the environment, reloaded for each
+// request with winrm_no_proxy, as it changes NO_PROXY. It returns nil for
+// the default proxies of the transports.
+func (c *Config) winRMProxyFunc() func(*http.Request) (*url.URL, error) {
+	if c.WinRMProxyHost != "" {
+		u := &url.URL{Scheme: "http", Host: packernet.HostPort(c.WinRMProxyHost, c.WinRMProxyPort)}
+		if c.WinRMProxyUsername != "" {
+			u.User = url.UserPassword(c.WinRMProxyUsername, c.WinRMProxyPassword)
+		}
+		proxy := (&httpproxy.Config{
+			HTTPProxy:  u.String(),
+			HTTPSProxy: u.String(),
+			NoProxy:    strings.Join(c.WinRMProxyBypass, ","),
+		}).ProxyFunc()
+		return func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+	if c.WinRMNoProxy {
+		return RefreshProxyFromEnvironment
+	}
+	return nil
+}
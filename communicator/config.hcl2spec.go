@@ -10,55 +10,100 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	Type                      *string  `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string  `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string  `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int     `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string  `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string  `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string  `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string  `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string  `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int     `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool    `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string  `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string  `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool    `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string  `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string  `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool    `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool    `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int     `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string  `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int     `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool    `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string  `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string  `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool    `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string  `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string  `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string  `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string  `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int     `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string  `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string  `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string  `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string  `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte   `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte   `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string  `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string  `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string  `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool    `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int     `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string  `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool    `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool    `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool    `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	Type                          *string                            `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect            *string                            `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                       *string                            `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                       *int                               `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername                   *string                            `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword                   *string                            `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyboardInteractiveAnswers []FlatSSHKeyboardInteractiveAnswer `mapstructure:"ssh_keyboard_interactive_answer" cty:"ssh_keyboard_interactive_answer" hcl:"ssh_keyboard_interactive_answer"`
+	SSHKeyboardInteractivePrompt  *bool                              `mapstructure:"ssh_keyboard_interactive_prompt" cty:"ssh_keyboard_interactive_prompt" hcl:"ssh_keyboard_interactive_prompt"`
+	SSHKeyPairName                *string                            `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName       *string                            `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType       *string                            `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits       *int                               `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                    []string                           `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys        *bool                              `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos                   []string                           `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHMACs                       []string                           `mapstructure:"ssh_mac_algorithms" cty:"ssh_mac_algorithms" hcl:"ssh_mac_algorithms"`
+	SSHHostKeyAlgos               []string                           `mapstructure:"ssh_host_key_algorithms" cty:"ssh_host_key_algorithms" hcl:"ssh_host_key_algorithms"`
+	SSHAlgorithmPreset            *string                            `mapstructure:"ssh_algorithm_preset" cty:"ssh_algorithm_preset" hcl:"ssh_algorithm_preset"`
+	SSHHostKeyChecking            *string                            `mapstructure:"ssh_host_key_checking" cty:"ssh_host_key_checking" hcl:"ssh_host_key_checking"`
+	SSHKnownHostsFile             *string                            `mapstructure:"ssh_known_hosts_file" cty:"ssh_known_hosts_file" hcl:"ssh_known_hosts_file"`
+	SSHHostKeyFingerprint         *string                            `mapstructure:"ssh_host_key_fingerprint" cty:"ssh_host_key_fingerprint" hcl:"ssh_host_key_fingerprint"`
+	SSHPrivateKeyFile             *string                            `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile            *string                            `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                        *bool                              `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHPtyTerm                    *string                            `mapstructure:"ssh_pty_term" cty:"ssh_pty_term" hcl:"ssh_pty_term"`
+	SSHPtyWidth                   *int                               `mapstructure:"ssh_pty_width" cty:"ssh_pty_width" hcl:"ssh_pty_width"`
+	SSHPtyHeight                  *int                               `mapstructure:"ssh_pty_height" cty:"ssh_pty_height" hcl:"ssh_pty_height"`
+	SSHTimeout                    *string                            `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout                *string                            `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth                  *bool                              `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding     *bool                              `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts          *int                               `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost                *string                            `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort                *int                               `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth           *bool                              `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername            *string                            `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword            *string                            `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive         *bool                              `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile      *string                            `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile     *string                            `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHJumpHosts                  []FlatSSHJumpHost                  `mapstructure:"ssh_jump_host" cty:"ssh_jump_host" hcl:"ssh_jump_host"`
+	SSHConfigFile                 *string                            `mapstructure:"ssh_config_file" cty:"ssh_config_file" hcl:"ssh_config_file"`
+	SSHFileTransferMethod         *string                            `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHSFTPMaxPacketSize          *int                               `mapstructure:"ssh_sftp_max_packet_size" cty:"ssh_sftp_max_packet_size" hcl:"ssh_sftp_max_packet_size"`
+	SSHSFTPConcurrency            *int                               `mapstructure:"ssh_sftp_concurrency" cty:"ssh_sftp_concurrency" hcl:"ssh_sftp_concurrency"`
+	SSHSFTPResume                 *bool                              `mapstructure:"ssh_sftp_resume" cty:"ssh_sftp_resume" hcl:"ssh_sftp_resume"`
+	SSHRsync                      *bool                              `mapstructure:"ssh_rsync" cty:"ssh_rsync" hcl:"ssh_rsync"`
+	SSHRsyncDelete                *bool                              `mapstructure:"ssh_rsync_delete" cty:"ssh_rsync_delete" hcl:"ssh_rsync_delete"`
+	SSHTransferRateLimit          *int64                             `mapstructure:"ssh_transfer_rate_limit" cty:"ssh_transfer_rate_limit" hcl:"ssh_transfer_rate_limit"`
+	SSHSymlinkPolicy              *string                            `mapstructure:"ssh_symlink_policy" cty:"ssh_symlink_policy" hcl:"ssh_symlink_policy"`
+	SSHProxyHost                  *string                            `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyType                  *string                            `mapstructure:"ssh_proxy_type" cty:"ssh_proxy_type" hcl:"ssh_proxy_type"`
+	SSHProxyPort                  *int                               `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername              *string                            `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword              *string                            `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval          *string                            `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHKeepAliveCountMax          *int                               `mapstructure:"ssh_keep_alive_count_max" cty:"ssh_keep_alive_count_max" hcl:"ssh_keep_alive_count_max"`
+	SSHInactivityTimeout          *string                            `mapstructure:"ssh_inactivity_timeout" cty:"ssh_inactivity_timeout" hcl:"ssh_inactivity_timeout"`
+	SSHRekeyThreshold             *int64                             `mapstructure:"ssh_rekey_threshold" cty:"ssh_rekey_threshold" hcl:"ssh_rekey_threshold"`
+	SSHReadWriteTimeout           *string                            `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels              []string                           `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels               []string                           `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey                  []byte                             `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey                 []byte                             `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                     *string                            `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword                 *string                            `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                     *string                            `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy                  *bool                              `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMProxyHost                *string                            `mapstructure:"winrm_proxy_host" cty:"winrm_proxy_host" hcl:"winrm_proxy_host"`
+	WinRMProxyPort                *int                               `mapstructure:"winrm_proxy_port" cty:"winrm_proxy_port" hcl:"winrm_proxy_port"`
+	WinRMProxyUsername            *string                            `mapstructure:"winrm_proxy_username" cty:"winrm_proxy_username" hcl:"winrm_proxy_username"`
+	WinRMProxyPassword            *string                            `mapstructure:"winrm_proxy_password" cty:"winrm_proxy_password" hcl:"winrm_proxy_password"`
+	WinRMProxyBypass              []string                           `mapstructure:"winrm_proxy_bypass" cty:"winrm_proxy_bypass" hcl:"winrm_proxy_bypass"`
+	WinRMPort                     *int                               `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout                  *string                            `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL                   *bool                              `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure                 *bool                              `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMTransferRateLimit        *int64                             `mapstructure:"winrm_transfer_rate_limit" cty:"winrm_transfer_rate_limit" hcl:"winrm_transfer_rate_limit"`
+	WinRMUploadChunkSize          *int                               `mapstructure:"winrm_upload_chunk_size" cty:"winrm_upload_chunk_size" hcl:"winrm_upload_chunk_size"`
+	WinRMUploadCompression        *bool                              `mapstructure:"winrm_upload_compression" cty:"winrm_upload_compression" hcl:"winrm_upload_compression"`
+	WinRMShell                    *string                            `mapstructure:"winrm_shell" cty:"winrm_shell" hcl:"winrm_shell"`
+	WinRMForce64Bit               *bool                              `mapstructure:"winrm_force_64bit" cty:"winrm_force_64bit" hcl:"winrm_force_64bit"`
+	WinRMRunAsSystem              *bool                              `mapstructure:"winrm_run_as_system" cty:"winrm_run_as_system" hcl:"winrm_run_as_system"`
+	WinRMUseNTLM                  *bool                              `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	WinRMUseKerberos              *bool                              `mapstructure:"winrm_use_kerberos" cty:"winrm_use_kerberos" hcl:"winrm_use_kerberos"`
+	WinRMKerberosRealm            *string                            `mapstructure:"winrm_kerberos_realm" cty:"winrm_kerberos_realm" hcl:"winrm_kerberos_realm"`
+	WinRMKerberosKeytab           *string                            `mapstructure:"winrm_kerberos_keytab" cty:"winrm_kerberos_keytab" hcl:"winrm_kerberos_keytab"`
+	WinRMKerberosCCache           *string                            `mapstructure:"winrm_kerberos_ccache" cty:"winrm_kerberos_ccache" hcl:"winrm_kerberos_ccache"`
+	WinRMKerberosConfig           *string                            `mapstructure:"winrm_kerberos_config" cty:"winrm_kerberos_config" hcl:"winrm_kerberos_config"`
+	WinRMKerberosSPN              *string                            `mapstructure:"winrm_kerberos_spn" cty:"winrm_kerberos_spn" hcl:"winrm_kerberos_spn"`
+	WinRMUseCredSSP               *bool                              `mapstructure:"winrm_use_credssp" cty:"winrm_use_credssp" hcl:"winrm_use_credssp"`
+	WinRMClientCert               *string                            `mapstructure:"winrm_client_cert" cty:"winrm_client_cert" hcl:"winrm_client_cert"`
+	WinRMClientKey                *string                            `mapstructure:"winrm_client_key" cty:"winrm_client_key" hcl:"winrm_client_key"`
+	WinRMUsePSRP                  *bool                              `mapstructure:"winrm_use_psrp" cty:"winrm_use_psrp" hcl:"winrm_use_psrp"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -73,55 +118,100 @@ func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatConfig.
 func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
-		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
-		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                     &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                 &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                 &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":             &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":      &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_type":      &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
-		"temporary_key_pair_bits":      &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
-		"ssh_ciphers":                  &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":    &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":  &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":         &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":         &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                      &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                  &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":             &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":               &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding": &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":       &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":             &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":             &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":         &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":         &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":      &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file": &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":     &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":               &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":               &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":           &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":           &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":      &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":       &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":           &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":            &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":               &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":              &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
-		"winrm_username":               &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password":               &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":                   &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy":               &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":                   &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":                &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"communicator":                    &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":         &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"ssh_host":                        &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                        &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                    &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                    &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keyboard_interactive_answer": &hcldec.BlockListSpec{TypeName: "ssh_keyboard_interactive_answer", Nested: hcldec.ObjectSpec((*FlatSSHKeyboardInteractiveAnswer)(nil).HCL2Spec())},
+		"ssh_keyboard_interactive_prompt": &hcldec.AttrSpec{Name: "ssh_keyboard_interactive_prompt", Type: cty.Bool, Required: false},
+		"ssh_keypair_name":                &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":         &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":         &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":         &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                     &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":       &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":     &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_mac_algorithms":              &hcldec.AttrSpec{Name: "ssh_mac_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_host_key_algorithms":         &hcldec.AttrSpec{Name: "ssh_host_key_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_algorithm_preset":            &hcldec.AttrSpec{Name: "ssh_algorithm_preset", Type: cty.String, Required: false},
+		"ssh_host_key_checking":           &hcldec.AttrSpec{Name: "ssh_host_key_checking", Type: cty.String, Required: false},
+		"ssh_known_hosts_file":            &hcldec.AttrSpec{Name: "ssh_known_hosts_file", Type: cty.String, Required: false},
+		"ssh_host_key_fingerprint":        &hcldec.AttrSpec{Name: "ssh_host_key_fingerprint", Type: cty.String, Required: false},
+		"ssh_private_key_file":            &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":            &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                         &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_pty_term":                    &hcldec.AttrSpec{Name: "ssh_pty_term", Type: cty.String, Required: false},
+		"ssh_pty_width":                   &hcldec.AttrSpec{Name: "ssh_pty_width", Type: cty.Number, Required: false},
+		"ssh_pty_height":                  &hcldec.AttrSpec{Name: "ssh_pty_height", Type: cty.Number, Required: false},
+		"ssh_timeout":                     &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":                &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                  &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":    &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":          &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":                &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":                &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":          &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":            &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":            &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":         &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":    &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":    &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_jump_host":                   &hcldec.BlockListSpec{TypeName: "ssh_jump_host", Nested: hcldec.ObjectSpec((*FlatSSHJumpHost)(nil).HCL2Spec())},
+		"ssh_config_file":                 &hcldec.AttrSpec{Name: "ssh_config_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":        &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_sftp_max_packet_size":        &hcldec.AttrSpec{Name: "ssh_sftp_max_packet_size", Type: cty.Number, Required: false},
+		"ssh_sftp_concurrency":            &hcldec.AttrSpec{Name: "ssh_sftp_concurrency", Type: cty.Number, Required: false},
+		"ssh_sftp_resume":                 &hcldec.AttrSpec{Name: "ssh_sftp_resume", Type: cty.Bool, Required: false},
+		"ssh_rsync":                       &hcldec.AttrSpec{Name: "ssh_rsync", Type: cty.Bool, Required: false},
+		"ssh_rsync_delete":                &hcldec.AttrSpec{Name: "ssh_rsync_delete", Type: cty.Bool, Required: false},
+		"ssh_transfer_rate_limit":         &hcldec.AttrSpec{Name: "ssh_transfer_rate_limit", Type: cty.Number, Required: false},
+		"ssh_symlink_policy":              &hcldec.AttrSpec{Name: "ssh_symlink_policy", Type: cty.String, Required: false},
+		"ssh_proxy_host":                  &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_type":                  &hcldec.AttrSpec{Name: "ssh_proxy_type", Type: cty.String, Required: false},
+		"ssh_proxy_port":                  &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":              &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":              &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":         &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_keep_alive_count_max":        &hcldec.AttrSpec{Name: "ssh_keep_alive_count_max", Type: cty.Number, Required: false},
+		"ssh_inactivity_timeout":          &hcldec.AttrSpec{Name: "ssh_inactivity_timeout", Type: cty.String, Required: false},
+		"ssh_rekey_threshold":             &hcldec.AttrSpec{Name: "ssh_rekey_threshold", Type: cty.Number, Required: false},
+		"ssh_read_write_timeout":          &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":              &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":               &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                  &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                 &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":                  &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":                  &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                      &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":                  &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_proxy_host":                &hcldec.AttrSpec{Name: "winrm_proxy_host", Type: cty.String, Required: false},
+		"winrm_proxy_port":                &hcldec.AttrSpec{Name: "winrm_proxy_port", Type: cty.Number, Required: false},
+		"winrm_proxy_username":            &hcldec.AttrSpec{Name: "winrm_proxy_username", Type: cty.String, Required: false},
+		"winrm_proxy_password":            &hcldec.AttrSpec{Name: "winrm_proxy_password", Type: cty.String, Required: false},
+		"winrm_proxy_bypass":              &hcldec.AttrSpec{Name: "winrm_proxy_bypass", Type: cty.List(cty.String), Required: false},
+		"winrm_port":                      &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                   &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                   &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":                  &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_transfer_rate_limit":       &hcldec.AttrSpec{Name: "winrm_transfer_rate_limit", Type: cty.Number, Required: false},
+		"winrm_upload_chunk_size":         &hcldec.AttrSpec{Name: "winrm_upload_chunk_size", Type: cty.Number, Required: false},
+		"winrm_upload_compression":        &hcldec.AttrSpec{Name: "winrm_upload_compression", Type: cty.Bool, Required: false},
+		"winrm_shell":                     &hcldec.AttrSpec{Name: "winrm_shell", Type: cty.String, Required: false},
+		"winrm_force_64bit":               &hcldec.AttrSpec{Name: "winrm_force_64bit", Type: cty.Bool, Required: false},
+		"winrm_run_as_system":             &hcldec.AttrSpec{Name: "winrm_run_as_system", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":                  &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"winrm_use_kerberos":              &hcldec.AttrSpec{Name: "winrm_use_kerberos", Type: cty.Bool, Required: false},
+		"winrm_kerberos_realm":            &hcldec.AttrSpec{Name: "winrm_kerberos_realm", Type: cty.String, Required: false},
+		"winrm_kerberos_keytab":           &hcldec.AttrSpec{Name: "winrm_kerberos_keytab", Type: cty.String, Required: false},
+		"winrm_kerberos_ccache":           &hcldec.AttrSpec{Name: "winrm_kerberos_ccache", Type: cty.String, Required: false},
+		"winrm_kerberos_config":           &hcldec.AttrSpec{Name: "winrm_kerberos_config", Type: cty.String, Required: false},
+		"winrm_kerberos_spn":              &hcldec.AttrSpec{Name: "winrm_kerberos_spn", Type: cty.String, Required: false},
+		"winrm_use_credssp":               &hcldec.AttrSpec{Name: "winrm_use_credssp", Type: cty.Bool, Required: false},
+		"winrm_client_cert":               &hcldec.AttrSpec{Name: "winrm_client_cert", Type: cty.String, Required: false},
+		"winrm_client_key":                &hcldec.AttrSpec{Name: "winrm_client_key", Type: cty.String, Required: false},
+		"winrm_use_psrp":                  &hcldec.AttrSpec{Name: "winrm_use_psrp", Type: cty.Bool, Required: false},
 	}
 	return s
 }
@@ -129,44 +219,68 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 // FlatSSH is an auto-generated flat version of SSH.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatSSH struct {
-	SSHHost                   *string  `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int     `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string  `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string  `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string  `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string  `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string  `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int     `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool    `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string  `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string  `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool    `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string  `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string  `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool    `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool    `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int     `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string  `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int     `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool    `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string  `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string  `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool    `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string  `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string  `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string  `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string  `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int     `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string  `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string  `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string  `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string  `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte   `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte   `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	SSHHost                       *string                            `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                       *int                               `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername                   *string                            `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword                   *string                            `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyboardInteractiveAnswers []FlatSSHKeyboardInteractiveAnswer `mapstructure:"ssh_keyboard_interactive_answer" cty:"ssh_keyboard_interactive_answer" hcl:"ssh_keyboard_interactive_answer"`
+	SSHKeyboardInteractivePrompt  *bool                              `mapstructure:"ssh_keyboard_interactive_prompt" cty:"ssh_keyboard_interactive_prompt" hcl:"ssh_keyboard_interactive_prompt"`
+	SSHKeyPairName                *string                            `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName       *string                            `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType       *string                            `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits       *int                               `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                    []string                           `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys        *bool                              `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos                   []string                           `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHMACs                       []string                           `mapstructure:"ssh_mac_algorithms" cty:"ssh_mac_algorithms" hcl:"ssh_mac_algorithms"`
+	SSHHostKeyAlgos               []string                           `mapstructure:"ssh_host_key_algorithms" cty:"ssh_host_key_algorithms" hcl:"ssh_host_key_algorithms"`
+	SSHAlgorithmPreset            *string                            `mapstructure:"ssh_algorithm_preset" cty:"ssh_algorithm_preset" hcl:"ssh_algorithm_preset"`
+	SSHHostKeyChecking            *string                            `mapstructure:"ssh_host_key_checking" cty:"ssh_host_key_checking" hcl:"ssh_host_key_checking"`
+	SSHKnownHostsFile             *string                            `mapstructure:"ssh_known_hosts_file" cty:"ssh_known_hosts_file" hcl:"ssh_known_hosts_file"`
+	SSHHostKeyFingerprint         *string                            `mapstructure:"ssh_host_key_fingerprint" cty:"ssh_host_key_fingerprint" hcl:"ssh_host_key_fingerprint"`
+	SSHPrivateKeyFile             *string                            `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile            *string                            `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                        *bool                              `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHPtyTerm                    *string                            `mapstructure:"ssh_pty_term" cty:"ssh_pty_term" hcl:"ssh_pty_term"`
+	SSHPtyWidth                   *int                               `mapstructure:"ssh_pty_width" cty:"ssh_pty_width" hcl:"ssh_pty_width"`
+	SSHPtyHeight                  *int                               `mapstructure:"ssh_pty_height" cty:"ssh_pty_height" hcl:"ssh_pty_height"`
+	SSHTimeout                    *string                            `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout                *string                            `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth                  *bool                              `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding     *bool                              `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts          *int                               `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost                *string                            `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort                *int                               `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth           *bool                              `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername            *string                            `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword            *string                            `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive         *bool                              `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile      *string                            `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile     *string                            `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHJumpHosts                  []FlatSSHJumpHost                  `mapstructure:"ssh_jump_host" cty:"ssh_jump_host" hcl:"ssh_jump_host"`
+	SSHConfigFile                 *string                            `mapstructure:"ssh_config_file" cty:"ssh_config_file" hcl:"ssh_config_file"`
+	SSHFileTransferMethod         *string                            `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHSFTPMaxPacketSize          *int                               `mapstructure:"ssh_sftp_max_packet_size" cty:"ssh_sftp_max_packet_size" hcl:"ssh_sftp_max_packet_size"`
+	SSHSFTPConcurrency            *int                               `mapstructure:"ssh_sftp_concurrency" cty:"ssh_sftp_concurrency" hcl:"ssh_sftp_concurrency"`
+	SSHSFTPResume                 *bool                              `mapstructure:"ssh_sftp_resume" cty:"ssh_sftp_resume" hcl:"ssh_sftp_resume"`
+	SSHRsync                      *bool                              `mapstructure:"ssh_rsync" cty:"ssh_rsync" hcl:"ssh_rsync"`
+	SSHRsyncDelete                *bool                              `mapstructure:"ssh_rsync_delete" cty:"ssh_rsync_delete" hcl:"ssh_rsync_delete"`
+	SSHTransferRateLimit          *int64                             `mapstructure:"ssh_transfer_rate_limit" cty:"ssh_transfer_rate_limit" hcl:"ssh_transfer_rate_limit"`
+	SSHSymlinkPolicy              *string                            `mapstructure:"ssh_symlink_policy" cty:"ssh_symlink_policy" hcl:"ssh_symlink_policy"`
+	SSHProxyHost                  *string                            `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyType                  *string                            `mapstructure:"ssh_proxy_type" cty:"ssh_proxy_type" hcl:"ssh_proxy_type"`
+	SSHProxyPort                  *int                               `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername              *string                            `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword              *string                            `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval          *string                            `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHKeepAliveCountMax          *int                               `mapstructure:"ssh_keep_alive_count_max" cty:"ssh_keep_alive_count_max" hcl:"ssh_keep_alive_count_max"`
+	SSHInactivityTimeout          *string                            `mapstructure:"ssh_inactivity_timeout" cty:"ssh_inactivity_timeout" hcl:"ssh_inactivity_timeout"`
+	SSHRekeyThreshold             *int64                             `mapstructure:"ssh_rekey_threshold" cty:"ssh_rekey_threshold" hcl:"ssh_rekey_threshold"`
+	SSHReadWriteTimeout           *string                            `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels              []string                           `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels               []string                           `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey                  []byte                             `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey                 []byte                             `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
 }
 
 // FlatMapstructure returns a new FlatSSH.
@@ -181,44 +295,128 @@ func (*SSH) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
 // The decoded values from this spec will then be applied to a FlatSSH.
 func (*FlatSSH) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                     &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                 &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                 &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":             &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":      &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_type":      &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
-		"temporary_key_pair_bits":      &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
-		"ssh_ciphers":                  &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":    &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":  &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":         &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":         &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                      &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                  &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":             &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":               &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding": &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":       &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":             &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":             &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":         &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":         &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":      &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file": &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":     &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":               &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":               &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":           &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":           &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":      &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":       &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":           &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":            &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":               &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":              &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_host":                        &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                        &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                    &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                    &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keyboard_interactive_answer": &hcldec.BlockListSpec{TypeName: "ssh_keyboard_interactive_answer", Nested: hcldec.ObjectSpec((*FlatSSHKeyboardInteractiveAnswer)(nil).HCL2Spec())},
+		"ssh_keyboard_interactive_prompt": &hcldec.AttrSpec{Name: "ssh_keyboard_interactive_prompt", Type: cty.Bool, Required: false},
+		"ssh_keypair_name":                &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":         &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":         &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":         &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                     &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":       &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":     &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_mac_algorithms":              &hcldec.AttrSpec{Name: "ssh_mac_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_host_key_algorithms":         &hcldec.AttrSpec{Name: "ssh_host_key_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_algorithm_preset":            &hcldec.AttrSpec{Name: "ssh_algorithm_preset", Type: cty.String, Required: false},
+		"ssh_host_key_checking":           &hcldec.AttrSpec{Name: "ssh_host_key_checking", Type: cty.String, Required: false},
+		"ssh_known_hosts_file":            &hcldec.AttrSpec{Name: "ssh_known_hosts_file", Type: cty.String, Required: false},
+		"ssh_host_key_fingerprint":        &hcldec.AttrSpec{Name: "ssh_host_key_fingerprint", Type: cty.String, Required: false},
+		"ssh_private_key_file":            &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":            &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                         &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_pty_term":                    &hcldec.AttrSpec{Name: "ssh_pty_term", Type: cty.String, Required: false},
+		"ssh_pty_width":                   &hcldec.AttrSpec{Name: "ssh_pty_width", Type: cty.Number, Required: false},
+		"ssh_pty_height":                  &hcldec.AttrSpec{Name: "ssh_pty_height", Type: cty.Number, Required: false},
+		"ssh_timeout":                     &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":                &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                  &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":    &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":          &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":                &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":                &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":          &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":            &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":            &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":         &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":    &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":    &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_jump_host":                   &hcldec.BlockListSpec{TypeName: "ssh_jump_host", Nested: hcldec.ObjectSpec((*FlatSSHJumpHost)(nil).HCL2Spec())},
+		"ssh_config_file":                 &hcldec.AttrSpec{Name: "ssh_config_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":        &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_sftp_max_packet_size":        &hcldec.AttrSpec{Name: "ssh_sftp_max_packet_size", Type: cty.Number, Required: false},
+		"ssh_sftp_concurrency":            &hcldec.AttrSpec{Name: "ssh_sftp_concurrency", Type: cty.Number, Required: false},
+		"ssh_sftp_resume":                 &hcldec.AttrSpec{Name: "ssh_sftp_resume", Type: cty.Bool, Required: false},
+		"ssh_rsync":                       &hcldec.AttrSpec{Name: "ssh_rsync", Type: cty.Bool, Required: false},
+		"ssh_rsync_delete":                &hcldec.AttrSpec{Name: "ssh_rsync_delete", Type: cty.Bool, Required: false},
+		"ssh_transfer_rate_limit":         &hcldec.AttrSpec{Name: "ssh_transfer_rate_limit", Type: cty.Number, Required: false},
+		"ssh_symlink_policy":              &hcldec.AttrSpec{Name: "ssh_symlink_policy", Type: cty.String, Required: false},
+		"ssh_proxy_host":                  &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_type":                  &hcldec.AttrSpec{Name: "ssh_proxy_type", Type: cty.String, Required: false},
+		"ssh_proxy_port":                  &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":              &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":              &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":         &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_keep_alive_count_max":        &hcldec.AttrSpec{Name: "ssh_keep_alive_count_max", Type: cty.Number, Required: false},
+		"ssh_inactivity_timeout":          &hcldec.AttrSpec{Name: "ssh_inactivity_timeout", Type: cty.String, Required: false},
+		"ssh_rekey_threshold":             &hcldec.AttrSpec{Name: "ssh_rekey_threshold", Type: cty.Number, Required: false},
+		"ssh_read_write_timeout":          &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":              &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":               &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                  &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                 &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+	}
+	return s
+}
+
+// FlatSSHJumpHost is an auto-generated flat version of SSHJumpHost.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSSHJumpHost struct {
+	Host            *string `mapstructure:"host" required:"true" cty:"host" hcl:"host"`
+	Port            *int    `mapstructure:"port" cty:"port" hcl:"port"`
+	Username        *string `mapstructure:"username" cty:"username" hcl:"username"`
+	Password        *string `mapstructure:"password" cty:"password" hcl:"password"`
+	PrivateKeyFile  *string `mapstructure:"private_key_file" cty:"private_key_file" hcl:"private_key_file"`
+	CertificateFile *string `mapstructure:"certificate_file" cty:"certificate_file" hcl:"certificate_file"`
+	AgentAuth       *bool   `mapstructure:"agent_auth" cty:"agent_auth" hcl:"agent_auth"`
+}
+
+// FlatMapstructure returns a new FlatSSHJumpHost.
+// FlatSSHJumpHost is an auto-generated flat version of SSHJumpHost.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SSHJumpHost) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSSHJumpHost)
+}
+
+// HCL2Spec returns the hcl spec of a SSHJumpHost.
+// This spec is used by HCL to read the fields of SSHJumpHost.
+// The decoded values from this spec will then be applied to a FlatSSHJumpHost.
+func (*FlatSSHJumpHost) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"host":             &hcldec.AttrSpec{Name: "host", Type: cty.String, Required: false},
+		"port":             &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: false},
+		"username":         &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":         &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"private_key_file": &hcldec.AttrSpec{Name: "private_key_file", Type: cty.String, Required: false},
+		"certificate_file": &hcldec.AttrSpec{Name: "certificate_file", Type: cty.String, Required: false},
+		"agent_auth":       &hcldec.AttrSpec{Name: "agent_auth", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatSSHKeyboardInteractiveAnswer is an auto-generated flat version of SSHKeyboardInteractiveAnswer.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSSHKeyboardInteractiveAnswer struct {
+	Prompt *string `mapstructure:"prompt" required:"true" cty:"prompt" hcl:"prompt"`
+	Answer *string `mapstructure:"answer" required:"true" cty:"answer" hcl:"answer"`
+}
+
+// FlatMapstructure returns a new FlatSSHKeyboardInteractiveAnswer.
+// FlatSSHKeyboardInteractiveAnswer is an auto-generated flat version of SSHKeyboardInteractiveAnswer.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SSHKeyboardInteractiveAnswer) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSSHKeyboardInteractiveAnswer)
+}
+
+// HCL2Spec returns the hcl spec of a SSHKeyboardInteractiveAnswer.
+// This spec is used by HCL to read the fields of SSHKeyboardInteractiveAnswer.
+// The decoded values from this spec will then be applied to a FlatSSHKeyboardInteractiveAnswer.
+func (*FlatSSHKeyboardInteractiveAnswer) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"prompt": &hcldec.AttrSpec{Name: "prompt", Type: cty.String, Required: false},
+		"answer": &hcldec.AttrSpec{Name: "answer", Type: cty.String, Required: false},
 	}
 	return s
 }
@@ -251,15 +449,36 @@ func (*FlatSSHTemporaryKeyPair) HCL2Spec() map[string]hcldec.Spec {
 // FlatWinRM is an auto-generated flat version of WinRM.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatWinRM struct {
-	WinRMUser     *string `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword *string `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost     *string `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy  *bool   `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort     *int    `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout  *string `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL   *bool   `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure *bool   `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM  *bool   `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	WinRMUser              *string  `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword          *string  `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost              *string  `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy           *bool    `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMProxyHost         *string  `mapstructure:"winrm_proxy_host" cty:"winrm_proxy_host" hcl:"winrm_proxy_host"`
+	WinRMProxyPort         *int     `mapstructure:"winrm_proxy_port" cty:"winrm_proxy_port" hcl:"winrm_proxy_port"`
+	WinRMProxyUsername     *string  `mapstructure:"winrm_proxy_username" cty:"winrm_proxy_username" hcl:"winrm_proxy_username"`
+	WinRMProxyPassword     *string  `mapstructure:"winrm_proxy_password" cty:"winrm_proxy_password" hcl:"winrm_proxy_password"`
+	WinRMProxyBypass       []string `mapstructure:"winrm_proxy_bypass" cty:"winrm_proxy_bypass" hcl:"winrm_proxy_bypass"`
+	WinRMPort              *int     `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout           *string  `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL            *bool    `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure          *bool    `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMTransferRateLimit *int64   `mapstructure:"winrm_transfer_rate_limit" cty:"winrm_transfer_rate_limit" hcl:"winrm_transfer_rate_limit"`
+	WinRMUploadChunkSize   *int     `mapstructure:"winrm_upload_chunk_size" cty:"winrm_upload_chunk_size" hcl:"winrm_upload_chunk_size"`
+	WinRMUploadCompression *bool    `mapstructure:"winrm_upload_compression" cty:"winrm_upload_compression" hcl:"winrm_upload_compression"`
+	WinRMShell             *string  `mapstructure:"winrm_shell" cty:"winrm_shell" hcl:"winrm_shell"`
+	WinRMForce64Bit        *bool    `mapstructure:"winrm_force_64bit" cty:"winrm_force_64bit" hcl:"winrm_force_64bit"`
+	WinRMRunAsSystem       *bool    `mapstructure:"winrm_run_as_system" cty:"winrm_run_as_system" hcl:"winrm_run_as_system"`
+	WinRMUseNTLM           *bool    `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	WinRMUseKerberos       *bool    `mapstructure:"winrm_use_kerberos" cty:"winrm_use_kerberos" hcl:"winrm_use_kerberos"`
+	WinRMKerberosRealm     *string  `mapstructure:"winrm_kerberos_realm" cty:"winrm_kerberos_realm" hcl:"winrm_kerberos_realm"`
+	WinRMKerberosKeytab    *string  `mapstructure:"winrm_kerberos_keytab" cty:"winrm_kerberos_keytab" hcl:"winrm_kerberos_keytab"`
+	WinRMKerberosCCache    *string  `mapstructure:"winrm_kerberos_ccache" cty:"winrm_kerberos_ccache" hcl:"winrm_kerberos_ccache"`
+	WinRMKerberosConfig    *string  `mapstructure:"winrm_kerberos_config" cty:"winrm_kerberos_config" hcl:"winrm_kerberos_config"`
+	WinRMKerberosSPN       *string  `mapstructure:"winrm_kerberos_spn" cty:"winrm_kerberos_spn" hcl:"winrm_kerberos_spn"`
+	WinRMUseCredSSP        *bool    `mapstructure:"winrm_use_credssp" cty:"winrm_use_credssp" hcl:"winrm_use_credssp"`
+	WinRMClientCert        *string  `mapstructure:"winrm_client_cert" cty:"winrm_client_cert" hcl:"winrm_client_cert"`
+	WinRMClientKey         *string  `mapstructure:"winrm_client_key" cty:"winrm_client_key" hcl:"winrm_client_key"`
+	WinRMUsePSRP           *bool    `mapstructure:"winrm_use_psrp" cty:"winrm_use_psrp" hcl:"winrm_use_psrp"`
 }
 
 // FlatMapstructure returns a new FlatWinRM.
@@ -274,15 +493,36 @@ func (*WinRM) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatWinRM.
 func (*FlatWinRM) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"winrm_username": &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password": &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":     &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy": &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":     &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":  &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":  &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure": &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm": &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"winrm_username":            &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":            &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":            &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_proxy_host":          &hcldec.AttrSpec{Name: "winrm_proxy_host", Type: cty.String, Required: false},
+		"winrm_proxy_port":          &hcldec.AttrSpec{Name: "winrm_proxy_port", Type: cty.Number, Required: false},
+		"winrm_proxy_username":      &hcldec.AttrSpec{Name: "winrm_proxy_username", Type: cty.String, Required: false},
+		"winrm_proxy_password":      &hcldec.AttrSpec{Name: "winrm_proxy_password", Type: cty.String, Required: false},
+		"winrm_proxy_bypass":        &hcldec.AttrSpec{Name: "winrm_proxy_bypass", Type: cty.List(cty.String), Required: false},
+		"winrm_port":                &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":             &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":             &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":            &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_transfer_rate_limit": &hcldec.AttrSpec{Name: "winrm_transfer_rate_limit", Type: cty.Number, Required: false},
+		"winrm_upload_chunk_size":   &hcldec.AttrSpec{Name: "winrm_upload_chunk_size", Type: cty.Number, Required: false},
+		"winrm_upload_compression":  &hcldec.AttrSpec{Name: "winrm_upload_compression", Type: cty.Bool, Required: false},
+		"winrm_shell":               &hcldec.AttrSpec{Name: "winrm_shell", Type: cty.String, Required: false},
+		"winrm_force_64bit":         &hcldec.AttrSpec{Name: "winrm_force_64bit", Type: cty.Bool, Required: false},
+		"winrm_run_as_system":       &hcldec.AttrSpec{Name: "winrm_run_as_system", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":            &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"winrm_use_kerberos":        &hcldec.AttrSpec{Name: "winrm_use_kerberos", Type: cty.Bool, Required: false},
+		"winrm_kerberos_realm":      &hcldec.AttrSpec{Name: "winrm_kerberos_realm", Type: cty.String, Required: false},
+		"winrm_kerberos_keytab":     &hcldec.AttrSpec{Name: "winrm_kerberos_keytab", Type: cty.String, Required: false},
+		"winrm_kerberos_ccache":     &hcldec.AttrSpec{Name: "winrm_kerberos_ccache", Type: cty.String, Required: false},
+		"winrm_kerberos_config":     &hcldec.AttrSpec{Name: "winrm_kerberos_config", Type: cty.String, Required: false},
+		"winrm_kerberos_spn":        &hcldec.AttrSpec{Name: "winrm_kerberos_spn", Type: cty.String, Required: false},
+		"winrm_use_credssp":         &hcldec.AttrSpec{Name: "winrm_use_credssp", Type: cty.Bool, Required: false},
+		"winrm_client_cert":         &hcldec.AttrSpec{Name: "winrm_client_cert", Type: cty.String, Required: false},
+		"winrm_client_key":          &hcldec.AttrSpec{Name: "winrm_client_key", Type: cty.String, Required: false},
+		"winrm_use_psrp":            &hcldec.AttrSpec{Name: "winrm_use_psrp", Type: cty.Bool, Required: false},
 	}
 	return s
 }
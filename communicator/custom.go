@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Custom is a communicator type provided by a plugin, selected with
+// `communicator = "<name>"` once registered with RegisterCustom. StepConnect
+// waits for the machine to accept its connections, retrying Connect until it
+// succeeds.
+type Custom struct {
+	// Connect connects to the machine. It is called again after
+	// RetryInterval when it fails, until Timeout. ctx is cancelled once the
+	// wait is over, so the communicator must not depend on it.
+	Connect func(ctx context.Context, state multistep.StateBag, config *Config) (packersdk.Communicator, error)
+
+	// Timeout is how long to wait for the machine to accept connections.
+	// Defaults to 5 minutes.
+	Timeout time.Duration
+
+	// RetryInterval is the time between the connection attempts. Defaults to
+	// 5 seconds.
+	RetryInterval time.Duration
+}
+
+var (
+	customMu sync.RWMutex
+	customs  = map[string]Custom{}
+)
+
+// builtinTypes are the communicator types that can't be registered.
+var builtinTypes = map[string]bool{
+	"ssh":                    true,
+	"winrm":                  true,
+	"none":                   true,
+	"docker":                 true,
+	"dockerWindowsContainer": true,
+}
+
+// RegisterCustom registers the custom communicator type name, usually from the
+// init function of the plugin providing it. It panics if name is already
+// registered, or is a built-in type, or if Connect is nil.
+func RegisterCustom(name string, c Custom) {
+	customMu.Lock()
+	defer customMu.Unlock()
+
+	if c.Connect == nil {
+		panic("communicator: Connect of custom communicator " + name + " is nil")
+	}
+	if builtinTypes[name] {
+		panic("communicator: can't register built-in communicator " + name)
+	}
+	if _, ok := customs[name]; ok {
+		panic("communicator: custom communicator " + name + " is already registered")
+	}
+	customs[name] = c
+}
+
+// CustomTypes returns the sorted names of the registered custom communicator
+// types.
+func CustomTypes() []string {
+	customMu.RLock()
+	defer customMu.RUnlock()
+
+	names := make([]string, 0, len(customs))
+	for name := range customs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupCustom(name string) (Custom, bool) {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	c, ok := customs[name]
+	return c, ok
+}
+
+// StepConnectCustom is a multistep Step implementation that waits for a
+// custom communicator to connect.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	communicator packersdk.Communicator
+type StepConnectCustom struct {
+	Config *Config
+	Custom Custom
+
+	comm packersdk.Communicator
+}
+
+func (s *StepConnectCustom) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	timeout := s.Custom.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+
+	ui.Say(fmt.Sprintf("Waiting for the %s communicator to connect...", s.Config.Type))
+	log.Printf("Waiting for the %s communicator, up to timeout: %s", s.Config.Type, timeout)
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	comm, err := s.waitForCustom(waitCtx, state)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Interrupt detected, quitting waiting for the %s communicator.", s.Config.Type)
+			return multistep.ActionHalt
+		}
+		err := fmt.Errorf("Timeout waiting for the %s communicator: %s", s.Config.Type, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Connected with the %s communicator!", s.Config.Type))
+	// The step runs again after pause_before_connecting.
+	s.Cleanup(state)
+	s.comm = comm
+	state.Put("communicator", comm)
+	return multistep.ActionContinue
+}
+
+// Cleanup closes the communicator when it's an io.Closer.
+func (s *StepConnectCustom) Cleanup(multistep.StateBag) {
+	if c, ok := s.comm.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("[WARN] Error closing the %s communicator: %s", s.Config.Type, err)
+		}
+	}
+	s.comm = nil
+}
+
+func (s *StepConnectCustom) waitForCustom(ctx context.Context, state multistep.StateBag) (packersdk.Communicator, error) {
+	interval := s.Custom.RetryInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	for {
+		comm, err := s.Custom.Connect(ctx, state, s.Config)
+		if err == nil {
+			return comm, nil
+		}
+		log.Printf("[DEBUG] %s communicator connection error: %s", s.Config.Type, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type closingCommunicator struct {
+	packersdk.MockCommunicator
+	closed bool
+}
+
+func (c *closingCommunicator) Close() error {
+	c.closed = true
+	return nil
+}
+
+// registerTestCustom registers a custom communicator for the duration of a
+// test.
+func registerTestCustom(t *testing.T, name string, c Custom) {
+	RegisterCustom(name, c)
+	t.Cleanup(func() {
+		customMu.Lock()
+		defer customMu.Unlock()
+		delete(customs, name)
+	})
+}
+
+func TestStepConnect_custom(t *testing.T) {
+	comm := new(closingCommunicator)
+	attempts := 0
+	registerTestCustom(t, "test-custom", Custom{
+		Connect: func(ctx context.Context, state multistep.StateBag, config *Config) (packersdk.Communicator, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errors.New("not ready")
+			}
+			return comm, nil
+		},
+		RetryInterval: time.Millisecond,
+	})
+	registerTestCustom(t, "test-custom-timeout", Custom{
+		Connect: func(ctx context.Context, state multistep.StateBag, config *Config) (packersdk.Communicator, error) {
+			return nil, errors.New("not ready")
+		},
+		Timeout:       10 * time.Millisecond,
+		RetryInterval: time.Millisecond,
+	})
+
+	config := &Config{Type: "test-custom"}
+	if errs := config.Prepare(testContext(t)); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	state := testState(t)
+	step := &StepConnect{Config: config}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", state.Get("error"))
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 connection attempts, got %d", attempts)
+	}
+	if state.Get("communicator") != comm {
+		t.Fatalf("expected the communicator of the custom type, got %#v", state.Get("communicator"))
+	}
+	step.Cleanup(state)
+	if !comm.closed {
+		t.Fatal("expected the communicator to be closed")
+	}
+
+	state = testState(t)
+	step = &StepConnect{Config: &Config{Type: "test-custom-timeout"}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("error") == nil {
+		t.Fatal("expected a timeout error")
+	}
+
+	if types := CustomTypes(); len(types) != 2 || types[0] != "test-custom" {
+		t.Fatalf("unexpected custom types %v", types)
+	}
+}
+
+func TestRegisterCustom_invalid(t *testing.T) {
+	connect := func(context.Context, multistep.StateBag, *Config) (packersdk.Communicator, error) {
+		return nil, nil
+	}
+	registerTestCustom(t, "test-custom-duplicate", Custom{Connect: connect})
+
+	for _, tc := range []struct {
+		name   string
+		custom Custom
+	}{
+		{name: "ssh", custom: Custom{Connect: connect}},
+		{name: "test-custom-duplicate", custom: Custom{Connect: connect}},
+		{name: "test-custom-nil"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %s to panic", tc.name)
+				}
+			}()
+			RegisterCustom(tc.name, tc.custom)
+		}()
+	}
+
+	config := &Config{Type: "test-custom-unknown"}
+	if errs := config.Prepare(testContext(t)); len(errs) == 0 {
+		t.Fatal("expected an error for an unknown communicator type")
+	}
+}
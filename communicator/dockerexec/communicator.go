@@ -0,0 +1,162 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package dockerexec implements a communicator running commands and copying
+// files in a running container, with the exec and cp commands of the Docker
+// CLI. Files are copied as tar archives, so that the container needs neither
+// a shell nor tar for the transfers.
+package dockerexec
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/cliexec"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/tarcopy"
+)
+
+// Communicator runs commands in the container ContainerID.
+type Communicator struct {
+	// ContainerID is the ID or the name of the container.
+	ContainerID string
+
+	// Executable is the Docker CLI, docker when empty. Compatible CLIs, such
+	// as podman, may be used.
+	Executable string
+
+	// User runs the commands as this user, or uid:gid, rather than as the
+	// user of the image.
+	User string
+
+	// WorkDir runs the commands in this directory of the container, rather
+	// than in the working directory of the image.
+	WorkDir string
+
+	// Env sets these environment variables, as KEY=value, in the commands.
+	Env []string
+
+	// EntryPoint runs the commands, given as its last argument. Defaults to
+	// /bin/sh -c.
+	EntryPoint []string
+
+	// ProgressTracker, if set, is given the progress of Upload transfers.
+	// packersdk.Ui implements it to display a progress bar.
+	ProgressTracker getter.ProgressTracker
+}
+
+var _ packersdk.Communicator = new(Communicator)
+
+func (c *Communicator) executable() string {
+	if c.Executable != "" {
+		return c.Executable
+	}
+	return "docker"
+}
+
+func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	args := []string{"exec"}
+	if cmd.Stdin != nil {
+		args = append(args, "-i")
+	}
+	if cmd.Pty != nil && *cmd.Pty {
+		args = append(args, "-t")
+	}
+	if c.User != "" {
+		args = append(args, "-u", c.User)
+	}
+	if c.WorkDir != "" {
+		args = append(args, "-w", c.WorkDir)
+	}
+	for _, env := range c.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, c.ContainerID)
+	if len(c.EntryPoint) > 0 {
+		args = append(args, c.EntryPoint...)
+	} else {
+		args = append(args, "/bin/sh", "-c")
+	}
+	args = append(args, cmd.Command)
+
+	localCmd := exec.CommandContext(ctx, c.executable(), args...)
+	log.Printf("[INFO] (dockerexec communicator): Executing %s in container %s", cmd.Command, c.ContainerID)
+	return cliexec.Start(localCmd, cmd)
+}
+
+// Upload writes the content of r to the file dst of the container. The
+// directories of dst are created when missing.
+func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	if strings.HasSuffix(dst, "/") {
+		if fi == nil {
+			return fmt.Errorf("Was unable to infer file basename for upload.")
+		}
+		dst = path.Join(dst, (*fi).Name())
+	}
+	log.Printf("Uploading to container %s: %s", c.ContainerID, dst)
+
+	var mode int64 = 0644
+	if fi != nil && (*fi).Mode().IsRegular() {
+		mode = int64((*fi).Mode().Perm())
+	}
+	tracked := packersdk.TrackedTransfer(c.ProgressTracker, dst, 0, packersdk.TransferSize(fi), r)
+	defer tracked.Close()
+	return c.copyIn(func(tw *tar.Writer) error {
+		return tarcopy.WriteFile(tw, dst, tracked, mode)
+	})
+}
+
+// UploadDir copies the directory src to the directory dst of the container,
+// archived by tarcopy.WriteTree.
+func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	log.Printf("Uploading directory '%s' to container %s: '%s'", src, c.ContainerID, dst)
+	return c.copyIn(func(tw *tar.Writer) error {
+		return tarcopy.WriteTree(tw, src, dst, exclude)
+	})
+}
+
+// Download writes the content of the file src of the container to w.
+func (c *Communicator) Download(src string, w io.Writer) error {
+	log.Printf("Downloading from container %s: %s", c.ContainerID, src)
+	return c.copyOut(src, func(tr *tar.Reader) error {
+		return tarcopy.ReadFile(tr, src, w)
+	})
+}
+
+// DownloadDir copies the directory src of the container into the directory
+// dst, creating the directory src itself within dst unless src has a
+// trailing slash.
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("Downloading directory '%s' from container %s to '%s'", src, c.ContainerID, dst)
+	contents := strings.HasSuffix(src, "/") && src != "/"
+	return c.copyOut(strings.TrimSuffix(src, "/"), func(tr *tar.Reader) error {
+		return tarcopy.ExtractTree(tr, dst, contents, exclude)
+	})
+}
+
+// copyIn runs docker cp, extracting the archive written by write at the
+// root of the container.
+func (c *Communicator) copyIn(write func(*tar.Writer) error) error {
+	cmd := exec.Command(c.executable(), "cp", "-", c.ContainerID+":/")
+	if err := tarcopy.CopyIn(cmd, write); err != nil {
+		return fmt.Errorf("Error copying to container %s: %s", c.ContainerID, err)
+	}
+	return nil
+}
+
+// copyOut runs docker cp, passing the archive of src to read.
+func (c *Communicator) copyOut(src string, read func(*tar.Reader) error) error {
+	cmd := exec.Command(c.executable(), "cp", c.ContainerID+":"+src, "-")
+	if err := tarcopy.CopyOut(cmd, read); err != nil {
+		return fmt.Errorf("Error copying from container %s: %s", c.ContainerID, err)
+	}
+	return nil
+}
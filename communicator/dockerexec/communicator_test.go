@@ -0,0 +1,239 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dockerexec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeDocker is a Docker CLI whose container is the directory
+// $FAKE_DOCKER_ROOT of the host.
+const fakeDocker = `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_ROOT.log"
+case "$1" in
+exec)
+	shift
+	while [ $# -gt 0 ]; do
+		case "$1" in
+		-e) export "$2"; shift 2 ;;
+		-u|-w) shift 2 ;;
+		-i|-t) shift ;;
+		*) break ;;
+		esac
+	done
+	shift
+	cd "$FAKE_DOCKER_ROOT" && exec "$@"
+	;;
+cp)
+	if [ "$2" = "-" ]; then
+		exec tar -x -C "$FAKE_DOCKER_ROOT${3#*:}"
+	fi
+	src="${2#*:}"
+	exec tar -c -C "$FAKE_DOCKER_ROOT$(dirname "$src")" "$(basename "$src")"
+	;;
+esac
+exit 125
+`
+
+func testCommunicator(t *testing.T) (*Communicator, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake Docker CLI is a shell script")
+	}
+
+	dir := t.TempDir()
+	docker := filepath.Join(dir, "docker")
+	if err := ioutil.WriteFile(docker, []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_DOCKER_ROOT", root)
+	return &Communicator{ContainerID: "test", Executable: docker}, root
+}
+
+func TestCommunicator_Start(t *testing.T) {
+	c, root := testCommunicator(t)
+	c.User = "nobody"
+	c.Env = []string{"GREETING=hello"}
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: `cat; echo "$GREETING"; exit 3`,
+		Stdin:   strings.NewReader("stdin\n"),
+		Stdout:  &stdout,
+	}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("error starting command: %s", err)
+	}
+	cmd.Wait()
+
+	if stdout.String() != "stdin\nhello\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+	if cmd.ExitStatus() != 3 {
+		t.Fatalf("expected exit status 3, got %d", cmd.ExitStatus())
+	}
+
+	log, err := ioutil.ReadFile(root + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `exec -i -u nobody -e GREETING=hello test /bin/sh -c cat; echo "$GREETING"; exit 3` + "\n"
+	if string(log) != expected {
+		t.Fatalf("expected docker %q, got %q", expected, log)
+	}
+}
+
+func TestCommunicator_UploadDownload(t *testing.T) {
+	c, root := testCommunicator(t)
+
+	if err := c.Upload("/tmp/scripts/script.sh", strings.NewReader("echo hi"), nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(root, "tmp", "scripts", "script.sh"))
+	if err != nil || string(b) != "echo hi" {
+		t.Fatalf("unexpected uploaded file %q: %v", b, err)
+	}
+
+	var out bytes.Buffer
+	if err := c.Download("/tmp/scripts/script.sh", &out); err != nil {
+		t.Fatalf("error downloading file: %s", err)
+	}
+	if out.String() != "echo hi" {
+		t.Fatalf("unexpected downloaded file %q", out.String())
+	}
+
+	err = c.Download("/tmp/scripts", &out)
+	if err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Fatalf("expected an error downloading a directory, got %v", err)
+	}
+}
+
+// testProgressTracker records the transfer it tracks.
+type testProgressTracker struct {
+	src    string
+	total  int64
+	read   int64
+	closed bool
+}
+
+func (p *testProgressTracker) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	p.src, p.total = src, totalSize
+	return p.wrap(stream)
+}
+
+func (p *testProgressTracker) wrap(stream io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: readerFunc(func(b []byte) (int, error) {
+			n, err := stream.Read(b)
+			p.read += int64(n)
+			return n, err
+		}),
+		Closer: closerFunc(func() error {
+			p.closed = true
+			return stream.Close()
+		}),
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) { return f(b) }
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestCommunicator_UploadProgress(t *testing.T) {
+	c, _ := testCommunicator(t)
+	tracker := new(testProgressTracker)
+	c.ProgressTracker = tracker
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "script.sh")
+	if err := ioutil.WriteFile(src, []byte("echo hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, _ := f.Stat()
+
+	if err := c.Upload("/tmp/script.sh", f, &fi); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	if tracker.src != "/tmp/script.sh" || tracker.total != 7 || tracker.read != 7 || !tracker.closed {
+		t.Fatalf("unexpected progress %#v", tracker)
+	}
+}
+
+func TestCommunicator_Dirs(t *testing.T) {
+	c, root := testCommunicator(t)
+
+	src := filepath.Join(t.TempDir(), "src")
+	for name, content := range map[string]string{
+		"a.txt":     "a",
+		"skip.log":  "log",
+		"sub/b.txt": "b",
+		"cache/big": "big",
+	} {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.UploadDir("/opt", src, []string{"*.log", "cache"}); err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	if err := c.UploadDir("/srv", src+"/", nil); err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	for _, name := range []string{"opt/src/a.txt", "opt/src/sub/b.txt", "srv/a.txt", "srv/cache/big"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("expected %s to be uploaded: %s", name, err)
+		}
+	}
+	for _, name := range []string{"opt/src/skip.log", "opt/src/cache"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			t.Errorf("expected %s to be excluded", name)
+		}
+	}
+
+	dst := t.TempDir()
+	if err := c.DownloadDir("/srv", dst, []string{"sub"}); err != nil {
+		t.Fatalf("error downloading directory: %s", err)
+	}
+	if err := c.DownloadDir("/opt/src/", filepath.Join(dst, "contents"), nil); err != nil {
+		t.Fatalf("error downloading directory: %s", err)
+	}
+	for _, name := range []string{"srv/a.txt", "srv/cache/big", "contents/a.txt", "contents/sub/b.txt"} {
+		if _, err := os.Stat(filepath.Join(dst, name)); err != nil {
+			t.Errorf("expected %s to be downloaded: %s", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "srv", "sub")); err == nil {
+		t.Errorf("expected srv/sub to be excluded")
+	}
+}
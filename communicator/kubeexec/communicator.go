@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package kubeexec implements a communicator running commands and copying
+// files in a container of a Kubernetes pod, with the exec API of the pods.
+// The API is reached with kubectl, so that every authentication method of
+// kubeconfig files, credential plugins included, is supported. Files are
+// copied as tar archives, which requires tar in the container, like kubectl
+// cp.
+package kubeexec
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/cliexec"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/tarcopy"
+)
+
+// Communicator runs commands in the container Container of the pod Pod.
+type Communicator struct {
+	// Pod is the name of the pod.
+	Pod string
+
+	// Namespace is the namespace of the pod. Defaults to the namespace of
+	// the kubeconfig context.
+	Namespace string
+
+	// Container is the container of the pod. Defaults to the default
+	// container of the pod.
+	Container string
+
+	// Kubeconfig is the path of the kubeconfig file. Defaults to the file
+	// kubectl uses, $KUBECONFIG or ~/.kube/config.
+	Kubeconfig string
+
+	// Context is the kubeconfig context. Defaults to the current context.
+	Context string
+
+	// Executable is kubectl, which is found in the PATH when empty.
+	Executable string
+
+	// EntryPoint runs the commands, given as its last argument. Defaults to
+	// /bin/sh -c.
+	EntryPoint []string
+
+	// ProgressTracker, if set, is given the progress of Upload transfers.
+	// packersdk.Ui implements it to display a progress bar.
+	ProgressTracker getter.ProgressTracker
+}
+
+var _ packersdk.Communicator = new(Communicator)
+
+func (c *Communicator) executable() string {
+	if c.Executable != "" {
+		return c.Executable
+	}
+	return "kubectl"
+}
+
+// execArgs returns the arguments of kubectl running command in the
+// container.
+func (c *Communicator) execArgs(stdin bool, tty bool, command ...string) []string {
+	var args []string
+	if c.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", c.Kubeconfig)
+	}
+	if c.Context != "" {
+		args = append(args, "--context", c.Context)
+	}
+	if c.Namespace != "" {
+		args = append(args, "-n", c.Namespace)
+	}
+	args = append(args, "exec")
+	if c.Container != "" {
+		args = append(args, "-c", c.Container)
+	}
+	if stdin {
+		args = append(args, "-i")
+	}
+	if tty {
+		args = append(args, "-t")
+	}
+	args = append(args, c.Pod, "--")
+	return append(args, command...)
+}
+
+func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	command := []string{"/bin/sh", "-c"}
+	if len(c.EntryPoint) > 0 {
+		command = c.EntryPoint
+	}
+	command = append(command[:len(command):len(command)], cmd.Command)
+
+	args := c.execArgs(cmd.Stdin != nil, cmd.Pty != nil && *cmd.Pty, command...)
+	localCmd := exec.CommandContext(ctx, c.executable(), args...)
+	log.Printf("[INFO] (kubeexec communicator): Executing %s in pod %s", cmd.Command, c.Pod)
+	return cliexec.Start(localCmd, cmd)
+}
+
+// Upload writes the content of r to the file dst of the container. The
+// directories of dst are created when missing.
+func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	if strings.HasSuffix(dst, "/") {
+		if fi == nil {
+			return fmt.Errorf("Was unable to infer file basename for upload.")
+		}
+		dst = path.Join(dst, (*fi).Name())
+	}
+	log.Printf("Uploading to pod %s: %s", c.Pod, dst)
+
+	var mode int64 = 0644
+	if fi != nil && (*fi).Mode().IsRegular() {
+		mode = int64((*fi).Mode().Perm())
+	}
+	tracked := packersdk.TrackedTransfer(c.ProgressTracker, dst, 0, packersdk.TransferSize(fi), r)
+	defer tracked.Close()
+	return c.copyIn(func(tw *tar.Writer) error {
+		return tarcopy.WriteFile(tw, dst, tracked, mode)
+	})
+}
+
+// UploadDir copies the directory src to the directory dst of the container,
+// archived by tarcopy.WriteTree.
+func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	log.Printf("Uploading directory '%s' to pod %s: '%s'", src, c.Pod, dst)
+	return c.copyIn(func(tw *tar.Writer) error {
+		return tarcopy.WriteTree(tw, src, dst, exclude)
+	})
+}
+
+// Download writes the content of the file src of the container to w.
+func (c *Communicator) Download(src string, w io.Writer) error {
+	log.Printf("Downloading from pod %s: %s", c.Pod, src)
+	return c.copyOut(src, func(tr *tar.Reader) error {
+		return tarcopy.ReadFile(tr, src, w)
+	})
+}
+
+// DownloadDir copies the directory src of the container into the directory
+// dst, creating the directory src itself within dst unless src has a
+// trailing slash.
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("Downloading directory '%s' from pod %s to '%s'", src, c.Pod, dst)
+	contents := strings.HasSuffix(src, "/") && src != "/"
+	return c.copyOut(strings.TrimSuffix(src, "/"), func(tr *tar.Reader) error {
+		return tarcopy.ExtractTree(tr, dst, contents, exclude)
+	})
+}
+
+// copyIn runs tar in the container, extracting the archive written by write
+// at the root of the container.
+func (c *Communicator) copyIn(write func(*tar.Writer) error) error {
+	cmd := exec.Command(c.executable(), c.execArgs(true, false, "tar", "-x", "-f", "-", "-C", "/")...)
+	if err := tarcopy.CopyIn(cmd, write); err != nil {
+		return fmt.Errorf("Error copying to pod %s: %s", c.Pod, err)
+	}
+	return nil
+}
+
+// copyOut runs tar in the container, passing the archive of src to read.
+func (c *Communicator) copyOut(src string, read func(*tar.Reader) error) error {
+	src = path.Clean(src)
+	dir, base := path.Dir(src), path.Base(src)
+	if src == "/" {
+		dir, base = "/", "."
+	}
+
+	cmd := exec.Command(c.executable(), c.execArgs(false, false, "tar", "-c", "-f", "-", "-C", dir, base)...)
+	if err := tarcopy.CopyOut(cmd, read); err != nil {
+		return fmt.Errorf("Error copying from pod %s: %s", c.Pod, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package kubeexec
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeKubectl is a kubectl whose container is the directory
+// $FAKE_KUBECTL_ROOT of the host.
+const fakeKubectl = `#!/bin/sh
+echo "$@" >> "$FAKE_KUBECTL_ROOT.log"
+while [ "$1" != "--" ]; do shift; done
+shift
+if [ "$1" = tar ]; then
+	mode=$2; dir=$6; shift 6
+	exec tar "$mode" -f - -C "$FAKE_KUBECTL_ROOT$dir" "$@"
+fi
+cd "$FAKE_KUBECTL_ROOT" && exec "$@"
+`
+
+func testCommunicator(t *testing.T) (*Communicator, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake kubectl is a shell script")
+	}
+
+	dir := t.TempDir()
+	kubectl := filepath.Join(dir, "kubectl")
+	if err := ioutil.WriteFile(kubectl, []byte(fakeKubectl), 0755); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_KUBECTL_ROOT", root)
+	return &Communicator{Pod: "builder", Executable: kubectl}, root
+}
+
+func TestCommunicator_Start(t *testing.T) {
+	c, root := testCommunicator(t)
+	c.Namespace = "packer"
+	c.Container = "main"
+	c.Context = "ci"
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: "cat; exit 3",
+		Stdin:   strings.NewReader("stdin\n"),
+		Stdout:  &stdout,
+	}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("error starting command: %s", err)
+	}
+	cmd.Wait()
+
+	if stdout.String() != "stdin\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+	if cmd.ExitStatus() != 3 {
+		t.Fatalf("expected exit status 3, got %d", cmd.ExitStatus())
+	}
+
+	log, err := ioutil.ReadFile(root + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "--context ci -n packer exec -c main -i builder -- /bin/sh -c cat; exit 3\n"
+	if string(log) != expected {
+		t.Fatalf("expected kubectl %q, got %q", expected, log)
+	}
+}
+
+func TestCommunicator_transfers(t *testing.T) {
+	c, root := testCommunicator(t)
+
+	if err := c.Upload("/tmp/scripts/script.sh", strings.NewReader("echo hi"), nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	var out bytes.Buffer
+	if err := c.Download("/tmp/scripts/script.sh", &out); err != nil {
+		t.Fatalf("error downloading file: %s", err)
+	}
+	if out.String() != "echo hi" {
+		t.Fatalf("unexpected downloaded file %q", out.String())
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	for name, content := range map[string]string{"a.txt": "a", "skip.log": "log", "sub/b.txt": "b"} {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.UploadDir("/opt", src, []string{"*.log"}); err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "opt", "src", "skip.log")); err == nil {
+		t.Fatalf("expected skip.log to be excluded")
+	}
+
+	dst := t.TempDir()
+	if err := c.DownloadDir("/opt/src", dst, []string{"sub"}); err != nil {
+		t.Fatalf("error downloading directory: %s", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "src", "a.txt")); err != nil || string(b) != "a" {
+		t.Fatalf("unexpected downloaded file %q: %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "src", "sub")); err == nil {
+		t.Fatalf("expected sub to be excluded")
+	}
+}
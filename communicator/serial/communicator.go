@@ -0,0 +1,422 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package serial implements a line-oriented communicator driving the console
+// of a machine, such as its serial console, for the appliances which have no
+// network access until late in their provisioning. It logs in with an
+// expect-style script, then runs the commands one at a time.
+//
+// By default the console must run a POSIX shell: the communicator disables
+// its echo and prompts, frames the output of the commands with markers to
+// get their exit status, and transfers files as base64, with the base64 and
+// tar commands. Other consoles, such as the CLIs of network appliances, are
+// driven with RawCommands, their prompt marking the end of the commands.
+package serial
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	getter "github.com/hashicorp/go-getter/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/tarcopy"
+)
+
+// Config is used to configure the console.
+type Config struct {
+	// Address is the console: tcp://host:port for a raw TCP connection,
+	// telnet://host:port, unix:///path for a Unix socket, or the path of a
+	// serial or pty device, whose line settings must already be set.
+	Address string
+
+	// Login is the script logging in once connected.
+	Login []Expect
+
+	// Prompt is a regular expression matching the prompt of the console. It
+	// is waited for after Login and, with RawCommands, after each command.
+	// Defaults to `[$#>] ?$`.
+	Prompt string
+
+	// RawCommands sends the commands as is, for the consoles which don't run
+	// a POSIX shell. Their exit status is always 0, their output is the
+	// output up to the next prompt, and files can't be transferred.
+	RawCommands bool
+
+	// LineEnding ends the lines sent. Defaults to "\n".
+	LineEnding string
+
+	// Timeout is the time waited for each pattern of Login, and for the
+	// prompt. Defaults to 1 minute.
+	Timeout time.Duration
+
+	// ProgressTracker, if set, is given the progress of Upload transfers.
+	// packersdk.Ui implements it to display a progress bar.
+	ProgressTracker getter.ProgressTracker
+}
+
+// Expect is a step of a login script.
+type Expect struct {
+	// Pattern is a regular expression waited for. Send is sent right away
+	// when it is empty.
+	Pattern string
+
+	// Send is sent, followed by a line ending, once Pattern matches.
+	Send string
+}
+
+// Communicator runs commands on a console.
+type Communicator struct {
+	config  *Config
+	console *console
+	prompt  *regexp.Regexp
+
+	// mu serializes the commands, which share the console.
+	mu sync.Mutex
+	n  int
+}
+
+var _ packersdk.Communicator = new(Communicator)
+
+// New connects to the console and logs in.
+func New(ctx context.Context, config *Config) (*Communicator, error) {
+	c := &Communicator{config: config}
+	prompt := config.Prompt
+	if prompt == "" {
+		prompt = `[$#>] ?$`
+	}
+	var err error
+	if c.prompt, err = regexp.Compile(prompt); err != nil {
+		return nil, fmt.Errorf("invalid prompt: %s", err)
+	}
+	patterns := make([]*regexp.Regexp, len(config.Login))
+	for i, step := range config.Login {
+		if step.Pattern == "" {
+			continue
+		}
+		if patterns[i], err = regexp.Compile(step.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern of login step %d: %s", i+1, err)
+		}
+	}
+
+	rw, err := dial(ctx, config.Address)
+	if err != nil {
+		return nil, err
+	}
+	c.console = newConsole(rw)
+
+	if err := c.login(ctx, patterns); err != nil {
+		c.console.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Communicator) lineEnding() string {
+	if c.config.LineEnding != "" {
+		return c.config.LineEnding
+	}
+	return "\n"
+}
+
+func (c *Communicator) timeout() time.Duration {
+	if c.config.Timeout > 0 {
+		return c.config.Timeout
+	}
+	return time.Minute
+}
+
+// expect waits for re, for up to the timeout.
+func (c *Communicator) expect(ctx context.Context, re *regexp.Regexp) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	s, err := c.console.expect(ctx, re)
+	if err == context.DeadlineExceeded {
+		return s, fmt.Errorf("timeout waiting for %s on the console", re)
+	}
+	return s, err
+}
+
+func (c *Communicator) login(ctx context.Context, patterns []*regexp.Regexp) error {
+	for i, step := range c.config.Login {
+		if patterns[i] != nil {
+			log.Printf("[DEBUG] (serial communicator): Waiting for %s", step.Pattern)
+			if _, err := c.expect(ctx, patterns[i]); err != nil {
+				return err
+			}
+		}
+		if err := c.console.send(step.Send + c.lineEnding()); err != nil {
+			return err
+		}
+	}
+	if _, err := c.expect(ctx, c.prompt); err != nil {
+		return err
+	}
+	if c.config.RawCommands {
+		return nil
+	}
+
+	// The shell echoes nothing but the output of the commands from now on.
+	err := c.console.send("stty -echo 2>/dev/null; PS1=''; PS2=''" + c.lineEnding() +
+		"echo " + quoteMarker("PACKER_READY") + c.lineEnding())
+	if err != nil {
+		return err
+	}
+	_, err = c.expect(ctx, regexp.MustCompile(`(^|\n)PACKER_READY\r?\n`))
+	return err
+}
+
+// quoteMarker returns the shell word printing marker, which is split by
+// quotes so that the echo of the command line doesn't match it.
+func quoteMarker(marker string) string {
+	return marker[:1] + "''" + marker[1:]
+}
+
+// Close disconnects from the console.
+func (c *Communicator) Close() error {
+	return c.console.Close()
+}
+
+func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	if cmd.Stdin != nil {
+		log.Printf("[WARN] (serial communicator): Ignoring the standard input of %s", cmd.Command)
+	}
+	go func() {
+		status, err := c.run(ctx, cmd.Command, nil, cmd.Stdout)
+		if err != nil {
+			log.Printf("[ERROR] (serial communicator): %s: %s", cmd.Command, err)
+			status = packersdk.CmdDisconnect
+		}
+		cmd.SetExited(status)
+	}()
+	return nil
+}
+
+// run runs command, writing its output to stdout, and returns its exit
+// status. The lines written by input, if set, are sent after the command,
+// as a here-document.
+func (c *Communicator) run(ctx context.Context, command string, input func(io.Writer) error, stdout io.Writer) (int, error) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.config.RawCommands {
+		return c.runRaw(ctx, command, stdout)
+	}
+
+	c.n++
+	begin, end := fmt.Sprintf("PACKER_BEGIN_%d", c.n), fmt.Sprintf("PACKER_END_%d", c.n)
+	w := &lineWriter{c: c}
+	// The command runs in a subshell, so that exiting doesn't log out.
+	fmt.Fprintf(w, "echo %s\n(\n%s\n", quoteMarker(begin), command)
+	if input != nil {
+		if err := input(w); err != nil {
+			// The here-document must still be ended.
+			log.Printf("[ERROR] (serial communicator): %s", err)
+		}
+		fmt.Fprintf(w, "\nPACKER_EOF\n")
+	}
+	fmt.Fprintf(w, ")\necho %s $?\n", quoteMarker(end))
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	for {
+		line, err := c.console.readLine(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if strings.HasSuffix(line, begin) {
+			break
+		}
+	}
+	for {
+		line, err := c.console.readLine(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if rest := strings.TrimPrefix(line, end+" "); rest != line {
+			return strconv.Atoi(rest)
+		}
+		if _, err := io.WriteString(stdout, line+"\n"); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// runRaw sends command and writes the output up to the next prompt to
+// stdout, but for the echo of the command.
+func (c *Communicator) runRaw(ctx context.Context, command string, stdout io.Writer) (int, error) {
+	if err := c.console.send(command + c.lineEnding()); err != nil {
+		return 0, err
+	}
+	output, err := c.console.expect(ctx, c.prompt)
+	if err != nil {
+		return 0, err
+	}
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	if i := strings.IndexByte(output, '\n'); i >= 0 && strings.HasSuffix(output[:i], command) {
+		output = output[i+1:]
+	}
+	// Leave out the prompt, which ends the last line.
+	if i := strings.LastIndexByte(output, '\n'); i >= 0 {
+		output = output[:i+1]
+	} else {
+		output = ""
+	}
+	_, err = io.WriteString(stdout, output)
+	return 0, err
+}
+
+// lineWriter sends lines to the console, with its line ending.
+type lineWriter struct {
+	c   *Communicator
+	err error
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	if w.err == nil {
+		w.err = w.c.console.send(strings.ReplaceAll(string(b), "\n", w.c.lineEnding()))
+	}
+	return len(b), w.err
+}
+
+// base64Lines returns a writer encoding its input as base64, in lines of
+// 76 characters, to w.
+func base64Lines(w io.Writer) io.WriteCloser {
+	return base64.NewEncoder(base64.StdEncoding, &wrapWriter{w: w})
+}
+
+// wrapWriter wraps its input in lines of 76 characters.
+type wrapWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (w *wrapWriter) Write(b []byte) (int, error) {
+	for _, x := range b {
+		if w.col == 76 {
+			if _, err := w.w.Write([]byte{'\n'}); err != nil {
+				return 0, err
+			}
+			w.col = 0
+		}
+		if _, err := w.w.Write([]byte{x}); err != nil {
+			return 0, err
+		}
+		w.col++
+	}
+	return len(b), nil
+}
+
+// shellQuote returns s quoted for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// transfer runs command, which reads a here-document of the base64 lines
+// written by input, when set, and returns its output.
+func (c *Communicator) transfer(command string, input func(io.Writer) error) ([]byte, error) {
+	if c.config.RawCommands {
+		return nil, errors.New("files can't be transferred with raw commands")
+	}
+	var encode func(io.Writer) error
+	if input != nil {
+		// The group reads the here-document, whatever pipeline it runs.
+		command = "{ " + command + "; } <<'PACKER_EOF'"
+		encode = func(w io.Writer) error {
+			enc := base64Lines(w)
+			if err := input(enc); err != nil {
+				return err
+			}
+			return enc.Close()
+		}
+	}
+
+	var output bytes.Buffer
+	status, err := c.run(context.Background(), command, encode, &output)
+	if err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("%s exited with status %d: %s", command, status, strings.TrimSpace(output.String()))
+	}
+	return output.Bytes(), nil
+}
+
+// Upload writes the content of r to the file dst of the machine. The
+// directories of dst are created when missing.
+func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	if strings.HasSuffix(dst, "/") {
+		if fi == nil {
+			return fmt.Errorf("Was unable to infer file basename for upload.")
+		}
+		dst = path.Join(dst, (*fi).Name())
+	}
+	log.Printf("Uploading to the console: %s", dst)
+	command := fmt.Sprintf("mkdir -p %s && base64 -d > %s", shellQuote(path.Dir(dst)), shellQuote(dst))
+	tracked := packersdk.TrackedTransfer(c.config.ProgressTracker, dst, 0, packersdk.TransferSize(fi), r)
+	defer tracked.Close()
+	_, err := c.transfer(command, func(w io.Writer) error {
+		_, err := io.Copy(w, tracked)
+		return err
+	})
+	return err
+}
+
+// UploadDir copies the directory src to the directory dst of the machine,
+// archived by tarcopy.WriteTree.
+func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	log.Printf("Uploading directory '%s' to the console: '%s'", src, dst)
+	_, err := c.transfer("base64 -d | tar -x -f - -C /", func(w io.Writer) error {
+		tw := tar.NewWriter(w)
+		if err := tarcopy.WriteTree(tw, src, dst, exclude); err != nil {
+			return err
+		}
+		return tw.Close()
+	})
+	return err
+}
+
+// Download writes the content of the file src of the machine to w.
+func (c *Communicator) Download(src string, w io.Writer) error {
+	log.Printf("Downloading from the console: %s", src)
+	output, err := c.transfer(fmt.Sprintf("base64 < %s", shellQuote(src)), nil)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, base64.NewDecoder(base64.StdEncoding, bytes.NewReader(output)))
+	return err
+}
+
+// DownloadDir copies the directory src of the machine into the directory
+// dst, creating the directory src itself within dst unless src has a
+// trailing slash.
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("Downloading directory '%s' from the console to '%s'", src, dst)
+	contents := strings.HasSuffix(src, "/") && src != "/"
+	src = path.Clean(src)
+	// The archive is written to a temporary file, as the exit status of tar
+	// would be lost in a pipeline.
+	output, err := c.transfer(fmt.Sprintf(`f=$(mktemp) && tar -c -f "$f" -C %s %s && base64 < "$f"; s=$?; rm -f "$f"; (exit $s)`,
+		shellQuote(path.Dir(src)), shellQuote(path.Base(src))), nil)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(output)))
+	return tarcopy.ExtractTree(tr, dst, contents, exclude)
+}
@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serial
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// readLine reads a line from conn, a byte at a time so that nothing more is
+// consumed.
+func readLine(conn net.Conn) string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := conn.Read(b); err != nil || b[0] == '\n' {
+			return string(line)
+		}
+		line = append(line, b[0])
+	}
+}
+
+// listen serves the connections with handle, returning the address of the
+// console.
+func listen(t *testing.T, scheme string, handle func(net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}()
+	return scheme + "://" + l.Addr().String()
+}
+
+func TestCommunicator_shell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the console runs /bin/sh")
+	}
+	root := t.TempDir()
+
+	address := listen(t, "tcp", func(conn net.Conn) {
+		// The first line wakes the console up.
+		readLine(conn)
+		conn.Write([]byte("\r\nlogin: "))
+		if readLine(conn) != "packer" {
+			return
+		}
+		conn.Write([]byte("Password: "))
+		if readLine(conn) != "secret" {
+			return
+		}
+		conn.Write([]byte("Welcome\r\n$ "))
+		cmd := exec.Command("/bin/sh")
+		cmd.Dir = root
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = conn, conn, conn
+		cmd.Run()
+	})
+
+	ctx := context.Background()
+	c, err := New(ctx, &Config{
+		Address: address,
+		Login: []Expect{
+			{Send: ""},
+			{Pattern: "login: $", Send: "packer"},
+			{Pattern: "Password: $", Send: "secret"},
+		},
+		Timeout: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error connecting to the console: %s", err)
+	}
+	defer c.Close()
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: "echo hello; echo oops >&2; exit 3", Stdout: &stdout}
+	if err := c.Start(ctx, cmd); err != nil {
+		t.Fatalf("error running command: %s", err)
+	}
+	cmd.Wait()
+	if stdout.String() != "hello\noops\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+	if cmd.ExitStatus() != 3 {
+		t.Fatalf("expected exit status 3, got %d", cmd.ExitStatus())
+	}
+
+	content := strings.Repeat("stuff\n", 1000)
+	dst := filepath.Join(root, "upload", "file.txt")
+	if err := c.Upload(dst, strings.NewReader(content), nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	var out bytes.Buffer
+	if err := c.Download(dst, &out); err != nil {
+		t.Fatalf("error downloading file: %s", err)
+	}
+	if out.String() != content {
+		t.Fatalf("downloaded %d bytes, expected %d", out.Len(), len(content))
+	}
+	if err := c.Download(filepath.Join(root, "missing"), &out); err == nil {
+		t.Fatalf("expected an error downloading a missing file")
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "skip.log"), []byte("log"), 0644)
+	if err := c.UploadDir(root, src, []string{"*.log"}); err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	local := t.TempDir()
+	if err := c.DownloadDir(filepath.Join(root, "src"), local, nil); err != nil {
+		t.Fatalf("error downloading directory: %s", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(local, "src", "sub", "a.txt")); err != nil || string(b) != "a" {
+		t.Fatalf("unexpected downloaded file %q: %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(local, "src", "skip.log")); err == nil {
+		t.Fatalf("expected skip.log to be excluded")
+	}
+}
+
+func TestCommunicator_rawTelnet(t *testing.T) {
+	replies := make(chan []byte, 1)
+	address := listen(t, "telnet", func(conn net.Conn) {
+		conn.Write([]byte{telnetIAC, telnetWILL, telnetEcho, telnetIAC, telnetDO, 24})
+		reply := make([]byte, 6)
+		if _, err := conn.Read(reply); err != nil {
+			return
+		}
+		replies <- reply
+		conn.Write([]byte("router> "))
+		for {
+			line := readLine(conn)
+			if line == "" {
+				return
+			}
+			conn.Write([]byte(line + "\r\nVersion 1.0\r\nrouter> "))
+		}
+	})
+
+	ctx := context.Background()
+	c, err := New(ctx, &Config{Address: address, RawCommands: true, Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("error connecting to the console: %s", err)
+	}
+	defer c.Close()
+
+	expected := []byte{telnetIAC, telnetDO, telnetEcho, telnetIAC, telnetWONT, 24}
+	if reply := <-replies; !bytes.Equal(reply, expected) {
+		t.Fatalf("expected telnet reply %v, got %v", expected, reply)
+	}
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: "show version", Stdout: &stdout}
+	if err := c.Start(ctx, cmd); err != nil {
+		t.Fatalf("error running command: %s", err)
+	}
+	cmd.Wait()
+	if stdout.String() != "Version 1.0\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+
+	if err := c.Upload("/tmp/file", strings.NewReader(""), nil); err == nil {
+		t.Fatalf("expected an error uploading with raw commands")
+	}
+}
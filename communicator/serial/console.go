@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serial
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// dial opens the console at address: tcp://host:port, telnet://host:port,
+// unix:///path, or the path of a serial or pty device.
+func dial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	if !strings.Contains(address, "://") {
+		return os.OpenFile(address, os.O_RDWR, 0)
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	switch u.Scheme {
+	case "tcp":
+		return d.DialContext(ctx, "tcp", u.Host)
+	case "telnet":
+		conn, err := d.DialContext(ctx, "tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return newTelnetConn(conn), nil
+	case "unix":
+		return d.DialContext(ctx, "unix", u.Path)
+	}
+	return nil, fmt.Errorf("unsupported console address %s", address)
+}
+
+// console buffers the output of a console, to be matched against patterns
+// or read line by line.
+type console struct {
+	rw io.ReadWriteCloser
+
+	mu  sync.Mutex
+	buf []byte
+	err error
+	// notify is signaled when the console outputs data.
+	notify chan struct{}
+}
+
+func newConsole(rw io.ReadWriteCloser) *console {
+	c := &console{rw: rw, notify: make(chan struct{}, 1)}
+	go c.read()
+	return c
+}
+
+func (c *console) read() {
+	b := make([]byte, 4096)
+	for {
+		n, err := c.rw.Read(b)
+		c.mu.Lock()
+		c.buf = append(c.buf, b[:n]...)
+		c.err = err
+		c.mu.Unlock()
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *console) Close() error {
+	return c.rw.Close()
+}
+
+func (c *console) send(s string) error {
+	_, err := io.WriteString(c.rw, s)
+	return err
+}
+
+// next consumes the output up to the end of the match of next, which
+// returns the index of that end, or -1 while the output doesn't match.
+func (c *console) next(ctx context.Context, next func([]byte) int) (string, error) {
+	for {
+		c.mu.Lock()
+		if i := next(c.buf); i >= 0 {
+			s := string(c.buf[:i])
+			c.buf = c.buf[i:]
+			c.mu.Unlock()
+			return s, nil
+		}
+		err := c.err
+		c.mu.Unlock()
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		select {
+		case <-c.notify:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// expect consumes the output up to the end of the first match of re,
+// returning that output.
+func (c *console) expect(ctx context.Context, re *regexp.Regexp) (string, error) {
+	return c.next(ctx, func(b []byte) int {
+		if loc := re.FindIndex(b); loc != nil {
+			return loc[1]
+		}
+		return -1
+	})
+}
+
+// readLine consumes a line of output, returning it without its line ending.
+func (c *console) readLine(ctx context.Context) (string, error) {
+	line, err := c.next(ctx, func(b []byte) int {
+		if i := strings.IndexByte(string(b), '\n'); i >= 0 {
+			return i + 1
+		}
+		return -1
+	})
+	return strings.TrimRight(line, "\r\n"), err
+}
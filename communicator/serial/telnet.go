@@ -0,0 +1,110 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serial
+
+import (
+	"bytes"
+	"net"
+)
+
+// The telnet commands and options, from RFC 854 and RFC 857.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetEcho            = 1
+	telnetSuppressGoAhead = 3
+)
+
+// telnetConn is a telnet connection, which strips the commands of the server
+// from its output and refuses the options it requests, but for the server
+// echoing and suppressing go aheads, as a terminal server would.
+type telnetConn struct {
+	net.Conn
+
+	// state is the state of the parsing of the commands, the command byte
+	// being parsed.
+	state byte
+	sb    bool
+}
+
+func newTelnetConn(conn net.Conn) *telnetConn {
+	return &telnetConn{Conn: conn}
+}
+
+func (c *telnetConn) Read(b []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(b)
+		n = c.filter(b[:n])
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// filter strips the commands from b, answering them, and returns the length
+// of the data left in b.
+func (c *telnetConn) filter(b []byte) int {
+	var reply []byte
+	n := 0
+	for _, x := range b {
+		switch c.state {
+		case 0:
+			if x == telnetIAC {
+				c.state = telnetIAC
+			} else if !c.sb {
+				b[n] = x
+				n++
+			}
+		case telnetIAC:
+			c.state = 0
+			switch x {
+			case telnetIAC:
+				if !c.sb {
+					b[n] = x
+					n++
+				}
+			case telnetSB:
+				c.sb = true
+			case telnetSE:
+				c.sb = false
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				c.state = x
+			}
+		default:
+			switch c.state {
+			case telnetWILL:
+				if x == telnetEcho || x == telnetSuppressGoAhead {
+					reply = append(reply, telnetIAC, telnetDO, x)
+				} else {
+					reply = append(reply, telnetIAC, telnetDONT, x)
+				}
+			case telnetDO:
+				if x == telnetSuppressGoAhead {
+					reply = append(reply, telnetIAC, telnetWILL, x)
+				} else {
+					reply = append(reply, telnetIAC, telnetWONT, x)
+				}
+			}
+			c.state = 0
+		}
+	}
+	if len(reply) > 0 {
+		c.Conn.Write(reply)
+	}
+	return n
+}
+
+func (c *telnetConn) Write(b []byte) (int, error) {
+	escaped := bytes.ReplaceAll(b, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+	if _, err := c.Conn.Write(escaped); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -6,6 +6,7 @@
 package ssh
 
 import (
+	"bytes"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
@@ -64,6 +65,22 @@ func ReadCertificate(certificatePath string, keySigner ssh.Signer) (ssh.Signer,
 		return keySigner, fmt.Errorf("no certificate file provided")
 	}
 
+	certificate, err := ReadCertificateFile(certificatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	certSigner, err := ssh.NewCertSigner(certificate, keySigner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cert signer: %v", err)
+	}
+
+	return certSigner, nil
+}
+
+// ReadCertificateFile reads an OpenSSH user certificate and checks that it is
+// currently valid.
+func ReadCertificateFile(certificatePath string) (*ssh.Certificate, error) {
 	// Load the certificate
 	cert, err := ioutil.ReadFile(certificatePath)
 	if err != nil {
@@ -87,12 +104,67 @@ func ReadCertificate(certificatePath string, keySigner ssh.Signer) (ssh.Signer,
 		return nil, fmt.Errorf("%s not a valid cert: %v", certificatePath, err)
 	}
 
-	certSigner, err := ssh.NewCertSigner(certificate, keySigner)
+	return certificate, nil
+}
+
+// CertSignersCallback wraps a signers callback, typically the one of an SSH
+// agent, so that the certificate at certificatePath is offered for the key it
+// was issued for. The certificate is offered first, followed by the
+// unmodified signers, so that certificates already held by the agent keep
+// working.
+func CertSignersCallback(certificatePath string, signers func() ([]ssh.Signer, error)) (func() ([]ssh.Signer, error), error) {
+	certificate, err := ReadCertificateFile(certificatePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cert signer: %v", err)
+		return nil, err
 	}
+	certKey := certificate.Key.Marshal()
+
+	return func() ([]ssh.Signer, error) {
+		keySigners, err := signers()
+		if err != nil {
+			return nil, err
+		}
+
+		var certSigners []ssh.Signer
+		for _, signer := range keySigners {
+			if !bytes.Equal(signer.PublicKey().Marshal(), certKey) {
+				continue
+			}
+			certSigner, err := ssh.NewCertSigner(certificate, signer)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create cert signer: %v", err)
+			}
+			certSigners = append(certSigners, certSigner)
+		}
+		if len(certSigners) == 0 {
+			return nil, fmt.Errorf(
+				"no key matching the certificate %s was found", certificatePath)
+		}
+
+		return append(certSigners, keySigners...), nil
+	}, nil
+}
 
-	return certSigner, nil
+// KeySignersCallback wraps a signers callback, typically the one of an SSH
+// agent, so that only the signers for key are offered. This lets keys that
+// only the agent can use, such as FIDO2 security keys, be selected by their
+// key file.
+func KeySignersCallback(key ssh.PublicKey, signers func() ([]ssh.Signer, error)) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		keySigners, err := signers()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, signer := range keySigners {
+			if bytes.Equal(signer.PublicKey().Marshal(), key.Marshal()) {
+				return []ssh.Signer{signer}, nil
+			}
+		}
+		return nil, fmt.Errorf(
+			"the SSH agent does not hold the %s key %s",
+			key.Type(), ssh.FingerprintSHA256(key))
+	}
 }
 
 // FileSigner returns an ssh.Signer for a key file.
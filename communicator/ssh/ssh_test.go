@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func testSigner(t *testing.T) gossh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("error creating signer: %s", err)
+	}
+	return signer
+}
+
+// testCertificateFile issues a user certificate for key and writes it to a
+// file, returning its path.
+func testCertificateFile(t *testing.T, key gossh.PublicKey, validBefore time.Time) string {
+	cert := &gossh.Certificate{
+		Key:             key,
+		CertType:        gossh.UserCert,
+		ValidPrincipals: []string{"packer"},
+		ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, testSigner(t)); err != nil {
+		t.Fatalf("error signing certificate: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "id_ed25519-cert.pub")
+	if err := os.WriteFile(path, gossh.MarshalAuthorizedKey(cert), 0600); err != nil {
+		t.Fatalf("error writing certificate: %s", err)
+	}
+	return path
+}
+
+func TestReadCertificateFile_expired(t *testing.T) {
+	path := testCertificateFile(t, testSigner(t).PublicKey(), time.Now().Add(-time.Second))
+	if _, err := ReadCertificateFile(path); err == nil {
+		t.Fatal("expected an error for an expired certificate")
+	}
+}
+
+func TestCertSignersCallback(t *testing.T) {
+	certified, other := testSigner(t), testSigner(t)
+	path := testCertificateFile(t, certified.PublicKey(), time.Now().Add(time.Hour))
+
+	agentSigners := func() ([]gossh.Signer, error) {
+		return []gossh.Signer{other, certified}, nil
+	}
+	cb, err := CertSignersCallback(path, agentSigners)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	signers, err := cb()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(signers) != 3 {
+		t.Fatalf("expected 3 signers, got %d", len(signers))
+	}
+	cert, ok := signers[0].PublicKey().(*gossh.Certificate)
+	if !ok {
+		t.Fatalf("expected the certificate to be offered first, got %s", signers[0].PublicKey().Type())
+	}
+	if string(cert.Key.Marshal()) != string(certified.PublicKey().Marshal()) {
+		t.Fatal("certificate was paired with the wrong key")
+	}
+}
+
+func TestCertSignersCallback_noMatchingKey(t *testing.T) {
+	path := testCertificateFile(t, testSigner(t).PublicKey(), time.Now().Add(time.Hour))
+
+	cb, err := CertSignersCallback(path, func() ([]gossh.Signer, error) {
+		return []gossh.Signer{testSigner(t)}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := cb(); err == nil {
+		t.Fatal("expected an error when no key matches the certificate")
+	}
+}
+
+func TestKeySignersCallback(t *testing.T) {
+	wanted, other := testSigner(t), testSigner(t)
+	agentSigners := func() ([]gossh.Signer, error) {
+		return []gossh.Signer{other, wanted}, nil
+	}
+
+	signers, err := KeySignersCallback(wanted.PublicKey(), agentSigners)()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(signers) != 1 || signers[0] != wanted {
+		t.Fatalf("expected only the matching signer, got %v", signers)
+	}
+
+	if _, err := KeySignersCallback(testSigner(t).PublicKey(), agentSigners)(); err == nil {
+		t.Fatal("expected an error when the agent does not hold the key")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+// sshAlgorithms lists the algorithms offered to the SSH server, in order of
+// preference. Empty lists use the defaults of golang.org/x/crypto/ssh.
+type sshAlgorithms struct {
+	Ciphers           []string
+	KeyExchanges      []string
+	MACs              []string
+	HostKeyAlgorithms []string
+}
+
+// sshAlgorithmPresets are the values of ssh_algorithm_preset.
+var sshAlgorithmPresets = map[string]sshAlgorithms{
+	"default": {},
+
+	// Only algorithms approved by FIPS 140-2, as accepted by servers
+	// running in FIPS mode.
+	"fips": {
+		Ciphers: []string{
+			"aes128-gcm@openssh.com",
+			"aes256-ctr", "aes192-ctr", "aes128-ctr",
+		},
+		KeyExchanges: []string{
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256",
+		},
+		MACs: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256",
+		},
+		HostKeyAlgorithms: []string{
+			"ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521",
+			"rsa-sha2-512", "rsa-sha2-256",
+		},
+	},
+
+	// The defaults, followed by the older algorithms that are disabled by
+	// default but still required by some network equipment and old servers.
+	"legacy": {
+		Ciphers: []string{
+			"aes128-gcm@openssh.com",
+			"chacha20-poly1305@openssh.com",
+			"aes128-ctr", "aes192-ctr", "aes256-ctr",
+			"aes128-cbc", "3des-cbc",
+		},
+		KeyExchanges: []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1",
+			"diffie-hellman-group1-sha1",
+		},
+		MACs: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256",
+			"hmac-sha1", "hmac-sha1-96",
+		},
+		HostKeyAlgorithms: []string{
+			"ssh-ed25519",
+			"ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521",
+			"rsa-sha2-512", "rsa-sha2-256",
+			"ssh-rsa", "ssh-dss",
+		},
+	},
+}
+
+// sshAlgorithms returns the algorithms of the ssh_algorithm_preset, replaced
+// by the ones set explicitly.
+func (c *Config) sshAlgorithms() sshAlgorithms {
+	algos := sshAlgorithmPresets[c.SSHAlgorithmPreset]
+	if len(c.SSHCiphers) != 0 {
+		algos.Ciphers = c.SSHCiphers
+	}
+	if len(c.SSHKEXAlgos) != 0 {
+		algos.KeyExchanges = c.SSHKEXAlgos
+	}
+	if len(c.SSHMACs) != 0 {
+		algos.MACs = c.SSHMACs
+	}
+	if len(c.SSHHostKeyAlgos) != 0 {
+		algos.HostKeyAlgorithms = c.SSHHostKeyAlgos
+	}
+	return algos
+}
@@ -0,0 +1,406 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// sshHostConfig holds the settings read from an OpenSSH client configuration
+// file for a host. Empty fields were not set by the file.
+type sshHostConfig struct {
+	HostName      string
+	User          string
+	Port          int
+	IdentityFiles []string
+	// ProxyJump is the comma separated list of jump hosts, or "none".
+	ProxyJump string
+}
+
+// maxSSHConfigIncludeDepth bounds nested Include directives, like OpenSSH.
+const maxSSHConfigIncludeDepth = 16
+
+// readSSHHostConfig reads the settings applying to host from the OpenSSH
+// client configuration file at path. As with OpenSSH, the first value found
+// for a keyword wins, except for IdentityFile which accumulates. Only the
+// keywords used by Packer are read; Match blocks other than "Match all" and
+// "Match host" never apply.
+func readSSHHostConfig(path string, host string) (*sshHostConfig, error) {
+	path, err := pathing.ExpandUser(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &sshConfigParser{
+		host: strings.ToLower(host),
+		dir:  filepath.Dir(path),
+		seen: make(map[string]bool),
+	}
+	if err := p.parse(path, true, 0); err != nil {
+		return nil, err
+	}
+
+	hc := &p.config
+	hc.HostName = strings.NewReplacer("%h", host, "%%", "%").Replace(hc.HostName)
+	for i, file := range hc.IdentityFiles {
+		if hc.IdentityFiles[i], err = expandSSHConfigTokens(file, host, hc); err != nil {
+			return nil, fmt.Errorf("IdentityFile %q is invalid: %s", file, err)
+		}
+	}
+	return hc, nil
+}
+
+type sshConfigParser struct {
+	host   string
+	dir    string
+	config sshHostConfig
+	// seen records the keywords that already got a value.
+	seen map[string]bool
+}
+
+// parse reads the file at path. active reports whether the block containing
+// the Include directive, if any, applies to the host.
+func (p *sshConfigParser) parse(path string, active bool, depth int) error {
+	if depth > maxSSHConfigIncludeDepth {
+		return fmt.Errorf("%s: too many nested Include directives", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		keyword, args, err := splitSSHConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		if keyword == "" {
+			continue
+		}
+
+		switch keyword {
+		case "host":
+			active = p.matchHost(args)
+			continue
+		case "match":
+			active = p.matchCriteria(args)
+			continue
+		}
+		if !active {
+			continue
+		}
+
+		if err := p.set(keyword, args, depth); err != nil {
+			return fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *sshConfigParser) set(keyword string, args []string, depth int) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s requires a value", keyword)
+	}
+
+	switch keyword {
+	case "include":
+		for _, pattern := range args {
+			pattern, err := pathing.ExpandUser(pattern)
+			if err != nil {
+				return err
+			}
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(p.dir, pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return err
+			}
+			for _, match := range matches {
+				if err := p.parse(match, true, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "identityfile":
+		p.config.IdentityFiles = append(p.config.IdentityFiles, args[0])
+		return nil
+	}
+
+	if p.seen[keyword] {
+		return nil
+	}
+	switch keyword {
+	case "hostname":
+		p.config.HostName = args[0]
+	case "user":
+		p.config.User = args[0]
+	case "port":
+		port, err := strconv.Atoi(args[0])
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("Port %q is invalid", args[0])
+		}
+		p.config.Port = port
+	case "proxyjump":
+		p.config.ProxyJump = args[0]
+	default:
+		return nil
+	}
+	p.seen[keyword] = true
+	return nil
+}
+
+// matchHost reports whether the patterns of a Host line match the host. A
+// negated pattern that matches excludes the host.
+func (p *sshConfigParser) matchHost(patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+		if !matchSSHPattern(pattern, p.host) {
+			continue
+		}
+		if negate {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+func (p *sshConfigParser) matchCriteria(args []string) bool {
+	if len(args) == 1 && strings.EqualFold(args[0], "all") {
+		return true
+	}
+	if len(args) == 2 && (strings.EqualFold(args[0], "host") || strings.EqualFold(args[0], "originalhost")) {
+		return p.matchHost(strings.Split(args[1], ","))
+	}
+	log.Printf("[DEBUG] Ignoring unsupported ssh_config Match block: %s", strings.Join(args, " "))
+	return false
+}
+
+// matchSSHPattern matches s against an ssh_config pattern, where * matches
+// any sequence of characters and ? any single character.
+func matchSSHPattern(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchSSHPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// splitSSHConfigLine returns the lower-cased keyword and the arguments of a
+// configuration line. Keywords are separated from their arguments by spaces
+// or an equal sign, and arguments may be double-quoted.
+func splitSSHConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end == -1 {
+		return strings.ToLower(line), nil, nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	for rest != "" {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				return "", nil, fmt.Errorf("unterminated quote")
+			}
+			args = append(args, rest[1:end+1])
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end == -1 {
+				end = len(rest)
+			}
+			args = append(args, rest[:end])
+			rest = rest[end:]
+		}
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return keyword, args, nil
+}
+
+// expandSSHConfigTokens expands ~ and the %d, %h, %p, %r, %u and %% tokens
+// of an ssh_config path.
+func expandSSHConfigTokens(s string, host string, hc *sshHostConfig) (string, error) {
+	s, err := pathing.ExpandUser(s)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	hostName := hc.HostName
+	if hostName == "" {
+		hostName = host
+	}
+	port := hc.Port
+	if port == 0 {
+		port = 22
+	}
+	remoteUser := hc.User
+	if remoteUser == "" {
+		remoteUser = u.Username
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", fmt.Errorf("trailing %%")
+		}
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'd':
+			b.WriteString(u.HomeDir)
+		case 'h':
+			b.WriteString(hostName)
+		case 'p':
+			b.WriteString(strconv.Itoa(port))
+		case 'r':
+			b.WriteString(remoteUser)
+		case 'u':
+			b.WriteString(u.Username)
+		default:
+			return "", fmt.Errorf("unknown token %%%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// sshIdentitySigners returns the signers of the identity files that can be
+// read. Missing files and keys protected by a passphrase are skipped.
+func sshIdentitySigners(files []string) []gossh.Signer {
+	var signers []gossh.Signer
+	for _, file := range files {
+		key, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("[DEBUG] Skipping ssh_config identity file: %s", err)
+			continue
+		}
+		signer, err := gossh.ParsePrivateKey(key)
+		if err != nil {
+			log.Printf("[DEBUG] Skipping ssh_config identity file %s: %s", file, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers
+}
+
+// sshProxyJumpHops returns the hops of the ProxyJump setting hc, each one
+// being itself configured by the ssh_config file. The hops authenticate with
+// their identity files and the SSH agent, when one is running.
+func (c *Config) sshProxyJumpHops(hc *sshHostConfig) ([]ssh.BastionHop, error) {
+	if hc.ProxyJump == "" || strings.EqualFold(hc.ProxyJump, "none") {
+		return nil, nil
+	}
+
+	var hops []ssh.BastionHop
+	for _, jump := range strings.Split(hc.ProxyJump, ",") {
+		jump = strings.TrimPrefix(jump, "ssh://")
+		username, host, port := "", jump, 0
+		if i := strings.LastIndex(host, "@"); i != -1 {
+			username, host = host[:i], host[i+1:]
+		}
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host = h
+			if port, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("ProxyJump %q has an invalid port", jump)
+			}
+		}
+		if host == "" {
+			return nil, fmt.Errorf("ProxyJump %q is invalid", jump)
+		}
+
+		jc, err := readSSHHostConfig(c.SSHConfigFile, host)
+		if err != nil {
+			return nil, err
+		}
+		if jc.HostName != "" {
+			host = jc.HostName
+		}
+		if port == 0 {
+			port = jc.Port
+		}
+		if port == 0 {
+			port = 22
+		}
+		if username == "" {
+			username = jc.User
+		}
+		if username == "" {
+			username = c.SSHUsername
+		}
+
+		var auth []gossh.AuthMethod
+		if signers := sshIdentitySigners(jc.IdentityFiles); len(signers) > 0 {
+			auth = append(auth, gossh.PublicKeys(signers...))
+		}
+		if agentAuth, err := sshAgentAuth(""); err == nil {
+			auth = append(auth, agentAuth)
+		}
+
+		hops = append(hops, ssh.BastionHop{
+			Proto: "tcp",
+			Addr:  packernet.HostPort(host, port),
+			Config: &gossh.ClientConfig{
+				User:            username,
+				Auth:            auth,
+				HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			},
+		})
+	}
+	return hops, nil
+}
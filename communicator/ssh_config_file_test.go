@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadSSHHostConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := `# Operator configuration
+Include conf.d/*.conf
+
+Host build-* !build-skip
+    HostName %h.internal
+    User builder
+    Port=2222
+    IdentityFile "` + dir + `/id_%r"
+
+Host build-vm
+    User ignored
+    ProxyJump jump@bastion:2022
+
+Match host build-skip
+    User matched
+
+Host *
+    IdentityFile ` + dir + `/id_default
+    Port 22
+`
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	included := "Host other\n    User other\n"
+	if err := os.WriteFile(filepath.Join(dir, "conf.d", "a.conf"), []byte(included), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host     string
+		expected sshHostConfig
+	}{
+		{"build-vm", sshHostConfig{
+			HostName:      "build-vm.internal",
+			User:          "builder",
+			Port:          2222,
+			IdentityFiles: []string{dir + "/id_builder", dir + "/id_default"},
+			ProxyJump:     "jump@bastion:2022",
+		}},
+		{"build-skip", sshHostConfig{
+			User:          "matched",
+			Port:          22,
+			IdentityFiles: []string{dir + "/id_default"},
+		}},
+		{"other", sshHostConfig{
+			User:          "other",
+			Port:          22,
+			IdentityFiles: []string{dir + "/id_default"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			hc, err := readSSHHostConfig(filepath.Join(dir, "config"), tt.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*hc, tt.expected) {
+				t.Fatalf("expected %#v, got %#v", tt.expected, *hc)
+			}
+		})
+	}
+}
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		keyword  string
+		args     []string
+		hasError bool
+	}{
+		{"", "", nil, false},
+		{"  # comment", "", nil, false},
+		{"Host a b", "host", []string{"a", "b"}, false},
+		{"Port=22", "port", []string{"22"}, false},
+		{"User = root", "user", []string{"root"}, false},
+		{`IdentityFile "~/my keys/id"`, "identityfile", []string{"~/my keys/id"}, false},
+		{`IdentityFile "~/id`, "", nil, true},
+	}
+	for _, tt := range tests {
+		keyword, args, err := splitSSHConfigLine(tt.line)
+		if (err != nil) != tt.hasError {
+			t.Fatalf("%q: unexpected error %v", tt.line, err)
+		}
+		if keyword != tt.keyword || !reflect.DeepEqual(args, tt.args) {
+			t.Fatalf("%q: expected %q %q, got %q %q", tt.line, tt.keyword, tt.args, keyword, args)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package sshkey
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/blowfish"
+)
+
+// bcryptPBKDF derives a key of keyLen bytes from password and salt with the
+// bcrypt_pbkdf function of OpenBSD, used by OpenSSH to encrypt private keys.
+// See https://github.com/openssh/openssh-portable/blob/master/openbsd-compat/bcrypt_pbkdf.c
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	if rounds < 1 {
+		return nil, errors.New("sshkey: bcrypt_pbkdf rounds must be at least 1")
+	}
+	if len(password) == 0 || len(salt) == 0 || keyLen <= 0 || keyLen > 1024 {
+		return nil, errors.New("sshkey: invalid bcrypt_pbkdf parameters")
+	}
+
+	const hashLen = 32
+	blocks := (keyLen + hashLen - 1) / hashLen
+	key := make([]byte, blocks*hashLen)
+
+	sha2pass := sha512.Sum512(password)
+	countSalt := make([]byte, len(salt)+4)
+	copy(countSalt, salt)
+
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(countSalt[len(salt):], uint32(block))
+
+		sha2salt := sha512.Sum512(countSalt)
+		tmp := bcryptHash(sha2pass[:], sha2salt[:])
+		out := tmp
+		for i := 1; i < rounds; i++ {
+			sha2salt = sha512.Sum512(tmp[:])
+			tmp = bcryptHash(sha2pass[:], sha2salt[:])
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		// The output is spread over the key, block after block.
+		for i, b := range out {
+			key[i*blocks+block-1] = b
+		}
+	}
+	return key[:keyLen], nil
+}
+
+// bcryptHash is the core hash of bcrypt_pbkdf: it encrypts a constant with a
+// Blowfish state expanded from both of its inputs.
+func bcryptHash(sha2pass, sha2salt []byte) [32]byte {
+	c, _ := blowfish.NewSaltedCipher(sha2pass, sha2salt)
+	for i := 0; i < 64; i++ {
+		blowfish.ExpandKey(sha2salt, c)
+		blowfish.ExpandKey(sha2pass, c)
+	}
+
+	var out [32]byte
+	copy(out[:], "OxychromaticBlowfishSwatDynamite")
+	for i := 0; i < 64; i++ {
+		for j := 0; j < len(out); j += blowfish.BlockSize {
+			c.Encrypt(out[j:j+blowfish.BlockSize], out[j:j+blowfish.BlockSize])
+		}
+	}
+
+	// The words are output in little-endian order.
+	for i := 0; i < len(out); i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] = out[i+3], out[i+2], out[i+1], out[i]
+	}
+	return out
+}
@@ -0,0 +1,324 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package sshkey
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Format is an encoding of private keys.
+type Format int
+
+const (
+	// FormatOpenSSH is the format of OpenSSH private keys, written by
+	// ssh-keygen by default. Encrypted keys use aes256-ctr and bcrypt, like
+	// ssh-keygen.
+	FormatOpenSSH Format = iota
+	// FormatPEM is the traditional PEM encoding: PKCS #1 for RSA keys, SEC 1
+	// for ECDSA keys and the OpenSSL encoding for DSA keys. ED25519 keys,
+	// which have no such encoding, are encoded in PKCS #8. Keys in this
+	// format can't be encrypted, as the legacy PEM encryption isn't
+	// authenticated and is open to padding oracle attacks: encrypted keys
+	// use FormatOpenSSH.
+	FormatPEM
+	// FormatPPK is the format of PuTTY private keys, version 2, read by every
+	// PuTTY release since 0.52. Encrypted keys use aes256-cbc.
+	FormatPPK
+)
+
+var (
+	ErrUnknownFormat    = fmt.Errorf("sshkey: unknown private key format")
+	ErrUnsupportedKey   = fmt.Errorf("sshkey: unsupported private key type")
+	ErrUnencryptablePEM = fmt.Errorf("sshkey: keys can't be encrypted in the PEM format, use the OpenSSH format")
+)
+
+// opensshKDFRounds is the number of bcrypt_pbkdf rounds used to encrypt
+// OpenSSH keys, the default of ssh-keygen.
+const opensshKDFRounds = 16
+
+// Marshal encodes the private key of the pair in format, encrypted with
+// passphrase unless it is empty. The comment is stored along with the key in
+// the OpenSSH and PPK formats.
+func (p *Pair) Marshal(format Format, passphrase string, comment string) ([]byte, error) {
+	return MarshalPrivateKey(p.Key, format, passphrase, comment)
+}
+
+// MarshalPrivateKey encodes key, a *rsa.PrivateKey, *dsa.PrivateKey,
+// *ecdsa.PrivateKey or ed25519.PrivateKey, in format. The key is encrypted
+// with passphrase unless it is empty, which FormatPEM requires. The comment
+// is stored along with the key in the OpenSSH and PPK formats.
+func MarshalPrivateKey(key interface{}, format Format, passphrase string, comment string) ([]byte, error) {
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		key = *k
+	}
+	pub, err := publicKeyOf(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatOpenSSH:
+		return marshalOpenSSH(key, pub, passphrase, comment)
+	case FormatPEM:
+		return marshalPEM(key, passphrase)
+	case FormatPPK:
+		return marshalPPK(key, pub, passphrase, comment)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func publicKeyOf(key interface{}) (ssh.PublicKey, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return ssh.NewPublicKey(&k.PublicKey)
+	case *dsa.PrivateKey:
+		return ssh.NewPublicKey(&k.PublicKey)
+	case *ecdsa.PrivateKey:
+		return ssh.NewPublicKey(&k.PublicKey)
+	case ed25519.PrivateKey:
+		return ssh.NewPublicKey(k.Public())
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// rsaPrimes returns the primes of an RSA key, along with the inverse of q
+// modulo p.
+func rsaPrimes(k *rsa.PrivateKey) (p, q, iqmp *big.Int, err error) {
+	if len(k.Primes) != 2 {
+		return nil, nil, nil, fmt.Errorf("sshkey: rsa keys with %d primes are not supported", len(k.Primes))
+	}
+	p, q = k.Primes[0], k.Primes[1]
+	return p, q, new(big.Int).ModInverse(q, p), nil
+}
+
+// marshalOpenSSH encodes key in the OpenSSH format.
+// See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key
+func marshalOpenSSH(key interface{}, pub ssh.PublicKey, passphrase string, comment string) ([]byte, error) {
+	var fields []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		p, q, iqmp, err := rsaPrimes(k)
+		if err != nil {
+			return nil, err
+		}
+		fields = ssh.Marshal(struct {
+			N, E, D, Iqmp, P, Q *big.Int
+		}{k.N, big.NewInt(int64(k.E)), k.D, iqmp, p, q})
+	case *dsa.PrivateKey:
+		fields = ssh.Marshal(struct {
+			P, Q, G, Y, X *big.Int
+		}{k.P, k.Q, k.G, k.Y, k.X})
+	case *ecdsa.PrivateKey:
+		fields = ssh.Marshal(struct {
+			Curve string
+			Q     []byte
+			D     *big.Int
+		}{
+			strings.TrimPrefix(pub.Type(), "ecdsa-sha2-"),
+			elliptic.Marshal(k.Curve, k.X, k.Y),
+			k.D,
+		})
+	case ed25519.PrivateKey:
+		fields = ssh.Marshal(struct {
+			Pub, Priv []byte
+		}{k.Public().(ed25519.PublicKey), k})
+	}
+
+	var check [4]byte
+	if _, err := cryptorand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	checkInt := binary.BigEndian.Uint32(check[:])
+	private := ssh.Marshal(struct {
+		Check1, Check2 uint32
+		Keytype        string
+	}{checkInt, checkInt, pub.Type()})
+	private = append(private, fields...)
+	private = append(private, ssh.Marshal(struct{ Comment string }{comment})...)
+
+	cipherName, kdfName, kdfOpts := "none", "none", ""
+	blockSize := 8
+	var stream cipher.Stream
+	if passphrase != "" {
+		salt := make([]byte, 16)
+		if _, err := cryptorand.Read(salt); err != nil {
+			return nil, err
+		}
+		keyIV, err := bcryptPBKDF([]byte(passphrase), salt, opensshKDFRounds, 32+aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(keyIV[:32])
+		if err != nil {
+			return nil, err
+		}
+		stream = cipher.NewCTR(block, keyIV[32:])
+
+		cipherName, kdfName = "aes256-ctr", "bcrypt"
+		kdfOpts = string(ssh.Marshal(struct {
+			Salt   string
+			Rounds uint32
+		}{string(salt), opensshKDFRounds}))
+		blockSize = aes.BlockSize
+	}
+
+	for i := 1; len(private)%blockSize != 0; i++ {
+		private = append(private, byte(i))
+	}
+	if stream != nil {
+		stream.XORKeyStream(private, private)
+	}
+
+	k := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   cipherName,
+		KdfName:      kdfName,
+		KdfOpts:      kdfOpts,
+		NumKeys:      1,
+		PubKey:       pub.Marshal(),
+		PrivKeyBlock: private,
+	}
+
+	const opensshV1Magic = "openssh-key-v1\x00"
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte(opensshV1Magic), ssh.Marshal(k)...),
+	}), nil
+}
+
+// marshalPEM encodes key in the traditional PEM format, unencrypted.
+func marshalPEM(key interface{}, passphrase string) ([]byte, error) {
+	if passphrase != "" {
+		return nil, ErrUnencryptablePEM
+	}
+
+	var blockType string
+	var der []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		blockType, der = "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k)
+	case *dsa.PrivateKey:
+		blockType = "DSA PRIVATE KEY"
+		der, err = marshalDSAPrivateKey(k)
+	case *ecdsa.PrivateKey:
+		blockType = "EC PRIVATE KEY"
+		der, err = x509.MarshalECPrivateKey(k)
+	case ed25519.PrivateKey:
+		blockType = "PRIVATE KEY"
+		der, err = x509.MarshalPKCS8PrivateKey(k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), nil
+}
+
+// marshalPPK encodes key in the PuTTY format, version 2.
+// See https://the.earth.li/~sgtatham/putty/0.76/htmldoc/AppendixC.html
+func marshalPPK(key interface{}, pub ssh.PublicKey, passphrase string, comment string) ([]byte, error) {
+	var private []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		p, q, iqmp, err := rsaPrimes(k)
+		if err != nil {
+			return nil, err
+		}
+		private = ssh.Marshal(struct {
+			D, P, Q, Iqmp *big.Int
+		}{k.D, p, q, iqmp})
+	case *dsa.PrivateKey:
+		private = ssh.Marshal(struct{ X *big.Int }{k.X})
+	case *ecdsa.PrivateKey:
+		private = ssh.Marshal(struct{ D *big.Int }{k.D})
+	case ed25519.PrivateKey:
+		private = ssh.Marshal(struct{ Seed []byte }{k.Seed()})
+	}
+
+	encryption := "none"
+	if passphrase != "" {
+		encryption = "aes256-cbc"
+		// Like PuTTY, pad with the hash of the private blob, which avoids
+		// a known plaintext at its end.
+		if pad := len(private) % aes.BlockSize; pad != 0 {
+			sum := sha1.Sum(private)
+			private = append(private, sum[:aes.BlockSize-pad]...)
+		}
+	}
+
+	macKey := sha1.Sum([]byte("putty-private-key-file-mac-key" + passphrase))
+	mac := hmac.New(sha1.New, macKey[:])
+	mac.Write(ssh.Marshal(struct {
+		Algorithm, Encryption, Comment string
+		Public, Private                []byte
+	}{pub.Type(), encryption, comment, pub.Marshal(), private}))
+
+	if passphrase != "" {
+		var key []byte
+		for i := 0; i < 2; i++ {
+			h := sha1.New()
+			binary.Write(h, binary.BigEndian, uint32(i))
+			h.Write([]byte(passphrase))
+			key = h.Sum(key)
+		}
+		block, err := aes.NewCipher(key[:32])
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(private, private)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PuTTY-User-Key-File-2: %s\n", pub.Type())
+	fmt.Fprintf(&b, "Encryption: %s\n", encryption)
+	fmt.Fprintf(&b, "Comment: %s\n", comment)
+	writePPKLines(&b, "Public-Lines", pub.Marshal())
+	writePPKLines(&b, "Private-Lines", private)
+	fmt.Fprintf(&b, "Private-MAC: %s\n", hex.EncodeToString(mac.Sum(nil)))
+	return b.Bytes(), nil
+}
+
+// writePPKLines writes data in base64, in lines of 64 characters preceded by
+// their count.
+func writePPKLines(b *bytes.Buffer, header string, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	lines := (len(encoded) + 63) / 64
+	fmt.Fprintf(b, "%s: %d\n", header, lines)
+	for i := 0; i < len(encoded); i += 64 {
+		end := i + 64
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		b.WriteString(encoded[i:end])
+		b.WriteByte('\n')
+	}
+}
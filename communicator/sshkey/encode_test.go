@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package sshkey
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestBcryptPBKDF(t *testing.T) {
+	// Test vector of the bcrypt_pbkdf implementation of OpenBSD.
+	got, err := bcryptPBKDF([]byte("password"), []byte("salt"), 4, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "5bbf0cc293587f1c3635555c27796598d47e579071bf427e9d8fbe842aba34d9"
+	if hex.EncodeToString(got) != want {
+		t.Fatalf("bcryptPBKDF() = %x, want %s", got, want)
+	}
+}
+
+func TestPair_Marshal(t *testing.T) {
+	tests := []struct {
+		t    Algorithm
+		bits int
+	}{
+		{RSA, 2048},
+		{DSA, 1024},
+		{ECDSA, 256},
+		{ECDSA, 384},
+		{ECDSA, 521},
+		{ED25519, 0},
+	}
+	for _, tt := range tests {
+		pair, err := GeneratePair(tt.t, nil, tt.bits)
+		if err != nil {
+			t.Fatalf("GeneratePair(%s, %d) error = %v", tt.t, tt.bits, err)
+		}
+		want, _, _, _, err := ssh.ParseAuthorizedKey(pair.Public)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, format := range []Format{FormatOpenSSH, FormatPEM} {
+			for _, passphrase := range []string{"", "secret"} {
+				if format == FormatPEM && passphrase != "" {
+					if _, err := pair.Marshal(format, passphrase, ""); err != ErrUnencryptablePEM {
+						t.Fatalf("Marshal() error = %v, want %v", err, ErrUnencryptablePEM)
+					}
+					continue
+				}
+
+				encoded, err := pair.Marshal(format, passphrase, "packer")
+				if err != nil {
+					t.Fatalf("%s %d: Marshal(%d, %q) error = %v", tt.t, tt.bits, format, passphrase, err)
+				}
+				if tt.t == DSA && format == FormatOpenSSH {
+					// The ssh package can't parse dsa keys in this format.
+					continue
+				}
+				var signer ssh.Signer
+				if passphrase == "" {
+					signer, err = ssh.ParsePrivateKey(encoded)
+				} else {
+					if _, err := ssh.ParsePrivateKey(encoded); err == nil {
+						t.Fatalf("%s %d: Marshal(%d) key is not encrypted", tt.t, tt.bits, format)
+					}
+					signer, err = ssh.ParsePrivateKeyWithPassphrase(encoded, []byte(passphrase))
+				}
+				if err != nil {
+					t.Fatalf("%s %d: Marshal(%d, %q) key can't be parsed: %v\n%s", tt.t, tt.bits, format, passphrase, err, encoded)
+				}
+				if !bytes.Equal(signer.PublicKey().Marshal(), want.Marshal()) {
+					t.Fatalf("%s %d: Marshal(%d, %q) wrong public key", tt.t, tt.bits, format, passphrase)
+				}
+			}
+		}
+	}
+}
+
+func TestPair_Marshal_ppk(t *testing.T) {
+	pair, err := GeneratePair(ED25519, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := pair.Key.(interface{ Seed() []byte }).Seed()
+
+	for _, passphrase := range []string{"", "secret"} {
+		encoded, err := pair.Marshal(FormatPPK, passphrase, "packer")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		headers, public, private := parsePPK(t, string(encoded))
+		if headers["PuTTY-User-Key-File-2"] != ssh.KeyAlgoED25519 {
+			t.Fatalf("wrong key type: %v", headers)
+		}
+		if headers["Comment"] != "packer" {
+			t.Fatalf("wrong comment: %v", headers)
+		}
+		pub, err := ssh.ParsePublicKey(public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _, _, _, _ := ssh.ParseAuthorizedKey(pair.Public)
+		if !bytes.Equal(pub.Marshal(), want.Marshal()) {
+			t.Fatal("wrong public key")
+		}
+
+		encryption := "none"
+		if passphrase != "" {
+			encryption = "aes256-cbc"
+			var key []byte
+			for i := 0; i < 2; i++ {
+				h := sha1.New()
+				binary.Write(h, binary.BigEndian, uint32(i))
+				h.Write([]byte(passphrase))
+				key = h.Sum(key)
+			}
+			block, _ := aes.NewCipher(key[:32])
+			cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(private, private)
+		}
+		if headers["Encryption"] != encryption {
+			t.Fatalf("wrong encryption: %v", headers)
+		}
+
+		var priv struct {
+			Seed []byte
+			Rest []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(private, &priv); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(priv.Seed, seed) {
+			t.Fatal("wrong private key")
+		}
+
+		macKey := sha1.Sum([]byte("putty-private-key-file-mac-key" + passphrase))
+		mac := hmac.New(sha1.New, macKey[:])
+		mac.Write(ssh.Marshal(struct {
+			Algorithm, Encryption, Comment string
+			Public, Private                []byte
+		}{ssh.KeyAlgoED25519, encryption, "packer", public, private}))
+		if headers["Private-MAC"] != hex.EncodeToString(mac.Sum(nil)) {
+			t.Fatal("wrong private MAC")
+		}
+	}
+}
+
+func parsePPK(t *testing.T, s string) (map[string]string, []byte, []byte) {
+	headers := map[string]string{}
+	blobs := map[string][]byte{}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		kv := strings.SplitN(lines[i], ": ", 2)
+		if len(kv) != 2 {
+			t.Fatalf("invalid line %q", lines[i])
+		}
+		headers[kv[0]] = kv[1]
+		if !strings.HasSuffix(kv[0], "-Lines") {
+			continue
+		}
+		var n int
+		for _, c := range kv[1] {
+			n = n*10 + int(c-'0')
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.Join(lines[i+1:i+1+n], ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blobs[kv[0]] = data
+		i += n
+	}
+	return headers, blobs["Public-Lines"], blobs["Private-Lines"]
+}
@@ -41,6 +41,9 @@ var (
 type Pair struct {
 	Private []byte
 	Public  []byte
+	// Key is the private key, which Marshal encodes in other formats. It is
+	// set by GeneratePair, NewPair and the PairFrom functions.
+	Key interface{}
 }
 
 func NewPair(public, private interface{}) (*Pair, error) {
@@ -62,6 +65,7 @@ func NewPair(public, private interface{}) (*Pair, error) {
 	return &Pair{
 		Private: pem.EncodeToMemory(privBlk),
 		Public:  ssh.MarshalAuthorizedKey(publicKey),
+		Key:     private,
 	}, nil
 }
 
@@ -139,12 +143,35 @@ func PairFromED25519(public ed25519.PublicKey, private ed25519.PrivateKey) (*Pai
 	return &Pair{
 		Private: pem.EncodeToMemory(privBlk),
 		Public:  ssh.MarshalAuthorizedKey(publicKey),
+		Key:     private,
 	}, nil
 }
 
 // PairFromDSA marshalls a valid pair of openssh pem for dsa keypairs.
 // x509.MarshalPKCS8PrivateKey does not know how to deal with dsa keys.
 func PairFromDSA(key *dsa.PrivateKey) (*Pair, error) {
+	kb, err := marshalDSAPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	privBlk := &pem.Block{
+		Type:    "DSA PRIVATE KEY",
+		Headers: nil,
+		Bytes:   kb,
+	}
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Pair{
+		Private: pem.EncodeToMemory(privBlk),
+		Public:  ssh.MarshalAuthorizedKey(publicKey),
+		Key:     key,
+	}, nil
+}
+
+// marshalDSAPrivateKey returns the ASN.1 encoding of OpenSSL for dsa keys.
+func marshalDSAPrivateKey(key *dsa.PrivateKey) ([]byte, error) {
 	// see https://github.com/golang/crypto/blob/7f63de1d35b0f77fa2b9faea3e7deb402a2383c8/ssh/keys.go#L1186-L1195
 	// and https://linux.die.net/man/1/dsa
 	k := struct {
@@ -162,23 +189,7 @@ func PairFromDSA(key *dsa.PrivateKey) (*Pair, error) {
 		Pub:     key.Y,
 		Priv:    key.X,
 	}
-	kb, err := asn1.Marshal(k)
-	if err != nil {
-		return nil, err
-	}
-	privBlk := &pem.Block{
-		Type:    "DSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   kb,
-	}
-	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
-	if err != nil {
-		return nil, err
-	}
-	return &Pair{
-		Private: pem.EncodeToMemory(privBlk),
-		Public:  ssh.MarshalAuthorizedKey(publicKey),
-	}, nil
+	return asn1.Marshal(k)
 }
 
 // GeneratePair generates a Private/Public key pair using algorithm t.
@@ -4,6 +4,8 @@
 package sshkey
 
 import (
+	"bytes"
+	"encoding/pem"
 	"fmt"
 
 	"golang.org/x/crypto/ssh"
@@ -12,8 +14,64 @@ import (
 func PublicKeyFromPrivate(privateKeyBytes []byte) ([]byte, error) {
 	key, err := ssh.ParsePrivateKey(privateKeyBytes)
 	if err != nil {
+		if pub, skErr := SecurityKeyPublicKey(privateKeyBytes); skErr == nil {
+			return ssh.MarshalAuthorizedKey(pub), nil
+		}
 		return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
 	}
 
 	return ssh.MarshalAuthorizedKey(key.PublicKey()), nil
 }
+
+// ErrNotSecurityKey is returned by SecurityKeyPublicKey for keys that are not
+// FIDO2 security keys.
+var ErrNotSecurityKey = fmt.Errorf("sshkey: not a security key")
+
+// IsSecurityKeyType reports whether keyType is one of the FIDO2 security key
+// types, sk-ssh-ed25519@openssh.com or sk-ecdsa-sha2-nistp256@openssh.com.
+func IsSecurityKeyType(keyType string) bool {
+	switch keyType {
+	case ssh.KeyAlgoSKED25519, ssh.KeyAlgoSKECDSA256,
+		ssh.CertAlgoSKED25519v01, ssh.CertAlgoSKECDSA256v01:
+		return true
+	}
+	return false
+}
+
+// SecurityKeyPublicKey returns the public key of an OpenSSH private key file
+// holding a FIDO2 security key. Such files only contain a handle to the key
+// stored on the hardware token, so they cannot be used to sign directly; an
+// SSH agent holding the key does the signing, and handles the touch and PIN
+// interactions.
+func SecurityKeyPublicKey(privateKeyBytes []byte) (ssh.PublicKey, error) {
+	block, _ := pem.Decode(privateKeyBytes)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil, ErrNotSecurityKey
+	}
+
+	// see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+		return nil, ErrNotSecurityKey
+	}
+	var key struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(magic):], &key); err != nil {
+		return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
+	}
+
+	pub, err := ssh.ParsePublicKey(key.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
+	}
+	if !IsSecurityKeyType(pub.Type()) {
+		return nil, ErrNotSecurityKey
+	}
+	return pub, nil
+}
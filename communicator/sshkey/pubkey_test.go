@@ -4,9 +4,12 @@
 package sshkey
 
 import (
+	"crypto/ed25519"
+	"encoding/pem"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -94,3 +97,60 @@ func TestPublicKeyFromPrivate(t *testing.T) {
 		})
 	}
 }
+
+// testSecurityKeyFile builds an OpenSSH private key file for an
+// sk-ssh-ed25519@openssh.com key. Only the public part matters here, as the
+// private part of such keys is a handle to the hardware token.
+func testSecurityKeyFile(t *testing.T) ([]byte, []byte) {
+	pub := ssh.Marshal(struct {
+		Type        string
+		PubKey      []byte
+		Application string
+	}{ssh.KeyAlgoSKED25519, make([]byte, ed25519.PublicKeySize), "ssh:"})
+
+	body := ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, pub, nil})
+
+	privKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), body...),
+	})
+
+	pubKey, err := ssh.ParsePublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privKey, ssh.MarshalAuthorizedKey(pubKey)
+}
+
+func TestSecurityKeyPublicKey(t *testing.T) {
+	privKey, pubKey := testSecurityKeyFile(t)
+
+	key, err := SecurityKeyPublicKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Type() != ssh.KeyAlgoSKED25519 {
+		t.Fatalf("unexpected key type %s", key.Type())
+	}
+
+	got, err := PublicKeyFromPrivate(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, pubKey); diff != "" {
+		t.Errorf("wrong PublicKeyFromPrivate(): %s", diff)
+	}
+
+	for _, privKey := range []string{priv_RSA, priv_ED25519} {
+		if _, err := SecurityKeyPublicKey([]byte(privKey)); err != ErrNotSecurityKey {
+			t.Errorf("expected ErrNotSecurityKey, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package ssm tunnels the communicators of builders to instances without
+// ingress, through port forwarding sessions of AWS Systems Manager Session
+// Manager. The sessions are run by the session-manager-plugin of the AWS CLI,
+// which must be installed.
+package ssm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
+)
+
+// DefaultPluginPath is the session-manager-plugin found in the PATH.
+const DefaultPluginPath = "session-manager-plugin"
+
+// portForwardingDocument is the document of the port forwarding sessions.
+const portForwardingDocument = "AWS-StartPortForwardingSession"
+
+// Session forwards a local port to a port of an instance, restarting the
+// session whenever it ends until it is closed, as idle sessions are ended by
+// Session Manager.
+type Session struct {
+	// SvcClient is the Systems Manager client starting and terminating the
+	// sessions.
+	SvcClient ssmiface.SSMAPI
+	// Region is the region of the instance.
+	Region string
+	// Profile is the shared configuration profile of the credentials used by
+	// the plugin, when they come from one.
+	Profile string
+	// Endpoint is the Systems Manager endpoint. Defaults to the endpoint of
+	// SvcClient, when it is an *ssm.SSM.
+	Endpoint string
+	// InstanceID is the instance the port is forwarded to.
+	InstanceID string
+	// LocalPort is the port forwarded. A free port between 8000 and 9000 is
+	// picked when zero.
+	LocalPort int
+	// RemotePort is the port of the instance. Defaults to 22, for SSH.
+	RemotePort int
+	// PluginPath is the session-manager-plugin. Defaults to
+	// DefaultPluginPath.
+	PluginPath string
+
+	mu        sync.Mutex
+	sessionID string
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Start starts forwarding the port, returning once the plugin is waiting
+// for connections.
+func (s *Session) Start(ctx context.Context) error {
+	plugin := s.PluginPath
+	if plugin == "" {
+		plugin = DefaultPluginPath
+	}
+	plugin, err := exec.LookPath(plugin)
+	if err != nil {
+		return fmt.Errorf("the session-manager-plugin is required for port forwarding sessions, "+
+			"see https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html: %s", err)
+	}
+	if s.RemotePort == 0 {
+		s.RemotePort = 22
+	}
+	if s.LocalPort == 0 {
+		l, err := packernet.ListenRangeConfig{Min: 8000, Max: 9000, Addr: "localhost"}.Listen(ctx)
+		if err != nil {
+			return err
+		}
+		s.LocalPort = l.Port
+		// The port is freed for the plugin, which listens on it.
+		l.Close()
+	}
+	if s.Endpoint == "" {
+		if svc, ok := s.SvcClient.(*ssm.SSM); ok {
+			s.Endpoint = svc.Endpoint
+		}
+	}
+
+	sessionCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	ready := make(chan error, 1)
+	go s.run(sessionCtx, plugin, ready)
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			s.Close()
+		}
+		return err
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+// run runs the sessions until ctx is cancelled, sending to ready nil once
+// the first one waits for connections, or its error.
+func (s *Session) run(ctx context.Context, plugin string, ready chan<- error) {
+	defer close(s.done)
+	started := false
+	for {
+		err := s.runSession(ctx, plugin, func() {
+			if !started {
+				started = true
+				ready <- nil
+			}
+		})
+		if !started {
+			ready <- err
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		log.Printf("[INFO] (ssm): Session to %s ended, starting a new one: %v", s.InstanceID, err)
+	}
+}
+
+// runSession starts a session and runs the plugin until it exits, calling
+// waiting once the plugin waits for connections.
+func (s *Session) runSession(ctx context.Context, plugin string, waiting func()) error {
+	input := &ssm.StartSessionInput{
+		DocumentName: aws.String(portForwardingDocument),
+		Target:       aws.String(s.InstanceID),
+		Parameters: map[string][]*string{
+			"portNumber":      {aws.String(strconv.Itoa(s.RemotePort))},
+			"localPortNumber": {aws.String(strconv.Itoa(s.LocalPort))},
+		},
+	}
+	output, err := s.SvcClient.StartSessionWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error starting a session to %s: %s", s.InstanceID, err)
+	}
+	s.mu.Lock()
+	s.sessionID = aws.StringValue(output.SessionId)
+	s.mu.Unlock()
+	defer s.terminate()
+	log.Printf("[INFO] (ssm): Started session %s, forwarding port %d to %s:%d",
+		aws.StringValue(output.SessionId), s.LocalPort, s.InstanceID, s.RemotePort)
+
+	outputJSON, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, plugin,
+		string(outputJSON), s.Region, "StartSession", s.Profile, string(inputJSON), s.Endpoint)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		log.Printf("[DEBUG] (ssm): %s", line)
+		if strings.Contains(line, "Waiting for connections") {
+			waiting()
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("session-manager-plugin exited: %s", err)
+	}
+	return errors.New("session-manager-plugin exited")
+}
+
+// terminate terminates the current session.
+func (s *Session) terminate() {
+	s.mu.Lock()
+	id := s.sessionID
+	s.sessionID = ""
+	s.mu.Unlock()
+	if id == "" {
+		return
+	}
+	_, err := s.SvcClient.TerminateSession(&ssm.TerminateSessionInput{SessionId: aws.String(id)})
+	if err != nil {
+		log.Printf("[WARN] (ssm): Error terminating session %s: %s", id, err)
+	}
+}
+
+// Close stops forwarding the port, terminating the session.
+func (s *Session) Close() error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	return nil
+}
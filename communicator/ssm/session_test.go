@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+
+	mu         sync.Mutex
+	inputs     []*ssm.StartSessionInput
+	terminated []string
+}
+
+func (m *mockSSM) StartSessionWithContext(_ aws.Context, in *ssm.StartSessionInput, _ ...request.Option) (*ssm.StartSessionOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, in)
+	return &ssm.StartSessionOutput{
+		SessionId:  aws.String(fmt.Sprintf("session-%d", len(m.inputs))),
+		StreamUrl:  aws.String("wss://example.com"),
+		TokenValue: aws.String("token"),
+	}, nil
+}
+
+func (m *mockSSM) TerminateSession(in *ssm.TerminateSessionInput) (*ssm.TerminateSessionOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terminated = append(m.terminated, aws.StringValue(in.SessionId))
+	return &ssm.TerminateSessionOutput{}, nil
+}
+
+// fakePlugin is a session-manager-plugin which exits once after waiting
+// for connections, so that the session is restarted.
+const fakePlugin = `#!/bin/sh
+echo "$@" >> "$0.log"
+echo "Waiting for connections..."
+if [ -e "$0.restarted" ]; then
+	exec sleep 60
+fi
+touch "$0.restarted"
+`
+
+func TestSession(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake plugin is a shell script")
+	}
+	plugin := filepath.Join(t.TempDir(), "session-manager-plugin")
+	if err := ioutil.WriteFile(plugin, []byte(fakePlugin), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &mockSSM{}
+	s := &Session{
+		SvcClient:  svc,
+		Region:     "us-east-1",
+		Endpoint:   "https://ssm.us-east-1.amazonaws.com",
+		InstanceID: "i-123",
+		LocalPort:  8022,
+		PluginPath: plugin,
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("error starting session: %s", err)
+	}
+
+	// The session is restarted once the plugin exits.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		svc.mu.Lock()
+		n := len(svc.inputs)
+		svc.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the session to be restarted, got %d sessions", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Close()
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	in := svc.inputs[0]
+	if aws.StringValue(in.DocumentName) != "AWS-StartPortForwardingSession" || aws.StringValue(in.Target) != "i-123" ||
+		aws.StringValue(in.Parameters["portNumber"][0]) != "22" || aws.StringValue(in.Parameters["localPortNumber"][0]) != "8022" {
+		t.Fatalf("unexpected session input %s", in)
+	}
+	if strings.Join(svc.terminated, ",") != "session-1,session-2" {
+		t.Fatalf("expected both sessions to be terminated, got %v", svc.terminated)
+	}
+
+	b, err := ioutil.ReadFile(plugin + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.SplitN(string(b), "\n", 2)[0]
+	if !strings.Contains(args, `"SessionId":"session-1"`) ||
+		!strings.HasSuffix(args, " us-east-1 StartSession  "+
+			`{"DocumentName":"AWS-StartPortForwardingSession","Parameters":{"localPortNumber":["8022"],"portNumber":["22"]},"Target":"i-123"}`+
+			" https://ssm.us-east-1.amazonaws.com") {
+		t.Fatalf("unexpected plugin arguments %s", args)
+	}
+}
+
+func TestSession_pluginMissing(t *testing.T) {
+	s := &Session{SvcClient: &mockSSM{}, InstanceID: "i-123", PluginPath: filepath.Join(t.TempDir(), "missing")}
+	err := s.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "session-manager-plugin is required") {
+		t.Fatalf("expected an error for the missing plugin, got %v", err)
+	}
+}
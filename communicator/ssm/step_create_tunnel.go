@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepCreateTunnel is a Packer build step forwarding a local port to the
+// instance through Session Manager, until the build ends. The local port is
+// put in the state as "ssm_local_port", for the communicator to connect to
+// localhost on it.
+type StepCreateTunnel struct {
+	SvcClient  ssmiface.SSMAPI
+	Region     string
+	Profile    string
+	LocalPort  int
+	RemotePort int
+	PluginPath string
+	// InstanceID returns the instance to forward the port to.
+	InstanceID func(multistep.StateBag) (string, error)
+
+	session *Session
+}
+
+// Run executes the Packer build step that starts the port forwarding
+// session.
+func (s *StepCreateTunnel) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	instanceID, err := s.InstanceID(state)
+	if err != nil {
+		err := fmt.Errorf("Error getting the instance to forward the port to: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.session = &Session{
+		SvcClient:  s.SvcClient,
+		Region:     s.Region,
+		Profile:    s.Profile,
+		InstanceID: instanceID,
+		LocalPort:  s.LocalPort,
+		RemotePort: s.RemotePort,
+		PluginPath: s.PluginPath,
+	}
+	ui.Say(fmt.Sprintf("Starting a Session Manager port forwarding session to %s...", instanceID))
+	if err := s.session.Start(ctx); err != nil {
+		err := fmt.Errorf("Error starting the port forwarding session: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Say(fmt.Sprintf("Forwarding localhost:%d to port %d of %s",
+		s.session.LocalPort, s.session.RemotePort, instanceID))
+	state.Put("ssm_local_port", s.session.LocalPort)
+	return multistep.ActionContinue
+}
+
+// Cleanup terminates the port forwarding session.
+func (s *StepCreateTunnel) Cleanup(state multistep.StateBag) {
+	if s.session == nil {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	ui.Say("Terminating the port forwarding session...")
+	s.session.Close()
+}
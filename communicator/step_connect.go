@@ -12,6 +12,8 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/none"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
 	gossh "golang.org/x/crypto/ssh"
 )
 
@@ -40,9 +42,15 @@ type StepConnect struct {
 	WinRMConfig func(multistep.StateBag) (*WinRMConfig, error)
 	WinRMPort   func(multistep.StateBag) (int, error)
 
+	// TrackProgress, if true, reports the progress of file uploads and
+	// downloads to the Ui, for communicators that support it. The progress
+	// is always reported to the ProgressTracker put in the state at
+	// packersdk.StateProgressTracker, if any, instead of the Ui.
+	TrackProgress bool
+
 	// CustomConnect can be set to have custom connectors for specific
 	// types. These take highest precedence so you can also override
-	// existing types.
+	// existing types, including the types registered with RegisterCustom.
 	CustomConnect map[string]multistep.Step
 
 	substep multistep.Step
@@ -65,25 +73,33 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 	typeMap := map[string]multistep.Step{
 		"none": nil,
 		"ssh": &StepConnectSSH{
-			Config:    s.Config,
-			Host:      s.Host,
-			SSHConfig: s.SSHConfig,
-			SSHPort:   s.SSHPort,
+			Config:        s.Config,
+			Host:          s.Host,
+			SSHConfig:     s.SSHConfig,
+			SSHPort:       s.SSHPort,
+			TrackProgress: s.TrackProgress,
 		},
 		"winrm": &StepConnectWinRM{
-			Config:      s.Config,
-			Host:        s.Host,
-			WinRMConfig: s.WinRMConfig,
-			WinRMPort:   s.WinRMPort,
+			Config:        s.Config,
+			Host:          s.Host,
+			WinRMConfig:   s.WinRMConfig,
+			WinRMPort:     s.WinRMPort,
+			TrackProgress: s.TrackProgress,
 		},
 	}
+	if custom, ok := lookupCustom(s.Config.Type); ok {
+		typeMap[s.Config.Type] = &StepConnectCustom{
+			Config: s.Config,
+			Custom: custom,
+		}
+	}
 	for k, v := range s.CustomConnect {
 		typeMap[k] = v
 	}
 
 	step, ok := typeMap[s.Config.Type]
 	if !ok {
-		state.Put("error", fmt.Errorf("unknown communicator type: %s", s.Config.Type))
+		state.Put("error", sdkerrors.UserErrorf("unknown communicator type: %s", s.Config.Type))
 		return multistep.ActionHalt
 	}
 
@@ -101,7 +117,9 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 		return multistep.ActionContinue
 	}
 
-	if host, err := s.Host(state); err == nil {
+	if s.Host == nil {
+		ui.Say(fmt.Sprintf("Using %s communicator to connect", s.Config.Type))
+	} else if host, err := s.Host(state); err == nil {
 		switch s.Config.Type {
 		case "ssh":
 			ui.Say(fmt.Sprintf("Using SSH communicator to connect: %s", host))
@@ -135,6 +153,11 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	// Trace the operations of the communicator when tracing is enabled
+	if comm, ok := state.GetOk("communicator"); ok && tracing.Enabled() {
+		state.Put("communicator", packersdk.TraceCommunicator(comm.(packersdk.Communicator)))
+	}
+
 	// Put communicator config into state so we can pass it to provisioners
 	// for specialized interpolation later
 	state.Put("communicator_config", s.Config)
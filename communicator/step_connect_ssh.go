@@ -18,9 +18,11 @@ import (
 
 	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/net/proxy"
@@ -31,10 +33,11 @@ import (
 // In general, you should use StepConnect.
 type StepConnectSSH struct {
 	// All the fields below are documented on StepConnect
-	Config    *Config
-	Host      func(multistep.StateBag) (string, error)
-	SSHConfig func(multistep.StateBag) (*gossh.ClientConfig, error)
-	SSHPort   func(multistep.StateBag) (int, error)
+	Config        *Config
+	Host          func(multistep.StateBag) (string, error)
+	SSHConfig     func(multistep.StateBag) (*gossh.ClientConfig, error)
+	SSHPort       func(multistep.StateBag) (int, error)
+	TrackProgress bool
 }
 
 func (s *StepConnectSSH) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -72,7 +75,7 @@ func (s *StepConnectSSH) Run(ctx context.Context, state multistep.StateBag) mult
 			state.Put("communicator", comm)
 			return multistep.ActionContinue
 		case <-timeout:
-			err := fmt.Errorf("Timeout waiting for SSH.")
+			err := sdkerrors.WrapRetryable(fmt.Errorf("Timeout waiting for SSH."))
 			state.Put("error", err)
 			ui.Error(err.Error())
 			cancel()
@@ -95,25 +98,35 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 	// Determine if we're using a bastion host, and if so, retrieve
 	// that configuration. This configuration doesn't change so we
 	// do this one before entering the retry loop.
-	var bProto, bAddr string
-	var bConf *gossh.ClientConfig
+	var hops []ssh.BastionHop
 	var pAddr string
 	var pAuth *proxy.Auth
 	if s.Config.SSHBastionHost != "" {
-		// The protocol is hardcoded for now, but may be configurable one day
-		bProto = "tcp"
-		bAddr = fmt.Sprintf(
-			"%s:%d", s.Config.SSHBastionHost, s.Config.SSHBastionPort)
-
 		conf, err := sshBastionConfig(s.Config)
 		if err != nil {
 			return nil, fmt.Errorf("Error configuring bastion: %s", err)
 		}
-		bConf = conf
+		// The protocol is hardcoded for now, but may be configurable one day
+		hops = append(hops, ssh.BastionHop{
+			Proto:  "tcp",
+			Addr:   packernet.HostPort(s.Config.SSHBastionHost, s.Config.SSHBastionPort),
+			Config: conf,
+		})
+	}
+	for _, jh := range s.Config.SSHJumpHosts {
+		conf, err := sshJumpHostConfig(jh)
+		if err != nil {
+			return nil, fmt.Errorf("Error configuring jump host %s: %s", jh.Host, err)
+		}
+		hops = append(hops, ssh.BastionHop{
+			Proto:  "tcp",
+			Addr:   packernet.HostPort(jh.Host, jh.Port),
+			Config: conf,
+		})
 	}
 
 	if s.Config.SSHProxyHost != "" {
-		pAddr = fmt.Sprintf("%s:%d", s.Config.SSHProxyHost, s.Config.SSHProxyPort)
+		pAddr = packernet.HostPort(s.Config.SSHProxyHost, s.Config.SSHProxyPort)
 		if s.Config.SSHProxyUsername != "" {
 			pAuth = new(proxy.Auth)
 			pAuth.User = s.Config.SSHProxyUsername
@@ -144,6 +157,35 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			log.Printf("[DEBUG] Error getting SSH address: %s", err)
 			continue
 		}
+		// Settings of the ssh_config file only fill in what the template
+		// leaves unset.
+		connHops := hops
+		if s.Config.SSHConfigFile != "" {
+			hc, err := readSSHHostConfig(s.Config.SSHConfigFile, host)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading ssh_config_file: %s", err)
+			}
+			s.Config.sshHostConfig = hc
+			if hc.HostName != "" {
+				host = hc.HostName
+			}
+			if s.Config.SSHUsername == "" {
+				s.Config.SSHUsername = hc.User
+			}
+			if s.Config.sshPortDefaulted && hc.Port != 0 {
+				s.Config.SSHPort = hc.Port
+			}
+			if len(hops) == 0 && pAddr == "" {
+				connHops, err = s.Config.sshProxyJumpHops(hc)
+				if err != nil {
+					return nil, fmt.Errorf("Error configuring ProxyJump of ssh_config_file: %s", err)
+				}
+			}
+			if s.Config.SSHUsername == "" {
+				return nil, fmt.Errorf("An ssh_username must be specified, either in the template or in ssh_config_file")
+			}
+		}
+
 		// store host and port in config so we can access them from provisioners
 		s.Config.SSHHost = host
 		port := s.Config.SSHPort
@@ -163,16 +205,25 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			log.Printf("[DEBUG] Error getting SSH config: %s", err)
 			continue
 		}
+		if s.Config.SSHRekeyThreshold > 0 {
+			sshConfig.RekeyThreshold = uint64(s.Config.SSHRekeyThreshold)
+		}
 
 		// Attempt to connect to SSH port
 		var connFunc func() (net.Conn, error)
-		address := fmt.Sprintf("%s:%d", host, port)
-		if bAddr != "" {
+		address := packernet.HostPort(host, port)
+		if len(connHops) > 0 {
+			bAddrs := make([]string, 0, len(connHops))
+			for _, hop := range connHops {
+				bAddrs = append(bAddrs, hop.Addr)
+			}
 			log.Printf("[INFO] connecting with SSH to host %s through bastion at %s",
-				address, bAddr)
-			// We're using a bastion host, so use the bastion connfunc
-			connFunc = ssh.BastionConnectFunc(
-				bProto, bAddr, bConf, "tcp", address)
+				address, strings.Join(bAddrs, ","))
+			// We're using bastion hosts, so use the bastion connfunc
+			connFunc = ssh.BastionChainConnectFunc(connHops, "tcp", address)
+		} else if pAddr != "" && s.Config.SSHProxyType == "http" {
+			// Connect via HTTP proxy
+			connFunc = ssh.HTTPProxyConnectFunc(pAddr, pAuth, "tcp", address)
 		} else if pAddr != "" {
 			// Connect via SOCKS5 proxy
 			connFunc = ssh.ProxyConnectFunc(pAddr, pAuth, "tcp", address)
@@ -212,35 +263,58 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			Connection:             connFunc,
 			SSHConfig:              sshConfig,
 			Pty:                    s.Config.SSHPty,
+			PtyTerm:                s.Config.SSHPtyTerm,
+			PtyWidth:               s.Config.SSHPtyWidth,
+			PtyHeight:              s.Config.SSHPtyHeight,
 			DisableAgentForwarding: s.Config.SSHDisableAgentForwarding,
 			UseSftp:                s.Config.SSHFileTransferMethod == "sftp",
+			SftpMaxPacket:          s.Config.SSHSFTPMaxPacketSize,
+			SftpConcurrency:        s.Config.SSHSFTPConcurrency,
+			SftpResume:             s.Config.SSHSFTPResume,
+			UseRsync:               s.Config.SSHRsync,
+			RsyncDelete:            s.Config.SSHRsyncDelete,
+			SymlinkPolicy:          ssh.SymlinkPolicy(s.Config.SSHSymlinkPolicy),
+			TransferRateLimit:      s.Config.SSHTransferRateLimit,
 			KeepAliveInterval:      s.Config.SSHKeepAliveInterval,
+			KeepAliveCountMax:      s.Config.SSHKeepAliveCountMax,
+			InactivityTimeout:      s.Config.SSHInactivityTimeout,
 			Timeout:                s.Config.SSHReadWriteTimeout,
 			Tunnels:                tunnels,
 		}
+		if _, ok := state.GetOk(packersdk.StateProgressTracker); ok || s.TrackProgress {
+			config.ProgressTracker = packersdk.ProgressTrackerFromState(state)
+		}
 
 		log.Printf("[INFO] Attempting SSH connection to %s...", address)
 		comm, err = ssh.New(address, config)
 		if err != nil {
 			log.Printf("[DEBUG] SSH handshake err: %s", err)
 
-			// Only count this as an attempt if we were able to attempt
-			// to authenticate. Note this is very brittle since it depends
-			// on the string of the error... but I don't see any other way.
-			if strings.Contains(err.Error(), "authenticate") {
+			// Transient errors, like the host being unreachable or slow to
+			// answer while it boots, are retried until the timeout. Others,
+			// like authentication failures, only count as an attempt.
+			if !ssh.IsTransient(err) {
 				log.Printf(
-					"[DEBUG] Detected authentication error. Increasing handshake attempts.")
-				err = fmt.Errorf("Packer experienced an authentication error "+
-					"when trying to connect via SSH. This can happen if your "+
-					"username/password are wrong. You may want to double-check"+
-					" your credentials as part of your debugging process. "+
-					"original error: %s",
-					err)
+					"[DEBUG] Detected non-transient error. Increasing handshake attempts.")
+				var authErr *ssh.AuthFailedError
+				if errors.As(err, &authErr) {
+					err = fmt.Errorf("Packer experienced an authentication error "+
+						"when trying to connect via SSH. This can happen if your "+
+						"username/password are wrong. You may want to double-check"+
+						" your credentials as part of your debugging process. "+
+						"original error: %w",
+						err)
+				}
 				handshakeAttempts += 1
 			}
 
 			if s.Config.SSHHandshakeAttempts > 0 &&
 				handshakeAttempts >= s.Config.SSHHandshakeAttempts {
+				var authErr *ssh.AuthFailedError
+				if errors.As(err, &authErr) {
+					// The credentials of the template are most likely wrong.
+					return nil, sdkerrors.WrapUser(err)
+				}
 				return nil, err
 			}
 
@@ -308,22 +382,100 @@ func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 	}
 
 	if config.SSHBastionAgentAuth {
-		authSock := os.Getenv("SSH_AUTH_SOCK")
-		if authSock == "" {
-			return nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
+		agentAuth, err := sshAgentAuth(config.SSHBastionCertificateFile)
+		if err != nil {
+			return nil, err
 		}
+		auth = append(auth, agentAuth)
+	}
+
+	return &gossh.ClientConfig{
+		User:            config.SSHBastionUsername,
+		Auth:            auth,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	}, nil
+}
+
+func sshJumpHostConfig(jh SSHJumpHost) (*gossh.ClientConfig, error) {
+	auth := make([]gossh.AuthMethod, 0, 2)
 
-		sshAgent, err := net.Dial("unix", authSock)
+	if jh.Password != "" {
+		auth = append(auth,
+			gossh.Password(jh.Password),
+			gossh.KeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(jh.Password)))
+	}
+
+	if jh.PrivateKeyFile != "" {
+		signer, err := jumpHostSigner(jh)
 		if err != nil {
-			return nil, fmt.Errorf("Cannot connect to SSH Agent socket %q: %s", authSock, err)
+			return nil, err
 		}
+		auth = append(auth, gossh.PublicKeys(signer))
+	}
 
-		auth = append(auth, gossh.PublicKeysCallback(agent.NewClient(sshAgent).Signers))
+	if jh.AgentAuth {
+		agentAuth, err := sshAgentAuth(jh.CertificateFile)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, agentAuth)
 	}
 
 	return &gossh.ClientConfig{
-		User:            config.SSHBastionUsername,
+		User:            jh.Username,
 		Auth:            auth,
 		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
 	}, nil
 }
+
+// sshAgentAuth returns an auth method using the signers of the local SSH
+// agent. When certPath is set, the certificate is offered along with the agent
+// key it was issued for.
+func sshAgentAuth(certPath string) (gossh.AuthMethod, error) {
+	signers, err := sshAgentSigners()
+	if err != nil {
+		return nil, err
+	}
+	return sshAgentCertAuth(signers, certPath)
+}
+
+// sshSecurityKeyAuth returns an auth method using the key held by the local
+// SSH agent for the FIDO2 security key pub. The agent takes care of the touch
+// and PIN interactions with the token.
+func sshSecurityKeyAuth(pub gossh.PublicKey, certPath string) (gossh.AuthMethod, error) {
+	signers, err := sshAgentSigners()
+	if err != nil {
+		return nil, fmt.Errorf("%s key requires an SSH agent: %s", pub.Type(), err)
+	}
+	return sshAgentCertAuth(helperssh.KeySignersCallback(pub, signers), certPath)
+}
+
+func sshAgentCertAuth(signers func() ([]gossh.Signer, error), certPath string) (gossh.AuthMethod, error) {
+	if certPath != "" {
+		path, err := pathing.ExpandUser(certPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error expanding path for SSH certificate: %s", err)
+		}
+		signers, err = helperssh.CertSignersCallback(path, signers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return gossh.PublicKeysCallback(signers), nil
+}
+
+func sshAgentSigners() (func() ([]gossh.Signer, error), error) {
+	authSock := os.Getenv("SSH_AUTH_SOCK")
+	if authSock == "" {
+		return nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
+	}
+
+	sshAgent, err := net.Dial("unix", authSock)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to SSH Agent socket %q: %s", authSock, err)
+	}
+
+	return agent.NewClient(sshAgent).Signers, nil
+}
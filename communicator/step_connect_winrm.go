@@ -17,8 +17,11 @@ import (
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/psrp"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/winrm"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
 	winrmcmd "github.com/masterzen/winrm"
 	"golang.org/x/net/http/httpproxy"
 )
@@ -34,10 +37,11 @@ import (
 //   communicator packersdk.Communicator
 type StepConnectWinRM struct {
 	// All the fields below are documented on StepConnect
-	Config      *Config
-	Host        func(multistep.StateBag) (string, error)
-	WinRMConfig func(multistep.StateBag) (*WinRMConfig, error)
-	WinRMPort   func(multistep.StateBag) (int, error)
+	Config        *Config
+	Host          func(multistep.StateBag) (string, error)
+	WinRMConfig   func(multistep.StateBag) (*WinRMConfig, error)
+	WinRMPort     func(multistep.StateBag) (int, error)
+	TrackProgress bool
 }
 
 func (s *StepConnectWinRM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -71,7 +75,7 @@ func (s *StepConnectWinRM) Run(ctx context.Context, state multistep.StateBag) mu
 			state.Put("communicator", comm)
 			return multistep.ActionContinue
 		case <-timeout:
-			err := fmt.Errorf("Timeout waiting for WinRM.")
+			err := sdkerrors.WrapRetryable(fmt.Errorf("Timeout waiting for WinRM."))
 			state.Put("error", err)
 			ui.Error(err.Error())
 			cancel()
@@ -153,8 +157,56 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 			}
 		}
 
+		if s.Config.WinRMUseKerberos {
+			settings := s.Config.winRMKerberosSettings()
+			settings.Username = user
+			settings.Password = password
+			s.Config.WinRMTransportDecorator = func() winrmcmd.Transporter {
+				return winrm.NewClientKerberos(settings)
+			}
+		}
+
+		if s.Config.WinRMUseCredSSP {
+			settings := s.Config.winRMCredSSPSettings()
+			settings.Username = user
+			settings.Password = password
+			s.Config.WinRMTransportDecorator = func() winrmcmd.Transporter {
+				return winrm.NewClientCredSSP(settings)
+			}
+		}
+
+		if s.Config.WinRMClientCert != "" {
+			settings, err := s.Config.winRMCertificateSettings()
+			if err != nil {
+				return nil, err
+			}
+			s.Config.WinRMTransportDecorator = func() winrmcmd.Transporter {
+				return winrm.NewClientCertificate(settings)
+			}
+		}
+
+		if s.Config.WinRMUsePSRP {
+			log.Println("[INFO] Attempting PSRP connection...")
+			comm, err = psrp.New(&psrp.Config{
+				Host:               host,
+				Port:               port,
+				Username:           user,
+				Password:           password,
+				Timeout:            s.Config.WinRMTimeout,
+				Https:              s.Config.WinRMUseSSL,
+				Insecure:           s.Config.WinRMInsecure,
+				TransportDecorator: s.Config.WinRMTransportDecorator,
+				TransferRateLimit:  s.Config.WinRMTransferRateLimit,
+			})
+			if err != nil {
+				log.Printf("[ERROR] PSRP connection err: %s", err)
+				continue
+			}
+			break
+		}
+
 		log.Println("[INFO] Attempting WinRM connection...")
-		comm, err = winrm.New(&winrm.Config{
+		winrmConfig := &winrm.Config{
 			Host:               host,
 			Port:               port,
 			Username:           user,
@@ -163,7 +215,17 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 			Https:              s.Config.WinRMUseSSL,
 			Insecure:           s.Config.WinRMInsecure,
 			TransportDecorator: s.Config.WinRMTransportDecorator,
-		})
+			TransferRateLimit:  s.Config.WinRMTransferRateLimit,
+			UploadChunkSize:    s.Config.WinRMUploadChunkSize,
+			UploadCompression:  s.Config.WinRMUploadCompression,
+			Shell:              s.Config.WinRMShell,
+			Force64Bit:         s.Config.WinRMForce64Bit,
+			RunAsSystem:        s.Config.WinRMRunAsSystem,
+		}
+		if _, ok := state.GetOk(packersdk.StateProgressTracker); ok || s.TrackProgress {
+			winrmConfig.ProgressTracker = packersdk.ProgressTrackerFromState(state)
+		}
+		comm, err = winrm.New(winrmConfig)
 		if err != nil {
 			log.Printf("[ERROR] WinRM connection err: %s", err)
 			continue
@@ -211,7 +273,7 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 // setNoProxy configures the $NO_PROXY env var
 func setNoProxy(host string, port int) error {
 	current := os.Getenv("NO_PROXY")
-	p := fmt.Sprintf("%s:%d", host, port)
+	p := packernet.HostPort(host, port)
 	if current == "" {
 		return os.Setenv("NO_PROXY", p)
 	}
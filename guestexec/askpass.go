@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// SudoPasswordProvisioner is implemented by the provisioners taking the
+// password sudo asks for on the Unix-like guests. It is the password of the
+// user the communicator connects as, unlike the elevated password, which is
+// the one of the elevated user.
+type SudoPasswordProvisioner interface {
+	SudoPassword() string
+}
+
+// ElevatedRunner returns the command running command elevated, as the
+// elevated user of p.
+//
+// On Windows, it is the runner uploaded by GenerateElevatedRunner. On the
+// Unix-like guests, the sudo password of p, when it is a
+// SudoPasswordProvisioner, is passed to sudo by a SUDO_ASKPASS helper
+// uploaded to the temporary directory, for the images disallowing NOPASSWD
+// sudo. The password is registered with the log secret filter. The command
+// returned shreds the helper when it exits, including on SIGHUP, SIGINT and
+// SIGTERM: it must be run, or the helper stays on the guest. Without sudo
+// password, command is elevated with ElevatedCommand.
+func (g *GuestCommands) ElevatedRunner(command string, p ElevatedProvisioner) (string, error) {
+	if g.GuestOSType == WindowsOSType {
+		return GenerateElevatedRunner(command, p)
+	}
+	var password string
+	if sp, ok := p.(SudoPasswordProvisioner); ok {
+		password = sp.SudoPassword()
+	}
+	if password == "" {
+		return g.ElevatedCommand(command), nil
+	}
+	packersdk.LogSecretFilter.Set(password)
+
+	helper := path.Join(g.TempDir(), fmt.Sprintf("packer-askpass-%s", uuid.TimeOrderedUUID()))
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' %s\n", shellQuote(password))
+	log.Printf("Uploading sudo askpass helper to [%s]", helper)
+	var fi os.FileInfo = askPassFileInfo{name: path.Base(helper), size: int64(len(script))}
+	if err := p.Communicator().Upload(helper, strings.NewReader(script), &fi); err != nil {
+		return "", fmt.Errorf("Error uploading sudo askpass helper: %s", err)
+	}
+
+	sudo := "sudo -A"
+	if user := p.ElevatedUser(); user != "" && user != "root" {
+		sudo += " -u " + shellQuote(user)
+	}
+	// The helper is removed by the trap on exit, set before sudo runs. The
+	// signals exit the shell with their usual status, running the trap.
+	// shred isn't installed on the BSDs and macOS, where rm -P overwrites
+	// the files.
+	cleanup := fmt.Sprintf("shred -u %[1]s 2>/dev/null || rm -P %[1]s 2>/dev/null || rm -f %[1]s", shellQuote(helper))
+	run := fmt.Sprintf("trap %s EXIT; trap 'exit 129' HUP; trap 'exit 130' INT; trap 'exit 143' TERM; "+
+		"SUDO_ASKPASS=%s %s -- sh -c %s",
+		shellQuote(cleanup), shellQuote(helper), sudo, shellQuote(command))
+	return "sh -c " + shellQuote(run), nil
+}
+
+// shellQuote single quotes s for the POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// askPassFileInfo uploads the askpass helpers executable by their owner
+// only.
+type askPassFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi askPassFileInfo) Name() string       { return fi.name }
+func (fi askPassFileInfo) Size() int64        { return fi.size }
+func (fi askPassFileInfo) Mode() os.FileMode  { return 0700 }
+func (fi askPassFileInfo) ModTime() time.Time { return time.Now() }
+func (fi askPassFileInfo) IsDir() bool        { return false }
+func (fi askPassFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type sudoProvisioner struct {
+	*packersdk.MockProvisioner
+	user, password, sudoPassword string
+}
+
+func (p *sudoProvisioner) ElevatedUser() string     { return p.user }
+func (p *sudoProvisioner) ElevatedPassword() string { return p.password }
+func (p *sudoProvisioner) SudoPassword() string     { return p.sudoPassword }
+
+func TestElevatedRunner(t *testing.T) {
+	guestCmd, err := NewGuestCommands(UnixOSType, true)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", UnixOSType)
+	}
+	comm := new(packersdk.MockCommunicator)
+	p := &sudoProvisioner{new(packersdk.MockProvisioner), "deploy", "deploy's", "it's secret"}
+	p.ProvCommunicator = comm
+
+	cmd, err := guestCmd.ElevatedRunner("echo 'hello'", p)
+	if err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+	if !strings.HasPrefix(comm.UploadPath, "/tmp/packer-askpass-") {
+		t.Fatalf("Unexpected askpass helper path: %s", comm.UploadPath)
+	}
+	if comm.UploadData != "#!/bin/sh\nprintf '%s\\n' 'it'\\''s secret'\n" {
+		t.Fatalf("Unexpected askpass helper: %s", comm.UploadData)
+	}
+	for _, part := range []string{
+		`SUDO_ASKPASS='\''` + comm.UploadPath + `'\'' sudo -A -u '\''deploy'\'' -- sh -c `,
+		`trap '\''shred -u '\''\'\'''\''` + comm.UploadPath,
+		`EXIT; trap '\''exit 129'\'' HUP; `,
+	} {
+		if !strings.Contains(cmd, part) {
+			t.Fatalf("Command %s should contain %s", cmd, part)
+		}
+	}
+	if filtered := packersdk.LogSecretFilter.FilterString("it's secret"); filtered != "<sensitive>" {
+		t.Fatalf("The password should be filtered, got %s", filtered)
+	}
+
+	// NOPASSWD sudo
+	p.sudoPassword = ""
+	cmd, err = guestCmd.ElevatedRunner("whoami", p)
+	if err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+	if cmd != "sudo whoami" {
+		t.Fatalf("Unexpected elevated command: %s", cmd)
+	}
+}
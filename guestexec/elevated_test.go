@@ -5,6 +5,7 @@ package guestexec
 
 import (
 	"regexp"
+	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -39,3 +40,48 @@ func TestProvisioner_GenerateElevatedRunner(t *testing.T) {
 		t.Fatalf("Got unexpected file: %s", path)
 	}
 }
+
+type pwshProvisioner struct {
+	*packersdk.MockProvisioner
+}
+
+func (p *pwshProvisioner) PowerShell() string { return PowerShellCore }
+
+func TestProvisioner_GenerateElevatedRunner_powerShell(t *testing.T) {
+	p := &pwshProvisioner{new(packersdk.MockProvisioner)}
+	p.Prepare(testConfig())
+	p.ProvCommunicator = new(packersdk.MockCommunicator)
+	cmd, err := GenerateElevatedRunner("whoami", p)
+	if err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+
+	matched, _ := regexp.MatchString(`^pwsh.exe -executionpolicy bypass -file "C:/Windows/Temp/packer-elevated-shell.*"$`, cmd)
+	if !matched {
+		t.Fatalf("Got unexpected command: %s", cmd)
+	}
+}
+
+type systemProvisioner struct {
+	*packersdk.MockProvisioner
+}
+
+func (p *systemProvisioner) ElevatedUser() string     { return SystemUser }
+func (p *systemProvisioner) ElevatedPassword() string { return "ignored" }
+
+func TestProvisioner_GenerateElevatedRunner_system(t *testing.T) {
+	p := &systemProvisioner{new(packersdk.MockProvisioner)}
+	p.Prepare(testConfig())
+	comm := new(packersdk.MockCommunicator)
+	p.ProvCommunicator = comm
+	if _, err := GenerateElevatedRunner("whoami", p); err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+
+	if !strings.Contains(comm.UploadData, "<UserId>SYSTEM</UserId>") {
+		t.Fatalf("Should run the task as SYSTEM")
+	}
+	if !strings.Contains(comm.UploadData, `$password = ""`) {
+		t.Fatalf("Should run the task without password")
+	}
+}
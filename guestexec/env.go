@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// envNameRe matches the names of the environment variables the shells of
+// every guest OS can set.
+var envNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// EnvPrefix returns the prefix of the commands of the guest setting the
+// environment variables vars: the one of PosixEnvPrefix for the Unix-like
+// guests, and of CmdEnvPrefix for Windows, where the communicators run the
+// commands with cmd.exe. The variables aren't passed through sudo.
+func (g *GuestCommands) EnvPrefix(vars map[string]string) (string, error) {
+	if g.GuestOSType == WindowsOSType {
+		return CmdEnvPrefix(vars)
+	}
+	return PosixEnvPrefix(vars)
+}
+
+// PosixEnvPrefix returns the prefix of the commands of the POSIX shells
+// exporting the environment variables vars, with their values single quoted,
+// like `export FOO='bar'; `.
+func PosixEnvPrefix(vars map[string]string) (string, error) {
+	names, err := envNames(vars)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	exports := make([]string, 0, len(names))
+	for _, name := range names {
+		exports = append(exports, name+"="+shellQuote(vars[name]))
+	}
+	return "export " + strings.Join(exports, " ") + "; ", nil
+}
+
+// CmdEnvPrefix returns the prefix of the commands of cmd.exe setting the
+// environment variables vars, like `set "FOO=bar" && `. The code page is
+// switched to UTF-8 first when a value isn't ASCII. cmd.exe has no escape
+// for the double quotes and the percent signs: values containing them, or
+// line breaks, are an error, and are set with PowerShellEnvPrefix instead.
+//
+// cmd.exe expands the variables when it reads the command line, so the
+// commands of the same line don't see the values set with %FOO%, but the
+// programs and scripts they run do.
+func CmdEnvPrefix(vars map[string]string) (string, error) {
+	names, err := envNames(vars)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	for _, name := range names {
+		if strings.ContainsAny(vars[name], "\"%\r\n") {
+			return "", fmt.Errorf("The value of the environment variable %s can't be set with cmd.exe", name)
+		}
+	}
+	var prefix strings.Builder
+	for _, name := range names {
+		if !isASCII(vars[name]) {
+			prefix.WriteString("chcp 65001 >nul && ")
+			break
+		}
+	}
+	for _, name := range names {
+		fmt.Fprintf(&prefix, "set \"%s=%s\" && ", name, vars[name])
+	}
+	return prefix.String(), nil
+}
+
+// psQuote escapes the quotes of PowerShell, including the typographic ones it
+// accepts, in single quoted strings.
+var psQuote = strings.NewReplacer(
+	"'", "''",
+	"‘", "‘‘",
+	"’", "’’",
+	"‚", "‚‚",
+	"‛", "‛‛",
+)
+
+// PowerShellEnvPrefix returns the prefix of the PowerShell scripts setting
+// the environment variables vars, with their values single quoted, like
+// `$env:FOO='bar'; `.
+func PowerShellEnvPrefix(vars map[string]string) (string, error) {
+	names, err := envNames(vars)
+	if err != nil {
+		return "", err
+	}
+	var prefix strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&prefix, "$env:%s='%s'; ", name, psQuote.Replace(vars[name]))
+	}
+	return prefix.String(), nil
+}
+
+// envNames returns the names of vars, sorted so that the prefixes are
+// stable, or an error for the names the shells can't set.
+func envNames(vars map[string]string) ([]string, error) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("Invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
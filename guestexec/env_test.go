@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestEnvPrefix(t *testing.T) {
+	vars := map[string]string{
+		"FOO":  "bar baz",
+		"QUOT": "it's $HOME",
+	}
+
+	guestCmd, err := NewGuestCommands(UnixOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", UnixOSType)
+	}
+	prefix, err := guestCmd.EnvPrefix(vars)
+	if err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+	if prefix != `export FOO='bar baz' QUOT='it'\''s $HOME'; ` {
+		t.Fatalf("Unexpected Unix env prefix: %s", prefix)
+	}
+	if sh, err := exec.LookPath("sh"); err == nil {
+		out, err := exec.Command(sh, "-c", prefix+`printf '%s|%s' "$FOO" "$QUOT"`).Output()
+		if err != nil || string(out) != "bar baz|it's $HOME" {
+			t.Fatalf("Unexpected env from the prefix: %q %v", out, err)
+		}
+	}
+
+	guestCmd, err = NewGuestCommands(WindowsOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", WindowsOSType)
+	}
+	prefix, err = guestCmd.EnvPrefix(map[string]string{"FOO": "a & b", "NAME": "café"})
+	if err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+	if prefix != `chcp 65001 >nul && set "FOO=a & b" && set "NAME=café" && ` {
+		t.Fatalf("Unexpected Windows env prefix: %s", prefix)
+	}
+	if _, err := guestCmd.EnvPrefix(map[string]string{"FOO": "100%"}); err == nil {
+		t.Fatalf("Should have returned an err for a percent sign with cmd.exe")
+	}
+
+	prefix, err = PowerShellEnvPrefix(vars)
+	if err != nil {
+		t.Fatalf("Did not expect error: %s", err.Error())
+	}
+	if prefix != `$env:FOO='bar baz'; $env:QUOT='it''s $HOME'; ` {
+		t.Fatalf("Unexpected PowerShell env prefix: %s", prefix)
+	}
+
+	if _, err := PosixEnvPrefix(map[string]string{"FOO-BAR": "x"}); err == nil {
+		t.Fatalf("Should have returned an err for an invalid name")
+	}
+	if prefix, err := PosixEnvPrefix(nil); err != nil || prefix != "" {
+		t.Fatalf("Unexpected empty env prefix: %q %v", prefix, err)
+	}
+}
@@ -12,34 +12,67 @@ const UnixOSType = "unix"
 const WindowsOSType = "windows"
 const DefaultOSType = UnixOSType
 
+// The OS types of the BSD and macOS guests, which run the POSIX commands of
+// the Unix guests without assuming GNU tools or bash.
+const (
+	FreeBSDOSType = "freebsd"
+	OpenBSDOSType = "openbsd"
+	DarwinOSType  = "darwin"
+)
+
 type guestOSTypeCommand struct {
 	chmod     string
 	mkdir     string
 	removeDir string
 	statPath  string
 	mv        string
+	// elevate runs a command as root, empty when the commands aren't
+	// elevated by a prefix.
+	elevate string
+	tempDir string
 }
 
-var guestOSTypeCommands = map[string]guestOSTypeCommand{
-	UnixOSType: {
+// posixCommands returns the commands of a POSIX guest.
+func posixCommands(tempDir string, elevate string) guestOSTypeCommand {
+	return guestOSTypeCommand{
 		chmod:     "chmod %s '%s'",
 		mkdir:     "mkdir -p '%s'",
 		removeDir: "rm -rf '%s'",
 		statPath:  "stat '%s'",
 		mv:        "mv '%s' '%s'",
-	},
+		elevate:   elevate,
+		tempDir:   tempDir,
+	}
+}
+
+var guestOSTypeCommands = map[string]guestOSTypeCommand{
+	UnixOSType:    posixCommands("/tmp", "sudo %s"),
+	FreeBSDOSType: posixCommands("/tmp", "sudo %s"),
+	// OpenBSD ships doas instead of sudo.
+	OpenBSDOSType: posixCommands("/tmp", "doas %s"),
+	// /tmp is a symlink to /private/tmp on macOS.
+	DarwinOSType: posixCommands("/private/tmp", "sudo %s"),
 	WindowsOSType: {
 		chmod:     "echo 'skipping chmod %s %s'", // no-op
 		mkdir:     "powershell.exe -Command \"New-Item -ItemType directory -Force -ErrorAction SilentlyContinue -Path %s\"",
 		removeDir: "powershell.exe -Command \"rm %s -recurse -force\"",
 		statPath:  "powershell.exe -Command { if (test-path %s) { exit 0 } else { exit 1 } }",
 		mv:        "powershell.exe -Command \"mv %s %s -force\"",
+		tempDir:   "C:/Windows/Temp",
 	},
 }
 
 type GuestCommands struct {
 	GuestOSType string
 	Sudo        bool
+	// Elevate is the template elevating the commands of the Unix-like guests
+	// when Sudo is set, such as "doas %s". Defaults to the one of the guest
+	// OS type.
+	Elevate string
+	// PowerShell is the executable running the commands of Windows guests,
+	// such as pwsh.exe or the path of a custom shell. Defaults to
+	// powershell.exe.
+	PowerShell string
 }
 
 func NewGuestCommands(osType string, sudo bool) (*GuestCommands, error) {
@@ -51,15 +84,15 @@ func NewGuestCommands(osType string, sudo bool) (*GuestCommands, error) {
 }
 
 func (g *GuestCommands) Chmod(path string, mode string) string {
-	return g.sudo(fmt.Sprintf(g.commands().chmod, mode, g.escapePath(path)))
+	return g.sudo(g.shell(fmt.Sprintf(g.commands().chmod, mode, g.escapePath(path))))
 }
 
 func (g *GuestCommands) CreateDir(path string) string {
-	return g.sudo(fmt.Sprintf(g.commands().mkdir, g.escapePath(path)))
+	return g.sudo(g.shell(fmt.Sprintf(g.commands().mkdir, g.escapePath(path))))
 }
 
 func (g *GuestCommands) RemoveDir(path string) string {
-	return g.sudo(fmt.Sprintf(g.commands().removeDir, g.escapePath(path)))
+	return g.sudo(g.shell(fmt.Sprintf(g.commands().removeDir, g.escapePath(path))))
 }
 
 func (g *GuestCommands) commands() guestOSTypeCommand {
@@ -70,20 +103,50 @@ func (g *GuestCommands) escapePath(path string) string {
 	if g.GuestOSType == WindowsOSType {
 		return strings.Replace(path, " ", "` ", -1)
 	}
-	return path
+	// The paths are single quoted by the commands.
+	return strings.Replace(path, "'", `'\''`, -1)
+}
+
+// TempDir returns the directory of the temporary files of the guest.
+func (g *GuestCommands) TempDir() string {
+	return g.commands().tempDir
+}
+
+// ElevatedCommand returns cmd run as root on the Unix-like guests, with sudo
+// or the Elevate template. It returns cmd unchanged on Windows, where the
+// elevated commands are run by GenerateElevatedRunner.
+func (g *GuestCommands) ElevatedCommand(cmd string) string {
+	elevate := g.commands().elevate
+	if elevate == "" {
+		return cmd
+	}
+	if g.Elevate != "" {
+		elevate = g.Elevate
+	}
+	return fmt.Sprintf(elevate, cmd)
 }
 
 func (g *GuestCommands) StatPath(path string) string {
-	return g.sudo(fmt.Sprintf(g.commands().statPath, g.escapePath(path)))
+	return g.sudo(g.shell(fmt.Sprintf(g.commands().statPath, g.escapePath(path))))
 }
 
 func (g *GuestCommands) MovePath(srcPath string, dstPath string) string {
-	return g.sudo(fmt.Sprintf(g.commands().mv, g.escapePath(srcPath), g.escapePath(dstPath)))
+	return g.sudo(g.shell(fmt.Sprintf(g.commands().mv, g.escapePath(srcPath), g.escapePath(dstPath))))
+}
+
+// shell runs the Windows commands with the configured PowerShell.
+func (g *GuestCommands) shell(cmd string) string {
+	if g.GuestOSType == WindowsOSType && g.PowerShell != "" {
+		if rest := strings.TrimPrefix(cmd, WindowsPowerShell+" "); rest != cmd {
+			return QuotePowerShell(g.PowerShell) + " " + rest
+		}
+	}
+	return cmd
 }
 
 func (g *GuestCommands) sudo(cmd string) string {
-	if g.GuestOSType == UnixOSType && g.Sudo {
-		return "sudo " + cmd
+	if g.Sudo {
+		return g.ElevatedCommand(cmd)
 	}
 	return cmd
 }
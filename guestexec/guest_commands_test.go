@@ -196,3 +196,83 @@ func TestMovePath(t *testing.T) {
 		t.Fatalf("Unexpected Windows remove dir cmd: %s", cmd)
 	}
 }
+
+func TestPowerShell(t *testing.T) {
+	guestCmd, err := NewGuestCommands(WindowsOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", WindowsOSType)
+	}
+	guestCmd.PowerShell = PowerShellCore
+	cmd := guestCmd.RemoveDir("C:\\Temp\\SomeDir")
+	if cmd != "pwsh.exe -Command \"rm C:\\Temp\\SomeDir -recurse -force\"" {
+		t.Fatalf("Unexpected Windows remove dir cmd: %s", cmd)
+	}
+
+	// Custom shell w/ space in path
+	guestCmd.PowerShell = "C:\\Program Files\\PowerShell\\7\\pwsh.exe"
+	cmd = guestCmd.MovePath("C:\\Temp\\SomeDir", "C:\\Temp\\NewDir")
+	if cmd != "\"C:\\Program Files\\PowerShell\\7\\pwsh.exe\" -Command \"mv C:\\Temp\\SomeDir C:\\Temp\\NewDir -force\"" {
+		t.Fatalf("Unexpected Windows move cmd: %s", cmd)
+	}
+
+	// chmod isn't run by PowerShell
+	cmd = guestCmd.Chmod("C:\\Temp\\SomeDir", "0777")
+	if cmd != "echo 'skipping chmod 0777 C:\\Temp\\SomeDir'" {
+		t.Fatalf("Unexpected Windows chmod cmd: %s", cmd)
+	}
+}
+
+func TestBSDAndDarwin(t *testing.T) {
+	for _, tc := range []struct {
+		osType  string
+		tempDir string
+		mkdir   string
+	}{
+		{FreeBSDOSType, "/tmp", "sudo mkdir -p '/tmp/temp dir'"},
+		{OpenBSDOSType, "/tmp", "doas mkdir -p '/tmp/temp dir'"},
+		{DarwinOSType, "/private/tmp", "sudo mkdir -p '/tmp/temp dir'"},
+	} {
+		guestCmd, err := NewGuestCommands(tc.osType, true)
+		if err != nil {
+			t.Fatalf("Failed to create new GuestCommands for OS: %s", tc.osType)
+		}
+		if dir := guestCmd.TempDir(); dir != tc.tempDir {
+			t.Fatalf("Unexpected %s temp dir: %s", tc.osType, dir)
+		}
+		cmd := guestCmd.CreateDir("/tmp/temp dir")
+		if cmd != tc.mkdir {
+			t.Fatalf("Unexpected %s create dir cmd: %s", tc.osType, cmd)
+		}
+	}
+
+	// Custom elevation
+	guestCmd, err := NewGuestCommands(FreeBSDOSType, true)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", FreeBSDOSType)
+	}
+	guestCmd.Elevate = "doas -u root %s"
+	cmd := guestCmd.RemoveDir("/tmp/dir")
+	if cmd != "doas -u root rm -rf '/tmp/dir'" {
+		t.Fatalf("Unexpected custom elevated remove dir cmd: %s", cmd)
+	}
+}
+
+func TestQuotedPath(t *testing.T) {
+	guestCmd, err := NewGuestCommands(UnixOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", UnixOSType)
+	}
+	cmd := guestCmd.MovePath("/tmp/it's", "/tmp/dir")
+	if cmd != `mv '/tmp/it'\''s' '/tmp/dir'` {
+		t.Fatalf("Unexpected quoted move cmd: %s", cmd)
+	}
+
+	// Windows commands aren't elevated with a prefix.
+	guestCmd, err = NewGuestCommands(WindowsOSType, true)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", WindowsOSType)
+	}
+	if cmd := guestCmd.ElevatedCommand("whoami"); cmd != "whoami" {
+		t.Fatalf("Unexpected Windows elevated cmd: %s", cmd)
+	}
+}
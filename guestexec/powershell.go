@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/powershell"
+)
+
+// The executables of Windows PowerShell, installed with Windows, and of
+// PowerShell 7.
+const (
+	WindowsPowerShell = powershell.WindowsPowerShell
+	PowerShellCore    = powershell.PowerShellCore
+)
+
+// PowerShellProvisioner is implemented by the provisioners which run
+// PowerShell with another executable than Windows PowerShell, such as
+// pwsh.exe or the path of a custom shell.
+type PowerShellProvisioner interface {
+	PowerShell() string
+}
+
+// powerShell returns the PowerShell executable of p, if it sets one.
+func powerShell(p interface{}) string {
+	if p, ok := p.(PowerShellProvisioner); ok {
+		return p.PowerShell()
+	}
+	return ""
+}
+
+// QuotePowerShell returns the executable shell, powershell.exe when empty,
+// double quoted for a command line if its path has spaces.
+func QuotePowerShell(shell string) string {
+	return powershell.Quote(shell)
+}
+
+// EncodedPowerShellCommand returns the command line running script with the
+// executable shell, powershell.exe when empty. The script is passed as
+// base64 encoded UTF-16LE with -EncodedCommand, so that it needs no quoting.
+func EncodedPowerShellCommand(shell string, script string) string {
+	return powershell.EncodedCommand(shell, script)
+}
+
+// Native64BitCommand returns the command line running command in a 64-bit
+// process on 64-bit Windows, even when shell, the PowerShell executable, is
+// a 32-bit one.
+func Native64BitCommand(shell string, command string) string {
+	return powershell.Native64BitCommand(shell, command)
+}
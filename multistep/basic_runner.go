@@ -7,6 +7,8 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
+
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
 )
 
 type runState int32
@@ -50,16 +52,27 @@ func (b *BasicRunner) Run(ctx context.Context, state StateBag) {
 	go func() {
 		select {
 		case <-ctx.Done():
+			state.Put(StateCancelCause, Cause(ctx))
 			state.Put(StateCancelled, true)
 		case <-doneCh:
 		}
 	}()
 
+	if reporter := buildReporter(state); reporter != nil {
+		reporter.BuildStarted()
+		// Deferred before the cleanups of the steps, so that it is
+		// reported after them.
+		defer func() {
+			reporter.BuildFinished(buildResult(ctx, state))
+		}()
+	}
+
 	for _, step := range b.Steps {
 		if step == nil {
 			continue
 		}
 		if err := ctx.Err(); err != nil {
+			state.Put(StateCancelCause, Cause(ctx))
 			state.Put(StateCancelled, true)
 			break
 		}
@@ -70,10 +83,39 @@ func (b *BasicRunner) Run(ctx context.Context, state StateBag) {
 			break
 		}
 
-		action := step.Run(ctx, state)
+		reporter := stepReporter(state)
+		_, paused := step.(*debugStepPause)
+		if paused {
+			reporter = nil
+		}
+		var name string
+		if reporter != nil || tracing.Enabled() {
+			name = StepName(step)
+		}
+		if reporter != nil {
+			reporter.StepStarted(name)
+		}
+
+		stepCtx, span := ctx, tracing.Span(nil)
+		if !paused {
+			stepCtx, span = tracing.Start(ctx, "step "+name, tracing.Attribute{Key: "packer.step", Value: name})
+		}
+		action := step.Run(stepCtx, state)
 		defer step.Cleanup(state)
 
-		if _, ok := state.GetOk(StateCancelled); ok {
+		_, cancelled := state.GetOk(StateCancelled)
+		if span != nil {
+			span.SetAttributes(tracing.Attribute{Key: "packer.step.halted", Value: cancelled || action == ActionHalt})
+			var err error
+			if action == ActionHalt {
+				err, _ = state.Get("error").(error)
+			}
+			tracing.End(span, err)
+		}
+		if reporter != nil {
+			reporter.StepFinished(name, cancelled || action == ActionHalt)
+		}
+		if cancelled {
 			break
 		}
 
@@ -5,8 +5,12 @@ package multistep
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
 )
 
 func TestBasicRunner_ImplRunner(t *testing.T) {
@@ -176,3 +180,141 @@ func TestBasicRunner_Cancel_Special(t *testing.T) {
 		t.Errorf("cancelled should be in state bag")
 	}
 }
+
+type testStepReporter struct {
+	steps []string
+}
+
+func (r *testStepReporter) StepStarted(name string) {
+	r.steps = append(r.steps, "start "+name)
+}
+
+func (r *testStepReporter) StepFinished(name string, halted bool) {
+	r.steps = append(r.steps, fmt.Sprintf("finish %s %t", name, halted))
+}
+
+func TestBasicRunner_Run_StepReporter(t *testing.T) {
+	reporter := new(testStepReporter)
+	data := new(BasicStateBag)
+	data.Put("ui", reporter)
+	stepA := &TestStepAcc{Data: "a"}
+	stepB := &TestStepAcc{Data: "b", Halt: true}
+	stepC := &TestStepAcc{Data: "c"}
+
+	r := &BasicRunner{Steps: []Step{stepA, stepB, stepC}}
+	r.Run(context.Background(), data)
+
+	expected := []string{
+		"start TestStepAcc", "finish TestStepAcc false",
+		"start TestStepAcc", "finish TestStepAcc true",
+	}
+	if !reflect.DeepEqual(reporter.steps, expected) {
+		t.Errorf("unexpected steps: %#v", reporter.steps)
+	}
+}
+
+func TestBasicRunner_Run_Tracing(t *testing.T) {
+	tp := new(tracing.MockTracerProvider)
+	tracing.SetTracerProvider(tp)
+	defer tracing.SetTracerProvider(nil)
+
+	data := new(BasicStateBag)
+	stepA := &TestStepAcc{Data: "a"}
+	stepB := TestStepFn{run: func(_ context.Context, state StateBag) StepAction {
+		state.Put("error", fmt.Errorf("oops"))
+		return ActionHalt
+	}}
+
+	r := &BasicRunner{Steps: []Step{stepA, stepB}}
+	r.Run(context.Background(), data)
+
+	spans := tp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected a span per step, got %d", len(spans))
+	}
+	for i, expected := range []struct {
+		name   string
+		halted bool
+	}{
+		{"step TestStepAcc", false},
+		{"step TestStepFn", true},
+	} {
+		span := spans[i]
+		if span.Name != expected.name || !span.Ended {
+			t.Errorf("unexpected span %#v", span)
+		}
+		if span.Attributes["packer.step.halted"] != expected.halted {
+			t.Errorf("span %d: expected halted %t, got %v", i, expected.halted, span.Attributes["packer.step.halted"])
+		}
+	}
+	if len(spans[1].Errors) != 1 {
+		t.Errorf("expected the error of the halted step to be recorded, got %v", spans[1].Errors)
+	}
+}
+
+// testBuildReporter records the builds and steps reported.
+type testBuildReporter struct {
+	testStepReporter
+}
+
+func (r *testBuildReporter) BuildStarted() {
+	r.steps = append(r.steps, "build started")
+}
+
+func (r *testBuildReporter) BuildFinished(halted bool, err error) {
+	r.steps = append(r.steps, fmt.Sprintf("build finished %t %v", halted, err))
+}
+
+// testStepError halts with an error.
+type testStepError struct{}
+
+func (testStepError) Run(ctx context.Context, state StateBag) StepAction {
+	state.Put("error", errors.New("oops"))
+	return ActionHalt
+}
+
+func (testStepError) Cleanup(state StateBag) {
+	r := state.Get("ui").(*testBuildReporter)
+	r.steps = append(r.steps, "cleanup")
+}
+
+func TestBasicRunner_Run_BuildReporter(t *testing.T) {
+	reporter := new(testBuildReporter)
+	data := new(BasicStateBag)
+	data.Put("ui", reporter)
+
+	r := &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}, testStepError{}}}
+	r.Run(context.Background(), data)
+
+	expected := []string{
+		"build started",
+		"start TestStepAcc", "finish TestStepAcc false",
+		"start testStepError", "finish testStepError true",
+		"cleanup",
+		"build finished true oops",
+	}
+	if !reflect.DeepEqual(reporter.steps, expected) {
+		t.Errorf("unexpected steps: %#v", reporter.steps)
+	}
+
+	reporter.steps = nil
+	data = new(BasicStateBag)
+	data.Put("ui", reporter)
+	r = &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}}}
+	r.Run(context.Background(), data)
+	if last := reporter.steps[len(reporter.steps)-1]; last != "build finished false <nil>" {
+		t.Errorf("unexpected end of the build: %q", last)
+	}
+
+	reporter.steps = nil
+	data = new(BasicStateBag)
+	data.Put("ui", reporter)
+	ctx, cancel := WithCancelCause(context.Background())
+	cancel(&CancelCause{Reason: CancelReasonUser})
+	r = &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}}}
+	r.Run(ctx, data)
+	expected = []string{"build started", "build finished true cancelled (user)"}
+	if !reflect.DeepEqual(reporter.steps, expected) {
+		t.Errorf("unexpected steps: %#v", reporter.steps)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// CancelReason tells why a build was cancelled.
+type CancelReason string
+
+const (
+	// CancelReasonUnknown is the reason of the cancellations without cause.
+	CancelReasonUnknown CancelReason = "unknown"
+	// CancelReasonUser is the reason of the cancellations asked by the user,
+	// such as with Ctrl-C.
+	CancelReasonUser CancelReason = "user"
+	// CancelReasonTimeout is the reason of the cancellations after a
+	// timeout.
+	CancelReasonTimeout CancelReason = "timeout"
+	// CancelReasonUpstreamFailure is the reason of the cancellations caused
+	// by the failure of something else, such as another build.
+	CancelReasonUpstreamFailure CancelReason = "upstream_failure"
+)
+
+// This is the key set in the state bag along with StateCancelled, holding
+// the *CancelCause of the cancellation.
+const StateCancelCause = "cancel_cause"
+
+// CancelCause is the cause of a cancellation. It is an error matching
+// context.Canceled with errors.Is, as well as context.DeadlineExceeded for
+// timeouts, so that it can be returned instead of the error of the context.
+type CancelCause struct {
+	Reason CancelReason
+	// Err is the error that caused the cancellation, if any, such as the
+	// failure of an upstream build.
+	Err error
+}
+
+func (c *CancelCause) Error() string {
+	if c.Err != nil {
+		return fmt.Sprintf("cancelled (%s): %s", c.Reason, c.Err)
+	}
+	return fmt.Sprintf("cancelled (%s)", c.Reason)
+}
+
+func (c *CancelCause) Unwrap() error {
+	return c.Err
+}
+
+func (c *CancelCause) Is(target error) bool {
+	return target == context.Canceled ||
+		(c.Reason == CancelReasonTimeout && target == context.DeadlineExceeded)
+}
+
+// CancelReason returns the reason of the cancellation as a string, for the
+// packages that can't import multistep.
+func (c *CancelCause) CancelReason() string {
+	return string(c.Reason)
+}
+
+type cancelCauseKey struct{}
+
+// cancelCauseHolder holds the cause of the cancellation of a context.
+type cancelCauseHolder struct {
+	l      sync.Mutex
+	cause  *CancelCause
+	parent *cancelCauseHolder
+}
+
+// set sets the cause, unless it is already set.
+func (h *cancelCauseHolder) set(cause *CancelCause) {
+	h.l.Lock()
+	defer h.l.Unlock()
+	if h.cause == nil {
+		h.cause = cause
+	}
+}
+
+// get returns the cause of the innermost cancelled context.
+func (h *cancelCauseHolder) get() *CancelCause {
+	for ; h != nil; h = h.parent {
+		h.l.Lock()
+		cause := h.cause
+		h.l.Unlock()
+		if cause != nil {
+			return cause
+		}
+	}
+	return nil
+}
+
+// WithCancelCause returns a copy of parent that is cancelled with a cause
+// when the returned function is called, or when parent is done. The first
+// cause given is kept, and a nil cause is a CancelReasonUnknown cause.
+func WithCancelCause(parent context.Context) (context.Context, func(*CancelCause)) {
+	ctx, cancel := context.WithCancel(parent)
+	holder := &cancelCauseHolder{}
+	holder.parent, _ = parent.Value(cancelCauseKey{}).(*cancelCauseHolder)
+	ctx = context.WithValue(ctx, cancelCauseKey{}, holder)
+
+	return ctx, func(cause *CancelCause) {
+		if cause == nil {
+			cause = &CancelCause{Reason: CancelReasonUnknown}
+		}
+		holder.set(cause)
+		cancel()
+	}
+}
+
+// Cause returns the cause of the cancellation of ctx, or nil when it isn't
+// done. Contexts cancelled without cause are of CancelReasonTimeout after
+// their deadline, or of CancelReasonUnknown otherwise.
+func Cause(ctx context.Context) *CancelCause {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if holder, ok := ctx.Value(cancelCauseKey{}).(*cancelCauseHolder); ok {
+		if cause := holder.get(); cause != nil {
+			return cause
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &CancelCause{Reason: CancelReasonTimeout}
+	}
+	return &CancelCause{Reason: CancelReasonUnknown}
+}
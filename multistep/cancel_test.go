@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithCancelCause(t *testing.T) {
+	ctx, cancel := WithCancelCause(context.Background())
+	if Cause(ctx) != nil {
+		t.Fatal("expected no cause before the cancellation")
+	}
+
+	upstream := errors.New("build qemu.a failed")
+	cancel(&CancelCause{Reason: CancelReasonUpstreamFailure, Err: upstream})
+	cancel(&CancelCause{Reason: CancelReasonUser})
+
+	cause := Cause(ctx)
+	if cause == nil || cause.Reason != CancelReasonUpstreamFailure {
+		t.Fatalf("expected the first cause, got %#v", cause)
+	}
+	if !errors.Is(cause, context.Canceled) || !errors.Is(cause, upstream) {
+		t.Fatalf("expected the cause to match context.Canceled and its error")
+	}
+
+	// The cause of a parent is the cause of its children.
+	child, cancelChild := context.WithTimeout(ctx, time.Hour)
+	defer cancelChild()
+	if cause := Cause(child); cause == nil || cause.Reason != CancelReasonUpstreamFailure {
+		t.Fatalf("expected the cause of the parent, got %#v", cause)
+	}
+}
+
+func TestCause_withoutCause(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if cause := Cause(ctx); cause.Reason != CancelReasonTimeout || !errors.Is(cause, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %#v", cause)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if cause := Cause(ctx); cause.Reason != CancelReasonUnknown {
+		t.Fatalf("expected an unknown reason, got %#v", cause)
+	}
+}
+
+func TestBasicRunner_Run_CancelCause(t *testing.T) {
+	ctx, cancel := WithCancelCause(context.Background())
+	cancel(&CancelCause{Reason: CancelReasonUser})
+
+	data := new(BasicStateBag)
+	r := &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}}}
+	r.Run(ctx, data)
+
+	cause, ok := data.Get(StateCancelCause).(*CancelCause)
+	if !ok || cause.Reason != CancelReasonUser {
+		t.Fatalf("expected the cause of the cancellation, got %#v", data.Get(StateCancelCause))
+	}
+}
@@ -202,7 +202,12 @@ func handleAbortsAndInterupts(state multistep.StateBag, ui packersdk.Ui, stepNam
 	}
 	if _, ok := state.GetOk(multistep.StateCancelled); ok {
 		if !alreadyLogged {
-			ui.Error("Interrupted, aborting...")
+			cause, _ := state.Get(multistep.StateCancelCause).(*multistep.CancelCause)
+			if cause != nil && cause.Reason != multistep.CancelReasonUser && cause.Reason != multistep.CancelReasonUnknown {
+				ui.Error(fmt.Sprintf("Interrupted (%s), aborting...", cause.Reason))
+			} else {
+				ui.Error("Interrupted, aborting...")
+			}
 			state.Put("abort_step_logged", true)
 		} else {
 			ui.Error(fmt.Sprintf("aborted: skipping cleanup of step %q", stepName))
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepBuildHooks fires the hooks around a build: packersdk.HookPreBuild when
+// it runs, and packersdk.HookPostBuild or packersdk.HookOnError when it's
+// cleaned up, depending on whether the build succeeded. It should be the
+// first step of a build, so that it's cleaned up last.
+//
+// Uses:
+//
+//	generated_data map[string]interface{}
+//	hook           packersdk.Hook
+//	ui             packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type StepBuildHooks struct{}
+
+func (s *StepBuildHooks) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	hook, ok := state.Get("hook").(packersdk.Hook)
+	if !ok {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+
+	log.Println("Running the pre-build hook")
+	if err := hook.Run(ctx, packersdk.HookPreBuild, ui, nil, buildHookData(state)); err != nil {
+		err := fmt.Errorf("Error running the pre-build hook: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepBuildHooks) Cleanup(state multistep.StateBag) {
+	hook, ok := state.Get("hook").(packersdk.Hook)
+	if !ok {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	name, data := packersdk.HookPostBuild, buildHookData(state)
+	if cancelled || halted {
+		err, _ := state.Get("error").(error)
+		name, data = packersdk.HookOnError, packersdk.OnErrorHookData(data, err)
+	}
+
+	log.Printf("Running the %s hook", name)
+	if err := hook.Run(context.Background(), name, ui, nil, data); err != nil {
+		ui.Error(fmt.Sprintf("Error running the %s hook: %s", name, err))
+	}
+}
+
+// buildHookData returns the generated data of the build, if any.
+func buildHookData(state multistep.StateBag) map[string]interface{} {
+	if data, ok := state.Get("generated_data").(map[string]interface{}); ok {
+		return data
+	}
+	return map[string]interface{}{}
+}
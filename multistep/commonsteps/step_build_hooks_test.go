@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepBuildHooks(t *testing.T) {
+	state := testState(t)
+	hook := new(packersdk.MockHook)
+	state.Put("hook", hook)
+	state.Put("generated_data", map[string]interface{}{"SourceImage": "ubuntu"})
+
+	step := new(StepBuildHooks)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if hook.RunName != packersdk.HookPreBuild {
+		t.Fatalf("expected the pre-build hook, got %q", hook.RunName)
+	}
+
+	step.Cleanup(state)
+	if hook.RunName != packersdk.HookPostBuild {
+		t.Fatalf("expected the post-build hook, got %q", hook.RunName)
+	}
+	if data := hook.RunData.(map[string]interface{}); data["SourceImage"] != "ubuntu" {
+		t.Fatalf("expected the generated data, got %#v", data)
+	}
+
+	state.Put(multistep.StateHalted, true)
+	state.Put("error", errors.New("oops"))
+	step.Cleanup(state)
+	if hook.RunName != packersdk.HookOnError {
+		t.Fatalf("expected the on-error hook, got %q", hook.RunName)
+	}
+	data := hook.RunData.(map[string]interface{})
+	if data[packersdk.HookDataBuildError] != "oops" || data[packersdk.HookDataBuildErrorClass] != "internal" ||
+		data["SourceImage"] != "ubuntu" {
+		t.Fatalf("unexpected on-error data %#v", data)
+	}
+}
+
+func TestStepBuildHooks_preBuildError(t *testing.T) {
+	state := testState(t)
+	state.Put("hook", &packersdk.MockHook{
+		RunFunc: func(context.Context) error { return errors.New("oops") },
+	})
+
+	step := new(StepBuildHooks)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("expected an error")
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/filelock"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
 )
 
 // StepDownload downloads a remote file using the download client within
@@ -140,7 +141,8 @@ func (s *StepDownload) Run(ctx context.Context, state multistep.StateBag) multis
 		errs = append(errs, err)
 	}
 
-	err := fmt.Errorf("error downloading %s: %v", s.Description, errs)
+	// The sources may be reachable again later.
+	err := sdkerrors.WrapRetryable(fmt.Errorf("error downloading %s: %v", s.Description, errs))
 	state.Put("error", err)
 	ui.Error(err.Error())
 	return multistep.ActionHalt
@@ -11,7 +11,9 @@ import (
 	"os"
 	"path"
 	"sort"
+	"sync"
 
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
@@ -37,6 +39,9 @@ func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
 //
 // Produces:
 //   http_port int - The port the HTTP server started on.
+//   http_requested bootcommand.HTTPRequestedFunc - Whether the guest
+//     requested a path, for the `<waitForHTTP path>` boot command
+//     expressions.
 type StepHTTPServer struct {
 	HTTPDir     string
 	HTTPContent map[string]string
@@ -45,6 +50,9 @@ type StepHTTPServer struct {
 	HTTPAddress string
 
 	l *net.Listener
+
+	requestsL sync.Mutex
+	requested map[string]bool
 }
 
 func (s *StepHTTPServer) Handler() http.Handler {
@@ -55,6 +63,26 @@ func (s *StepHTTPServer) Handler() http.Handler {
 	return MapServer(s.HTTPContent)
 }
 
+// recordRequests returns h recording the paths requested.
+func (s *StepHTTPServer) recordRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requestsL.Lock()
+		if s.requested == nil {
+			s.requested = make(map[string]bool)
+		}
+		s.requested[path.Clean("/"+r.URL.Path)] = true
+		s.requestsL.Unlock()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Requested returns whether path was requested from the HTTP server.
+func (s *StepHTTPServer) Requested(_ context.Context, p string) (bool, error) {
+	s.requestsL.Lock()
+	defer s.requestsL.Unlock()
+	return s.requested[path.Clean("/"+p)], nil
+}
+
 type MapServer map[string]string
 
 func (s MapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -117,11 +145,12 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
 
 	// Start the HTTP server and run it in the background
-	server := &http.Server{Addr: "", Handler: s.Handler()}
+	server := &http.Server{Addr: "", Handler: s.recordRequests(s.Handler())}
 	go server.Serve(s.l)
 
 	// Save the address into the state so it can be accessed in the future
 	state.Put("http_port", s.l.Port)
+	state.Put("http_requested", bootcommand.HTTPRequestedFunc(s.Requested))
 
 	return multistep.ActionContinue
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
 
@@ -81,6 +82,15 @@ func TestStepHTTPServer_Run(t *testing.T) {
 				if diff := cmp.Diff(wantResponse, gotResponse); diff != "" {
 					t.Fatalf("Unexpected %q content: %s", k, diff)
 				}
+				requested := state.Get("http_requested").(bootcommand.HTTPRequestedFunc)
+				if ok, _ := requested(context.Background(), k); !ok {
+					t.Fatalf("%q should be requested", k)
+				}
+			}
+			if requested, ok := state.GetOk("http_requested"); ok {
+				if ok, _ := requested.(bootcommand.HTTPRequestedFunc)(context.Background(), "/phone-home"); ok {
+					t.Fatal("/phone-home should not be requested")
+				}
 			}
 		})
 	}
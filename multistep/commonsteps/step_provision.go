@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"strconv"
 	"time"
@@ -68,7 +69,7 @@ func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{}
 		hookData["PackerHTTPIP"] = httIP.(string)
 	}
 	if okPort && okIP {
-		hookData["PackerHTTPAddr"] = fmt.Sprintf("%s:%s", hookData["PackerHTTPIP"], hookData["PackerHTTPPort"])
+		hookData["PackerHTTPAddr"] = net.JoinHostPort(httIP.(string), strconv.Itoa(httpPort.(int)))
 	}
 
 	// Read communicator data into hook data
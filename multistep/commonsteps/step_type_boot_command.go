@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// BootCommandTemplateData is the data the boot commands are rendered with
+// by StepTypeBootCommand.
+type BootCommandTemplateData struct {
+	HTTPIP   string
+	HTTPPort int
+	Name     string
+}
+
+// StepTypeBootCommand waits boot_wait, then types the boot command of
+// BootConfig, rendered with the template engine, with the driver of the
+// builder. Each entry of the boot command is typed separately, waiting
+// boot_keygroup_interval in between.
+//
+// Uses:
+//   ui     packersdk.Ui
+//   http_ip string - The IP of the HTTP server, if any.
+//   http_port int - The port of the HTTP server, if any.
+//   http_requested bootcommand.HTTPRequestedFunc - The requests to the HTTP
+//     server, for the `<waitForHTTP path>` expressions, if any.
+//   pauseFn multistep.DebugPauseFn - The pause of -debug, if any.
+type StepTypeBootCommand struct {
+	BootConfig *bootcommand.BootConfig
+	// Ctx is the interpolation context the boot command is rendered with,
+	// with a BootCommandTemplateData as data.
+	Ctx interpolate.Context
+	// VMName is the Name of the BootCommandTemplateData.
+	VMName string
+	// Driver returns the driver typing the boot command, for example
+	// connected to the VNC server of the VM found in state. The key pacing
+	// of BootConfig is set on the drivers with bootcommand.SetKeyPacing.
+	Driver func(ctx context.Context, state multistep.StateBag) (bootcommand.BCDriver, error)
+	// Conditions returns the conditions of the `<waitForPort port>` and
+	// `<waitForHTTP path>` expressions, if set. HTTPRequested defaults to
+	// the http_requested of the state.
+	Conditions func(state multistep.StateBag) bootcommand.WaitConditions
+	// Description says how the boot command is typed, like "over VNC".
+	Description string
+}
+
+func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if len(s.BootConfig.BootCommand) == 0 {
+		log.Println("No boot command given, skipping")
+		return multistep.ActionContinue
+	}
+
+	// Wait for the vm to boot.
+	if s.BootConfig.BootWait > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", s.BootConfig.BootWait))
+		select {
+		case <-time.After(s.BootConfig.BootWait):
+		case <-ctx.Done():
+			return multistep.ActionHalt
+		}
+	}
+
+	data := &BootCommandTemplateData{Name: s.VMName}
+	if httpIP, ok := state.GetOk("http_ip"); ok {
+		data.HTTPIP = httpIP.(string)
+	}
+	if httpPort, ok := state.GetOk("http_port"); ok {
+		data.HTTPPort = httpPort.(int)
+	}
+	s.Ctx.Data = data
+
+	macros := make(map[string]string, len(s.BootConfig.BootCommandMacros))
+	for name, macro := range s.BootConfig.BootCommandMacros {
+		var err error
+		if macros[name], err = interpolate.Render(macro, &s.Ctx); err != nil {
+			return s.halt(state, fmt.Errorf("Error preparing boot command macro %q: %s", name, err))
+		}
+	}
+	// The groups are parsed before typing any, so that the errors in the
+	// last ones don't interrupt the boot halfway.
+	seqs := make([]interface {
+		Do(context.Context, bootcommand.BCDriver) error
+	}, len(s.BootConfig.BootCommand))
+	for i, group := range s.BootConfig.BootCommand {
+		command, err := interpolate.Render(group, &s.Ctx)
+		if err != nil {
+			return s.halt(state, fmt.Errorf("Error preparing boot command: %s", err))
+		}
+		seq, err := bootcommand.GenerateExpressionSequenceWithMacros(command, macros)
+		if err != nil {
+			return s.halt(state, fmt.Errorf("Error generating boot command: %s", err))
+		}
+		seqs[i] = seq
+	}
+
+	driver, err := s.Driver(ctx, state)
+	if err != nil {
+		return s.halt(state, fmt.Errorf("Error creating boot command driver: %s", err))
+	}
+	bootcommand.SetKeyPacing(driver, s.BootConfig.KeyPacing())
+	var conditions bootcommand.WaitConditions
+	if s.Conditions != nil {
+		conditions = s.Conditions(state)
+	}
+	if conditions.HTTPRequested == nil {
+		if requested, ok := state.GetOk("http_requested"); ok {
+			conditions.HTTPRequested = requested.(bootcommand.HTTPRequestedFunc)
+		}
+	}
+	driver = bootcommand.WithWaitConditions(driver, conditions)
+
+	if s.Description != "" {
+		ui.Say(fmt.Sprintf("Typing the boot command %s...", s.Description))
+	} else {
+		ui.Say("Typing the boot command...")
+	}
+	for i, seq := range seqs {
+		if i > 0 && s.BootConfig.BootGroupInterval > 0 {
+			log.Printf("Waiting %s before the next group of the boot command", s.BootConfig.BootGroupInterval)
+			select {
+			case <-time.After(s.BootConfig.BootGroupInterval):
+			case <-ctx.Done():
+				return multistep.ActionHalt
+			}
+		}
+		if err := seq.Do(ctx, driver); err != nil {
+			return s.halt(state, fmt.Errorf("Error running boot command: %s", err))
+		}
+	}
+
+	if pauseFn, ok := state.GetOk("pauseFn"); ok {
+		pauseFn.(multistep.DebugPauseFn)(multistep.DebugLocationAfterRun, "boot command", state)
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepTypeBootCommand) halt(state multistep.StateBag, err error) multistep.StepAction {
+	state.Put("error", err)
+	state.Get("ui").(packersdk.Ui).Error(err.Error())
+	return multistep.ActionHalt
+}
+
+func (s *StepTypeBootCommand) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// testBCDriver records the keys typed, and when.
+type testBCDriver struct {
+	typed  string
+	times  []time.Time
+	pacing bootcommand.KeyPacing
+}
+
+func (d *testBCDriver) SendKey(key rune, action bootcommand.KeyAction) error {
+	d.typed += string(key)
+	d.times = append(d.times, time.Now())
+	return nil
+}
+
+func (d *testBCDriver) SendSpecial(special string, action bootcommand.KeyAction) error {
+	d.typed += fmt.Sprintf("<%s>", special)
+	return nil
+}
+
+func (d *testBCDriver) Flush() error { return nil }
+
+func (d *testBCDriver) SetKeyPacing(p bootcommand.KeyPacing) { d.pacing = p }
+
+func TestStepTypeBootCommand(t *testing.T) {
+	defer func(d time.Duration) { bootcommand.WaitForInterval = d }(bootcommand.WaitForInterval)
+	bootcommand.WaitForInterval = time.Millisecond
+
+	driver := new(testBCDriver)
+	step := &StepTypeBootCommand{
+		BootConfig: &bootcommand.BootConfig{
+			BootWait:          time.Millisecond,
+			BootCommand:       []string{"<macro ks>", "<waitForHTTP /done><enter>"},
+			BootCommandMacros: map[string]string{"ks": "ks=http://{{ .HTTPIP }}:{{ .HTTPPort }}/{{ .Name }}"},
+			BootKeyJitter:     10 * time.Millisecond,
+		},
+		VMName: "vm",
+		Driver: func(context.Context, multistep.StateBag) (bootcommand.BCDriver, error) {
+			return driver, nil
+		},
+	}
+	state := testState(t)
+	state.Put("http_ip", "10.0.2.2")
+	state.Put("http_port", 8080)
+	state.Put("http_requested", bootcommand.HTTPRequestedFunc(func(_ context.Context, path string) (bool, error) {
+		return path == "/done", nil
+	}))
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	if expected := "ks=http://10.0.2.2:8080/vm<enter>"; driver.typed != expected {
+		t.Fatalf("typed %q, expected %q", driver.typed, expected)
+	}
+	if driver.pacing.Jitter != 10*time.Millisecond {
+		t.Fatalf("bad pacing: %#v", driver.pacing)
+	}
+
+	// Bad boot commands halt.
+	step.BootConfig.BootCommand = []string{"<macro unknown>"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have an error")
+	}
+}
+
+func TestStepTypeBootCommand_groups(t *testing.T) {
+	driver := new(testBCDriver)
+	step := &StepTypeBootCommand{
+		BootConfig: &bootcommand.BootConfig{
+			BootCommand:       []string{"a", "b"},
+			BootGroupInterval: 50 * time.Millisecond,
+			BootKeyHold:       time.Millisecond,
+		},
+		Driver: func(context.Context, multistep.StateBag) (bootcommand.BCDriver, error) {
+			// The driver is found behind the wrappers to set its pacing.
+			return bootcommand.WithTextSender(driver, bootcommand.TextSenderFunc(func(text string) error {
+				for _, key := range text {
+					driver.SendKey(key, bootcommand.KeyPress)
+				}
+				return nil
+			})), nil
+		},
+	}
+
+	if action := step.Run(context.Background(), testState(t)); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.typed != "ab" {
+		t.Fatalf("typed %q", driver.typed)
+	}
+	if d := driver.times[1].Sub(driver.times[0]); d < 50*time.Millisecond {
+		t.Fatalf("the groups should be typed 50ms apart, got %s", d)
+	}
+	if driver.pacing.Hold != time.Millisecond {
+		t.Fatalf("bad pacing: %#v", driver.pacing)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"reflect"
+)
+
+// StepReporter is told of the steps run by the runners, which report them to
+// the value of the "ui" key of the state bag when it implements StepReporter.
+// The Uis of the packer package emit them as events.
+type StepReporter interface {
+	// StepStarted is called before the step runs.
+	StepStarted(name string)
+	// StepFinished is called after the step ran, telling whether it halted
+	// or cancelled the sequence.
+	StepFinished(name string, halted bool)
+}
+
+// BuildReporter is told of the sequences of steps run by the runners, which
+// report them to the value of the "ui" key of the state bag when it
+// implements BuildReporter, like StepReporter. The Uis of the packer package
+// emit them as events.
+type BuildReporter interface {
+	// BuildStarted is called before the first step runs.
+	BuildStarted()
+	// BuildFinished is called once the steps ran and were cleaned up,
+	// telling whether a step halted or cancelled the sequence, with the
+	// error why, if any: the cause of the cancellation, or the "error" of
+	// the state bag.
+	BuildFinished(halted bool, err error)
+}
+
+// StepName returns the name of a step: the name of the step it wraps, for a
+// StepWrapper, or the name of its type.
+func StepName(step Step) string {
+	if wrapped, ok := step.(StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
+	return reflect.Indirect(reflect.ValueOf(step)).Type().Name()
+}
+
+// stepReporter returns the StepReporter of state, or nil.
+func stepReporter(state StateBag) StepReporter {
+	ui, ok := state.GetOk("ui")
+	if !ok {
+		return nil
+	}
+	r, _ := ui.(StepReporter)
+	return r
+}
+
+// buildReporter returns the BuildReporter of state, or nil.
+func buildReporter(state StateBag) BuildReporter {
+	ui, ok := state.GetOk("ui")
+	if !ok {
+		return nil
+	}
+	r, _ := ui.(BuildReporter)
+	return r
+}
+
+// buildResult returns whether the steps run with state halted, and the
+// error why.
+func buildResult(ctx context.Context, state StateBag) (bool, error) {
+	if _, ok := state.GetOk(StateCancelled); ok {
+		if cause, ok := state.Get(StateCancelCause).(*CancelCause); ok {
+			return true, cause
+		}
+		if cause := Cause(ctx); cause != nil {
+			return true, cause
+		}
+		return true, &CancelCause{Reason: CancelReasonUnknown}
+	}
+	if _, ok := state.GetOk(StateHalted); ok {
+		err, _ := state.Get("error").(error)
+		return true, err
+	}
+	return false, nil
+}
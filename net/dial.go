@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FallbackDelay is how long a connection attempt to the preferred address
+// family is given before an attempt to the other family is raced with it.
+const FallbackDelay = 300 * time.Millisecond
+
+// HostPort joins host and port into an address suitable for Dial, bracketing
+// IPv6 literals. The host may be given with or without brackets.
+func HostPort(host string, port int) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// URLHost returns host as used in the authority of a URL, bracketing IPv6
+// literals.
+func URLHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// DialDualStack connects to addr, a "host:port" address. When the host name
+// resolves to both IPv4 and IPv6 addresses, a connection to each family is
+// raced, starting with the family listed first by the resolver, as described
+// by RFC 6555 ("happy eyeballs"). This way hosts connect promptly on build
+// networks where only one of the families is routable.
+//
+// On failure, the error lists the addresses the host resolves to, to tell
+// resolution problems from connectivity ones.
+func DialDualStack(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	d := &net.Dialer{
+		Timeout:       timeout,
+		FallbackDelay: FallbackDelay,
+		KeepAlive:     5 * time.Second,
+	}
+	c, err := d.DialContext(ctx, network, addr)
+	if err == nil {
+		return c, nil
+	}
+
+	host, _, splitErr := net.SplitHostPort(addr)
+	if splitErr != nil || net.ParseIP(host) != nil {
+		return nil, err
+	}
+	ips, lookupErr := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if lookupErr != nil || len(ips) == 0 {
+		return nil, err
+	}
+	resolved := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		resolved = append(resolved, ip.String())
+	}
+	return nil, fmt.Errorf("%w (%s resolves to %s)", err, host, strings.Join(resolved, ", "))
+}
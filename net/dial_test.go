@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"example.com", "example.com:22"},
+		{"10.0.0.1", "10.0.0.1:22"},
+		{"2001:db8::1", "[2001:db8::1]:22"},
+		{"[2001:db8::1]", "[2001:db8::1]:22"},
+	}
+	for _, tt := range tests {
+		if got := HostPort(tt.host, 22); got != tt.expected {
+			t.Errorf("HostPort(%q): expected %q, got %q", tt.host, tt.expected, got)
+		}
+	}
+
+	if got := URLHost("2001:db8::1"); got != "[2001:db8::1]" {
+		t.Errorf("unexpected URL host %q", got)
+	}
+	if got := URLHost("example.com"); got != "example.com" {
+		t.Errorf("unexpected URL host %q", got)
+	}
+}
+
+func TestDialDualStack(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		t.Run(host, func(t *testing.T) {
+			l, err := net.Listen("tcp", HostPort(host, 0))
+			if err != nil {
+				t.Skipf("%s is not available: %s", host, err)
+			}
+			defer l.Close()
+
+			port := l.Addr().(*net.TCPAddr).Port
+			c, err := DialDualStack(context.Background(), "tcp", HostPort(host, port), time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ArtifactStateMetadata is the name of the state of the artifacts holding
+// their ArtifactMetadata, as set by AttachArtifactMetadata.
+const ArtifactStateMetadata = "packer.artifact.metadata"
+
+// ArtifactMetadata is the standard metadata of an artifact, which
+// registries, manifests and inventories record along with it.
+//
+// Builders attach it to the state of their artifacts with
+// AttachArtifactMetadata, and post-processors read it with
+// ReadArtifactMetadata, so that it goes through the RPC connection between
+// them. An Artifact may also implement ArtifactMetadata itself.
+type ArtifactMetadata interface {
+	// Labels are free-form details of the artifact, such as the OS it runs.
+	Labels() map[string]string
+	// SourceImage is the ID of the image the artifact was built from, if
+	// any.
+	SourceImage() string
+	// BuildTime is when the artifact was built.
+	BuildTime() time.Time
+	// ComponentVersions are the versions of the components that built the
+	// artifact, by name, such as "packer" or "packer-plugin-docker".
+	ComponentVersions() map[string]string
+}
+
+// BasicArtifactMetadata is an implementation of ArtifactMetadata with fields.
+type BasicArtifactMetadata struct {
+	LabelsValue            map[string]string `json:"labels,omitempty"`
+	SourceImageValue       string            `json:"source_image,omitempty"`
+	BuildTimeValue         time.Time         `json:"build_time"`
+	ComponentVersionsValue map[string]string `json:"component_versions,omitempty"`
+}
+
+var _ ArtifactMetadata = new(BasicArtifactMetadata)
+
+func (m *BasicArtifactMetadata) Labels() map[string]string {
+	return m.LabelsValue
+}
+
+func (m *BasicArtifactMetadata) SourceImage() string {
+	return m.SourceImageValue
+}
+
+func (m *BasicArtifactMetadata) BuildTime() time.Time {
+	return m.BuildTimeValue
+}
+
+func (m *BasicArtifactMetadata) ComponentVersions() map[string]string {
+	return m.ComponentVersionsValue
+}
+
+// MarshalArtifactMetadata returns the JSON encoding of md, an object with
+// "labels", "source_image", "build_time" and "component_versions".
+func MarshalArtifactMetadata(md ArtifactMetadata) ([]byte, error) {
+	return json.Marshal(basicArtifactMetadata(md))
+}
+
+func basicArtifactMetadata(md ArtifactMetadata) *BasicArtifactMetadata {
+	return &BasicArtifactMetadata{
+		LabelsValue:            md.Labels(),
+		SourceImageValue:       md.SourceImage(),
+		BuildTimeValue:         md.BuildTime().UTC(),
+		ComponentVersionsValue: md.ComponentVersions(),
+	}
+}
+
+// UnmarshalArtifactMetadata decodes metadata encoded by
+// MarshalArtifactMetadata.
+func UnmarshalArtifactMetadata(data []byte) (*BasicArtifactMetadata, error) {
+	md := new(BasicArtifactMetadata)
+	if err := json.Unmarshal(data, md); err != nil {
+		return nil, fmt.Errorf("invalid artifact metadata: %s", err)
+	}
+	return md, nil
+}
+
+// AttachArtifactMetadata sets md as the ArtifactStateMetadata of state, the
+// state data of an artifact, encoded so that it can be sent over RPC.
+func AttachArtifactMetadata(state map[string]interface{}, md ArtifactMetadata) error {
+	data, err := MarshalArtifactMetadata(md)
+	if err != nil {
+		return err
+	}
+	state[ArtifactStateMetadata] = string(data)
+	return nil
+}
+
+// ReadArtifactMetadata returns the metadata of a, or nil when it has none.
+func ReadArtifactMetadata(a Artifact) (ArtifactMetadata, error) {
+	if md, ok := a.(ArtifactMetadata); ok {
+		return md, nil
+	}
+
+	switch v := a.State(ArtifactStateMetadata).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return UnmarshalArtifactMetadata([]byte(v))
+	case []byte:
+		return UnmarshalArtifactMetadata(v)
+	case ArtifactMetadata:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unexpected artifact metadata of type %T", v)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArtifactMetadata(t *testing.T) {
+	md := &BasicArtifactMetadata{
+		LabelsValue:            map[string]string{"os": "ubuntu"},
+		SourceImageValue:       "ami-1234",
+		BuildTimeValue:         time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		ComponentVersionsValue: map[string]string{"packer-plugin-amazon": "1.0.0"},
+	}
+	a := &MockArtifact{StateValues: map[string]interface{}{}}
+	if err := AttachArtifactMetadata(a.StateValues, md); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"labels":{"os":"ubuntu"},"source_image":"ami-1234","build_time":"2021-01-02T03:04:05Z","component_versions":{"packer-plugin-amazon":"1.0.0"}}`
+	if a.StateValues[ArtifactStateMetadata] != expected {
+		t.Fatalf("expected %s, got %s", expected, a.StateValues[ArtifactStateMetadata])
+	}
+
+	read, err := ReadArtifactMetadata(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, md) {
+		t.Fatalf("expected %#v, got %#v", md, read)
+	}
+}
+
+func TestReadArtifactMetadata(t *testing.T) {
+	if md, err := ReadArtifactMetadata(new(MockArtifact)); md != nil || err != nil {
+		t.Fatalf("expected no metadata, got %#v, %v", md, err)
+	}
+
+	a := &MockArtifact{StateValues: map[string]interface{}{ArtifactStateMetadata: "{"}}
+	if _, err := ReadArtifactMetadata(a); err == nil {
+		t.Fatal("expected an error for invalid metadata")
+	}
+	a.StateValues[ArtifactStateMetadata] = 42
+	if _, err := ReadArtifactMetadata(a); err == nil {
+		t.Fatal("expected an error for metadata of an unexpected type")
+	}
+}
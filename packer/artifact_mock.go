@@ -51,6 +51,15 @@ func (a *MockArtifact) State(name string) interface{} {
 	return value
 }
 
+// StateNames returns the names of StateValues.
+func (a *MockArtifact) StateNames() []string {
+	names := make([]string, 0, len(a.StateValues))
+	for name := range a.StateValues {
+		names = append(names, name)
+	}
+	return names
+}
+
 func (a *MockArtifact) Destroy() error {
 	a.DestroyCalled = true
 	return nil
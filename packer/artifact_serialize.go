@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// SerializedArtifactVersion is the version of the schema of the artifacts
+// serialized by SerializeArtifact.
+const SerializedArtifactVersion = 1
+
+// ArtifactStateLister is implemented by the artifacts that can list the names
+// of their state, so that SerializeArtifact serializes it.
+type ArtifactStateLister interface {
+	StateNames() []string
+}
+
+// SerializedArtifact is an artifact serialized by SerializeArtifact.
+type SerializedArtifact struct {
+	SchemaVersion int                      `json:"schema_version"`
+	ID            string                   `json:"id"`
+	BuilderID     string                   `json:"builder_id"`
+	Files         []SerializedArtifactFile `json:"files"`
+	State         map[string]interface{}   `json:"state,omitempty"`
+	Metadata      *BasicArtifactMetadata   `json:"metadata,omitempty"`
+}
+
+// SerializedArtifactFile is a file of a SerializedArtifact.
+type SerializedArtifactFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SerializeArtifact returns the JSON encoding of a, in the schema of
+// SerializedArtifact, so that manifest, registry and inventory
+// post-processors all record artifacts the same way. The encoding is stable:
+// the files are in the order of a.Files, and the state is sorted by name.
+//
+// The files are hashed, so they must be local. The state is only serialized
+// for the artifacts that are ArtifactStateListers, and the metadata is the
+// one read by ReadArtifactMetadata.
+func SerializeArtifact(a Artifact) ([]byte, error) {
+	s := SerializedArtifact{
+		SchemaVersion: SerializedArtifactVersion,
+		ID:            a.Id(),
+		BuilderID:     a.BuilderId(),
+		Files:         []SerializedArtifactFile{},
+	}
+
+	for _, name := range a.Files() {
+		f, err := serializeArtifactFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("error serializing artifact file: %s", err)
+		}
+		s.Files = append(s.Files, f)
+	}
+
+	if lister, ok := a.(ArtifactStateLister); ok {
+		names := lister.StateNames()
+		sort.Strings(names)
+		for _, name := range names {
+			if name == ArtifactStateMetadata {
+				continue
+			}
+			if s.State == nil {
+				s.State = make(map[string]interface{})
+			}
+			s.State[name] = a.State(name)
+		}
+	}
+
+	md, err := ReadArtifactMetadata(a)
+	if err != nil {
+		return nil, err
+	}
+	if md != nil {
+		s.Metadata = basicArtifactMetadata(md)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing artifact %s: %s", a.Id(), err)
+	}
+	return data, nil
+}
+
+func serializeArtifactFile(name string) (SerializedArtifactFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return SerializedArtifactFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return SerializedArtifactFile{}, err
+	}
+	return SerializedArtifactFile{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
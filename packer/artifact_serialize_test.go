@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSerializeArtifact(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "image.qcow2")
+	if err := ioutil.WriteFile(file, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &MockArtifact{
+		FilesValue:  []string{file},
+		StateValues: map[string]interface{}{"zone": "a", "disk_size": 10},
+	}
+	err := AttachArtifactMetadata(a.StateValues, &BasicArtifactMetadata{
+		SourceImageValue: "ubuntu",
+		BuildTimeValue:   time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := SerializeArtifact(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"schema_version":1,"id":"id","builder_id":"bid","files":[{"name":"` + file + `","size":5,` +
+		`"sha256":"6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d"}],` +
+		`"state":{"disk_size":10,"zone":"a"},` +
+		`"metadata":{"source_image":"ubuntu","build_time":"2021-01-02T03:04:05Z"}}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+}
+
+func TestSerializeArtifact_errors(t *testing.T) {
+	if _, err := SerializeArtifact(&MockArtifact{FilesValue: []string{"missing"}}); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+	a := &MockArtifact{
+		FilesValue:  []string{},
+		StateValues: map[string]interface{}{"callback": func() {}},
+	}
+	if _, err := SerializeArtifact(a); err == nil {
+		t.Fatal("expected an error for state that can't be serialized")
+	}
+}
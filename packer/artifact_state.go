@@ -0,0 +1,153 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The well-known names of the state of the artifacts, read by the typed
+// accessors below.
+const (
+	// ArtifactStateGeneratedData is the data generated by the build, a
+	// map[string]interface{}, as passed to the provisioners.
+	ArtifactStateGeneratedData = "generated_data"
+	// ArtifactStateSourceImageID is the ID of the image the artifact was
+	// built from, a string.
+	ArtifactStateSourceImageID = "source_image_id"
+	// ArtifactStateRegions are the IDs of the artifact by region, a
+	// map[string]string, for the artifacts copied to several regions.
+	ArtifactStateRegions = "regions"
+	// ArtifactStateVersioned is the ArtifactStateEnvelope of the artifact,
+	// its well-known state versioned, as set by AttachArtifactStateEnvelope.
+	ArtifactStateVersioned = "packer.artifact.state"
+)
+
+// ArtifactStateEnvelopeVersion is the version of the ArtifactStateEnvelopes
+// attached by AttachArtifactStateEnvelope.
+const ArtifactStateEnvelopeVersion = 1
+
+// ArtifactStateEnvelope holds the well-known state of an artifact, encoded
+// together and versioned so that it can be read by post-processors of
+// another version than the builder.
+type ArtifactStateEnvelope struct {
+	Version       int                    `json:"version"`
+	GeneratedData map[string]interface{} `json:"generated_data,omitempty"`
+	SourceImageID string                 `json:"source_image_id,omitempty"`
+	Regions       map[string]string      `json:"regions,omitempty"`
+}
+
+// AttachArtifactStateEnvelope sets env as the ArtifactStateEnvelope of state,
+// the state data of an artifact, along with each of its fields under their
+// well-known names for the post-processors which don't read envelopes.
+func AttachArtifactStateEnvelope(state map[string]interface{}, env ArtifactStateEnvelope) error {
+	env.Version = ArtifactStateEnvelopeVersion
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("error encoding artifact state: %s", err)
+	}
+	state[ArtifactStateVersioned] = string(data)
+
+	if env.GeneratedData != nil {
+		state[ArtifactStateGeneratedData] = env.GeneratedData
+	}
+	if env.SourceImageID != "" {
+		state[ArtifactStateSourceImageID] = env.SourceImageID
+	}
+	if env.Regions != nil {
+		state[ArtifactStateRegions] = env.Regions
+	}
+	return nil
+}
+
+// ReadArtifactStateEnvelope returns the ArtifactStateEnvelope of a, or nil
+// when it has none. It fails for envelopes of a later version.
+func ReadArtifactStateEnvelope(a Artifact) (*ArtifactStateEnvelope, error) {
+	var data []byte
+	switch v := a.State(ArtifactStateVersioned).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, fmt.Errorf("unexpected artifact state envelope of type %T", v)
+	}
+
+	env := new(ArtifactStateEnvelope)
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, fmt.Errorf("invalid artifact state envelope: %s", err)
+	}
+	if env.Version > ArtifactStateEnvelopeVersion {
+		return nil, fmt.Errorf("unsupported artifact state envelope version %d, expected up to %d",
+			env.Version, ArtifactStateEnvelopeVersion)
+	}
+	return env, nil
+}
+
+// ArtifactGeneratedData returns the ArtifactStateGeneratedData of a, from its
+// envelope or its state, and whether it has any.
+func ArtifactGeneratedData(a Artifact) (map[string]interface{}, bool) {
+	if env, err := ReadArtifactStateEnvelope(a); err == nil && env != nil && env.GeneratedData != nil {
+		return env.GeneratedData, true
+	}
+	return stringKeyedMap(a.State(ArtifactStateGeneratedData))
+}
+
+// ArtifactSourceImageID returns the ArtifactStateSourceImageID of a, from its
+// envelope or its state, and whether it has one.
+func ArtifactSourceImageID(a Artifact) (string, bool) {
+	if env, err := ReadArtifactStateEnvelope(a); err == nil && env != nil && env.SourceImageID != "" {
+		return env.SourceImageID, true
+	}
+	id, ok := a.State(ArtifactStateSourceImageID).(string)
+	return id, ok && id != ""
+}
+
+// ArtifactRegions returns the ArtifactStateRegions of a, from its envelope or
+// its state, and whether it has any.
+func ArtifactRegions(a Artifact) (map[string]string, bool) {
+	if env, err := ReadArtifactStateEnvelope(a); err == nil && env != nil && env.Regions != nil {
+		return env.Regions, true
+	}
+	if regions, ok := a.State(ArtifactStateRegions).(map[string]string); ok {
+		return regions, true
+	}
+	m, ok := stringKeyedMap(a.State(ArtifactStateRegions))
+	if !ok {
+		return nil, false
+	}
+	regions := make(map[string]string, len(m))
+	for region, id := range m {
+		s, ok := id.(string)
+		if !ok {
+			return nil, false
+		}
+		regions[region] = s
+	}
+	return regions, true
+}
+
+// stringKeyedMap returns v as a map[string]interface{}, converting the
+// maps of interface{} keys that maps are decoded to over RPC.
+func stringKeyedMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			s, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			converted[s] = v
+		}
+		return converted, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArtifactStateEnvelope(t *testing.T) {
+	a := &MockArtifact{StateValues: map[string]interface{}{}}
+	env := ArtifactStateEnvelope{
+		GeneratedData: map[string]interface{}{"SourceAMIName": "ubuntu"},
+		SourceImageID: "ami-1234",
+		Regions:       map[string]string{"us-east-1": "ami-5678"},
+	}
+	if err := AttachArtifactStateEnvelope(a.StateValues, env); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadArtifactStateEnvelope(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Version = ArtifactStateEnvelopeVersion
+	if !reflect.DeepEqual(*read, env) {
+		t.Fatalf("expected %#v, got %#v", env, read)
+	}
+	if a.StateValues[ArtifactStateSourceImageID] != "ami-1234" {
+		t.Fatalf("expected the well-known state to be set, got %#v", a.StateValues)
+	}
+
+	a.StateValues[ArtifactStateVersioned] = `{"version":2}`
+	if _, err := ReadArtifactStateEnvelope(a); err == nil {
+		t.Fatal("expected an error for a later version")
+	}
+}
+
+func TestArtifactStateAccessors(t *testing.T) {
+	// State decoded over RPC
+	a := &MockArtifact{StateValues: map[string]interface{}{
+		ArtifactStateGeneratedData: map[interface{}]interface{}{"SourceAMIName": "ubuntu"},
+		ArtifactStateSourceImageID: "ami-1234",
+		ArtifactStateRegions:       map[interface{}]interface{}{"us-east-1": "ami-5678"},
+	}}
+
+	if data, ok := ArtifactGeneratedData(a); !ok || data["SourceAMIName"] != "ubuntu" {
+		t.Fatalf("unexpected generated data %#v", data)
+	}
+	if id, ok := ArtifactSourceImageID(a); !ok || id != "ami-1234" {
+		t.Fatalf("unexpected source image id %q", id)
+	}
+	if regions, ok := ArtifactRegions(a); !ok || regions["us-east-1"] != "ami-5678" {
+		t.Fatalf("unexpected regions %#v", regions)
+	}
+
+	a = new(MockArtifact)
+	if _, ok := ArtifactGeneratedData(a); ok {
+		t.Fatal("expected no generated data")
+	}
+	if _, ok := ArtifactSourceImageID(a); ok {
+		t.Fatal("expected no source image id")
+	}
+	if _, ok := ArtifactRegions(a); ok {
+		t.Fatal("expected no regions")
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package attestation produces signed provenance documents for artifacts,
+recording how they were built: the template, the materials such as ISOs and
+their checksums, and the provisioners that ran.
+
+The documents are in-toto statements with a SLSA provenance predicate,
+signed in a DSSE envelope by a Signer, a local key or a KMS key, so that they
+can be verified with the usual supply chain tools.
+*/
+package attestation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// StatementType is the type of the in-toto statements.
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	// PredicateType is the type of the provenance predicate of the
+	// statements.
+	PredicateType = "https://slsa.dev/provenance/v0.2"
+	// BuildType is the type of the builds of the provenance predicates.
+	BuildType = "https://www.packer.io/attestation/build/v1"
+)
+
+// Statement is an in-toto statement of the provenance of an artifact.
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is an artifact, or one of its files, and its digests.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is a SLSA provenance predicate.
+type Provenance struct {
+	Builder     Builder     `json:"builder"`
+	BuildType   string      `json:"buildType"`
+	Invocation  Invocation  `json:"invocation"`
+	BuildConfig BuildConfig `json:"buildConfig"`
+	Metadata    Metadata    `json:"metadata"`
+	Materials   []Material  `json:"materials,omitempty"`
+}
+
+// Builder identifies the builder of the artifact.
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// Invocation is what the build was started with.
+type Invocation struct {
+	ConfigSource ConfigSource `json:"configSource"`
+}
+
+// ConfigSource is the template of the build.
+type ConfigSource struct {
+	URI        string            `json:"uri,omitempty"`
+	Digest     map[string]string `json:"digest,omitempty"`
+	EntryPoint string            `json:"entryPoint,omitempty"`
+}
+
+// BuildConfig are the steps of the build beyond the builder.
+type BuildConfig struct {
+	Provisioners []string `json:"provisioners,omitempty"`
+}
+
+// Metadata is when the build ran.
+type Metadata struct {
+	BuildStartedOn  *time.Time `json:"buildStartedOn,omitempty"`
+	BuildFinishedOn *time.Time `json:"buildFinishedOn,omitempty"`
+}
+
+// Material is an input of the build, such as an ISO, and its digests.
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// Inputs describe how an artifact was built.
+type Inputs struct {
+	// Build is the name of the build, the entry point of the template.
+	Build string
+	// TemplatePath is the path of the template, which is hashed.
+	TemplatePath string
+	// Materials are the inputs of the build, such as ISOs, see
+	// MaterialFromChecksum.
+	Materials []Material
+	// Provisioners are the types of the provisioners that ran, in order.
+	Provisioners []string
+	// Started and Finished are when the build ran.
+	Started  time.Time
+	Finished time.Time
+}
+
+// NewStatement returns the provenance statement of a, built with in. The
+// subjects are the artifact, by ID, and each of its files, hashed.
+//
+// The digest of the artifact subject is the SHA-256 digest of its ID, not of
+// its content: most artifacts, like cloud images, are stored out of reach of
+// Packer, and their ID is all that names them. Only the subjects of the
+// files of the artifact vouch for its content.
+func NewStatement(a packer.Artifact, in Inputs) (*Statement, error) {
+	s := &Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Subject: []Subject{{
+			Name:   a.Id(),
+			Digest: map[string]string{"sha256": sha256Hex([]byte(a.Id()))},
+		}},
+		Predicate: Provenance{
+			Builder:   Builder{ID: a.BuilderId()},
+			BuildType: BuildType,
+			BuildConfig: BuildConfig{
+				Provisioners: in.Provisioners,
+			},
+			Materials: in.Materials,
+		},
+	}
+
+	for _, path := range a.Files() {
+		digest, err := fileSHA256(path)
+		if err != nil {
+			return nil, fmt.Errorf("error hashing artifact file: %s", err)
+		}
+		s.Subject = append(s.Subject, Subject{Name: path, Digest: map[string]string{"sha256": digest}})
+	}
+
+	source := &s.Predicate.Invocation.ConfigSource
+	source.EntryPoint = in.Build
+	if in.TemplatePath != "" {
+		digest, err := fileSHA256(in.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("error hashing template: %s", err)
+		}
+		source.URI = in.TemplatePath
+		source.Digest = map[string]string{"sha256": digest}
+	}
+
+	if !in.Started.IsZero() {
+		started := in.Started.UTC()
+		s.Predicate.Metadata.BuildStartedOn = &started
+	}
+	if !in.Finished.IsZero() {
+		finished := in.Finished.UTC()
+		s.Predicate.Metadata.BuildFinishedOn = &finished
+	}
+	return s, nil
+}
+
+// checksumTypes are the checksum types guessed from the length of checksums
+// without a type.
+var checksumTypes = map[int]string{
+	32:  "md5",
+	40:  "sha1",
+	64:  "sha256",
+	128: "sha512",
+}
+
+// MaterialFromChecksum returns the material of uri with checksum, in the
+// "type:value" format of iso_checksum, such as "sha256:...". The type is
+// guessed from the length of checksums without one.
+func MaterialFromChecksum(uri, checksum string) (Material, error) {
+	typ, value := "", checksum
+	if i := strings.Index(checksum, ":"); i >= 0 {
+		typ, value = strings.ToLower(checksum[:i]), checksum[i+1:]
+	} else {
+		typ = checksumTypes[len(checksum)]
+	}
+	if typ == "" || value == "" {
+		return Material{}, fmt.Errorf("invalid checksum %q of %s", checksum, uri)
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return Material{}, fmt.Errorf("invalid checksum %q of %s: %s", checksum, uri, err)
+	}
+	return Material{URI: uri, Digest: map[string]string{typ: strings.ToLower(value)}}, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testStatement(t *testing.T) *Statement {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "image.qcow2")
+	template := filepath.Join(dir, "build.pkr.hcl")
+	if err := ioutil.WriteFile(image, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(template, []byte("source {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	iso, err := MaterialFromChecksum("https://example.com/ubuntu.iso", "sha256:"+sha256Hex([]byte("iso")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStatement(&packer.MockArtifact{FilesValue: []string{image}}, Inputs{
+		Build:        "qemu.ubuntu",
+		TemplatePath: template,
+		Materials:    []Material{iso},
+		Provisioners: []string{"shell", "file"},
+		Started:      time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Finished:     time.Date(2021, 1, 2, 3, 14, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewStatement(t *testing.T) {
+	s := testStatement(t)
+
+	if len(s.Subject) != 2 || s.Subject[0].Name != "id" || s.Subject[1].Digest["sha256"] != sha256Hex([]byte("image")) {
+		t.Fatalf("unexpected subjects %#v", s.Subject)
+	}
+	if s.Predicate.Builder.ID != "bid" {
+		t.Fatalf("unexpected builder %q", s.Predicate.Builder.ID)
+	}
+	source := s.Predicate.Invocation.ConfigSource
+	if source.EntryPoint != "qemu.ubuntu" || source.Digest["sha256"] != sha256Hex([]byte("source {}")) {
+		t.Fatalf("unexpected config source %#v", source)
+	}
+	if len(s.Predicate.BuildConfig.Provisioners) != 2 || len(s.Predicate.Materials) != 1 {
+		t.Fatalf("unexpected build config %#v", s.Predicate)
+	}
+
+	if _, err := NewStatement(&packer.MockArtifact{FilesValue: []string{"missing"}}, Inputs{}); err == nil {
+		t.Fatal("expected an error for a missing artifact file")
+	}
+}
+
+func TestMaterialFromChecksum(t *testing.T) {
+	for checksum, expected := range map[string]string{
+		"sha256:" + sha256Hex(nil):                 "sha256",
+		"MD5:d41d8cd98f00b204e9800998ecf8427e":     "md5",
+		"da39a3ee5e6b4b0d3255bfef95601890afd80709": "sha1",
+	} {
+		m, err := MaterialFromChecksum("file.iso", checksum)
+		if err != nil {
+			t.Fatalf("%s: %s", checksum, err)
+		}
+		if len(m.Digest) != 1 || m.Digest[expected] == "" {
+			t.Fatalf("%s: unexpected digest %#v", checksum, m.Digest)
+		}
+	}
+
+	for _, checksum := range []string{"", "abc", "sha256:", "sha256:xyz"} {
+		if _, err := MaterialFromChecksum("file.iso", checksum); err == nil {
+			t.Errorf("expected an error for checksum %q", checksum)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s := testStatement(t)
+
+	for _, signer := range []*LocalSigner{
+		{Key: edKey, ID: "ed25519"},
+		{Key: ecKey, ID: "ecdsa"},
+	} {
+		env, err := Sign(context.Background(), s, signer)
+		if err != nil {
+			t.Fatalf("%s: %s", signer.ID, err)
+		}
+		verified, err := Verify(env, signer.Verifier())
+		if err != nil {
+			t.Fatalf("%s: %s", signer.ID, err)
+		}
+		if verified.Subject[1].Digest["sha256"] != s.Subject[1].Digest["sha256"] {
+			t.Fatalf("%s: unexpected statement %#v", signer.ID, verified)
+		}
+
+		env.Payload = env.Payload[4:]
+		if _, err := Verify(env, signer.Verifier()); err == nil {
+			t.Fatalf("%s: expected an error for a tampered payload", signer.ID)
+		}
+	}
+}
+
+func TestLoadLocalSigner(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := LoadLocalSigner(path, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signer.Key.Public().(*ecdsa.PublicKey).Equal(&key.PublicKey) {
+		t.Fatal("unexpected key")
+	}
+}
+
+// kmsMock signs with a local key.
+type kmsMock struct {
+	kmsiface.KMSAPI
+	key crypto.Signer
+}
+
+func (m *kmsMock) SignWithContext(_ aws.Context, in *kms.SignInput, _ ...request.Option) (*kms.SignOutput, error) {
+	hash, pss, err := kmsAlgorithm(*in.SigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.Message) != hash.Size() {
+		return nil, fmt.Errorf("digest of %d bytes for %s", len(in.Message), *in.SigningAlgorithm)
+	}
+	var opts crypto.SignerOpts = hash
+	if pss {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	sig, err := m.key.Sign(rand.Reader, in.Message, opts)
+	return &kms.SignOutput{Signature: sig, KeyId: in.KeyId}, err
+}
+
+func TestKMSSigner(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer := &KMSSigner{Client: &kmsMock{key: key}, KeyId: "alias/packer"}
+
+	env, err := Sign(context.Background(), testStatement(t), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Signatures[0].KeyID != "alias/packer" {
+		t.Fatalf("unexpected key id %q", env.Signatures[0].KeyID)
+	}
+	if _, err := Verify(env, &LocalVerifier{Key: &key.PublicKey, ID: "alias/packer"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	for _, tc := range []struct {
+		algorithm string
+		key       crypto.Signer
+	}{
+		{kms.SigningAlgorithmSpecEcdsaSha384, ecKey},
+		{kms.SigningAlgorithmSpecRsassaPkcs1V15Sha512, rsaKey},
+		{kms.SigningAlgorithmSpecRsassaPssSha256, rsaKey},
+		{kms.SigningAlgorithmSpecRsassaPssSha384, rsaKey},
+	} {
+		signer := &KMSSigner{Client: &kmsMock{key: tc.key}, KeyId: "alias/packer", Algorithm: tc.algorithm}
+		env, err := Sign(context.Background(), testStatement(t), signer)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.algorithm, err)
+		}
+		verifier := &LocalVerifier{Key: tc.key.Public(), ID: "alias/packer", Algorithm: tc.algorithm}
+		if _, err := Verify(env, verifier); err != nil {
+			t.Fatalf("%s: %s", tc.algorithm, err)
+		}
+		verifier.Algorithm = ""
+		if _, err := Verify(env, verifier); err == nil {
+			t.Fatalf("%s: expected an error verifying with the default algorithm", tc.algorithm)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package attestation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// PayloadType is the type of the payload of the envelopes, a Statement.
+const PayloadType = "application/vnd.in-toto+json"
+
+// Envelope is a DSSE envelope holding a signed Statement.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of the payload of an Envelope.
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// Sign returns the envelope of s, signed by each of signers.
+func Sign(ctx context.Context, s *Statement, signers ...Signer) (*Envelope, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("no signer for the attestation")
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+	}
+	message := pae(PayloadType, payload)
+	for _, signer := range signers {
+		sig, err := signer.Sign(ctx, message)
+		if err != nil {
+			return nil, fmt.Errorf("error signing the attestation with %s: %s", signer.KeyID(), err)
+		}
+		env.Signatures = append(env.Signatures, Signature{
+			KeyID: signer.KeyID(),
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		})
+	}
+	return env, nil
+}
+
+// Verify checks that env has a valid signature of verifier, and returns its
+// statement.
+func Verify(env *Envelope, verifier Verifier) (*Statement, error) {
+	if env.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %s", err)
+	}
+
+	message := pae(env.PayloadType, payload)
+	verified := false
+	for _, sig := range env.Signatures {
+		if sig.KeyID != "" && verifier.KeyID() != "" && sig.KeyID != verifier.KeyID() {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+		if err != nil {
+			continue
+		}
+		if verifier.Verify(message, raw) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("no valid signature of the attestation")
+	}
+
+	s := new(Statement)
+	if err := json.Unmarshal(payload, s); err != nil {
+		return nil, fmt.Errorf("invalid statement: %s", err)
+	}
+	return s, nil
+}
+
+// pae returns the pre-authentication encoding of a payload, which is what
+// is signed.
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
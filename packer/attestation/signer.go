@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// A Signer signs attestations.
+type Signer interface {
+	// KeyID identifies the key of the signatures, to find the key to
+	// verify them with.
+	KeyID() string
+	// Sign returns the signature of message.
+	Sign(ctx context.Context, message []byte) ([]byte, error)
+}
+
+// A Verifier verifies the signatures of a Signer.
+type Verifier interface {
+	KeyID() string
+	Verify(message, sig []byte) error
+}
+
+// LocalSigner signs with a private key, an Ed25519, ECDSA or RSA key. ECDSA
+// and RSA keys sign the SHA-256 digest of the messages, with PKCS #1 v1.5 for
+// RSA.
+type LocalSigner struct {
+	Key crypto.Signer
+	ID  string
+}
+
+var _ Signer = new(LocalSigner)
+
+// LoadLocalSigner returns a signer with the PEM encoded private key at path,
+// in PKCS #8, PKCS #1 or SEC 1 format.
+func LoadLocalSigner(path, id string) (*LocalSigner, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key in %s", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing key %s: %s", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key of type %T in %s", key, path)
+	}
+	return &LocalSigner{Key: signer, ID: id}, nil
+}
+
+func (s *LocalSigner) KeyID() string {
+	return s.ID
+}
+
+func (s *LocalSigner) Sign(_ context.Context, message []byte) ([]byte, error) {
+	if _, ok := s.Key.(ed25519.PrivateKey); ok {
+		return s.Key.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(message)
+	return s.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// Verifier returns a verifier of the signatures of s.
+func (s *LocalSigner) Verifier() *LocalVerifier {
+	return &LocalVerifier{Key: s.Key.Public(), ID: s.ID}
+}
+
+// LocalVerifier verifies the signatures of a LocalSigner, or of a KMSSigner,
+// with its public key.
+type LocalVerifier struct {
+	Key crypto.PublicKey
+	ID  string
+	// Algorithm is the signing algorithm of the KMSSigner of the
+	// signatures, such as RSASSA_PSS_SHA_384. It is empty for the
+	// signatures of a LocalSigner, or of a KMSSigner with its default
+	// algorithm, ECDSA_SHA_256.
+	Algorithm string
+}
+
+var _ Verifier = new(LocalVerifier)
+
+func (v *LocalVerifier) KeyID() string {
+	return v.ID
+}
+
+func (v *LocalVerifier) Verify(message, sig []byte) error {
+	if key, ok := v.Key.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(key, message, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	hash, pss := crypto.SHA256, false
+	if v.Algorithm != "" {
+		var err error
+		if hash, pss, err = kmsAlgorithm(v.Algorithm); err != nil {
+			return err
+		}
+	}
+	h := hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+	switch key := v.Key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if pss {
+			return rsa.VerifyPSS(key, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig)
+	default:
+		return fmt.Errorf("unsupported key of type %T", v.Key)
+	}
+}
+
+// KMSSigner signs with an asymmetric AWS KMS key, which never leaves KMS.
+type KMSSigner struct {
+	Client kmsiface.KMSAPI
+	// KeyId is the ID, ARN or alias of the key.
+	KeyId string
+	// Algorithm is the signing algorithm of the key, one of the ECDSA_SHA_*,
+	// RSASSA_PKCS1_V1_5_SHA_* and RSASSA_PSS_SHA_* algorithms. Defaults to
+	// ECDSA_SHA_256.
+	Algorithm string
+}
+
+var _ Signer = new(KMSSigner)
+
+func (s *KMSSigner) KeyID() string {
+	return s.KeyId
+}
+
+// Sign signs the digest of message, with the hash of the algorithm of s,
+// with KMS.
+func (s *KMSSigner) Sign(ctx context.Context, message []byte) ([]byte, error) {
+	algorithm := s.Algorithm
+	if algorithm == "" {
+		algorithm = kms.SigningAlgorithmSpecEcdsaSha256
+	}
+	hash, _, err := kmsAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(message)
+	out, err := s.Client.SignWithContext(ctx, &kms.SignInput{
+		KeyId:            aws.String(s.KeyId),
+		Message:          h.Sum(nil),
+		MessageType:      aws.String(kms.MessageTypeDigest),
+		SigningAlgorithm: aws.String(algorithm),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Signature, nil
+}
+
+// kmsAlgorithm returns the hash of the KMS signing algorithm, and whether it
+// is an RSASSA-PSS one.
+func kmsAlgorithm(algorithm string) (hash crypto.Hash, pss bool, err error) {
+	switch algorithm {
+	case kms.SigningAlgorithmSpecEcdsaSha256, kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256:
+		return crypto.SHA256, false, nil
+	case kms.SigningAlgorithmSpecEcdsaSha384, kms.SigningAlgorithmSpecRsassaPkcs1V15Sha384:
+		return crypto.SHA384, false, nil
+	case kms.SigningAlgorithmSpecEcdsaSha512, kms.SigningAlgorithmSpecRsassaPkcs1V15Sha512:
+		return crypto.SHA512, false, nil
+	case kms.SigningAlgorithmSpecRsassaPssSha256:
+		return crypto.SHA256, true, nil
+	case kms.SigningAlgorithmSpecRsassaPssSha384:
+		return crypto.SHA384, true, nil
+	case kms.SigningAlgorithmSpecRsassaPssSha512:
+		return crypto.SHA512, true, nil
+	default:
+		return 0, false, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
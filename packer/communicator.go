@@ -6,6 +6,7 @@ package packer
 import (
 	"context"
 	"io"
+	"net"
 	"os"
 	"strings"
 	"sync"
@@ -36,6 +37,11 @@ type RemoteCmd struct {
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// Pty, when set, overrides whether the communicator allocates a
+	// pseudo-terminal for the command. Communicators without terminals
+	// ignore it.
+	Pty *bool
+
 	// Once Exited is true, this will contain the exit code of the process.
 	exitStatus int
 
@@ -66,8 +72,9 @@ type Communicator interface {
 	Upload(string, io.Reader, *os.FileInfo) error
 
 	// UploadDir uploads the contents of a directory recursively to
-	// the remote path. It also takes an optional slice of paths to
-	// ignore when uploading.
+	// the remote path. It also takes an optional slice of glob patterns
+	// of paths to ignore when uploading, matched against paths relative
+	// to the source directory or their base names.
 	//
 	// The folder name of the source folder should be created unless there
 	// is a trailing slash on the source "/". For example: "/tmp/src" as
@@ -77,12 +84,77 @@ type Communicator interface {
 
 	// Download downloads a file from the machine from the given remote path
 	// with the contents writing to the given writer. This method will
-	// block until it completes.
+	// block until it completes. Communicators may accept a glob pattern
+	// matching a single file as the path.
 	Download(string, io.Writer) error
 
+	// DownloadDir downloads a remote directory recursively into the local
+	// path dst, creating the source folder within it, skipping paths
+	// matching the exclude glob patterns. Communicators may accept a glob
+	// pattern as the source, to download every match into dst.
 	DownloadDir(src string, dst string, exclude []string) error
 }
 
+// PortForwarder is implemented by communicators that can forward TCP
+// connections through their connection to the machine. Forwards last until
+// they are closed or the connection they were opened on is.
+type PortForwarder interface {
+	// ForwardLocal listens on localAddr on the local host, and forwards the
+	// connections it accepts to remoteAddr, dialed from the machine.
+	ForwardLocal(localAddr string, remoteAddr string) (PortForward, error)
+
+	// ForwardRemote listens on remoteAddr on the machine, and forwards the
+	// connections it accepts to localAddr, dialed from the local host.
+	ForwardRemote(remoteAddr string, localAddr string) (PortForward, error)
+}
+
+// PortForward is a port forward opened by a PortForwarder.
+type PortForward interface {
+	// Addr returns the address the forward listens on, which tells the
+	// port that was picked when listening on port 0.
+	Addr() net.Addr
+
+	// Close stops forwarding connections.
+	Close() error
+}
+
+// BatchCommunicator is implemented by communicators that can run several
+// commands in a single remote session, saving the cost of setting up a
+// session for each of them. Use RunBatch to run commands with any
+// communicator.
+type BatchCommunicator interface {
+	// StartBatch starts the commands, to be run in order in a single remote
+	// session. Like Start, it returns once the commands are started, and each
+	// command is marked as exited with its own exit status once it ran.
+	// Commands run without standard input, their Stdin being ignored.
+	StartBatch(context.Context, []*RemoteCmd) error
+}
+
+// RunBatch runs the commands in order and waits for all of them to exit,
+// using a single remote session when c is a BatchCommunicator and a session
+// per command otherwise. A command exiting with a non-zero status does not
+// stop the following ones: the exit status of each command is reported by
+// its ExitStatus method.
+func RunBatch(ctx context.Context, c Communicator, cmds []*RemoteCmd) error {
+	if bc, ok := c.(BatchCommunicator); ok {
+		if err := bc.StartBatch(ctx, cmds); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			cmd.Wait()
+		}
+		return nil
+	}
+
+	for _, cmd := range cmds {
+		if err := c.Start(ctx, cmd); err != nil {
+			return err
+		}
+		cmd.Wait()
+	}
+	return nil
+}
+
 type ConfigurableCommunicator interface {
 	HCL2Speccer
 	Configure(...interface{}) ([]string, error)
@@ -89,3 +89,20 @@ func TestRemoteCmd_Wait(t *testing.T) {
 		t.Fatal("never got exit notification")
 	}
 }
+
+func TestRunBatch(t *testing.T) {
+	c := &MockCommunicator{StartStdout: "out", StartExitStatus: 1}
+	var cmds []*RemoteCmd
+	for i := 0; i < 2; i++ {
+		cmds = append(cmds, &RemoteCmd{Command: "true", Stdout: new(bytes.Buffer)})
+	}
+
+	if err := RunBatch(context.Background(), c, cmds); err != nil {
+		t.Fatal(err)
+	}
+	for i, cmd := range cmds {
+		if cmd.ExitStatus() != 1 {
+			t.Fatalf("command %d: unexpected exit status %d", i, cmd.ExitStatus())
+		}
+	}
+}
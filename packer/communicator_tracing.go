@@ -0,0 +1,168 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
+)
+
+// TraceCommunicator returns comm recording a tracing span for each of its
+// operations. The communicator returned is a PortForwarder, an io.Closer or a
+// SessionCommunicator when comm is. Commands run with StartBatch are traced
+// when comm is a BatchCommunicator, and started one by one otherwise.
+func TraceCommunicator(comm Communicator) Communicator {
+	t := &tracedCommunicator{comm: comm}
+	pf, isForwarder := comm.(PortForwarder)
+	closer, isCloser := comm.(io.Closer)
+	var sessioner *tracedSessioner
+	if sc, ok := comm.(SessionCommunicator); ok {
+		sessioner = &tracedSessioner{sc}
+	}
+
+	switch {
+	case isForwarder && isCloser && sessioner != nil:
+		return struct {
+			*tracedCommunicator
+			PortForwarder
+			io.Closer
+			*tracedSessioner
+		}{t, pf, closer, sessioner}
+	case isForwarder && isCloser:
+		return struct {
+			*tracedCommunicator
+			PortForwarder
+			io.Closer
+		}{t, pf, closer}
+	case isForwarder && sessioner != nil:
+		return struct {
+			*tracedCommunicator
+			PortForwarder
+			*tracedSessioner
+		}{t, pf, sessioner}
+	case isCloser && sessioner != nil:
+		return struct {
+			*tracedCommunicator
+			io.Closer
+			*tracedSessioner
+		}{t, closer, sessioner}
+	case isForwarder:
+		return struct {
+			*tracedCommunicator
+			PortForwarder
+		}{t, pf}
+	case isCloser:
+		return struct {
+			*tracedCommunicator
+			io.Closer
+		}{t, closer}
+	case sessioner != nil:
+		return struct {
+			*tracedCommunicator
+			*tracedSessioner
+		}{t, sessioner}
+	default:
+		return t
+	}
+}
+
+type tracedCommunicator struct {
+	comm Communicator
+}
+
+func (c *tracedCommunicator) Start(ctx context.Context, cmd *RemoteCmd) error {
+	ctx, span := tracing.Start(ctx, "communicator start",
+		tracing.Attribute{Key: "packer.command", Value: LogSecretFilter.FilterString(cmd.Command)})
+	err := c.comm.Start(ctx, cmd)
+	if err != nil {
+		tracing.End(span, err)
+		return err
+	}
+	go func() {
+		cmd.Wait()
+		span.SetAttributes(tracing.Attribute{Key: "packer.exit_status", Value: cmd.ExitStatus()})
+		span.End()
+	}()
+	return nil
+}
+
+func (c *tracedCommunicator) StartBatch(ctx context.Context, cmds []*RemoteCmd) error {
+	ctx, span := tracing.Start(ctx, "communicator start batch",
+		tracing.Attribute{Key: "packer.commands", Value: len(cmds)})
+	if bc, ok := c.comm.(BatchCommunicator); ok {
+		if err := bc.StartBatch(ctx, cmds); err != nil {
+			tracing.End(span, err)
+			return err
+		}
+		go func() {
+			for _, cmd := range cmds {
+				cmd.Wait()
+			}
+			span.End()
+		}()
+		return nil
+	}
+
+	// Start the commands in order, like a batch would run them.
+	go func() {
+		defer span.End()
+		for _, cmd := range cmds {
+			if err := c.comm.Start(ctx, cmd); err != nil {
+				span.RecordError(err)
+				cmd.SetExited(1)
+				continue
+			}
+			cmd.Wait()
+		}
+	}()
+	return nil
+}
+
+func (c *tracedCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	_, span := tracing.Start(context.Background(), "communicator upload",
+		tracing.Attribute{Key: "packer.path", Value: dst})
+	err := c.comm.Upload(dst, r, fi)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *tracedCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	_, span := tracing.Start(context.Background(), "communicator upload dir",
+		tracing.Attribute{Key: "packer.path", Value: dst})
+	err := c.comm.UploadDir(dst, src, exclude)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *tracedCommunicator) Download(src string, w io.Writer) error {
+	_, span := tracing.Start(context.Background(), "communicator download",
+		tracing.Attribute{Key: "packer.path", Value: src})
+	err := c.comm.Download(src, w)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *tracedCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	_, span := tracing.Start(context.Background(), "communicator download dir",
+		tracing.Attribute{Key: "packer.path", Value: src})
+	err := c.comm.DownloadDir(src, dst, exclude)
+	tracing.End(span, err)
+	return err
+}
+
+// tracedSessioner traces the sessions of a SessionCommunicator.
+type tracedSessioner struct {
+	sc SessionCommunicator
+}
+
+func (s *tracedSessioner) NewSession() (Communicator, error) {
+	session, err := s.sc.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return TraceCommunicator(session), nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
+)
+
+type closingMockCommunicator struct {
+	MockCommunicator
+}
+
+func (c *closingMockCommunicator) Close() error { return nil }
+
+func (c *closingMockCommunicator) DownloadDir(string, string, []string) error {
+	return errors.New("oops")
+}
+
+func TestTraceCommunicator(t *testing.T) {
+	tp := new(tracing.MockTracerProvider)
+	tracing.SetTracerProvider(tp)
+	defer tracing.SetTracerProvider(nil)
+
+	mock := new(closingMockCommunicator)
+	comm := TraceCommunicator(mock)
+	if _, ok := comm.(io.Closer); !ok {
+		t.Fatal("expected the traced communicator to be an io.Closer")
+	}
+	if _, ok := comm.(PortForwarder); ok {
+		t.Fatal("expected the traced communicator not to be a PortForwarder")
+	}
+
+	cmd := &RemoteCmd{Command: "echo foo"}
+	if err := cmd.RunWithUi(context.Background(), comm, TestUi(t)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := comm.Upload("/tmp/foo", strings.NewReader("foo"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := comm.DownloadDir("/tmp", "dst", nil); err == nil {
+		t.Fatal("expected the error of the communicator")
+	}
+
+	spans := tp.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	select {
+	case <-spans[0].Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the span of the command wasn't ended")
+	}
+	for i, expected := range []struct {
+		name string
+		errs int
+	}{
+		{"communicator start", 0},
+		{"communicator upload", 0},
+		{"communicator download dir", 1},
+	} {
+		span := spans[i]
+		if span.Name != expected.name || !span.Ended || len(span.Errors) != expected.errs {
+			t.Errorf("span %d: unexpected span %#v", i, span)
+		}
+	}
+	if spans[0].Attributes["packer.command"] != "echo foo" || spans[0].Attributes["packer.exit_status"] != 0 {
+		t.Errorf("unexpected attributes %v", spans[0].Attributes)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+)
+
+// ComponentKind is the kind of a component of a ComponentRegistry.
+type ComponentKind string
+
+const (
+	ComponentBuilder       ComponentKind = "builder"
+	ComponentProvisioner   ComponentKind = "provisioner"
+	ComponentPostProcessor ComponentKind = "post-processor"
+	ComponentDatasource    ComponentKind = "data source"
+)
+
+// ComponentMetadata describes a component registered in a ComponentRegistry,
+// so that it can be listed without being started.
+type ComponentMetadata struct {
+	// Name and Kind are set by the registry.
+	Name string
+	Kind ComponentKind
+
+	Version     string
+	Description string
+	// ConfigSpec, if set, returns the HCL2 spec of the configuration of the
+	// component. The component is started to get it otherwise.
+	ConfigSpec func() hcldec.ObjectSpec
+}
+
+// ComponentRegistry is a registry of the builders, provisioners,
+// post-processors and data sources that can be used, with their metadata. The
+// components are created on demand by their factory, a new one each time they
+// are started. It is safe to be used from multiple goroutines.
+type ComponentRegistry struct {
+	l          sync.RWMutex
+	components map[ComponentKind]map[string]registeredComponent
+}
+
+type registeredComponent struct {
+	metadata ComponentMetadata
+	factory  func() (HCL2Speccer, error)
+}
+
+// NewComponentRegistry returns an empty ComponentRegistry.
+func NewComponentRegistry() *ComponentRegistry {
+	return &ComponentRegistry{
+		components: make(map[ComponentKind]map[string]registeredComponent),
+	}
+}
+
+// RegisterBuilder registers the builder name created by factory. It fails if
+// a builder with this name is already registered.
+func (r *ComponentRegistry) RegisterBuilder(name string, metadata ComponentMetadata, factory func() (Builder, error)) error {
+	return r.register(ComponentBuilder, name, metadata, func() (HCL2Speccer, error) {
+		return factory()
+	})
+}
+
+// RegisterProvisioner registers the provisioner name created by factory. It
+// fails if a provisioner with this name is already registered.
+func (r *ComponentRegistry) RegisterProvisioner(name string, metadata ComponentMetadata, factory func() (Provisioner, error)) error {
+	return r.register(ComponentProvisioner, name, metadata, func() (HCL2Speccer, error) {
+		return factory()
+	})
+}
+
+// RegisterPostProcessor registers the post-processor name created by factory.
+// It fails if a post-processor with this name is already registered.
+func (r *ComponentRegistry) RegisterPostProcessor(name string, metadata ComponentMetadata, factory func() (PostProcessor, error)) error {
+	return r.register(ComponentPostProcessor, name, metadata, func() (HCL2Speccer, error) {
+		return factory()
+	})
+}
+
+// RegisterDatasource registers the data source name created by factory. It
+// fails if a data source with this name is already registered.
+func (r *ComponentRegistry) RegisterDatasource(name string, metadata ComponentMetadata, factory func() (Datasource, error)) error {
+	return r.register(ComponentDatasource, name, metadata, func() (HCL2Speccer, error) {
+		return factory()
+	})
+}
+
+func (r *ComponentRegistry) register(kind ComponentKind, name string, metadata ComponentMetadata, factory func() (HCL2Speccer, error)) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.components == nil {
+		r.components = make(map[ComponentKind]map[string]registeredComponent)
+	}
+	components, ok := r.components[kind]
+	if !ok {
+		components = make(map[string]registeredComponent)
+		r.components[kind] = components
+	}
+	if _, ok := components[name]; ok {
+		return fmt.Errorf("%s %s is already registered", kind, name)
+	}
+	metadata.Name = name
+	metadata.Kind = kind
+	components[name] = registeredComponent{metadata: metadata, factory: factory}
+	return nil
+}
+
+func (r *ComponentRegistry) lookup(kind ComponentKind, name string) (registeredComponent, bool) {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	c, ok := r.components[kind][name]
+	return c, ok
+}
+
+// Has tells whether the component name of kind is registered.
+func (r *ComponentRegistry) Has(kind ComponentKind, name string) bool {
+	_, ok := r.lookup(kind, name)
+	return ok
+}
+
+// Metadata returns the metadata of the component name of kind, if registered.
+func (r *ComponentRegistry) Metadata(kind ComponentKind, name string) (ComponentMetadata, bool) {
+	c, ok := r.lookup(kind, name)
+	return c.metadata, ok
+}
+
+// List returns the metadata of the components of kind, sorted by name.
+func (r *ComponentRegistry) List(kind ComponentKind) []ComponentMetadata {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	res := make([]ComponentMetadata, 0, len(r.components[kind]))
+	for _, c := range r.components[kind] {
+		res = append(res, c.metadata)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// ConfigSpec returns the HCL2 spec of the configuration of the component name
+// of kind, from its metadata or by starting it.
+func (r *ComponentRegistry) ConfigSpec(kind ComponentKind, name string) (hcldec.ObjectSpec, error) {
+	c, ok := r.lookup(kind, name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown %s %s", kind, name)
+	}
+	if c.metadata.ConfigSpec != nil {
+		return c.metadata.ConfigSpec(), nil
+	}
+	component, err := c.factory()
+	if err != nil {
+		return nil, err
+	}
+	return component.ConfigSpec(), nil
+}
+
+func (r *ComponentRegistry) start(kind ComponentKind, name string) (HCL2Speccer, error) {
+	c, ok := r.lookup(kind, name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown %s %s", kind, name)
+	}
+	return c.factory()
+}
+
+// StartBuilder creates the builder name.
+func (r *ComponentRegistry) StartBuilder(name string) (Builder, error) {
+	c, err := r.start(ComponentBuilder, name)
+	if err != nil {
+		return nil, err
+	}
+	component, _ := c.(Builder)
+	return component, nil
+}
+
+// StartProvisioner creates the provisioner name.
+func (r *ComponentRegistry) StartProvisioner(name string) (Provisioner, error) {
+	c, err := r.start(ComponentProvisioner, name)
+	if err != nil {
+		return nil, err
+	}
+	component, _ := c.(Provisioner)
+	return component, nil
+}
+
+// StartPostProcessor creates the post-processor name.
+func (r *ComponentRegistry) StartPostProcessor(name string) (PostProcessor, error) {
+	c, err := r.start(ComponentPostProcessor, name)
+	if err != nil {
+		return nil, err
+	}
+	component, _ := c.(PostProcessor)
+	return component, nil
+}
+
+// StartDatasource creates the data source name.
+func (r *ComponentRegistry) StartDatasource(name string) (Datasource, error) {
+	c, err := r.start(ComponentDatasource, name)
+	if err != nil {
+		return nil, err
+	}
+	component, _ := c.(Datasource)
+	return component, nil
+}
+
+// Builders returns a MapOfBuilder starting the builders of the registry on
+// demand.
+func (r *ComponentRegistry) Builders() MapOfBuilder {
+	res := MapOfBuilder{}
+	for _, c := range r.List(ComponentBuilder) {
+		name := c.Name
+		res[name] = func() (Builder, error) { return r.StartBuilder(name) }
+	}
+	return res
+}
+
+// Provisioners returns a MapOfProvisioner starting the provisioners of the
+// registry on demand.
+func (r *ComponentRegistry) Provisioners() MapOfProvisioner {
+	res := MapOfProvisioner{}
+	for _, c := range r.List(ComponentProvisioner) {
+		name := c.Name
+		res[name] = func() (Provisioner, error) { return r.StartProvisioner(name) }
+	}
+	return res
+}
+
+// PostProcessors returns a MapOfPostProcessor starting the post-processors of
+// the registry on demand.
+func (r *ComponentRegistry) PostProcessors() MapOfPostProcessor {
+	res := MapOfPostProcessor{}
+	for _, c := range r.List(ComponentPostProcessor) {
+		name := c.Name
+		res[name] = func() (PostProcessor, error) { return r.StartPostProcessor(name) }
+	}
+	return res
+}
+
+// Datasources returns a MapOfDatasource starting the data sources of the
+// registry on demand.
+func (r *ComponentRegistry) Datasources() MapOfDatasource {
+	res := MapOfDatasource{}
+	for _, c := range r.List(ComponentDatasource) {
+		name := c.Name
+		res[name] = func() (Datasource, error) { return r.StartDatasource(name) }
+	}
+	return res
+}
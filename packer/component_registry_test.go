@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+)
+
+func TestComponentRegistry(t *testing.T) {
+	r := NewComponentRegistry()
+	started := 0
+	err := r.RegisterBuilder("mock", ComponentMetadata{Version: "1.0.0", Description: "A mock builder"}, func() (Builder, error) {
+		started++
+		return new(MockBuilder), nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := r.RegisterBuilder("mock", ComponentMetadata{}, nil); err == nil {
+		t.Fatal("expected an error registering a builder twice")
+	}
+	spec := hcldec.ObjectSpec{}
+	err = r.RegisterProvisioner("mock", ComponentMetadata{ConfigSpec: func() hcldec.ObjectSpec { return spec }}, func() (Provisioner, error) {
+		t.Fatal("the provisioner should not be started")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if started != 0 {
+		t.Fatal("expected the builder not to be started when registered")
+	}
+	if !r.Has(ComponentBuilder, "mock") || r.Has(ComponentPostProcessor, "mock") {
+		t.Fatal("unexpected registered components")
+	}
+	list := r.List(ComponentBuilder)
+	if len(list) != 1 || list[0].Name != "mock" || list[0].Kind != ComponentBuilder || list[0].Version != "1.0.0" {
+		t.Fatalf("unexpected builders %#v", list)
+	}
+
+	if _, err := r.ConfigSpec(ComponentProvisioner, "mock"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := r.ConfigSpec(ComponentBuilder, "mock"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if started != 1 {
+		t.Fatal("expected the builder to be started for its spec")
+	}
+
+	b, err := r.Builders().Start("mock")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := b.(*MockBuilder); !ok || started != 2 {
+		t.Fatalf("unexpected builder %#v", b)
+	}
+	if _, err := r.StartDatasource("mock"); err == nil {
+		t.Fatal("expected an error starting an unknown data source")
+	}
+}
+
+func TestComponentRegistry_concurrent(t *testing.T) {
+	r := NewComponentRegistry()
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "c", "d"} {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.RegisterDatasource(name, ComponentMetadata{}, func() (Datasource, error) {
+				return new(MockDatasource), nil
+			})
+			r.List(ComponentDatasource)
+		}()
+	}
+	wg.Wait()
+
+	if len(r.Datasources().List()) != 4 {
+		t.Fatalf("expected 4 data sources, got %v", r.List(ComponentDatasource))
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+const (
+	EventBuildStarted  EventType = "build_started"
+	EventBuildFinished EventType = "build_finished"
+	EventStepStarted   EventType = "step_started"
+	EventStepFinished  EventType = "step_finished"
+	EventArtifact      EventType = "artifact"
+	EventError         EventType = "error"
+)
+
+// ErrorClass classifies the errors of the error events, telling whether it
+// is worth retrying or fixing the configuration.
+type ErrorClass string
+
+const (
+	// ErrorClassUser errors come from the configuration or the input.
+	ErrorClassUser ErrorClass = "user"
+	// ErrorClassTransient errors may not happen again when retrying.
+	ErrorClassTransient ErrorClass = "transient"
+	// ErrorClassCancelled errors come from a cancellation.
+	ErrorClassCancelled ErrorClass = "cancelled"
+	// ErrorClassFatalInfra errors come from the infrastructure and are not
+	// worth retrying.
+	ErrorClassFatalInfra ErrorClass = "fatal_infra"
+	// ErrorClassInternal errors are the other errors.
+	ErrorClassInternal ErrorClass = "internal"
+)
+
+// An Event is a machine-readable record of the progress of a build, emitted
+// alongside the text output of the Ui.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Build is the name of the build.
+	Build string `json:"build,omitempty"`
+	// Step is the name of the step of the step events.
+	Step string `json:"step,omitempty"`
+	// Halted tells whether the step of a step finished event halted the
+	// build, or whether the build of a build finished event failed.
+	Halted bool `json:"halted,omitempty"`
+	// ArtifactID and BuilderID are the Id and BuilderId of the artifact of
+	// an artifact event.
+	ArtifactID string `json:"artifact_id,omitempty"`
+	BuilderID  string `json:"builder_id,omitempty"`
+	// Message is the description of the artifact of an artifact event, or
+	// the error of an error event.
+	Message    string     `json:"message,omitempty"`
+	ErrorClass ErrorClass `json:"error_class,omitempty"`
+	// CancelReason is why the build was cancelled, for the error events of
+	// cancellations with a cause, such as a multistep.CancelCause.
+	CancelReason string `json:"cancel_reason,omitempty"`
+}
+
+// EventEmitter is implemented by the Uis that can emit a stream of events,
+// for GUIs and orchestration services. Use EmitEvent to emit events with
+// any Ui.
+type EventEmitter interface {
+	Emit(Event)
+}
+
+// EmitEvent emits e with ui when it's an EventEmitter, setting its time if
+// it isn't set.
+func EmitEvent(ui Ui, e Event) {
+	emitter, ok := ui.(EventEmitter)
+	if !ok {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	emitter.Emit(e)
+}
+
+// ArtifactEvent returns the event of an artifact produced by a build.
+func ArtifactEvent(a Artifact) Event {
+	return Event{
+		Type:       EventArtifact,
+		ArtifactID: a.Id(),
+		BuilderID:  a.BuilderId(),
+		Message:    a.String(),
+	}
+}
+
+// EmitBuildFinished emits the events of the end of a build with ui: the
+// error event of err, if any, then the build finished event, telling
+// whether the build halted.
+func EmitBuildFinished(ui Ui, halted bool, err error) {
+	if err != nil {
+		EmitEvent(ui, ErrorEvent(err))
+	}
+	EmitEvent(ui, Event{Type: EventBuildFinished, Halted: halted || err != nil})
+}
+
+// ErrorEvent returns the event of an error, classified by ClassifyError,
+// with the reason of the cancellation it wraps, if any.
+func ErrorEvent(err error) Event {
+	e := Event{
+		Type:       EventError,
+		Message:    err.Error(),
+		ErrorClass: ClassifyError(err),
+	}
+	var cause interface{ CancelReason() string }
+	if errors.As(err, &cause) {
+		e.CancelReason = cause.CancelReason()
+	}
+	return e
+}
+
+// ClassifiedError is an error of a known class.
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns the class of err: the class of the ClassifiedError it
+// wraps, the class it is given by the sdkerrors package, ErrorClassCancelled
+// for cancellations and ErrorClassTransient for timeouts, and
+// ErrorClassInternal otherwise.
+func ClassifyError(err error) ErrorClass {
+	var classified *ClassifiedError
+	switch {
+	case errors.As(err, &classified):
+		return classified.Class
+	case sdkerrors.IsRetryable(err):
+		return ErrorClassTransient
+	case sdkerrors.IsUserError(err):
+		return ErrorClassUser
+	case sdkerrors.IsFatalInfra(err):
+		return ErrorClassFatalInfra
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrInterrupted):
+		return ErrorClassCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTransient
+	default:
+		return ErrorClassInternal
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+)
+
+type testEventUi struct {
+	MockUi
+	events []Event
+}
+
+func (u *testEventUi) Emit(e Event) {
+	u.events = append(u.events, e)
+}
+
+func TestEmitEvent(t *testing.T) {
+	// Uis which aren't emitters are ignored.
+	EmitEvent(new(MockUi), Event{Type: EventBuildStarted})
+
+	ui := new(testEventUi)
+	EmitEvent(ui, Event{Type: EventBuildStarted, Build: "docker.ubuntu"})
+	if len(ui.events) != 1 {
+		t.Fatalf("expected an event, got %#v", ui.events)
+	}
+	if e := ui.events[0]; e.Type != EventBuildStarted || e.Build != "docker.ubuntu" || e.Time.IsZero() {
+		t.Fatalf("unexpected event %#v", e)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{errors.New("oops"), ErrorClassInternal},
+		{fmt.Errorf("step: %w", context.Canceled), ErrorClassCancelled},
+		{ErrInterrupted, ErrorClassCancelled},
+		{fmt.Errorf("step: %w", context.DeadlineExceeded), ErrorClassTransient},
+		{fmt.Errorf("step: %w", &ClassifiedError{Class: ErrorClassUser, Err: errors.New("bad config")}), ErrorClassUser},
+		{sdkerrors.UserErrorf("bad config"), ErrorClassUser},
+		{fmt.Errorf("step: %w", sdkerrors.WrapRetryable(errors.New("throttled"))), ErrorClassTransient},
+		{sdkerrors.WrapFatalInfra(errors.New("quota exceeded")), ErrorClassFatalInfra},
+	} {
+		if class := ClassifyError(tc.err); class != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.err, tc.expected, class)
+		}
+	}
+
+	e := ErrorEvent(&ClassifiedError{Class: ErrorClassTransient, Err: errors.New("timeout")})
+	if e.Type != EventError || e.Message != "timeout" || e.ErrorClass != ErrorClassTransient {
+		t.Fatalf("unexpected event %#v", e)
+	}
+}
+
+type testCancelCause struct{}
+
+func (testCancelCause) Error() string        { return "cancelled" }
+func (testCancelCause) CancelReason() string { return "timeout" }
+
+func TestErrorEvent_cancelReason(t *testing.T) {
+	e := ErrorEvent(fmt.Errorf("step: %w", testCancelCause{}))
+	if e.CancelReason != "timeout" {
+		t.Fatalf("expected the reason of the cancellation, got %#v", e)
+	}
+}
+
+func TestEmitBuildFinished(t *testing.T) {
+	ui := new(testEventUi)
+	EmitBuildFinished(ui, true, sdkerrors.UserErrorf("bad config"))
+	if len(ui.events) != 2 {
+		t.Fatalf("expected 2 events, got %#v", ui.events)
+	}
+	if e := ui.events[0]; e.Type != EventError || e.Message != "bad config" || e.ErrorClass != ErrorClassUser {
+		t.Fatalf("unexpected event %#v", e)
+	}
+	if e := ui.events[1]; e.Type != EventBuildFinished || !e.Halted {
+		t.Fatalf("unexpected event %#v", e)
+	}
+
+	ui = new(testEventUi)
+	EmitBuildFinished(ui, false, nil)
+	if len(ui.events) != 1 || ui.events[0].Type != EventBuildFinished || ui.events[0].Halted {
+		t.Fatalf("unexpected events %#v", ui.events)
+	}
+}
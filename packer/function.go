@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Functions are template functions served by plugins, so that they can be
+// called in a Packer configuration like the functions of Packer, for
+// example `internal_secret("db")`.
+type Function interface {
+	// Signature returns the parameters and the return type of the function.
+	Signature() FunctionSignature
+
+	// Call calls the function with args, which are known values matching
+	// its signature.
+	Call(args []cty.Value) (cty.Value, error)
+}
+
+// FunctionSignature describes how a Function is called.
+type FunctionSignature struct {
+	Description string
+	Params      []FunctionParameter
+
+	// VarParam, when set, is the parameter of the variable number of
+	// arguments following Params.
+	VarParam *FunctionParameter
+
+	ReturnType cty.Type
+}
+
+// FunctionParameter is a parameter of a Function.
+type FunctionParameter struct {
+	Name        string
+	Description string
+	Type        cty.Type
+
+	// AllowNull is whether the argument can be null.
+	AllowNull bool
+}
+
+func (p FunctionParameter) ctyParameter() function.Parameter {
+	return function.Parameter{
+		Name:      p.Name,
+		Type:      p.Type,
+		AllowNull: p.AllowNull,
+	}
+}
+
+// HCL2Function returns f as a cty function, to be added to the functions of
+// the HCL evaluation context. It returns an unknown value when an argument
+// is unknown, without calling f.
+func HCL2Function(f Function) function.Function {
+	sig := f.Signature()
+	spec := &function.Spec{
+		Type: function.StaticReturnType(sig.ReturnType),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return f.Call(args)
+		},
+	}
+	for _, p := range sig.Params {
+		spec.Params = append(spec.Params, p.ctyParameter())
+	}
+	if sig.VarParam != nil {
+		p := sig.VarParam.ctyParameter()
+		spec.VarParam = &p
+	}
+	return function.New(spec)
+}
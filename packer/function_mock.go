@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MockFunction is a Function joining its string arguments with a separator.
+type MockFunction struct {
+	CallCalled bool
+	CallArgs   []cty.Value
+}
+
+func (f *MockFunction) Signature() FunctionSignature {
+	return FunctionSignature{
+		Description: "Joins strings with a separator.",
+		Params:      []FunctionParameter{{Name: "separator", Type: cty.String}},
+		VarParam:    &FunctionParameter{Name: "strings", Type: cty.String},
+		ReturnType:  cty.String,
+	}
+}
+
+func (f *MockFunction) Call(args []cty.Value) (cty.Value, error) {
+	f.CallCalled = true
+	f.CallArgs = args
+	strs := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		strs = append(strs, arg.AsString())
+	}
+	return cty.StringVal(strings.Join(strs, args[0].AsString())), nil
+}
@@ -0,0 +1,33 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHCL2Function(t *testing.T) {
+	f := &MockFunction{}
+	fn := HCL2Function(f)
+
+	got, err := fn.Call([]cty.Value{cty.StringVal("-"), cty.StringVal("a"), cty.StringVal("b")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !got.RawEquals(cty.StringVal("a-b")) {
+		t.Fatalf("bad result: %#v", got)
+	}
+
+	got, err = fn.Call([]cty.Value{cty.StringVal("-"), cty.UnknownVal(cty.String)})
+	if err != nil || got.IsKnown() {
+		t.Fatalf("expected an unknown result, got %#v, %v", got, err)
+	}
+
+	f.CallCalled = false
+	if _, err := fn.Call([]cty.Value{cty.NullVal(cty.String)}); err == nil || f.CallCalled {
+		t.Fatal("a null separator should be rejected")
+	}
+}
@@ -11,6 +11,25 @@ import (
 const HookProvision = "packer_provision"
 const HookCleanupProvision = "packer_cleanup_provision"
 
+// These are the hooks fired around a build, so that external tools, such as
+// inventory updaters or notifiers, can follow its lifecycle. The data of the
+// hooks is the generated data of the build, with the error of the build for
+// HookOnError, see OnErrorHookData. They are fired without communicator.
+const (
+	// HookPreBuild is fired before the build starts.
+	HookPreBuild = "packer_pre_build"
+	// HookPostBuild is fired once the build succeeded.
+	HookPostBuild = "packer_post_build"
+	// HookOnError is fired once the build failed, or was cancelled.
+	HookOnError = "packer_on_error"
+)
+
+// The keys of the error of a build in the data of HookOnError.
+const (
+	HookDataBuildError      = "PackerBuildError"
+	HookDataBuildErrorClass = "PackerBuildErrorClass"
+)
+
 // A Hook is used to hook into an arbitrarily named location in a build,
 // allowing custom behavior to run at certain points along a build.
 //
@@ -54,3 +73,21 @@ func (h *DispatchHook) Run(ctx context.Context, name string, ui Ui, comm Communi
 
 	return nil
 }
+
+// OnErrorHookData returns a copy of data, the generated data of a build, with
+// err, the error of the build, for HookOnError. err may be nil when the build
+// was halted without error.
+func OnErrorHookData(data map[string]interface{}, err error) map[string]interface{} {
+	hookData := make(map[string]interface{}, len(data)+2)
+	for k, v := range data {
+		hookData[k] = v
+	}
+	if err != nil {
+		hookData[HookDataBuildError] = err.Error()
+		hookData[HookDataBuildErrorClass] = string(ClassifyError(err))
+	} else {
+		hookData[HookDataBuildError] = ""
+		hookData[HookDataBuildErrorClass] = string(ErrorClassCancelled)
+	}
+	return hookData
+}
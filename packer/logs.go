@@ -6,16 +6,32 @@ package packer
 import (
 	"bytes"
 	"io"
+	"regexp"
 	"strings"
 	"sync"
 )
 
+// maxPatternMatch is the length of the longest match of the patterns of a
+// secretFilter that is redacted when split across several writes.
+const maxPatternMatch = 256
+
+// secretFilter redacts secrets from what is written to its output. The
+// secrets are literal strings, or the matches of patterns; they can be added
+// at any time, for example when a build generates a temporary password.
+//
+// A secret split across several calls to Write is still redacted: the end of
+// a line that could be the start of a secret is held back until the next
+// write, or until Flush is called. A secret spanning several lines is only
+// redacted when written at once.
 type secretFilter struct {
-	s map[string]struct{}
-	m sync.Mutex
-	w io.Writer
+	s        map[string]struct{}
+	patterns map[string]*regexp.Regexp
+	m        sync.Mutex
+	w        io.Writer
+	tail     []byte
 }
 
+// Set adds literal secrets to filter.
 func (l *secretFilter) Set(secrets ...string) {
 	l.m.Lock()
 	defer l.m.Unlock()
@@ -24,6 +40,18 @@ func (l *secretFilter) Set(secrets ...string) {
 	}
 }
 
+// SetPatterns adds patterns to filter, each of their matches being a secret.
+func (l *secretFilter) SetPatterns(patterns ...*regexp.Regexp) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.patterns == nil {
+		l.patterns = make(map[string]*regexp.Regexp)
+	}
+	for _, p := range patterns {
+		l.patterns[p.String()] = p
+	}
+}
+
 func (l *secretFilter) SetOutput(output io.Writer) {
 	l.m.Lock()
 	defer l.m.Unlock()
@@ -31,27 +59,98 @@ func (l *secretFilter) SetOutput(output io.Writer) {
 }
 
 func (l *secretFilter) Write(p []byte) (n int, err error) {
-	for s := range l.s {
-		if s != "" {
-			p = bytes.Replace(p, []byte(s), []byte("<sensitive>"), -1)
-		}
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	buf := l.filterLocked(append(l.tail, p...))
+
+	// Hold back what could be the start of a secret completed by the next
+	// write.
+	hold := len(buf) - (bytes.LastIndexByte(buf, '\n') + 1)
+	if window := l.windowLocked(); hold > window {
+		hold = window
+	}
+	l.tail = append([]byte(nil), buf[len(buf)-hold:]...)
+
+	if _, err := l.w.Write(buf[:len(buf)-hold]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes what Write held back.
+func (l *secretFilter) Flush() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if len(l.tail) == 0 {
+		return nil
 	}
-	return l.w.Write(p)
+	_, err := l.w.Write(l.tail)
+	l.tail = nil
+	return err
 }
 
 // FilterString will overwrite any senstitive variables in a string, returning
 // the filtered string.
 func (l *secretFilter) FilterString(message string) string {
+	l.m.Lock()
+	defer l.m.Unlock()
 	for s := range l.s {
 		if s != "" {
 			message = strings.Replace(message, s, "<sensitive>", -1)
 		}
 	}
+	for _, p := range l.patterns {
+		message = p.ReplaceAllLiteralString(message, "<sensitive>")
+	}
 	return message
 }
 
+func (l *secretFilter) filterLocked(p []byte) []byte {
+	for s := range l.s {
+		if s != "" {
+			p = bytes.Replace(p, []byte(s), []byte("<sensitive>"), -1)
+		}
+	}
+	for _, pattern := range l.patterns {
+		p = pattern.ReplaceAllLiteral(p, []byte("<sensitive>"))
+	}
+	return p
+}
+
+// windowLocked returns the length of the longest incomplete secret.
+func (l *secretFilter) windowLocked() int {
+	window := 0
+	if len(l.patterns) > 0 {
+		window = maxPatternMatch - 1
+	}
+	for s := range l.s {
+		if len(s)-1 > window {
+			window = len(s) - 1
+		}
+	}
+	return window
+}
+
 var LogSecretFilter secretFilter
 
 func init() {
 	LogSecretFilter.s = make(map[string]struct{})
 }
+
+// SecretRegistrar is implemented by the Uis that forward the secrets
+// registered with RegisterSecrets, such as the Ui of a plugin, which forwards
+// them to Packer.
+type SecretRegistrar interface {
+	RegisterSecrets(secrets ...string)
+}
+
+// RegisterSecrets adds secrets generated during a build, such as temporary
+// passwords or tokens, to LogSecretFilter, and forwards them with ui when
+// it's a SecretRegistrar so that they are redacted from its output as well.
+func RegisterSecrets(ui Ui, secrets ...string) {
+	LogSecretFilter.Set(secrets...)
+	if registrar, ok := ui.(SecretRegistrar); ok {
+		registrar.RegisterSecrets(secrets...)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	getter "github.com/hashicorp/go-getter/v2"
+)
+
+// MultiProgressBar is a progress tracker rendering each of the streams it
+// tracks as a labeled bar on its own line of a terminal, so that concurrent
+// transfers don't garble the output. It is safe to be called from multiple
+// goroutines.
+//
+// The bars are redrawn in place with ANSI escape sequences, and stay on
+// screen once all the transfers are done.
+type MultiProgressBar struct {
+	// Writer is the terminal the bars are drawn on.
+	Writer io.Writer
+	// Width is the width of the bars, without their label and size.
+	// Defaults to 40.
+	Width int
+	// RefreshInterval is the minimum time between two draws of the bars.
+	// Defaults to 200ms.
+	RefreshInterval time.Duration
+
+	l     sync.Mutex
+	bars  []*progressBar
+	lines int
+	last  time.Time
+}
+
+var _ getter.ProgressTracker = new(MultiProgressBar)
+
+// progressBar is the state of a stream tracked by a MultiProgressBar.
+type progressBar struct {
+	label   string
+	current int64
+	total   int64
+	done    bool
+}
+
+// maxProgressLabel is the length above which the labels of the bars are
+// truncated.
+const maxProgressLabel = 30
+
+// TrackProgress adds a bar for stream, labeled with src, and returns a stream
+// updating it. The bar is complete when the returned stream is closed.
+func (p *MultiProgressBar) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	label := src
+	if len(label) > maxProgressLabel {
+		label = "..." + label[len(label)-maxProgressLabel+3:]
+	}
+	bar := &progressBar{label: label, current: currentSize, total: totalSize}
+
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.bars = append(p.bars, bar)
+	p.drawLocked()
+	return &progressBarReader{tracker: p, bar: bar, stream: stream}
+}
+
+// update adds n to the progress of bar, and redraws the bars when
+// RefreshInterval has elapsed, or when done.
+func (p *MultiProgressBar) update(bar *progressBar, n int64, done bool) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	bar.current += n
+	interval := p.RefreshInterval
+	if interval == 0 {
+		interval = 200 * time.Millisecond
+	}
+	if done {
+		bar.done = true
+	} else if time.Since(p.last) < interval {
+		return
+	}
+	p.drawLocked()
+
+	for _, b := range p.bars {
+		if !b.done {
+			return
+		}
+	}
+	// Leave the finished bars where they are, the next ones are drawn below.
+	p.bars = nil
+	p.lines = 0
+}
+
+func (p *MultiProgressBar) drawLocked() {
+	p.last = time.Now()
+
+	var b strings.Builder
+	if p.lines > 0 {
+		// Go back to the first bar.
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+	for _, bar := range p.bars {
+		b.WriteString("\r\x1b[K")
+		b.WriteString(bar.line(p.width()))
+		b.WriteString("\n")
+	}
+	p.lines = len(p.bars)
+	io.WriteString(p.Writer, b.String())
+}
+
+func (p *MultiProgressBar) width() int {
+	if p.Width == 0 {
+		return 40
+	}
+	return p.Width
+}
+
+// line returns the text of the bar, such as
+//
+//	ubuntu.iso [=======>      ]  50% 1.5 GiB/3.0 GiB
+//
+// or only its label and current size when its total is unknown.
+func (bar *progressBar) line(width int) string {
+	label := fmt.Sprintf("%-*s", maxProgressLabel, bar.label)
+	if bar.total <= 0 {
+		return fmt.Sprintf("%s %s", label, formatProgressSize(bar.current))
+	}
+
+	current := bar.current
+	if current > bar.total {
+		current = bar.total
+	}
+	filled := int(int64(width) * current / bar.total)
+	fill := strings.Repeat("=", filled)
+	if filled < width {
+		fill += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %3d%% %s/%s", label, fill, 100*current/bar.total,
+		formatProgressSize(bar.current), formatProgressSize(bar.total))
+}
+
+// formatProgressSize returns size in bytes in the largest binary unit it
+// fits in.
+func formatProgressSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// progressBarReader updates its bar with the reads of stream.
+type progressBarReader struct {
+	tracker *MultiProgressBar
+	bar     *progressBar
+	stream  io.ReadCloser
+	once    sync.Once
+}
+
+func (r *progressBarReader) Read(p []byte) (int, error) {
+	n, err := r.stream.Read(p)
+	r.tracker.update(r.bar, int64(n), false)
+	return n, err
+}
+
+func (r *progressBarReader) Close() error {
+	r.once.Do(func() {
+		r.tracker.update(r.bar, 0, true)
+	})
+	return r.stream.Close()
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMultiProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	p := &MultiProgressBar{Writer: &out, Width: 10}
+
+	streams := []io.ReadCloser{
+		p.TrackProgress("first.iso", 0, 2048, ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 2048)))),
+		p.TrackProgress("second.iso", 0, 0, ioutil.NopCloser(strings.NewReader(strings.Repeat("b", 100)))),
+	}
+	var wg sync.WaitGroup
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s io.ReadCloser) {
+			defer wg.Done()
+			io.Copy(ioutil.Discard, s)
+		}(s)
+	}
+	wg.Wait()
+	for _, s := range streams {
+		s.Close()
+		s.Close()
+	}
+
+	// The last draw has both the bars, once the first one is back to the
+	// top.
+	draws := strings.Split(out.String(), "\x1b[2A")
+	last := strings.Split(strings.TrimSuffix(draws[len(draws)-1], "\n"), "\n")
+	expected := []string{
+		"\r\x1b[Kfirst.iso                      [==========] 100% 2.0 KiB/2.0 KiB",
+		"\r\x1b[Ksecond.iso                     100 B",
+	}
+	if len(last) != len(expected) {
+		t.Fatalf("expected %d bars, got %q", len(expected), last)
+	}
+	for i := range expected {
+		if last[i] != expected[i] {
+			t.Errorf("bar %d: expected %q, got %q", i, expected[i], last[i])
+		}
+	}
+
+	// The bars of the next transfers are drawn below the finished ones.
+	out.Reset()
+	s := p.TrackProgress("third.iso", 0, 10, ioutil.NopCloser(strings.NewReader("")))
+	s.Close()
+	if !strings.HasPrefix(out.String(), "\r\x1b[Kthird.iso") {
+		t.Fatalf("expected the bars not to move up, got %q", out.String())
+	}
+}
+
+func TestFormatProgressSize(t *testing.T) {
+	for size, expected := range map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1536:          "1.5 KiB",
+		3 << 30:       "3.0 GiB",
+		5<<40 + 1<<39: "5.5 TiB",
+	} {
+		if got := formatProgressSize(size); got != expected {
+			t.Errorf("%d: expected %q, got %q", size, expected, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// HookedProvisioner is a provisioner run by a ProvisionHook.
+type HookedProvisioner struct {
+	Provisioner Provisioner
+	TypeName    string
+	// Parallel marks the provisioners that are independent of the Parallel
+	// provisioners next to them, so that they run concurrently.
+	Parallel bool
+}
+
+// SessionCommunicator is implemented by the communicators that can open
+// independent sessions, such as new connections, for the provisioners run
+// concurrently. The sessions are closed once used when they are io.Closers.
+type SessionCommunicator interface {
+	NewSession() (Communicator, error)
+}
+
+// ProvisionHook is a Hook running provisioners, for HookProvision. The
+// provisioners run in order, except the consecutive Parallel provisioners,
+// which run concurrently, each with its own session when the communicator is
+// a SessionCommunicator.
+type ProvisionHook struct {
+	Provisioners []*HookedProvisioner
+}
+
+var _ Hook = new(ProvisionHook)
+
+// Run runs the provisioners with comm. When some of the concurrent
+// provisioners fail, the others still run to completion and the errors are
+// returned together in a MultiError.
+func (h *ProvisionHook) Run(ctx context.Context, name string, ui Ui, comm Communicator, data interface{}) error {
+	if comm == nil {
+		return errors.New("No communicator found for provisioners! This is usually because " +
+			"the `communicator` config was set to \"none\". If you have any provisioners " +
+			"then a communicator is required. Please fix this to continue.")
+	}
+	hookData, _ := data.(map[string]interface{})
+
+	for i := 0; i < len(h.Provisioners); {
+		j := i + 1
+		if h.Provisioners[i].Parallel {
+			for j < len(h.Provisioners) && h.Provisioners[j].Parallel {
+				j++
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if j-i == 1 {
+			err = h.Provisioners[i].Provisioner.Provision(ctx, ui, comm, hookData)
+		} else {
+			err = h.runParallel(ctx, h.Provisioners[i:j], ui, comm, hookData)
+		}
+		if err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+func (h *ProvisionHook) runParallel(ctx context.Context, provisioners []*HookedProvisioner, ui Ui, comm Communicator, hookData map[string]interface{}) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, p := range provisioners {
+		// Each provisioner gets its own copy of the data, which they may
+		// update.
+		data := make(map[string]interface{}, len(hookData))
+		for k, v := range hookData {
+			data[k] = v
+		}
+
+		wg.Add(1)
+		go func(p *HookedProvisioner) {
+			defer wg.Done()
+			if err := runWithSession(ctx, p, ui, comm, data); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %s", p.TypeName, err))
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+func runWithSession(ctx context.Context, p *HookedProvisioner, ui Ui, comm Communicator, data map[string]interface{}) error {
+	if sc, ok := comm.(SessionCommunicator); ok {
+		session, err := sc.NewSession()
+		if err != nil {
+			return fmt.Errorf("error opening a communicator session: %s", err)
+		}
+		if c, ok := session.(io.Closer); ok {
+			defer func() {
+				if err := c.Close(); err != nil {
+					log.Printf("[WARN] Error closing the communicator session of %s: %s", p.TypeName, err)
+				}
+			}()
+		}
+		comm = session
+	}
+	return p.Provisioner.Provision(ctx, ui, comm, data)
+}
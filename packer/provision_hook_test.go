@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sessionCommunicator struct {
+	MockCommunicator
+
+	mu     sync.Mutex
+	opened int
+	closed int
+}
+
+type closingSession struct {
+	MockCommunicator
+	comm *sessionCommunicator
+}
+
+func (s *closingSession) Close() error {
+	s.comm.mu.Lock()
+	defer s.comm.mu.Unlock()
+	s.comm.closed++
+	return nil
+}
+
+func (c *sessionCommunicator) NewSession() (Communicator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opened++
+	return &closingSession{comm: c}, nil
+}
+
+func TestProvisionHook_parallel(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(2)
+	all := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(all)
+	}()
+	// The parallel provisioners only return once both of them started.
+	waitOthers := func(context.Context) error {
+		started.Done()
+		select {
+		case <-all:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("provisioners didn't run concurrently")
+		}
+	}
+
+	first := &MockProvisioner{ProvFunc: waitOthers}
+	second := &MockProvisioner{ProvFunc: waitOthers}
+	last := &MockProvisioner{}
+	comm := new(sessionCommunicator)
+	hook := &ProvisionHook{Provisioners: []*HookedProvisioner{
+		{Provisioner: first, TypeName: "file", Parallel: true},
+		{Provisioner: second, TypeName: "file", Parallel: true},
+		{Provisioner: last, TypeName: "shell"},
+	}}
+
+	if err := hook.Run(context.Background(), HookProvision, new(MockUi), comm, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if !last.ProvCalled || last.ProvCommunicator != comm {
+		t.Fatal("expected the last provisioner to run with the communicator")
+	}
+	if first.ProvCommunicator == comm || comm.opened != 2 || comm.closed != 2 {
+		t.Fatalf("expected the parallel provisioners to run with their own sessions, opened %d, closed %d",
+			comm.opened, comm.closed)
+	}
+}
+
+func TestProvisionHook_parallelErrors(t *testing.T) {
+	fail := func(context.Context) error { return errors.New("oops") }
+	last := &MockProvisioner{}
+	hook := &ProvisionHook{Provisioners: []*HookedProvisioner{
+		{Provisioner: &MockProvisioner{ProvFunc: fail}, TypeName: "file", Parallel: true},
+		{Provisioner: &MockProvisioner{}, TypeName: "file", Parallel: true},
+		{Provisioner: &MockProvisioner{ProvFunc: fail}, TypeName: "shell", Parallel: true},
+		{Provisioner: last, TypeName: "shell"},
+	}}
+
+	err := hook.Run(context.Background(), HookProvision, new(MockUi), new(MockCommunicator), nil)
+	multi, ok := err.(*MultiError)
+	if !ok || len(multi.Errors) != 2 {
+		t.Fatalf("expected the 2 errors, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "file: oops") || !strings.Contains(err.Error(), "shell: oops") {
+		t.Fatalf("expected the errors of the provisioners, got %s", err)
+	}
+	if last.ProvCalled {
+		t.Fatal("expected the provisioners after a failure not to run")
+	}
+}
+
+func TestProvisionHook_noCommunicator(t *testing.T) {
+	hook := &ProvisionHook{Provisioners: []*HookedProvisioner{{Provisioner: new(MockProvisioner)}}}
+	if err := hook.Run(context.Background(), HookProvision, new(MockUi), nil, nil); err == nil {
+		t.Fatal("expected an error without communicator")
+	}
+}
@@ -90,6 +90,7 @@ func FromMappedData(mappedData interface{}, f func(key, value interface{}) (*Ima
 // FromArtifact returns an *Image that can be used by Packer core for publishing to the HCP Packer Registry.
 // By default FromArtifact will use the a.BuilderID() as the ProviderName, and the a.Id() as the ImageID that
 // should be tracked within the HCP Packer Registry. No Region is selected by default as region varies per builder.
+// The Labels and SourceImageID default to the ones of the packer.ArtifactMetadata of the artifact, if any.
 // The use of one or more ArtifactOverrideFunc can be used to override any of the defaults used.
 func FromArtifact(a packer.Artifact, opts ...ArtifactOverrideFunc) (*Image, error) {
 	if a == nil {
@@ -102,6 +103,17 @@ func FromArtifact(a packer.Artifact, opts ...ArtifactOverrideFunc) (*Image, erro
 		Labels:       make(map[string]string),
 	}
 
+	md, err := packer.ReadArtifactMetadata(a)
+	if err != nil {
+		return nil, err
+	}
+	if md != nil {
+		for k, v := range md.Labels() {
+			img.Labels[k] = v
+		}
+		img.SourceImageID = md.SourceImage()
+	}
+
 	for _, opt := range opts {
 		err := opt(&img)
 		if err != nil {
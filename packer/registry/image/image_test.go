@@ -142,3 +142,27 @@ func TestFromArtifact_SetLabels(t *testing.T) {
 	}
 
 }
+
+func TestFromArtifact_metadata(t *testing.T) {
+	artifact := &packer.MockArtifact{StateValues: map[string]interface{}{}}
+	err := packer.AttachArtifactMetadata(artifact.StateValues, &packer.BasicArtifactMetadata{
+		LabelsValue:      map[string]string{"os": "ubuntu"},
+		SourceImageValue: "ami-1234",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := FromArtifact(artifact, WithSourceID("ami-5678"))
+	if err != nil {
+		t.Fatalf("unexpected error when creating an image from a MockArtifact: %s", err)
+	}
+
+	if img.Labels["os"] != "ubuntu" {
+		t.Errorf("expected resulting Image to have the labels of the metadata, but it got %v", img.Labels)
+	}
+
+	if img.SourceImageID != "ami-5678" {
+		t.Errorf("expected resulting Image to have the overridden SourceImageID, but it got %q", img.SourceImageID)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	getter "github.com/hashicorp/go-getter/v2"
+)
+
+// StateProgressTracker is the state key of the ProgressTracker used instead of
+// the Ui to report the progress of the transfers of the communicators.
+const StateProgressTracker = "progress_tracker"
+
+// ProgressTrackerFromState returns the ProgressTracker of the transfers of a
+// build from its state: the one put at StateProgressTracker, or the "ui"
+// otherwise. It returns nil when there is neither.
+func ProgressTrackerFromState(state interface {
+	GetOk(string) (interface{}, bool)
+}) getter.ProgressTracker {
+	if v, ok := state.GetOk(StateProgressTracker); ok {
+		if tracker, ok := v.(getter.ProgressTracker); ok {
+			return tracker
+		}
+	}
+	if v, ok := state.GetOk("ui"); ok {
+		if tracker, ok := v.(getter.ProgressTracker); ok {
+			return tracker
+		}
+	}
+	return nil
+}
+
+// TrackedTransfer wraps r to report the transfer of src to tracker, starting
+// at current bytes out of total, which is 0 when unknown. It returns r as is
+// when tracker is nil. Closing the returned reader ends the report, it does
+// not close r.
+//
+// Communicators wrap the readers of their uploads and downloads with it, so
+// that all of them report their progress the same way.
+func TrackedTransfer(tracker getter.ProgressTracker, src string, current, total int64, r io.Reader) io.ReadCloser {
+	rc := ioutil.NopCloser(r)
+	if tracker == nil {
+		return rc
+	}
+	return tracker.TrackProgress(src, current, total, rc)
+}
+
+// TransferSize returns the size of the transfer of a file, as given to the
+// Upload of a Communicator, or 0 when it isn't known.
+func TransferSize(fi *os.FileInfo) int64 {
+	if fi == nil || *fi == nil || !(*fi).Mode().IsRegular() {
+		return 0
+	}
+	return (*fi).Size()
+}
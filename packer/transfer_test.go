@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testStateBag map[string]interface{}
+
+func (s testStateBag) GetOk(key string) (interface{}, bool) {
+	v, ok := s[key]
+	return v, ok
+}
+
+func TestProgressTrackerFromState(t *testing.T) {
+	ui := TestUi(t)
+	tracker := new(NoopProgressTracker)
+
+	if got := ProgressTrackerFromState(testStateBag{}); got != nil {
+		t.Fatalf("expected no tracker, got %#v", got)
+	}
+	if got := ProgressTrackerFromState(testStateBag{"ui": ui}); got != ui {
+		t.Fatalf("expected the ui, got %#v", got)
+	}
+	if got := ProgressTrackerFromState(testStateBag{"ui": ui, StateProgressTracker: tracker}); got != tracker {
+		t.Fatalf("expected the tracker of the state, got %#v", got)
+	}
+}
+
+func TestTrackedTransfer(t *testing.T) {
+	r := TrackedTransfer(nil, "src", 0, 0, strings.NewReader("foo"))
+	if b, _ := ioutil.ReadAll(r); string(b) != "foo" {
+		t.Fatalf("unexpected content %q", b)
+	}
+
+	ui := new(MockUi)
+	r = TrackedTransfer(ui, "src", 0, 3, strings.NewReader("foo"))
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if !ui.TrackProgressCalled || !ui.ProgressBarAddCalled || !ui.ProgressBarCloseCalled {
+		t.Fatalf("expected the transfer to be tracked: %#v", ui)
+	}
+}
+
+func TestTransferSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(path, []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := TransferSize(&fi); size != 3 {
+		t.Fatalf("expected 3, got %d", size)
+	}
+	if size := TransferSize(&dir); size != 0 {
+		t.Fatalf("expected 0 for a directory, got %d", size)
+	}
+	if size := TransferSize(nil); size != 0 {
+		t.Fatalf("expected 0 without a file info, got %d", size)
+	}
+}
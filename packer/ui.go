@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	getter "github.com/hashicorp/go-getter/v2"
 )
@@ -48,11 +49,22 @@ type BasicUi struct {
 	interrupted bool
 	TTY         TTY
 	PB          getter.ProgressTracker
+	// Events, if set, is where the events of the Ui are emitted.
+	Events EventEmitter
+	// Level is the lowest level of the messages written with Log that are
+	// shown, the others are only logged. Defaults to UiLevelInfo.
+	Level UiLevel
 }
 
 var _ Ui = new(BasicUi)
 
 func (rw *BasicUi) Ask(query string) (string, error) {
+	return rw.AskWithOptions(query, AskOptions{})
+}
+
+// AskWithOptions asks query on TTY, showing the default answer of opts. It
+// returns ErrNonInteractive without TTY.
+func (rw *BasicUi) AskWithOptions(query string, opts AskOptions) (string, error) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
@@ -61,7 +73,10 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	}
 
 	if rw.TTY == nil {
-		return "", errors.New("no available tty")
+		return opts.answer("", ErrNonInteractive)
+	}
+	if opts.Default != "" {
+		query = fmt.Sprintf("%s [%s]", query, opts.Default)
 	}
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
@@ -84,9 +99,22 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 		result <- strings.TrimSpace(line)
 	}()
 
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
 	select {
 	case line := <-result:
-		return line, nil
+		return opts.answer(line, nil)
+	case <-timeout:
+		// Print a newline so that any further output starts properly
+		// on a new line.
+		fmt.Fprintln(rw.Writer)
+
+		return opts.answer("", ErrAskTimeout)
 	case <-sigCh:
 		// Print a newline so that any further output starts properly
 		// on a new line.
@@ -99,6 +127,11 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	}
 }
 
+// Interactive returns whether the Ui has a TTY to ask on.
+func (rw *BasicUi) Interactive() bool {
+	return rw.TTY != nil
+}
+
 func (rw *BasicUi) Say(message string) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
@@ -113,6 +146,26 @@ func (rw *BasicUi) Say(message string) {
 	}
 }
 
+// SayWithFields writes message like Say, and logs it with fields, as
+// key=value pairs.
+func (rw *BasicUi) SayWithFields(message string, fields map[string]interface{}) {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+
+	// Use LogSecretFilter to scrub out sensitive variables
+	message = LogSecretFilter.FilterString(message)
+
+	if len(fields) > 0 {
+		log.Printf("ui: %s %s", message, LogSecretFilter.FilterString(formatFields(fields)))
+	} else {
+		log.Printf("ui: %s", message)
+	}
+	_, err := fmt.Fprint(rw.Writer, message+"\n")
+	if err != nil {
+		log.Printf("[ERR] Failed to write to UI: %s", err)
+	}
+}
+
 func (rw *BasicUi) Message(message string) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
@@ -150,6 +203,42 @@ func (rw *BasicUi) Machine(t string, args ...string) {
 	log.Printf("machine readable: %s %#v", t, args)
 }
 
+// Log shows message with Error from UiLevelError, or with Say from Level.
+func (rw *BasicUi) Log(level UiLevel, message string) {
+	switch {
+	case level < rw.Level:
+		log.Printf("ui %s: %s", level, LogSecretFilter.FilterString(message))
+	case level >= UiLevelError:
+		rw.Error(message)
+	default:
+		rw.Say(message)
+	}
+}
+
+// Emit logs e, and emits it with Events when it's set.
+func (rw *BasicUi) Emit(e Event) {
+	log.Printf("ui event: %s %s%s", e.Type, e.Step, e.Message)
+	if rw.Events != nil {
+		rw.Events.Emit(e)
+	}
+}
+
+func (rw *BasicUi) StepStarted(name string) {
+	EmitEvent(rw, Event{Type: EventStepStarted, Step: name})
+}
+
+func (rw *BasicUi) StepFinished(name string, halted bool) {
+	EmitEvent(rw, Event{Type: EventStepFinished, Step: name, Halted: halted})
+}
+
+func (rw *BasicUi) BuildStarted() {
+	EmitEvent(rw, Event{Type: EventBuildStarted})
+}
+
+func (rw *BasicUi) BuildFinished(halted bool, err error) {
+	EmitBuildFinished(rw, halted, err)
+}
+
 func (rw *BasicUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
 	return rw.PB.TrackProgress(src, currentSize, totalSize, stream)
 }
@@ -172,12 +261,32 @@ func (u *SafeUi) Ask(s string) (string, error) {
 	return ret, err
 }
 
+func (u *SafeUi) AskWithOptions(s string, opts AskOptions) (string, error) {
+	u.Sem <- 1
+	ret, err := AskWithOptions(u.Ui, s, opts)
+	<-u.Sem
+
+	return ret, err
+}
+
+// Interactive doesn't wait for the questions being asked, so that it answers
+// while one is waiting for an answer.
+func (u *SafeUi) Interactive() bool {
+	return IsInteractive(u.Ui)
+}
+
 func (u *SafeUi) Say(s string) {
 	u.Sem <- 1
 	u.Ui.Say(s)
 	<-u.Sem
 }
 
+func (u *SafeUi) SayWithFields(s string, fields map[string]interface{}) {
+	u.Sem <- 1
+	SayWithFields(u.Ui, s, fields)
+	<-u.Sem
+}
+
 func (u *SafeUi) Message(s string) {
 	u.Sem <- 1
 	u.Ui.Message(s)
@@ -196,6 +305,34 @@ func (u *SafeUi) Machine(t string, args ...string) {
 	<-u.Sem
 }
 
+func (u *SafeUi) Log(level UiLevel, message string) {
+	u.Sem <- 1
+	UiLog(u.Ui, level, message)
+	<-u.Sem
+}
+
+func (u *SafeUi) Emit(e Event) {
+	u.Sem <- 1
+	EmitEvent(u.Ui, e)
+	<-u.Sem
+}
+
+func (u *SafeUi) StepStarted(name string) {
+	EmitEvent(u, Event{Type: EventStepStarted, Step: name})
+}
+
+func (u *SafeUi) StepFinished(name string, halted bool) {
+	EmitEvent(u, Event{Type: EventStepFinished, Step: name, Halted: halted})
+}
+
+func (u *SafeUi) BuildStarted() {
+	EmitEvent(u, Event{Type: EventBuildStarted})
+}
+
+func (u *SafeUi) BuildFinished(halted bool, err error) {
+	EmitBuildFinished(u, halted, err)
+}
+
 func (u *SafeUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
 	u.Sem <- 1
 	ret := u.Ui.TrackProgress(src, currentSize, totalSize, stream)
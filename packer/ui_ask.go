@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNonInteractive is returned by Ask when there is no terminal to answer,
+// as when running in CI.
+var ErrNonInteractive = errors.New("no interactive terminal available to answer")
+
+// ErrAskTimeout is returned by AskWithOptions when the question isn't
+// answered in time and there is no default answer.
+var ErrAskTimeout = errors.New("timeout waiting for an answer")
+
+// AskOptions are the options of a question asked with AskWithOptions.
+type AskOptions struct {
+	// Default is the answer when the question is left empty, isn't answered
+	// in time, or when there is no terminal to answer. There is no default
+	// answer when empty.
+	Default string
+	// Timeout is how long to wait for an answer. There is no limit when
+	// zero.
+	Timeout time.Duration
+}
+
+// OptionsAsker is implemented by the Uis that support the options of
+// AskWithOptions.
+type OptionsAsker interface {
+	AskWithOptions(query string, opts AskOptions) (string, error)
+}
+
+// InteractiveUi is implemented by the Uis that can tell whether there is
+// somebody to answer their questions.
+type InteractiveUi interface {
+	Interactive() bool
+}
+
+// IsInteractive returns whether there is somebody to answer the questions
+// asked with ui, so that they can fail with ErrNonInteractive without being
+// asked. Uis which aren't InteractiveUis are assumed to be interactive.
+func IsInteractive(ui Ui) bool {
+	if i, ok := ui.(InteractiveUi); ok {
+		return i.Interactive()
+	}
+	return true
+}
+
+// AskWithOptions asks query with ui, so that steps asking for confirmation
+// don't block or fail when there is nobody to answer. It returns
+// ErrNonInteractive when there is no terminal to answer and no default
+// answer, and ErrAskTimeout when there is no answer in time and no default
+// answer.
+//
+// Uis which aren't OptionsAskers are asked with Ask, and are left waiting
+// for an answer on timeout.
+func AskWithOptions(ui Ui, query string, opts AskOptions) (string, error) {
+	if asker, ok := ui.(OptionsAsker); ok {
+		return asker.AskWithOptions(query, opts)
+	}
+
+	type answer struct {
+		line string
+		err  error
+	}
+	result := make(chan answer, 1)
+	go func() {
+		line, err := ui.Ask(query)
+		result <- answer{line, err}
+	}()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case a := <-result:
+		return opts.answer(a.line, a.err)
+	case <-timeout:
+		return opts.answer("", ErrAskTimeout)
+	}
+}
+
+// answer returns the answer of a question given the line read, or the error
+// reading it.
+func (opts AskOptions) answer(line string, err error) (string, error) {
+	if opts.Default == "" {
+		return line, err
+	}
+	if errors.Is(err, ErrNonInteractive) || errors.Is(err, ErrAskTimeout) || (err == nil && line == "") {
+		return opts.Default, nil
+	}
+	return line, err
+}
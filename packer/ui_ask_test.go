@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testTTY answers with line, or never answers when line is empty.
+type testTTY struct {
+	line string
+}
+
+func (t *testTTY) ReadString() (string, error) {
+	if t.line == "" {
+		select {}
+	}
+	return t.line, nil
+}
+
+func (t *testTTY) Close() error { return nil }
+
+func TestBasicUi_AskWithOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		tty      TTY
+		opts     AskOptions
+		expected string
+		err      error
+	}{
+		{"no tty", nil, AskOptions{}, "", ErrNonInteractive},
+		{"no tty with default", nil, AskOptions{Default: "n"}, "n", nil},
+		{"answer", &testTTY{line: "y\n"}, AskOptions{Default: "n"}, "y", nil},
+		{"empty answer", &testTTY{line: "\n"}, AskOptions{Default: "n"}, "n", nil},
+		{"timeout", &testTTY{}, AskOptions{Timeout: time.Millisecond}, "", ErrAskTimeout},
+		{"timeout with default", &testTTY{}, AskOptions{Default: "n", Timeout: time.Millisecond}, "n", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			ui := &BasicUi{Writer: &out, TTY: tc.tty}
+			answer, err := AskWithOptions(ui, "Overwrite?", tc.opts)
+			if err != tc.err || answer != tc.expected {
+				t.Fatalf("expected %q, %v, got %q, %v", tc.expected, tc.err, answer, err)
+			}
+		})
+	}
+}
+
+func TestBasicUi_AskWithOptionsQuery(t *testing.T) {
+	var out bytes.Buffer
+	ui := &BasicUi{Writer: &out, TTY: &testTTY{line: "\n"}}
+	AskWithOptions(ui, "Overwrite?", AskOptions{Default: "n"})
+	if expected := "Overwrite? [n] "; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestAskWithOptions_notOptionsAsker(t *testing.T) {
+	answer, err := AskWithOptions(new(MockUi), "Overwrite?", AskOptions{Default: "n"})
+	if err != nil || answer != "foo" {
+		t.Fatalf("expected the answer of the Ui, got %q, %v", answer, err)
+	}
+
+	var out bytes.Buffer
+	ui := &JSONUi{Writer: &out}
+	answer, err = AskWithOptions(ui, "Overwrite?", AskOptions{Default: "n"})
+	if err != nil || answer != "n" {
+		t.Fatalf("expected the default answer, got %q, %v", answer, err)
+	}
+}
+
+func TestIsInteractive(t *testing.T) {
+	for _, tc := range []struct {
+		ui       Ui
+		expected bool
+	}{
+		{ui: new(MockUi), expected: true},
+		{ui: &BasicUi{TTY: &testTTY{line: "y"}}, expected: true},
+		{ui: new(BasicUi), expected: false},
+		{ui: &SafeUi{Sem: make(chan int, 1), Ui: new(BasicUi)}, expected: false},
+		{ui: &PrefixedUi{Ui: &JSONUi{Reader: new(bytes.Buffer)}}, expected: true},
+		{ui: new(JSONUi), expected: false},
+	} {
+		if got := IsInteractive(tc.ui); got != tc.expected {
+			t.Fatalf("%T: expected %v, got %v", tc.ui, tc.expected, got)
+		}
+	}
+}
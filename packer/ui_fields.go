@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// FieldsSayer is implemented by the Uis that can attach structured fields to
+// their messages, such as an instance ID, a region or a duration, so that
+// log pipelines can index them. Use SayWithFields to say messages with fields
+// with any Ui.
+type FieldsSayer interface {
+	SayWithFields(message string, fields map[string]interface{})
+}
+
+// SayWithFields says message with ui, with fields when it's a FieldsSayer.
+// Other Uis only say message, and the fields are logged.
+func SayWithFields(ui Ui, message string, fields map[string]interface{}) {
+	if sayer, ok := ui.(FieldsSayer); ok {
+		sayer.SayWithFields(message, fields)
+		return
+	}
+	if len(fields) > 0 {
+		log.Printf("ui fields: %s", LogSecretFilter.FilterString(formatFields(fields)))
+	}
+	ui.Say(message)
+}
+
+// formatFields returns fields as space separated key=value pairs, sorted by
+// key. The values with spaces are quoted.
+func formatFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		v := fmt.Sprint(fields[k])
+		if strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		pairs[i] = k + "=" + v
+	}
+	return strings.Join(pairs, " ")
+}
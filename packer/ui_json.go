@@ -0,0 +1,263 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSONUi is an implementation of Ui writing each of its calls as a line of
+// JSON, so that its output can be parsed by CI systems and log pipelines. It
+// is safe to be called from multiple goroutines.
+//
+// Every line is an object with a "timestamp", in RFC 3339 format, a "level",
+// "info" or "error" or the level given to Log, the "type" of the call, such as
+// "say" or "error", and the "build" name when BuildName is set. The text of
+// Say, Message, Error, Log and Ask is in "message", the fields of
+// SayWithFields in "fields", the machine readable output in "machine_type"
+// and "machine_data", the progress of transfers in "progress", and the events
+// emitted in "event".
+type JSONUi struct {
+	// Writer is where the lines are written.
+	Writer io.Writer
+	// Reader, if set, is where the answers of Ask are read from, a line per
+	// answer. Ask returns ErrNonInteractive without it.
+	Reader io.Reader
+	// BuildName is the name of the build the output belongs to.
+	BuildName string
+	// ProgressInterval is the minimum time between two progress events of a
+	// transfer. Defaults to a second.
+	ProgressInterval time.Duration
+	// Level is the lowest level of the messages written with Log that are
+	// written, the others are only logged. Defaults to UiLevelInfo.
+	Level UiLevel
+
+	l      sync.Mutex
+	reader *bufio.Reader
+}
+
+var _ Ui = new(JSONUi)
+
+// The levels and types of the events of a JSONUi.
+const (
+	jsonUiLevelInfo  = "info"
+	jsonUiLevelError = "error"
+
+	jsonUiTypeAsk      = "ask"
+	jsonUiTypeSay      = "say"
+	jsonUiTypeMessage  = "message"
+	jsonUiTypeError    = "error"
+	jsonUiTypeMachine  = "machine"
+	jsonUiTypeProgress = "progress"
+	jsonUiTypeLog      = "log"
+)
+
+type jsonUiEvent struct {
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Type        string                 `json:"type"`
+	Build       string                 `json:"build,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	MachineType string                 `json:"machine_type,omitempty"`
+	MachineData []string               `json:"machine_data,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Progress    *jsonUiProgress        `json:"progress,omitempty"`
+	Event       *Event                 `json:"event,omitempty"`
+}
+
+type jsonUiProgress struct {
+	Source  string `json:"source"`
+	Current int64  `json:"current"`
+	Total   int64  `json:"total"`
+	Done    bool   `json:"done"`
+}
+
+func (u *JSONUi) write(e jsonUiEvent) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	u.writeLocked(e)
+}
+
+func (u *JSONUi) writeLocked(e jsonUiEvent) {
+	e.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	if e.Build == "" {
+		e.Build = u.BuildName
+	}
+	// Use LogSecretFilter to scrub out sensitive variables
+	e.Message = LogSecretFilter.FilterString(e.Message)
+	for i, arg := range e.MachineData {
+		e.MachineData[i] = LogSecretFilter.FilterString(arg)
+	}
+
+	if err := json.NewEncoder(u.Writer).Encode(e); err != nil {
+		log.Printf("[ERR] Failed to write to UI: %s", err)
+	}
+}
+
+func (u *JSONUi) Ask(query string) (string, error) {
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	log.Printf("ui: ask: %s", query)
+	u.writeLocked(jsonUiEvent{Level: jsonUiLevelInfo, Type: jsonUiTypeAsk, Message: query})
+	if u.Reader == nil {
+		return "", ErrNonInteractive
+	}
+	if u.reader == nil {
+		u.reader = bufio.NewReader(u.Reader)
+	}
+	line, err := u.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// Interactive returns whether the Ui has a Reader to read the answers from.
+func (u *JSONUi) Interactive() bool {
+	return u.Reader != nil
+}
+
+func (u *JSONUi) Say(message string) {
+	log.Printf("ui: %s", LogSecretFilter.FilterString(message))
+	u.write(jsonUiEvent{Level: jsonUiLevelInfo, Type: jsonUiTypeSay, Message: message})
+}
+
+// SayWithFields writes a say line with fields in "fields".
+func (u *JSONUi) SayWithFields(message string, fields map[string]interface{}) {
+	log.Printf("ui: %s", LogSecretFilter.FilterString(message))
+	filtered := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if s, ok := v.(string); ok {
+			v = LogSecretFilter.FilterString(s)
+		}
+		filtered[k] = v
+	}
+	u.write(jsonUiEvent{Level: jsonUiLevelInfo, Type: jsonUiTypeSay, Message: message, Fields: filtered})
+}
+
+func (u *JSONUi) Message(message string) {
+	log.Printf("ui: %s", LogSecretFilter.FilterString(message))
+	u.write(jsonUiEvent{Level: jsonUiLevelInfo, Type: jsonUiTypeMessage, Message: message})
+}
+
+func (u *JSONUi) Error(message string) {
+	log.Printf("ui error: %s", LogSecretFilter.FilterString(message))
+	u.write(jsonUiEvent{Level: jsonUiLevelError, Type: jsonUiTypeError, Message: message})
+}
+
+func (u *JSONUi) Machine(t string, args ...string) {
+	u.write(jsonUiEvent{
+		Level:       jsonUiLevelInfo,
+		Type:        jsonUiTypeMachine,
+		MachineType: t,
+		MachineData: append([]string(nil), args...),
+	})
+}
+
+// Log writes message with the name of its level as level, from Level.
+func (u *JSONUi) Log(level UiLevel, message string) {
+	log.Printf("ui %s: %s", level, LogSecretFilter.FilterString(message))
+	if level < u.Level {
+		return
+	}
+	u.write(jsonUiEvent{Level: level.String(), Type: jsonUiTypeLog, Message: message})
+}
+
+// Emit writes e, with its type as the type of the line.
+func (u *JSONUi) Emit(e Event) {
+	level := jsonUiLevelInfo
+	if e.Type == EventError {
+		level = jsonUiLevelError
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Message = LogSecretFilter.FilterString(e.Message)
+	u.write(jsonUiEvent{Level: level, Type: string(e.Type), Build: e.Build, Message: e.Message, Event: &e})
+}
+
+func (u *JSONUi) StepStarted(name string) {
+	u.Emit(Event{Type: EventStepStarted, Step: name})
+}
+
+func (u *JSONUi) StepFinished(name string, halted bool) {
+	u.Emit(Event{Type: EventStepFinished, Step: name, Halted: halted})
+}
+
+func (u *JSONUi) BuildStarted() {
+	u.Emit(Event{Type: EventBuildStarted})
+}
+
+func (u *JSONUi) BuildFinished(halted bool, err error) {
+	EmitBuildFinished(u, halted, err)
+}
+
+// TrackProgress writes a progress event when the transfer starts, at most
+// every ProgressInterval while it runs, and when stream is closed.
+func (u *JSONUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	interval := u.ProgressInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	r := &jsonUiProgressReader{
+		ui:       u,
+		stream:   stream,
+		interval: interval,
+		progress: jsonUiProgress{Source: src, Current: currentSize, Total: totalSize},
+	}
+	r.report()
+	return r
+}
+
+// jsonUiProgressReader reports the progress of the reads of a stream.
+type jsonUiProgressReader struct {
+	ui       *JSONUi
+	stream   io.ReadCloser
+	interval time.Duration
+
+	l        sync.Mutex
+	progress jsonUiProgress
+	last     time.Time
+	closed   bool
+}
+
+func (r *jsonUiProgressReader) Read(p []byte) (int, error) {
+	n, err := r.stream.Read(p)
+
+	r.l.Lock()
+	r.progress.Current += int64(n)
+	report := time.Since(r.last) >= r.interval
+	r.l.Unlock()
+	if report {
+		r.report()
+	}
+	return n, err
+}
+
+func (r *jsonUiProgressReader) Close() error {
+	r.l.Lock()
+	closed := r.closed
+	r.closed = true
+	r.progress.Done = true
+	r.l.Unlock()
+	if !closed {
+		r.report()
+	}
+	return r.stream.Close()
+}
+
+func (r *jsonUiProgressReader) report() {
+	r.l.Lock()
+	r.last = time.Now()
+	progress := r.progress
+	r.l.Unlock()
+	r.ui.write(jsonUiEvent{Level: jsonUiLevelInfo, Type: jsonUiTypeProgress, Progress: &progress})
+}
@@ -0,0 +1,166 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readJSONUiEvents(t *testing.T, b *bytes.Buffer) []map[string]interface{} {
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid line %q: %s", line, err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, e["timestamp"].(string)); err != nil {
+			t.Fatalf("invalid timestamp: %s", err)
+		}
+		delete(e, "timestamp")
+		events = append(events, e)
+	}
+	b.Reset()
+	return events
+}
+
+func TestJSONUi(t *testing.T) {
+	var b bytes.Buffer
+	ui := &JSONUi{Writer: &b, BuildName: "docker.ubuntu"}
+
+	LogSecretFilter.Set("s3cr3t")
+	defer delete(LogSecretFilter.s, "s3cr3t")
+
+	ui.Say("Starting s3cr3t")
+	ui.Message("message")
+	ui.Error("oops")
+	ui.Machine("artifact", "0", "id", "s3cr3t")
+
+	expected := []map[string]interface{}{
+		{"level": "info", "type": "say", "build": "docker.ubuntu", "message": "Starting <sensitive>"},
+		{"level": "info", "type": "message", "build": "docker.ubuntu", "message": "message"},
+		{"level": "error", "type": "error", "build": "docker.ubuntu", "message": "oops"},
+		{"level": "info", "type": "machine", "build": "docker.ubuntu", "machine_type": "artifact",
+			"machine_data": []interface{}{"0", "id", "<sensitive>"}},
+	}
+	events := readJSONUiEvents(t, &b)
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i := range expected {
+		got, _ := json.Marshal(events[i])
+		want, _ := json.Marshal(expected[i])
+		if !bytes.Equal(got, want) {
+			t.Errorf("event %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestJSONUi_Ask(t *testing.T) {
+	var b bytes.Buffer
+	ui := &JSONUi{Writer: &b}
+	if _, err := ui.Ask("Continue?"); err != ErrNonInteractive {
+		t.Fatalf("expected ErrNonInteractive without a reader, got %v", err)
+	}
+
+	ui = &JSONUi{Writer: &b, Reader: strings.NewReader("yes\nno")}
+	for _, expected := range []string{"yes", "no"} {
+		answer, err := ui.Ask("Continue?")
+		if err != nil {
+			t.Fatalf("error asking: %s", err)
+		}
+		if answer != expected {
+			t.Fatalf("expected %q, got %q", expected, answer)
+		}
+	}
+	if _, err := ui.Ask("Continue?"); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestJSONUi_TrackProgress(t *testing.T) {
+	var b bytes.Buffer
+	ui := &JSONUi{Writer: &b, ProgressInterval: time.Hour}
+
+	stream := ui.TrackProgress("file.iso", 10, 110, ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 100))))
+	if _, err := io.Copy(ioutil.Discard, stream); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	stream.Close()
+
+	events := readJSONUiEvents(t, &b)
+	if len(events) != 2 {
+		t.Fatalf("expected a start and an end event, got %v", events)
+	}
+	for i, expected := range []map[string]interface{}{
+		{"source": "file.iso", "current": 10.0, "total": 110.0, "done": false},
+		{"source": "file.iso", "current": 110.0, "total": 110.0, "done": true},
+	} {
+		got, _ := json.Marshal(events[i]["progress"])
+		want, _ := json.Marshal(expected)
+		if !bytes.Equal(got, want) {
+			t.Errorf("event %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestJSONUi_Emit(t *testing.T) {
+	var b bytes.Buffer
+	ui := &JSONUi{Writer: &b, BuildName: "docker.ubuntu"}
+
+	ui.StepStarted("StepConnect")
+	ui.StepFinished("StepConnect", true)
+	EmitEvent(ui, ErrorEvent(errors.New("oops")))
+
+	events := readJSONUiEvents(t, &b)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %v", events)
+	}
+	for i, expected := range []struct{ level, typ string }{
+		{"info", "step_started"},
+		{"info", "step_finished"},
+		{"error", "error"},
+	} {
+		if events[i]["level"] != expected.level || events[i]["type"] != expected.typ {
+			t.Errorf("event %d: unexpected level and type in %v", i, events[i])
+		}
+	}
+	event := events[1]["event"].(map[string]interface{})
+	if event["step"] != "StepConnect" || event["halted"] != true {
+		t.Errorf("unexpected step event %v", event)
+	}
+	event = events[2]["event"].(map[string]interface{})
+	if event["message"] != "oops" || event["error_class"] != "internal" {
+		t.Errorf("unexpected error event %v", event)
+	}
+}
+
+func TestJSONUi_SayWithFields(t *testing.T) {
+	var b bytes.Buffer
+	ui := &JSONUi{Writer: &b}
+
+	LogSecretFilter.Set("s3cr3t")
+	defer delete(LogSecretFilter.s, "s3cr3t")
+
+	SayWithFields(ui, "Instance started", map[string]interface{}{"instance_id": "i-1234", "token": "s3cr3t", "count": 2})
+
+	events := readJSONUiEvents(t, &b)
+	expected := []map[string]interface{}{{
+		"level":   "info",
+		"type":    "say",
+		"message": "Instance started",
+		"fields":  map[string]interface{}{"count": 2.0, "instance_id": "i-1234", "token": "<sensitive>"},
+	}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected %v, got %v", expected, events)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// UiLevel is the level of a message written with UiLog. The zero value is
+// UiLevelInfo.
+type UiLevel int
+
+const (
+	UiLevelTrace UiLevel = iota - 2
+	UiLevelDebug
+	UiLevelInfo
+	UiLevelWarn
+	UiLevelError
+)
+
+var uiLevelNames = map[UiLevel]string{
+	UiLevelTrace: "trace",
+	UiLevelDebug: "debug",
+	UiLevelInfo:  "info",
+	UiLevelWarn:  "warn",
+	UiLevelError: "error",
+}
+
+func (l UiLevel) String() string {
+	if name, ok := uiLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("UiLevel(%d)", int(l))
+}
+
+// ParseUiLevel returns the level named s, such as "debug" or "WARN".
+func ParseUiLevel(s string) (UiLevel, error) {
+	for l, name := range uiLevelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return UiLevelInfo, fmt.Errorf("unknown ui level %q", s)
+}
+
+// LeveledUi is implemented by the Uis that can filter their messages by
+// level. Use UiLog to write messages with a level with any Ui.
+type LeveledUi interface {
+	Log(level UiLevel, message string)
+}
+
+// UiLog writes message with ui at the given level. Uis which aren't
+// LeveledUis show the messages from UiLevelInfo with Say, and the errors with
+// Error; the trace and debug messages are only logged.
+func UiLog(ui Ui, level UiLevel, message string) {
+	if leveled, ok := ui.(LeveledUi); ok {
+		leveled.Log(level, message)
+		return
+	}
+	switch {
+	case level >= UiLevelError:
+		ui.Error(message)
+	case level >= UiLevelInfo:
+		ui.Say(message)
+	default:
+		log.Printf("ui %s: %s", level, LogSecretFilter.FilterString(message))
+	}
+}
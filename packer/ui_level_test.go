@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBasicUi_Log(t *testing.T) {
+	var out, errOut bytes.Buffer
+	ui := &BasicUi{Writer: &out, ErrorWriter: &errOut}
+
+	for l := UiLevelTrace; l <= UiLevelError; l++ {
+		UiLog(ui, l, l.String())
+	}
+	if expected := "info\nwarn\n"; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+	if expected := "error\n"; errOut.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, errOut.String())
+	}
+
+	out.Reset()
+	ui.Level = UiLevelTrace
+	UiLog(ui, UiLevelTrace, "trace")
+	if expected := "trace\n"; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestUiLog_notLeveled(t *testing.T) {
+	ui := new(MockUi)
+	UiLog(ui, UiLevelDebug, "debug")
+	UiLog(ui, UiLevelWarn, "warn")
+	UiLog(ui, UiLevelError, "error")
+
+	if len(ui.SayMessages) != 1 || ui.SayMessages[0].Message != "warn" {
+		t.Fatalf("unexpected messages %#v", ui.SayMessages)
+	}
+	if ui.ErrorMessage != "error" {
+		t.Fatalf("unexpected error %q", ui.ErrorMessage)
+	}
+}
+
+func TestParseUiLevel(t *testing.T) {
+	for _, l := range []UiLevel{UiLevelTrace, UiLevelDebug, UiLevelInfo, UiLevelWarn, UiLevelError} {
+		parsed, err := ParseUiLevel(l.String())
+		if err != nil || parsed != l {
+			t.Fatalf("parsing %s: got %s, %v", l, parsed, err)
+		}
+	}
+	if l, err := ParseUiLevel("WARN"); err != nil || l != UiLevelWarn {
+		t.Fatalf("expected warn, got %s, %v", l, err)
+	}
+	if _, err := ParseUiLevel("verbose"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+}
+
+func TestSayWithFields(t *testing.T) {
+	var out bytes.Buffer
+	SayWithFields(&BasicUi{Writer: &out}, "Instance started", map[string]interface{}{"instance_id": "i-1234"})
+	if expected := "Instance started\n"; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+
+	ui := new(MockUi)
+	SayWithFields(ui, "Instance started", map[string]interface{}{"instance_id": "i-1234"})
+	if len(ui.SayMessages) != 1 || ui.SayMessages[0].Message != "Instance started" {
+		t.Fatalf("unexpected messages %#v", ui.SayMessages)
+	}
+
+	if got := formatFields(map[string]interface{}{"b": "two words", "a": 1}); got != `a=1 b="two words"` {
+		t.Fatalf("unexpected fields %s", got)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UiColor is the ANSI code of the color of the output of a PrefixedUi.
+type UiColor uint
+
+const (
+	UiColorRed     UiColor = 31
+	UiColorGreen   UiColor = 32
+	UiColorYellow  UiColor = 33
+	UiColorBlue    UiColor = 34
+	UiColorMagenta UiColor = 35
+	UiColorCyan    UiColor = 36
+)
+
+// PrefixedUiColors are the colors given to the builds by NewPrefixedUis, in
+// order.
+var PrefixedUiColors = []UiColor{
+	UiColorGreen,
+	UiColorCyan,
+	UiColorMagenta,
+	UiColorYellow,
+	UiColorBlue,
+}
+
+// PrefixedUi is a Ui decorator formatting the output of a build the way
+// Packer does when running several builds: each line is prefixed with the
+// name of the build, "==> name: " for Say and Error, and "    name: " for
+// Message, and colored.
+type PrefixedUi struct {
+	// Prefix is the name of the build.
+	Prefix string
+	// Width is the width the prefixes are padded to, to align the output of
+	// builds of different names.
+	Width int
+	// Color is the color of the output, none when zero.
+	Color UiColor
+	// ErrorColor is the color of the errors. Defaults to UiColorRed when
+	// Color is set.
+	ErrorColor UiColor
+	Ui         Ui
+}
+
+var _ Ui = new(PrefixedUi)
+
+// NewPrefixedUis returns a PrefixedUi writing with ui for each of the builds
+// in names, aligned, and colored with PrefixedUiColors when color is true.
+func NewPrefixedUis(ui Ui, names []string, color bool) map[string]*PrefixedUi {
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	uis := make(map[string]*PrefixedUi, len(names))
+	for i, name := range names {
+		prefixed := &PrefixedUi{Prefix: name, Width: width, Ui: ui}
+		if color {
+			prefixed.Color = PrefixedUiColors[i%len(PrefixedUiColors)]
+		}
+		uis[name] = prefixed
+	}
+	return uis
+}
+
+func (u *PrefixedUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(u.colorize(u.prefixLines(true, query), u.Color, true))
+}
+
+func (u *PrefixedUi) AskWithOptions(query string, opts AskOptions) (string, error) {
+	return AskWithOptions(u.Ui, u.colorize(u.prefixLines(true, query), u.Color, true), opts)
+}
+
+func (u *PrefixedUi) Interactive() bool {
+	return IsInteractive(u.Ui)
+}
+
+func (u *PrefixedUi) Say(message string) {
+	u.Ui.Say(u.colorize(u.prefixLines(true, message), u.Color, true))
+}
+
+func (u *PrefixedUi) SayWithFields(message string, fields map[string]interface{}) {
+	SayWithFields(u.Ui, u.colorize(u.prefixLines(true, message), u.Color, true), fields)
+}
+
+func (u *PrefixedUi) Message(message string) {
+	u.Ui.Message(u.colorize(u.prefixLines(false, message), u.Color, false))
+}
+
+func (u *PrefixedUi) Error(message string) {
+	color := u.ErrorColor
+	if color == 0 && u.Color != 0 {
+		color = UiColorRed
+	}
+	u.Ui.Error(u.colorize(u.prefixLines(true, message), color, true))
+}
+
+// Machine writes the output with the Ui, the name of the build being already
+// part of the machine readable output.
+func (u *PrefixedUi) Machine(t string, args ...string) {
+	u.Ui.Machine(t, args...)
+}
+
+// Log writes message with the Ui, prefixed like Say, or like Error from
+// UiLevelError.
+func (u *PrefixedUi) Log(level UiLevel, message string) {
+	color := u.Color
+	if level >= UiLevelError {
+		color = u.ErrorColor
+		if color == 0 && u.Color != 0 {
+			color = UiColorRed
+		}
+	}
+	UiLog(u.Ui, level, u.colorize(u.prefixLines(true, message), color, true))
+}
+
+// Emit emits e with the Ui, with the name of the build when it isn't set.
+func (u *PrefixedUi) Emit(e Event) {
+	if e.Build == "" {
+		e.Build = u.Prefix
+	}
+	EmitEvent(u.Ui, e)
+}
+
+func (u *PrefixedUi) StepStarted(name string) {
+	EmitEvent(u, Event{Type: EventStepStarted, Step: name})
+}
+
+func (u *PrefixedUi) StepFinished(name string, halted bool) {
+	EmitEvent(u, Event{Type: EventStepFinished, Step: name, Halted: halted})
+}
+
+func (u *PrefixedUi) RegisterSecrets(secrets ...string) {
+	RegisterSecrets(u.Ui, secrets...)
+}
+
+func (u *PrefixedUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	return u.Ui.TrackProgress(src, currentSize, totalSize, stream)
+}
+
+func (u *PrefixedUi) prefixLines(arrow bool, message string) string {
+	prefix := fmt.Sprintf("%-*s", u.Width, u.Prefix)
+	if arrow {
+		prefix = "==> " + prefix + ": "
+	} else {
+		prefix = "    " + prefix + ": "
+	}
+
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (u *PrefixedUi) colorize(message string, color UiColor, bold bool) string {
+	if color == 0 {
+		return message
+	}
+	attr := 0
+	if bold {
+		attr = 1
+	}
+	return fmt.Sprintf("\033[%d;%dm%s\033[0m", attr, color, message)
+}
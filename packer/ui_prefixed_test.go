@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixedUi(t *testing.T) {
+	var out bytes.Buffer
+	ui := &BasicUi{Writer: &out}
+	uis := NewPrefixedUis(ui, []string{"docker.ubuntu", "qemu.a"}, false)
+
+	uis["qemu.a"].Say("Starting\nVM")
+	uis["docker.ubuntu"].Message("Pulling")
+	uis["qemu.a"].Error("oops")
+
+	expected := "==> qemu.a       : Starting\n" +
+		"==> qemu.a       : VM\n" +
+		"    docker.ubuntu: Pulling\n" +
+		"==> qemu.a       : oops\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestPrefixedUi_color(t *testing.T) {
+	var out bytes.Buffer
+	ui := &BasicUi{Writer: &out}
+	uis := NewPrefixedUis(ui, []string{"a", "b"}, true)
+
+	if uis["a"].Color != UiColorGreen || uis["b"].Color != UiColorCyan {
+		t.Fatalf("unexpected colors %d and %d", uis["a"].Color, uis["b"].Color)
+	}
+
+	uis["b"].Say("hello")
+	uis["b"].Message("world")
+	uis["b"].Error("oops")
+	expected := "\033[1;36m==> b: hello\033[0m\n" +
+		"\033[0;36m    b: world\033[0m\n" +
+		"\033[1;31m==> b: oops\033[0m\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestPrefixedUi_Emit(t *testing.T) {
+	ui := new(testEventUi)
+	prefixed := &PrefixedUi{Prefix: "docker.ubuntu", Ui: ui}
+	prefixed.StepStarted("StepPull")
+
+	if len(ui.events) != 1 || ui.events[0].Build != "docker.ubuntu" || ui.events[0].Step != "StepPull" {
+		t.Fatalf("unexpected events %#v", ui.events)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ChainStep is a step of a post-processor chain.
+type ChainStep struct {
+	// PostProcessor is the name of a post-processor registered in the
+	// same Set.
+	PostProcessor string
+	// Config is the configuration the plugin fixes for the step. It is
+	// passed before the one of the user, which can override it.
+	Config map[string]interface{}
+}
+
+// RegisterPostProcessorChain registers, as the post-processor name, the
+// post-processors of steps running one after the other, each one on the
+// artifact of the previous one. For example, registering the steps
+// "compress", "checksum" and "upload" ships them as a single "release"
+// post-processor:
+//
+//	post-processor "foo-release" {
+//	  compress {
+//	    format = "zip"
+//	  }
+//	  upload {
+//	    bucket = "images"
+//	  }
+//	}
+//
+// Each step is configured with its block, with the other settings Packer
+// passes, like the build name, shared by all the steps.
+func (i *Set) RegisterPostProcessorChain(name string, steps ...ChainStep) {
+	if len(steps) == 0 {
+		panic(fmt.Errorf("registering %s post-processor chain without steps", name))
+	}
+	chain := &postProcessorChain{name: name}
+	for _, step := range steps {
+		pp, found := i.PostProcessors[step.PostProcessor]
+		if !found {
+			panic(fmt.Errorf("registering %s post-processor chain: unknown %s post-processor", name, step.PostProcessor))
+		}
+		if chain.has(step.PostProcessor) {
+			panic(fmt.Errorf("registering %s post-processor chain: duplicate %s step", name, step.PostProcessor))
+		}
+		chain.steps = append(chain.steps, chainStep{ChainStep: step, pp: pp})
+	}
+	i.RegisterPostProcessor(name, chain)
+}
+
+type chainStep struct {
+	ChainStep
+	pp packersdk.PostProcessor
+}
+
+// postProcessorChain is a post-processor running the post-processors of its
+// steps in order.
+type postProcessorChain struct {
+	name  string
+	steps []chainStep
+}
+
+var _ packersdk.PostProcessor = new(postProcessorChain)
+
+func (c *postProcessorChain) has(name string) bool {
+	for _, step := range c.steps {
+		if step.PostProcessor == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigSpec has a block for each step, holding its configuration.
+func (c *postProcessorChain) ConfigSpec() hcldec.ObjectSpec {
+	spec := hcldec.ObjectSpec{}
+	for _, step := range c.steps {
+		spec[step.PostProcessor] = &hcldec.BlockSpec{
+			TypeName: step.PostProcessor,
+			Nested:   step.pp.ConfigSpec(),
+		}
+	}
+	return spec
+}
+
+func (c *postProcessorChain) Configure(raws ...interface{}) error {
+	for _, step := range c.steps {
+		configs := []interface{}{}
+		if step.Config != nil {
+			configs = append(configs, step.Config)
+		}
+		for _, raw := range raws {
+			if config := c.stepConfig(step.PostProcessor, raw); config != nil {
+				configs = append(configs, config)
+			}
+		}
+		if err := step.pp.Configure(configs...); err != nil {
+			return fmt.Errorf("%s: %w", step.PostProcessor, err)
+		}
+	}
+	return nil
+}
+
+// stepConfig returns the part of raw configuring the step name: its block,
+// and the settings not belonging to a step. It returns nil when raw has
+// nothing for the step.
+func (c *postProcessorChain) stepConfig(name string, raw interface{}) interface{} {
+	switch raw := raw.(type) {
+	case cty.Value:
+		if raw.IsNull() || !raw.Type().IsObjectType() || !raw.Type().HasAttribute(name) {
+			return nil
+		}
+		if v := raw.GetAttr(name); !v.IsNull() {
+			return v
+		}
+		return nil
+	case map[string]interface{}:
+		config := map[string]interface{}{}
+		for k, v := range raw {
+			switch {
+			case k == name:
+				block, ok := v.(map[string]interface{})
+				if !ok {
+					// Let the step report the bad configuration.
+					config[k] = v
+					continue
+				}
+				for k, v := range block {
+					config[k] = v
+				}
+			case c.has(k):
+			default:
+				config[k] = v
+			}
+		}
+		if len(config) == 0 {
+			return nil
+		}
+		return config
+	default:
+		return raw
+	}
+}
+
+// PostProcess runs the steps in order. The intermediate artifacts are
+// destroyed when the step using them doesn't keep them. Whether Packer keeps
+// the input artifact is up to the first step.
+func (c *postProcessorChain) PostProcess(ctx context.Context, ui packersdk.Ui, source packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	artifact := source
+	keep, forceOverride := true, false
+	for n, step := range c.steps {
+		ui.Say(fmt.Sprintf("%s: running %s (%d/%d)", c.name, step.PostProcessor, n+1, len(c.steps)))
+		result, stepKeep, stepForceOverride, err := step.pp.PostProcess(ctx, ui, artifact)
+		if err != nil {
+			return nil, keep, forceOverride, fmt.Errorf("%s: %w", step.PostProcessor, err)
+		}
+		if n == 0 {
+			keep, forceOverride = stepKeep, stepForceOverride
+		} else if !stepKeep && result != artifact {
+			if err := artifact.Destroy(); err != nil {
+				return nil, keep, forceOverride, fmt.Errorf("%s: destroying the artifact of %s: %w", step.PostProcessor, c.steps[n-1].PostProcessor, err)
+			}
+		}
+		if result == nil && n < len(c.steps)-1 {
+			return nil, keep, forceOverride, fmt.Errorf("%s returned no artifact", step.PostProcessor)
+		}
+		artifact = result
+	}
+	return artifact, keep, forceOverride, nil
+}
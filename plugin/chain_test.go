@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type chainPostProcessor struct {
+	suffix  string
+	keep    bool
+	configs []interface{}
+}
+
+func (*chainPostProcessor) ConfigSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		"level": &hcldec.AttrSpec{Name: "level", Type: cty.Number},
+	}
+}
+
+func (p *chainPostProcessor) Configure(raws ...interface{}) error {
+	p.configs = raws
+	return nil
+}
+
+func (p *chainPostProcessor) PostProcess(_ context.Context, _ packersdk.Ui, a packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	return &packersdk.MockArtifact{IdValue: a.Id() + p.suffix}, p.keep, false, nil
+}
+
+func TestSet_RegisterPostProcessorChain(t *testing.T) {
+	compress := &chainPostProcessor{suffix: ".zip", keep: true}
+	checksum := &chainPostProcessor{suffix: ".sum"}
+	set := NewSet()
+	set.RegisterPostProcessor("compress", compress)
+	set.RegisterPostProcessor("checksum", checksum)
+	set.RegisterPostProcessorChain("release",
+		ChainStep{PostProcessor: "compress", Config: map[string]interface{}{"level": 9}},
+		ChainStep{PostProcessor: "checksum"},
+	)
+
+	chain := set.PostProcessors["release"]
+	spec := chain.ConfigSpec()
+	if len(spec) != 2 || spec["compress"] == nil || spec["checksum"] == nil {
+		t.Fatalf("bad spec: %#v", spec)
+	}
+
+	err := chain.Configure(
+		map[string]interface{}{"compress": map[string]interface{}{"level": 1}},
+		map[string]interface{}{"packer_build_name": "example"},
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"level": 9},
+		map[string]interface{}{"level": 1},
+		map[string]interface{}{"packer_build_name": "example"},
+	}
+	if !reflect.DeepEqual(compress.configs, expected) {
+		t.Fatalf("bad compress configs: %#v", compress.configs)
+	}
+	expected = []interface{}{map[string]interface{}{"packer_build_name": "example"}}
+	if !reflect.DeepEqual(checksum.configs, expected) {
+		t.Fatalf("bad checksum configs: %#v", checksum.configs)
+	}
+
+	level := cty.ObjectVal(map[string]cty.Value{"level": cty.NumberIntVal(3)})
+	err = chain.Configure(cty.ObjectVal(map[string]cty.Value{
+		"compress": level,
+		"checksum": cty.NullVal(level.Type()),
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(compress.configs) != 2 || !compress.configs[1].(cty.Value).RawEquals(level) {
+		t.Fatalf("bad compress configs: %#v", compress.configs)
+	}
+	if len(checksum.configs) != 0 {
+		t.Fatalf("bad checksum configs: %#v", checksum.configs)
+	}
+
+	artifact, keep, _, err := chain.PostProcess(context.Background(), packersdk.TestUi(t), &packersdk.MockArtifact{IdValue: "image"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if artifact.Id() != "image.zip.sum" {
+		t.Fatalf("bad artifact: %s", artifact.Id())
+	}
+	if !keep {
+		t.Fatal("the input artifact should be kept, as the first step does")
+	}
+}
+
+func TestSet_RegisterPostProcessorChain_unknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("should panic")
+		}
+	}()
+	set := NewSet()
+	set.RegisterPostProcessorChain("release", ChainStep{PostProcessor: "compress"})
+}
@@ -0,0 +1,261 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ConfigDocumenter is implemented by the components documenting the options
+// of their configuration in the describe output of the plugin.
+//
+// ConfigStruct returns the configuration struct of the component, usually a
+// pointer to its Config. The options are matched with its fields using their
+// mapstructure tag, and documented with the partials generated from the
+// comments of the structs by packer-sdc struct-markdown, see Set.SetDocs.
+type ConfigDocumenter interface {
+	ConfigStruct() interface{}
+}
+
+// ComponentDescription describes a component of a Set.
+type ComponentDescription struct {
+	// Version is the version of the component, see ComponentVersioner.
+	Version string `json:"version,omitempty"`
+	// Options are the options of the configuration of the component, or the
+	// parameters of a function.
+	Options []OptionDescription `json:"options"`
+	// Outputs are the outputs of a datasource.
+	Outputs []OptionDescription `json:"outputs,omitempty"`
+	// Doc is the description of a function.
+	Doc string `json:"doc,omitempty"`
+	// VarParam is the parameter of the variable number of arguments of a
+	// function.
+	VarParam *OptionDescription `json:"var_param,omitempty"`
+	// ReturnType is the type of the values returned by a function.
+	ReturnType string `json:"return_type,omitempty"`
+}
+
+// OptionDescription describes an option of a configuration, an attribute or
+// a block of its HCL2 spec, or a parameter of a function.
+type OptionDescription struct {
+	Name string `json:"name"`
+	// Type is the type of an attribute, like "list(string)", or the kind
+	// of block, "block", "block_list", "block_attrs" or "block_object".
+	Type string `json:"type"`
+	// Required is set for the required options, and the parameters of the
+	// functions not accepting null.
+	Required bool   `json:"required"`
+	Doc      string `json:"doc,omitempty"`
+	// Options are the options of a block.
+	Options []OptionDescription `json:"options,omitempty"`
+}
+
+// describeComponent describes the options of a component, and the outputs of
+// a datasource, documented with docs.
+func describeComponent(c interface{ ConfigSpec() hcldec.ObjectSpec }, docs fs.FS) ComponentDescription {
+	res := ComponentDescription{Options: describeSpec(c.ConfigSpec())}
+	if d, ok := c.(ConfigDocumenter); ok && docs != nil {
+		documentOptions(res.Options, reflect.TypeOf(d.ConfigStruct()), docs)
+	}
+	if d, ok := c.(interface{ OutputSpec() hcldec.ObjectSpec }); ok {
+		res.Outputs = describeSpec(d.OutputSpec())
+	}
+	return res
+}
+
+// describeFunction describes the parameters and the return type of f.
+func describeFunction(f packersdk.Function) ComponentDescription {
+	sig := f.Signature()
+	res := ComponentDescription{
+		Options:    []OptionDescription{},
+		Doc:        sig.Description,
+		ReturnType: describeType(sig.ReturnType),
+	}
+	for _, p := range sig.Params {
+		res.Options = append(res.Options, describeParameter(p))
+	}
+	if sig.VarParam != nil {
+		p := describeParameter(*sig.VarParam)
+		res.VarParam = &p
+	}
+	return res
+}
+
+func describeParameter(p packersdk.FunctionParameter) OptionDescription {
+	return OptionDescription{Name: p.Name, Type: describeType(p.Type), Required: !p.AllowNull, Doc: p.Description}
+}
+
+// describeSpec returns the options of spec, sorted by name.
+func describeSpec(spec hcldec.ObjectSpec) []OptionDescription {
+	res := []OptionDescription{}
+	for _, s := range spec {
+		var o OptionDescription
+		switch s := s.(type) {
+		case *hcldec.AttrSpec:
+			o = OptionDescription{Name: s.Name, Type: describeType(s.Type), Required: s.Required}
+		case *hcldec.BlockSpec:
+			o = OptionDescription{Name: s.TypeName, Type: "block", Required: s.Required, Options: describeNested(s.Nested)}
+		case *hcldec.BlockListSpec:
+			o = OptionDescription{Name: s.TypeName, Type: "block_list", Required: s.MinItems > 0, Options: describeNested(s.Nested)}
+		case *hcldec.BlockAttrsSpec:
+			o = OptionDescription{Name: s.TypeName, Type: "block_attrs", Required: s.Required}
+		case *hcldec.BlockObjectSpec:
+			o = OptionDescription{Name: s.TypeName, Type: "block_object", Options: describeNested(s.Nested)}
+		default:
+			continue
+		}
+		res = append(res, o)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func describeNested(spec hcldec.Spec) []OptionDescription {
+	if obj, ok := spec.(hcldec.ObjectSpec); ok {
+		return describeSpec(obj)
+	}
+	return nil
+}
+
+func describeType(t cty.Type) string {
+	if t == cty.NilType {
+		return ""
+	}
+	return typeexpr.TypeString(t)
+}
+
+// documentOptions documents options with the partials of docs of the struct
+// t, descending in the squashed fields and in the structs of the blocks.
+func documentOptions(options []OptionDescription, t reflect.Type, docs fs.FS) {
+	t = structType(t)
+	if t == nil {
+		return
+	}
+	fields := structDocs(docs, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if opts == "squash" || (f.Anonymous && name == "") {
+			documentOptions(options, f.Type, docs)
+			continue
+		}
+		for i := range options {
+			o := &options[i]
+			if o.Name != name {
+				continue
+			}
+			if doc, ok := fields[name]; ok {
+				o.Doc = doc.doc
+				o.Required = o.Required || doc.required
+			}
+			documentOptions(o.Options, f.Type, docs)
+		}
+	}
+}
+
+// fieldDoc is the documentation of a field in a partial generated by
+// packer-sdc struct-markdown.
+type fieldDoc struct {
+	doc      string
+	required bool
+}
+
+// structDocs returns the documentation of the fields of the struct t, by
+// name, read from its partials in docs, the "<Struct>-required.mdx" and
+// "<Struct>-not-required.mdx" files of the directory of its package.
+//
+// As packer-sdc generates the partials in the directories of the packages
+// relative to the root of the module, the directory of the package is the
+// longest of the directories of docs its import path ends with.
+func structDocs(docs fs.FS, t reflect.Type) map[string]fieldDoc {
+	res := map[string]fieldDoc{}
+	if t.Name() == "" {
+		return res
+	}
+	dir, pkg := "", t.PkgPath()
+	fs.WalkDir(docs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && len(p) > len(dir) && (p == pkg || strings.HasSuffix(pkg, "/"+p)) {
+			dir = p
+		}
+		return nil
+	})
+	if dir == "" {
+		return res
+	}
+	for _, required := range []bool{true, false} {
+		suffix := "-not-required.mdx"
+		if required {
+			suffix = "-required.mdx"
+		}
+		b, err := fs.ReadFile(docs, path.Join(dir, t.Name()+suffix))
+		if err != nil {
+			continue
+		}
+		for name, doc := range parsePartial(string(b)) {
+			res[name] = fieldDoc{doc: doc, required: required}
+		}
+	}
+	return res
+}
+
+// partialField matches the first line of the documentation of a field in a
+// partial: "- `name` (type) - doc".
+var partialField = regexp.MustCompile("^- `([^`]+)` \\(.*?\\) - (.*)$")
+
+// parsePartial returns the documentation of the fields of a partial, by
+// name. The lines following the first line of a field, indented, are part of
+// its documentation.
+func parsePartial(partial string) map[string]string {
+	res := map[string]string{}
+	var name string
+	var doc []string
+	end := func() {
+		if name != "" {
+			res[name] = strings.TrimSpace(strings.Join(doc, "\n"))
+		}
+		name, doc = "", nil
+	}
+	for _, line := range strings.Split(partial, "\n") {
+		if m := partialField.FindStringSubmatch(line); m != nil {
+			end()
+			name, doc = m[1], []string{m[2]}
+			continue
+		}
+		if strings.HasPrefix(line, "<!--") {
+			end()
+			continue
+		}
+		if name != "" {
+			doc = append(doc, strings.TrimPrefix(line, "  "))
+		}
+	}
+	end()
+	return res
+}
+
+// structType returns the struct of t, looking through its pointers, slices
+// and maps, or nil if it's not a struct.
+func structType(t reflect.Type) reflect.Type {
+	for t != nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			return t
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
+)
+
+// Handshake is the line a plugin outputs to tell Packer how to connect to
+// it:
+//
+//	APIVersionMajor|APIVersionMinor|network|address[|protocol[|features[|versions[|cert]]]]
+//
+// The features are the features of the protocol negotiated with Packer,
+// separated by commas. The versions are the ones of the plugin, like
+// "sdk=0.3.3,plugin=1.0.0,component=1.0.0", output when
+// rpc.FeatureVersions is negotiated. The cert is the certificate of the
+// plugin, output when Packer asks for TLS, see ClientCertEnvKey. The
+// protocol, the features, the versions and the cert are only output when
+// needed, so that the handshake is understood by older versions of Packer.
+type Handshake struct {
+	APIVersionMajor string
+	APIVersionMinor string
+	Network         string
+	Address         string
+	Protocol        string
+
+	// Features is nil when Packer didn't list the features it supports,
+	// which older versions of Packer don't.
+	Features []string
+
+	// SDKVersion, PluginVersion and ComponentVersion are the versions of
+	// the SDK the plugin is built with, of the plugin, and of the component
+	// it serves. They're empty when unknown, the handshakes of older
+	// versions of the SDK have none. See VersionRequirements.
+	SDKVersion       string
+	PluginVersion    string
+	ComponentVersion string
+
+	// ServerCert is the certificate the plugin serves TLS with, encoded in
+	// base64 DER. It is empty when the connection isn't encrypted.
+	ServerCert string
+}
+
+// newHandshake returns the handshake of this version of the SDK for
+// protocol.
+func newHandshake(protocol string) Handshake {
+	return Handshake{
+		APIVersionMajor: APIVersionMajor,
+		APIVersionMinor: APIVersionMinor,
+		Protocol:        protocol,
+		SDKVersion:      pluginVersion.SDKVersion.String(),
+	}
+}
+
+// versions returns the versions part of the handshake.
+func (h Handshake) versions() string {
+	var res []string
+	for _, v := range []struct{ key, version string }{
+		{"sdk", h.SDKVersion},
+		{"plugin", h.PluginVersion},
+		{"component", h.ComponentVersion},
+	} {
+		if v.version != "" {
+			res = append(res, v.key+"="+v.version)
+		}
+	}
+	return strings.Join(res, ",")
+}
+
+func (h Handshake) String() string {
+	parts := []string{h.APIVersionMajor, h.APIVersionMinor, h.Network, h.Address}
+	protocol := h.Protocol
+	if protocol == "" {
+		protocol = ProtocolNetRPC
+	}
+	versions := h.versions()
+	// The optional parts are output up to the last one needed.
+	optional := []string{protocol, strings.Join(h.Features, ","), versions, h.ServerCert}
+	switch {
+	case h.ServerCert != "":
+	case versions != "":
+		optional = optional[:3]
+	case h.Features != nil:
+		optional = optional[:2]
+	case protocol != ProtocolNetRPC:
+		optional = optional[:1]
+	default:
+		optional = nil
+	}
+	return strings.Join(append(parts, optional...), "|")
+}
+
+// ParseHandshake parses the handshake line output by a plugin. The
+// Protocol of the returned Handshake is ProtocolNetRPC when the line has
+// none, and its Features are empty when the line has none: the plugin uses
+// none of the optional features then.
+func ParseHandshake(line string) (Handshake, error) {
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) < 4 || len(parts) > 8 {
+		return Handshake{}, fmt.Errorf("Unrecognized plugin handshake: %q", line)
+	}
+	h := Handshake{
+		APIVersionMajor: parts[0],
+		APIVersionMinor: parts[1],
+		Network:         parts[2],
+		Address:         parts[3],
+		Protocol:        ProtocolNetRPC,
+		Features:        []string{},
+	}
+	if len(parts) >= 5 {
+		h.Protocol = parts[4]
+	}
+	if len(parts) >= 6 {
+		h.Features = splitList(parts[5])
+	}
+	if len(parts) >= 7 {
+		for _, v := range splitList(parts[6]) {
+			key, version, _ := strings.Cut(v, "=")
+			// Unknown versions are ignored, for the ones of future versions
+			// of the SDK.
+			switch key {
+			case "sdk":
+				h.SDKVersion = version
+			case "plugin":
+				h.PluginVersion = version
+			case "component":
+				h.ComponentVersion = version
+			}
+		}
+	}
+	if len(parts) == 8 {
+		h.ServerCert = parts[7]
+	}
+	switch h.Protocol {
+	case ProtocolNetRPC, ProtocolGRPC:
+	default:
+		return Handshake{}, fmt.Errorf("Unknown plugin protocol %q", h.Protocol)
+	}
+	return h, nil
+}
+
+// splitList returns the elements of the comma-separated list s.
+func splitList(s string) []string {
+	res := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
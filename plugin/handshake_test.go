@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
+)
+
+func TestHandshake(t *testing.T) {
+	cases := []struct {
+		line     string
+		expected Handshake
+		err      bool
+	}{
+		{
+			line: "5|0|unix|/tmp/packer-plugin123\n",
+			expected: Handshake{
+				APIVersionMajor: "5", APIVersionMinor: "0", Network: "unix", Address: "/tmp/packer-plugin123",
+				Protocol: ProtocolNetRPC, Features: []string{},
+			},
+		},
+		{
+			line: "5|0|tcp|127.0.0.1:10000|grpc",
+			expected: Handshake{
+				APIVersionMajor: "5", APIVersionMinor: "0", Network: "tcp", Address: "127.0.0.1:10000",
+				Protocol: ProtocolGRPC, Features: []string{},
+			},
+		},
+		{
+			line: "5|0|tcp|127.0.0.1:10000|netrpc|ui-log,ui-events",
+			expected: Handshake{
+				APIVersionMajor: "5", APIVersionMinor: "0", Network: "tcp", Address: "127.0.0.1:10000",
+				Protocol: ProtocolNetRPC, Features: []string{"ui-log", "ui-events"},
+			},
+		},
+		{
+			line: "5|1|tcp|127.0.0.1:10000|grpc|versions|sdk=0.4.0,plugin=1.2.0,component=1.0.0,future=1",
+			expected: Handshake{
+				APIVersionMajor: "5", APIVersionMinor: "1", Network: "tcp", Address: "127.0.0.1:10000",
+				Protocol: ProtocolGRPC, Features: []string{"versions"},
+				SDKVersion: "0.4.0", PluginVersion: "1.2.0", ComponentVersion: "1.0.0",
+			},
+		},
+		{
+			line: "5|1|unix|/tmp/packer-plugin123|netrpc|||MIIBhTCCASug",
+			expected: Handshake{
+				APIVersionMajor: "5", APIVersionMinor: "1", Network: "unix", Address: "/tmp/packer-plugin123",
+				Protocol: ProtocolNetRPC, Features: []string{}, ServerCert: "MIIBhTCCASug",
+			},
+		},
+		{line: "5|0|tcp|127.0.0.1:10000|carrier-pigeon", err: true},
+		{line: "5|0|tcp", err: true},
+	}
+	for _, tc := range cases {
+		h, err := ParseHandshake(tc.line)
+		if (err != nil) != tc.err {
+			t.Fatalf("%q: unexpected error: %v", tc.line, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(h, tc.expected) {
+			t.Fatalf("%q: bad handshake: %#v", tc.line, h)
+		}
+		if parsed, _ := ParseHandshake(h.String()); !reflect.DeepEqual(parsed, h) {
+			t.Fatalf("%q: bad round trip: %q", tc.line, h.String())
+		}
+	}
+
+	// The handshake without features stays understood by older versions of
+	// Packer.
+	h := Handshake{APIVersionMajor: "5", APIVersionMinor: "0", Network: "unix", Address: "/tmp/p"}
+	if h.String() != "5|0|unix|/tmp/p" {
+		t.Fatalf("bad handshake: %q", h.String())
+	}
+	h.Features = []string{}
+	if h.String() != "5|0|unix|/tmp/p|netrpc|" {
+		t.Fatalf("bad handshake: %q", h.String())
+	}
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	cases := map[string]string{
+		"":            ProtocolNetRPC,
+		"netrpc":      ProtocolNetRPC,
+		"netrpc,grpc": ProtocolGRPC,
+		"grpc":        ProtocolGRPC,
+	}
+	for env, expected := range cases {
+		t.Setenv(ProtocolsEnvKey, env)
+		if p := negotiateProtocol(); p != expected {
+			t.Fatalf("%q: got %q, expected %q", env, p, expected)
+		}
+	}
+}
+
+func TestNegotiateFeatures(t *testing.T) {
+	features, listed := negotiateFeatures()
+	if listed || len(features) != 0 {
+		t.Fatalf("older versions of Packer support no features, got %v", features)
+	}
+
+	t.Setenv(FeaturesEnvKey, "ui-log, future-feature,"+packrpc.FeatureUiEvents)
+	features, listed = negotiateFeatures()
+	if !listed || !reflect.DeepEqual(features, []string{packrpc.FeatureUiEvents, packrpc.FeatureUiLog}) {
+		t.Fatalf("bad features: %v", features)
+	}
+}
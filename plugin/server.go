@@ -11,8 +11,10 @@
 package plugin
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"net"
@@ -33,6 +35,11 @@ import (
 // be checked by the plugin safely to take action.
 var Interrupts int32 = 0
 
+// ShutdownTimeout is how long a plugin started by a Set waits for its calls
+// in flight, like the cleanups of a build, when it shuts down: once Packer
+// closed the connection, or when the plugin receives a SIGTERM.
+var ShutdownTimeout = 1 * time.Minute
+
 const MagicCookieKey = "PACKER_PLUGIN_MAGIC_COOKIE"
 const MagicCookieValue = "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d6991ca9872b2"
 
@@ -49,22 +56,129 @@ const (
 	//   |-------------------------|--------------------------------|-------------|-----------------|
 	//   | 4                       | yes                            | No          | No              |
 	//   | 5.0                     | yes                            | yes         | No              |
-	//   | 5.1                     | yes                            | yes         | yes             |
+	//   | 5.1 (functions)         | yes                            | yes         | yes             |
 	//   | 6.0                     | no (deprecated, so major bump) | yes         | yes             |
 	//
 	// Api version 4 did not have the notion of a minor version, so Packer will
 	// error with a weird error message.
 	APIVersionMajor, APIVersionMinor = "5", "0"
+
+	// APIVersionMinorFunctions is the minor API version of the plugins
+	// registering functions, which older versions of Packer can't read. The
+	// other plugins keep APIVersionMinor.
+	APIVersionMinorFunctions = "1"
 )
 
 var ErrManuallyStartedPlugin = errors.New(
 	"Please do not execute plugins directly. Packer will execute these for you.")
 
+const (
+	// ProtocolsEnvKey is the environment variable in which Packer lists the
+	// protocols it speaks, separated by commas. Packer only speaks
+	// ProtocolNetRPC when it is unset.
+	ProtocolsEnvKey = "PACKER_PLUGIN_PROTOCOLS"
+
+	// FeaturesEnvKey is the environment variable in which Packer lists the
+	// features of the protocol it supports, the rpc.SupportedFeatures of its
+	// SDK, separated by commas. Older versions of Packer don't set it, and
+	// support none of the features. Packer then uses the features of the
+	// handshake, see Handshake.
+	FeaturesEnvKey = "PACKER_PLUGIN_FEATURES"
+
+	// TransportEnvKey is the environment variable in which Packer asks the
+	// plugins to use another transport than the default one, a local TCP or
+	// Unix socket.
+	TransportEnvKey = "PACKER_PLUGIN_TRANSPORT"
+	// TransportStdio is the transport over the standard input and output of
+	// the plugin, for the environments where opening listeners is
+	// prohibited. The plugin outputs its handshake, with the "stdio" network,
+	// and then serves Packer over stdin and stdout. Packer must keep reading
+	// stdout with the reader it read the handshake with, which may have
+	// buffered the start of the connection.
+	TransportStdio = "stdio"
+
+	// ClientCertEnvKey is the environment variable in which Packer sets its
+	// certificate, PEM-encoded, to ask the plugins to serve mutual TLS on
+	// their listener: the plugin generates a certificate, outputs it in its
+	// handshake, and only accepts the connection of the certificate of
+	// Packer. See GenerateCertificate and Handshake.ClientTLSConfig.
+	ClientCertEnvKey = "PACKER_PLUGIN_CLIENT_CERT"
+
+	// ProtocolNetRPC is the net/rpc protocol, the one of rpc.PluginServer.
+	ProtocolNetRPC = "netrpc"
+	// ProtocolGRPC is the gRPC protocol, the one of rpc.GRPCServer, defined
+	// in rpc/proto/plugin.proto.
+	ProtocolGRPC = "grpc"
+)
+
+// negotiateProtocol returns the protocol used to serve Packer: gRPC when
+// Packer speaks it, net/rpc otherwise.
+func negotiateProtocol() string {
+	for _, p := range splitList(os.Getenv(ProtocolsEnvKey)) {
+		if p == ProtocolGRPC {
+			return ProtocolGRPC
+		}
+	}
+	return ProtocolNetRPC
+}
+
+// negotiateFeatures returns the features supported by both Packer and this
+// plugin, and whether Packer listed the ones it supports.
+func negotiateFeatures() ([]string, bool) {
+	env, ok := os.LookupEnv(FeaturesEnvKey)
+	if !ok {
+		return []string{}, false
+	}
+	return packrpc.NegotiateFeatures(splitList(env)), true
+}
+
 // Server waits for a connection to this plugin and returns a Packer
 // RPC server that you can use to register components and serve them.
 func Server() (*packrpc.PluginServer, error) {
+	return newServer(newHandshake(ProtocolNetRPC))
+}
+
+// newServer is Server, outputting handshake.
+func newServer(handshake Handshake) (*packrpc.PluginServer, error) {
+	conn, features, err := accept(handshake)
+	if err != nil {
+		return nil, err
+	}
+	server, err := packrpc.NewServer(conn)
+	if err != nil {
+		return nil, err
+	}
+	server.SetFeatures(features)
+	return server, nil
+}
+
+// GRPCServer is like Server, but returns a server speaking the gRPC
+// protocol. Packer must list ProtocolGRPC in ProtocolsEnvKey.
+func GRPCServer() (*packrpc.GRPCServer, error) {
+	return newGRPCServer(newHandshake(ProtocolGRPC))
+}
+
+// newGRPCServer is GRPCServer, outputting handshake.
+func newGRPCServer(handshake Handshake) (*packrpc.GRPCServer, error) {
+	conn, features, err := accept(handshake)
+	if err != nil {
+		return nil, err
+	}
+	server, err := packrpc.NewGRPCServer(conn)
+	if err != nil {
+		return nil, err
+	}
+	server.SetFeatures(features)
+	return server, nil
+}
+
+// accept outputs handshake, completed with the address of the plugin and
+// the features negotiated with Packer, telling Packer how to connect to this
+// plugin, and waits for its connection. It returns the features negotiated,
+// and compresses the connection when rpc.FeatureCompression is one of them.
+func accept(handshake Handshake) (io.ReadWriteCloser, []string, error) {
 	if os.Getenv(MagicCookieKey) != MagicCookieValue {
-		return nil, ErrManuallyStartedPlugin
+		return nil, nil, ErrManuallyStartedPlugin
 	}
 
 	// If there is no explicit number of Go threads to use, then set it
@@ -72,6 +186,62 @@ func Server() (*packrpc.PluginServer, error) {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
 
+	features, listed := negotiateFeatures()
+	if listed {
+		handshake.Features = features
+	}
+	if !hasFeature(features, packrpc.FeatureVersions) {
+		// Packer doesn't understand the versions part of the handshake.
+		handshake.SDKVersion = ""
+		handshake.PluginVersion = ""
+		handshake.ComponentVersion = ""
+	}
+	log.Printf("Plugin protocol features: %v", features)
+
+	var conn io.ReadWriteCloser
+	var err error
+	if os.Getenv(TransportEnvKey) == TransportStdio {
+		conn = acceptStdio(handshake)
+	} else {
+		conn, err = acceptListener(handshake)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Eat the interrupts
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		var count int32 = 0
+		for {
+			<-ch
+			newCount := atomic.AddInt32(&count, 1)
+			log.Printf("Received interrupt signal (count: %d). Ignoring.", newCount)
+		}
+	}()
+
+	// Serve a single connection
+	log.Printf("Serving a plugin connection over %s...", handshake.Protocol)
+	if hasFeature(features, packrpc.FeatureCompression) {
+		log.Println("Compressing the plugin connection")
+		return packrpc.NewCompressedConn(conn), features, nil
+	}
+	return conn, features, nil
+}
+
+func hasFeature(features []string, feature string) bool {
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptListener outputs the handshake with the address of a new listener,
+// and waits for the connection of Packer to it.
+func acceptListener(handshake Handshake) (net.Conn, error) {
 	listener, err := serverListener()
 	if err != nil {
 		return nil, err
@@ -81,11 +251,16 @@ func Server() (*packrpc.PluginServer, error) {
 	// Output the address to stdout
 	log.Printf("Plugin address: %s %s\n",
 		listener.Addr().Network(), listener.Addr().String())
-	fmt.Printf("%s|%s|%s|%s\n",
-		APIVersionMajor,
-		APIVersionMinor,
-		listener.Addr().Network(),
-		listener.Addr().String())
+	handshake.Network = listener.Addr().Network()
+	handshake.Address = listener.Addr().String()
+	var tlsConfig *tls.Config
+	if clientCert := os.Getenv(ClientCertEnvKey); clientCert != "" {
+		tlsConfig, handshake.ServerCert, err = serverTLSConfig([]byte(clientCert))
+		if err != nil {
+			return nil, err
+		}
+	}
+	fmt.Println(handshake)
 	os.Stdout.Sync()
 
 	// Accept a connection
@@ -95,22 +270,32 @@ func Server() (*packrpc.PluginServer, error) {
 		log.Printf("Error accepting connection: %s\n", err.Error())
 		return nil, err
 	}
-
-	// Eat the interrupts
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		var count int32 = 0
-		for {
-			<-ch
-			newCount := atomic.AddInt32(&count, 1)
-			log.Printf("Received interrupt signal (count: %d). Ignoring.", newCount)
+	if tlsConfig != nil {
+		log.Println("Serving TLS")
+		tlsConn := tls.Server(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with Packer: %w", err)
 		}
-	}()
+		return tlsConn, nil
+	}
+	return conn, nil
+}
 
-	// Serve a single connection
-	log.Println("Serving a plugin connection...")
-	return packrpc.NewServer(conn)
+// acceptStdio outputs the handshake, and returns the connection over stdin
+// and stdout. The standard output of the plugin is then redirected to its
+// standard error, so that what the plugin prints doesn't break the
+// connection.
+func acceptStdio(handshake Handshake) io.ReadWriteCloser {
+	log.Println("Plugin address: stdio")
+	handshake.Network = TransportStdio
+	handshake.Address = "-"
+	fmt.Println(handshake)
+	os.Stdout.Sync()
+
+	conn := NewStdioConn(os.Stdin, os.Stdout)
+	os.Stdout = os.Stderr
+	return conn
 }
 
 func serverListener() (net.Listener, error) {
@@ -7,10 +7,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"os/signal"
 	"sort"
+	"syscall"
+	"time"
 
+	"github.com/hashicorp/hcl/v2/hcldec"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
 )
@@ -31,10 +36,12 @@ type Set struct {
 	version        string
 	sdkVersion     string
 	apiVersion     string
+	docs           fs.FS
 	Builders       map[string]packersdk.Builder
 	PostProcessors map[string]packersdk.PostProcessor
 	Provisioners   map[string]packersdk.Provisioner
 	Datasources    map[string]packersdk.Datasource
+	Functions      map[string]packersdk.Function
 }
 
 // SetDescription describes a Set.
@@ -46,6 +53,10 @@ type SetDescription struct {
 	PostProcessors []string `json:"post_processors"`
 	Provisioners   []string `json:"provisioners"`
 	Datasources    []string `json:"datasources"`
+	Functions      []string `json:"functions"`
+	// Components describes the configuration of the components, by type
+	// and by name, like Components["builder"]["example"].
+	Components map[string]map[string]ComponentDescription `json:"components"`
 }
 
 ////
@@ -60,6 +71,7 @@ func NewSet() *Set {
 		PostProcessors: map[string]packersdk.PostProcessor{},
 		Provisioners:   map[string]packersdk.Provisioner{},
 		Datasources:    map[string]packersdk.Datasource{},
+		Functions:      map[string]packersdk.Function{},
 	}
 }
 
@@ -67,6 +79,20 @@ func (i *Set) SetVersion(version *pluginVersion.PluginVersion) {
 	i.version = version.String()
 }
 
+// SetDocs sets the partials documenting the options of the components in
+// the describe output, see ConfigDocumenter. docs is the docs-partials
+// directory generated by packer-sdc struct-markdown, usually embedded in the
+// plugin:
+//
+//	//go:embed docs-partials
+//	var docs embed.FS
+//
+//	sub, _ := fs.Sub(docs, "docs-partials")
+//	pps.SetDocs(sub)
+func (i *Set) SetDocs(docs fs.FS) {
+	i.docs = docs
+}
+
 func (i *Set) RegisterBuilder(name string, builder packersdk.Builder) {
 	if _, found := i.Builders[name]; found {
 		panic(fmt.Errorf("registering duplicate %s builder", name))
@@ -95,10 +121,21 @@ func (i *Set) RegisterDatasource(name string, datasource packersdk.Datasource) {
 	i.Datasources[name] = datasource
 }
 
+// RegisterFunction registers a function that Packer makes available to the
+// templates. Its name in the templates is the name of the plugin, followed by
+// name, unless name is DEFAULT_NAME.
+func (i *Set) RegisterFunction(name string, function packersdk.Function) {
+	if _, found := i.Functions[name]; found {
+		panic(fmt.Errorf("registering duplicate %s function", name))
+	}
+	i.Functions[name] = function
+}
+
 // Run takes the os Args and runs a packer plugin command from it.
 //  * "describe" command makes the plugin set describe itself.
 //  * "start builder builder-name" starts the builder "builder-name"
 //  * "start post-processor example" starts the post-processor "example"
+//  * "start function example" starts the function "example"
 func (i *Set) Run() error {
 	args := os.Args[1:]
 	return i.RunCommand(args...)
@@ -123,12 +160,42 @@ func (i *Set) RunCommand(args ...string) error {
 	}
 }
 
+// componentServer is implemented by both the net/rpc and the gRPC servers.
+type componentServer interface {
+	RegisterBuilder(packersdk.Builder) error
+	RegisterPostProcessor(packersdk.PostProcessor) error
+	RegisterProvisioner(packersdk.Provisioner) error
+	RegisterDatasource(packersdk.Datasource) error
+	RegisterFunction(packersdk.Function) error
+	StreamLogs(component string)
+	Shutdown(timeout time.Duration) error
+	Serve()
+}
+
 func (i *Set) start(kind, name string) error {
-	server, err := Server()
+	handshake := newHandshake(negotiateProtocol())
+	handshake.APIVersionMinor = i.apiVersionMinor()
+	handshake.SDKVersion = i.sdkVersion
+	handshake.PluginVersion = i.version
+	handshake.ComponentVersion = i.componentVersion(kind, name)
+
+	var server componentServer
+	var err error
+	if handshake.Protocol == ProtocolGRPC {
+		server, err = newGRPCServer(handshake)
+	} else {
+		server, err = newServer(handshake)
+	}
 	if err != nil {
 		return err
 	}
 
+	component := kind
+	if name != DEFAULT_NAME {
+		component += " " + name
+	}
+	server.StreamLogs(component)
+
 	log.Printf("[TRACE] starting %s %s", kind, name)
 
 	switch kind {
@@ -140,29 +207,59 @@ func (i *Set) start(kind, name string) error {
 		err = server.RegisterProvisioner(i.Provisioners[name])
 	case "datasource":
 		err = server.RegisterDatasource(i.Datasources[name])
+	case "function":
+		err = server.RegisterFunction(i.Functions[name])
 	default:
 		err = fmt.Errorf("Unknown plugin type: %s", kind)
 	}
 	if err != nil {
 		return err
 	}
+
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGTERM)
+	go func() {
+		<-terminate
+		log.Printf("[INFO] Received a SIGTERM, shutting down %s", component)
+		if err := server.Shutdown(ShutdownTimeout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+
 	server.Serve()
-	return nil
+	// Packer closed the connection, the calls in flight are cancelled and
+	// their cleanups waited for before the plugin exits.
+	return server.Shutdown(ShutdownTimeout)
 }
 
 ////
 // Describe
 ////
 
+// apiVersionMinor returns the minor API version the set is served with.
+func (i *Set) apiVersionMinor() string {
+	if len(i.Functions) > 0 {
+		return APIVersionMinorFunctions
+	}
+	return APIVersionMinor
+}
+
 func (i *Set) description() SetDescription {
+	apiVersion := i.apiVersion
+	if len(i.Functions) > 0 {
+		apiVersion = "x" + APIVersionMajor + "." + APIVersionMinorFunctions
+	}
 	return SetDescription{
 		Version:        i.version,
 		SDKVersion:     i.sdkVersion,
-		APIVersion:     i.apiVersion,
+		APIVersion:     apiVersion,
 		Builders:       i.buildersDescription(),
 		PostProcessors: i.postProcessorsDescription(),
 		Provisioners:   i.provisionersDescription(),
 		Datasources:    i.datasourceDescription(),
+		Functions:      i.functionsDescription(),
+		Components:     i.componentsDescription(),
 	}
 }
 
@@ -205,3 +302,46 @@ func (i *Set) datasourceDescription() []string {
 	sort.Strings(out)
 	return out
 }
+
+func (i *Set) functionsDescription() []string {
+	out := []string{}
+	for key := range i.Functions {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (i *Set) componentsDescription() map[string]map[string]ComponentDescription {
+	out := map[string]map[string]ComponentDescription{
+		"builder":        {},
+		"post-processor": {},
+		"provisioner":    {},
+		"datasource":     {},
+		"function":       {},
+	}
+	for key, b := range i.Builders {
+		out["builder"][key] = i.describeComponent(b)
+	}
+	for key, p := range i.PostProcessors {
+		out["post-processor"][key] = i.describeComponent(p)
+	}
+	for key, p := range i.Provisioners {
+		out["provisioner"][key] = i.describeComponent(p)
+	}
+	for key, d := range i.Datasources {
+		out["datasource"][key] = i.describeComponent(d)
+	}
+	for key, f := range i.Functions {
+		res := describeFunction(f)
+		res.Version = i.versionOf(f)
+		out["function"][key] = res
+	}
+	return out
+}
+
+func (i *Set) describeComponent(c interface{ ConfigSpec() hcldec.ObjectSpec }) ComponentDescription {
+	res := describeComponent(c, i.docs)
+	res.Version = i.versionOf(c)
+	return res
+}
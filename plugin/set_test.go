@@ -5,36 +5,104 @@ package plugin
 
 import (
 	"testing"
+	"testing/fstest"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hcldec"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type MockBuilder struct {
 	packersdk.Builder
 }
 
+type MockBuilderConfig struct {
+	Region string           `mapstructure:"region" required:"true"`
+	Disks  []MockDiskConfig `mapstructure:"disk"`
+}
+
+type MockDiskConfig struct {
+	Size int `mapstructure:"size"`
+}
+
+// mockDocs are the partials packer-sdc struct-markdown generates for the
+// configuration of MockBuilder.
+var mockDocs = fstest.MapFS{
+	"plugin/MockBuilderConfig-required.mdx": {Data: []byte(`<!-- Code generated from the comments of the MockBuilderConfig struct in plugin/set_test.go; DO NOT EDIT MANUALLY -->
+
+- ` + "`region`" + ` (string) - The region to build in.
+
+<!-- End of code generated from the comments of the MockBuilderConfig struct in plugin/set_test.go; -->
+`)},
+	"plugin/MockDiskConfig-not-required.mdx": {Data: []byte(`<!-- Code generated from the comments of the MockDiskConfig struct in plugin/set_test.go; DO NOT EDIT MANUALLY -->
+
+- ` + "`size`" + ` (int) - The size of the disk, in GB.
+  Defaults to 10.
+
+<!-- End of code generated from the comments of the MockDiskConfig struct in plugin/set_test.go; -->
+`)},
+}
+
+func (*MockBuilder) ConfigSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		"region": &hcldec.AttrSpec{Name: "region", Type: cty.String},
+		"disk": &hcldec.BlockListSpec{TypeName: "disk", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		}},
+	}
+}
+
+func (*MockBuilder) ConfigStruct() interface{} { return new(MockBuilderConfig) }
+
 var _ packersdk.Builder = new(MockBuilder)
 
 type MockProvisioner struct {
 	packersdk.Provisioner
 }
 
+func (*MockProvisioner) ConfigSpec() hcldec.ObjectSpec { return hcldec.ObjectSpec{} }
+
 var _ packersdk.Provisioner = new(MockProvisioner)
 
 type MockPostProcessor struct {
 	packersdk.PostProcessor
 }
 
+func (*MockPostProcessor) ConfigSpec() hcldec.ObjectSpec { return hcldec.ObjectSpec{} }
+
 var _ packersdk.PostProcessor = new(MockPostProcessor)
 
 type MockDatasource struct {
 	packersdk.Datasource
 }
 
+func (*MockDatasource) ConfigSpec() hcldec.ObjectSpec { return hcldec.ObjectSpec{} }
+
+func (*MockDatasource) OutputSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{"id": &hcldec.AttrSpec{Name: "id", Type: cty.List(cty.String)}}
+}
+
+func (*MockDatasource) ComponentVersion() string { return "2.0.0" }
+
 var _ packersdk.Datasource = new(MockDatasource)
 
+type MockFunction struct {
+	packersdk.Function
+}
+
+func (*MockFunction) Signature() packersdk.FunctionSignature {
+	return packersdk.FunctionSignature{
+		Description: "Joins strings.",
+		Params:      []packersdk.FunctionParameter{{Name: "sep", Type: cty.String}},
+		VarParam:    &packersdk.FunctionParameter{Name: "elems", Type: cty.String, AllowNull: true},
+		ReturnType:  cty.String,
+	}
+}
+
+var _ packersdk.Function = new(MockFunction)
+
 func TestSet(t *testing.T) {
 	set := NewSet()
 	set.RegisterBuilder("example-2", new(MockBuilder))
@@ -45,8 +113,10 @@ func TestSet(t *testing.T) {
 	set.RegisterProvisioner("example-2", new(MockProvisioner))
 	set.RegisterDatasource("example", new(MockDatasource))
 	set.RegisterDatasource("example-2", new(MockDatasource))
+	set.RegisterFunction("example", new(MockFunction))
 	set.SetVersion(pluginVersion.InitializePluginVersion(
 		"1.1.1", ""))
+	set.SetDocs(mockDocs)
 
 	outputDesc := set.description()
 
@@ -54,17 +124,59 @@ func TestSet(t *testing.T) {
 	if diff := cmp.Diff(SetDescription{
 		Version:        "1.1.1",
 		SDKVersion:     sdkVersion.String(),
-		APIVersion:     "x" + APIVersionMajor + "." + APIVersionMinor,
+		APIVersion:     "x" + APIVersionMajor + "." + APIVersionMinorFunctions,
 		Builders:       []string{"example", "example-2"},
 		PostProcessors: []string{"example", "example-2"},
 		Provisioners:   []string{"example", "example-2"},
 		Datasources:    []string{"example", "example-2"},
+		Functions:      []string{"example"},
+		Components:     outputDesc.Components,
 	}, outputDesc); diff != "" {
 		t.Fatalf("Unexpected description: %s", diff)
 	}
 
+	builder := ComponentDescription{Version: "1.1.1", Options: []OptionDescription{
+		{Name: "disk", Type: "block_list", Options: []OptionDescription{
+			{Name: "size", Type: "number", Doc: "The size of the disk, in GB.\nDefaults to 10."},
+		}},
+		{Name: "region", Type: "string", Required: true, Doc: "The region to build in."},
+	}}
+	if diff := cmp.Diff(builder, outputDesc.Components["builder"]["example"]); diff != "" {
+		t.Fatalf("Unexpected builder description: %s", diff)
+	}
+	datasource := ComponentDescription{
+		Version: "2.0.0",
+		Options: []OptionDescription{},
+		Outputs: []OptionDescription{{Name: "id", Type: "list(string)"}},
+	}
+	if diff := cmp.Diff(datasource, outputDesc.Components["datasource"]["example-2"]); diff != "" {
+		t.Fatalf("Unexpected datasource description: %s", diff)
+	}
+	function := ComponentDescription{
+		Version:    "1.1.1",
+		Options:    []OptionDescription{{Name: "sep", Type: "string", Required: true}},
+		Doc:        "Joins strings.",
+		VarParam:   &OptionDescription{Name: "elems", Type: "string"},
+		ReturnType: "string",
+	}
+	if diff := cmp.Diff(function, outputDesc.Components["function"]["example"]); diff != "" {
+		t.Fatalf("Unexpected function description: %s", diff)
+	}
+
 	err := set.RunCommand("start", "builder", "example")
 	if diff := cmp.Diff(err.Error(), ErrManuallyStartedPlugin.Error()); diff != "" {
 		t.Fatalf("Unexpected error: %s", diff)
 	}
 }
+
+func TestSet_apiVersion(t *testing.T) {
+	set := NewSet()
+	set.RegisterBuilder("example", new(MockBuilder))
+	if v := set.description().APIVersion; v != "x5.0" {
+		t.Fatalf("bad API version without functions: %s", v)
+	}
+	set.RegisterFunction("example", new(MockFunction))
+	if v := set.description().APIVersion; v != "x5.1" {
+		t.Fatalf("bad API version with functions: %s", v)
+	}
+}
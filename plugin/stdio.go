@@ -0,0 +1,32 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import "io"
+
+// stdioConn is a connection over a pair of pipes, like the standard input
+// and output of a plugin.
+type stdioConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// NewStdioConn returns the connection reading from r and writing to w, used
+// with TransportStdio. The plugin uses its stdin and stdout, and Packer the
+// stdout of the plugin process, or the reader it read the handshake with,
+// and its stdin. Closing the connection closes w, and r when it is an
+// io.Closer.
+func NewStdioConn(r io.Reader, w io.WriteCloser) io.ReadWriteCloser {
+	return &stdioConn{Reader: r, WriteCloser: w}
+}
+
+func (c *stdioConn) Close() error {
+	err := c.WriteCloser.Close()
+	if r, ok := c.Reader.(io.Closer); ok {
+		if rerr := r.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
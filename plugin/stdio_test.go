@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
+)
+
+func TestStdioConn(t *testing.T) {
+	// The pipes of the standard input and output of the plugin.
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+
+	handshake := Handshake{APIVersionMajor: "5", APIVersionMinor: "1", Network: TransportStdio, Address: "-"}
+	go fmt.Fprintln(stdoutW, handshake)
+	stdout := bufio.NewReader(stdoutR)
+	line, err := stdout.ReadString('\n')
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if h, err := ParseHandshake(line); err != nil || h.Network != TransportStdio {
+		t.Fatalf("bad handshake: %#v, %v", h, err)
+	}
+
+	server, err := packrpc.NewServer(NewStdioConn(stdinR, stdoutW))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer server.Close()
+	b := &packersdk.MockBuilder{}
+	server.RegisterBuilder(b)
+	go server.Serve()
+
+	client, err := packrpc.NewClient(NewStdioConn(stdout, stdinW))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer client.Close()
+	if _, _, err := client.Builder().Prepare(map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !b.PrepareCalled {
+		t.Fatal("prepare should be called")
+	}
+}
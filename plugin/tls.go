@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// tlsServerName is the name the certificates are valid for, whatever the
+// address of the plugin, as the connections are local.
+const tlsServerName = "localhost"
+
+// certValidity is how long the certificates are valid. They're only checked
+// when connecting, so that it bounds the time Packer can start plugins for,
+// not the one of the builds.
+var certValidity = 30 * 24 * time.Hour
+
+// GenerateCertificate generates a self-signed certificate for the plugin
+// connections, and returns it and its private key PEM-encoded. Packer sets
+// the certificate in ClientCertEnvKey to start the plugins, and
+// authenticates with it.
+func GenerateCertificate() (certPEM, keyPEM []byte, err error) {
+	cert, key, err := generateCertificate()
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// generateCertificate returns a self-signed certificate, DER-encoded, and its
+// private key. The certificate is its own CA, for both ends of a connection.
+func generateCertificate() ([]byte, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   tlsServerName,
+			Organization: []string{"Packer"},
+		},
+		DNSNames:              []string{tlsServerName},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:             now.Add(-30 * time.Second),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// serverTLSConfig generates the certificate of the plugin, and returns the
+// TLS configuration serving it and only accepting the client certificate
+// clientPEM, with the certificate for the handshake.
+func serverTLSConfig(clientPEM []byte) (*tls.Config, string, error) {
+	clients := x509.NewCertPool()
+	if !clients.AppendCertsFromPEM(clientPEM) {
+		return nil, "", errors.New("no valid certificate in " + ClientCertEnvKey)
+	}
+	cert, key, err := generateCertificate()
+	if err != nil {
+		return nil, "", fmt.Errorf("generating the plugin certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return config, base64.StdEncoding.EncodeToString(cert), nil
+}
+
+// ClientTLSConfig returns the TLS configuration Packer connects to the
+// plugin that output h with, authenticating with the certificate certPEM and
+// its key keyPEM, the ones set in ClientCertEnvKey, and only trusting the
+// certificate of the handshake.
+func (h Handshake) ClientTLSConfig(certPEM, keyPEM []byte) (*tls.Config, error) {
+	if h.ServerCert == "" {
+		return nil, errors.New("the plugin handshake has no certificate: the plugin doesn't serve TLS")
+	}
+	der, err := base64.StdEncoding.DecodeString(h.ServerCert)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the plugin certificate: %w", err)
+	}
+	serverCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the plugin certificate: %w", err)
+	}
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	servers := x509.NewCertPool()
+	servers.AddCert(serverCert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      servers,
+		ServerName:   tlsServerName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
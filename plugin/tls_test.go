@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+)
+
+// testTLSHandshake connects a client with clientConfig to a server with
+// serverConfig, returning the error of the server.
+func testTLSHandshake(t *testing.T, serverConfig, clientConfig *tls.Config) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer clientConn.Close()
+	serverConn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer serverConn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		client := tls.Client(clientConn, clientConfig)
+		if err := client.Handshake(); err != nil {
+			errs <- err
+			return
+		}
+		_, err := client.Write([]byte("ping"))
+		errs <- err
+	}()
+
+	server := tls.Server(serverConn, serverConfig)
+	if err := server.Handshake(); err != nil {
+		clientConn.Close()
+		<-errs
+		return err
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(server, b); err != nil || string(b) != "ping" {
+		t.Fatalf("bad read: %q, %v", b, err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("client err: %s", err)
+	}
+	return nil
+}
+
+func TestTLS(t *testing.T) {
+	certPEM, keyPEM, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	serverConfig, serverCert, err := serverTLSConfig(certPEM)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	h, err := ParseHandshake(Handshake{
+		APIVersionMajor: "5", APIVersionMinor: "1", Network: "unix", Address: "/tmp/p",
+		ServerCert: serverCert,
+	}.String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	clientConfig, err := h.ClientTLSConfig(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testTLSHandshake(t, serverConfig, clientConfig); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Another client isn't accepted.
+	otherPEM, otherKeyPEM, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	otherConfig, err := h.ClientTLSConfig(otherPEM, otherKeyPEM)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testTLSHandshake(t, serverConfig, otherConfig); err == nil {
+		t.Fatal("should reject the certificate of another client")
+	}
+
+	if _, err := (Handshake{}).ClientTLSConfig(certPEM, keyPEM); err == nil {
+		t.Fatal("should fail without the certificate of the plugin")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// ComponentVersioner is implemented by the components versioned apart from
+// their plugin. The version of the other components is the one of the
+// plugin, see Set.SetVersion.
+type ComponentVersioner interface {
+	ComponentVersion() string
+}
+
+// componentVersion returns the version of the component kind name.
+func (i *Set) componentVersion(kind, name string) string {
+	var c interface{}
+	switch kind {
+	case "builder":
+		c = i.Builders[name]
+	case "post-processor":
+		c = i.PostProcessors[name]
+	case "provisioner":
+		c = i.Provisioners[name]
+	case "datasource":
+		c = i.Datasources[name]
+	case "function":
+		c = i.Functions[name]
+	}
+	return i.versionOf(c)
+}
+
+func (i *Set) versionOf(c interface{}) string {
+	if v, ok := c.(ComponentVersioner); ok && v.ComponentVersion() != "" {
+		return v.ComponentVersion()
+	}
+	return i.version
+}
+
+// VersionRequirements are the minimum versions of the plugins a version of
+// Packer works with. Checking them against the handshake or the describe
+// output of a plugin makes the incompatible plugins fail fast, with a
+// message telling what to upgrade.
+type VersionRequirements struct {
+	// APIVersion is the minimum API version, like "5.1". The major
+	// versions must match, see APIVersionMajor.
+	APIVersion string
+	// SDKVersion is the minimum version of the SDK the plugins are built
+	// with. The plugins built with older versions of the SDK, not telling
+	// its version, don't meet it.
+	SDKVersion string
+}
+
+// CheckHandshake returns an error when plugin, which output h, doesn't meet
+// the requirements.
+func (r VersionRequirements) CheckHandshake(plugin string, h Handshake) error {
+	return r.check(plugin, h.APIVersionMajor+"."+h.APIVersionMinor, h.SDKVersion)
+}
+
+// CheckDescription returns an error when plugin, described by d, doesn't
+// meet the requirements.
+func (r VersionRequirements) CheckDescription(plugin string, d SetDescription) error {
+	return r.check(plugin, strings.TrimPrefix(d.APIVersion, "x"), d.SDKVersion)
+}
+
+func (r VersionRequirements) check(plugin, apiVersion, sdkVersion string) error {
+	if r.APIVersion != "" {
+		required, err := version.NewVersion(r.APIVersion)
+		if err != nil {
+			return fmt.Errorf("invalid required API version %q: %s", r.APIVersion, err)
+		}
+		v, err := version.NewVersion(apiVersion)
+		if err != nil {
+			return fmt.Errorf("plugin %s has an invalid API version %q: %s", plugin, apiVersion, err)
+		}
+		switch {
+		case v.Segments()[0] < required.Segments()[0]:
+			return fmt.Errorf("plugin %s speaks the plugin API v%s, which is no longer supported by this version of Packer, requiring v%s: upgrade the plugin", plugin, apiVersion, r.APIVersion)
+		case v.Segments()[0] > required.Segments()[0]:
+			return fmt.Errorf("plugin %s speaks the plugin API v%s, which this version of Packer doesn't support, speaking v%s: upgrade Packer, or install an older version of the plugin", plugin, apiVersion, r.APIVersion)
+		case v.LessThan(required):
+			return fmt.Errorf("plugin %s speaks the plugin API v%s, this version of Packer requires v%s or later: upgrade the plugin", plugin, apiVersion, r.APIVersion)
+		}
+	}
+
+	if r.SDKVersion != "" {
+		required, err := version.NewVersion(r.SDKVersion)
+		if err != nil {
+			return fmt.Errorf("invalid required SDK version %q: %s", r.SDKVersion, err)
+		}
+		if sdkVersion == "" {
+			return fmt.Errorf("plugin %s is built with a version of the SDK too old to tell it, this version of Packer requires v%s or later: upgrade the plugin", plugin, r.SDKVersion)
+		}
+		v, err := version.NewVersion(sdkVersion)
+		if err != nil {
+			return fmt.Errorf("plugin %s has an invalid SDK version %q: %s", plugin, sdkVersion, err)
+		}
+		if v.LessThan(required) {
+			return fmt.Errorf("plugin %s is built with the SDK v%s, this version of Packer requires v%s or later: upgrade the plugin, or ask its maintainers to upgrade its SDK", plugin, sdkVersion, r.SDKVersion)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionRequirements(t *testing.T) {
+	req := VersionRequirements{APIVersion: "5.1", SDKVersion: "0.4.0"}
+	cases := []struct {
+		handshake Handshake
+		err       string
+	}{
+		{handshake: Handshake{APIVersionMajor: "5", APIVersionMinor: "1", SDKVersion: "0.4.0"}},
+		{handshake: Handshake{APIVersionMajor: "5", APIVersionMinor: "2", SDKVersion: "0.5.0-dev"}},
+		{
+			handshake: Handshake{APIVersionMajor: "5", APIVersionMinor: "0", SDKVersion: "0.4.0"},
+			err:       "requires v5.1 or later: upgrade the plugin",
+		},
+		{
+			handshake: Handshake{APIVersionMajor: "4", APIVersionMinor: "0", SDKVersion: "0.4.0"},
+			err:       "no longer supported",
+		},
+		{
+			handshake: Handshake{APIVersionMajor: "6", APIVersionMinor: "0", SDKVersion: "0.4.0"},
+			err:       "upgrade Packer",
+		},
+		{
+			handshake: Handshake{APIVersionMajor: "5", APIVersionMinor: "1", SDKVersion: "0.3.3"},
+			err:       "built with the SDK v0.3.3",
+		},
+		{
+			handshake: Handshake{APIVersionMajor: "5", APIVersionMinor: "1"},
+			err:       "too old to tell it",
+		},
+	}
+	for _, tc := range cases {
+		err := req.CheckHandshake("packer-plugin-example", tc.handshake)
+		if tc.err == "" {
+			if err != nil {
+				t.Fatalf("%#v: unexpected error: %s", tc.handshake, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%#v: expected an error containing %q, got %v", tc.handshake, tc.err, err)
+		}
+	}
+
+	d := NewSet().description()
+	if err := (VersionRequirements{APIVersion: APIVersionMajor + "." + APIVersionMinor, SDKVersion: d.SDKVersion}).CheckDescription("packer-plugin-example", d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
 )
 
 // Config represents a retry config
@@ -26,7 +28,8 @@ type Config struct {
 	Tries int
 
 	// ShouldRetry tells whether error should be retried. Nil defaults to always
-	// true.
+	// true. sdkerrors.IsRetryable only retries the errors classified as
+	// retryable.
 	ShouldRetry func(error) bool
 }
 
@@ -49,6 +52,8 @@ func (err *RetryExhaustedError) Error() string {
 // - The maximum number of tries, Config.Tries is exceeded.
 // - The function returns with an error that does not satisfy conditions
 //   set in the Config.ShouldRetry function.
+// - The function returns with an error classified as a user or fatal
+//   infrastructure error by the sdkerrors package.
 // If the given function (fn) does not return an error, then Run will return
 // nil. Otherwise, Run will return a relevant error.
 func (cfg Config) Run(ctx context.Context, fn func(context.Context) error) error {
@@ -73,7 +78,7 @@ func (cfg Config) Run(ctx context.Context, fn func(context.Context) error) error
 		if err = fn(ctx); err == nil {
 			return nil
 		}
-		if !shouldRetry(err) {
+		if sdkerrors.IsPermanent(err) || !shouldRetry(err) {
 			return err
 		}
 
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
 )
 
 func success(context.Context) error { return nil }
@@ -88,6 +89,30 @@ func TestConfig_Run(t *testing.T) {
 	}
 }
 
+func TestConfig_Run_permanent(t *testing.T) {
+	tries := 0
+	userErr := sdkerrors.UserErrorf("bad config")
+	err := Config{RetryDelay: func() time.Duration { return 0 }, Tries: 3}.Run(context.Background(), func(context.Context) error {
+		tries++
+		return userErr
+	})
+	if err != userErr || tries != 1 {
+		t.Fatalf("expected the user error not to be retried, got %v after %d tries", err, tries)
+	}
+
+	tries = 0
+	err = Config{RetryDelay: func() time.Duration { return 0 }, Tries: 3, ShouldRetry: sdkerrors.IsRetryable}.Run(context.Background(), func(context.Context) error {
+		tries++
+		if tries == 1 {
+			return sdkerrors.WrapRetryable(failErr)
+		}
+		return failErr
+	})
+	if err != failErr || tries != 2 {
+		t.Fatalf("expected only the retryable error to be retried, got %v after %d tries", err, tries)
+	}
+}
+
 func TestBackoff_Linear(t *testing.T) {
 	b := Backoff{
 		InitialBackoff: 2 * time.Minute,
@@ -4,6 +4,8 @@
 package rpc
 
 import (
+	"log"
+
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -16,6 +18,7 @@ type artifact struct {
 // ArtifactServer wraps a packersdk.Artifact implementation and makes it
 // exportable as part of a Golang RPC server.
 type ArtifactServer struct {
+	mux      *muxBroker
 	artifact packersdk.Artifact
 }
 
@@ -39,8 +42,21 @@ func (a *artifact) String() (result string) {
 	return
 }
 
-func (a *artifact) State(name string) (result interface{}) {
-	a.client.Call(a.endpoint+".State", name, &result)
+func (a *artifact) State(name string) interface{} {
+	v, err := a.loadState(name)
+	if err != nil {
+		log.Printf("[ERR] Error getting the state %q of the artifact: %s", name, err)
+	}
+	return v
+}
+
+// StateNames returns the names of the state of the artifact, none when it
+// isn't a packersdk.ArtifactStateLister or the server doesn't support it.
+func (a *artifact) StateNames() (result []string) {
+	if !a.mux.has(FeatureArtifactStateNames) {
+		return nil
+	}
+	a.client.Call(a.endpoint+".StateNames", new(interface{}), &result)
 	return
 }
 
@@ -78,6 +94,13 @@ func (s *ArtifactServer) State(name string, reply *interface{}) error {
 	return nil
 }
 
+func (s *ArtifactServer) StateNames(args *interface{}, reply *[]string) error {
+	if lister, ok := s.artifact.(packersdk.ArtifactStateLister); ok {
+		*reply = lister.StateNames()
+	}
+	return nil
+}
+
 func (s *ArtifactServer) Destroy(args *interface{}, reply *error) error {
 	err := s.artifact.Destroy()
 	if err != nil {
@@ -0,0 +1,189 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The state of an artifact can be large, like an image manifest or a map by
+// region. It's transferred by chunks over a stream of the connection, and
+// the clients refuse the states larger than MaxArtifactStateSize with an
+// ErrStateTooLarge error. The servers of older versions of the SDK return
+// the state in the reply of a call.
+
+// MaxArtifactStateSize is the maximum size, in bytes, of the encoded state
+// of an artifact the clients accept.
+var MaxArtifactStateSize int64 = 64 * 1024 * 1024
+
+// ErrStateTooLarge is returned when the state of an artifact exceeds
+// MaxArtifactStateSize.
+var ErrStateTooLarge = errors.New("artifact state too large")
+
+// ArtifactState returns the state name of a, like a.State, but returns the
+// errors of the artifacts served over RPC, like ErrStateTooLarge, which
+// State only logs.
+func ArtifactState(a packersdk.Artifact, name string) (interface{}, error) {
+	if s, ok := a.(interface {
+		loadState(string) (interface{}, error)
+	}); ok {
+		return s.loadState(name)
+	}
+	return a.State(name), nil
+}
+
+func stateTooLarge(name string) error {
+	return fmt.Errorf("%w: the state %q exceeds the limit of %d bytes", ErrStateTooLarge, name, MaxArtifactStateSize)
+}
+
+// ArtifactStateStreamArgs are the arguments of ArtifactServer.StateStream.
+type ArtifactStateStreamArgs struct {
+	Name     string
+	StreamId uint32
+}
+
+func (a *artifact) loadState(name string) (result interface{}, err error) {
+	if !a.mux.has(FeatureArtifactStateStream) {
+		err = a.client.Call(a.endpoint+".State", name, &result)
+		return
+	}
+
+	id := a.mux.NextId()
+	var size int64
+	if err := a.client.Call(a.endpoint+".StateStream", &ArtifactStateStreamArgs{Name: name, StreamId: id}, &size); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	conn, err := a.mux.Accept(id)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the stream stops the server when the state is too large.
+	defer conn.Close()
+	if size > MaxArtifactStateSize {
+		return nil, stateTooLarge(name)
+	}
+	b := make([]byte, size)
+	if n, err := io.ReadFull(conn, b); err != nil {
+		return nil, fmt.Errorf("the state %q of the artifact is truncated, got %d of its %d bytes: %w", name, n, size, err)
+	}
+	h := &codec.MsgpackHandle{
+		WriteExt: true,
+	}
+	err = codec.NewDecoderBytes(b, h).Decode(&result)
+	return
+}
+
+// StateStream replies with the size of the encoded state args.Name, and
+// sends it over the stream args.StreamId. The state is nil when the size is
+// 0.
+func (s *ArtifactServer) StateStream(args *ArtifactStateStreamArgs, reply *int64) error {
+	v := s.artifact.State(args.Name)
+	if v == nil {
+		return nil
+	}
+	var b []byte
+	h := &codec.MsgpackHandle{
+		WriteExt: true,
+	}
+	if err := codec.NewEncoderBytes(&b, h).Encode(v); err != nil {
+		return NewBasicError(err)
+	}
+	*reply = int64(len(b))
+
+	go func() {
+		conn, err := s.mux.Dial(args.StreamId)
+		if err != nil {
+			log.Printf("[ERR] Error sending the state %q of the artifact: %s", args.Name, err)
+			return
+		}
+		defer conn.Close()
+		if _, err := copyChunks(conn, bytes.NewReader(b), nil); err != nil {
+			log.Printf("[ERR] Error sending the state %q of the artifact: %s", args.Name, err)
+		}
+	}()
+	return nil
+}
+
+// stateBuffer buffers the chunks of the state name, up to
+// MaxArtifactStateSize bytes.
+type stateBuffer struct {
+	name string
+	bytes.Buffer
+}
+
+func (b *stateBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > MaxArtifactStateSize {
+		return 0, stateTooLarge(b.name)
+	}
+	return b.Buffer.Write(p)
+}
+
+func (a *grpcArtifact) loadState(name string) (interface{}, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancelling the stream stops the server when the state is too large.
+	defer cancel()
+	stream, err := a.peer.newStream(ctx, &grpcArtifactService.Streams[0], grpcArtifactName)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&pbArtifactRequest{Artifact: a.id, StateName: name}); err != nil {
+		return nil, fromGRPCStatus(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, fromGRPCStatus(err)
+	}
+	buf := &stateBuffer{name: name}
+	for {
+		var chunk pbChunk
+		err := stream.RecvMsg(&chunk)
+		if err == io.EOF {
+			break
+		}
+		if status.Code(err) == codes.Unimplemented {
+			// The server is of an older version of the SDK.
+			return a.unaryState(name)
+		}
+		if err != nil {
+			return nil, fromGRPCStatus(err)
+		}
+		if _, err := buf.Write(chunk.Data); err != nil {
+			return nil, err
+		}
+	}
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		return nil, fmt.Errorf("decoding the state %q of the artifact: %w", name, err)
+	}
+	return v, nil
+}
+
+func (a *grpcArtifact) unaryState(name string) (interface{}, error) {
+	var resp pbArtifactStateResponse
+	err := a.peer.invoke(context.Background(), "/"+grpcArtifactName+"/State", &pbArtifactRequest{Artifact: a.id, StateName: name}, &resp)
+	if err != nil || resp.Value == nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(resp.Value, &v); err != nil {
+		return nil, fmt.Errorf("decoding the state %q of the artifact: %w", name, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testArtifactState() *packersdk.MockArtifact {
+	regions := map[string]interface{}{}
+	for _, r := range []string{"us-east-1", "eu-west-1", "ap-south-1"} {
+		regions[r] = strings.Repeat("ami-0123456789 ", 40000)
+	}
+	return &packersdk.MockArtifact{StateValues: map[string]interface{}{
+		"regions": regions,
+		"small":   "value",
+	}}
+}
+
+func TestArtifactState(t *testing.T) {
+	a := testArtifactState()
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	// The states sent over a stream are decoded like the ones in the replies
+	// of the older servers.
+	streamed := map[string]interface{}{}
+	for _, name := range []string{"regions", "small", "none"} {
+		v, err := ArtifactState(client.Artifact(), name)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		streamed[name] = v
+	}
+	client.SetFeatures([]string{})
+	for name, v := range streamed {
+		if expected := client.Artifact().State(name); !reflect.DeepEqual(v, expected) {
+			t.Fatalf("%s: bad state: %#v, expected %#v", name, v, expected)
+		}
+	}
+	if streamed["none"] != nil {
+		t.Fatalf("bad: %#v", streamed["none"])
+	}
+}
+
+func TestArtifactState_tooLarge(t *testing.T) {
+	defer func(max int64) { MaxArtifactStateSize = max }(MaxArtifactStateSize)
+	MaxArtifactStateSize = 64 * 1024
+
+	a := testArtifactState()
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	if _, err := ArtifactState(client.Artifact(), "regions"); !errors.Is(err, ErrStateTooLarge) {
+		t.Fatalf("bad: %v", err)
+	}
+	if v := client.Artifact().State("small"); v == nil {
+		t.Fatal("the next states should be transferred")
+	}
+}
+
+func TestGRPCArtifactState(t *testing.T) {
+	a := testArtifactState()
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	aClient := &grpcArtifact{peer: client.peer, id: server.peer.export(a)}
+
+	v, err := ArtifactState(aClient, "regions")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(v, a.StateValues["regions"]) {
+		t.Fatal("bad state")
+	}
+	if v := aClient.State("none"); v != nil {
+		t.Fatalf("bad: %#v", v)
+	}
+
+	defer func(max int64) { MaxArtifactStateSize = max }(MaxArtifactStateSize)
+	MaxArtifactStateSize = 64 * 1024
+	if _, err := ArtifactState(aClient, "regions"); !errors.Is(err, ErrStateTooLarge) {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -40,6 +40,22 @@ func TestArtifactRPC(t *testing.T) {
 	}
 }
 
+func TestArtifactRPC_StateNames(t *testing.T) {
+	a := &packersdk.MockArtifact{StateValues: map[string]interface{}{"zone": "a"}}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	aClient := client.Artifact()
+	names := aClient.(packersdk.ArtifactStateLister).StateNames()
+	if !reflect.DeepEqual(names, []string{"zone"}) {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
 func TestArtifact_Implements(t *testing.T) {
 	var _ packersdk.Artifact = new(artifact)
+	var _ packersdk.ArtifactStateLister = new(artifact)
 }
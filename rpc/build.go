@@ -7,6 +7,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -20,7 +21,7 @@ type build struct {
 // as part of a Golang RPC server.
 type BuildServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel func(*multistep.CancelCause)
 
 	build packersdk.Build
 	mux   *muxBroker
@@ -61,7 +62,7 @@ func (b *build) Run(ctx context.Context, ui packersdk.Ui) ([]packersdk.Artifact,
 		select {
 		case <-ctx.Done():
 			log.Printf("Cancelling build after context cancellation %v", ctx.Err())
-			if err := b.client.Call("Build.Cancel", new(interface{}), new(interface{})); err != nil {
+			if err := b.client.Call("Build.Cancel", new(CancelArgs), new(interface{})); err != nil {
 				log.Printf("Error cancelling builder: %s", err)
 			}
 		case <-done:
@@ -105,7 +106,7 @@ func (b *build) SetOnError(val string) {
 }
 
 func (b *build) Cancel() {
-	if err := b.client.Call("Build.Cancel", new(interface{}), new(interface{})); err != nil {
+	if err := b.client.Call("Build.Cancel", new(CancelArgs), new(interface{})); err != nil {
 		panic(err)
 	}
 }
@@ -126,7 +127,7 @@ func (b *BuildServer) Prepare(args *interface{}, resp *BuildPrepareResponse) err
 
 func (b *BuildServer) Run(streamId uint32, reply *[]uint32) error {
 	if b.context == nil {
-		b.context, b.contextCancel = context.WithCancel(context.Background())
+		b.context, b.contextCancel = multistep.WithCancelCause(context.Background())
 	}
 
 	client, err := newClientWithMux(b.mux, streamId)
@@ -168,9 +169,9 @@ func (b *BuildServer) SetOnError(val *string, reply *interface{}) error {
 	return nil
 }
 
-func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
+func (b *BuildServer) Cancel(args *CancelArgs, reply *interface{}) error {
 	if b.contextCancel != nil {
-		b.contextCancel()
+		b.contextCancel(args.cause())
 	}
 	return nil
 }
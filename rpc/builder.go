@@ -5,8 +5,8 @@ package rpc
 
 import (
 	"context"
-	"log"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -20,7 +20,7 @@ type builder struct {
 // as part of a Golang RPC server.
 type BuilderServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel func(*multistep.CancelCause)
 
 	commonServer
 	builder packersdk.Builder
@@ -42,7 +42,7 @@ func (b *builder) Prepare(config ...interface{}) ([]string, []string, error) {
 		return nil, nil, err
 	}
 	var resp BuilderPrepareResponse
-	cerr := b.client.Call(b.endpoint+".Prepare", &BuilderPrepareArgs{config}, &resp)
+	cerr := b.call("Prepare", &BuilderPrepareArgs{config}, &resp)
 	if cerr != nil {
 		return nil, nil, cerr
 	}
@@ -61,22 +61,11 @@ func (b *builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	server.RegisterUi(ui)
 	go server.Serve()
 
-	done := make(chan interface{})
-	defer close(done)
-	go func() {
-		select {
-		case <-ctx.Done():
-			log.Printf("Cancelling builder after context cancellation %v", ctx.Err())
-			if err := b.client.Call(b.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
-				log.Printf("Error cancelling builder: %s", err)
-			}
-		case <-done:
-		}
-	}()
+	defer b.cancelOnDone(ctx, nextId, "builder")()
 
 	var responseId uint32
 
-	if err := b.client.Call(b.endpoint+".Run", nextId, &responseId); err != nil {
+	if err := b.call("Run", nextId, &responseId); err != nil {
 		return nil, err
 	}
 
@@ -92,11 +81,13 @@ func (b *builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	return client.Artifact(), nil
 }
 
-func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareResponse) error {
+func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareResponse) (err error) {
+	defer recoverCrash(b.mux, DefaultBuilderEndpoint, "Prepare", args.Configs, &err)
 	config, err := decodeCTYValues(args.Configs)
 	if err != nil {
 		return err
 	}
+	b.setConfigs(config)
 	generated, warnings, err := b.builder.Prepare(config...)
 	*reply = BuilderPrepareResponse{
 		GeneratedVars: generated,
@@ -106,7 +97,8 @@ func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareR
 	return nil
 }
 
-func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
+func (b *BuilderServer) Run(streamId uint32, reply *uint32) (err error) {
+	defer recoverCrash(b.mux, DefaultBuilderEndpoint, "Run", b.crashConfigs(), &err)
 	client, err := newClientWithMux(b.mux, streamId)
 	if err != nil {
 		return NewBasicError(err)
@@ -114,16 +106,21 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 	defer client.Close()
 
 	if b.context == nil {
-		b.context, b.contextCancel = context.WithCancel(context.Background())
+		b.context, b.contextCancel = multistep.WithCancelCause(context.Background())
 	}
 
-	artifact, err := b.builder.Run(b.context, client.Ui(), client.Hook())
+	ctx, done := b.mux.callContext(b.context, streamId)
+	defer done()
+
+	ui := client.Ui()
+	artifact, err := b.builder.Run(ctx, ui, client.Hook())
 	if err != nil {
 		return NewBasicError(err)
 	}
 
 	*reply = 0
 	if artifact != nil {
+		packersdk.EmitEvent(ui, packersdk.ArtifactEvent(artifact))
 		streamId = b.mux.NextId()
 		artifactServer := newServerWithMux(b.mux, streamId)
 		artifactServer.RegisterArtifact(artifact)
@@ -134,7 +131,9 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 	return nil
 }
 
-func (b *BuilderServer) Cancel(args *interface{}, reply *interface{}) error {
-	b.contextCancel()
+func (b *BuilderServer) Cancel(args *CancelArgs, reply *interface{}) error {
+	if b.contextCancel != nil {
+		b.contextCancel(args.cause())
+	}
 	return nil
 }
@@ -85,6 +85,10 @@ func TestBuilderRun(t *testing.T) {
 	if artifact.Id() != testBuilderArtifact.Id() {
 		t.Fatalf("bad: %s", artifact.Id())
 	}
+
+	if len(ui.events) != 1 || ui.events[0].Type != packersdk.EventArtifact || ui.events[0].ArtifactID != artifact.Id() {
+		t.Fatalf("expected the event of the artifact, got %#v", ui.events)
+	}
 }
 
 func TestBuilderRun_nilResult(t *testing.T) {
@@ -0,0 +1,281 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// The calls taking a context, like Builder.Run or Provisioner.Provision, are
+// cancelled over a dedicated stream of the connection, on which the client
+// writes the ID of the calls to cancel, followed by the cause of the
+// cancellation. A call is identified by the ID of the stream its client
+// opened for it, like the one of the Ui of a build. The servers of older
+// versions of the SDK only support a Cancel method per component, cancelling
+// all of its calls, which the clients call when the server doesn't support
+// FeatureCancelStream, or the stream fails.
+//
+// The contexts of the calls cancelled are cancelled with the cause, so that
+// multistep.Cause tells why on the server too.
+
+// cancelStreamId is the ID of the mux stream of the cancellations, out of
+// the range of the IDs returned by muxBroker.NextId.
+const cancelStreamId = math.MaxUint32 - 1
+
+// maxCancelField is the maximum length of the fields of the causes written
+// to the cancel stream. Longer errors are truncated.
+const maxCancelField = math.MaxUint16
+
+// CancelArgs are the arguments of the Cancel methods of the components: the
+// cause of the cancellation. The clients of older versions of the SDK send
+// no arguments, which is a cancellation of multistep.CancelReasonUnknown.
+type CancelArgs struct {
+	Reason string
+	// Err is the message of the error that caused the cancellation, if any.
+	Err string
+}
+
+// newCancelArgs returns the CancelArgs of the cancellation of ctx.
+func newCancelArgs(ctx context.Context) *CancelArgs {
+	args := new(CancelArgs)
+	if cause := multistep.Cause(ctx); cause != nil {
+		args.Reason = string(cause.Reason)
+		if cause.Err != nil {
+			args.Err = cause.Err.Error()
+		}
+	}
+	return args
+}
+
+// cause returns the cause of the cancellation of args.
+func (a *CancelArgs) cause() *multistep.CancelCause {
+	if a == nil || a.Reason == "" {
+		return nil
+	}
+	cause := &multistep.CancelCause{Reason: multistep.CancelReason(a.Reason)}
+	if a.Err != "" {
+		cause.Err = errors.New(a.Err)
+	}
+	return cause
+}
+
+// callSet is the set of the calls in flight of a server, by ID.
+type callSet struct {
+	l sync.Mutex
+	// calls are the cancel functions of the calls in flight, and cancelled
+	// the calls cancelled before they started.
+	calls     map[uint32]func(*multistep.CancelCause)
+	cancelled map[uint32]*multistep.CancelCause
+	// draining is set once the server shuts down, see drain. idle is then
+	// closed once no call is in flight.
+	draining bool
+	idle     chan struct{}
+}
+
+// start returns the context of the call id, derived from parent and
+// cancelled with its cause when the call is cancelled, and the function to
+// call once the call returns.
+func (s *callSet) start(parent context.Context, id uint32) (context.Context, func()) {
+	ctx, cancel := multistep.WithCancelCause(parent)
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[uint32]func(*multistep.CancelCause))
+	}
+	s.calls[id] = cancel
+	if cause, ok := s.cancelled[id]; ok {
+		// The call was cancelled before it started.
+		delete(s.cancelled, id)
+		cancel(cause)
+	} else if s.draining {
+		cancel(nil)
+	}
+	return ctx, func() {
+		s.l.Lock()
+		delete(s.calls, id)
+		if len(s.calls) == 0 && s.idle != nil {
+			close(s.idle)
+			s.idle = nil
+		}
+		s.l.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel cancels the context of the call id with cause, which may be nil.
+func (s *callSet) cancel(id uint32, cause *multistep.CancelCause) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if cancel, ok := s.calls[id]; ok {
+		cancel(cause)
+		return
+	}
+	if s.cancelled == nil {
+		s.cancelled = make(map[uint32]*multistep.CancelCause)
+	}
+	s.cancelled[id] = cause
+}
+
+// len returns the number of calls in flight.
+func (s *callSet) len() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return len(s.calls)
+}
+
+func (s *callSet) isDraining() bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.draining
+}
+
+// drain cancels the calls in flight, and the ones started afterwards, and
+// waits for them to return, so that they run their cleanups, until ctx is
+// done.
+func (s *callSet) drain(ctx context.Context) error {
+	s.l.Lock()
+	s.draining = true
+	for _, cancel := range s.calls {
+		cancel(nil)
+	}
+	if len(s.calls) == 0 {
+		s.l.Unlock()
+		return nil
+	}
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+	}
+	idle := s.idle
+	s.l.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d calls still in flight: %w", s.len(), ctx.Err())
+	}
+}
+
+// callContext returns the context of the call id, derived from parent and
+// cancelled when the client cancels the call, and the function to call once
+// the call returns.
+func (m *muxBroker) callContext(parent context.Context, id uint32) (context.Context, func()) {
+	return m.calls.start(parent, id)
+}
+
+// cancelCall cancels the context of the call id with cause.
+func (m *muxBroker) cancelCall(id uint32, cause *multistep.CancelCause) {
+	m.calls.cancel(id, cause)
+}
+
+// sendCancel asks the other end to cancel the call id, because of args.
+func (m *muxBroker) sendCancel(id uint32, args *CancelArgs) error {
+	m.cancelLock.Lock()
+	defer m.cancelLock.Unlock()
+	if m.cancelConn == nil {
+		conn, err := m.Dial(cancelStreamId)
+		if err != nil {
+			return err
+		}
+		m.cancelConn = conn
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, id)
+	writeCancelField(&buf, args.Reason)
+	writeCancelField(&buf, args.Err)
+	if _, err := m.cancelConn.Write(buf.Bytes()); err != nil {
+		m.cancelConn.Close()
+		m.cancelConn = nil
+		return err
+	}
+	return nil
+}
+
+// serveCancels cancels the calls the other end writes the ID of to conn,
+// until it is closed.
+func (m *muxBroker) serveCancels(conn net.Conn) {
+	defer conn.Close()
+	// Ack the connection, like Accept does.
+	if err := binary.Write(conn, binary.LittleEndian, uint32(cancelStreamId)); err != nil {
+		return
+	}
+	for {
+		var id uint32
+		var args CancelArgs
+		err := binary.Read(conn, binary.LittleEndian, &id)
+		if err == nil {
+			args.Reason, err = readCancelField(conn)
+		}
+		if err == nil {
+			args.Err, err = readCancelField(conn)
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("[ERR] Error reading the cancel stream: %s", err)
+			}
+			return
+		}
+		m.cancelCall(id, args.cause())
+	}
+}
+
+// writeCancelField writes s to w, prefixed with its length, truncated to
+// maxCancelField.
+func writeCancelField(w io.Writer, s string) {
+	if len(s) > maxCancelField {
+		s = s[:maxCancelField]
+	}
+	binary.Write(w, binary.LittleEndian, uint16(len(s)))
+	io.WriteString(w, s)
+}
+
+// readCancelField reads a field written by writeCancelField from r.
+func readCancelField(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// cancelOnDone cancels the call id of the server, with the cause of the
+// cancellation of ctx, when ctx is done, until the returned function is
+// called. name is the name of the component, for the logs.
+func (c *commonClient) cancelOnDone(ctx context.Context, id uint32, name string) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("Cancelling %s after context cancellation %v", name, ctx.Err())
+			args := newCancelArgs(ctx)
+			if c.mux.has(FeatureCancelStream) {
+				err := c.mux.sendCancel(id, args)
+				if err == nil {
+					return
+				}
+				log.Printf("Error cancelling %s over the cancel stream: %s", name, err)
+			}
+			if err := c.client.Call(c.endpoint+".Cancel", args, new(interface{})); err != nil {
+				log.Printf("Error cancelling %s: %s", name, err)
+			}
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
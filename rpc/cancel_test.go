@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testBuilderCancel(t *testing.T, features []string) {
+	b := new(packersdk.MockBuilder)
+	started := make(chan struct{}, 2)
+	causes := make(chan *multistep.CancelCause, 1)
+	b.RunFn = func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+		causes <- multistep.Cause(ctx)
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	client.SetFeatures(features)
+	bClient := client.Builder()
+
+	ctx, cancel := multistep.WithCancelCause(context.Background())
+	go func() {
+		<-started
+		cancel(&multistep.CancelCause{
+			Reason: multistep.CancelReasonUpstreamFailure,
+			Err:    errors.New("build qemu.a failed"),
+		})
+	}()
+	if _, err := bClient.Run(ctx, new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cause := <-causes
+	if cause.Reason != multistep.CancelReasonUpstreamFailure || cause.Err == nil || cause.Err.Error() != "build qemu.a failed" {
+		t.Fatalf("expected the cause of the cancellation, got %#v", cause)
+	}
+}
+
+func TestBuilderCancel_stream(t *testing.T) {
+	testBuilderCancel(t, SupportedFeatures)
+}
+
+func TestBuilderCancel_olderServer(t *testing.T) {
+	testBuilderCancel(t, []string{})
+}
+
+func TestBuilderCancel_olderClient(t *testing.T) {
+	// The clients of older versions of the SDK call Cancel without
+	// arguments.
+	b := new(packersdk.MockBuilder)
+	started := make(chan struct{})
+	causes := make(chan *multistep.CancelCause, 1)
+	b.RunFn = func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		causes <- multistep.Cause(ctx)
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder().(*builder)
+
+	go func() {
+		<-started
+		if err := bClient.client.Call(DefaultBuilderEndpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}()
+	if _, err := bClient.Run(context.Background(), new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if cause := <-causes; cause.Reason != multistep.CancelReasonUnknown {
+		t.Fatalf("expected an unknown reason, got %#v", cause)
+	}
+}
+
+func TestBuilderCancel_otherCalls(t *testing.T) {
+	// Cancelling a call doesn't cancel the next ones.
+	b := new(packersdk.MockBuilder)
+	started := make(chan struct{})
+	b.RunFn = func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+		if _, err := bClient.Run(ctx, new(testUi), new(packersdk.MockHook)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestMuxBroker_cancelBeforeCall(t *testing.T) {
+	m := newMuxBroker(nil)
+	m.cancelCall(42, &multistep.CancelCause{Reason: multistep.CancelReasonUser})
+	ctx, done := m.callContext(context.Background(), 42)
+	defer done()
+	if cause := multistep.Cause(ctx); cause == nil || cause.Reason != multistep.CancelReasonUser {
+		t.Fatalf("the call should be cancelled with its cause, got %#v", cause)
+	}
+	ctx, done = m.callContext(context.Background(), 42)
+	defer done()
+	if ctx.Err() != nil {
+		t.Fatal("only the first call should be cancelled")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/rpc"
 
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
 	"github.com/ugorji/go/codec"
 )
 
@@ -49,7 +50,16 @@ func newClientWithMux(mux *muxBroker, streamId uint32) (*Client, error) {
 	h := &codec.MsgpackHandle{
 		WriteExt: true,
 	}
-	clientCodec := codec.GoRpc.ClientCodec(clientConn, h)
+	var clientCodec rpc.ClientCodec
+	if observe := callObserver(); observe != nil {
+		conn := newCountingConn(clientConn)
+		clientCodec = newObservedClientCodec(codec.GoRpc.ClientCodec(conn, h), conn, observe)
+	} else {
+		clientCodec = codec.GoRpc.ClientCodec(clientConn, h)
+	}
+	if tracing.Enabled() {
+		clientCodec = newTracingClientCodec(clientCodec)
+	}
 
 	return &Client{
 		mux:      mux,
@@ -76,6 +86,7 @@ func (c *Client) Artifact() packer.Artifact {
 		commonClient: commonClient{
 			endpoint: DefaultArtifactEndpoint,
 			client:   c.client,
+			mux:      c.mux,
 		},
 	}
 }
@@ -150,11 +161,22 @@ func (c *Client) Datasource() packer.Datasource {
 	}
 }
 
+func (c *Client) Function() packer.Function {
+	return &function{
+		commonClient: commonClient{
+			endpoint: DefaultFunctionEndpoint,
+			client:   c.client,
+			mux:      c.mux,
+		},
+	}
+}
+
 func (c *Client) Ui() packer.Ui {
 	return &Ui{
 		commonClient: commonClient{
 			endpoint: DefaultUiEndpoint,
 			client:   c.client,
+			mux:      c.mux,
 		},
 		endpoint: DefaultUiEndpoint,
 	}
@@ -6,9 +6,11 @@ package rpc
 import (
 	"net"
 	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
 )
 
-func testConn(t *testing.T) (net.Conn, net.Conn) {
+func testConn(t testing.TB) (net.Conn, net.Conn) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("err: %s", err)
@@ -40,13 +42,14 @@ func testConn(t *testing.T) (net.Conn, net.Conn) {
 	return clientConn, serverConn
 }
 
-func testClientServer(t *testing.T) (*Client, *PluginServer) {
+func testClientServer(t testing.TB) (*Client, *PluginServer) {
 	clientConn, serverConn := testConn(t)
 
 	server, err := NewServer(serverConn)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
+	server.SetFeatures(SupportedFeatures)
 	go server.Serve()
 
 	client, err := NewClient(clientConn)
@@ -54,6 +57,33 @@ func testClientServer(t *testing.T) (*Client, *PluginServer) {
 		server.Close()
 		t.Fatalf("err: %s", err)
 	}
+	client.SetFeatures(SupportedFeatures)
 
 	return client, server
 }
+
+func TestClient_tracing(t *testing.T) {
+	tp := new(tracing.MockTracerProvider)
+	tracing.SetTracerProvider(tp)
+	defer tracing.SetTracerProvider(nil)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(new(testUi))
+
+	client.Ui().Say("format")
+
+	var span *tracing.MockSpan
+	for _, s := range tp.Spans() {
+		if s.Name == "rpc Ui.Say" {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatalf("expected a span for the call, got %v", tp.Spans())
+	}
+	if !span.Ended || len(span.Errors) != 0 || span.Attributes["rpc.method"] != "Ui.Say" {
+		t.Fatalf("unexpected span %#v", span)
+	}
+}
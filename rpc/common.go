@@ -5,12 +5,12 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"net/rpc"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
-	"github.com/zclconf/go-cty/cty"
 )
 
 // commonClient allows to rpc call funcs that can be defined on the different
@@ -24,11 +24,34 @@ type commonClient struct {
 	mux      *muxBroker
 }
 
+// call calls method of the endpoint, returning the crash report of the
+// server when it panicked.
+func (p *commonClient) call(method string, args interface{}, reply interface{}) error {
+	return decodeCrash(p.client.Call(p.endpoint+"."+method, args, reply))
+}
+
+// callContext calls method with client, returning the error of ctx if it is
+// done before the call returns. reply is then written when the call returns.
+func callContext(ctx context.Context, client *rpc.Client, method string, args, reply interface{}) error {
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 type commonServer struct {
+	configured
+	// endpoint is the endpoint the server is registered with.
+	endpoint         string
 	mux              *muxBroker
 	selfConfigurable interface {
 		ConfigSpec() hcldec.ObjectSpec
 	}
+	// specs caches the encoded specs, see spec_cache.go.
+	specs specCache
 }
 
 type ConfigSpecResponse struct {
@@ -40,35 +63,39 @@ func (p *commonClient) ConfigSpec() hcldec.ObjectSpec {
 	// return an error; should we simply panic ? Logging this for now; will
 	// decide later. The correct approach would probably be to return an error
 	// in ConfigSpec but that will break a lot of things.
-	resp := &ConfigSpecResponse{}
-	cerr := p.client.Call(p.endpoint+".ConfigSpec", new(interface{}), resp)
-	if cerr != nil {
-		err := fmt.Errorf("ConfigSpec failed: %v", cerr)
-		panic(err.Error())
-	}
+	load := func() (interface{}, error) {
+		resp := &ConfigSpecResponse{}
+		cerr := p.call("ConfigSpec", new(interface{}), resp)
+		if cerr != nil {
+			err := fmt.Errorf("ConfigSpec failed: %v", cerr)
+			panic(err.Error())
+		}
 
-	res := hcldec.ObjectSpec{}
-	err := gob.NewDecoder(bytes.NewReader(resp.ConfigSpec)).Decode(&res)
-	if err != nil {
-		panic("ici:" + err.Error())
+		res := hcldec.ObjectSpec{}
+		err := decodeGob(resp.ConfigSpec, &res)
+		if err != nil {
+			panic("ici:" + err.Error())
+		}
+		return res, nil
 	}
-	return res
-}
-
-func (s *commonServer) ConfigSpec(_ interface{}, reply *ConfigSpecResponse) error {
-	spec := s.selfConfigurable.ConfigSpec()
-	b := bytes.NewBuffer(nil)
-	err := gob.NewEncoder(b).Encode(spec)
-	reply.ConfigSpec = b.Bytes()
-
-	return err
+	if p.mux == nil {
+		res, _ := load()
+		return res.(hcldec.ObjectSpec)
+	}
+	res, _ := p.mux.specs.get(p.endpoint+".ConfigSpec", load)
+	return copySpec(res.(hcldec.ObjectSpec))
 }
 
-func init() {
-	gob.Register(new(hcldec.AttrSpec))
-	gob.Register(new(hcldec.BlockSpec))
-	gob.Register(new(hcldec.BlockAttrsSpec))
-	gob.Register(new(hcldec.BlockListSpec))
-	gob.Register(new(hcldec.BlockObjectSpec))
-	gob.Register(new(cty.Value))
+func (s *commonServer) ConfigSpec(_ interface{}, reply *ConfigSpecResponse) (err error) {
+	defer recoverCrash(s.mux, s.endpoint, "ConfigSpec", nil, &err)
+	spec, err := s.specs.get("ConfigSpec", func() (interface{}, error) {
+		b := bytes.NewBuffer(nil)
+		err := gob.NewEncoder(b).Encode(s.selfConfigurable.ConfigSpec())
+		return b.Bytes(), err
+	})
+	if err != nil {
+		return err
+	}
+	reply.ConfigSpec = spec.([]byte)
+	return nil
 }
@@ -34,6 +34,7 @@ type CommandFinished struct {
 
 type CommunicatorStartArgs struct {
 	Command          string
+	Pty              *bool
 	StdinStreamId    uint32
 	StdoutStreamId   uint32
 	StderrStreamId   uint32
@@ -75,6 +76,7 @@ func Communicator(client *rpc.Client) *communicator {
 func (c *communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error) {
 	var args CommunicatorStartArgs
 	args.Command = cmd.Command
+	args.Pty = cmd.Pty
 
 	var wg sync.WaitGroup
 
@@ -106,7 +108,22 @@ func (c *communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err
 	responseStreamId := c.mux.NextId()
 	args.ResponseStreamId = responseStreamId
 
+	// The command runs until it exits, or ctx is done.
+	exited := make(chan struct{})
+	if c.mux.has(FeatureCancelStream) {
+		go func() {
+			select {
+			case <-ctx.Done():
+				if err := c.mux.sendCancel(responseStreamId, newCancelArgs(ctx)); err != nil {
+					log.Printf("Error cancelling command: %s", err)
+				}
+			case <-exited:
+			}
+		}()
+	}
+
 	go func() {
+		defer close(exited)
 		conn, err := c.mux.Accept(responseStreamId)
 		wg.Wait()
 		if err != nil {
@@ -118,8 +135,7 @@ func (c *communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err
 		defer conn.Close()
 
 		var finished CommandFinished
-		decoder := gob.NewDecoder(conn)
-		if err := decoder.Decode(&finished); err != nil {
+		if err := decodeGobStream(conn, &finished); err != nil {
 			log.Printf("[ERR] Error decoding response stream %d: %s",
 				responseStreamId, err)
 			cmd.SetExited(123)
@@ -209,12 +225,13 @@ func (c *communicator) Download(path string, w io.Writer) (err error) {
 }
 
 func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface{}) error {
-	ctx := context.TODO()
+	ctx, cancel := c.mux.callContext(context.Background(), args.ResponseStreamId)
 
 	// Build the RemoteCmd on this side so that it all pipes over
 	// to the remote side.
 	var cmd packersdk.RemoteCmd
 	cmd.Command = args.Command
+	cmd.Pty = args.Pty
 
 	// Create a channel to signal we're done so that we can close
 	// our stdin/stdout/stderr streams
@@ -222,6 +239,7 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	doneCh := make(chan struct{})
 	go func() {
 		<-doneCh
+		cancel()
 		for _, conn := range toClose {
 			defer conn.Close()
 		}
@@ -342,7 +360,14 @@ func serveSingleCopy(name string, mux *muxBroker, id uint32, dst io.Writer, src
 		src = conn
 	}
 
-	written, err := io.Copy(dst, src)
+	// Large transfers log their progress every transferLogInterval bytes.
+	var logged int64
+	written, err := copyChunks(dst, src, func(written int64) {
+		if written-logged >= transferLogInterval {
+			logged = written
+			log.Printf("[TRACE] %d bytes copied for '%s'", written, name)
+		}
+	})
 	log.Printf("[INFO] %d bytes written for '%s'", written, name)
 	if err != nil {
 		log.Printf("[ERR] '%s' copy error: %s", name, err)
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// compressedConn compresses a connection with flate. Each write is flushed,
+// so that the messages are sent as soon as they are written.
+type compressedConn struct {
+	conn io.ReadWriteCloser
+	r    io.ReadCloser
+
+	l sync.Mutex
+	w *flate.Writer
+}
+
+// NewCompressedConn returns conn compressed, when FeatureCompression was
+// negotiated. The other end must compress its end of the connection too.
+//
+// The configurations, the HCL2 specs and the Ui messages sent between Packer
+// and the plugins compress well, which speeds up the builds over slow links,
+// like remote docker hosts or plugins forwarded over SSH.
+func NewCompressedConn(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	// flate.NewWriter only fails with an invalid level.
+	w, _ := flate.NewWriter(conn, flate.BestSpeed)
+	return &compressedConn{
+		conn: conn,
+		r:    flate.NewReader(conn),
+		w:    w,
+	}
+}
+
+func (c *compressedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *compressedConn) Write(p []byte) (int, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
+
+func (c *compressedConn) Close() error {
+	c.l.Lock()
+	c.w.Close()
+	c.l.Unlock()
+	c.r.Close()
+	return c.conn.Close()
+}
@@ -0,0 +1,43 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestCompressedConn(t *testing.T) {
+	clientConn, serverConn := testConn(t)
+
+	server, err := NewServer(NewCompressedConn(serverConn))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer server.Close()
+	go server.Serve()
+	client, err := NewClient(NewCompressedConn(clientConn))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer client.Close()
+
+	b := &packer.MockBuilder{ArtifactId: "foo"}
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if _, _, err := bClient.Prepare(map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ui := new(testUi)
+	artifact, err := bClient.Run(context.Background(), ui, new(packer.MockHook))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if artifact.Id() != "foo" {
+		t.Fatalf("bad artifact ID: %s", artifact.Id())
+	}
+}
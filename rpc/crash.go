@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// When a component of a plugin panics while serving a call, the panic is
+// recovered and turned into a CrashReport, sent back to Packer as the error
+// of the call. Packer writes it to a crash file, instead of getting an
+// unexpected EOF from the plugin exiting. The panics of the goroutines
+// started by the components still make the plugin exit.
+//
+// The reports are only sent when FeatureCrashReport is negotiated, the
+// other ends get the message of the report as a plain error. They don't
+// carry the configuration of the component, which can hold secrets, but a
+// hash of it telling whether crashes happened with the same configuration.
+
+// crashPrefix starts the message of the errors carrying a CrashReport.
+const crashPrefix = "packer-plugin-crash: "
+
+// CrashReportDir is the directory the clients write the crash reports to. It
+// is the default directory for temporary files when empty.
+var CrashReportDir = ""
+
+// CrashReport describes the panic of a component of a plugin.
+type CrashReport struct {
+	Time time.Time
+	// Component is the type of the component which panicked, like
+	// "Builder", and Method the method of the component, like "Run".
+	Component string
+	Method    string
+	// Panic is the value the component panicked with, and Stack the stack
+	// of the goroutine which panicked.
+	Panic string
+	Stack string
+	// ConfigHash is the SHA-256 of the configuration of the component,
+	// hex encoded.
+	ConfigHash string
+	SDKVersion string
+
+	// Path is the crash file the report was written to by the client.
+	Path string `json:"-"`
+}
+
+func (r *CrashReport) Error() string {
+	msg := fmt.Sprintf("%s.%s panicked: %s", r.Component, r.Method, r.Panic)
+	if r.Path != "" {
+		msg += fmt.Sprintf(" (crash report written to %s)", r.Path)
+	}
+	return msg
+}
+
+// WriteFile writes the report as JSON to a new file of dir, and sets its
+// Path.
+func (r *CrashReport) WriteFile(dir string) error {
+	f, err := os.CreateTemp(dir, "packer-plugin-crash-*.json")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	r.Path = f.Name()
+	return nil
+}
+
+// recoverCrash recovers a panic of method of component, setting err to a
+// crash report, or to its message when the connection doesn't use
+// FeatureCrashReport. It must be deferred by the servers.
+func recoverCrash(features interface{ has(string) bool }, component, method string, configs []interface{}, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	r := &CrashReport{
+		Time:       time.Now(),
+		Component:  component,
+		Method:     method,
+		Panic:      packer.LogSecretFilter.FilterString(fmt.Sprint(v)),
+		Stack:      packer.LogSecretFilter.FilterString(string(debug.Stack())),
+		ConfigHash: crashConfigHash(configs),
+		SDKVersion: pluginVersion.SDKVersion.String(),
+	}
+	log.Printf("[ERR] %s\n%s", r, r.Stack)
+	if features == nil || !features.has(FeatureCrashReport) {
+		*err = errors.New(r.Error())
+		return
+	}
+	b, jsonErr := json.Marshal(r)
+	if jsonErr != nil {
+		*err = errors.New(r.Error())
+		return
+	}
+	*err = errors.New(crashPrefix + string(b))
+}
+
+// decodeCrash returns the CrashReport carried by err, written to
+// CrashReportDir, or err if there is none.
+func decodeCrash(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if !strings.HasPrefix(msg, crashPrefix) {
+		return err
+	}
+	r := new(CrashReport)
+	if jsonErr := json.Unmarshal([]byte(msg[len(crashPrefix):]), r); jsonErr != nil {
+		return err
+	}
+	if err := r.WriteFile(CrashReportDir); err != nil {
+		log.Printf("[ERR] Error writing the crash report: %s", err)
+	}
+	return r
+}
+
+// crashConfigHash returns the SHA-256 of the configs as JSON, hex encoded.
+func crashConfigHash(configs []interface{}) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, c := range configs {
+		if v, ok := c.(cty.Value); ok {
+			if !v.IsWhollyKnown() {
+				enc.Encode("<unknown>")
+				continue
+			}
+			b, err := ctyjson.Marshal(v, v.Type())
+			if err != nil {
+				enc.Encode("<" + err.Error() + ">")
+				continue
+			}
+			c = json.RawMessage(b)
+		}
+		if err := enc.Encode(c); err != nil {
+			enc.Encode("<" + err.Error() + ">")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// configured holds the configs of a component, for its crash reports.
+type configured struct {
+	configs []interface{}
+}
+
+func (c *configured) setConfigs(configs []interface{}) { c.configs = configs }
+func (c *configured) crashConfigs() []interface{}      { return c.configs }
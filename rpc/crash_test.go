@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testCrashReport(t *testing.T, err error) *CrashReport {
+	var r *CrashReport
+	if !errors.As(err, &r) {
+		t.Fatalf("expected a crash report, got %#v", err)
+	}
+	if r.Component != "Builder" || r.Method != "Run" || r.Panic != "boom" {
+		t.Fatalf("bad report: %#v", r)
+	}
+	if !strings.Contains(r.Stack, "panic") || r.SDKVersion == "" {
+		t.Fatalf("bad report: %#v", r)
+	}
+	if len(r.ConfigHash) != 64 {
+		t.Fatalf("bad config hash: %s", r.ConfigHash)
+	}
+
+	b, err := os.ReadFile(r.Path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var written CrashReport
+	if err := json.Unmarshal(b, &written); err != nil || written.Stack != r.Stack {
+		t.Fatalf("bad crash file: %s, %v", b, err)
+	}
+	return r
+}
+
+func TestBuilder_crash(t *testing.T) {
+	CrashReportDir = t.TempDir()
+	defer func() { CrashReportDir = "" }()
+
+	b := &packer.MockBuilder{RunFn: func(context.Context) { panic("boom") }}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	config := map[string]interface{}{"name": "foo", "password": "hunter2"}
+	if _, _, err := bClient.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err := bClient.Run(context.Background(), new(testUi), new(packer.MockHook))
+	testCrashReport(t, err)
+}
+
+func TestGRPCBuilder_crash(t *testing.T) {
+	CrashReportDir = t.TempDir()
+	defer func() { CrashReportDir = "" }()
+
+	b := &packer.MockBuilder{RunFn: func(context.Context) { panic("boom") }}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	config := cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("foo"), "password": cty.StringVal("hunter2")})
+	if _, _, err := bClient.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err := bClient.Run(context.Background(), new(testUi), new(packer.MockHook))
+	testCrashReport(t, err)
+}
+
+func TestBuilder_crashUnsupported(t *testing.T) {
+	CrashReportDir = t.TempDir()
+	defer func() { CrashReportDir = "" }()
+
+	b := &packer.MockBuilder{RunFn: func(context.Context) { panic("boom") }}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.SetFeatures([]string{})
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if _, _, err := bClient.Prepare(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err := bClient.Run(context.Background(), new(testUi), new(packer.MockHook))
+	var r *CrashReport
+	if err == nil || errors.As(err, &r) {
+		t.Fatalf("expected a plain error, got %#v", err)
+	}
+	if err.Error() != "Builder.Run panicked: boom" {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestCrashConfigHash(t *testing.T) {
+	a := crashConfigHash([]interface{}{
+		map[string]interface{}{"name": "foo", "password": "hunter2"},
+		cty.ObjectVal(map[string]cty.Value{"name": cty.UnknownVal(cty.String)}),
+	})
+	b := crashConfigHash([]interface{}{
+		map[string]interface{}{"password": "hunter2", "name": "foo"},
+		cty.ObjectVal(map[string]cty.Value{"name": cty.UnknownVal(cty.String)}),
+	})
+	c := crashConfigHash([]interface{}{
+		map[string]interface{}{"name": "bar", "password": "hunter2"},
+	})
+	if a != b || a == c || len(a) != 64 {
+		t.Fatalf("bad hashes: %s, %s, %s", a, b, c)
+	}
+}
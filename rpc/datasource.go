@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -33,7 +34,7 @@ func (d *datasource) Configure(configs ...interface{}) error {
 		return err
 	}
 	var resp DatasourceConfigureResponse
-	if err := d.client.Call(d.endpoint+".Configure", &DatasourceConfigureArgs{Configs: configs}, &resp); err != nil {
+	if err := d.call("Configure", &DatasourceConfigureArgs{Configs: configs}, &resp); err != nil {
 		return err
 	}
 	if resp.Error != nil {
@@ -47,17 +48,20 @@ type OutputSpecResponse struct {
 }
 
 func (d *datasource) OutputSpec() hcldec.ObjectSpec {
-	resp := new(OutputSpecResponse)
-	if err := d.client.Call(d.endpoint+".OutputSpec", new(interface{}), resp); err != nil {
-		err := fmt.Errorf("Datasource.OutputSpec failed: %v", err)
-		panic(err.Error())
-	}
-	res := hcldec.ObjectSpec{}
-	err := gob.NewDecoder(bytes.NewReader(resp.OutputSpec)).Decode(&res)
-	if err != nil {
-		panic("ici:" + err.Error())
-	}
-	return res
+	res, _ := d.mux.specs.get(d.endpoint+".OutputSpec", func() (interface{}, error) {
+		resp := new(OutputSpecResponse)
+		if err := d.call("OutputSpec", new(interface{}), resp); err != nil {
+			err := fmt.Errorf("Datasource.OutputSpec failed: %v", err)
+			panic(err.Error())
+		}
+		res := hcldec.ObjectSpec{}
+		err := decodeGob(resp.OutputSpec, &res)
+		if err != nil {
+			panic("ici:" + err.Error())
+		}
+		return res, nil
+	})
+	return copySpec(res.(hcldec.ObjectSpec))
 }
 
 type ExecuteResponse struct {
@@ -68,11 +72,11 @@ type ExecuteResponse struct {
 func (d *datasource) Execute() (cty.Value, error) {
 	res := new(cty.Value)
 	resp := new(ExecuteResponse)
-	if err := d.client.Call(d.endpoint+".Execute", new(interface{}), resp); err != nil {
-		err := fmt.Errorf("Datasource.Execute failed: %v", err)
+	if err := d.call("Execute", new(interface{}), resp); err != nil {
+		err := fmt.Errorf("Datasource.Execute failed: %w", err)
 		return *res, err
 	}
-	err := gob.NewDecoder(bytes.NewReader(resp.Value)).Decode(&res)
+	err := decodeGob(resp.Value, &res)
 	if err != nil {
 		return *res, err
 	}
@@ -85,31 +89,40 @@ func (d *datasource) Execute() (cty.Value, error) {
 // DatasourceServer wraps a packer.Datasource implementation and makes it
 // exportable as part of a Golang RPC server.
 type DatasourceServer struct {
-	contextCancel func()
+	contextCancel func(*multistep.CancelCause)
 
 	commonServer
 	d packer.Datasource
 }
 
-func (d *DatasourceServer) Configure(args *DatasourceConfigureArgs, reply *DatasourceConfigureResponse) error {
+func (d *DatasourceServer) Configure(args *DatasourceConfigureArgs, reply *DatasourceConfigureResponse) (err error) {
+	defer recoverCrash(d.mux, DefaultDatasourceEndpoint, "Configure", args.Configs, &err)
 	config, err := decodeCTYValues(args.Configs)
 	if err != nil {
 		return err
 	}
+	d.setConfigs(config)
 	err = d.d.Configure(config...)
 	reply.Error = NewBasicError(err)
 	return err
 }
 
-func (d *DatasourceServer) OutputSpec(args *DatasourceConfigureArgs, reply *OutputSpecResponse) error {
-	spec := d.d.OutputSpec()
-	b := bytes.NewBuffer(nil)
-	err := gob.NewEncoder(b).Encode(spec)
-	reply.OutputSpec = b.Bytes()
-	return err
+func (d *DatasourceServer) OutputSpec(args *DatasourceConfigureArgs, reply *OutputSpecResponse) (err error) {
+	defer recoverCrash(d.mux, DefaultDatasourceEndpoint, "OutputSpec", d.crashConfigs(), &err)
+	spec, err := d.specs.get("OutputSpec", func() (interface{}, error) {
+		b := bytes.NewBuffer(nil)
+		err := gob.NewEncoder(b).Encode(d.d.OutputSpec())
+		return b.Bytes(), err
+	})
+	if err != nil {
+		return err
+	}
+	reply.OutputSpec = spec.([]byte)
+	return nil
 }
 
-func (d *DatasourceServer) Execute(args *interface{}, reply *ExecuteResponse) error {
+func (d *DatasourceServer) Execute(args *interface{}, reply *ExecuteResponse) (err error) {
+	defer recoverCrash(d.mux, DefaultDatasourceEndpoint, "Execute", d.crashConfigs(), &err)
 	spec, err := d.d.Execute()
 	reply.Error = NewBasicError(err)
 	b := bytes.NewBuffer(nil)
@@ -121,13 +134,9 @@ func (d *DatasourceServer) Execute(args *interface{}, reply *ExecuteResponse) er
 	return err
 }
 
-func (d *DatasourceServer) Cancel(args *interface{}, reply *interface{}) error {
+func (d *DatasourceServer) Cancel(args *CancelArgs, reply *interface{}) error {
 	if d.contextCancel != nil {
-		d.contextCancel()
+		d.contextCancel(args.cause())
 	}
 	return nil
 }
-
-func init() {
-	gob.Register(new(cty.Value))
-}
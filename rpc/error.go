@@ -3,12 +3,31 @@
 
 package rpc
 
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+)
+
+// The classes of the BasicErrors of the errors of the contexts, which
+// sdkerrors doesn't classify.
+const (
+	errorClassCanceled         = "canceled"
+	errorClassDeadlineExceeded = "deadline_exceeded"
+)
+
 // This is a type that wraps error types so that they can be messaged
 // across RPC channels. Since "error" is an interface, we can't always
 // gob-encode the underlying structure. This is a valid error interface
 // implementer that we will push across.
 type BasicError struct {
 	Message string
+	// Class is the class of the error, restored by Unwrap: the name of its
+	// sdkerrors.Class, or "canceled" and "deadline_exceeded" for the errors
+	// of the contexts. It is empty for the unclassified errors, and the
+	// errors of older versions of the SDK.
+	Class string
 }
 
 func NewBasicError(err error) *BasicError {
@@ -16,9 +35,45 @@ func NewBasicError(err error) *BasicError {
 		return nil
 	}
 
-	return &BasicError{err.Error()}
+	return &BasicError{
+		Message: err.Error(),
+		Class:   errorClass(err),
+	}
 }
 
 func (e *BasicError) Error() string {
 	return e.Message
 }
+
+// Unwrap returns an error of the class of e, with the same message, so that
+// errors.Is and sdkerrors.Classify see the class of the original error.
+func (e *BasicError) Unwrap() error {
+	switch e.Class {
+	case errorClassCanceled:
+		return context.Canceled
+	case errorClassDeadlineExceeded:
+		return context.DeadlineExceeded
+	case sdkerrors.ClassRetryable.String():
+		return &sdkerrors.Retryable{Err: errors.New(e.Message)}
+	case sdkerrors.ClassUser.String():
+		return &sdkerrors.UserError{Err: errors.New(e.Message)}
+	case sdkerrors.ClassFatalInfra.String():
+		return &sdkerrors.FatalInfra{Err: errors.New(e.Message)}
+	}
+	return nil
+}
+
+// errorClass returns the Class of the BasicError of err, classified the way
+// toGRPCStatus does.
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorClassDeadlineExceeded
+	}
+	if class := sdkerrors.Classify(err); class != sdkerrors.ClassUnknown {
+		return class.String()
+	}
+	return ""
+}
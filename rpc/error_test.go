@@ -4,8 +4,12 @@
 package rpc
 
 import (
+	"context"
 	"errors"
 	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
 )
 
 func TestBasicError_ImplementsError(t *testing.T) {
@@ -20,3 +24,32 @@ func TestBasicError_MatchesMessage(t *testing.T) {
 		t.Fatalf("bad: %#v", wrapped.Error())
 	}
 }
+
+func TestBasicErrorClass(t *testing.T) {
+	cases := []struct {
+		err   error
+		check func(error) bool
+	}{
+		{sdkerrors.WrapRetryable(errors.New("foo")), sdkerrors.IsRetryable},
+		{sdkerrors.UserErrorf("foo"), sdkerrors.IsUserError},
+		{sdkerrors.WrapFatalInfra(errors.New("foo")), sdkerrors.IsFatalInfra},
+		{context.Canceled, func(err error) bool { return errors.Is(err, context.Canceled) }},
+		{errors.New("foo"), func(err error) bool { return sdkerrors.Classify(err) == sdkerrors.ClassUnknown }},
+	}
+	for _, tc := range cases {
+		p := new(TestPostProcessor)
+		p.postProcessFn = func(context.Context) error { return tc.err }
+		client, server := testClientServer(t)
+		server.RegisterPostProcessor(p)
+
+		_, _, _, err := client.PostProcessor().PostProcess(context.Background(), new(testUi), new(packersdk.MockArtifact))
+		if !tc.check(err) {
+			t.Errorf("%v: bad class of %#v", tc.err, err)
+		}
+		if err.Error() != tc.err.Error() {
+			t.Errorf("bad message: %q", err.Error())
+		}
+		client.Close()
+		server.Close()
+	}
+}
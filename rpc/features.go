@@ -0,0 +1,172 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"sort"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The features of the net/rpc protocol added after its first version. The
+// features used over a connection are negotiated at handshake, so that Packer
+// and the plugins built with different versions of the SDK can work
+// together: the clients call the methods of the features the other end
+// doesn't support the way older versions of the SDK did.
+const (
+	// FeatureAskOptions is Ui.AskWithOptions.
+	FeatureAskOptions = "ask-options"
+	// FeatureUiFields is Ui.SayWithFields.
+	FeatureUiFields = "ui-fields"
+	// FeatureUiLog is Ui.Log.
+	FeatureUiLog = "ui-log"
+	// FeatureUiSecrets is Ui.RegisterSecrets.
+	FeatureUiSecrets = "ui-secrets"
+	// FeatureUiEvents is Ui.Emit.
+	FeatureUiEvents = "ui-events"
+	// FeatureArtifactStateNames is Artifact.StateNames.
+	FeatureArtifactStateNames = "artifact-state-names"
+	// FeatureLogStream is the stream of the logs of the plugin, see
+	// PluginServer.StreamLogs.
+	FeatureLogStream = "log-stream"
+	// FeatureCompression is the compression of the connection, see
+	// NewCompressedConn. Unlike the other features, it applies to the gRPC
+	// protocol too.
+	FeatureCompression = "compression"
+	// FeatureCancelStream is the cancellation of the calls over a dedicated
+	// stream, see cancel.go.
+	FeatureCancelStream = "cancel-stream"
+	// FeatureLifecycle is the Lifecycle endpoint, see lifecycle.go.
+	FeatureLifecycle = "lifecycle"
+	// FeatureVersions is the versions part of the handshake of the
+	// plugins, see plugin.Handshake.
+	FeatureVersions = "versions"
+	// FeatureArtifactStateStream is the transfer of the state of the
+	// artifacts over a stream, see artifact_state.go.
+	FeatureArtifactStateStream = "artifact-state-stream"
+	// FeatureUiInteractive is Ui.Interactive.
+	FeatureUiInteractive = "ui-interactive"
+	// FeaturePortForward is Communicator.ForwardLocal, see forward.go.
+	FeaturePortForward = "port-forward"
+	// FeatureCrashReport is the CrashReport of the panics of the
+	// components, see crash.go. Like FeatureCompression, it applies to the
+	// gRPC protocol too.
+	FeatureCrashReport = "crash-report"
+	// FeatureCommunicatorSessions is Communicator.NewSession, see
+	// session.go.
+	FeatureCommunicatorSessions = "communicator-sessions"
+)
+
+// SupportedFeatures are the features supported by this version of the SDK.
+var SupportedFeatures = []string{
+	FeatureAskOptions,
+	FeatureUiFields,
+	FeatureUiLog,
+	FeatureUiSecrets,
+	FeatureUiEvents,
+	FeatureArtifactStateNames,
+	FeatureLogStream,
+	FeatureCompression,
+	FeatureCancelStream,
+	FeatureLifecycle,
+	FeatureVersions,
+	FeatureArtifactStateStream,
+	FeatureUiInteractive,
+	FeaturePortForward,
+	FeatureCrashReport,
+	FeatureCommunicatorSessions,
+}
+
+// NegotiateFeatures returns the SupportedFeatures also supported by the other
+// end, sorted.
+func NegotiateFeatures(theirs []string) []string {
+	res := []string{}
+	for _, f := range SupportedFeatures {
+		for _, t := range theirs {
+			if f == t {
+				res = append(res, f)
+				break
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// featureSet is the set of features used over a connection. A nil
+// featureSet has none, which is the case until the features are negotiated:
+// an end that never negotiates them only uses the first version of the
+// protocol.
+type featureSet map[string]bool
+
+func newFeatureSet(features []string) featureSet {
+	res := featureSet{}
+	for _, f := range features {
+		res[f] = true
+	}
+	return res
+}
+
+func (s featureSet) has(feature string) bool {
+	return s[feature]
+}
+
+// SetFeatures sets the features negotiated with the server, usually at
+// handshake. No optional feature is used until it is called.
+func (c *Client) SetFeatures(features []string) {
+	c.mux.setFeatures(features)
+}
+
+// SetFeatures sets the features negotiated with the client, usually at
+// handshake. No optional feature is used until it is called.
+func (s *PluginServer) SetFeatures(features []string) {
+	s.mux.setFeatures(features)
+}
+
+// SetFeatures sets the features negotiated with the client, usually at
+// handshake. No optional feature is used until it is called.
+func (s *GRPCServer) SetFeatures(features []string) {
+	s.peer.setFeatures(features)
+}
+
+// SetFeatures sets the features negotiated with the plugin, usually at
+// handshake. No optional feature is used until it is called.
+func (c *GRPCClient) SetFeatures(features []string) {
+	c.peer.setFeatures(features)
+}
+
+func (p *grpcPeer) setFeatures(features []string) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.features = newFeatureSet(features)
+}
+
+// has returns whether feature is used over the connection.
+func (p *grpcPeer) has(feature string) bool {
+	p.l.Lock()
+	defer p.l.Unlock()
+	return p.features.has(feature)
+}
+
+func (m *muxBroker) setFeatures(features []string) {
+	m.Lock()
+	defer m.Unlock()
+	m.features = newFeatureSet(features)
+}
+
+// has returns whether feature is used over the connection.
+func (m *muxBroker) has(feature string) bool {
+	if m == nil {
+		return false
+	}
+	m.Lock()
+	defer m.Unlock()
+	return m.features.has(feature)
+}
+
+// baseUi hides the optional methods of a Ui, so that the helpers of the
+// packer package call its base methods instead.
+type baseUi struct {
+	packersdk.Ui
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestNegotiateFeatures(t *testing.T) {
+	got := NegotiateFeatures([]string{FeatureUiLog, "future-feature", FeatureAskOptions})
+	if expected := []string{FeatureAskOptions, FeatureUiLog}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := NegotiateFeatures(nil); len(got) != 0 {
+		t.Fatalf("expected no features, got %v", got)
+	}
+}
+
+func TestFeatures_notNegotiated(t *testing.T) {
+	if featureSet(nil).has(FeatureUiLog) {
+		t.Fatal("no feature should be used before they are negotiated")
+	}
+
+	clientConn, serverConn := testConn(t)
+	server, err := NewServer(serverConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer server.Close()
+	go server.Serve()
+	client, err := NewClient(clientConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer client.Close()
+	ui := new(testUi)
+	server.RegisterUi(ui)
+
+	uiClient := client.Ui()
+	packersdk.UiLog(uiClient, packersdk.UiLevelWarn, "warn")
+	packersdk.RegisterSecrets(uiClient, "secret")
+	if ui.sayMessage != "warn" || len(ui.secrets) != 0 {
+		t.Fatalf("expected the first version of the protocol to be used, got %#v", ui)
+	}
+}
+
+func TestUiRPC_olderServer(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	ui := new(testUi)
+	server.RegisterUi(ui)
+	client.SetFeatures([]string{})
+
+	uiClient := client.Ui()
+	packersdk.UiLog(uiClient, packersdk.UiLevelWarn, "warn")
+	packersdk.UiLog(uiClient, packersdk.UiLevelError, "error")
+	if ui.sayMessage != "warn" || ui.errorMessage != "error" {
+		t.Fatalf("expected the logs to fall back on Say and Error, got %#v", ui)
+	}
+
+	packersdk.SayWithFields(uiClient, "say", map[string]interface{}{"a": 1})
+	if ui.sayMessage != "say" {
+		t.Fatalf("expected the message to be said, got %q", ui.sayMessage)
+	}
+
+	if _, err := packersdk.AskWithOptions(uiClient, "query", packersdk.AskOptions{}); err != nil || ui.askQuery != "query" {
+		t.Fatalf("expected the question to fall back on Ask, got %v", err)
+	}
+
+	packersdk.RegisterSecrets(uiClient, "secret")
+	packersdk.EmitEvent(uiClient, packersdk.Event{Type: packersdk.EventBuildStarted})
+	if len(ui.secrets) != 0 || len(ui.events) != 0 {
+		t.Fatalf("expected no call to the older server, got %#v", ui)
+	}
+}
+
+func TestArtifactRPC_olderServer(t *testing.T) {
+	a := &packersdk.MockArtifact{StateValues: map[string]interface{}{"zone": "a"}}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+	client.SetFeatures(nil)
+
+	if names := client.Artifact().(packersdk.ArtifactStateLister).StateNames(); names != nil {
+		t.Fatalf("expected no state names, got %#v", names)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The port forwards of the communicators served over RPC listen on the end
+// calling ForwardLocal. Each connection they accept is forwarded by a call,
+// over a stream of the connection, to the end serving the communicator,
+// which dials the remote address with a port forward of its communicator.
+
+type CommunicatorForwardArgs struct {
+	RemoteAddr string
+	StreamId   uint32
+}
+
+// ForwardLocal listens on localAddr, and forwards the connections it accepts
+// to remoteAddr, dialed from the machine by the communicator on the other
+// end.
+func (c *communicator) ForwardLocal(localAddr string, remoteAddr string) (packersdk.PortForward, error) {
+	if !c.mux.has(FeaturePortForward) {
+		return nil, fmt.Errorf("the plugin can't forward ports")
+	}
+	return listenForward(localAddr, func(conn net.Conn) {
+		args := CommunicatorForwardArgs{
+			RemoteAddr: remoteAddr,
+			StreamId:   c.mux.NextId(),
+		}
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			stream, err := c.mux.Accept(args.StreamId)
+			if err != nil {
+				stream = nil
+			}
+			accepted <- stream
+		}()
+		if err := c.client.Call(c.endpoint+".ForwardLocal", &args, new(interface{})); err != nil {
+			log.Printf("[ERR] Error forwarding a connection to %s: %s", remoteAddr, err)
+			conn.Close()
+			return
+		}
+		stream := <-accepted
+		if stream == nil {
+			conn.Close()
+			return
+		}
+		pipeConns(conn, &frameConn{Conn: stream})
+	})
+}
+
+// ForwardRemote isn't supported over RPC.
+func (c *communicator) ForwardRemote(remoteAddr string, localAddr string) (packersdk.PortForward, error) {
+	return nil, fmt.Errorf("remote port forwards aren't supported over RPC")
+}
+
+func (c *CommunicatorServer) ForwardLocal(args *CommunicatorForwardArgs, reply *interface{}) error {
+	guest, err := dialForward(c.c, args.RemoteAddr)
+	if err != nil {
+		return NewBasicError(err)
+	}
+	stream, err := c.mux.Dial(args.StreamId)
+	if err != nil {
+		guest.Close()
+		return NewBasicError(err)
+	}
+	go pipeConns(guest, &frameConn{Conn: stream})
+	return nil
+}
+
+// listenForward listens on localAddr and forwards the connections accepted
+// with forward, until the listener returned is closed.
+func listenForward(localAddr string, forward func(net.Conn)) (packersdk.PortForward, error) {
+	l, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go forward(conn)
+		}
+	}()
+	return l, nil
+}
+
+// dialForward dials remoteAddr from the machine of comm, through a port
+// forward closed with the connection returned.
+func dialForward(comm packersdk.Communicator, remoteAddr string) (net.Conn, error) {
+	pf, ok := comm.(packersdk.PortForwarder)
+	if !ok {
+		return nil, fmt.Errorf("the communicator can't forward ports")
+	}
+	f, err := pf.ForwardLocal("127.0.0.1:0", remoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error forwarding %s: %s", remoteAddr, err)
+	}
+	conn, err := net.Dial("tcp", f.Addr().String())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &forwardConn{Conn: conn, forward: f}, nil
+}
+
+// forwardConn is a connection through a port forward of its own.
+type forwardConn struct {
+	net.Conn
+	forward packersdk.PortForward
+}
+
+func (c *forwardConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+func (c *forwardConn) Close() error {
+	defer c.forward.Close()
+	return c.Conn.Close()
+}
+
+// frameConn frames the data written to a stream of the connection, so that
+// the end of the data can be sent with CloseWrite: the streams can't be
+// closed for writing only, their reads end once they are closed.
+type frameConn struct {
+	net.Conn
+	left uint32
+}
+
+func (c *frameConn) Read(b []byte) (int, error) {
+	if c.left == 0 {
+		if err := binary.Read(c.Conn, binary.BigEndian, &c.left); err != nil {
+			return 0, err
+		}
+		if c.left == 0 {
+			return 0, io.EOF
+		}
+	}
+	if uint32(len(b)) > c.left {
+		b = b[:c.left]
+	}
+	n, err := c.Conn.Read(b)
+	c.left -= uint32(n)
+	return n, err
+}
+
+func (c *frameConn) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if err := binary.Write(c.Conn, binary.BigEndian, uint32(len(b))); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// CloseWrite sends an empty frame.
+func (c *frameConn) CloseWrite() error {
+	return binary.Write(c.Conn, binary.BigEndian, uint32(0))
+}
+
+// pipeConns copies the data of a and b to each other, then closes them.
+// The end of the data of one is forwarded to the other by closing it for
+// writing, or closing it when it can't.
+func pipeConns(a, b io.ReadWriteCloser) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipe := func(dst, src io.ReadWriteCloser) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go pipe(a, b)
+	go pipe(b, a)
+	wg.Wait()
+	a.Close()
+	b.Close()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// forwardCommunicator forwards the ports to the addresses of the local host.
+type forwardCommunicator struct {
+	*packersdk.MockCommunicator
+}
+
+func (c *forwardCommunicator) ForwardLocal(localAddr string, remoteAddr string) (packersdk.PortForward, error) {
+	return listenForward(localAddr, func(conn net.Conn) {
+		remote, err := net.Dial("tcp", remoteAddr)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		pipeConns(conn, remote)
+	})
+}
+
+func (c *forwardCommunicator) ForwardRemote(remoteAddr string, localAddr string) (packersdk.PortForward, error) {
+	return nil, io.EOF
+}
+
+// testEchoServer returns the address of a server echoing the data of the
+// connections it accepts.
+func testEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func testForward(t *testing.T, comm packersdk.Communicator) {
+	pf, ok := comm.(packersdk.PortForwarder)
+	if !ok {
+		t.Fatal("should be a PortForwarder")
+	}
+	f, err := pf.ForwardLocal("127.0.0.1:0", testEchoServer(t))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	conn, err := net.Dial("tcp", f.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.(*net.TCPConn).CloseWrite()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestCommunicatorRPC_forward(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(&forwardCommunicator{new(packersdk.MockCommunicator)})
+
+	testForward(t, client.Communicator())
+}
+
+func TestCommunicatorRPC_forwardUnsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(&forwardCommunicator{new(packersdk.MockCommunicator)})
+	client.SetFeatures(nil)
+
+	pf := client.Communicator().(packersdk.PortForwarder)
+	if _, err := pf.ForwardLocal("127.0.0.1:0", "127.0.0.1:22"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGRPCCommunicator_forward(t *testing.T) {
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	c := &forwardCommunicator{new(packersdk.MockCommunicator)}
+	testForward(t, &grpcCommunicator{peer: client.peer, id: server.peer.export(c)})
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// An implementation of packer.Function where the function is actually
+// executed over an RPC connection. Like the values of the data sources, the
+// cty types and values are gob encoded.
+type function struct {
+	commonClient
+}
+
+type FunctionSignatureResponse struct {
+	Signature []byte
+}
+
+func (f *function) Signature() packer.FunctionSignature {
+	resp := new(FunctionSignatureResponse)
+	if err := f.client.Call(f.endpoint+".Signature", new(interface{}), resp); err != nil {
+		err := fmt.Errorf("Function.Signature failed: %v", err)
+		panic(err.Error())
+	}
+	var res packer.FunctionSignature
+	if err := decodeGob(resp.Signature, &res); err != nil {
+		panic(fmt.Sprintf("Function.Signature failed: %v", err))
+	}
+	return res
+}
+
+type FunctionCallArgs struct {
+	Args []byte
+}
+
+type FunctionCallResponse struct {
+	Value []byte
+	Error *BasicError
+}
+
+func (f *function) Call(args []cty.Value) (cty.Value, error) {
+	b := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(b).Encode(args); err != nil {
+		return cty.NilVal, err
+	}
+	resp := new(FunctionCallResponse)
+	if err := f.call("Call", &FunctionCallArgs{Args: b.Bytes()}, resp); err != nil {
+		return cty.NilVal, fmt.Errorf("Function.Call failed: %w", err)
+	}
+	if resp.Error != nil {
+		return cty.NilVal, resp.Error
+	}
+	var res cty.Value
+	if err := decodeGob(resp.Value, &res); err != nil {
+		return cty.NilVal, err
+	}
+	return res, nil
+}
+
+// FunctionServer wraps a packer.Function implementation and makes it
+// exportable as part of a Golang RPC server.
+type FunctionServer struct {
+	f   packer.Function
+	mux *muxBroker
+}
+
+func (f *FunctionServer) Signature(args *interface{}, reply *FunctionSignatureResponse) error {
+	b := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(b).Encode(f.f.Signature())
+	reply.Signature = b.Bytes()
+	return err
+}
+
+func (f *FunctionServer) Call(args *FunctionCallArgs, reply *FunctionCallResponse) (err error) {
+	defer recoverCrash(f.mux, DefaultFunctionEndpoint, "Call", nil, &err)
+	var values []cty.Value
+	if err := decodeGob(args.Args, &values); err != nil {
+		return err
+	}
+	v, err := f.f.Call(values)
+	if err != nil {
+		reply.Error = NewBasicError(err)
+		return nil
+	}
+	b := bytes.NewBuffer(nil)
+	err = gob.NewEncoder(b).Encode(v)
+	reply.Value = b.Bytes()
+	return err
+}
@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFunction(t *testing.T) {
+	f := new(packer.MockFunction)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterFunction(f)
+	fClient := client.Function()
+
+	if sig := fClient.Signature(); !reflect.DeepEqual(sig, f.Signature()) {
+		t.Fatalf("bad signature: %#v", sig)
+	}
+
+	args := []cty.Value{cty.StringVal("-"), cty.StringVal("a"), cty.StringVal("b")}
+	v, err := fClient.Call(args)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !f.CallCalled {
+		t.Fatal("call should be called")
+	}
+	if !v.RawEquals(cty.StringVal("a-b")) {
+		t.Fatalf("bad value: %#v", v)
+	}
+}
+
+func TestFunction_Implements(t *testing.T) {
+	var _ packer.Function = new(function)
+}
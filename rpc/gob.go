@@ -0,0 +1,534 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Some messages are gob-encoded, like the specs of the configurations, the
+// values of the functions and the datasources, or the exit status of the
+// remote commands. They are decoded with decodeGob, which bounds their size
+// and only accepts, as the interface values they hold, the types registered
+// with RegisterGobType: gob decodes the types registered by any package, and
+// a compromised or buggy peer must not make the other end allocate without
+// bounds or decode types it doesn't expect. The types are checked by walking
+// the message before it is decoded, so that no value of another type is ever
+// allocated. The values decoding themselves, like the cty values, are
+// checked by their own decoders.
+//
+// The other messages, encoded by the msgpack codec of the net/rpc
+// connections, aren't covered: they are decoded into the concrete types of
+// the arguments and replies of the methods.
+
+// MaxGobMessageSize is the size of the largest gob message decoded from
+// the other end, in bytes.
+var MaxGobMessageSize = 16 << 20
+
+// ErrGobMessageTooLarge is returned when decoding a gob message larger than
+// MaxGobMessageSize.
+var ErrGobMessageTooLarge = errors.New("gob message too large")
+
+var (
+	gobTypesMu sync.RWMutex
+	gobTypes   = make(map[string]bool)
+)
+
+// RegisterGobType registers the type of value with gob.Register, and allows
+// decoding it as an interface value of the messages from the other end.
+func RegisterGobType(value interface{}) {
+	gob.Register(value)
+	gobTypesMu.Lock()
+	defer gobTypesMu.Unlock()
+	gobTypes[gobName(reflect.TypeOf(value))] = true
+}
+
+// gobName returns the name gob.Register gives to t, which identifies the
+// type of the interface values in the messages.
+func gobName(t reflect.Type) string {
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+
+// basicGobTypes are the names of the predeclared types, like int or string,
+// which are always allowed.
+var basicGobTypes = map[string]bool{}
+
+func init() {
+	for _, v := range []interface{}{
+		false, "",
+		int(0), int8(0), int16(0), int32(0), int64(0),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0), uintptr(0),
+		float32(0), float64(0), complex64(0), complex128(0),
+	} {
+		basicGobTypes[gobName(reflect.TypeOf(v))] = true
+	}
+}
+
+func gobTypeAllowed(name string) bool {
+	if basicGobTypes[name] {
+		return true
+	}
+	gobTypesMu.RLock()
+	defer gobTypesMu.RUnlock()
+	return gobTypes[name]
+}
+
+// decodeGob decodes the gob message b into v.
+func decodeGob(b []byte, v interface{}) error {
+	if len(b) > MaxGobMessageSize {
+		return fmt.Errorf("%w: %d bytes, the maximum is %d", ErrGobMessageTooLarge, len(b), MaxGobMessageSize)
+	}
+	return decodeGobFrom(bytes.NewReader(b), v)
+}
+
+// decodeGobStream decodes the gob message read from r into v, reading at
+// most MaxGobMessageSize bytes.
+func decodeGobStream(r io.Reader, v interface{}) error {
+	lr := &io.LimitedReader{R: r, N: int64(MaxGobMessageSize) + 1}
+	err := decodeGobFrom(lr, v)
+	if lr.N <= 0 {
+		return fmt.Errorf("%w: more than %d bytes", ErrGobMessageTooLarge, MaxGobMessageSize)
+	}
+	return err
+}
+
+// decodeGobFrom checks the types of the message read from r with a
+// gobScanner, then decodes the bytes it read into v.
+func decodeGobFrom(r io.Reader, v interface{}) error {
+	var message bytes.Buffer
+	s := &gobScanner{r: io.TeeReader(r, &message), types: map[int64]*gobWireType{}}
+	if err := s.scan(); err != nil {
+		return err
+	}
+	return gob.NewDecoder(&message).Decode(v)
+}
+
+// The ids of the types predeclared by gob.
+const (
+	gobBool      = 1
+	gobInt       = 2
+	gobUint      = 3
+	gobFloat     = 4
+	gobBytes     = 5
+	gobString    = 6
+	gobComplex   = 7
+	gobInterface = 8
+
+	gobFirstUserID = 64
+)
+
+// maxGobDepth bounds the nesting of the values of a message.
+const maxGobDepth = 1000
+
+// The kinds of the types defined in a message.
+const (
+	gobArray = iota + 1
+	gobSlice
+	gobStruct
+	gobMap
+	gobEncoded
+)
+
+// gobWireType is a type defined in a message.
+type gobWireType struct {
+	kind   int
+	key    int64
+	elem   int64
+	fields []int64
+}
+
+// gobScanner walks a gob message the way the gob.Decoder decodes it,
+// without allocating its values, and returns an error when it holds an
+// interface value of a type that isn't allowed.
+type gobScanner struct {
+	r     io.Reader
+	buf   []byte
+	types map[int64]*gobWireType
+	depth int
+}
+
+var errGobCorrupted = errors.New("corrupted gob message")
+
+func (s *gobScanner) scan() error {
+	id, err := s.typeSequence(false)
+	if err != nil {
+		return err
+	}
+	return s.value(id)
+}
+
+// recvMessage reads the next count-delimited part of the message.
+func (s *gobScanner) recvMessage() error {
+	var b [9]byte
+	if _, err := io.ReadFull(s.r, b[:1]); err != nil {
+		return err
+	}
+	n := uint64(b[0])
+	if n > 0x7f {
+		l := -int(int8(b[0]))
+		if l > 8 {
+			return errGobCorrupted
+		}
+		if _, err := io.ReadFull(s.r, b[1:1+l]); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		n = 0
+		for _, c := range b[1 : 1+l] {
+			n = n<<8 | uint64(c)
+		}
+	}
+	if n > uint64(MaxGobMessageSize) {
+		return fmt.Errorf("%w: %d bytes, the maximum is %d", ErrGobMessageTooLarge, n, MaxGobMessageSize)
+	}
+	s.buf = make([]byte, n)
+	if _, err := io.ReadFull(s.r, s.buf); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (s *gobScanner) uint() (uint64, error) {
+	if len(s.buf) == 0 {
+		return 0, errGobCorrupted
+	}
+	c := s.buf[0]
+	s.buf = s.buf[1:]
+	if c <= 0x7f {
+		return uint64(c), nil
+	}
+	l := -int(int8(c))
+	if l > 8 || l > len(s.buf) {
+		return 0, errGobCorrupted
+	}
+	var n uint64
+	for _, c := range s.buf[:l] {
+		n = n<<8 | uint64(c)
+	}
+	s.buf = s.buf[l:]
+	return n, nil
+}
+
+func (s *gobScanner) int() (int64, error) {
+	u, err := s.uint()
+	if u&1 != 0 {
+		return ^int64(u >> 1), err
+	}
+	return int64(u >> 1), err
+}
+
+func (s *gobScanner) bytes() ([]byte, error) {
+	n, err := s.uint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(s.buf)) {
+		return nil, errGobCorrupted
+	}
+	b := s.buf[:n]
+	s.buf = s.buf[n:]
+	return b, nil
+}
+
+// typeSequence reads the types defined before a value, and returns the type
+// of the value.
+func (s *gobScanner) typeSequence(isInterface bool) (int64, error) {
+	for {
+		if len(s.buf) == 0 {
+			if err := s.recvMessage(); err != nil {
+				return 0, err
+			}
+		}
+		id, err := s.int()
+		if err != nil {
+			return 0, err
+		}
+		if id >= 0 {
+			return id, nil
+		}
+		if err := s.recvType(-id); err != nil {
+			return 0, err
+		}
+		if len(s.buf) > 0 {
+			if !isInterface {
+				return 0, errGobCorrupted
+			}
+			// The count of the value follows the type in interfaces.
+			if _, err := s.uint(); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// fields reads the fields of a struct, calling field with the number of
+// each one of them.
+func (s *gobScanner) fields(field func(n int) error) error {
+	n := -1
+	for {
+		delta, err := s.uint()
+		if err != nil {
+			return err
+		}
+		if delta == 0 {
+			return nil
+		}
+		if delta > math.MaxInt32 {
+			return errGobCorrupted
+		}
+		n += int(delta)
+		if err := field(n); err != nil {
+			return err
+		}
+	}
+}
+
+// recvType reads the definition of the type id, a wireType of gob.
+func (s *gobScanner) recvType(id int64) error {
+	if id < gobFirstUserID || s.types[id] != nil {
+		return errGobCorrupted
+	}
+	w := new(gobWireType)
+	commonType := func(n int) error {
+		switch n {
+		case 0:
+			_, err := s.bytes()
+			return err
+		case 1:
+			_, err := s.int()
+			return err
+		}
+		return errGobCorrupted
+	}
+	typeID := func(id *int64) error {
+		var err error
+		*id, err = s.int()
+		return err
+	}
+	err := s.fields(func(n int) error {
+		if w.kind != 0 {
+			return errGobCorrupted
+		}
+		switch n {
+		case 0:
+			w.kind = gobArray
+			return s.fields(func(n int) error {
+				switch n {
+				case 0:
+					return s.fields(commonType)
+				case 1:
+					return typeID(&w.elem)
+				case 2:
+					_, err := s.int()
+					return err
+				}
+				return errGobCorrupted
+			})
+		case 1:
+			w.kind = gobSlice
+			return s.fields(func(n int) error {
+				switch n {
+				case 0:
+					return s.fields(commonType)
+				case 1:
+					return typeID(&w.elem)
+				}
+				return errGobCorrupted
+			})
+		case 2:
+			w.kind = gobStruct
+			return s.fields(func(n int) error {
+				switch n {
+				case 0:
+					return s.fields(commonType)
+				case 1:
+					count, err := s.uint()
+					if err != nil {
+						return err
+					}
+					if count > uint64(len(s.buf)) {
+						return errGobCorrupted
+					}
+					w.fields = make([]int64, count)
+					for i := range w.fields {
+						err := s.fields(func(n int) error {
+							switch n {
+							case 0:
+								_, err := s.bytes()
+								return err
+							case 1:
+								return typeID(&w.fields[i])
+							}
+							return errGobCorrupted
+						})
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+				return errGobCorrupted
+			})
+		case 3:
+			w.kind = gobMap
+			return s.fields(func(n int) error {
+				switch n {
+				case 0:
+					return s.fields(commonType)
+				case 1:
+					return typeID(&w.key)
+				case 2:
+					return typeID(&w.elem)
+				}
+				return errGobCorrupted
+			})
+		case 4, 5, 6:
+			// GobEncoder, BinaryMarshaler and TextMarshaler.
+			w.kind = gobEncoded
+			return s.fields(func(n int) error {
+				if n != 0 {
+					return errGobCorrupted
+				}
+				return s.fields(commonType)
+			})
+		}
+		return errGobCorrupted
+	})
+	if err != nil {
+		return err
+	}
+	if w.kind == 0 {
+		return errGobCorrupted
+	}
+	s.types[id] = w
+	return nil
+}
+
+// value reads a value of type id sent on its own, at the top of the message
+// or in an interface.
+func (s *gobScanner) value(id int64) error {
+	if w := s.types[id]; w != nil && w.kind == gobStruct {
+		return s.field(id)
+	}
+	// Other values are sent as the only field of a struct.
+	if delta, err := s.uint(); err != nil || delta != 0 {
+		return errGobCorrupted
+	}
+	return s.field(id)
+}
+
+// field reads a value of type id.
+func (s *gobScanner) field(id int64) error {
+	s.depth++
+	defer func() { s.depth-- }()
+	if s.depth > maxGobDepth {
+		return errGobCorrupted
+	}
+
+	switch id {
+	case gobBool, gobUint, gobFloat:
+		_, err := s.uint()
+		return err
+	case gobInt:
+		_, err := s.int()
+		return err
+	case gobBytes, gobString:
+		_, err := s.bytes()
+		return err
+	case gobComplex:
+		if _, err := s.uint(); err != nil {
+			return err
+		}
+		_, err := s.uint()
+		return err
+	case gobInterface:
+		return s.interfaceValue()
+	}
+
+	w := s.types[id]
+	if w == nil {
+		return errGobCorrupted
+	}
+	switch w.kind {
+	case gobStruct:
+		return s.fields(func(n int) error {
+			if n >= len(w.fields) {
+				return errGobCorrupted
+			}
+			return s.field(w.fields[n])
+		})
+	case gobArray, gobSlice, gobMap:
+		// Every element takes at least a byte, so that the count can't
+		// make this loop longer than the message.
+		count, err := s.uint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < count; i++ {
+			if w.kind == gobMap {
+				if err := s.field(w.key); err != nil {
+					return err
+				}
+			}
+			if err := s.field(w.elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		_, err := s.bytes()
+		return err
+	}
+}
+
+// interfaceValue reads an interface value, which is named after its type.
+func (s *gobScanner) interfaceValue() error {
+	name, err := s.bytes()
+	if err != nil || len(name) == 0 {
+		return err
+	}
+	if !gobTypeAllowed(string(name)) {
+		return fmt.Errorf("unexpected type %s in gob message", name)
+	}
+	id, err := s.typeSequence(true)
+	if err != nil {
+		return err
+	}
+	if _, err := s.uint(); err != nil {
+		return err
+	}
+	return s.value(id)
+}
+func init() {
+	RegisterGobType(new(map[string]string))
+	RegisterGobType(make([]interface{}, 0))
+	RegisterGobType(new(BasicError))
+	RegisterGobType(new(cty.Value))
+
+	// The specs sent by the components, see hcldec/gob.go.
+	RegisterGobType(hcldec.ObjectSpec(nil))
+	RegisterGobType(hcldec.TupleSpec(nil))
+	RegisterGobType(new(hcldec.AttrSpec))
+	RegisterGobType(new(hcldec.LiteralSpec))
+	RegisterGobType(new(hcldec.BlockSpec))
+	RegisterGobType(new(hcldec.BlockListSpec))
+	RegisterGobType(new(hcldec.BlockSetSpec))
+	RegisterGobType(new(hcldec.BlockMapSpec))
+	RegisterGobType(new(hcldec.BlockLabelSpec))
+	RegisterGobType(new(hcldec.BlockAttrsSpec))
+	RegisterGobType(new(hcldec.BlockObjectSpec))
+	RegisterGobType(new(hcldec.DefaultSpec))
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// unexpectedGobType is registered with gob only, not allowed.
+type unexpectedGobType struct {
+	Size int
+}
+
+// decodedGobType counts its decodings, and is registered with gob only.
+type decodedGobType struct{}
+
+var gobDecodings int
+
+func (decodedGobType) GobEncode() ([]byte, error) { return []byte("x"), nil }
+
+func (*decodedGobType) GobDecode([]byte) error {
+	gobDecodings++
+	return nil
+}
+
+func init() {
+	gob.Register(new(unexpectedGobType))
+	gob.Register(new(decodedGobType))
+}
+
+func testEncodeGob(t *testing.T, v interface{}) []byte {
+	b := new(bytes.Buffer)
+	if err := gob.NewEncoder(b).Encode(v); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return b.Bytes()
+}
+
+func TestDecodeGob(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: true},
+		"block": &hcldec.BlockSpec{TypeName: "block", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		}},
+	}
+	res := hcldec.ObjectSpec{}
+	if err := decodeGob(testEncodeGob(t, spec), &res); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(res, spec) {
+		t.Fatalf("bad spec: %#v", res)
+	}
+
+	var values []interface{}
+	b := testEncodeGob(t, []interface{}{"a", 1, new(unexpectedGobType)})
+	if err := decodeGob(b, &values); err == nil || !strings.Contains(err.Error(), "unexpected type") {
+		t.Fatalf("expected an unexpected type error, got %v", err)
+	}
+	if err := decodeGobStream(bytes.NewReader(b), &values); err == nil {
+		t.Fatal("expected an unexpected type error")
+	}
+}
+
+func TestDecodeGob_notDecoded(t *testing.T) {
+	gobDecodings = 0
+	var values []interface{}
+	b := testEncodeGob(t, []interface{}{new(BasicError), new(decodedGobType)})
+	if err := decodeGob(b, &values); err == nil || !strings.Contains(err.Error(), "unexpected type") {
+		t.Fatalf("expected an unexpected type error, got %v", err)
+	}
+	if gobDecodings != 0 {
+		t.Fatal("the unexpected type should not be decoded")
+	}
+}
+
+func TestDecodeGob_types(t *testing.T) {
+	// The types are defined in the middle of the values.
+	v := []interface{}{
+		"a", 1,
+		&BasicError{Message: "error"},
+		&map[string]string{"a": "b"},
+		hcldec.TupleSpec{&hcldec.LiteralSpec{Value: cty.True}},
+		cty.StringVal("value"),
+		[]interface{}{nil, &hcldec.AttrSpec{Name: "attr", Type: cty.List(cty.String)}},
+	}
+	var res []interface{}
+	if err := decodeGob(testEncodeGob(t, v), &res); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(res) != len(v) || res[0] != "a" || res[2].(*BasicError).Message != "error" {
+		t.Fatalf("bad values: %#v", res)
+	}
+
+	if err := decodeGob([]byte{0x03, 0xff, 0x80, 0x00}, &res); err == nil {
+		t.Fatal("expected an error for a corrupted message")
+	}
+}
+
+func TestDecodeGob_tooLarge(t *testing.T) {
+	defer func(size int) { MaxGobMessageSize = size }(MaxGobMessageSize)
+	MaxGobMessageSize = 64
+
+	b := testEncodeGob(t, strings.Repeat("x", 100))
+	var s string
+	if err := decodeGob(b, &s); !errors.Is(err, ErrGobMessageTooLarge) {
+		t.Fatalf("expected ErrGobMessageTooLarge, got %v", err)
+	}
+	if err := decodeGobStream(bytes.NewReader(b), &s); !errors.Is(err, ErrGobMessageTooLarge) {
+		t.Fatalf("expected ErrGobMessageTooLarge, got %v", err)
+	}
+
+	b = testEncodeGob(t, &CommandFinished{ExitStatus: 1})
+	var finished CommandFinished
+	if err := decodeGobStream(bytes.NewReader(b), &finished); err != nil || finished.ExitStatus != 1 {
+		t.Fatalf("bad decode: %#v, %v", finished, err)
+	}
+}
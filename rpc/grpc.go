@@ -0,0 +1,546 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// The gRPC protocol, defined in proto/plugin.proto, is an alternative to the
+// net/rpc protocol negotiated at handshake. Its messages can be generated in
+// any language, so that plugins don't have to be written in Go.
+//
+// Like with net/rpc, the connection between Packer and the plugin is
+// multiplexed with yamux. Both ends serve gRPC on the streams opened by the
+// other end, and call it on the streams they open. The objects passed as
+// arguments, like the Ui, or returned, like the artifacts, are served by the
+// end they come from under an ID, and called by the other end with it.
+
+// grpcPeer is an end of a gRPC plugin connection.
+type grpcPeer struct {
+	session *yamux.Session
+	server  *grpc.Server
+	conn    *grpc.ClientConn
+
+	l       sync.Mutex
+	nextID  uint32
+	objects map[uint32]interface{}
+	logs    LogHandler
+
+	// features are the features negotiated over the connection.
+	features featureSet
+
+	// calls are the calls served, drained at shutdown.
+	calls    callSet
+	nextCall uint32
+	// limiter limits the calls served, see limits.go.
+	limiter *callLimiter
+
+	// specs caches the specs of the components called, and servedSpecs
+	// the ones served, see spec_cache.go.
+	specs       specCache
+	servedSpecs specCache
+}
+
+func newGRPCPeer(session *yamux.Session) (*grpcPeer, error) {
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return session.Open()
+		}),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(pbCodec{})),
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.ForceServerCodec(pbCodec{}),
+	}
+	if observe := callObserver(); observe != nil {
+		dialOpts = append(dialOpts, grpc.WithStatsHandler(grpcStatsHandler{observe}))
+		serverOpts = append(serverOpts, grpc.StatsHandler(grpcStatsHandler{observe}))
+	}
+	conn, err := grpc.Dial("packer-plugin", dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &grpcPeer{
+		session: session,
+		conn:    conn,
+		objects: make(map[uint32]interface{}),
+		logs:    DefaultLogHandler,
+	}
+	p.server = grpc.NewServer(append(serverOpts,
+		grpc.UnaryInterceptor(p.trackUnary),
+		grpc.StreamInterceptor(p.trackStream),
+	)...)
+	p.server.RegisterService(&grpcUiService, p)
+	p.server.RegisterService(&grpcHookService, p)
+	p.server.RegisterService(&grpcArtifactService, p)
+	p.server.RegisterService(&grpcCommunicatorService, p)
+	p.server.RegisterService(&grpcLogService, p)
+	return p, nil
+}
+
+// serve serves gRPC until the connection is closed.
+func (p *grpcPeer) serve() {
+	if err := p.server.Serve(p.session); err != nil && !p.session.IsClosed() {
+		log.Printf("[ERR] Error serving gRPC: %s", err)
+	}
+}
+
+func (p *grpcPeer) Close() error {
+	p.conn.Close()
+	p.server.Stop()
+	return p.session.Close()
+}
+
+// export serves v to the other end, returning its ID.
+func (p *grpcPeer) export(v interface{}) uint32 {
+	if v == nil {
+		return 0
+	}
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.nextID++
+	p.objects[p.nextID] = v
+	return p.nextID
+}
+
+func (p *grpcPeer) unexport(id uint32) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	delete(p.objects, id)
+}
+
+func (p *grpcPeer) object(id uint32) (interface{}, error) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	v, ok := p.objects[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no object with ID %d", id)
+	}
+	return v, nil
+}
+
+func (p *grpcPeer) ui(id uint32) (packer.Ui, error) {
+	v, err := p.object(id)
+	if err != nil {
+		return nil, err
+	}
+	ui, ok := v.(packer.Ui)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "object %d is not a Ui", id)
+	}
+	return ui, nil
+}
+
+func (p *grpcPeer) hook(id uint32) (packer.Hook, error) {
+	v, err := p.object(id)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := v.(packer.Hook)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "object %d is not a Hook", id)
+	}
+	return h, nil
+}
+
+func (p *grpcPeer) artifact(id uint32) (packer.Artifact, error) {
+	v, err := p.object(id)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.(packer.Artifact)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "object %d is not an Artifact", id)
+	}
+	return a, nil
+}
+
+func (p *grpcPeer) communicator(id uint32) (packer.Communicator, error) {
+	v, err := p.object(id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := v.(packer.Communicator)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "object %d is not a Communicator", id)
+	}
+	return c, nil
+}
+
+// trackUnary tracks the unary calls served, but the ones of the Lifecycle
+// service, in the calls of the peer.
+func (p *grpcPeer) trackUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, "/"+grpcLifecycleName+"/") {
+		return handler(ctx, req)
+	}
+	if err := p.limiter.acquire(ctx); err != nil {
+		return nil, toGRPCStatus(err)
+	}
+	defer p.limiter.release()
+	ctx, done := p.calls.start(ctx, atomic.AddUint32(&p.nextCall, 1))
+	defer done()
+	return handler(ctx, req)
+}
+
+// trackStream is trackUnary for the streams.
+func (p *grpcPeer) trackStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := p.limiter.acquire(stream.Context()); err != nil {
+		return toGRPCStatus(err)
+	}
+	defer p.limiter.release()
+	ctx, done := p.calls.start(stream.Context(), atomic.AddUint32(&p.nextCall, 1))
+	defer done()
+	return handler(srv, &grpcServerStream{ServerStream: stream, ctx: ctx})
+}
+
+// grpcServerStream is a grpc.ServerStream with another context.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context { return s.ctx }
+
+// invoke calls method of the other end.
+func (p *grpcPeer) invoke(ctx context.Context, method string, req, resp pbMessage) error {
+	if err := p.conn.Invoke(ctx, method, req, resp); err != nil {
+		return fromGRPCStatus(err)
+	}
+	return nil
+}
+
+// grpcMethod returns the unary method name of service, decoding its request
+// with newReq and handling it with handle.
+func grpcMethod(service, name string, newReq func() pbMessage, handle func(srv interface{}, ctx context.Context, req pbMessage) (pbMessage, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			h := func(ctx context.Context, req interface{}) (resp interface{}, err error) {
+				defer recoverCrash(grpcServerPeer(srv), grpcComponentName(service), name, grpcCrashConfigs(srv), &err)
+				resp, err = handle(srv, ctx, req.(pbMessage))
+				if err != nil {
+					return nil, toGRPCStatus(err)
+				}
+				return resp, nil
+			}
+			if interceptor == nil {
+				return h(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + service + "/" + name}
+			return interceptor(ctx, req, info, h)
+		},
+	}
+}
+
+// grpcComponentName returns the name of the component of service, like
+// "Builder", for the crash reports.
+func grpcComponentName(service string) string {
+	return strings.TrimPrefix(service, "packer.plugin.v1.")
+}
+
+// grpcServerPeer returns the peer serving srv.
+func grpcServerPeer(srv interface{}) *grpcPeer {
+	switch s := srv.(type) {
+	case *grpcPeer:
+		return s
+	case *grpcBuilderServer:
+		return s.peer
+	case *grpcProvisionerServer:
+		return s.peer
+	case *grpcPostProcessorServer:
+		return s.peer
+	case *grpcDatasourceServer:
+		return s.peer
+	case *grpcFunctionServer:
+		return s.peer
+	}
+	return nil
+}
+
+// grpcCrashConfigs returns the configs of the component served by srv, for
+// its crash reports.
+func grpcCrashConfigs(srv interface{}) []interface{} {
+	if c, ok := srv.(interface{ crashConfigs() []interface{} }); ok {
+		return c.crashConfigs()
+	}
+	return nil
+}
+
+// toGRPCStatus returns err as a gRPC status, with a code telling its class.
+func toGRPCStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	default:
+		switch sdkerrors.Classify(err) {
+		case sdkerrors.ClassRetryable:
+			code = codes.Unavailable
+		case sdkerrors.ClassUser:
+			code = codes.InvalidArgument
+		case sdkerrors.ClassFatalInfra:
+			code = codes.FailedPrecondition
+		}
+	}
+	return status.Error(code, err.Error())
+}
+
+// grpcError is an error returned by the other end of a gRPC connection.
+type grpcError struct {
+	message string
+	cause   error
+}
+
+func (e *grpcError) Error() string { return e.message }
+func (e *grpcError) Unwrap() error { return e.cause }
+
+// fromGRPCStatus returns the error of a gRPC status, classified after its
+// code.
+func fromGRPCStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if strings.HasPrefix(st.Message(), crashPrefix) {
+		return decodeCrash(errors.New(st.Message()))
+	}
+	e := &grpcError{message: st.Message()}
+	switch st.Code() {
+	case codes.OK:
+		return nil
+	case codes.Canceled:
+		e.cause = context.Canceled
+	case codes.DeadlineExceeded:
+		e.cause = context.DeadlineExceeded
+	case codes.Unavailable:
+		return sdkerrors.WrapRetryable(e)
+	case codes.InvalidArgument:
+		return sdkerrors.WrapUser(e)
+	case codes.FailedPrecondition:
+		return sdkerrors.WrapFatalInfra(e)
+	}
+	return e
+}
+
+// GRPCServer serves a component of a plugin over the gRPC protocol. It's
+// the gRPC counterpart of PluginServer.
+type GRPCServer struct {
+	peer *grpcPeer
+	logs *logWriter
+}
+
+// NewGRPCServer returns a GRPCServer for the connection of Packer to the
+// plugin.
+func NewGRPCServer(conn io.ReadWriteCloser) (*GRPCServer, error) {
+	session, err := yamux.Server(conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := newGRPCPeer(session)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	s := &GRPCServer{peer: peer}
+	s.SetLimits(DefaultServerLimits)
+	peer.server.RegisterService(&grpcLifecycleService, s)
+	return s, nil
+}
+
+func (s *GRPCServer) RegisterBuilder(b packer.Builder) error {
+	s.peer.server.RegisterService(&grpcBuilderService, &grpcBuilderServer{peer: s.peer, builder: b})
+	return nil
+}
+
+func (s *GRPCServer) RegisterProvisioner(p packer.Provisioner) error {
+	s.peer.server.RegisterService(&grpcProvisionerService, &grpcProvisionerServer{peer: s.peer, p: p})
+	return nil
+}
+
+func (s *GRPCServer) RegisterPostProcessor(p packer.PostProcessor) error {
+	s.peer.server.RegisterService(&grpcPostProcessorService, &grpcPostProcessorServer{peer: s.peer, p: p})
+	return nil
+}
+
+func (s *GRPCServer) RegisterDatasource(d packer.Datasource) error {
+	s.peer.server.RegisterService(&grpcDatasourceService, &grpcDatasourceServer{peer: s.peer, d: d})
+	return nil
+}
+
+func (s *GRPCServer) RegisterFunction(f packer.Function) error {
+	s.peer.server.RegisterService(&grpcFunctionService, &grpcFunctionServer{peer: s.peer, f: f})
+	return nil
+}
+
+// StreamLogs sends the logs of the standard logger to the client, tagged
+// with component.
+func (s *GRPCServer) StreamLogs(component string) {
+	s.logs = streamLogs(component, func() (func(LogEntry) error, error) {
+		stream, err := s.peer.newStream(context.Background(), &grpcLogService.Streams[0], grpcLogName)
+		if err != nil {
+			return nil, err
+		}
+		return func(e LogEntry) error {
+			return stream.SendMsg(&pbLogEntry{
+				Time:      e.Time.UnixNano(),
+				Level:     int32(e.Level),
+				Component: e.Component,
+				Message:   e.Message,
+			})
+		}, nil
+	})
+}
+
+// Shutdown cancels the calls in flight, and the ones made afterwards, waits
+// for them to return, for at most timeout when it's not 0, and flushes the
+// logs, like PluginServer.Shutdown.
+func (s *GRPCServer) Shutdown(timeout time.Duration) error {
+	return shutdown(&s.peer.calls, s.logs, timeout)
+}
+
+// Serve serves the registered component until the connection is closed.
+func (s *GRPCServer) Serve() {
+	s.peer.serve()
+}
+
+func (s *GRPCServer) Close() error {
+	return s.peer.Close()
+}
+
+// GRPCClient calls the component of a plugin over the gRPC protocol. It's the
+// gRPC counterpart of Client.
+type GRPCClient struct {
+	peer *grpcPeer
+}
+
+// NewGRPCClient returns a GRPCClient for the connection to the plugin.
+func NewGRPCClient(conn io.ReadWriteCloser) (*GRPCClient, error) {
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := newGRPCPeer(session)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	go peer.serve()
+	return &GRPCClient{peer: peer}, nil
+}
+
+func (c *GRPCClient) Close() error {
+	return c.peer.Close()
+}
+
+// ServeLogs calls h, instead of DefaultLogHandler, with the logs the server
+// streams.
+func (c *GRPCClient) ServeLogs(h LogHandler) {
+	c.peer.l.Lock()
+	defer c.peer.l.Unlock()
+	c.peer.logs = h
+}
+
+// Health returns the health of the server, like Client.Health.
+func (c *GRPCClient) Health(ctx context.Context) (*Health, error) {
+	var resp pbHealth
+	if err := c.peer.invoke(ctx, "/"+grpcLifecycleName+"/Health", &pbEmpty{}, &resp); err != nil {
+		return nil, err
+	}
+	return &Health{Calls: int(resp.Calls), Draining: resp.Draining}, nil
+}
+
+// Shutdown shuts the server down gracefully, like Client.Shutdown.
+func (c *GRPCClient) Shutdown(ctx context.Context) error {
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return c.peer.invoke(ctx, "/"+grpcLifecycleName+"/Shutdown", &pbShutdownRequest{Timeout: int64(timeout)}, &pbEmpty{})
+}
+
+func (c *GRPCClient) Builder() packer.Builder {
+	return &grpcBuilder{peer: c.peer}
+}
+
+func (c *GRPCClient) Provisioner() packer.Provisioner {
+	return &grpcProvisioner{peer: c.peer}
+}
+
+func (c *GRPCClient) PostProcessor() packer.PostProcessor {
+	return &grpcPostProcessor{peer: c.peer}
+}
+
+func (c *GRPCClient) Datasource() packer.Datasource {
+	return &grpcDatasource{peer: c.peer}
+}
+
+func (c *GRPCClient) Function() packer.Function {
+	return &grpcFunction{peer: c.peer}
+}
+
+// grpcConfigSpec calls the ConfigSpec, or OutputSpec, method of the other
+// end. Like with net/rpc, it panics when the call fails, as the specs can't
+// be returned along with an error.
+func grpcConfigSpec(p *grpcPeer, method string) hcldec.ObjectSpec {
+	spec, err := p.specs.get(method, func() (interface{}, error) {
+		var resp pbConfigSpecResponse
+		if err := p.invoke(context.Background(), method, &pbEmpty{}, &resp); err != nil {
+			return nil, err
+		}
+		return objectSpecFromPB(resp.Spec)
+	})
+	if err != nil {
+		panic(fmt.Sprintf("%s failed: %v", method, err))
+	}
+	return copySpec(spec.(hcldec.ObjectSpec))
+}
+
+// cachedConfigSpecResponse is configSpecResponse for the spec returned by
+// spec, served by p as method.
+func (p *grpcPeer) cachedConfigSpecResponse(method string, spec func() hcldec.ObjectSpec) (pbMessage, error) {
+	resp, err := p.servedSpecs.get(method, func() (interface{}, error) {
+		return configSpecResponse(spec())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(pbMessage), nil
+}
+
+func configSpecResponse(spec hcldec.ObjectSpec) (pbMessage, error) {
+	pb, err := objectSpecToPB(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &pbConfigSpecResponse{Spec: pb}, nil
+}
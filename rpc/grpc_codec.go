@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pbMessage is a message of proto/plugin.proto. The messages are encoded by
+// hand with protowire, which keeps the SDK free of generated code while
+// being compatible with the plugins generating theirs from the proto file.
+// The messages are checked against the proto file by grpc_codec_test.go.
+type pbMessage interface {
+	marshalPB(e *pbEncoder)
+	unmarshalPB(b []byte) error
+}
+
+// pbCodec is the gRPC codec of the pbMessages. It is named after the proto
+// codec as it uses the same wire format.
+type pbCodec struct{}
+
+func (pbCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(pbMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	var e pbEncoder
+	m.marshalPB(&e)
+	return e.b, nil
+}
+
+func (pbCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(pbMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.unmarshalPB(data)
+}
+
+func (pbCodec) Name() string { return "proto" }
+
+// pbEncoder appends the fields of a message. Like proto3 does, the fields
+// with the zero value of their type are not written, except the ones of the
+// repeated and optional fields and the messages.
+type pbEncoder struct {
+	b []byte
+}
+
+func (e *pbEncoder) bytes(num protowire.Number, b []byte) {
+	if len(b) > 0 {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendBytes(e.b, b)
+	}
+}
+
+func (e *pbEncoder) string(num protowire.Number, s string) {
+	if s != "" {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, s)
+	}
+}
+
+func (e *pbEncoder) strings(num protowire.Number, ss []string) {
+	for _, s := range ss {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, s)
+	}
+}
+
+func (e *pbEncoder) bytesList(num protowire.Number, bs [][]byte) {
+	for _, b := range bs {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendBytes(e.b, b)
+	}
+}
+
+func (e *pbEncoder) uint(num protowire.Number, v uint64) {
+	if v != 0 {
+		e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+		e.b = protowire.AppendVarint(e.b, v)
+	}
+}
+
+func (e *pbEncoder) int(num protowire.Number, v int64) {
+	e.uint(num, uint64(v))
+}
+
+func (e *pbEncoder) bool(num protowire.Number, v bool) {
+	if v {
+		e.uint(num, 1)
+	}
+}
+
+func (e *pbEncoder) optionalBool(num protowire.Number, v *bool) {
+	if v != nil {
+		e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+		e.b = protowire.AppendVarint(e.b, protowire.EncodeBool(*v))
+	}
+}
+
+func (e *pbEncoder) message(num protowire.Number, m pbMessage) {
+	var sub pbEncoder
+	m.marshalPB(&sub)
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, sub.b)
+}
+
+// pbValue is the value of a field being decoded.
+type pbValue struct {
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+func (v pbValue) Bytes() []byte {
+	// The buffer of the message can be reused by gRPC.
+	return append([]byte(nil), v.bytes...)
+}
+
+func (v pbValue) String() string  { return string(v.bytes) }
+func (v pbValue) Uint32() uint32  { return uint32(v.varint) }
+func (v pbValue) Int64() int64    { return int64(v.varint) }
+func (v pbValue) Int32() int32    { return int32(v.varint) }
+func (v pbValue) Bool() bool      { return protowire.DecodeBool(v.varint) }
+func (v pbValue) isMessage() bool { return v.typ == protowire.BytesType }
+
+func (v pbValue) Message(m pbMessage) error {
+	if !v.isMessage() {
+		return fmt.Errorf("unexpected wire type %d for a message", v.typ)
+	}
+	return m.unmarshalPB(v.bytes)
+}
+
+// pbDecode calls field for each field of the message b. The fields that
+// aren't varints or bytes, none in plugin.proto, are skipped.
+func pbDecode(b []byte, field func(num protowire.Number, v pbValue) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		v := pbValue{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			v.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := field(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The messages encoded by hand are checked against proto/plugin.proto: each
+// field is encoded alone, decoded as a dynamic message of the descriptor
+// built from the proto file, and encoded back.
+
+// pbMessages are the messages of plugin.proto, by name.
+var pbMessages = map[string]func() pbMessage{
+	"Empty":                       func() pbMessage { return new(pbEmpty) },
+	"Config":                      func() pbMessage { return new(pbConfig) },
+	"ConfigureRequest":            func() pbMessage { return new(pbConfigureRequest) },
+	"PrepareResponse":             func() pbMessage { return new(pbPrepareResponse) },
+	"Spec":                        func() pbMessage { return new(pbSpec) },
+	"ObjectSpec":                  func() pbMessage { return new(pbObjectSpec) },
+	"AttrSpec":                    func() pbMessage { return new(pbAttrSpec) },
+	"BlockSpec":                   func() pbMessage { return new(pbBlockSpec) },
+	"BlockListSpec":               func() pbMessage { return new(pbBlockListSpec) },
+	"BlockAttrsSpec":              func() pbMessage { return new(pbBlockAttrsSpec) },
+	"BlockObjectSpec":             func() pbMessage { return new(pbBlockObjectSpec) },
+	"ConfigSpecResponse":          func() pbMessage { return new(pbConfigSpecResponse) },
+	"BuilderRunRequest":           func() pbMessage { return new(pbBuilderRunRequest) },
+	"ArtifactResponse":            func() pbMessage { return new(pbArtifactResponse) },
+	"ProvisionRequest":            func() pbMessage { return new(pbProvisionRequest) },
+	"PostProcessRequest":          func() pbMessage { return new(pbPostProcessRequest) },
+	"PostProcessResponse":         func() pbMessage { return new(pbPostProcessResponse) },
+	"ExecuteResponse":             func() pbMessage { return new(pbExecuteResponse) },
+	"FunctionSignature":           func() pbMessage { return new(pbFunctionSignature) },
+	"FunctionParameter":           func() pbMessage { return new(pbFunctionParameter) },
+	"FunctionCallRequest":         func() pbMessage { return new(pbFunctionCallRequest) },
+	"UiRequest":                   func() pbMessage { return new(pbUiRequest) },
+	"UiAskRequest":                func() pbMessage { return new(pbUiAskRequest) },
+	"UiInteractiveResponse":       func() pbMessage { return new(pbUiInteractiveResponse) },
+	"UiAskResponse":               func() pbMessage { return new(pbUiAskResponse) },
+	"UiMachineRequest":            func() pbMessage { return new(pbUiMachineRequest) },
+	"UiProgress":                  func() pbMessage { return new(pbUiProgress) },
+	"LogEntry":                    func() pbMessage { return new(pbLogEntry) },
+	"Health":                      func() pbMessage { return new(pbHealth) },
+	"ShutdownRequest":             func() pbMessage { return new(pbShutdownRequest) },
+	"HookRunRequest":              func() pbMessage { return new(pbHookRunRequest) },
+	"ArtifactRequest":             func() pbMessage { return new(pbArtifactRequest) },
+	"ArtifactInfo":                func() pbMessage { return new(pbArtifactInfo) },
+	"ArtifactStateResponse":       func() pbMessage { return new(pbArtifactStateResponse) },
+	"CommunicatorStartRequest":    func() pbMessage { return new(pbCommunicatorStartRequest) },
+	"CommunicatorStartResponse":   func() pbMessage { return new(pbCommunicatorStartResponse) },
+	"FileInfo":                    func() pbMessage { return new(pbFileInfo) },
+	"CommunicatorUploadRequest":   func() pbMessage { return new(pbCommunicatorUploadRequest) },
+	"CommunicatorDirRequest":      func() pbMessage { return new(pbCommunicatorDirRequest) },
+	"CommunicatorDownloadRequest": func() pbMessage { return new(pbCommunicatorDownloadRequest) },
+	"CommunicatorForwardRequest":  func() pbMessage { return new(pbCommunicatorForwardRequest) },
+	"CommunicatorSession":         func() pbMessage { return new(pbCommunicatorSession) },
+	"Chunk":                       func() pbMessage { return new(pbChunk) },
+}
+
+// pbServices are the services of plugin.proto, by name.
+var pbServices = map[string]*grpc.ServiceDesc{
+	"Builder":       &grpcBuilderService,
+	"Provisioner":   &grpcProvisionerService,
+	"PostProcessor": &grpcPostProcessorService,
+	"Datasource":    &grpcDatasourceService,
+	"Function":      &grpcFunctionService,
+	"Ui":            &grpcUiService,
+	"Hook":          &grpcHookService,
+	"Artifact":      &grpcArtifactService,
+	"Communicator":  &grpcCommunicatorService,
+	"Lifecycle":     &grpcLifecycleService,
+	"Log":           &grpcLogService,
+}
+
+func TestPBMessages(t *testing.T) {
+	fd := testProtoFile(t)
+	msgs := fd.Messages()
+	if msgs.Len() != len(pbMessages) {
+		t.Fatalf("plugin.proto has %d messages, %d are encoded", msgs.Len(), len(pbMessages))
+	}
+	for i := 0; i < msgs.Len(); i++ {
+		md := msgs.Get(i)
+		newMsg, ok := pbMessages[string(md.Name())]
+		if !ok {
+			t.Fatalf("message %s isn't encoded", md.Name())
+		}
+		testPBMessage(t, md, newMsg)
+	}
+}
+
+func testPBMessage(t *testing.T, md protoreflect.MessageDescriptor, newMsg func() pbMessage) {
+	typ := reflect.TypeOf(newMsg()).Elem()
+	if typ.NumField() != md.Fields().Len() {
+		t.Fatalf("%s has %d fields, %s has %d", md.Name(), md.Fields().Len(), typ, typ.NumField())
+	}
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		name := strings.Replace(string(fd.Name()), "_", "", -1)
+		sf, ok := typ.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if !ok {
+			t.Fatalf("%s has no field %s", typ, fd.Name())
+		}
+
+		m := newMsg()
+		fv := reflect.ValueOf(m).Elem().FieldByIndex(sf.Index)
+		fv.Set(testPBValue(t, sf.Type))
+		var e pbEncoder
+		m.marshalPB(&e)
+
+		dyn := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(e.b, dyn); err != nil {
+			t.Fatalf("%s.%s: %s", md.Name(), fd.Name(), err)
+		}
+		testPBNoUnknown(t, md.Name(), dyn)
+		dyn.Range(func(other protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if other != fd {
+				t.Fatalf("%s.%s: %s is set", md.Name(), fd.Name(), other.Name())
+			}
+			return true
+		})
+		if !dyn.Has(fd) {
+			t.Fatalf("%s.%s isn't set", md.Name(), fd.Name())
+		}
+		if isPBScalar(fd) {
+			expected := fmt.Sprint(reflect.Indirect(fv).Interface())
+			if got := fmt.Sprint(dyn.Get(fd).Interface()); got != expected {
+				t.Fatalf("%s.%s: expected %s, got %s", md.Name(), fd.Name(), expected, got)
+			}
+		}
+
+		b, err := proto.Marshal(dyn)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		back := newMsg()
+		if err := back.unmarshalPB(b); err != nil {
+			t.Fatalf("%s.%s: %s", md.Name(), fd.Name(), err)
+		}
+		if diff := cmp.Diff(m, back, cmpopts.EquateEmpty(), cmp.Exporter(func(reflect.Type) bool { return true })); diff != "" {
+			t.Fatalf("%s.%s round trip: %s", md.Name(), fd.Name(), diff)
+		}
+	}
+}
+
+// isPBScalar returns whether fd is a single field whose value prints the
+// same in Go and in a dynamic message.
+func isPBScalar(fd protoreflect.FieldDescriptor) bool {
+	if fd.Cardinality() == protoreflect.Repeated {
+		return false
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BoolKind, protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Uint32Kind:
+		return true
+	}
+	return false
+}
+
+func testPBNoUnknown(t *testing.T, name protoreflect.Name, m protoreflect.Message) {
+	if len(m.GetUnknown()) > 0 {
+		t.Fatalf("%s: unknown fields %x", name, m.GetUnknown())
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				if fd.MapValue().Message() != nil {
+					testPBNoUnknown(t, name, v.Message())
+				}
+				return true
+			})
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len(); i++ {
+				testPBNoUnknown(t, name, v.List().Get(i).Message())
+			}
+		case fd.Message() != nil:
+			testPBNoUnknown(t, name, v.Message())
+		}
+		return true
+	})
+}
+
+// testPBValue returns a value of typ which isn't the zero value of the
+// fields of its type, but for the optional ones, set.
+func testPBValue(t *testing.T, typ reflect.Type) reflect.Value {
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf("x y").Convert(typ)
+	case reflect.Bool:
+		return reflect.ValueOf(true)
+	case reflect.Uint32:
+		return reflect.ValueOf(uint32(4000000000))
+	case reflect.Int32:
+		return reflect.ValueOf(int32(-5))
+	case reflect.Int64:
+		return reflect.ValueOf(int64(-3))
+	case reflect.Ptr:
+		if typ.Elem().Kind() == reflect.Bool {
+			// An optional bool set to false.
+			return reflect.ValueOf(new(bool))
+		}
+		return reflect.New(typ.Elem())
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return reflect.ValueOf([]byte{0, 1})
+		}
+		s := reflect.MakeSlice(typ, 0, 2)
+		return reflect.Append(s, testPBValue(t, typ.Elem()), testPBValue(t, typ.Elem()))
+	case reflect.Map:
+		m := reflect.MakeMap(typ)
+		m.SetMapIndex(reflect.ValueOf("k"), testPBValue(t, typ.Elem()))
+		return m
+	}
+	t.Fatalf("unexpected field type %s", typ)
+	return reflect.Value{}
+}
+
+func TestPBServices(t *testing.T) {
+	fd := testProtoFile(t)
+	services := fd.Services()
+	if services.Len() != len(pbServices) {
+		t.Fatalf("plugin.proto has %d services, %d are served", services.Len(), len(pbServices))
+	}
+	for i := 0; i < services.Len(); i++ {
+		sd := services.Get(i)
+		desc, ok := pbServices[string(sd.Name())]
+		if !ok {
+			t.Fatalf("service %s isn't served", sd.Name())
+		}
+		if desc.ServiceName != string(sd.FullName()) {
+			t.Fatalf("bad service name %s, expected %s", desc.ServiceName, sd.FullName())
+		}
+		var methods []string
+		for _, m := range desc.Methods {
+			methods = append(methods, m.MethodName)
+		}
+		for _, s := range desc.Streams {
+			methods = append(methods, fmt.Sprintf("%s client:%t server:%t", s.StreamName, s.ClientStreams, s.ServerStreams))
+		}
+		var expected []string
+		for j := 0; j < sd.Methods().Len(); j++ {
+			m := sd.Methods().Get(j)
+			if m.IsStreamingClient() || m.IsStreamingServer() {
+				continue
+			}
+			expected = append(expected, string(m.Name()))
+		}
+		for j := 0; j < sd.Methods().Len(); j++ {
+			m := sd.Methods().Get(j)
+			if m.IsStreamingClient() || m.IsStreamingServer() {
+				expected = append(expected, fmt.Sprintf("%s client:%t server:%t", m.Name(), m.IsStreamingClient(), m.IsStreamingServer()))
+			}
+		}
+		if diff := cmp.Diff(expected, methods, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+			t.Fatalf("bad methods of %s: %s", sd.Name(), diff)
+		}
+	}
+}
+
+// testProtoFile returns the descriptor of proto/plugin.proto.
+func testProtoFile(t *testing.T) protoreflect.FileDescriptor {
+	src, err := os.ReadFile("proto/plugin.proto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fdp, err := parseProto(string(src))
+	if err != nil {
+		t.Fatalf("error parsing plugin.proto: %s", err)
+	}
+	fdp.Name = proto.String("plugin.proto")
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatalf("bad plugin.proto: %s", err)
+	}
+	return fd
+}
+
+// parseProto parses the subset of the proto3 language plugin.proto is
+// written in: messages of scalars, messages, maps and oneofs, and services.
+func parseProto(src string) (*descriptorpb.FileDescriptorProto, error) {
+	p := &protoParser{toks: protoTokens(src)}
+	fd := &descriptorpb.FileDescriptorProto{}
+	for !p.done() {
+		switch tok := p.next(); tok {
+		case "syntax":
+			p.expect("=")
+			fd.Syntax = proto.String(strings.Trim(p.next(), `"`))
+			p.expect(";")
+		case "package":
+			fd.Package = proto.String(p.next())
+			p.expect(";")
+		case "option":
+			for p.next() != ";" {
+			}
+		case "message":
+			fd.MessageType = append(fd.MessageType, p.message(fd.GetPackage()))
+		case "service":
+			fd.Service = append(fd.Service, p.service(fd.GetPackage()))
+		default:
+			p.err = fmt.Errorf("unexpected %q", tok)
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+	}
+	return fd, nil
+}
+
+type protoParser struct {
+	toks []string
+	err  error
+}
+
+func (p *protoParser) done() bool { return len(p.toks) == 0 || p.err != nil }
+
+func (p *protoParser) next() string {
+	if len(p.toks) == 0 {
+		if p.err == nil {
+			p.err = fmt.Errorf("unexpected end of file")
+		}
+		return ""
+	}
+	tok := p.toks[0]
+	p.toks = p.toks[1:]
+	return tok
+}
+
+func (p *protoParser) expect(tok string) {
+	if got := p.next(); got != tok && p.err == nil {
+		p.err = fmt.Errorf("expected %q, got %q", tok, got)
+	}
+}
+
+var protoScalars = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":  descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint32": descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"uint64": descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+}
+
+func (p *protoParser) field(pkg, typ, name string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(protoJSONName(name)),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if t, ok := protoScalars[typ]; ok {
+		f.Type = t.Enum()
+	} else {
+		f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		f.TypeName = proto.String("." + pkg + "." + typ)
+	}
+	p.expect("=")
+	n, err := strconv.Atoi(p.next())
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	f.Number = proto.Int32(int32(n))
+	p.expect(";")
+	return f
+}
+
+func (p *protoParser) message(pkg string) *descriptorpb.DescriptorProto {
+	m := &descriptorpb.DescriptorProto{Name: proto.String(p.next())}
+	var synthetic []*descriptorpb.FieldDescriptorProto
+	p.expect("{")
+	for tok := p.next(); tok != "}" && p.err == nil; tok = p.next() {
+		switch tok {
+		case "oneof":
+			m.OneofDecl = append(m.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(p.next())})
+			p.expect("{")
+			for typ := p.next(); typ != "}" && p.err == nil; typ = p.next() {
+				f := p.field(pkg, typ, p.next())
+				f.OneofIndex = proto.Int32(int32(len(m.OneofDecl) - 1))
+				m.Field = append(m.Field, f)
+			}
+		case "map":
+			p.expect("<")
+			key := p.next()
+			p.expect(",")
+			value := p.next()
+			p.expect(">")
+			name := p.next()
+			entry := &descriptorpb.DescriptorProto{
+				Name: proto.String(protoCamelCase(name) + "Entry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("key"), JsonName: proto.String("key"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: protoScalars[key].Enum()},
+					{Name: proto.String("value"), JsonName: proto.String("value"), Number: proto.Int32(2), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}
+			if t, ok := protoScalars[value]; ok {
+				entry.Field[1].Type = t.Enum()
+			} else {
+				entry.Field[1].Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				entry.Field[1].TypeName = proto.String("." + pkg + "." + value)
+			}
+			m.NestedType = append(m.NestedType, entry)
+			f := p.field(pkg, "", name)
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			f.TypeName = proto.String("." + pkg + "." + m.GetName() + "." + entry.GetName())
+			m.Field = append(m.Field, f)
+		case "repeated":
+			typ := p.next()
+			f := p.field(pkg, typ, p.next())
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			m.Field = append(m.Field, f)
+		case "optional":
+			typ := p.next()
+			f := p.field(pkg, typ, p.next())
+			f.Proto3Optional = proto.Bool(true)
+			synthetic = append(synthetic, f)
+			m.Field = append(m.Field, f)
+		default:
+			m.Field = append(m.Field, p.field(pkg, tok, p.next()))
+		}
+	}
+	// The oneofs of the optional fields follow the others.
+	for _, f := range synthetic {
+		f.OneofIndex = proto.Int32(int32(len(m.OneofDecl)))
+		m.OneofDecl = append(m.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.GetName())})
+	}
+	return m
+}
+
+func (p *protoParser) service(pkg string) *descriptorpb.ServiceDescriptorProto {
+	s := &descriptorpb.ServiceDescriptorProto{Name: proto.String(p.next())}
+	p.expect("{")
+	for tok := p.next(); tok != "}" && p.err == nil; tok = p.next() {
+		if tok != "rpc" {
+			p.err = fmt.Errorf("unexpected %q in service %s", tok, s.GetName())
+			break
+		}
+		m := &descriptorpb.MethodDescriptorProto{Name: proto.String(p.next())}
+		typ := func() (string, bool) {
+			p.expect("(")
+			t := p.next()
+			stream := t == "stream"
+			if stream {
+				t = p.next()
+			}
+			p.expect(")")
+			return "." + pkg + "." + t, stream
+		}
+		in, clientStreaming := typ()
+		p.expect("returns")
+		out, serverStreaming := typ()
+		p.expect(";")
+		m.InputType, m.OutputType = proto.String(in), proto.String(out)
+		if clientStreaming {
+			m.ClientStreaming = proto.Bool(true)
+		}
+		if serverStreaming {
+			m.ServerStreaming = proto.Bool(true)
+		}
+		s.Method = append(s.Method, m)
+	}
+	return s
+}
+
+// protoTokens splits src into identifiers, numbers, strings and
+// punctuation, skipping the comments.
+func protoTokens(src string) []string {
+	var toks []string
+	for len(src) > 0 {
+		r := rune(src[0])
+		switch {
+		case strings.HasPrefix(src, "//"):
+			if i := strings.IndexByte(src, '\n'); i >= 0 {
+				src = src[i:]
+			} else {
+				src = ""
+			}
+		case unicode.IsSpace(r):
+			src = src[1:]
+		case r == '"':
+			i := strings.IndexByte(src[1:], '"') + 2
+			toks = append(toks, src[:i])
+			src = src[i:]
+		case r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			i := strings.IndexFunc(src, func(r rune) bool {
+				return !(r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r))
+			})
+			if i < 0 {
+				i = len(src)
+			}
+			toks = append(toks, src[:i])
+			src = src[i:]
+		default:
+			toks = append(toks, src[:1])
+			src = src[1:]
+		}
+	}
+	return toks
+}
+
+func protoCamelCase(name string) string {
+	var b bytes.Buffer
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+func protoJSONName(name string) string {
+	s := protoCamelCase(name)
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,393 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"google.golang.org/grpc"
+)
+
+// The services of the components served by the plugins. The context of the
+// calls is cancelled when the one of the caller is, so unlike with net/rpc
+// there is no Cancel method.
+
+const (
+	grpcBuilderName       = "packer.plugin.v1.Builder"
+	grpcProvisionerName   = "packer.plugin.v1.Provisioner"
+	grpcPostProcessorName = "packer.plugin.v1.PostProcessor"
+	grpcDatasourceName    = "packer.plugin.v1.Datasource"
+	grpcFunctionName      = "packer.plugin.v1.Function"
+)
+
+func newPBEmpty() pbMessage            { return new(pbEmpty) }
+func newPBConfigureRequest() pbMessage { return new(pbConfigureRequest) }
+
+// configure calls method with configs.
+func (p *grpcPeer) configure(method string, configs []interface{}, resp pbMessage) error {
+	pb, err := configsToPB(configs)
+	if err != nil {
+		return err
+	}
+	return p.invoke(context.Background(), method, &pbConfigureRequest{Configs: pb}, resp)
+}
+
+// An implementation of packer.Builder where the builder is actually executed
+// over a gRPC connection.
+type grpcBuilder struct {
+	peer *grpcPeer
+}
+
+func (b *grpcBuilder) ConfigSpec() hcldec.ObjectSpec {
+	return grpcConfigSpec(b.peer, "/"+grpcBuilderName+"/ConfigSpec")
+}
+
+func (b *grpcBuilder) Prepare(config ...interface{}) ([]string, []string, error) {
+	var resp pbPrepareResponse
+	if err := b.peer.configure("/"+grpcBuilderName+"/Prepare", config, &resp); err != nil {
+		return nil, nil, err
+	}
+	var err error
+	if resp.Error != "" {
+		err = &BasicError{Message: resp.Error}
+	}
+	return resp.GeneratedVars, resp.Warnings, err
+}
+
+func (b *grpcBuilder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
+	req := &pbBuilderRunRequest{Ui: b.peer.export(ui), Hook: b.peer.export(hook)}
+	defer b.peer.unexport(req.Ui)
+	defer b.peer.unexport(req.Hook)
+
+	var resp pbArtifactResponse
+	if err := b.peer.invoke(ctx, "/"+grpcBuilderName+"/Run", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Artifact == 0 {
+		return nil, nil
+	}
+	return &grpcArtifact{peer: b.peer, id: resp.Artifact}, nil
+}
+
+type grpcBuilderServer struct {
+	configured
+	peer    *grpcPeer
+	builder packer.Builder
+}
+
+var grpcBuilderService = grpc.ServiceDesc{
+	ServiceName: grpcBuilderName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcBuilderName, "ConfigSpec", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			s := srv.(*grpcBuilderServer)
+			return s.peer.cachedConfigSpecResponse("/"+grpcBuilderName+"/ConfigSpec", s.builder.ConfigSpec)
+		}),
+		grpcMethod(grpcBuilderName, "Prepare", newPBConfigureRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			configs, err := configsFromPB(req.(*pbConfigureRequest).Configs)
+			if err != nil {
+				return nil, err
+			}
+			srv.(*grpcBuilderServer).setConfigs(configs)
+			generated, warnings, err := srv.(*grpcBuilderServer).builder.Prepare(configs...)
+			resp := &pbPrepareResponse{GeneratedVars: generated, Warnings: warnings}
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			return resp, nil
+		}),
+		grpcMethod(grpcBuilderName, "Run", func() pbMessage { return new(pbBuilderRunRequest) }, func(srv interface{}, ctx context.Context, req pbMessage) (pbMessage, error) {
+			s := srv.(*grpcBuilderServer)
+			r := req.(*pbBuilderRunRequest)
+			artifact, err := s.builder.Run(ctx, &grpcUi{peer: s.peer, id: r.Ui}, &grpcHook{peer: s.peer, id: r.Hook})
+			if err != nil {
+				return nil, err
+			}
+			return &pbArtifactResponse{Artifact: s.peer.export(artifact)}, nil
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+// An implementation of packer.Provisioner where the provisioner is actually
+// executed over a gRPC connection.
+type grpcProvisioner struct {
+	peer *grpcPeer
+}
+
+func (p *grpcProvisioner) ConfigSpec() hcldec.ObjectSpec {
+	return grpcConfigSpec(p.peer, "/"+grpcProvisionerName+"/ConfigSpec")
+}
+
+func (p *grpcProvisioner) Prepare(configs ...interface{}) error {
+	return p.peer.configure("/"+grpcProvisionerName+"/Prepare", configs, &pbEmpty{})
+}
+
+func (p *grpcProvisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator, generatedData map[string]interface{}) error {
+	data, err := jsonOrNil(generatedData)
+	if err != nil {
+		return err
+	}
+	req := &pbProvisionRequest{Ui: p.peer.export(ui), Communicator: p.peer.export(comm), GeneratedData: data}
+	defer p.peer.unexport(req.Ui)
+	defer p.peer.unexport(req.Communicator)
+
+	return p.peer.invoke(ctx, "/"+grpcProvisionerName+"/Provision", req, &pbEmpty{})
+}
+
+type grpcProvisionerServer struct {
+	configured
+	peer *grpcPeer
+	p    packer.Provisioner
+}
+
+var grpcProvisionerService = grpc.ServiceDesc{
+	ServiceName: grpcProvisionerName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcProvisionerName, "ConfigSpec", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			s := srv.(*grpcProvisionerServer)
+			return s.peer.cachedConfigSpecResponse("/"+grpcProvisionerName+"/ConfigSpec", s.p.ConfigSpec)
+		}),
+		grpcMethod(grpcProvisionerName, "Prepare", newPBConfigureRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			configs, err := configsFromPB(req.(*pbConfigureRequest).Configs)
+			if err != nil {
+				return nil, err
+			}
+			srv.(*grpcProvisionerServer).setConfigs(configs)
+			return &pbEmpty{}, srv.(*grpcProvisionerServer).p.Prepare(configs...)
+		}),
+		grpcMethod(grpcProvisionerName, "Provision", func() pbMessage { return new(pbProvisionRequest) }, func(srv interface{}, ctx context.Context, req pbMessage) (pbMessage, error) {
+			s := srv.(*grpcProvisionerServer)
+			r := req.(*pbProvisionRequest)
+			var generatedData map[string]interface{}
+			if r.GeneratedData != nil {
+				if err := json.Unmarshal(r.GeneratedData, &generatedData); err != nil {
+					return nil, err
+				}
+			}
+			var comm packer.Communicator
+			if r.Communicator != 0 {
+				comm = &grpcCommunicator{peer: s.peer, id: r.Communicator}
+			}
+			return &pbEmpty{}, s.p.Provision(ctx, &grpcUi{peer: s.peer, id: r.Ui}, comm, generatedData)
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+// An implementation of packer.PostProcessor where the post-processor is
+// actually executed over a gRPC connection.
+type grpcPostProcessor struct {
+	peer *grpcPeer
+}
+
+func (p *grpcPostProcessor) ConfigSpec() hcldec.ObjectSpec {
+	return grpcConfigSpec(p.peer, "/"+grpcPostProcessorName+"/ConfigSpec")
+}
+
+func (p *grpcPostProcessor) Configure(configs ...interface{}) error {
+	return p.peer.configure("/"+grpcPostProcessorName+"/Configure", configs, &pbEmpty{})
+}
+
+func (p *grpcPostProcessor) PostProcess(ctx context.Context, ui packer.Ui, a packer.Artifact) (packer.Artifact, bool, bool, error) {
+	// The artifact stays exported, as the post-processor can return it.
+	req := &pbPostProcessRequest{Ui: p.peer.export(ui), Artifact: p.peer.export(a)}
+	defer p.peer.unexport(req.Ui)
+
+	var resp pbPostProcessResponse
+	if err := p.peer.invoke(ctx, "/"+grpcPostProcessorName+"/PostProcess", req, &resp); err != nil {
+		return nil, false, false, err
+	}
+	var artifact packer.Artifact
+	if resp.Artifact != 0 {
+		artifact = &grpcArtifact{peer: p.peer, id: resp.Artifact}
+	}
+	return artifact, resp.Keep, resp.ForceOverride, nil
+}
+
+type grpcPostProcessorServer struct {
+	configured
+	peer *grpcPeer
+	p    packer.PostProcessor
+}
+
+var grpcPostProcessorService = grpc.ServiceDesc{
+	ServiceName: grpcPostProcessorName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcPostProcessorName, "ConfigSpec", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			s := srv.(*grpcPostProcessorServer)
+			return s.peer.cachedConfigSpecResponse("/"+grpcPostProcessorName+"/ConfigSpec", s.p.ConfigSpec)
+		}),
+		grpcMethod(grpcPostProcessorName, "Configure", newPBConfigureRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			configs, err := configsFromPB(req.(*pbConfigureRequest).Configs)
+			if err != nil {
+				return nil, err
+			}
+			srv.(*grpcPostProcessorServer).setConfigs(configs)
+			return &pbEmpty{}, srv.(*grpcPostProcessorServer).p.Configure(configs...)
+		}),
+		grpcMethod(grpcPostProcessorName, "PostProcess", func() pbMessage { return new(pbPostProcessRequest) }, func(srv interface{}, ctx context.Context, req pbMessage) (pbMessage, error) {
+			s := srv.(*grpcPostProcessorServer)
+			r := req.(*pbPostProcessRequest)
+			var a packer.Artifact
+			if r.Artifact != 0 {
+				a = &grpcArtifact{peer: s.peer, id: r.Artifact}
+			}
+			artifact, keep, forceOverride, err := s.p.PostProcess(ctx, &grpcUi{peer: s.peer, id: r.Ui}, a)
+			if err != nil {
+				return nil, err
+			}
+			return &pbPostProcessResponse{
+				Artifact:      s.peer.export(artifact),
+				Keep:          keep,
+				ForceOverride: forceOverride,
+			}, nil
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+// An implementation of packer.Datasource where the data source is actually
+// executed over a gRPC connection.
+type grpcDatasource struct {
+	peer *grpcPeer
+}
+
+func (d *grpcDatasource) ConfigSpec() hcldec.ObjectSpec {
+	return grpcConfigSpec(d.peer, "/"+grpcDatasourceName+"/ConfigSpec")
+}
+
+func (d *grpcDatasource) Configure(configs ...interface{}) error {
+	return d.peer.configure("/"+grpcDatasourceName+"/Configure", configs, &pbEmpty{})
+}
+
+func (d *grpcDatasource) OutputSpec() hcldec.ObjectSpec {
+	return grpcConfigSpec(d.peer, "/"+grpcDatasourceName+"/OutputSpec")
+}
+
+func (d *grpcDatasource) Execute() (cty.Value, error) {
+	var resp pbExecuteResponse
+	if err := d.peer.invoke(context.Background(), "/"+grpcDatasourceName+"/Execute", &pbEmpty{}, &resp); err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(resp.Value, cty.DynamicPseudoType)
+}
+
+type grpcDatasourceServer struct {
+	configured
+	peer *grpcPeer
+	d    packer.Datasource
+}
+
+var grpcDatasourceService = grpc.ServiceDesc{
+	ServiceName: grpcDatasourceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcDatasourceName, "ConfigSpec", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			s := srv.(*grpcDatasourceServer)
+			return s.peer.cachedConfigSpecResponse("/"+grpcDatasourceName+"/ConfigSpec", s.d.ConfigSpec)
+		}),
+		grpcMethod(grpcDatasourceName, "Configure", newPBConfigureRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			configs, err := configsFromPB(req.(*pbConfigureRequest).Configs)
+			if err != nil {
+				return nil, err
+			}
+			srv.(*grpcDatasourceServer).setConfigs(configs)
+			return &pbEmpty{}, srv.(*grpcDatasourceServer).d.Configure(configs...)
+		}),
+		grpcMethod(grpcDatasourceName, "OutputSpec", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			s := srv.(*grpcDatasourceServer)
+			return s.peer.cachedConfigSpecResponse("/"+grpcDatasourceName+"/OutputSpec", s.d.OutputSpec)
+		}),
+		grpcMethod(grpcDatasourceName, "Execute", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			v, err := srv.(*grpcDatasourceServer).d.Execute()
+			if err != nil {
+				return nil, err
+			}
+			b, err := ctyjson.Marshal(v, cty.DynamicPseudoType)
+			if err != nil {
+				return nil, err
+			}
+			return &pbExecuteResponse{Value: b}, nil
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+// An implementation of packer.Function where the function is actually
+// executed over a gRPC connection.
+type grpcFunction struct {
+	peer *grpcPeer
+}
+
+func (f *grpcFunction) Signature() packer.FunctionSignature {
+	var resp pbFunctionSignature
+	if err := f.peer.invoke(context.Background(), "/"+grpcFunctionName+"/Signature", &pbEmpty{}, &resp); err != nil {
+		panic(fmt.Sprintf("Function.Signature failed: %v", err))
+	}
+	sig, err := functionSignatureFromPB(&resp)
+	if err != nil {
+		panic(fmt.Sprintf("Function.Signature failed: %v", err))
+	}
+	return sig
+}
+
+func (f *grpcFunction) Call(args []cty.Value) (cty.Value, error) {
+	req := &pbFunctionCallRequest{}
+	for _, arg := range args {
+		b, err := ctyjson.Marshal(arg, cty.DynamicPseudoType)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		req.Args = append(req.Args, b)
+	}
+	var resp pbExecuteResponse
+	if err := f.peer.invoke(context.Background(), "/"+grpcFunctionName+"/Call", req, &resp); err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(resp.Value, cty.DynamicPseudoType)
+}
+
+type grpcFunctionServer struct {
+	peer *grpcPeer
+	f    packer.Function
+}
+
+var grpcFunctionService = grpc.ServiceDesc{
+	ServiceName: grpcFunctionName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcFunctionName, "Signature", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			return functionSignatureToPB(srv.(*grpcFunctionServer).f.Signature())
+		}),
+		grpcMethod(grpcFunctionName, "Call", func() pbMessage { return new(pbFunctionCallRequest) }, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			var args []cty.Value
+			for _, b := range req.(*pbFunctionCallRequest).Args {
+				arg, err := ctyjson.Unmarshal(b, cty.DynamicPseudoType)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+			}
+			v, err := srv.(*grpcFunctionServer).f.Call(args)
+			if err != nil {
+				return nil, err
+			}
+			b, err := ctyjson.Marshal(v, cty.DynamicPseudoType)
+			if err != nil {
+				return nil, err
+			}
+			return &pbExecuteResponse{Value: b}, nil
+		}),
+	},
+	Metadata: "plugin.proto",
+}
@@ -0,0 +1,1138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of proto/plugin.proto, with the same field numbers.
+
+type pbEmpty struct{}
+
+func (*pbEmpty) marshalPB(*pbEncoder) {}
+
+func (*pbEmpty) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(protowire.Number, pbValue) error { return nil })
+}
+
+type pbConfig struct {
+	Cty  []byte
+	JSON []byte
+}
+
+func (m *pbConfig) marshalPB(e *pbEncoder) {
+	if m.Cty != nil {
+		e.b = protowire.AppendTag(e.b, 1, protowire.BytesType)
+		e.b = protowire.AppendBytes(e.b, m.Cty)
+		return
+	}
+	e.b = protowire.AppendTag(e.b, 2, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, m.JSON)
+}
+
+func (m *pbConfig) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Cty, m.JSON = v.Bytes(), nil
+		case 2:
+			m.Cty, m.JSON = nil, v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbConfigureRequest struct {
+	Configs []*pbConfig
+}
+
+func (m *pbConfigureRequest) marshalPB(e *pbEncoder) {
+	for _, c := range m.Configs {
+		e.message(1, c)
+	}
+}
+
+func (m *pbConfigureRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			c := new(pbConfig)
+			if err := v.Message(c); err != nil {
+				return err
+			}
+			m.Configs = append(m.Configs, c)
+		}
+		return nil
+	})
+}
+
+type pbPrepareResponse struct {
+	GeneratedVars []string
+	Warnings      []string
+	Error         string
+}
+
+func (m *pbPrepareResponse) marshalPB(e *pbEncoder) {
+	e.strings(1, m.GeneratedVars)
+	e.strings(2, m.Warnings)
+	e.string(3, m.Error)
+}
+
+func (m *pbPrepareResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.GeneratedVars = append(m.GeneratedVars, v.String())
+		case 2:
+			m.Warnings = append(m.Warnings, v.String())
+		case 3:
+			m.Error = v.String()
+		}
+		return nil
+	})
+}
+
+// pbSpec has one of its fields set.
+type pbSpec struct {
+	Object      *pbObjectSpec
+	Attr        *pbAttrSpec
+	Block       *pbBlockSpec
+	BlockList   *pbBlockListSpec
+	BlockAttrs  *pbBlockAttrsSpec
+	BlockObject *pbBlockObjectSpec
+}
+
+func (m *pbSpec) marshalPB(e *pbEncoder) {
+	switch {
+	case m.Object != nil:
+		e.message(1, m.Object)
+	case m.Attr != nil:
+		e.message(2, m.Attr)
+	case m.Block != nil:
+		e.message(3, m.Block)
+	case m.BlockList != nil:
+		e.message(4, m.BlockList)
+	case m.BlockAttrs != nil:
+		e.message(5, m.BlockAttrs)
+	case m.BlockObject != nil:
+		e.message(6, m.BlockObject)
+	}
+}
+
+func (m *pbSpec) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		*m = pbSpec{}
+		switch num {
+		case 1:
+			m.Object = new(pbObjectSpec)
+			return v.Message(m.Object)
+		case 2:
+			m.Attr = new(pbAttrSpec)
+			return v.Message(m.Attr)
+		case 3:
+			m.Block = new(pbBlockSpec)
+			return v.Message(m.Block)
+		case 4:
+			m.BlockList = new(pbBlockListSpec)
+			return v.Message(m.BlockList)
+		case 5:
+			m.BlockAttrs = new(pbBlockAttrsSpec)
+			return v.Message(m.BlockAttrs)
+		case 6:
+			m.BlockObject = new(pbBlockObjectSpec)
+			return v.Message(m.BlockObject)
+		}
+		return nil
+	})
+}
+
+type pbObjectSpec struct {
+	Attributes map[string]*pbSpec
+}
+
+// pbSpecEntry is an entry of the attributes map of an ObjectSpec.
+type pbSpecEntry struct {
+	Key   string
+	Value *pbSpec
+}
+
+func (m *pbSpecEntry) marshalPB(e *pbEncoder) {
+	e.string(1, m.Key)
+	e.message(2, m.Value)
+}
+
+func (m *pbSpecEntry) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Key = v.String()
+		case 2:
+			m.Value = new(pbSpec)
+			return v.Message(m.Value)
+		}
+		return nil
+	})
+}
+
+func (m *pbObjectSpec) marshalPB(e *pbEncoder) {
+	for k, v := range m.Attributes {
+		e.message(1, &pbSpecEntry{Key: k, Value: v})
+	}
+}
+
+func (m *pbObjectSpec) unmarshalPB(b []byte) error {
+	m.Attributes = map[string]*pbSpec{}
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			entry := new(pbSpecEntry)
+			if err := v.Message(entry); err != nil {
+				return err
+			}
+			if entry.Value == nil {
+				entry.Value = new(pbSpec)
+			}
+			m.Attributes[entry.Key] = entry.Value
+		}
+		return nil
+	})
+}
+
+type pbAttrSpec struct {
+	Name     string
+	Type     []byte
+	Required bool
+}
+
+func (m *pbAttrSpec) marshalPB(e *pbEncoder) {
+	e.string(1, m.Name)
+	e.bytes(2, m.Type)
+	e.bool(3, m.Required)
+}
+
+func (m *pbAttrSpec) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Name = v.String()
+		case 2:
+			m.Type = v.Bytes()
+		case 3:
+			m.Required = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbBlockSpec struct {
+	TypeName string
+	Nested   *pbSpec
+	Required bool
+}
+
+func (m *pbBlockSpec) marshalPB(e *pbEncoder) {
+	e.string(1, m.TypeName)
+	if m.Nested != nil {
+		e.message(2, m.Nested)
+	}
+	e.bool(3, m.Required)
+}
+
+func (m *pbBlockSpec) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.TypeName = v.String()
+		case 2:
+			m.Nested = new(pbSpec)
+			return v.Message(m.Nested)
+		case 3:
+			m.Required = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbBlockListSpec struct {
+	TypeName string
+	Nested   *pbSpec
+	MinItems int64
+	MaxItems int64
+}
+
+func (m *pbBlockListSpec) marshalPB(e *pbEncoder) {
+	e.string(1, m.TypeName)
+	if m.Nested != nil {
+		e.message(2, m.Nested)
+	}
+	e.int(3, m.MinItems)
+	e.int(4, m.MaxItems)
+}
+
+func (m *pbBlockListSpec) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.TypeName = v.String()
+		case 2:
+			m.Nested = new(pbSpec)
+			return v.Message(m.Nested)
+		case 3:
+			m.MinItems = v.Int64()
+		case 4:
+			m.MaxItems = v.Int64()
+		}
+		return nil
+	})
+}
+
+type pbBlockAttrsSpec struct {
+	TypeName    string
+	ElementType []byte
+	Required    bool
+}
+
+func (m *pbBlockAttrsSpec) marshalPB(e *pbEncoder) {
+	e.string(1, m.TypeName)
+	e.bytes(2, m.ElementType)
+	e.bool(3, m.Required)
+}
+
+func (m *pbBlockAttrsSpec) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.TypeName = v.String()
+		case 2:
+			m.ElementType = v.Bytes()
+		case 3:
+			m.Required = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbBlockObjectSpec struct {
+	TypeName   string
+	LabelNames []string
+	Nested     *pbSpec
+}
+
+func (m *pbBlockObjectSpec) marshalPB(e *pbEncoder) {
+	e.string(1, m.TypeName)
+	e.strings(2, m.LabelNames)
+	if m.Nested != nil {
+		e.message(3, m.Nested)
+	}
+}
+
+func (m *pbBlockObjectSpec) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.TypeName = v.String()
+		case 2:
+			m.LabelNames = append(m.LabelNames, v.String())
+		case 3:
+			m.Nested = new(pbSpec)
+			return v.Message(m.Nested)
+		}
+		return nil
+	})
+}
+
+type pbConfigSpecResponse struct {
+	Spec *pbObjectSpec
+}
+
+func (m *pbConfigSpecResponse) marshalPB(e *pbEncoder) {
+	if m.Spec != nil {
+		e.message(1, m.Spec)
+	}
+}
+
+func (m *pbConfigSpecResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Spec = new(pbObjectSpec)
+			return v.Message(m.Spec)
+		}
+		return nil
+	})
+}
+
+type pbBuilderRunRequest struct {
+	Ui   uint32
+	Hook uint32
+}
+
+func (m *pbBuilderRunRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.uint(2, uint64(m.Hook))
+}
+
+func (m *pbBuilderRunRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Hook = v.Uint32()
+		}
+		return nil
+	})
+}
+
+type pbArtifactResponse struct {
+	Artifact uint32
+}
+
+func (m *pbArtifactResponse) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Artifact))
+}
+
+func (m *pbArtifactResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Artifact = v.Uint32()
+		}
+		return nil
+	})
+}
+
+type pbProvisionRequest struct {
+	Ui            uint32
+	Communicator  uint32
+	GeneratedData []byte
+}
+
+func (m *pbProvisionRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.uint(2, uint64(m.Communicator))
+	e.bytes(3, m.GeneratedData)
+}
+
+func (m *pbProvisionRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Communicator = v.Uint32()
+		case 3:
+			m.GeneratedData = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbPostProcessRequest struct {
+	Ui       uint32
+	Artifact uint32
+}
+
+func (m *pbPostProcessRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.uint(2, uint64(m.Artifact))
+}
+
+func (m *pbPostProcessRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Artifact = v.Uint32()
+		}
+		return nil
+	})
+}
+
+type pbPostProcessResponse struct {
+	Artifact      uint32
+	Keep          bool
+	ForceOverride bool
+}
+
+func (m *pbPostProcessResponse) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Artifact))
+	e.bool(2, m.Keep)
+	e.bool(3, m.ForceOverride)
+}
+
+func (m *pbPostProcessResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Artifact = v.Uint32()
+		case 2:
+			m.Keep = v.Bool()
+		case 3:
+			m.ForceOverride = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbExecuteResponse struct {
+	Value []byte
+}
+
+func (m *pbExecuteResponse) marshalPB(e *pbEncoder) {
+	e.bytes(1, m.Value)
+}
+
+func (m *pbExecuteResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Value = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbFunctionSignature struct {
+	Description string
+	Params      []*pbFunctionParameter
+	VarParam    *pbFunctionParameter
+	ReturnType  []byte
+}
+
+func (m *pbFunctionSignature) marshalPB(e *pbEncoder) {
+	e.string(1, m.Description)
+	for _, p := range m.Params {
+		e.message(2, p)
+	}
+	if m.VarParam != nil {
+		e.message(3, m.VarParam)
+	}
+	e.bytes(4, m.ReturnType)
+}
+
+func (m *pbFunctionSignature) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Description = v.String()
+		case 2:
+			p := new(pbFunctionParameter)
+			m.Params = append(m.Params, p)
+			return v.Message(p)
+		case 3:
+			m.VarParam = new(pbFunctionParameter)
+			return v.Message(m.VarParam)
+		case 4:
+			m.ReturnType = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbFunctionParameter struct {
+	Name        string
+	Description string
+	Type        []byte
+	AllowNull   bool
+}
+
+func (m *pbFunctionParameter) marshalPB(e *pbEncoder) {
+	e.string(1, m.Name)
+	e.string(2, m.Description)
+	e.bytes(3, m.Type)
+	e.bool(4, m.AllowNull)
+}
+
+func (m *pbFunctionParameter) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Name = v.String()
+		case 2:
+			m.Description = v.String()
+		case 3:
+			m.Type = v.Bytes()
+		case 4:
+			m.AllowNull = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbFunctionCallRequest struct {
+	Args [][]byte
+}
+
+func (m *pbFunctionCallRequest) marshalPB(e *pbEncoder) {
+	e.bytesList(1, m.Args)
+}
+
+func (m *pbFunctionCallRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Args = append(m.Args, v.Bytes())
+		}
+		return nil
+	})
+}
+
+type pbUiRequest struct {
+	Ui      uint32
+	Message string
+}
+
+func (m *pbUiRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.string(2, m.Message)
+}
+
+func (m *pbUiRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Message = v.String()
+		}
+		return nil
+	})
+}
+
+type pbUiAskRequest struct {
+	Ui      uint32
+	Query   string
+	Default string
+	Timeout int64
+}
+
+func (m *pbUiAskRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.string(2, m.Query)
+	e.string(3, m.Default)
+	e.int(4, m.Timeout)
+}
+
+func (m *pbUiAskRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Query = v.String()
+		case 3:
+			m.Default = v.String()
+		case 4:
+			m.Timeout = v.Int64()
+		}
+		return nil
+	})
+}
+
+type pbUiInteractiveResponse struct {
+	Interactive bool
+}
+
+func (m *pbUiInteractiveResponse) marshalPB(e *pbEncoder) {
+	e.bool(1, m.Interactive)
+}
+
+func (m *pbUiInteractiveResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Interactive = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbUiAskResponse struct {
+	Answer string
+}
+
+func (m *pbUiAskResponse) marshalPB(e *pbEncoder) {
+	e.string(1, m.Answer)
+}
+
+func (m *pbUiAskResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Answer = v.String()
+		}
+		return nil
+	})
+}
+
+type pbUiMachineRequest struct {
+	Ui   uint32
+	Type string
+	Args []string
+}
+
+func (m *pbUiMachineRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.string(2, m.Type)
+	e.strings(3, m.Args)
+}
+
+func (m *pbUiMachineRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Type = v.String()
+		case 3:
+			m.Args = append(m.Args, v.String())
+		}
+		return nil
+	})
+}
+
+type pbUiProgress struct {
+	Ui          uint32
+	Src         string
+	CurrentSize int64
+	TotalSize   int64
+	Read        int64
+}
+
+func (m *pbUiProgress) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Ui))
+	e.string(2, m.Src)
+	e.int(3, m.CurrentSize)
+	e.int(4, m.TotalSize)
+	e.int(5, m.Read)
+}
+
+func (m *pbUiProgress) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Ui = v.Uint32()
+		case 2:
+			m.Src = v.String()
+		case 3:
+			m.CurrentSize = v.Int64()
+		case 4:
+			m.TotalSize = v.Int64()
+		case 5:
+			m.Read = v.Int64()
+		}
+		return nil
+	})
+}
+
+type pbHookRunRequest struct {
+	Hook         uint32
+	Name         string
+	Ui           uint32
+	Communicator uint32
+	Data         []byte
+}
+
+func (m *pbHookRunRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Hook))
+	e.string(2, m.Name)
+	e.uint(3, uint64(m.Ui))
+	e.uint(4, uint64(m.Communicator))
+	e.bytes(5, m.Data)
+}
+
+func (m *pbHookRunRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Hook = v.Uint32()
+		case 2:
+			m.Name = v.String()
+		case 3:
+			m.Ui = v.Uint32()
+		case 4:
+			m.Communicator = v.Uint32()
+		case 5:
+			m.Data = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbArtifactRequest struct {
+	Artifact  uint32
+	StateName string
+}
+
+func (m *pbArtifactRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Artifact))
+	e.string(2, m.StateName)
+}
+
+func (m *pbArtifactRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Artifact = v.Uint32()
+		case 2:
+			m.StateName = v.String()
+		}
+		return nil
+	})
+}
+
+type pbArtifactInfo struct {
+	BuilderID   string
+	ID          string
+	Files       []string
+	Description string
+	StateNames  []string
+}
+
+func (m *pbArtifactInfo) marshalPB(e *pbEncoder) {
+	e.string(1, m.BuilderID)
+	e.string(2, m.ID)
+	e.strings(3, m.Files)
+	e.string(4, m.Description)
+	e.strings(5, m.StateNames)
+}
+
+func (m *pbArtifactInfo) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.BuilderID = v.String()
+		case 2:
+			m.ID = v.String()
+		case 3:
+			m.Files = append(m.Files, v.String())
+		case 4:
+			m.Description = v.String()
+		case 5:
+			m.StateNames = append(m.StateNames, v.String())
+		}
+		return nil
+	})
+}
+
+type pbArtifactStateResponse struct {
+	Value []byte
+}
+
+func (m *pbArtifactStateResponse) marshalPB(e *pbEncoder) {
+	e.bytes(1, m.Value)
+}
+
+func (m *pbArtifactStateResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Value = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorStartRequest struct {
+	Communicator uint32
+	Command      string
+	Pty          *bool
+	Stdin        bool
+	StdinData    []byte
+}
+
+func (m *pbCommunicatorStartRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Communicator))
+	e.string(2, m.Command)
+	e.optionalBool(3, m.Pty)
+	e.bool(4, m.Stdin)
+	e.bytes(5, m.StdinData)
+}
+
+func (m *pbCommunicatorStartRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Communicator = v.Uint32()
+		case 2:
+			m.Command = v.String()
+		case 3:
+			pty := v.Bool()
+			m.Pty = &pty
+		case 4:
+			m.Stdin = v.Bool()
+		case 5:
+			m.StdinData = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorStartResponse struct {
+	Stdout     []byte
+	Stderr     []byte
+	Exited     bool
+	ExitStatus int32
+}
+
+func (m *pbCommunicatorStartResponse) marshalPB(e *pbEncoder) {
+	e.bytes(1, m.Stdout)
+	e.bytes(2, m.Stderr)
+	e.bool(3, m.Exited)
+	e.int(4, int64(m.ExitStatus))
+}
+
+func (m *pbCommunicatorStartResponse) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Stdout = v.Bytes()
+		case 2:
+			m.Stderr = v.Bytes()
+		case 3:
+			m.Exited = v.Bool()
+		case 4:
+			m.ExitStatus = v.Int32()
+		}
+		return nil
+	})
+}
+
+type pbFileInfo struct {
+	Name    string
+	Size    int64
+	Mode    uint32
+	ModTime int64
+}
+
+func (m *pbFileInfo) marshalPB(e *pbEncoder) {
+	e.string(1, m.Name)
+	e.int(2, m.Size)
+	e.uint(3, uint64(m.Mode))
+	e.int(4, m.ModTime)
+}
+
+func (m *pbFileInfo) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Name = v.String()
+		case 2:
+			m.Size = v.Int64()
+		case 3:
+			m.Mode = v.Uint32()
+		case 4:
+			m.ModTime = v.Int64()
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorUploadRequest struct {
+	Communicator uint32
+	Path         string
+	FileInfo     *pbFileInfo
+	Data         []byte
+}
+
+func (m *pbCommunicatorUploadRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Communicator))
+	e.string(2, m.Path)
+	if m.FileInfo != nil {
+		e.message(3, m.FileInfo)
+	}
+	e.bytes(4, m.Data)
+}
+
+func (m *pbCommunicatorUploadRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Communicator = v.Uint32()
+		case 2:
+			m.Path = v.String()
+		case 3:
+			m.FileInfo = new(pbFileInfo)
+			return v.Message(m.FileInfo)
+		case 4:
+			m.Data = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorDirRequest struct {
+	Communicator uint32
+	Dst          string
+	Src          string
+	Exclude      []string
+}
+
+func (m *pbCommunicatorDirRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Communicator))
+	e.string(2, m.Dst)
+	e.string(3, m.Src)
+	e.strings(4, m.Exclude)
+}
+
+func (m *pbCommunicatorDirRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Communicator = v.Uint32()
+		case 2:
+			m.Dst = v.String()
+		case 3:
+			m.Src = v.String()
+		case 4:
+			m.Exclude = append(m.Exclude, v.String())
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorDownloadRequest struct {
+	Communicator uint32
+	Path         string
+}
+
+func (m *pbCommunicatorDownloadRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Communicator))
+	e.string(2, m.Path)
+}
+
+func (m *pbCommunicatorDownloadRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Communicator = v.Uint32()
+		case 2:
+			m.Path = v.String()
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorForwardRequest struct {
+	Communicator uint32
+	RemoteAddr   string
+	Data         []byte
+}
+
+func (m *pbCommunicatorForwardRequest) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Communicator))
+	e.string(2, m.RemoteAddr)
+	e.bytes(3, m.Data)
+}
+
+func (m *pbCommunicatorForwardRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Communicator = v.Uint32()
+		case 2:
+			m.RemoteAddr = v.String()
+		case 3:
+			m.Data = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbCommunicatorSession struct {
+	Communicator uint32
+}
+
+func (m *pbCommunicatorSession) marshalPB(e *pbEncoder) {
+	e.uint(1, uint64(m.Communicator))
+}
+
+func (m *pbCommunicatorSession) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Communicator = v.Uint32()
+		}
+		return nil
+	})
+}
+
+type pbChunk struct {
+	Data []byte
+}
+
+func (m *pbChunk) marshalPB(e *pbEncoder) {
+	e.bytes(1, m.Data)
+}
+
+func (m *pbChunk) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Data = v.Bytes()
+		}
+		return nil
+	})
+}
+
+type pbLogEntry struct {
+	Time      int64
+	Level     int32
+	Component string
+	Message   string
+}
+
+func (m *pbLogEntry) marshalPB(e *pbEncoder) {
+	e.int(1, m.Time)
+	e.int(2, int64(m.Level))
+	e.string(3, m.Component)
+	e.string(4, m.Message)
+}
+
+func (m *pbLogEntry) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Time = v.Int64()
+		case 2:
+			m.Level = v.Int32()
+		case 3:
+			m.Component = v.String()
+		case 4:
+			m.Message = v.String()
+		}
+		return nil
+	})
+}
+
+type pbHealth struct {
+	Calls    int64
+	Draining bool
+}
+
+func (m *pbHealth) marshalPB(e *pbEncoder) {
+	e.int(1, m.Calls)
+	e.bool(2, m.Draining)
+}
+
+func (m *pbHealth) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		switch num {
+		case 1:
+			m.Calls = v.Int64()
+		case 2:
+			m.Draining = v.Bool()
+		}
+		return nil
+	})
+}
+
+type pbShutdownRequest struct {
+	Timeout int64
+}
+
+func (m *pbShutdownRequest) marshalPB(e *pbEncoder) {
+	e.int(1, m.Timeout)
+}
+
+func (m *pbShutdownRequest) unmarshalPB(b []byte) error {
+	return pbDecode(b, func(num protowire.Number, v pbValue) error {
+		if num == 1 {
+			m.Timeout = v.Int64()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,966 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The services of the objects passed between Packer and the plugins. Both
+// ends serve them, with the grpcPeer as the service implementation, for the
+// objects they exported.
+
+const (
+	grpcUiName           = "packer.plugin.v1.Ui"
+	grpcHookName         = "packer.plugin.v1.Hook"
+	grpcArtifactName     = "packer.plugin.v1.Artifact"
+	grpcCommunicatorName = "packer.plugin.v1.Communicator"
+	grpcLogName          = "packer.plugin.v1.Log"
+	grpcLifecycleName    = "packer.plugin.v1.Lifecycle"
+
+	// grpcChunkSize is the maximum size of the data sent in a message,
+	// well below the default maximum size of the gRPC messages.
+	grpcChunkSize = transferChunkSize
+)
+
+// grpcStream returns the stream method name, converting the errors of
+// handler to gRPC statuses.
+func grpcStream(name string, clientStreams, serverStreams bool, handler func(p *grpcPeer, stream grpc.ServerStream) error) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName: name,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			if err := handler(srv.(*grpcPeer), stream); err != nil {
+				return toGRPCStatus(err)
+			}
+			return nil
+		},
+		ClientStreams: clientStreams,
+		ServerStreams: serverStreams,
+	}
+}
+
+// newStream opens a stream to method, described by desc, of the other end.
+func (p *grpcPeer) newStream(ctx context.Context, desc *grpc.StreamDesc, service string) (grpc.ClientStream, error) {
+	stream, err := p.conn.NewStream(ctx, desc, "/"+service+"/"+desc.StreamName)
+	if err != nil {
+		return nil, fromGRPCStatus(err)
+	}
+	return stream, nil
+}
+
+// chunkWriter sends what is written to it by chunks of at most
+// grpcChunkSize bytes.
+type chunkWriter func([]byte) error
+
+func (w chunkWriter) Write(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > grpcChunkSize {
+			chunk = chunk[:grpcChunkSize]
+		}
+		if err := w(chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		b = b[len(chunk):]
+	}
+	return n, nil
+}
+
+// sendChunks reads r until EOF, calling send with each chunk read.
+func sendChunks(r io.Reader, send func([]byte) error) error {
+	_, err := copyChunks(chunkWriter(send), r, nil)
+	return err
+}
+
+// An implementation of packer.Ui where the Ui is actually executed over a
+// gRPC connection.
+type grpcUi struct {
+	peer *grpcPeer
+	id   uint32
+
+	interactiveOnce sync.Once
+	interactive     bool
+}
+
+var _ packer.Ui = new(grpcUi)
+
+func (u *grpcUi) Ask(query string) (string, error) {
+	if !u.Interactive() {
+		return "", packer.ErrNonInteractive
+	}
+	var resp pbUiAskResponse
+	err := u.peer.invoke(context.Background(), "/"+grpcUiName+"/Ask", &pbUiRequest{Ui: u.id, Message: query}, &resp)
+	return resp.Answer, askError(err)
+}
+
+// AskWithOptions is like Ui.AskWithOptions. The other ends of older
+// versions of the SDK are asked with Ask.
+func (u *grpcUi) AskWithOptions(query string, opts packer.AskOptions) (string, error) {
+	if !u.Interactive() {
+		return unanswered(opts, packer.ErrNonInteractive)
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout+askTimeoutGrace)
+		defer cancel()
+	}
+	req := &pbUiAskRequest{Ui: u.id, Query: query, Default: opts.Default, Timeout: int64(opts.Timeout)}
+	var resp pbUiAskResponse
+	err := u.peer.conn.Invoke(ctx, "/"+grpcUiName+"/AskWithOptions", req, &resp)
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		return packer.AskWithOptions(baseUi{u}, query, opts)
+	case status.Code(err) == codes.DeadlineExceeded && ctx.Err() != nil:
+		log.Printf("[WARN] Ui.AskWithOptions not answered %s after its timeout", askTimeoutGrace)
+		return unanswered(opts, packer.ErrAskTimeout)
+	case err != nil:
+		return "", askError(fromGRPCStatus(err))
+	}
+	return resp.Answer, nil
+}
+
+// Interactive is like Ui.Interactive. The Uis of the other ends of older
+// versions of the SDK are assumed to be interactive.
+func (u *grpcUi) Interactive() bool {
+	u.interactiveOnce.Do(func() {
+		var resp pbUiInteractiveResponse
+		err := u.peer.conn.Invoke(context.Background(), "/"+grpcUiName+"/Interactive", &pbUiRequest{Ui: u.id}, &resp)
+		if err != nil && status.Code(err) != codes.Unimplemented {
+			log.Printf("Error in Ui.Interactive gRPC call: %s", err)
+		}
+		u.interactive = err != nil || resp.Interactive
+	})
+	return u.interactive
+}
+
+func (u *grpcUi) say(method, message string) {
+	err := u.peer.invoke(context.Background(), "/"+grpcUiName+"/"+method, &pbUiRequest{Ui: u.id, Message: message}, &pbEmpty{})
+	if err != nil {
+		log.Printf("Error in Ui.%s gRPC call: %s", method, err)
+	}
+}
+
+func (u *grpcUi) Say(message string)     { u.say("Say", message) }
+func (u *grpcUi) Message(message string) { u.say("Message", message) }
+func (u *grpcUi) Error(message string)   { u.say("Error", message) }
+
+func (u *grpcUi) Machine(t string, args ...string) {
+	err := u.peer.invoke(context.Background(), "/"+grpcUiName+"/Machine", &pbUiMachineRequest{Ui: u.id, Type: t, Args: args}, &pbEmpty{})
+	if err != nil {
+		log.Printf("Error in Ui.Machine gRPC call: %s", err)
+	}
+}
+
+// TrackProgress streams the size of each read of stream to the Ui.
+func (u *grpcUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	s, err := u.peer.newStream(context.Background(), &grpcUiService.Streams[0], grpcUiName)
+	if err == nil {
+		err = s.SendMsg(&pbUiProgress{Ui: u.id, Src: src, CurrentSize: currentSize, TotalSize: totalSize})
+	}
+	if err != nil {
+		log.Printf("Error in Ui.TrackProgress gRPC call: %s", err)
+		return stream
+	}
+	return &grpcProgressTracker{stream: s, ReadCloser: stream}
+}
+
+type grpcProgressTracker struct {
+	stream grpc.ClientStream
+	batch  progressBatch
+	io.ReadCloser
+}
+
+func (t *grpcProgressTracker) Read(b []byte) (int, error) {
+	n, err := t.ReadCloser.Read(b)
+	t.add(n, err != nil)
+	return n, err
+}
+
+func (t *grpcProgressTracker) add(read int, flush bool) {
+	n := t.batch.add(read, flush)
+	if n == 0 {
+		return
+	}
+	if err := t.stream.SendMsg(&pbUiProgress{Read: n}); err != nil {
+		log.Printf("Error in Ui.TrackProgress gRPC stream: %s", err)
+	}
+}
+
+func (t *grpcProgressTracker) Close() error {
+	t.add(0, true)
+	if err := t.stream.CloseSend(); err == nil {
+		t.stream.RecvMsg(&pbEmpty{})
+	}
+	return t.ReadCloser.Close()
+}
+
+func newPBUiRequest() pbMessage { return new(pbUiRequest) }
+
+var grpcUiService = grpc.ServiceDesc{
+	ServiceName: grpcUiName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcUiName, "Ask", newPBUiRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbUiRequest)
+			ui, err := srv.(*grpcPeer).ui(r.Ui)
+			if err != nil {
+				return nil, err
+			}
+			answer, err := ui.Ask(r.Message)
+			return &pbUiAskResponse{Answer: answer}, err
+		}),
+		grpcMethod(grpcUiName, "AskWithOptions", func() pbMessage { return new(pbUiAskRequest) }, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbUiAskRequest)
+			ui, err := srv.(*grpcPeer).ui(r.Ui)
+			if err != nil {
+				return nil, err
+			}
+			answer, err := packer.AskWithOptions(ui, r.Query, packer.AskOptions{Default: r.Default, Timeout: time.Duration(r.Timeout)})
+			return &pbUiAskResponse{Answer: answer}, err
+		}),
+		grpcMethod(grpcUiName, "Interactive", newPBUiRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			ui, err := srv.(*grpcPeer).ui(req.(*pbUiRequest).Ui)
+			if err != nil {
+				return nil, err
+			}
+			return &pbUiInteractiveResponse{Interactive: packer.IsInteractive(ui)}, nil
+		}),
+		grpcMethod(grpcUiName, "Say", newPBUiRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbUiRequest)
+			ui, err := srv.(*grpcPeer).ui(r.Ui)
+			if err != nil {
+				return nil, err
+			}
+			ui.Say(r.Message)
+			return &pbEmpty{}, nil
+		}),
+		grpcMethod(grpcUiName, "Message", newPBUiRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbUiRequest)
+			ui, err := srv.(*grpcPeer).ui(r.Ui)
+			if err != nil {
+				return nil, err
+			}
+			ui.Message(r.Message)
+			return &pbEmpty{}, nil
+		}),
+		grpcMethod(grpcUiName, "Error", newPBUiRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbUiRequest)
+			ui, err := srv.(*grpcPeer).ui(r.Ui)
+			if err != nil {
+				return nil, err
+			}
+			ui.Error(r.Message)
+			return &pbEmpty{}, nil
+		}),
+		grpcMethod(grpcUiName, "Machine", func() pbMessage { return new(pbUiMachineRequest) }, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbUiMachineRequest)
+			ui, err := srv.(*grpcPeer).ui(r.Ui)
+			if err != nil {
+				return nil, err
+			}
+			ui.Machine(r.Type, r.Args...)
+			return &pbEmpty{}, nil
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		grpcStream("TrackProgress", true, false, func(p *grpcPeer, stream grpc.ServerStream) error {
+			var first pbUiProgress
+			if err := stream.RecvMsg(&first); err != nil {
+				return err
+			}
+			ui, err := p.ui(first.Ui)
+			if err != nil {
+				return err
+			}
+			tracker := ui.TrackProgress(first.Src, first.CurrentSize, first.TotalSize, nopReadCloser{})
+			for {
+				var progress pbUiProgress
+				err := stream.RecvMsg(&progress)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					tracker.Close()
+					return err
+				}
+				readProgress(tracker, progress.Read)
+			}
+			// The tracker is closed once the client closed its own.
+			tracker.Close()
+			return stream.SendMsg(&pbEmpty{})
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+// An implementation of packer.Hook where the hook is actually executed over
+// a gRPC connection.
+type grpcHook struct {
+	peer *grpcPeer
+	id   uint32
+}
+
+func (h *grpcHook) Run(ctx context.Context, name string, ui packer.Ui, comm packer.Communicator, data interface{}) error {
+	b, err := jsonOrNil(data)
+	if err != nil {
+		return err
+	}
+	req := &pbHookRunRequest{
+		Hook:         h.id,
+		Name:         name,
+		Ui:           h.peer.export(ui),
+		Communicator: h.peer.export(comm),
+		Data:         b,
+	}
+	defer h.peer.unexport(req.Ui)
+	defer h.peer.unexport(req.Communicator)
+
+	return h.peer.invoke(ctx, "/"+grpcHookName+"/Run", req, &pbEmpty{})
+}
+
+var grpcHookService = grpc.ServiceDesc{
+	ServiceName: grpcHookName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcHookName, "Run", func() pbMessage { return new(pbHookRunRequest) }, func(srv interface{}, ctx context.Context, req pbMessage) (pbMessage, error) {
+			p := srv.(*grpcPeer)
+			r := req.(*pbHookRunRequest)
+			hook, err := p.hook(r.Hook)
+			if err != nil {
+				return nil, err
+			}
+			var data interface{}
+			if r.Data != nil {
+				if err := json.Unmarshal(r.Data, &data); err != nil {
+					return nil, err
+				}
+			}
+			var comm packer.Communicator
+			if r.Communicator != 0 {
+				comm = &grpcCommunicator{peer: p, id: r.Communicator}
+			}
+			return &pbEmpty{}, hook.Run(ctx, r.Name, &grpcUi{peer: p, id: r.Ui}, comm, data)
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+// An implementation of packer.Artifact where the artifact is actually
+// available over a gRPC connection. Its description is fetched once.
+type grpcArtifact struct {
+	peer *grpcPeer
+	id   uint32
+
+	once sync.Once
+	info pbArtifactInfo
+}
+
+func (a *grpcArtifact) describe() *pbArtifactInfo {
+	a.once.Do(func() {
+		err := a.peer.invoke(context.Background(), "/"+grpcArtifactName+"/Describe", &pbArtifactRequest{Artifact: a.id}, &a.info)
+		if err != nil {
+			log.Printf("Error in Artifact.Describe gRPC call: %s", err)
+		}
+	})
+	return &a.info
+}
+
+func (a *grpcArtifact) BuilderId() string    { return a.describe().BuilderID }
+func (a *grpcArtifact) Files() []string      { return a.describe().Files }
+func (a *grpcArtifact) Id() string           { return a.describe().ID }
+func (a *grpcArtifact) String() string       { return a.describe().Description }
+func (a *grpcArtifact) StateNames() []string { return a.describe().StateNames }
+
+func (a *grpcArtifact) State(name string) interface{} {
+	v, err := a.loadState(name)
+	if err != nil {
+		log.Printf("Error in Artifact.State gRPC call: %s", err)
+	}
+	return v
+}
+
+func (a *grpcArtifact) Destroy() error {
+	return a.peer.invoke(context.Background(), "/"+grpcArtifactName+"/Destroy", &pbArtifactRequest{Artifact: a.id}, &pbEmpty{})
+}
+
+func newPBArtifactRequest() pbMessage { return new(pbArtifactRequest) }
+
+var grpcArtifactService = grpc.ServiceDesc{
+	ServiceName: grpcArtifactName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcArtifactName, "Describe", newPBArtifactRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			a, err := srv.(*grpcPeer).artifact(req.(*pbArtifactRequest).Artifact)
+			if err != nil {
+				return nil, err
+			}
+			info := &pbArtifactInfo{
+				BuilderID:   a.BuilderId(),
+				ID:          a.Id(),
+				Files:       a.Files(),
+				Description: a.String(),
+			}
+			if lister, ok := a.(packer.ArtifactStateLister); ok {
+				info.StateNames = lister.StateNames()
+			}
+			return info, nil
+		}),
+		grpcMethod(grpcArtifactName, "State", newPBArtifactRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbArtifactRequest)
+			a, err := srv.(*grpcPeer).artifact(r.Artifact)
+			if err != nil {
+				return nil, err
+			}
+			b, err := jsonOrNil(a.State(r.StateName))
+			if err != nil {
+				return nil, err
+			}
+			return &pbArtifactStateResponse{Value: b}, nil
+		}),
+		grpcMethod(grpcArtifactName, "Destroy", newPBArtifactRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			a, err := srv.(*grpcPeer).artifact(req.(*pbArtifactRequest).Artifact)
+			if err != nil {
+				return nil, err
+			}
+			return &pbEmpty{}, a.Destroy()
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		grpcStream("StateStream", false, true, grpcArtifactStateStream),
+	},
+	Metadata: "plugin.proto",
+}
+
+func grpcArtifactStateStream(p *grpcPeer, stream grpc.ServerStream) error {
+	var req pbArtifactRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	a, err := p.artifact(req.Artifact)
+	if err != nil {
+		return err
+	}
+	b, err := jsonOrNil(a.State(req.StateName))
+	if err != nil {
+		return err
+	}
+	return sendChunks(bytes.NewReader(b), func(b []byte) error {
+		return stream.SendMsg(&pbChunk{Data: b})
+	})
+}
+
+// An implementation of packer.Communicator where the communicator is
+// actually executed over a gRPC connection.
+type grpcCommunicator struct {
+	peer *grpcPeer
+	id   uint32
+}
+
+// Start starts cmd, streaming its stdin, stdout and stderr. Like with
+// net/rpc, the exit status of cmd is 123 when the stream fails.
+func (c *grpcCommunicator) Start(ctx context.Context, cmd *packer.RemoteCmd) error {
+	stream, err := c.peer.newStream(ctx, &grpcCommunicatorService.Streams[0], grpcCommunicatorName)
+	if err != nil {
+		return err
+	}
+	req := &pbCommunicatorStartRequest{
+		Communicator: c.id,
+		Command:      cmd.Command,
+		Pty:          cmd.Pty,
+		Stdin:        cmd.Stdin != nil,
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return fromGRPCStatus(err)
+	}
+
+	go func() {
+		if cmd.Stdin != nil {
+			err := sendChunks(cmd.Stdin, func(b []byte) error {
+				return stream.SendMsg(&pbCommunicatorStartRequest{StdinData: b})
+			})
+			if err != nil {
+				log.Printf("[ERR] Error sending stdin over gRPC: %s", err)
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	go func() {
+		for {
+			var resp pbCommunicatorStartResponse
+			if err := stream.RecvMsg(&resp); err != nil {
+				log.Printf("[ERR] Error in Communicator.Start gRPC stream: %s", fromGRPCStatus(err))
+				cmd.SetExited(123)
+				return
+			}
+			if len(resp.Stdout) > 0 && cmd.Stdout != nil {
+				cmd.Stdout.Write(resp.Stdout)
+			}
+			if len(resp.Stderr) > 0 && cmd.Stderr != nil {
+				cmd.Stderr.Write(resp.Stderr)
+			}
+			if resp.Exited {
+				cmd.SetExited(int(resp.ExitStatus))
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (c *grpcCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	stream, err := c.peer.newStream(context.Background(), &grpcCommunicatorService.Streams[1], grpcCommunicatorName)
+	if err != nil {
+		return err
+	}
+	req := &pbCommunicatorUploadRequest{Communicator: c.id, Path: path}
+	if fi != nil && *fi != nil {
+		req.FileInfo = &pbFileInfo{
+			Name:    (*fi).Name(),
+			Size:    (*fi).Size(),
+			Mode:    uint32((*fi).Mode()),
+			ModTime: (*fi).ModTime().UnixNano(),
+		}
+	}
+	err = stream.SendMsg(req)
+	if err == nil {
+		err = sendChunks(r, func(b []byte) error {
+			return stream.SendMsg(&pbCommunicatorUploadRequest{Data: b})
+		})
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err == io.EOF {
+		// The upload failed on the other end, which RecvMsg returns.
+		err = nil
+	}
+	if err == nil {
+		err = stream.RecvMsg(&pbEmpty{})
+	}
+	if err != nil {
+		return fromGRPCStatus(err)
+	}
+	return nil
+}
+
+func (c *grpcCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	req := &pbCommunicatorDirRequest{Communicator: c.id, Dst: dst, Src: src, Exclude: exclude}
+	return c.peer.invoke(context.Background(), "/"+grpcCommunicatorName+"/UploadDir", req, &pbEmpty{})
+}
+
+func (c *grpcCommunicator) Download(path string, w io.Writer) error {
+	stream, err := c.peer.newStream(context.Background(), &grpcCommunicatorService.Streams[2], grpcCommunicatorName)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&pbCommunicatorDownloadRequest{Communicator: c.id, Path: path}); err != nil {
+		return fromGRPCStatus(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fromGRPCStatus(err)
+	}
+	for {
+		var chunk pbChunk
+		err := stream.RecvMsg(&chunk)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fromGRPCStatus(err)
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *grpcCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	req := &pbCommunicatorDirRequest{Communicator: c.id, Dst: dst, Src: src, Exclude: exclude}
+	return c.peer.invoke(context.Background(), "/"+grpcCommunicatorName+"/DownloadDir", req, &pbEmpty{})
+}
+
+// ForwardLocal listens on localAddr, and forwards the connections it accepts
+// to remoteAddr, each over a Forward stream.
+func (c *grpcCommunicator) ForwardLocal(localAddr string, remoteAddr string) (packer.PortForward, error) {
+	return listenForward(localAddr, func(conn net.Conn) {
+		defer conn.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream, err := c.peer.newStream(ctx, &grpcCommunicatorService.Streams[3], grpcCommunicatorName)
+		if err == nil {
+			err = stream.SendMsg(&pbCommunicatorForwardRequest{Communicator: c.id, RemoteAddr: remoteAddr})
+		}
+		if err != nil {
+			log.Printf("[ERR] Error forwarding a connection to %s: %s", remoteAddr, fromGRPCStatus(err))
+			return
+		}
+		pipeConns(conn, &grpcStreamConn{
+			send: func(b []byte) error {
+				return stream.SendMsg(&pbCommunicatorForwardRequest{Data: b})
+			},
+			recv: func() ([]byte, error) {
+				var chunk pbChunk
+				err := stream.RecvMsg(&chunk)
+				return chunk.Data, err
+			},
+			closeWrite: stream.CloseSend,
+			close:      cancel,
+		})
+	})
+}
+
+// ForwardRemote isn't supported over gRPC.
+func (c *grpcCommunicator) ForwardRemote(remoteAddr string, localAddr string) (packer.PortForward, error) {
+	return nil, fmt.Errorf("remote port forwards aren't supported over gRPC")
+}
+
+// grpcStreamConn reads and writes the data of a forwarded connection from
+// and to a stream.
+type grpcStreamConn struct {
+	send       func([]byte) error
+	recv       func() ([]byte, error)
+	closeWrite func() error
+	close      func()
+	buf        []byte
+}
+
+func (c *grpcStreamConn) Read(b []byte) (int, error) {
+	for len(c.buf) == 0 {
+		data, err := c.recv()
+		if err != nil {
+			return 0, err
+		}
+		c.buf = data
+	}
+	n := copy(b, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *grpcStreamConn) Write(b []byte) (int, error) {
+	if err := c.send(append([]byte(nil), b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *grpcStreamConn) CloseWrite() error {
+	if c.closeWrite != nil {
+		return c.closeWrite()
+	}
+	return nil
+}
+
+func (c *grpcStreamConn) Close() error {
+	if c.close != nil {
+		c.close()
+	}
+	return nil
+}
+
+// NewSession opens a session of the communicator on the other end. It is
+// the communicator itself when the other end doesn't support the sessions.
+func (c *grpcCommunicator) NewSession() (packer.Communicator, error) {
+	var resp pbCommunicatorSession
+	err := c.peer.conn.Invoke(context.Background(), "/"+grpcCommunicatorName+"/NewSession", &pbCommunicatorSession{Communicator: c.id}, &resp)
+	if status.Code(err) == codes.Unimplemented {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fromGRPCStatus(err)
+	}
+	return &grpcCommunicatorSession{grpcCommunicator{peer: c.peer, id: resp.Communicator}}, nil
+}
+
+// grpcCommunicatorSession is a session of a communicator of the other end.
+type grpcCommunicatorSession struct {
+	grpcCommunicator
+}
+
+func (s *grpcCommunicatorSession) Close() error {
+	return s.peer.invoke(context.Background(), "/"+grpcCommunicatorName+"/CloseSession", &pbCommunicatorSession{Communicator: s.id}, &pbEmpty{})
+}
+
+// grpcSession is a session exported, closed when it was opened by the
+// communicator.
+type grpcSession struct {
+	packer.Communicator
+	opened bool
+}
+
+func newPBCommunicatorDirRequest() pbMessage { return new(pbCommunicatorDirRequest) }
+func newPBCommunicatorSession() pbMessage    { return new(pbCommunicatorSession) }
+
+var grpcCommunicatorService = grpc.ServiceDesc{
+	ServiceName: grpcCommunicatorName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcCommunicatorName, "UploadDir", newPBCommunicatorDirRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbCommunicatorDirRequest)
+			comm, err := srv.(*grpcPeer).communicator(r.Communicator)
+			if err != nil {
+				return nil, err
+			}
+			return &pbEmpty{}, comm.UploadDir(r.Dst, r.Src, r.Exclude)
+		}),
+		grpcMethod(grpcCommunicatorName, "DownloadDir", newPBCommunicatorDirRequest, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			r := req.(*pbCommunicatorDirRequest)
+			comm, err := srv.(*grpcPeer).communicator(r.Communicator)
+			if err != nil {
+				return nil, err
+			}
+			return &pbEmpty{}, comm.DownloadDir(r.Src, r.Dst, r.Exclude)
+		}),
+		grpcMethod(grpcCommunicatorName, "NewSession", newPBCommunicatorSession, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			p := srv.(*grpcPeer)
+			comm, err := p.communicator(req.(*pbCommunicatorSession).Communicator)
+			if err != nil {
+				return nil, err
+			}
+			session := &grpcSession{Communicator: comm}
+			if sc, ok := comm.(packer.SessionCommunicator); ok {
+				if session.Communicator, err = sc.NewSession(); err != nil {
+					return nil, err
+				}
+				session.opened = true
+			}
+			return &pbCommunicatorSession{Communicator: p.export(session)}, nil
+		}),
+		grpcMethod(grpcCommunicatorName, "CloseSession", newPBCommunicatorSession, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			p := srv.(*grpcPeer)
+			id := req.(*pbCommunicatorSession).Communicator
+			v, err := p.object(id)
+			if err != nil {
+				return nil, err
+			}
+			session, ok := v.(*grpcSession)
+			if !ok {
+				return nil, status.Errorf(codes.NotFound, "object %d is not a communicator session", id)
+			}
+			p.unexport(id)
+			if closer, ok := session.Communicator.(io.Closer); ok && session.opened {
+				return &pbEmpty{}, closer.Close()
+			}
+			return &pbEmpty{}, nil
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		grpcStream("Start", true, true, grpcCommunicatorStart),
+		grpcStream("Upload", true, false, grpcCommunicatorUpload),
+		grpcStream("Download", false, true, grpcCommunicatorDownload),
+		grpcStream("Forward", true, true, grpcCommunicatorForward),
+	},
+	Metadata: "plugin.proto",
+}
+
+func grpcCommunicatorStart(p *grpcPeer, stream grpc.ServerStream) error {
+	var first pbCommunicatorStartRequest
+	if err := stream.RecvMsg(&first); err != nil {
+		return err
+	}
+	comm, err := p.communicator(first.Communicator)
+	if err != nil {
+		return err
+	}
+
+	// The output is written concurrently by the communicators.
+	var l sync.Mutex
+	send := func(resp *pbCommunicatorStartResponse) error {
+		l.Lock()
+		defer l.Unlock()
+		return stream.SendMsg(resp)
+	}
+	cmd := &packer.RemoteCmd{
+		Command: first.Command,
+		Pty:     first.Pty,
+		Stdout: chunkWriter(func(b []byte) error {
+			return send(&pbCommunicatorStartResponse{Stdout: b})
+		}),
+		Stderr: chunkWriter(func(b []byte) error {
+			return send(&pbCommunicatorStartResponse{Stderr: b})
+		}),
+	}
+	if first.Stdin {
+		stdinR, stdinW := io.Pipe()
+		defer stdinR.Close()
+		cmd.Stdin = stdinR
+		go func() {
+			for {
+				var req pbCommunicatorStartRequest
+				if err := stream.RecvMsg(&req); err != nil {
+					if err == io.EOF {
+						err = nil
+					}
+					stdinW.CloseWithError(err)
+					return
+				}
+				if _, err := stdinW.Write(req.StdinData); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	if err := comm.Start(stream.Context(), cmd); err != nil {
+		return err
+	}
+	status := cmd.Wait()
+	return send(&pbCommunicatorStartResponse{Exited: true, ExitStatus: int32(status)})
+}
+
+func grpcCommunicatorUpload(p *grpcPeer, stream grpc.ServerStream) error {
+	var first pbCommunicatorUploadRequest
+	if err := stream.RecvMsg(&first); err != nil {
+		return err
+	}
+	comm, err := p.communicator(first.Communicator)
+	if err != nil {
+		return err
+	}
+
+	var fi *os.FileInfo
+	if first.FileInfo != nil {
+		info := fileInfo{
+			N: first.FileInfo.Name,
+			S: first.FileInfo.Size,
+			M: os.FileMode(first.FileInfo.Mode),
+		}
+		if first.FileInfo.ModTime != 0 {
+			info.T = time.Unix(0, first.FileInfo.ModTime)
+		}
+		var osInfo os.FileInfo = info
+		fi = &osInfo
+	}
+
+	r, w := io.Pipe()
+	defer r.Close()
+	go func() {
+		for {
+			var req pbCommunicatorUploadRequest
+			if err := stream.RecvMsg(&req); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				w.CloseWithError(err)
+				return
+			}
+			if _, err := w.Write(req.Data); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := comm.Upload(first.Path, r, fi); err != nil {
+		return err
+	}
+	return stream.SendMsg(&pbEmpty{})
+}
+
+func grpcCommunicatorDownload(p *grpcPeer, stream grpc.ServerStream) error {
+	var req pbCommunicatorDownloadRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	comm, err := p.communicator(req.Communicator)
+	if err != nil {
+		return err
+	}
+	return comm.Download(req.Path, chunkWriter(func(b []byte) error {
+		return stream.SendMsg(&pbChunk{Data: b})
+	}))
+}
+
+func grpcCommunicatorForward(p *grpcPeer, stream grpc.ServerStream) error {
+	var first pbCommunicatorForwardRequest
+	if err := stream.RecvMsg(&first); err != nil {
+		return err
+	}
+	comm, err := p.communicator(first.Communicator)
+	if err != nil {
+		return err
+	}
+	guest, err := dialForward(comm, first.RemoteAddr)
+	if err != nil {
+		return err
+	}
+	pipeConns(guest, &grpcStreamConn{
+		send: func(b []byte) error {
+			return stream.SendMsg(&pbChunk{Data: b})
+		},
+		recv: func() ([]byte, error) {
+			var req pbCommunicatorForwardRequest
+			err := stream.RecvMsg(&req)
+			return req.Data, err
+		},
+	})
+	return nil
+}
+
+var grpcLogService = grpc.ServiceDesc{
+	ServiceName: grpcLogName,
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		grpcStream("Stream", true, false, func(p *grpcPeer, stream grpc.ServerStream) error {
+			for {
+				var e pbLogEntry
+				err := stream.RecvMsg(&e)
+				if err == io.EOF {
+					return stream.SendMsg(&pbEmpty{})
+				}
+				if err != nil {
+					return err
+				}
+				p.l.Lock()
+				h := p.logs
+				p.l.Unlock()
+				h(LogEntry{
+					Time:      time.Unix(0, e.Time),
+					Level:     packer.UiLevel(e.Level),
+					Component: e.Component,
+					Message:   e.Message,
+				})
+			}
+		}),
+	},
+	Metadata: "plugin.proto",
+}
+
+var grpcLifecycleService = grpc.ServiceDesc{
+	ServiceName: grpcLifecycleName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcMethod(grpcLifecycleName, "Health", newPBEmpty, func(srv interface{}, _ context.Context, _ pbMessage) (pbMessage, error) {
+			calls := &srv.(*GRPCServer).peer.calls
+			return &pbHealth{Calls: int64(calls.len()), Draining: calls.isDraining()}, nil
+		}),
+		grpcMethod(grpcLifecycleName, "Shutdown", func() pbMessage { return new(pbShutdownRequest) }, func(srv interface{}, _ context.Context, req pbMessage) (pbMessage, error) {
+			timeout := time.Duration(req.(*pbShutdownRequest).Timeout)
+			if err := srv.(*GRPCServer).Shutdown(timeout); err != nil {
+				return nil, err
+			}
+			return &pbEmpty{}, nil
+		}),
+	},
+	Metadata: "plugin.proto",
+}
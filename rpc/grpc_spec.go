@@ -0,0 +1,230 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// The specs, configurations and values of the gRPC protocol are encoded so
+// that they can be used by plugins written in any language: the hcldec specs
+// as Spec messages, and the cty values and types in their JSON encoding.
+
+func objectSpecToPB(spec hcldec.ObjectSpec) (*pbObjectSpec, error) {
+	res := &pbObjectSpec{Attributes: make(map[string]*pbSpec, len(spec))}
+	for k, v := range spec {
+		s, err := specToPB(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err)
+		}
+		res.Attributes[k] = s
+	}
+	return res, nil
+}
+
+func specToPB(spec hcldec.Spec) (*pbSpec, error) {
+	switch s := spec.(type) {
+	case nil:
+		return nil, nil
+	case hcldec.ObjectSpec:
+		o, err := objectSpecToPB(s)
+		return &pbSpec{Object: o}, err
+	case *hcldec.ObjectSpec:
+		o, err := objectSpecToPB(*s)
+		return &pbSpec{Object: o}, err
+	case *hcldec.AttrSpec:
+		t, err := json.Marshal(s.Type)
+		return &pbSpec{Attr: &pbAttrSpec{Name: s.Name, Type: t, Required: s.Required}}, err
+	case *hcldec.BlockSpec:
+		nested, err := specToPB(s.Nested)
+		return &pbSpec{Block: &pbBlockSpec{TypeName: s.TypeName, Nested: nested, Required: s.Required}}, err
+	case *hcldec.BlockListSpec:
+		nested, err := specToPB(s.Nested)
+		return &pbSpec{BlockList: &pbBlockListSpec{
+			TypeName: s.TypeName,
+			Nested:   nested,
+			MinItems: int64(s.MinItems),
+			MaxItems: int64(s.MaxItems),
+		}}, err
+	case *hcldec.BlockAttrsSpec:
+		t, err := json.Marshal(s.ElementType)
+		return &pbSpec{BlockAttrs: &pbBlockAttrsSpec{TypeName: s.TypeName, ElementType: t, Required: s.Required}}, err
+	case *hcldec.BlockObjectSpec:
+		nested, err := specToPB(s.Nested)
+		return &pbSpec{BlockObject: &pbBlockObjectSpec{
+			TypeName:   s.TypeName,
+			LabelNames: s.LabelNames,
+			Nested:     nested,
+		}}, err
+	default:
+		return nil, fmt.Errorf("unsupported spec type %T", spec)
+	}
+}
+
+func objectSpecFromPB(spec *pbObjectSpec) (hcldec.ObjectSpec, error) {
+	res := hcldec.ObjectSpec{}
+	if spec == nil {
+		return res, nil
+	}
+	for k, v := range spec.Attributes {
+		s, err := specFromPB(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err)
+		}
+		res[k] = s
+	}
+	return res, nil
+}
+
+func specFromPB(spec *pbSpec) (hcldec.Spec, error) {
+	switch {
+	case spec == nil:
+		return nil, nil
+	case spec.Object != nil:
+		return objectSpecFromPB(spec.Object)
+	case spec.Attr != nil:
+		var t cty.Type
+		if err := json.Unmarshal(spec.Attr.Type, &t); err != nil {
+			return nil, err
+		}
+		return &hcldec.AttrSpec{Name: spec.Attr.Name, Type: t, Required: spec.Attr.Required}, nil
+	case spec.Block != nil:
+		nested, err := specFromPB(spec.Block.Nested)
+		return &hcldec.BlockSpec{TypeName: spec.Block.TypeName, Nested: nested, Required: spec.Block.Required}, err
+	case spec.BlockList != nil:
+		nested, err := specFromPB(spec.BlockList.Nested)
+		return &hcldec.BlockListSpec{
+			TypeName: spec.BlockList.TypeName,
+			Nested:   nested,
+			MinItems: int(spec.BlockList.MinItems),
+			MaxItems: int(spec.BlockList.MaxItems),
+		}, err
+	case spec.BlockAttrs != nil:
+		var t cty.Type
+		if err := json.Unmarshal(spec.BlockAttrs.ElementType, &t); err != nil {
+			return nil, err
+		}
+		return &hcldec.BlockAttrsSpec{TypeName: spec.BlockAttrs.TypeName, ElementType: t, Required: spec.BlockAttrs.Required}, nil
+	case spec.BlockObject != nil:
+		nested, err := specFromPB(spec.BlockObject.Nested)
+		return &hcldec.BlockObjectSpec{
+			TypeName:   spec.BlockObject.TypeName,
+			LabelNames: spec.BlockObject.LabelNames,
+			Nested:     nested,
+		}, err
+	default:
+		return nil, fmt.Errorf("empty spec")
+	}
+}
+
+func functionSignatureToPB(sig packer.FunctionSignature) (*pbFunctionSignature, error) {
+	t, err := json.Marshal(sig.ReturnType)
+	if err != nil {
+		return nil, err
+	}
+	res := &pbFunctionSignature{Description: sig.Description, ReturnType: t}
+	for _, p := range sig.Params {
+		pb, err := functionParameterToPB(p)
+		if err != nil {
+			return nil, err
+		}
+		res.Params = append(res.Params, pb)
+	}
+	if sig.VarParam != nil {
+		if res.VarParam, err = functionParameterToPB(*sig.VarParam); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func functionParameterToPB(p packer.FunctionParameter) (*pbFunctionParameter, error) {
+	t, err := json.Marshal(p.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", p.Name, err)
+	}
+	return &pbFunctionParameter{Name: p.Name, Description: p.Description, Type: t, AllowNull: p.AllowNull}, nil
+}
+
+func functionSignatureFromPB(sig *pbFunctionSignature) (packer.FunctionSignature, error) {
+	res := packer.FunctionSignature{Description: sig.Description}
+	if err := json.Unmarshal(sig.ReturnType, &res.ReturnType); err != nil {
+		return res, err
+	}
+	for _, p := range sig.Params {
+		param, err := functionParameterFromPB(p)
+		if err != nil {
+			return res, err
+		}
+		res.Params = append(res.Params, param)
+	}
+	if sig.VarParam != nil {
+		param, err := functionParameterFromPB(sig.VarParam)
+		if err != nil {
+			return res, err
+		}
+		res.VarParam = &param
+	}
+	return res, nil
+}
+
+func functionParameterFromPB(p *pbFunctionParameter) (packer.FunctionParameter, error) {
+	res := packer.FunctionParameter{Name: p.Name, Description: p.Description, AllowNull: p.AllowNull}
+	if err := json.Unmarshal(p.Type, &res.Type); err != nil {
+		return res, fmt.Errorf("%s: %s", p.Name, err)
+	}
+	return res, nil
+}
+
+func configsToPB(configs []interface{}) ([]*pbConfig, error) {
+	res := make([]*pbConfig, len(configs))
+	for i, c := range configs {
+		if v, ok := c.(cty.Value); ok {
+			b, err := ctyjson.Marshal(v, cty.DynamicPseudoType)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = &pbConfig{Cty: b}
+			continue
+		}
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = &pbConfig{JSON: b}
+	}
+	return res, nil
+}
+
+func configsFromPB(configs []*pbConfig) ([]interface{}, error) {
+	res := make([]interface{}, len(configs))
+	for i, c := range configs {
+		if c.Cty != nil {
+			v, err := ctyjson.Unmarshal(c.Cty, cty.DynamicPseudoType)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = v
+			continue
+		}
+		if err := json.Unmarshal(c.JSON, &res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// jsonOrNil returns v encoded in JSON, or nil when v is nil.
+func jsonOrNil(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
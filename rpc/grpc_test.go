@@ -0,0 +1,423 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testGRPCClientServer(t *testing.T) (*GRPCClient, *GRPCServer) {
+	clientConn, serverConn := testConn(t)
+
+	server, err := NewGRPCServer(serverConn)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	server.SetFeatures(SupportedFeatures)
+	go server.Serve()
+
+	client, err := NewGRPCClient(clientConn)
+	if err != nil {
+		server.Close()
+		t.Fatalf("err: %s", err)
+	}
+	client.SetFeatures(SupportedFeatures)
+
+	return client, server
+}
+
+func TestGRPCBuilder(t *testing.T) {
+	b := &packersdk.MockBuilder{ArtifactId: "foo", PrepareWarnings: []string{"careful"}}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	spec := bClient.ConfigSpec()
+	if !reflect.DeepEqual(spec, b.ConfigSpec()) {
+		t.Fatalf("bad spec: %#v", spec)
+	}
+
+	config := cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("bar")})
+	_, warnings, err := bClient.Prepare(config, map[string]interface{}{"packer_force": true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(warnings, []string{"careful"}) {
+		t.Fatalf("bad warnings: %#v", warnings)
+	}
+	expected := []interface{}{config, map[string]interface{}{"packer_force": true}}
+	if !reflect.DeepEqual(b.PrepareConfig, expected) {
+		t.Fatalf("bad config: %#v", b.PrepareConfig)
+	}
+
+	ui := &testUi{}
+	hook := &packersdk.MockHook{}
+	artifact, err := bClient.Run(context.Background(), ui, hook)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if artifact.Id() != "foo" {
+		t.Fatalf("bad artifact ID: %s", artifact.Id())
+	}
+	if !hook.RunCalled || hook.RunName != packersdk.HookProvision {
+		t.Fatal("hook should be run")
+	}
+	if _, ok := hook.RunComm.(*grpcCommunicator); !ok {
+		t.Fatalf("bad communicator: %#v", hook.RunComm)
+	}
+
+	b.RunErrResult = true
+	if _, err := bClient.Run(context.Background(), ui, hook); err == nil || err.Error() != "foo" {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestGRPCBuilder_cancel(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	b := &packersdk.MockBuilder{RunFn: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := client.Builder().Run(ctx, &testUi{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("bad error: %v", err)
+	}
+	<-cancelled
+}
+
+func TestGRPCProvisioner(t *testing.T) {
+	p := new(packersdk.MockProvisioner)
+	p.ProvFunc = func(context.Context) error {
+		p.ProvUi.Say("hello")
+		return nil
+	}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterProvisioner(p)
+	pClient := client.Provisioner()
+
+	if spec := pClient.ConfigSpec(); !reflect.DeepEqual(spec, p.ConfigSpec()) {
+		t.Fatalf("bad spec: %#v", spec)
+	}
+
+	config := cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("bar")})
+	if err := pClient.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(p.PrepConfigs, []interface{}{config}) {
+		t.Fatalf("bad config: %#v", p.PrepConfigs)
+	}
+
+	ui := &testUi{}
+	comm := new(packersdk.MockCommunicator)
+	if err := pClient.Provision(context.Background(), ui, comm, map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.ProvCalled {
+		t.Fatal("provision should be called")
+	}
+	if _, ok := p.ProvCommunicator.(*grpcCommunicator); !ok {
+		t.Fatalf("bad communicator: %#v", p.ProvCommunicator)
+	}
+	if !ui.sayCalled || ui.sayMessage != "hello" {
+		t.Fatalf("bad ui: %#v", ui)
+	}
+}
+
+func TestGRPCPostProcessor(t *testing.T) {
+	p := new(TestPostProcessor)
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterPostProcessor(p)
+	ppClient := client.PostProcessor()
+
+	config := map[string]interface{}{"foo": "bar"}
+	if err := ppClient.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.configCalled || !reflect.DeepEqual(p.configVal, []interface{}{config}) {
+		t.Fatalf("bad config: %#v", p.configVal)
+	}
+
+	a := &packersdk.MockArtifact{IdValue: "ppTestId"}
+	artifact, keep, forceOverride, err := ppClient.PostProcess(context.Background(), new(testUi), a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.ppCalled || p.ppArtifactId != "ppTestId" {
+		t.Fatalf("bad artifact: %#v", p.ppArtifact)
+	}
+	if keep || forceOverride {
+		t.Fatalf("bad keep: %t, %t", keep, forceOverride)
+	}
+	if artifact.Id() != testPostProcessorArtifact.Id() {
+		t.Fatalf("bad artifact ID: %s", artifact.Id())
+	}
+}
+
+func TestGRPCDatasource(t *testing.T) {
+	d := &testDatasource{
+		outputSpec:   (&packersdk.MockDatasource{}).OutputSpec(),
+		executeValue: cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("bar")}),
+	}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(d)
+	dsClient := client.Datasource()
+
+	config := cty.ObjectVal(map[string]cty.Value{"foo": cty.StringVal("bar")})
+	if err := dsClient.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !d.configCalled || !reflect.DeepEqual(d.configVal, []interface{}{config}) {
+		t.Fatalf("bad config: %#v", d.configVal)
+	}
+
+	if spec := dsClient.OutputSpec(); !d.outputSpecCalled || !reflect.DeepEqual(spec, d.outputSpec) {
+		t.Fatalf("bad output spec: %#v", spec)
+	}
+
+	value, err := dsClient.Execute()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !d.executeCalled || !value.RawEquals(d.executeValue) {
+		t.Fatalf("bad value: %#v", value)
+	}
+}
+
+func TestGRPCFunction(t *testing.T) {
+	f := new(packersdk.MockFunction)
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterFunction(f)
+	fClient := client.Function()
+
+	if sig := fClient.Signature(); !reflect.DeepEqual(sig, f.Signature()) {
+		t.Fatalf("bad signature: %#v", sig)
+	}
+	v, err := fClient.Call([]cty.Value{cty.StringVal("-"), cty.StringVal("a"), cty.StringVal("b")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !v.RawEquals(cty.StringVal("a-b")) {
+		t.Fatalf("bad value: %#v", v)
+	}
+}
+
+func TestGRPCUi(t *testing.T) {
+	ui := &testUi{}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	id := client.peer.export(ui)
+	uiClient := &grpcUi{peer: server.peer, id: id}
+
+	uiClient.Say("say")
+	uiClient.Message("message")
+	uiClient.Error("error")
+	uiClient.Machine("type", "a", "b")
+	if ui.sayMessage != "say" || ui.messageMessage != "message" || ui.errorMessage != "error" {
+		t.Fatalf("bad ui: %#v", ui)
+	}
+	if ui.machineType != "type" || !reflect.DeepEqual(ui.machineArgs, []string{"a", "b"}) {
+		t.Fatalf("bad machine: %#v", ui)
+	}
+	if answer, err := uiClient.Ask("query"); err != nil || answer != "foo" || ui.askQuery != "query" {
+		t.Fatalf("bad ask: %q, %v", answer, err)
+	}
+
+	tracked := uiClient.TrackProgress("src", 0, 4, io.NopCloser(strings.NewReader("data")))
+	if b, err := io.ReadAll(tracked); err != nil || string(b) != "data" {
+		t.Fatalf("bad read: %q, %v", b, err)
+	}
+	tracked.Close()
+	if !ui.trackProgressCalled || !ui.progressBarAddCalled || !ui.progressBarCloseCalled {
+		t.Fatalf("bad progress tracking: %#v", ui)
+	}
+
+	client.peer.unexport(id)
+	if _, err := uiClient.Ask("query"); err == nil {
+		t.Fatal("should fail once the Ui isn't exported")
+	}
+}
+
+func TestGRPCArtifact(t *testing.T) {
+	a := &packersdk.MockArtifact{
+		BuilderIdValue: "builder",
+		FilesValue:     []string{"a", "b"},
+		IdValue:        "id",
+		StateValues:    map[string]interface{}{"foo": "bar"},
+	}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	aClient := &grpcArtifact{peer: client.peer, id: server.peer.export(a)}
+	if aClient.BuilderId() != "builder" || aClient.Id() != "id" || aClient.String() != a.String() {
+		t.Fatalf("bad artifact: %#v", aClient.describe())
+	}
+	if !reflect.DeepEqual(aClient.Files(), []string{"a", "b"}) {
+		t.Fatalf("bad files: %#v", aClient.Files())
+	}
+	if !reflect.DeepEqual(aClient.StateNames(), []string{"foo"}) {
+		t.Fatalf("bad state names: %#v", aClient.StateNames())
+	}
+	if v := aClient.State("foo"); v != "bar" {
+		t.Fatalf("bad state: %#v", v)
+	}
+	if v := aClient.State("unknown"); v != nil {
+		t.Fatalf("bad state: %#v", v)
+	}
+	if err := aClient.Destroy(); err != nil || !a.DestroyCalled {
+		t.Fatalf("should be destroyed: %v", err)
+	}
+}
+
+func TestGRPCCommunicator(t *testing.T) {
+	c := &packersdk.MockCommunicator{
+		StartStdout:     "outfoo\n",
+		StartStderr:     "errfoo\n",
+		StartExitStatus: 42,
+		DownloadData:    "download",
+	}
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	remote := &grpcCommunicator{peer: client.peer, id: server.peer.export(c)}
+
+	// Unlike with net/rpc, stdout and stderr are sent over the same stream,
+	// so they are buffered rather than read one after the other.
+	stdinR, stdinW := io.Pipe()
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: "foo",
+		Stdin:   stdinR,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := remote.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	stdinW.Write([]byte("info\n"))
+	stdinW.Close()
+	if status := cmd.Wait(); status != 42 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+	if stdout.String() != "outfoo\n" || stderr.String() != "errfoo\n" {
+		t.Fatalf("bad output: %q, %q", stdout.String(), stderr.String())
+	}
+	if c.StartCmd.Command != "foo" || c.StartStdin != "info\n" {
+		t.Fatalf("bad command: %q, stdin %q", c.StartCmd.Command, c.StartStdin)
+	}
+
+	var fi os.FileInfo = dummyFileInfo{}
+	if err := remote.Upload("path", strings.NewReader("upload"), &fi); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.UploadPath != "path" || c.UploadData != "upload" {
+		t.Fatalf("bad upload: %q, %q", c.UploadPath, c.UploadData)
+	}
+
+	var buf bytes.Buffer
+	if err := remote.Download("path", &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.DownloadPath != "path" || buf.String() != "download" {
+		t.Fatalf("bad download: %q, %q", c.DownloadPath, buf.String())
+	}
+
+	if err := remote.UploadDir("dst", "src", []string{"ex"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.UploadDirDst != "dst" || c.UploadDirSrc != "src" || !reflect.DeepEqual(c.UploadDirExclude, []string{"ex"}) {
+		t.Fatalf("bad upload dir: %#v", c)
+	}
+}
+
+func TestGRPCSpec(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: true},
+		"tags": &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.String)},
+		"block": &hcldec.BlockSpec{TypeName: "block", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		}},
+		"blocks": &hcldec.BlockListSpec{TypeName: "blocks", Nested: hcldec.ObjectSpec{}, MinItems: 1, MaxItems: 2},
+		"attrs":  &hcldec.BlockAttrsSpec{TypeName: "attrs", ElementType: cty.Bool},
+		"object": &hcldec.BlockObjectSpec{TypeName: "object", LabelNames: []string{"name"}, Nested: hcldec.ObjectSpec{}},
+	}
+	resp, err := configSpecResponse(spec)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, err := pbCodec{}.Marshal(resp)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var decoded pbConfigSpecResponse
+	if err := (pbCodec{}).Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	res, err := objectSpecFromPB(decoded.Spec)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(res, spec) {
+		t.Fatalf("bad spec: %#v", res)
+	}
+}
+
+func TestGRPCErrorClass(t *testing.T) {
+	cases := []struct {
+		err   error
+		check func(error) bool
+	}{
+		{sdkerrors.WrapRetryable(errors.New("foo")), sdkerrors.IsRetryable},
+		{sdkerrors.UserErrorf("foo"), sdkerrors.IsUserError},
+		{sdkerrors.WrapFatalInfra(errors.New("foo")), sdkerrors.IsFatalInfra},
+		{context.Canceled, func(err error) bool { return errors.Is(err, context.Canceled) }},
+		{errors.New("foo"), func(err error) bool { return sdkerrors.Classify(err) == sdkerrors.ClassUnknown }},
+	}
+	for _, tc := range cases {
+		err := fromGRPCStatus(toGRPCStatus(tc.err))
+		if !tc.check(err) {
+			t.Errorf("%v: bad class of %#v", tc.err, err)
+		}
+		if err.Error() != tc.err.Error() {
+			t.Errorf("bad message: %q", err.Error())
+		}
+	}
+}
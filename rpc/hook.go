@@ -5,9 +5,9 @@ package rpc
 
 import (
 	"context"
-	"log"
 	"sync"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -21,7 +21,7 @@ type hook struct {
 // as part of a Golang RPC server.
 type HookServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel func(*multistep.CancelCause)
 
 	hook packersdk.Hook
 	lock sync.Mutex
@@ -41,18 +41,7 @@ func (h *hook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packe
 	server.RegisterUi(ui)
 	go server.Serve()
 
-	done := make(chan interface{})
-	defer close(done)
-	go func() {
-		select {
-		case <-ctx.Done():
-			log.Printf("Cancelling hook after context cancellation %v", ctx.Err())
-			if err := h.client.Call(h.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
-				log.Printf("Error cancelling builder: %s", err)
-			}
-		case <-done:
-		}
-	}()
+	defer h.cancelOnDone(ctx, nextId, "hook")()
 
 	args := HookRunArgs{
 		Name:     name,
@@ -72,10 +61,14 @@ func (h *HookServer) Run(args *HookRunArgs, reply *interface{}) error {
 
 	h.lock.Lock()
 	if h.context == nil {
-		h.context, h.contextCancel = context.WithCancel(context.Background())
+		h.context, h.contextCancel = multistep.WithCancelCause(context.Background())
 	}
 	h.lock.Unlock()
-	if err := h.hook.Run(h.context, args.Name, client.Ui(), client.Communicator(), args.Data); err != nil {
+
+	ctx, done := h.mux.callContext(h.context, args.StreamId)
+	defer done()
+
+	if err := h.hook.Run(ctx, args.Name, client.Ui(), client.Communicator(), args.Data); err != nil {
 		return NewBasicError(err)
 	}
 
@@ -83,10 +76,10 @@ func (h *HookServer) Run(args *HookRunArgs, reply *interface{}) error {
 	return nil
 }
 
-func (h *HookServer) Cancel(args *interface{}, reply *interface{}) error {
+func (h *HookServer) Cancel(args *CancelArgs, reply *interface{}) error {
 	h.lock.Lock()
 	if h.contextCancel != nil {
-		h.contextCancel()
+		h.contextCancel(args.cause())
 	}
 	h.lock.Unlock()
 	return nil
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// The Lifecycle endpoint of a plugin server tells its health, so that Packer
+// can tell a busy plugin from a hung one, and shuts it down gracefully: the
+// calls in flight are cancelled and their cleanups waited for, and the logs
+// are flushed. Packer can then close the connection, and kill the plugin
+// if it didn't shut down in time.
+
+// ErrLifecycleUnsupported is returned by the Health and Shutdown methods of
+// the clients when the server doesn't support FeatureLifecycle.
+var ErrLifecycleUnsupported = errors.New("the plugin doesn't support health checks and graceful shutdowns")
+
+// Health is the health of a plugin server.
+type Health struct {
+	// Calls is the number of calls in flight, like builds or provisionings.
+	// A plugin answering with calls in flight is busy, not hung.
+	Calls int
+	// Draining is true once the server is shutting down.
+	Draining bool
+}
+
+// Shutdown cancels the calls in flight, and the ones made afterwards, waits
+// for them to return, for at most timeout when it's not 0, and flushes the
+// logs. It returns an error when calls are still in flight after timeout.
+// The connection is left open, Packer closes it after a shutdown.
+func (s *PluginServer) Shutdown(timeout time.Duration) error {
+	return shutdown(&s.mux.calls, s.logs, timeout)
+}
+
+func shutdown(calls *callSet, logs *logWriter, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	log.Printf("[INFO] Shutting down, %d calls in flight", calls.len())
+	err := calls.drain(ctx)
+	if err != nil {
+		log.Printf("[ERR] Error shutting down: %s", err)
+	}
+	logs.flush()
+	return err
+}
+
+// LifecycleServer is the server of the Lifecycle endpoint of a PluginServer.
+type LifecycleServer struct {
+	server *PluginServer
+}
+
+func (s *LifecycleServer) Health(args interface{}, reply *Health) error {
+	*reply = Health{
+		Calls:    s.server.mux.calls.len(),
+		Draining: s.server.mux.calls.isDraining(),
+	}
+	return nil
+}
+
+func (s *LifecycleServer) Shutdown(timeout time.Duration, reply *interface{}) error {
+	if err := s.server.Shutdown(timeout); err != nil {
+		return NewBasicError(err)
+	}
+	return nil
+}
+
+// Health returns the health of the server. It returns the error of ctx when
+// the server doesn't answer before ctx is done, meaning it's hung.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	if !c.mux.has(FeatureLifecycle) {
+		return nil, ErrLifecycleUnsupported
+	}
+	h := new(Health)
+	if err := callContext(ctx, c.client, DefaultLifecycleEndpoint+".Health", new(interface{}), h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Shutdown shuts the server down gracefully, see PluginServer.Shutdown. The
+// server waits for its calls in flight until the deadline of ctx, if any. It
+// returns the error of ctx when the server didn't shut down before ctx is
+// done, which should then be killed.
+func (c *Client) Shutdown(ctx context.Context) error {
+	if !c.mux.has(FeatureLifecycle) {
+		return ErrLifecycleUnsupported
+	}
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return callContext(ctx, c.client, DefaultLifecycleEndpoint+".Shutdown", timeout, new(interface{}))
+}
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type lifecycleClient interface {
+	Health(context.Context) (*Health, error)
+	Shutdown(context.Context) error
+	Builder() packersdk.Builder
+}
+
+func testLifecycle(t *testing.T, client lifecycleClient, register func(packersdk.Builder)) {
+	b := new(packersdk.MockBuilder)
+	started := make(chan struct{})
+	cleaned := make(chan struct{})
+	b.RunFn = func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		// The build cleans up after its cancellation.
+		time.Sleep(50 * time.Millisecond)
+		close(cleaned)
+	}
+	register(b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h, err := client.Health(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if h.Calls != 0 || h.Draining {
+		t.Fatalf("bad: %#v", h)
+	}
+
+	ran := make(chan error)
+	go func() {
+		_, err := client.Builder().Run(context.Background(), new(testUi), new(packersdk.MockHook))
+		ran <- err
+	}()
+	<-started
+	h, err = client.Health(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if h.Calls != 1 {
+		t.Fatalf("bad: %#v", h)
+	}
+
+	if err := client.Shutdown(ctx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	select {
+	case <-cleaned:
+	default:
+		t.Fatal("shut down before the cleanup of the build")
+	}
+	// The build was cancelled, calling its hook may fail.
+	if err := <-ran; err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("err: %s", err)
+	}
+	h, err = client.Health(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if h.Calls != 0 || !h.Draining {
+		t.Fatalf("bad: %#v", h)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	testLifecycle(t, client, func(b packersdk.Builder) { server.RegisterBuilder(b) })
+}
+
+func TestGRPCLifecycle(t *testing.T) {
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	testLifecycle(t, client, func(b packersdk.Builder) { server.RegisterBuilder(b) })
+}
+
+func TestLifecycle_olderServer(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	client.SetFeatures([]string{})
+
+	if _, err := client.Health(context.Background()); !errors.Is(err, ErrLifecycleUnsupported) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestShutdown_timeout(t *testing.T) {
+	var calls callSet
+	_, done := calls.start(context.Background(), 1)
+	defer done()
+
+	if err := shutdown(&calls, nil, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdkerrors"
+)
+
+// ErrServerBusy is returned to the calls made when a plugin server serves
+// as many calls as it can, and has as many calls waiting as it can, see
+// ServerLimits. It is retryable.
+var ErrServerBusy = sdkerrors.WrapRetryable(errors.New("the plugin is serving too many calls, try again later"))
+
+// ServerLimits bound the calls a plugin server serves at once over its
+// connection to Packer, so that a misbehaving host, or many provisioners
+// uploading directories in parallel, can't spawn an unbounded number of
+// goroutines in the plugin.
+//
+// The long calls, like Builder.Run, take a slot until they return, so that
+// MaxConcurrentCalls must leave room for the calls they lead Packer to make,
+// like the ones to the communicator of the build. The cancellations and the
+// Lifecycle calls are never limited.
+type ServerLimits struct {
+	// MaxConcurrentCalls is the number of calls served at once. There is no
+	// limit when it is 0.
+	MaxConcurrentCalls int
+	// MaxQueuedCalls is the number of gRPC calls waiting for a slot. The
+	// calls made once it is reached fail with ErrServerBusy. The net/rpc
+	// calls never wait: as a connection reads its calls one after the other,
+	// a waiting call would hold up the ones behind it, like its
+	// cancellation, so they fail with ErrServerBusy as soon as there is no
+	// slot.
+	MaxQueuedCalls int
+}
+
+// DefaultServerLimits are the limits of the servers returned by NewServer
+// and NewGRPCServer. Plugins can change them before serving.
+var DefaultServerLimits = ServerLimits{
+	MaxConcurrentCalls: 64,
+	MaxQueuedCalls:     1024,
+}
+
+// callLimiter enforces ServerLimits. A nil callLimiter doesn't limit.
+type callLimiter struct {
+	slots     chan struct{}
+	maxQueued int32
+	queued    int32
+}
+
+func newCallLimiter(limits ServerLimits) *callLimiter {
+	if limits.MaxConcurrentCalls <= 0 {
+		return nil
+	}
+	return &callLimiter{
+		slots:     make(chan struct{}, limits.MaxConcurrentCalls),
+		maxQueued: int32(limits.MaxQueuedCalls),
+	}
+}
+
+// acquire waits for a slot, and returns ErrServerBusy when too many calls
+// wait already, or the error of ctx when it is done first.
+func (l *callLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if atomic.AddInt32(&l.queued, 1) > l.maxQueued {
+		atomic.AddInt32(&l.queued, -1)
+		return ErrServerBusy
+	}
+	defer atomic.AddInt32(&l.queued, -1)
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tryAcquire takes a slot if there is one, and returns ErrServerBusy
+// otherwise.
+func (l *callLimiter) tryAcquire() error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		return ErrServerBusy
+	}
+}
+
+// release frees the slot of a call returning.
+func (l *callLimiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// SetLimits sets the limits of the calls served over the connection,
+// DefaultServerLimits until it is called.
+func (s *PluginServer) SetLimits(limits ServerLimits) {
+	s.mux.setLimiter(newCallLimiter(limits))
+}
+
+// SetLimits is PluginServer.SetLimits.
+func (s *GRPCServer) SetLimits(limits ServerLimits) {
+	s.peer.limiter = newCallLimiter(limits)
+}
+
+func (m *muxBroker) setLimiter(l *callLimiter) {
+	m.Lock()
+	defer m.Unlock()
+	m.limiter = l
+}
+
+func (m *muxBroker) callLimiter() *callLimiter {
+	m.Lock()
+	defer m.Unlock()
+	return m.limiter
+}
+
+// unlimitedMethod tells whether the net/rpc method isn't limited.
+func unlimitedMethod(method string) bool {
+	return strings.HasPrefix(method, DefaultLifecycleEndpoint+".") || strings.HasSuffix(method, ".Cancel")
+}
+
+// limitedServerCodec is a rpc.ServerCodec taking a slot of its limiter for
+// each call it reads, before net/rpc starts a goroutine serving it. It never
+// waits for a slot, as that would stop reading the connection: failing to
+// get one fails the call at once, net/rpc answering it with the error of
+// ReadRequestBody.
+type limitedServerCodec struct {
+	rpc.ServerCodec
+	limiter *callLimiter
+
+	// The request being read.
+	limited bool
+	seq     uint64
+
+	l        sync.Mutex
+	acquired map[uint64]bool
+}
+
+func newLimitedServerCodec(c rpc.ServerCodec, limiter *callLimiter) *limitedServerCodec {
+	return &limitedServerCodec{
+		ServerCodec: c,
+		limiter:     limiter,
+		acquired:    make(map[uint64]bool),
+	}
+}
+
+func (c *limitedServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err == nil {
+		c.limited, c.seq = !unlimitedMethod(r.ServiceMethod), r.Seq
+	}
+	return err
+}
+
+func (c *limitedServerCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	// net/rpc discards the body of the calls it can't serve.
+	if body == nil || !c.limited {
+		return nil
+	}
+	if err := c.limiter.tryAcquire(); err != nil {
+		return err
+	}
+	c.l.Lock()
+	c.acquired[c.seq] = true
+	c.l.Unlock()
+	return nil
+}
+
+func (c *limitedServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	err := c.ServerCodec.WriteResponse(r, body)
+	c.l.Lock()
+	if c.acquired[r.Seq] {
+		delete(c.acquired, r.Seq)
+		c.limiter.release()
+	}
+	c.l.Unlock()
+	return err
+}
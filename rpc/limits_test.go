@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallLimiter(t *testing.T) {
+	l := newCallLimiter(ServerLimits{MaxConcurrentCalls: 1, MaxQueuedCalls: 1})
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	queued := make(chan error)
+	go func() { queued <- l.acquire(context.Background()) }()
+	for atomic.LoadInt32(&l.queued) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := l.acquire(context.Background()); !errors.Is(err, ErrServerBusy) {
+		t.Fatalf("expected ErrServerBusy, got %v", err)
+	}
+
+	l.release()
+	if err := <-queued; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the error of the context, got %v", err)
+	}
+
+	if newCallLimiter(ServerLimits{}) != nil {
+		t.Fatal("the limiter without MaxConcurrentCalls should not limit")
+	}
+}
+
+func TestPluginServer_limits(t *testing.T) {
+	clientConn, serverConn := testConn(t)
+	server, err := NewServer(serverConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer server.Close()
+	server.SetLimits(ServerLimits{MaxConcurrentCalls: 1, MaxQueuedCalls: 1})
+	server.SetFeatures(SupportedFeatures)
+	go server.Serve()
+	client, err := NewClient(clientConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer client.Close()
+	client.SetFeatures(SupportedFeatures)
+
+	ui := &hangingUi{interactive: true, release: make(chan struct{})}
+	server.RegisterUi(ui)
+	uiClient := client.Ui()
+	answered := make(chan error)
+	go func() {
+		_, err := uiClient.Ask("Overwrite?")
+		answered <- err
+	}()
+	limiter := server.mux.callLimiter()
+	for len(limiter.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The only slot is taken by the question, and the net/rpc calls don't
+	// queue.
+	err = client.client.Call("Ui.Say", "message", new(interface{}))
+	if err == nil || !strings.Contains(err.Error(), "too many calls") {
+		t.Fatalf("expected the server to be busy, got %v", err)
+	}
+	if _, err := client.Health(context.Background()); err != nil {
+		t.Fatalf("the Lifecycle calls should not be limited: %s", err)
+	}
+
+	close(ui.release)
+	if err := <-answered; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := client.client.Call("Ui.Say", "message", new(interface{})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The logs of the plugins are written to their stderr, which Packer reads
+// and logs along with its own. When Packer and the plugin support it, they
+// flow over a dedicated channel of the connection instead, with the level
+// parsed from the "[DEBUG]" like prefix of the lines, and the component of
+// the plugin writing them, so that Packer can filter and attribute them.
+
+// logStreamId is the ID of the mux stream of the logs, out of the range of
+// the IDs returned by muxBroker.NextId.
+const logStreamId = math.MaxUint32
+
+// LogEntry is a line logged by a plugin.
+type LogEntry struct {
+	Time time.Time
+	// Level is parsed from the prefix of the line, UiLevelInfo if it has
+	// none.
+	Level packer.UiLevel
+	// Component is the component of the plugin which logged the line, like
+	// "builder example".
+	Component string
+	// Message is the line, without its level prefix.
+	Message string
+}
+
+// LogHandler handles the entries logged by a plugin.
+type LogHandler func(LogEntry)
+
+// DefaultLogHandler logs the entries with the standard logger, the way they
+// are when the plugin logs to its stderr.
+func DefaultLogHandler(e LogEntry) {
+	log.Printf("[%s] %s: %s", strings.ToUpper(e.Level.String()), e.Component, e.Message)
+}
+
+// FilterLogs returns a LogHandler calling h with the entries of at least
+// level.
+func FilterLogs(level packer.UiLevel, h LogHandler) LogHandler {
+	return func(e LogEntry) {
+		if e.Level >= level {
+			h(e)
+		}
+	}
+}
+
+var logLevelPrefixes = map[string]packer.UiLevel{
+	"[TRACE]": packer.UiLevelTrace,
+	"[DEBUG]": packer.UiLevelDebug,
+	"[INFO]":  packer.UiLevelInfo,
+	"[WARN]":  packer.UiLevelWarn,
+	"[ERR]":   packer.UiLevelError,
+	"[ERROR]": packer.UiLevelError,
+}
+
+// parseLogLine returns the entry of a line logged by component.
+func parseLogLine(component, line string) LogEntry {
+	e := LogEntry{Time: time.Now(), Level: packer.UiLevelInfo, Component: component, Message: line}
+	if i := strings.IndexByte(line, ']'); strings.HasPrefix(line, "[") && i > 0 {
+		if level, ok := logLevelPrefixes[strings.ToUpper(line[:i+1])]; ok {
+			e.Level = level
+			e.Message = strings.TrimLeft(line[i+1:], " ")
+		}
+	}
+	return e
+}
+
+// logQueueSize is the number of log lines queued to be sent. The lines
+// logged once the queue is full are dropped, so that logging never waits for
+// the connection.
+const logQueueSize = 1024
+
+// logFlushTimeout bounds the time flush waits for the queued lines to be
+// sent.
+const logFlushTimeout = 5 * time.Second
+
+// queuedLog is a line queued to be sent, or a marker closing done once the
+// lines queued before it are sent.
+type queuedLog struct {
+	entry LogEntry
+	line  string
+	done  chan struct{}
+}
+
+// logWriter is the output of the standard logger of a plugin streaming its
+// logs. It writes the lines to fallback until it is connected, or once
+// sending them fails. The lines are sent by another goroutine, through a
+// queue of logQueueSize lines.
+type logWriter struct {
+	component string
+	fallback  io.Writer
+	// flags and prefix are the ones of the standard logger, to strip the
+	// header it writes before the lines.
+	flags  int
+	prefix string
+
+	l       sync.Mutex
+	buf     []byte
+	queue   chan queuedLog
+	dropped int
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.l.Lock()
+	defer w.l.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		w.writeLine(line)
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) writeLine(line string) {
+	if w.queue == nil {
+		io.WriteString(w.fallback, line+"\n")
+		return
+	}
+	// The queue is only written to with w.l held: there is room for the
+	// warning and the line when it has two free slots.
+	if w.dropped > 0 && len(w.queue) < cap(w.queue)-1 {
+		msg := fmt.Sprintf("[WARN] %d log lines were dropped, logged faster than they could be sent", w.dropped)
+		w.queue <- queuedLog{entry: parseLogLine(w.component, msg), line: msg}
+		w.dropped = 0
+	}
+	select {
+	case w.queue <- queuedLog{entry: parseLogLine(w.component, stripLogHeader(line, w.flags, w.prefix)), line: line}:
+	default:
+		w.dropped++
+	}
+}
+
+// stripLogHeader returns line, written by a logger with flags and prefix,
+// without the header the logger wrote before it.
+func stripLogHeader(line string, flags int, prefix string) string {
+	if flags&log.Lmsgprefix == 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	n := 0
+	if flags&log.Ldate != 0 {
+		n += len("2006/01/02 ")
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n += len("15:04:05 ")
+		if flags&log.Lmicroseconds != 0 {
+			n += len(".000000")
+		}
+	}
+	if n > len(line) {
+		return line
+	}
+	line = line[n:]
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if i := strings.Index(line, ": "); i >= 0 {
+			line = line[i+2:]
+		}
+	}
+	if flags&log.Lmsgprefix != 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	return line
+}
+
+// flush writes the last line logged when it doesn't end with a newline, and
+// waits for the queued lines to be sent, for at most logFlushTimeout.
+func (w *logWriter) flush() {
+	if w == nil {
+		return
+	}
+	w.l.Lock()
+	if len(w.buf) > 0 {
+		w.writeLine(string(w.buf))
+		w.buf = nil
+	}
+	queue := w.queue
+	w.l.Unlock()
+	if queue == nil {
+		return
+	}
+
+	timeout := time.NewTimer(logFlushTimeout)
+	defer timeout.Stop()
+	done := make(chan struct{})
+	select {
+	case queue <- queuedLog{done: done}:
+	case <-timeout.C:
+		return
+	}
+	select {
+	case <-done:
+	case <-timeout.C:
+	}
+}
+
+// connect starts sending the lines logged with send.
+func (w *logWriter) connect(send func(LogEntry) error) {
+	queue := make(chan queuedLog, logQueueSize)
+	w.l.Lock()
+	w.queue = queue
+	w.l.Unlock()
+	go w.send(queue, send)
+}
+
+// send sends the lines of queue, until sending one fails: the lines logged
+// are then written to fallback, the queued ones included.
+func (w *logWriter) send(queue chan queuedLog, send func(LogEntry) error) {
+	failed := false
+	for q := range queue {
+		if q.done != nil {
+			close(q.done)
+			continue
+		}
+		if !failed {
+			if err := send(q.entry); err == nil {
+				continue
+			}
+			failed = true
+		}
+		w.l.Lock()
+		w.queue = nil
+		io.WriteString(w.fallback, q.line+"\n")
+		w.l.Unlock()
+	}
+}
+
+// streamLogs makes the standard logger write to a logWriter for component,
+// connected with the send function returned by connect, and returns it.
+func streamLogs(component string, connect func() (func(LogEntry) error, error)) *logWriter {
+	w := &logWriter{
+		component: component,
+		fallback:  log.Writer(),
+		flags:     log.Flags(),
+		prefix:    log.Prefix(),
+	}
+	log.SetOutput(w)
+	go func() {
+		send, err := connect()
+		if err != nil {
+			log.Printf("[WARN] Logging to stderr, streaming the logs failed: %s", err)
+			return
+		}
+		w.connect(send)
+	}()
+	return w
+}
+
+// StreamLogs sends the logs of the standard logger to the client, tagged
+// with component, when the client supports FeatureLogStream. The client must
+// call ServeLogs.
+func (s *PluginServer) StreamLogs(component string) {
+	if !s.mux.has(FeatureLogStream) {
+		return
+	}
+	s.logs = streamLogs(component, func() (func(LogEntry) error, error) {
+		conn, err := s.mux.Dial(logStreamId)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(conn)
+		return func(e LogEntry) error { return enc.Encode(e) }, nil
+	})
+}
+
+// ServeLogs calls h with the logs the server streams, when it supports
+// FeatureLogStream, until the connection is closed.
+func (c *Client) ServeLogs(h LogHandler) {
+	if !c.mux.has(FeatureLogStream) {
+		return
+	}
+	go func() {
+		conn, err := c.mux.Accept(logStreamId)
+		if err != nil {
+			log.Printf("[ERR] Error accepting the log stream: %s", err)
+			return
+		}
+		defer conn.Close()
+		dec := json.NewDecoder(conn)
+		for {
+			var e LogEntry
+			if err := dec.Decode(&e); err != nil {
+				return
+			}
+			h(e)
+		}
+	}()
+}
@@ -0,0 +1,169 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestParseLogLine(t *testing.T) {
+	cases := []struct {
+		line    string
+		level   packer.UiLevel
+		message string
+	}{
+		{"[DEBUG] foo", packer.UiLevelDebug, "foo"},
+		{"[TRACE]foo", packer.UiLevelTrace, "foo"},
+		{"[warn] foo", packer.UiLevelWarn, "foo"},
+		{"[ERR] foo", packer.UiLevelError, "foo"},
+		{"foo", packer.UiLevelInfo, "foo"},
+		{"[foo] bar", packer.UiLevelInfo, "[foo] bar"},
+	}
+	for _, tc := range cases {
+		e := parseLogLine("builder example", tc.line)
+		if e.Level != tc.level || e.Message != tc.message || e.Component != "builder example" {
+			t.Errorf("%q: bad entry: %#v", tc.line, e)
+		}
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var fallback bytes.Buffer
+	w := &logWriter{component: "c", fallback: &fallback}
+
+	w.Write([]byte("[INFO] before\n[DEBUG] par"))
+	var entries []LogEntry
+	w.connect(func(e LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	w.Write([]byte("tial\n"))
+	w.flush()
+
+	if fallback.String() != "[INFO] before\n" {
+		t.Fatalf("bad fallback: %q", fallback.String())
+	}
+	if len(entries) != 1 || entries[0].Message != "partial" || entries[0].Level != packer.UiLevelDebug {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+}
+
+func TestLogWriter_full(t *testing.T) {
+	w := &logWriter{component: "c", fallback: new(bytes.Buffer)}
+	release := make(chan struct{})
+	var entries []LogEntry
+	w.connect(func(e LogEntry) error {
+		<-release
+		entries = append(entries, e)
+		return nil
+	})
+
+	// The logs don't wait for the blocked sends: the lines past the queue
+	// are dropped.
+	for i := 0; i < 2*logQueueSize; i++ {
+		w.Write([]byte("[DEBUG] line\n"))
+	}
+	close(release)
+	w.flush()
+	w.Write([]byte("[INFO] last\n"))
+	w.flush()
+
+	if len(entries) > logQueueSize+2 {
+		t.Fatalf("%d entries were queued", len(entries))
+	}
+	last := entries[len(entries)-2:]
+	if last[0].Level != packer.UiLevelWarn || !strings.Contains(last[0].Message, "log lines were dropped") || last[1].Message != "last" {
+		t.Fatalf("bad last entries: %#v", last)
+	}
+}
+
+func TestLogWriter_sendFailure(t *testing.T) {
+	var fallback bytes.Buffer
+	w := &logWriter{component: "c", fallback: &fallback}
+	w.connect(func(e LogEntry) error { return errors.New("closed") })
+	w.Write([]byte("[INFO] foo\n"))
+	w.flush()
+	w.Write([]byte("[INFO] bar\n"))
+
+	if fallback.String() != "[INFO] foo\n[INFO] bar\n" {
+		t.Fatalf("bad fallback: %q", fallback.String())
+	}
+}
+
+func TestStripLogHeader(t *testing.T) {
+	cases := []struct {
+		line   string
+		flags  int
+		prefix string
+	}{
+		{"[INFO] foo", 0, ""},
+		{"2021/01/02 03:04:05 [INFO] foo", log.LstdFlags, ""},
+		{"03:04:05.123456 main.go:12: [INFO] foo", log.Ltime | log.Lmicroseconds | log.Lshortfile, ""},
+		{"plugin: 2021/01/02 [INFO] foo", log.Ldate, "plugin: "},
+		{"2021/01/02 03:04:05 plugin: [INFO] foo", log.LstdFlags | log.Lmsgprefix, "plugin: "},
+	}
+	for _, tc := range cases {
+		if actual := stripLogHeader(tc.line, tc.flags, tc.prefix); actual != "[INFO] foo" {
+			t.Errorf("%q: got %q", tc.line, actual)
+		}
+	}
+}
+
+// testStreamLogs logs with the standard logger until entries receives one.
+func testStreamLogs(t *testing.T, entries chan LogEntry) LogEntry {
+	timeout := time.After(5 * time.Second)
+	for {
+		log.Printf("[WARN] foo")
+		select {
+		case e := <-entries:
+			return e
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("no log received")
+		}
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	entries := make(chan LogEntry, 100)
+	client.ServeLogs(func(e LogEntry) { entries <- e })
+	defer log.SetOutput(log.Writer())
+	flags := log.Flags()
+	server.StreamLogs("builder example")
+	if log.Flags() != flags {
+		t.Fatal("the flags of the standard logger should not change")
+	}
+
+	e := testStreamLogs(t, entries)
+	if e.Level != packer.UiLevelWarn || e.Component != "builder example" || e.Message != "foo" {
+		t.Fatalf("bad entry: %#v", e)
+	}
+}
+
+func TestGRPCStreamLogs(t *testing.T) {
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	entries := make(chan LogEntry, 100)
+	client.ServeLogs(func(e LogEntry) { entries <- e })
+	defer log.SetOutput(log.Writer())
+	server.StreamLogs("provisioner")
+
+	e := testStreamLogs(t, entries)
+	if e.Level != packer.UiLevelWarn || e.Component != "provisioner" || e.Message != "foo" {
+		t.Fatalf("bad entry: %#v", e)
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// CallStats describes an RPC call made or served, with net/rpc or gRPC.
+type CallStats struct {
+	// Method is the method called, like "Ui.Say" with net/rpc or
+	// "/packer.plugin.v1.Ui/Say" with gRPC.
+	Method string
+	// Server is whether the call was served, rather than made.
+	Server bool
+	// Duration is the time from the request to the response.
+	Duration time.Duration
+	// Sent and Received are the sizes in bytes of the payloads written and
+	// read: the request and the response for a client, the other way
+	// around for a server.
+	Sent, Received int64
+	// Err is the error of the call, if any.
+	Err error
+}
+
+// CallObserver is called with the stats of each call, from the goroutines
+// of the connection: it must be fast and safe for concurrent use.
+type CallObserver func(CallStats)
+
+var (
+	observerMu sync.RWMutex
+	observer   CallObserver
+)
+
+// SetCallObserver makes the clients and the servers of the package report
+// their calls to o, or stops it when o is nil. Counting the calls by method
+// shows the chatty ones, like the Ui calls made for each line of output,
+// slowing the builds down. The clients and the servers check the observer
+// when they are created, so it should be set first thing.
+func SetCallObserver(o CallObserver) {
+	observerMu.Lock()
+	defer observerMu.Unlock()
+	observer = o
+}
+
+func callObserver() CallObserver {
+	observerMu.RLock()
+	defer observerMu.RUnlock()
+	return observer
+}
+
+// MethodMetrics are the metrics of the calls of a method.
+type MethodMetrics struct {
+	Calls  int
+	Errors int
+	// Duration is the total time of the calls, and MaxDuration the time of
+	// the longest one.
+	Duration    time.Duration
+	MaxDuration time.Duration
+	// Sent and Received are the total sizes of the payloads, in bytes.
+	Sent, Received int64
+}
+
+// CallMetrics aggregates the stats of the calls by method. Its Observe
+// method is a CallObserver:
+//
+//	metrics := new(rpc.CallMetrics)
+//	rpc.SetCallObserver(metrics.Observe)
+type CallMetrics struct {
+	l       sync.Mutex
+	methods map[string]MethodMetrics
+}
+
+func (m *CallMetrics) Observe(s CallStats) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if m.methods == nil {
+		m.methods = make(map[string]MethodMetrics)
+	}
+	mm := m.methods[s.Method]
+	mm.Calls++
+	if s.Err != nil {
+		mm.Errors++
+	}
+	mm.Duration += s.Duration
+	if s.Duration > mm.MaxDuration {
+		mm.MaxDuration = s.Duration
+	}
+	mm.Sent += s.Sent
+	mm.Received += s.Received
+	m.methods[s.Method] = mm
+}
+
+// Methods returns the metrics of the methods called so far.
+func (m *CallMetrics) Methods() map[string]MethodMetrics {
+	m.l.Lock()
+	defer m.l.Unlock()
+	methods := make(map[string]MethodMetrics, len(m.methods))
+	for name, mm := range m.methods {
+		methods[name] = mm
+	}
+	return methods
+}
+
+// countingConn counts the bytes read from and written to a connection.
+// It buffers them itself, under the counts, so that the codec reading it
+// doesn't read ahead: the count of bytes read while decoding a message is
+// its size.
+type countingConn struct {
+	io.Closer
+	r *bufio.Reader
+	w *bufio.Writer
+
+	read, written int64
+}
+
+func newCountingConn(conn io.ReadWriteCloser) *countingConn {
+	return &countingConn{
+		Closer: conn,
+		r:      bufio.NewReader(conn),
+		w:      bufio.NewWriter(conn),
+	}
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Flush() error { return c.w.Flush() }
+
+// Buffered tells the codec the reads are buffered already.
+func (c *countingConn) Buffered() int { return c.r.Buffered() }
+
+func (c *countingConn) bytesRead() int64    { return atomic.LoadInt64(&c.read) }
+func (c *countingConn) bytesWritten() int64 { return atomic.LoadInt64(&c.written) }
+
+// observedCall is a net/rpc call waiting for its response.
+type observedCall struct {
+	method         string
+	start          time.Time
+	sent, received int64
+}
+
+// observedClientCodec is a rpc.ClientCodec reporting its calls to an
+// observer. net/rpc writes the requests one at a time, and reads the
+// responses from a single goroutine.
+type observedClientCodec struct {
+	rpc.ClientCodec
+	conn    *countingConn
+	observe CallObserver
+
+	l     sync.Mutex
+	calls map[uint64]observedCall
+
+	// The response being read.
+	seq       uint64
+	readStart int64
+	callErr   error
+}
+
+func newObservedClientCodec(c rpc.ClientCodec, conn *countingConn, observe CallObserver) *observedClientCodec {
+	return &observedClientCodec{
+		ClientCodec: c,
+		conn:        conn,
+		observe:     observe,
+		calls:       make(map[uint64]observedCall),
+	}
+}
+
+func (c *observedClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	call := observedCall{method: r.ServiceMethod, start: time.Now()}
+	written := c.conn.bytesWritten()
+	err := c.ClientCodec.WriteRequest(r, body)
+	call.sent = c.conn.bytesWritten() - written
+	if err != nil {
+		c.observe(CallStats{Method: call.method, Duration: time.Since(call.start), Sent: call.sent, Err: err})
+		return err
+	}
+	c.l.Lock()
+	c.calls[r.Seq] = call
+	c.l.Unlock()
+	return nil
+}
+
+func (c *observedClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	c.readStart = c.conn.bytesRead()
+	err := c.ClientCodec.ReadResponseHeader(r)
+	if err == nil {
+		c.seq, c.callErr = r.Seq, nil
+		if r.Error != "" {
+			c.callErr = errors.New(r.Error)
+		}
+	}
+	return err
+}
+
+func (c *observedClientCodec) ReadResponseBody(body interface{}) error {
+	err := c.ClientCodec.ReadResponseBody(body)
+	c.l.Lock()
+	call, ok := c.calls[c.seq]
+	delete(c.calls, c.seq)
+	c.l.Unlock()
+	if ok {
+		callErr := c.callErr
+		if callErr == nil {
+			callErr = err
+		}
+		c.observe(CallStats{
+			Method:   call.method,
+			Duration: time.Since(call.start),
+			Sent:     call.sent,
+			Received: c.conn.bytesRead() - c.readStart,
+			Err:      callErr,
+		})
+	}
+	return err
+}
+
+// observedServerCodec is a rpc.ServerCodec reporting the calls it serves to
+// an observer. net/rpc reads the requests from a single goroutine, and
+// writes the responses one at a time.
+type observedServerCodec struct {
+	rpc.ServerCodec
+	conn    *countingConn
+	observe CallObserver
+
+	l     sync.Mutex
+	calls map[uint64]observedCall
+
+	// The request being read.
+	seq       uint64
+	call      observedCall
+	readStart int64
+}
+
+func newObservedServerCodec(c rpc.ServerCodec, conn *countingConn, observe CallObserver) *observedServerCodec {
+	return &observedServerCodec{
+		ServerCodec: c,
+		conn:        conn,
+		observe:     observe,
+		calls:       make(map[uint64]observedCall),
+	}
+}
+
+func (c *observedServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.readStart = c.conn.bytesRead()
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err == nil {
+		c.seq = r.Seq
+		c.call = observedCall{method: r.ServiceMethod, start: time.Now()}
+	}
+	return err
+}
+
+func (c *observedServerCodec) ReadRequestBody(body interface{}) error {
+	err := c.ServerCodec.ReadRequestBody(body)
+	c.call.received = c.conn.bytesRead() - c.readStart
+	c.l.Lock()
+	c.calls[c.seq] = c.call
+	c.l.Unlock()
+	return err
+}
+
+func (c *observedServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	written := c.conn.bytesWritten()
+	err := c.ServerCodec.WriteResponse(r, body)
+	c.l.Lock()
+	call, ok := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.l.Unlock()
+	if ok {
+		callErr := err
+		if r.Error != "" {
+			callErr = errors.New(r.Error)
+		}
+		c.observe(CallStats{
+			Method:   call.method,
+			Server:   true,
+			Duration: time.Since(call.start),
+			Sent:     c.conn.bytesWritten() - written,
+			Received: call.received,
+			Err:      callErr,
+		})
+	}
+	return err
+}
+
+// grpcStatsHandler is a gRPC stats.Handler reporting the calls to an
+// observer.
+type grpcStatsHandler struct {
+	observe CallObserver
+}
+
+type grpcCallStatsKey struct{}
+
+// grpcCallStats are the stats of a gRPC call. The payloads of streams can
+// be sent and received concurrently.
+type grpcCallStats struct {
+	method         string
+	sent, received int64
+}
+
+func (h grpcStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, grpcCallStatsKey{}, &grpcCallStats{method: info.FullMethodName})
+}
+
+func (h grpcStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	call, ok := ctx.Value(grpcCallStatsKey{}).(*grpcCallStats)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InPayload:
+		atomic.AddInt64(&call.received, int64(s.Length))
+	case *stats.OutPayload:
+		atomic.AddInt64(&call.sent, int64(s.Length))
+	case *stats.End:
+		h.observe(CallStats{
+			Method:   call.method,
+			Server:   !s.IsClient(),
+			Duration: s.EndTime.Sub(s.BeginTime),
+			Sent:     atomic.LoadInt64(&call.sent),
+			Received: atomic.LoadInt64(&call.received),
+			Err:      s.Error,
+		})
+	}
+}
+
+func (grpcStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type testObserver struct {
+	l     sync.Mutex
+	calls []CallStats
+}
+
+func (o *testObserver) observe(s CallStats) {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.calls = append(o.calls, s)
+}
+
+// call waits for the stats of the call of method, made or served.
+func (o *testObserver) call(t *testing.T, method string, server bool) CallStats {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		o.l.Lock()
+		for _, s := range o.calls {
+			if s.Method == method && s.Server == server {
+				o.l.Unlock()
+				return s
+			}
+		}
+		o.l.Unlock()
+	}
+	t.Fatalf("no stats for %s (server: %t)", method, server)
+	return CallStats{}
+}
+
+func testCallObserver(t *testing.T, o *testObserver, method string, say func(string)) {
+	message := strings.Repeat("x", 1000)
+	say(message)
+	client := o.call(t, method, false)
+	server := o.call(t, method, true)
+	if client.Sent < int64(len(message)) || client.Sent != server.Received {
+		t.Fatalf("bad request sizes: sent %d, received %d", client.Sent, server.Received)
+	}
+	if client.Received != server.Sent {
+		t.Fatalf("bad response sizes: sent %d, received %d", server.Sent, client.Received)
+	}
+	if client.Err != nil || server.Err != nil || client.Duration <= 0 {
+		t.Fatalf("bad stats: %#v, %#v", client, server)
+	}
+}
+
+func TestCallObserver(t *testing.T) {
+	o := new(testObserver)
+	SetCallObserver(o.observe)
+	defer SetCallObserver(nil)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(new(testUi))
+	testCallObserver(t, o, "Ui.Say", client.Ui().Say)
+
+	grpcClient, grpcServer := testGRPCClientServer(t)
+	defer grpcClient.Close()
+	defer grpcServer.Close()
+	ui := &grpcUi{peer: grpcServer.peer, id: grpcClient.peer.export(new(testUi))}
+	testCallObserver(t, o, "/packer.plugin.v1.Ui/Say", ui.Say)
+}
+
+func TestCallMetrics(t *testing.T) {
+	m := new(CallMetrics)
+	m.Observe(CallStats{Method: "Ui.Say", Duration: time.Second, Sent: 10, Received: 1})
+	m.Observe(CallStats{Method: "Ui.Say", Duration: 2 * time.Second, Sent: 20, Received: 1})
+	m.Observe(CallStats{Method: "Ui.Ask", Err: packer.ErrNonInteractive})
+
+	methods := m.Methods()
+	expected := MethodMetrics{Calls: 2, Duration: 3 * time.Second, MaxDuration: 2 * time.Second, Sent: 30, Received: 2}
+	if methods["Ui.Say"] != expected {
+		t.Fatalf("bad Ui.Say metrics: %#v", methods["Ui.Say"])
+	}
+	if methods["Ui.Ask"].Errors != 1 {
+		t.Fatalf("bad Ui.Ask metrics: %#v", methods["Ui.Ask"])
+	}
+}
@@ -25,6 +25,21 @@ type muxBroker struct {
 	session *yamux.Session
 	streams map[uint32]*muxBrokerPending
 
+	// features are the features negotiated over the connection.
+	features featureSet
+
+	// calls are the calls served, see cancel.go.
+	calls callSet
+	// cancelConn is the stream the cancellations are sent on.
+	cancelConn net.Conn
+	cancelLock sync.Mutex
+
+	// limiter limits the calls served, see limits.go.
+	limiter *callLimiter
+
+	// specs caches the specs of the components called, see spec_cache.go.
+	specs specCache
+
 	sync.Mutex
 }
 
@@ -139,6 +154,11 @@ func (m *muxBroker) Run() {
 			continue
 		}
 
+		if id == cancelStreamId {
+			go m.serveCancels(stream)
+			continue
+		}
+
 		// Initialize the waiter
 		p := m.getStream(id)
 		select {
@@ -7,6 +7,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -20,7 +21,7 @@ type postProcessor struct {
 // exportable as part of a Golang RPC server.
 type PostProcessorServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel func(*multistep.CancelCause)
 
 	commonServer
 	p packersdk.PostProcessor
@@ -43,7 +44,7 @@ func (p *postProcessor) Configure(raw ...interface{}) error {
 		return err
 	}
 	args := &PostProcessorConfigureArgs{Configs: raw}
-	return p.client.Call(p.endpoint+".Configure", args, new(interface{}))
+	return p.call("Configure", args, new(interface{}))
 }
 
 func (p *postProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
@@ -53,22 +54,10 @@ func (p *postProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a pack
 	server.RegisterUi(ui)
 	go server.Serve()
 
-	done := make(chan interface{})
-	defer close(done)
-
-	go func() {
-		select {
-		case <-ctx.Done():
-			log.Printf("Cancelling post-processor after context cancellation %v", ctx.Err())
-			if err := p.client.Call(p.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
-				log.Printf("Error cancelling post-processor: %s", err)
-			}
-		case <-done:
-		}
-	}()
+	defer p.cancelOnDone(ctx, nextId, "post-processor")()
 
 	var response PostProcessorProcessResponse
-	if err := p.client.Call(p.endpoint+".PostProcess", nextId, &response); err != nil {
+	if err := p.call("PostProcess", nextId, &response); err != nil {
 		return nil, false, false, err
 	}
 
@@ -89,26 +78,33 @@ func (p *postProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a pack
 }
 
 func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply *interface{}) (err error) {
+	defer recoverCrash(p.mux, DefaultPostProcessorEndpoint, "Configure", args.Configs, &err)
 	config, err := decodeCTYValues(args.Configs)
 	if err != nil {
 		return err
 	}
+	p.setConfigs(config)
 	err = p.p.Configure(config...)
 	return err
 }
 
-func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorProcessResponse) error {
+func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorProcessResponse) (err error) {
+	defer recoverCrash(p.mux, DefaultPostProcessorEndpoint, "PostProcess", p.crashConfigs(), &err)
 	client, err := newClientWithMux(p.mux, streamId)
 	if err != nil {
 		return NewBasicError(err)
 	}
 
 	if p.context == nil {
-		p.context, p.contextCancel = context.WithCancel(context.Background())
+		p.context, p.contextCancel = multistep.WithCancelCause(context.Background())
 	}
 
 	artifact := client.Artifact()
-	artifactResult, keep, forceOverride, err := p.p.PostProcess(p.context, client.Ui(), artifact)
+	ctx, done := p.mux.callContext(p.context, streamId)
+	defer done()
+
+	ui := client.Ui()
+	artifactResult, keep, forceOverride, err := p.p.PostProcess(ctx, ui, artifact)
 	*reply = PostProcessorProcessResponse{
 		Err:           NewBasicError(err),
 		Keep:          keep,
@@ -130,6 +126,7 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 	}
 
 	if artifactResult != nil {
+		packersdk.EmitEvent(ui, packersdk.ArtifactEvent(artifactResult))
 		streamId = p.mux.NextId()
 		reply.StreamId = streamId
 		server := newServerWithMux(p.mux, streamId)
@@ -141,9 +138,9 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 	return nil
 }
 
-func (b *PostProcessorServer) Cancel(args *interface{}, reply *interface{}) error {
+func (b *PostProcessorServer) Cancel(args *CancelArgs, reply *interface{}) error {
 	if b.contextCancel != nil {
-		b.contextCancel()
+		b.contextCancel(args.cause())
 	}
 	return nil
 }
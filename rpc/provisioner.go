@@ -5,8 +5,8 @@ package rpc
 
 import (
 	"context"
-	"log"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -20,7 +20,7 @@ type provisioner struct {
 // exportable as part of a Golang RPC server.
 type ProvisionerServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel func(*multistep.CancelCause)
 
 	commonServer
 	p packersdk.Provisioner
@@ -36,7 +36,7 @@ func (p *provisioner) Prepare(configs ...interface{}) error {
 		return err
 	}
 	args := &ProvisionerPrepareArgs{configs}
-	return p.client.Call(p.endpoint+".Prepare", args, new(interface{}))
+	return p.call("Prepare", args, new(interface{}))
 }
 
 type ProvisionerProvisionArgs struct {
@@ -51,33 +51,24 @@ func (p *provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packe
 	server.RegisterUi(ui)
 	go server.Serve()
 
-	done := make(chan interface{})
-	defer close(done)
-
-	go func() {
-		select {
-		case <-ctx.Done():
-			log.Printf("Cancelling provisioner after context cancellation %v", ctx.Err())
-			if err := p.client.Call(p.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
-				log.Printf("Error cancelling provisioner: %s", err)
-			}
-		case <-done:
-		}
-	}()
+	defer p.cancelOnDone(ctx, nextId, "provisioner")()
 
 	args := &ProvisionerProvisionArgs{generatedData, nextId}
-	return p.client.Call(p.endpoint+".Provision", args, new(interface{}))
+	return p.call("Provision", args, new(interface{}))
 }
 
-func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *interface{}) error {
+func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *interface{}) (err error) {
+	defer recoverCrash(p.mux, DefaultProvisionerEndpoint, "Prepare", args.Configs, &err)
 	config, err := decodeCTYValues(args.Configs)
 	if err != nil {
 		return err
 	}
+	p.setConfigs(config)
 	return p.p.Prepare(config...)
 }
 
-func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *interface{}) error {
+func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *interface{}) (err error) {
+	defer recoverCrash(p.mux, DefaultProvisionerEndpoint, "Provision", p.crashConfigs(), &err)
 	streamId := args.StreamID
 	client, err := newClientWithMux(p.mux, streamId)
 	if err != nil {
@@ -86,16 +77,21 @@ func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *int
 	defer client.Close()
 
 	if p.context == nil {
-		p.context, p.contextCancel = context.WithCancel(context.Background())
+		p.context, p.contextCancel = multistep.WithCancelCause(context.Background())
 	}
-	if err := p.p.Provision(p.context, client.Ui(), client.Communicator(), args.GeneratedData); err != nil {
+	ctx, done := p.mux.callContext(p.context, streamId)
+	defer done()
+
+	if err := p.p.Provision(ctx, client.Ui(), client.Communicator(), args.GeneratedData); err != nil {
 		return NewBasicError(err)
 	}
 
 	return nil
 }
 
-func (p *ProvisionerServer) Cancel(args *interface{}, reply *interface{}) error {
-	p.contextCancel()
+func (p *ProvisionerServer) Cancel(args *CancelArgs, reply *interface{}) error {
+	if p.contextCancel != nil {
+		p.contextCancel(args.cause())
+	}
 	return nil
 }
@@ -23,6 +23,8 @@ const (
 	DefaultPostProcessorEndpoint string = "PostProcessor"
 	DefaultProvisionerEndpoint   string = "Provisioner"
 	DefaultDatasourceEndpoint    string = "Datasource"
+	DefaultFunctionEndpoint      string = "Function"
+	DefaultLifecycleEndpoint     string = "Lifecycle"
 	DefaultUiEndpoint            string = "Ui"
 )
 
@@ -35,6 +37,7 @@ type PluginServer struct {
 	streamId uint32
 	server   *rpc.Server
 	closeMux bool
+	logs     *logWriter
 }
 
 // NewServer returns a new Packer RPC server.
@@ -45,6 +48,8 @@ func NewServer(conn io.ReadWriteCloser) (*PluginServer, error) {
 	}
 	result := newServerWithMux(mux, 0)
 	result.closeMux = true
+	result.SetLimits(DefaultServerLimits)
+	result.server.RegisterName(DefaultLifecycleEndpoint, &LifecycleServer{server: result})
 	go mux.Run()
 	return result, nil
 }
@@ -69,6 +74,7 @@ func (s *PluginServer) Close() error {
 
 func (s *PluginServer) RegisterArtifact(a packer.Artifact) error {
 	return s.server.RegisterName(DefaultArtifactEndpoint, &ArtifactServer{
+		mux:      s.mux,
 		artifact: a,
 	})
 }
@@ -83,6 +89,7 @@ func (s *PluginServer) RegisterBuild(b packer.Build) error {
 func (s *PluginServer) RegisterBuilder(b packer.Builder) error {
 	return s.server.RegisterName(DefaultBuilderEndpoint, &BuilderServer{
 		commonServer: commonServer{
+			endpoint:         DefaultBuilderEndpoint,
 			selfConfigurable: b,
 			mux:              s.mux,
 		},
@@ -109,6 +116,7 @@ func (s *PluginServer) RegisterHook(h packer.Hook) error {
 func (s *PluginServer) RegisterPostProcessor(p packer.PostProcessor) error {
 	return s.server.RegisterName(DefaultPostProcessorEndpoint, &PostProcessorServer{
 		commonServer: commonServer{
+			endpoint:         DefaultPostProcessorEndpoint,
 			selfConfigurable: p,
 			mux:              s.mux,
 		},
@@ -119,6 +127,7 @@ func (s *PluginServer) RegisterPostProcessor(p packer.PostProcessor) error {
 func (s *PluginServer) RegisterProvisioner(p packer.Provisioner) error {
 	return s.server.RegisterName(DefaultProvisionerEndpoint, &ProvisionerServer{
 		commonServer: commonServer{
+			endpoint:         DefaultProvisionerEndpoint,
 			selfConfigurable: p,
 			mux:              s.mux,
 		},
@@ -129,6 +138,7 @@ func (s *PluginServer) RegisterProvisioner(p packer.Provisioner) error {
 func (s *PluginServer) RegisterDatasource(d packer.Datasource) error {
 	return s.server.RegisterName(DefaultDatasourceEndpoint, &DatasourceServer{
 		commonServer: commonServer{
+			endpoint:         DefaultDatasourceEndpoint,
 			selfConfigurable: d,
 			mux:              s.mux,
 		},
@@ -136,6 +146,13 @@ func (s *PluginServer) RegisterDatasource(d packer.Datasource) error {
 	})
 }
 
+func (s *PluginServer) RegisterFunction(f packer.Function) error {
+	return s.server.RegisterName(DefaultFunctionEndpoint, &FunctionServer{
+		f:   f,
+		mux: s.mux,
+	})
+}
+
 func (s *PluginServer) RegisterUi(ui packer.Ui) error {
 	return s.server.RegisterName(DefaultUiEndpoint, &UiServer{
 		ui:       ui,
@@ -158,6 +175,15 @@ func (s *PluginServer) Serve() {
 	h := &codec.MsgpackHandle{
 		WriteExt: true,
 	}
-	rpcCodec := codec.GoRpc.ServerCodec(stream, h)
+	var rpcCodec rpc.ServerCodec
+	if observe := callObserver(); observe != nil {
+		conn := newCountingConn(stream)
+		rpcCodec = newObservedServerCodec(codec.GoRpc.ServerCodec(conn, h), conn, observe)
+	} else {
+		rpcCodec = codec.GoRpc.ServerCodec(stream, h)
+	}
+	if limiter := s.mux.callLimiter(); limiter != nil {
+		rpcCodec = newLimitedServerCodec(rpcCodec, limiter)
+	}
 	s.server.ServeCodec(rpcCodec)
 }
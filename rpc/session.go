@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"io"
+	"log"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The sessions of the communicators served over RPC are served on streams of
+// their own, and closed along with them.
+
+type CommunicatorSessionArgs struct {
+	StreamId uint32
+}
+
+// NewSession opens a session of the communicator on the other end. It is
+// the communicator itself when the other end doesn't support the sessions.
+func (c *communicator) NewSession() (packersdk.Communicator, error) {
+	if !c.mux.has(FeatureCommunicatorSessions) {
+		return c, nil
+	}
+	args := CommunicatorSessionArgs{StreamId: c.mux.NextId()}
+	if err := c.client.Call(c.endpoint+".NewSession", &args, new(interface{})); err != nil {
+		return nil, err
+	}
+	client, err := newClientWithMux(c.mux, args.StreamId)
+	if err != nil {
+		return nil, err
+	}
+	return &communicatorSession{
+		communicator: &communicator{
+			commonClient: commonClient{
+				endpoint: DefaultCommunicatorEndpoint,
+				client:   client.client,
+				mux:      c.mux,
+			},
+		},
+		client: client,
+	}, nil
+}
+
+// communicatorSession is a session of a communicator served over RPC.
+type communicatorSession struct {
+	*communicator
+	client *Client
+}
+
+func (s *communicatorSession) Close() error {
+	return s.client.Close()
+}
+
+// NewSession serves a session of the communicator on args.StreamId, until
+// the client closes it. Without sessions, the communicator itself is served.
+func (c *CommunicatorServer) NewSession(args *CommunicatorSessionArgs, reply *interface{}) error {
+	session := c.c
+	sc, opened := c.c.(packersdk.SessionCommunicator)
+	if opened {
+		var err error
+		session, err = sc.NewSession()
+		if err != nil {
+			return NewBasicError(err)
+		}
+	}
+	server := newServerWithMux(c.mux, args.StreamId)
+	server.RegisterCommunicator(session)
+	go func() {
+		server.Serve()
+		if closer, ok := session.(io.Closer); ok && opened {
+			if err := closer.Close(); err != nil {
+				log.Printf("[WARN] Error closing a communicator session: %s", err)
+			}
+		}
+	}()
+	return nil
+}
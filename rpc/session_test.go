@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// sessionCommunicator opens sessions recording when they are closed.
+type sessionCommunicator struct {
+	*packersdk.MockCommunicator
+	closed chan bool
+}
+
+func (c *sessionCommunicator) NewSession() (packersdk.Communicator, error) {
+	return &closableCommunicator{
+		MockCommunicator: &packersdk.MockCommunicator{StartStdout: "session\n"},
+		closed:           c.closed,
+	}, nil
+}
+
+type closableCommunicator struct {
+	*packersdk.MockCommunicator
+	closed chan bool
+}
+
+func (c *closableCommunicator) Close() error {
+	c.closed <- true
+	return nil
+}
+
+func testSession(t *testing.T, comm packersdk.Communicator, closed chan bool) {
+	sc, ok := comm.(packersdk.SessionCommunicator)
+	if !ok {
+		t.Fatal("should be a SessionCommunicator")
+	}
+	session, err := sc.NewSession()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cmd := &packersdk.RemoteCmd{Command: "foo"}
+	ui := new(testUi)
+	if err := cmd.RunWithUi(context.Background(), session, ui); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ui.messageMessage != "session" {
+		t.Fatalf("bad: %q", ui.messageMessage)
+	}
+
+	closer, ok := session.(interface{ Close() error })
+	if !ok {
+		t.Fatal("the session should be closable")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session should be closed")
+	}
+}
+
+func TestCommunicatorRPC_session(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	closed := make(chan bool, 1)
+	server.RegisterCommunicator(&sessionCommunicator{new(packersdk.MockCommunicator), closed})
+
+	testSession(t, client.Communicator(), closed)
+}
+
+func TestCommunicatorRPC_sessionUnsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(&sessionCommunicator{new(packersdk.MockCommunicator), make(chan bool, 1)})
+	client.SetFeatures(nil)
+
+	comm := client.Communicator()
+	session, err := comm.(packersdk.SessionCommunicator).NewSession()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if session != comm {
+		t.Fatal("the session should be the communicator")
+	}
+}
+
+func TestGRPCCommunicator_session(t *testing.T) {
+	client, server := testGRPCClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	closed := make(chan bool, 1)
+	c := &sessionCommunicator{new(packersdk.MockCommunicator), closed}
+	testSession(t, &grpcCommunicator{peer: client.peer, id: server.peer.export(c)}, closed)
+}
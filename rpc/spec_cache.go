@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"sync"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+)
+
+// The specs of the configurations, generated from the FlatConfig structs of
+// the components, don't change once a plugin is started. Packer asks for
+// them several times per component, so that they are cached on both ends
+// of the connection: encoded by the servers, and decoded by the clients.
+
+// specCache caches values by key. The errors aren't cached, so that the
+// calls failing, or the components panicking, are retried.
+type specCache struct {
+	l      sync.Mutex
+	values map[string]interface{}
+}
+
+// get returns the value of key, loading it with load the first time.
+func (c *specCache) get(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.l.Lock()
+	v, ok := c.values[key]
+	c.l.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = v
+	return v, nil
+}
+
+// copySpec returns a copy of spec, so that the callers changing the specs
+// they get don't change the cached ones.
+func copySpec(spec hcldec.ObjectSpec) hcldec.ObjectSpec {
+	res := make(hcldec.ObjectSpec, len(spec))
+	for k, v := range spec {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// specCountingBuilder counts the calls to ConfigSpec.
+type specCountingBuilder struct {
+	packersdk.MockBuilder
+	specCalls int32
+}
+
+func (b *specCountingBuilder) ConfigSpec() hcldec.ObjectSpec {
+	atomic.AddInt32(&b.specCalls, 1)
+	return b.MockBuilder.ConfigSpec()
+}
+
+func TestConfigSpec_cached(t *testing.T) {
+	b := new(specCountingBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+
+	spec := client.Builder().ConfigSpec()
+	if len(spec) == 0 {
+		t.Fatal("empty spec")
+	}
+	delete(spec, "artifact_id")
+	if spec := client.Builder().ConfigSpec(); spec["artifact_id"] == nil {
+		t.Fatal("changing a spec should not change the cached one")
+	}
+	if b.specCalls != 1 {
+		t.Fatalf("ConfigSpec called %d times", b.specCalls)
+	}
+
+	// The servers cache the encoded spec too.
+	builderServer := &BuilderServer{commonServer: commonServer{selfConfigurable: b}}
+	for i := 0; i < 2; i++ {
+		reply := new(ConfigSpecResponse)
+		if err := builderServer.ConfigSpec(nil, reply); err != nil || len(reply.ConfigSpec) == 0 {
+			t.Fatalf("bad reply: %v", err)
+		}
+	}
+	if b.specCalls != 2 {
+		t.Fatalf("ConfigSpec called %d times", b.specCalls)
+	}
+
+	b = new(specCountingBuilder)
+	grpcClient, grpcServer := testGRPCClientServer(t)
+	defer grpcClient.Close()
+	defer grpcServer.Close()
+	grpcServer.RegisterBuilder(b)
+	for i := 0; i < 2; i++ {
+		if spec := grpcClient.Builder().ConfigSpec(); spec["artifact_id"] == nil {
+			t.Fatalf("bad spec: %#v", spec)
+		}
+	}
+	if b.specCalls != 1 {
+		t.Fatalf("ConfigSpec called %d times over gRPC", b.specCalls)
+	}
+}
+
+func BenchmarkConfigSpec(b *testing.B) {
+	client, server := testClientServer(b)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(new(packersdk.MockBuilder))
+	builder := client.Builder()
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			builder.ConfigSpec()
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			client.mux.specs = specCache{}
+			builder.ConfigSpec()
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/tracing"
+)
+
+// tracingClientCodec is a rpc.ClientCodec recording a span for each call,
+// from its request to its response.
+type tracingClientCodec struct {
+	rpc.ClientCodec
+
+	l     sync.Mutex
+	spans map[uint64]tracing.Span
+}
+
+func newTracingClientCodec(c rpc.ClientCodec) *tracingClientCodec {
+	return &tracingClientCodec{
+		ClientCodec: c,
+		spans:       make(map[uint64]tracing.Span),
+	}
+}
+
+func (c *tracingClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	_, span := tracing.Start(context.Background(), "rpc "+r.ServiceMethod,
+		tracing.Attribute{Key: "rpc.method", Value: r.ServiceMethod})
+	c.l.Lock()
+	c.spans[r.Seq] = span
+	c.l.Unlock()
+
+	err := c.ClientCodec.WriteRequest(r, body)
+	if err != nil {
+		c.end(r.Seq, err)
+	}
+	return err
+}
+
+func (c *tracingClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	err := c.ClientCodec.ReadResponseHeader(r)
+	if err == nil {
+		var callErr error
+		if r.Error != "" {
+			callErr = errors.New(r.Error)
+		}
+		c.end(r.Seq, callErr)
+	}
+	return err
+}
+
+func (c *tracingClientCodec) Close() error {
+	c.l.Lock()
+	for seq, span := range c.spans {
+		tracing.End(span, rpc.ErrShutdown)
+		delete(c.spans, seq)
+	}
+	c.l.Unlock()
+	return c.ClientCodec.Close()
+}
+
+func (c *tracingClientCodec) end(seq uint64, err error) {
+	c.l.Lock()
+	span, ok := c.spans[seq]
+	delete(c.spans, seq)
+	c.l.Unlock()
+	if ok {
+		tracing.End(span, err)
+	}
+}
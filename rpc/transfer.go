@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"io"
+)
+
+// The data of the transfers, like the uploads and downloads of the
+// communicators, is copied over streams of the connection by bounded chunks.
+// The streams are flow controlled: a chunk is only read once the previous
+// one was written, and a writer blocks once the other end has a window of
+// data it didn't read yet. A slow end slows the transfer down instead of
+// having the data buffered in memory.
+
+// transferChunkSize is the maximum size of the chunks of the transfers.
+const transferChunkSize = 64 * 1024
+
+// transferLogInterval is the number of bytes copied between the logs of the
+// progress of a transfer.
+const transferLogInterval = 16 * 1024 * 1024
+
+// copyChunks copies src to dst by chunks of at most transferChunkSize bytes.
+// progress, when not nil, is called with the number of bytes copied so far
+// after each chunk is written.
+func copyChunks(dst io.Writer, src io.Reader, progress func(written int64)) (int64, error) {
+	// Hiding the WriterTo of src, and the ReaderFrom of dst behind the
+	// countingWriter, keeps io.CopyBuffer copying through the buffer.
+	return io.CopyBuffer(&countingWriter{w: dst, progress: progress},
+		struct{ io.Reader }{src}, make([]byte, transferChunkSize))
+}
+
+// countingWriter counts the bytes written to w, calling progress, when not
+// nil, with the count after each write.
+type countingWriter struct {
+	w        io.Writer
+	written  int64
+	progress func(written int64)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	if err == nil && c.progress != nil {
+		c.progress(c.written)
+	}
+	return n, err
+}
+
+// progressBatch batches the sizes of the reads of a tracked stream, so that
+// the progress is sent to the Ui at most once per transferChunkSize bytes,
+// instead of once per read.
+type progressBatch struct {
+	pending int64
+}
+
+// add adds n read bytes, returning the number of bytes to send, or 0 when
+// they are batched. flush forces the batch to be sent.
+func (b *progressBatch) add(n int, flush bool) int64 {
+	b.pending += int64(n)
+	if b.pending == 0 || (!flush && b.pending < transferChunkSize) {
+		return 0
+	}
+	res := b.pending
+	b.pending = 0
+	return res
+}
+
+// readProgress reads n bytes of stream, a stream returned by the
+// TrackProgress method of a Ui, to advance its progress by n, without
+// allocating n bytes.
+func readProgress(stream io.Reader, n int64) {
+	size := int64(transferChunkSize)
+	if n < size {
+		size = n
+	}
+	buf := make([]byte, size)
+	for n > 0 {
+		if n < size {
+			buf = buf[:n]
+		}
+		read, err := stream.Read(buf)
+		n -= int64(read)
+		if err != nil {
+			return
+		}
+	}
+}
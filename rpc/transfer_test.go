@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+type maxWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *maxWriter) Write(b []byte) (int, error) {
+	if len(b) > w.max {
+		w.max = len(b)
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestCopyChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), transferChunkSize/2)
+
+	w := new(maxWriter)
+	var progress []int64
+	written, err := copyChunks(w, bytes.NewReader(data), func(n int64) {
+		progress = append(progress, n)
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if written != int64(len(data)) || !bytes.Equal(w.Bytes(), data) {
+		t.Fatalf("bad: %d bytes written", written)
+	}
+	if w.max > transferChunkSize {
+		t.Fatalf("chunk of %d bytes written", w.max)
+	}
+	if len(progress) != 5 || progress[len(progress)-1] != written {
+		t.Fatalf("bad progress: %v", progress)
+	}
+}
+
+// progressUi counts the reads of the streams it tracks.
+type progressUi struct {
+	testUi
+	reads int
+	read  int64
+}
+
+func (u *progressUi) TrackProgress(_ string, _, _ int64, stream io.ReadCloser) io.ReadCloser {
+	return &readCloser{
+		read: func(p []byte) (int, error) {
+			n, err := stream.Read(p)
+			u.reads++
+			u.read += int64(n)
+			return n, err
+		},
+		close: stream.Close,
+	}
+}
+
+func TestProgressTracking_batched(t *testing.T) {
+	ui := new(progressUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	size := 10*transferChunkSize + 1
+	rc := io.NopCloser(bytes.NewReader(make([]byte, size)))
+	stream := client.Ui().TrackProgress("stuff", 0, int64(size), rc)
+
+	// Read by small reads, each of them used to be an RPC call.
+	buf := make([]byte, 1024)
+	for {
+		_, err := stream.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	stream.Close()
+
+	if ui.read != int64(size) {
+		t.Fatalf("bad: progress of %d bytes, expected %d", ui.read, size)
+	}
+	if ui.reads > 11 {
+		t.Fatalf("bad: %d progress calls", ui.reads)
+	}
+}
@@ -4,7 +4,11 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"log"
+	"sync"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -14,6 +18,9 @@ import (
 type Ui struct {
 	commonClient
 	endpoint string
+
+	interactiveOnce sync.Once
+	interactive     bool
 }
 
 var _ packersdk.Ui = new(Ui)
@@ -31,11 +38,101 @@ type UiMachineArgs struct {
 	Args     []string
 }
 
+// The arguments sent to Ui.Log
+type UiLogArgs struct {
+	Level   packersdk.UiLevel
+	Message string
+}
+
+// askTimeoutGrace is how long the questions asked with a timeout wait for
+// the answer of the server after the timeout, before failing with
+// packersdk.ErrAskTimeout, so that a hung server doesn't block the build.
+var askTimeoutGrace = 5 * time.Second
+
 func (u *Ui) Ask(query string) (result string, err error) {
-	err = u.client.Call("Ui.Ask", query, &result)
+	if !u.Interactive() {
+		return "", packersdk.ErrNonInteractive
+	}
+	err = askError(u.client.Call("Ui.Ask", query, &result))
 	return
 }
 
+// The arguments sent to Ui.AskWithOptions
+type UiAskArgs struct {
+	Query   string
+	Options packersdk.AskOptions
+}
+
+func (u *Ui) AskWithOptions(query string, opts packersdk.AskOptions) (string, error) {
+	if !u.Interactive() {
+		return unanswered(opts, packersdk.ErrNonInteractive)
+	}
+	if !u.mux.has(FeatureAskOptions) {
+		return packersdk.AskWithOptions(baseUi{u}, query, opts)
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout+askTimeoutGrace)
+		defer cancel()
+	}
+	// The answer is written once the server answers, which can be after
+	// the timeout.
+	answer := new(string)
+	err := callContext(ctx, u.client, "Ui.AskWithOptions", &UiAskArgs{Query: query, Options: opts}, answer)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("[WARN] Ui.AskWithOptions not answered %s after its timeout", askTimeoutGrace)
+		return unanswered(opts, packersdk.ErrAskTimeout)
+	}
+	if err != nil {
+		return "", askError(err)
+	}
+	return *answer, nil
+}
+
+// Interactive returns whether the Ui of the server is interactive, asking it
+// once. The questions asked to a non-interactive Ui fail with
+// packersdk.ErrNonInteractive, or get their default answer, without being
+// sent to the server.
+func (u *Ui) Interactive() bool {
+	if !u.mux.has(FeatureUiInteractive) {
+		return true
+	}
+	u.interactiveOnce.Do(func() {
+		var interactive bool
+		if err := u.client.Call("Ui.Interactive", new(interface{}), &interactive); err != nil {
+			log.Printf("Error in Ui.Interactive RPC call: %s", err)
+			interactive = true
+		}
+		u.interactive = interactive
+	})
+	return u.interactive
+}
+
+// unanswered returns the answer of a question asked with opts which isn't
+// answered because of err, ErrNonInteractive or ErrAskTimeout.
+func unanswered(opts packersdk.AskOptions, err error) (string, error) {
+	if opts.Default != "" {
+		return opts.Default, nil
+	}
+	return "", err
+}
+
+// askError returns the errors of the questions asked to the server that can
+// be compared as such.
+func askError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, e := range []error{packersdk.ErrNonInteractive, packersdk.ErrAskTimeout, packersdk.ErrInterrupted} {
+		if err.Error() == e.Error() {
+			return e
+		}
+	}
+	return err
+}
+
 func (u *Ui) Error(message string) {
 	if err := u.client.Call("Ui.Error", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Error RPC call: %s", err)
@@ -59,17 +156,102 @@ func (u *Ui) Message(message string) {
 	}
 }
 
+// The arguments sent to Ui.SayWithFields
+type UiSayWithFieldsArgs struct {
+	Message string
+	Fields  map[string]interface{}
+}
+
+func (u *Ui) SayWithFields(message string, fields map[string]interface{}) {
+	if !u.mux.has(FeatureUiFields) {
+		packersdk.SayWithFields(baseUi{u}, message, fields)
+		return
+	}
+	rpcArgs := &UiSayWithFieldsArgs{
+		Message: message,
+		Fields:  fields,
+	}
+
+	if err := u.client.Call("Ui.SayWithFields", rpcArgs, new(interface{})); err != nil {
+		log.Printf("Error in Ui.SayWithFields RPC call: %s", err)
+	}
+}
+
 func (u *Ui) Say(message string) {
 	if err := u.client.Call("Ui.Say", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Say RPC call: %s", err)
 	}
 }
 
+// Log writes message with the Ui of the server, which filters it by level.
+func (u *Ui) Log(level packersdk.UiLevel, message string) {
+	if !u.mux.has(FeatureUiLog) {
+		packersdk.UiLog(baseUi{u}, level, message)
+		return
+	}
+	rpcArgs := &UiLogArgs{
+		Level:   level,
+		Message: message,
+	}
+
+	if err := u.client.Call("Ui.Log", rpcArgs, new(interface{})); err != nil {
+		log.Printf("Error in Ui.Log RPC call: %s", err)
+	}
+}
+
+// RegisterSecrets adds secrets to the secrets redacted by the server. Older
+// servers only get the output already redacted by the plugin.
+func (u *Ui) RegisterSecrets(secrets ...string) {
+	if !u.mux.has(FeatureUiSecrets) {
+		return
+	}
+	if err := u.client.Call("Ui.RegisterSecrets", secrets, new(interface{})); err != nil {
+		log.Printf("Error in Ui.RegisterSecrets RPC call: %s", err)
+	}
+}
+
+// Emit emits e with the Ui of the server. Servers without events ignore
+// them.
+func (u *Ui) Emit(e packersdk.Event) {
+	if !u.mux.has(FeatureUiEvents) {
+		return
+	}
+	if err := u.client.Call("Ui.Emit", &e, new(interface{})); err != nil {
+		log.Printf("Error in Ui.Emit RPC call: %s", err)
+	}
+}
+
+func (u *Ui) StepStarted(name string) {
+	packersdk.EmitEvent(u, packersdk.Event{Type: packersdk.EventStepStarted, Step: name})
+}
+
+func (u *Ui) StepFinished(name string, halted bool) {
+	packersdk.EmitEvent(u, packersdk.Event{Type: packersdk.EventStepFinished, Step: name, Halted: halted})
+}
+
+func (u *Ui) BuildStarted() {
+	packersdk.EmitEvent(u, packersdk.Event{Type: packersdk.EventBuildStarted})
+}
+
+func (u *Ui) BuildFinished(halted bool, err error) {
+	packersdk.EmitBuildFinished(u, halted, err)
+}
+
 func (u *UiServer) Ask(query string, reply *string) (err error) {
 	*reply, err = u.ui.Ask(query)
 	return
 }
 
+func (u *UiServer) AskWithOptions(args *UiAskArgs, reply *string) (err error) {
+	*reply, err = packersdk.AskWithOptions(u.ui, args.Query, args.Options)
+	return
+}
+
+func (u *UiServer) Interactive(args *interface{}, reply *bool) error {
+	*reply = packersdk.IsInteractive(u.ui)
+	return nil
+}
+
 func (u *UiServer) Error(message *string, reply *interface{}) error {
 	u.ui.Error(*message)
 
@@ -96,3 +278,31 @@ func (u *UiServer) Say(message *string, reply *interface{}) error {
 	*reply = nil
 	return nil
 }
+
+func (u *UiServer) Log(args *UiLogArgs, reply *interface{}) error {
+	packersdk.UiLog(u.ui, args.Level, args.Message)
+
+	*reply = nil
+	return nil
+}
+
+func (u *UiServer) RegisterSecrets(secrets []string, reply *interface{}) error {
+	packersdk.RegisterSecrets(u.ui, secrets...)
+
+	*reply = nil
+	return nil
+}
+
+func (u *UiServer) SayWithFields(args *UiSayWithFieldsArgs, reply *interface{}) error {
+	packersdk.SayWithFields(u.ui, args.Message, args.Fields)
+
+	*reply = nil
+	return nil
+}
+
+func (u *UiServer) Emit(e *packersdk.Event, reply *interface{}) error {
+	packersdk.EmitEvent(u.ui, *e)
+
+	*reply = nil
+	return nil
+}
@@ -37,20 +37,30 @@ type ProgressTrackingClient struct {
 	id     string
 	client *rpc.Client
 	stream io.ReadCloser
+	batch  progressBatch
 }
 
-// Read will send len(b) over the wire instead of it's content
+// Read will send len(b) over the wire instead of it's content, batched by
+// chunks of bytes read.
 func (u *ProgressTrackingClient) Read(b []byte) (read int, err error) {
-	defer func() {
-		if err := u.client.Call("Ui"+u.id+".Add", read, new(interface{})); err != nil {
-			log.Printf("Error in ProgressTrackingClient.Read RPC call: %s", err)
-		}
-	}()
-	return u.stream.Read(b)
+	read, err = u.stream.Read(b)
+	u.add(read, err != nil)
+	return read, err
+}
+
+func (u *ProgressTrackingClient) add(read int, flush bool) {
+	n := u.batch.add(read, flush)
+	if n == 0 {
+		return
+	}
+	if err := u.client.Call("Ui"+u.id+".Add", int(n), new(interface{})); err != nil {
+		log.Printf("Error in ProgressTrackingClient.Read RPC call: %s", err)
+	}
 }
 
 func (u *ProgressTrackingClient) Close() error {
 	log.Printf("closing")
+	u.add(0, true)
 	if err := u.client.Call("Ui"+u.id+".Close", nil, new(interface{})); err != nil {
 		log.Printf("Error in ProgressTrackingClient.Close RPC call: %s", err)
 	}
@@ -85,8 +95,7 @@ type ProgressTrackingServer struct {
 }
 
 func (t *ProgressTrackingServer) Add(size int, _ *interface{}) error {
-	stubBytes := make([]byte, size)
-	t.stream.Read(stubBytes)
+	readProgress(t.stream, int64(size))
 	return nil
 }
 
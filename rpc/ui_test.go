@@ -9,6 +9,10 @@ import (
 	"io/ioutil"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 type testUi struct {
@@ -27,6 +31,17 @@ type testUi struct {
 	trackProgressCalled    bool
 	progressBarAddCalled   bool
 	progressBarCloseCalled bool
+
+	events  []packersdk.Event
+	secrets []string
+}
+
+func (u *testUi) RegisterSecrets(secrets ...string) {
+	u.secrets = append(u.secrets, secrets...)
+}
+
+func (u *testUi) Emit(e packersdk.Event) {
+	u.events = append(u.events, e)
 }
 
 func (u *testUi) Ask(query string) (string, error) {
@@ -129,8 +144,10 @@ func TestUiRPC(t *testing.T) {
 		t.Errorf("ProgressBastream not called.")
 	}
 
-	b := []byte{0}
-	stream.Read(b) // output ignored
+	// The reads are batched, the progress is sent by chunk or at EOF.
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		t.Fatalf("err: %s", err)
+	}
 	if ui.progressBarAddCalled != true {
 		t.Errorf("Add not called.")
 	}
@@ -154,3 +171,179 @@ func TestUiRPC(t *testing.T) {
 		t.Fatalf("bad: %#v", ui.machineArgs)
 	}
 }
+
+func TestUiRPC_Emit(t *testing.T) {
+	ui := new(testUi)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	uiClient := client.Ui()
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := packersdk.Event{
+		Type:       packersdk.EventError,
+		Time:       now,
+		Build:      "docker.ubuntu",
+		Message:    "oops",
+		ErrorClass: packersdk.ErrorClassUser,
+	}
+	packersdk.EmitEvent(uiClient, event)
+	uiClient.(multistep.StepReporter).StepStarted("StepCreate")
+
+	if len(ui.events) != 2 {
+		t.Fatalf("expected 2 events, got %#v", ui.events)
+	}
+	if got := ui.events[0]; !got.Time.Equal(now) || got.Type != event.Type || got.Build != event.Build ||
+		got.Message != event.Message || got.ErrorClass != event.ErrorClass {
+		t.Fatalf("expected %#v, got %#v", event, got)
+	}
+	if got := ui.events[1]; got.Type != packersdk.EventStepStarted || got.Step != "StepCreate" {
+		t.Fatalf("unexpected step event %#v", got)
+	}
+}
+
+func TestUiRPC_Log(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: &out,
+		Level:  packersdk.UiLevelDebug,
+	}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	uiClient := client.Ui()
+	packersdk.UiLog(uiClient, packersdk.UiLevelTrace, "trace")
+	packersdk.UiLog(uiClient, packersdk.UiLevelDebug, "debug")
+	packersdk.UiLog(uiClient, packersdk.UiLevelWarn, "warn")
+
+	if expected := "debug\nwarn\n"; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestUiRPC_RegisterSecrets(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	ui := new(testUi)
+	server.RegisterUi(ui)
+
+	packersdk.RegisterSecrets(client.Ui(), "generated-p4ssw0rd")
+	if !reflect.DeepEqual(ui.secrets, []string{"generated-p4ssw0rd"}) {
+		t.Fatalf("expected the secret to be forwarded, got %#v", ui.secrets)
+	}
+	if got := packersdk.LogSecretFilter.FilterString("generated-p4ssw0rd"); got != "<sensitive>" {
+		t.Fatalf("expected the secret to be filtered, got %q", got)
+	}
+}
+
+func TestUiRPC_AskWithOptions(t *testing.T) {
+	ui := &packersdk.BasicUi{Writer: ioutil.Discard}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	uiClient := client.Ui()
+	if _, err := uiClient.Ask("Overwrite?"); err != packersdk.ErrNonInteractive {
+		t.Fatalf("expected ErrNonInteractive, got %v", err)
+	}
+	answer, err := packersdk.AskWithOptions(uiClient, "Overwrite?", packersdk.AskOptions{Default: "n"})
+	if err != nil || answer != "n" {
+		t.Fatalf("expected the default answer, got %q, %v", answer, err)
+	}
+}
+
+// hangingUi never answers the questions until released.
+type hangingUi struct {
+	testUi
+	interactive bool
+	release     chan struct{}
+}
+
+func (u *hangingUi) Ask(string) (string, error) {
+	<-u.release
+	return "late", nil
+}
+
+func (u *hangingUi) AskWithOptions(string, packersdk.AskOptions) (string, error) {
+	return u.Ask("")
+}
+
+func (u *hangingUi) Interactive() bool { return u.interactive }
+
+func testUiAskHanging(t *testing.T, newClient func(packersdk.Ui) packersdk.Ui) {
+	defer func(grace time.Duration) { askTimeoutGrace = grace }(askTimeoutGrace)
+	askTimeoutGrace = 10 * time.Millisecond
+
+	// The questions to a non-interactive Ui aren't sent.
+	ui := &hangingUi{release: make(chan struct{})}
+	defer close(ui.release)
+	uiClient := newClient(ui)
+	if _, err := uiClient.Ask("Overwrite?"); err != packersdk.ErrNonInteractive {
+		t.Fatalf("expected ErrNonInteractive, got %v", err)
+	}
+	answer, err := packersdk.AskWithOptions(uiClient, "Overwrite?", packersdk.AskOptions{Default: "n"})
+	if err != nil || answer != "n" {
+		t.Fatalf("expected the default answer, got %q, %v", answer, err)
+	}
+
+	// The questions asked with a timeout don't wait for a hung Ui.
+	ui = &hangingUi{interactive: true, release: make(chan struct{})}
+	defer close(ui.release)
+	uiClient = newClient(ui)
+	opts := packersdk.AskOptions{Timeout: 10 * time.Millisecond}
+	if _, err := packersdk.AskWithOptions(uiClient, "Overwrite?", opts); err != packersdk.ErrAskTimeout {
+		t.Fatalf("expected ErrAskTimeout, got %v", err)
+	}
+	opts.Default = "n"
+	answer, err = packersdk.AskWithOptions(uiClient, "Overwrite?", opts)
+	if err != nil || answer != "n" {
+		t.Fatalf("expected the default answer, got %q, %v", answer, err)
+	}
+}
+
+func TestUiRPC_AskHanging(t *testing.T) {
+	testUiAskHanging(t, func(ui packersdk.Ui) packersdk.Ui {
+		client, server := testClientServer(t)
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+		server.RegisterUi(ui)
+		return client.Ui()
+	})
+}
+
+func TestGRPCUi_AskHanging(t *testing.T) {
+	testUiAskHanging(t, func(ui packersdk.Ui) packersdk.Ui {
+		client, server := testGRPCClientServer(t)
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+		return &grpcUi{peer: client.peer, id: server.peer.export(ui)}
+	})
+}
+
+func TestUiRPC_SayWithFields(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packersdk.JSONUi{Writer: &out}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	packersdk.SayWithFields(client.Ui(), "Instance started", map[string]interface{}{"instance_id": "i-1234"})
+	if !bytes.Contains(out.Bytes(), []byte(`"fields":{"instance_id":"i-1234"}`)) {
+		t.Fatalf("expected the fields, got %s", out.String())
+	}
+}
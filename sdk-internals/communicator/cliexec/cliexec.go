@@ -0,0 +1,42 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package cliexec runs the commands of the communicators whose transport is
+// a local CLI, such as docker exec or kubectl exec.
+package cliexec
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Start starts the local command localCmd, which runs cmd on the machine,
+// with the standard streams of cmd. The exit status of cmd is set when
+// localCmd exits, to 1 when it couldn't run.
+func Start(localCmd *exec.Cmd, cmd *packersdk.RemoteCmd) error {
+	localCmd.Stdin = cmd.Stdin
+	localCmd.Stdout = cmd.Stdout
+	localCmd.Stderr = cmd.Stderr
+	if err := localCmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		exitStatus := 0
+		if err := localCmd.Wait(); err != nil {
+			exitStatus = 1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitStatus = exitErr.ExitCode()
+			}
+		}
+
+		log.Printf("[INFO] %s exited with '%d'", cmd.Command, exitStatus)
+		cmd.SetExited(exitStatus)
+	}()
+
+	return nil
+}
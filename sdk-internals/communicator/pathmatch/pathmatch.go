@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package pathmatch matches the paths of the directory transfers of the
+// communicators against their exclude glob patterns.
+package pathmatch
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Excluded reports whether p, relative to the root of the transferred
+// directory, matches one of the exclude glob patterns, either as a whole or
+// by its base name. Both are matched with slashes, whatever the host.
+func Excluded(p string, patterns []string) bool {
+	p = filepath.ToSlash(p)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGlobMeta reports whether p contains any of the special characters of
+// glob patterns.
+func HasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
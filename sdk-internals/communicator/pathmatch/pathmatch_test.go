@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package pathmatch
+
+import "testing"
+
+func TestExcluded(t *testing.T) {
+	cases := []struct {
+		path     string
+		patterns []string
+		expected bool
+	}{
+		{"foo", nil, false},
+		{"foo", []string{"foo"}, true},
+		{"bar/foo", []string{"foo"}, true},
+		{"bar/foo", []string{"bar/*"}, true},
+		{"bar/foo", []string{"bar/"}, false},
+		{"bar", []string{"bar/"}, true},
+		{"bar/foo.log", []string{"*.txt", "*.log"}, true},
+		{"bar/foo.log", []string{"baz/*.log"}, false},
+	}
+	for _, tc := range cases {
+		if actual := Excluded(tc.path, tc.patterns); actual != tc.expected {
+			t.Errorf("%q %q: expected %t, got %t", tc.path, tc.patterns, tc.expected, actual)
+		}
+	}
+}
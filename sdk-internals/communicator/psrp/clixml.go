@@ -0,0 +1,244 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The messages hold objects serialized in CLIXML, the format of
+// Export-Clixml. Only the few messages sent by the communicator are
+// serialized, and received objects are only read as strings.
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-psrp
+
+// protocolVersion is the version of PSRP spoken by the client, that of
+// PowerShell 5.1.
+const protocolVersion = "2.3"
+
+func sessionCapability() []byte {
+	return []byte(`<Obj RefId="0"><MS>` +
+		`<Version N="protocolversion">` + protocolVersion + `</Version>` +
+		`<Version N="PSVersion">2.0</Version>` +
+		`<Version N="SerializationVersion">1.1.0.1</Version>` +
+		`</MS></Obj>`)
+}
+
+// nullHostInfo tells that the client has no host: host calls such as
+// Read-Host fail on the server.
+const nullHostInfo = `<MS>` +
+	`<B N="_isHostNull">true</B>` +
+	`<B N="_isHostUINull">true</B>` +
+	`<B N="_isHostRawUINull">true</B>` +
+	`<B N="_useRunspaceHost">true</B>` +
+	`</MS>`
+
+// initRunspacePool opens a pool of a single runspace.
+func initRunspacePool() []byte {
+	return []byte(`<Obj RefId="0"><MS>` +
+		`<I32 N="MinRunspaces">1</I32>` +
+		`<I32 N="MaxRunspaces">1</I32>` +
+		`<Obj N="PSThreadOptions" RefId="1"><TN RefId="0">` +
+		`<T>System.Management.Automation.Runspaces.PSThreadOptions</T>` +
+		`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>Default</ToString><I32>0</I32></Obj>` +
+		`<Obj N="ApartmentState" RefId="2"><TN RefId="1">` +
+		`<T>System.Threading.ApartmentState</T>` +
+		`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>Unknown</ToString><I32>2</I32></Obj>` +
+		`<Obj N="HostInfo" RefId="3">` + nullHostInfo + `</Obj>` +
+		`<Nil N="ApplicationArguments" />` +
+		`</MS></Obj>`)
+}
+
+// createPipeline runs script, a PowerShell script reading its input, if
+// any, from $input.
+func createPipeline(script string, noInput bool) []byte {
+	var b strings.Builder
+	b.WriteString(`<Obj RefId="0"><MS>`)
+	fmt.Fprintf(&b, `<B N="NoInput">%t</B>`, noInput)
+	b.WriteString(`<Obj N="ApartmentState" RefId="1"><TN RefId="0">` +
+		`<T>System.Threading.ApartmentState</T>` +
+		`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>Unknown</ToString><I32>2</I32></Obj>`)
+	b.WriteString(`<Obj N="RemoteStreamOptions" RefId="2"><TN RefId="1">` +
+		`<T>System.Management.Automation.RemoteStreamOptions</T>` +
+		`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>0</ToString><I32>0</I32></Obj>`)
+	b.WriteString(`<B N="AddToHistory">false</B>`)
+	b.WriteString(`<Obj N="HostInfo" RefId="3">` + nullHostInfo + `</Obj>`)
+
+	b.WriteString(`<Obj N="PowerShell" RefId="4"><MS>`)
+	b.WriteString(`<B N="IsNested">false</B>`)
+	b.WriteString(`<Nil N="ExtraCmds" />`)
+	b.WriteString(`<Obj N="Cmds" RefId="5"><TN RefId="2">` +
+		"<T>System.Collections.Generic.List`1[[System.Management.Automation.PSObject, " +
+		"System.Management.Automation, Version=1.0.0.0, Culture=neutral, PublicKeyToken=31bf3856ad364e35]]</T>" +
+		`<T>System.Object</T></TN><LST>`)
+	b.WriteString(`<Obj RefId="6"><MS>`)
+	b.WriteString(`<S N="Cmd">` + clixmlString(script) + `</S>`)
+	b.WriteString(`<B N="IsScript">true</B>`)
+	b.WriteString(`<Nil N="UseLocalScope" />`)
+	b.WriteString(`<Obj N="MergeMyResult" RefId="7"><TN RefId="3">` +
+		`<T>System.Management.Automation.Runspaces.PipelineResultTypes</T>` +
+		`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>None</ToString><I32>0</I32></Obj>`)
+	refID := 8
+	for _, merge := range []string{"MergeToResult", "MergePreviousResults", "MergeError",
+		"MergeWarning", "MergeVerbose", "MergeDebug", "MergeInformation"} {
+		fmt.Fprintf(&b, `<Obj N="%s" RefId="%d"><TNRef RefId="3" /><ToString>None</ToString><I32>0</I32></Obj>`,
+			merge, refID)
+		refID++
+	}
+	fmt.Fprintf(&b, `<Obj N="Args" RefId="%d"><TNRef RefId="2" /><LST /></Obj>`, refID)
+	b.WriteString(`</MS></Obj>`)
+	b.WriteString(`</LST></Obj>`)
+	b.WriteString(`<Nil N="History" />`)
+	b.WriteString(`<B N="RedirectShellErrorOutputPipe">false</B>`)
+	b.WriteString(`</MS></Obj>`)
+
+	b.WriteString(`<B N="IsNested">false</B>`)
+	b.WriteString(`</MS></Obj>`)
+	return []byte(b.String())
+}
+
+// pipelineInput returns the serialized string s.
+func pipelineInput(s string) []byte {
+	return []byte(`<S>` + clixmlString(s) + `</S>`)
+}
+
+var clixmlEscapeRe = regexp.MustCompile(`(?i)_(x[0-9a-f]{4}_)`)
+
+// clixmlString escapes s for CLIXML: besides XML escaping, control
+// characters are encoded as _xHHHH_, and the underscore of text that looks
+// like such an encoding is itself encoded.
+func clixmlString(s string) string {
+	s = clixmlEscapeRe.ReplaceAllString(s, "_x005F_$1")
+	var b bytes.Buffer
+	for _, r := range s {
+		if r < 0x20 || r == 0xfffe || r == 0xffff {
+			fmt.Fprintf(&b, "_x%04X_", r)
+			continue
+		}
+		xml.EscapeText(&b, []byte(string(r)))
+	}
+	return b.String()
+}
+
+var clixmlUnescapeRe = regexp.MustCompile(`(?i)_x([0-9a-f]{4})_`)
+
+// clixmlUnescape decodes the _xHHHH_ encodings of s, which has already been
+// XML unescaped.
+func clixmlUnescape(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	var units []uint16
+	var b strings.Builder
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+	last := 0
+	for _, m := range clixmlUnescapeRe.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] != last {
+			flush()
+			b.WriteString(s[last:m[0]])
+		}
+		u, _ := strconv.ParseUint(s[m[2]:m[3]], 16, 16)
+		units = append(units, uint16(u))
+		last = m[1]
+	}
+	flush()
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// clixmlNode is an element of a serialized object.
+type clixmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Content  string       `xml:",chardata"`
+	Children []clixmlNode `xml:",any"`
+}
+
+func parseCLIXML(data []byte) (*clixmlNode, error) {
+	var n clixmlNode
+	if err := xml.Unmarshal(data, &n); err != nil {
+		return nil, fmt.Errorf("invalid CLIXML: %w", err)
+	}
+	return &n, nil
+}
+
+// attr returns the value of the attribute name of n.
+func (n *clixmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child of n of the element name, if any, with the
+// property name prop unless it is empty.
+func (n *clixmlNode) child(name string, prop string) *clixmlNode {
+	for i := range n.Children {
+		c := &n.Children[i]
+		if c.XMLName.Local == name && (prop == "" || c.attr("N") == prop) {
+			return c
+		}
+	}
+	return nil
+}
+
+// property returns the member property name of the object n.
+func (n *clixmlNode) property(name string) *clixmlNode {
+	for _, set := range []string{"MS", "Props"} {
+		if ms := n.child(set, ""); ms != nil {
+			for i := range ms.Children {
+				if ms.Children[i].attr("N") == name {
+					return &ms.Children[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the string form of the serialized object n: the value of
+// primitive objects, otherwise the result of their ToString method.
+func (n *clixmlNode) String() string {
+	switch n.XMLName.Local {
+	case "Nil":
+		return ""
+	case "Obj":
+		if s := n.child("ToString", ""); s != nil {
+			return clixmlUnescape(s.Content)
+		}
+		for i := range n.Children {
+			c := &n.Children[i]
+			switch c.XMLName.Local {
+			case "TN", "TNRef", "MS", "Props":
+				continue
+			}
+			return c.String()
+		}
+		return ""
+	default:
+		return clixmlUnescape(n.Content)
+	}
+}
+
+// int returns the integer value of n.
+func (n *clixmlNode) int() (int, error) {
+	return strconv.Atoi(strings.TrimSpace(n.Content))
+}
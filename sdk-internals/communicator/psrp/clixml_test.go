@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"testing"
+)
+
+func TestCLIXMLString(t *testing.T) {
+	cases := map[string]string{
+		"plain":            "plain",
+		"<a & b>":          "&lt;a &amp; b&gt;",
+		"line\r\nnext":     "line_x000D__x000A_next",
+		"_x0041_":          "_x005F_x0041_",
+		"snake_case_x":     "snake_case_x",
+		"emoji \U0001F600": "emoji \U0001F600",
+	}
+	for s, expected := range cases {
+		escaped := clixmlString(s)
+		if escaped != expected {
+			t.Errorf("%q: expected %q, got %q", s, expected, escaped)
+		}
+		obj, err := parseCLIXML([]byte("<S>" + escaped + "</S>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.String() != s {
+			t.Errorf("%q: unescaped to %q", s, obj.String())
+		}
+	}
+
+	if got := clixmlUnescape("_xD83D__xDE00_"); got != "\U0001F600" {
+		t.Errorf("expected the surrogate pair to be decoded, got %q", got)
+	}
+}
+
+func TestCLIXMLNode_String(t *testing.T) {
+	cases := map[string]string{
+		`<Nil />`:       "",
+		`<I32>42</I32>`: "42",
+		`<Obj RefId="0"><TN RefId="0"><T>System.Management.Automation.ErrorRecord</T></TN>` +
+			`<ToString>Access is denied</ToString><MS><S N="Message">x</S></MS></Obj>`: "Access is denied",
+		`<Obj RefId="0"><TN RefId="0"><T>System.IO.FileAttributes</T></TN><I32>32</I32></Obj>`: "32",
+	}
+	for data, expected := range cases {
+		obj, err := parseCLIXML([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := obj.String(); got != expected {
+			t.Errorf("%s: expected %q, got %q", data, expected, got)
+		}
+	}
+}
+
+func TestCreatePipeline(t *testing.T) {
+	obj, err := parseCLIXML(createPipeline("Write-Output '<ok>'", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := obj.property("PowerShell").property("Cmds").child("LST", "")
+	if cmds == nil || len(cmds.Children) != 1 {
+		t.Fatalf("expected a single command")
+	}
+	if cmd := cmds.Children[0].property("Cmd").String(); cmd != "Write-Output '<ok>'" {
+		t.Fatalf("unexpected command %q", cmd)
+	}
+	if noInput := obj.property("NoInput").String(); noInput != "true" {
+		t.Fatalf("unexpected NoInput %q", noInput)
+	}
+
+	for _, data := range [][]byte{sessionCapability(), initRunspacePool(), pipelineInput("x")} {
+		if _, err := parseCLIXML(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
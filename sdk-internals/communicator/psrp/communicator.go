@@ -0,0 +1,347 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package psrp implements a WinRM communicator running PowerShell pipelines
+// over the PowerShell Remoting Protocol, rather than commands in WinRM
+// shells. Plugin maintainers should not import this package directly, instead
+// using the tooling in the "packer-plugin-sdk/communicator" module.
+package psrp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/throttle"
+	"github.com/masterzen/winrm"
+)
+
+// Config is used to configure the PSRP connection
+type Config struct {
+	Host               string
+	Port               int
+	Username           string
+	Password           string
+	Timeout            time.Duration
+	Https              bool
+	Insecure           bool
+	TransportDecorator func() winrm.Transporter
+
+	// TransferRateLimit limits file transfers to that many bytes per second,
+	// shared by all the transfers. Zero doesn't limit them.
+	TransferRateLimit int64
+}
+
+// Communicator runs the commands and transfers the files through a pool of
+// a single PowerShell runspace. Commands run one at a time; the files are
+// streamed as pipeline input and output, in large fragments.
+type Communicator struct {
+	config  *Config
+	limiter *throttle.Limiter
+
+	l    sync.Mutex
+	pool *runspacePool
+}
+
+// New creates a new communicator implementation over PSRP, opening its
+// runspace pool.
+func New(config *Config) (*Communicator, error) {
+	// The host is used as is in the endpoint URL, which requires IPv6
+	// literals to be bracketed.
+	endpoint := &winrm.Endpoint{
+		Host:     packernet.URLHost(config.Host),
+		Port:     config.Port,
+		HTTPS:    config.Https,
+		Insecure: config.Insecure,
+	}
+
+	params := *winrm.DefaultParameters
+	// The client runs the transport, which is then used for the PowerShell
+	// requests.
+	var transport winrm.Transporter
+	decorator := config.TransportDecorator
+	if decorator == nil {
+		decorator = func() winrm.Transporter { return winrm.NewClientWithDial(nil) }
+	}
+	params.TransportDecorator = func() winrm.Transporter {
+		transport = decorator()
+		return transport
+	}
+	client, err := winrm.NewClientWithParameters(endpoint, config.Username, config.Password, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "http"
+	if endpoint.HTTPS {
+		scheme = "https"
+	}
+	w := &wsman{
+		client:    client,
+		transport: transport,
+		url:       fmt.Sprintf("%s://%s:%d/wsman", scheme, endpoint.Host, endpoint.Port),
+		params:    &params,
+	}
+
+	log.Printf("[DEBUG] opening PowerShell runspace pool using PSRP")
+	pool, err := openRunspacePool(w)
+	if err != nil {
+		log.Printf("[ERROR] connection error: %s", err)
+		return nil, err
+	}
+
+	return &Communicator{
+		config:  config,
+		limiter: throttle.New(config.TransferRateLimit),
+		pool:    pool,
+	}, nil
+}
+
+// Close closes the runspace pool.
+func (c *Communicator) Close() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.pool.close()
+}
+
+// Start implementation of communicator.Communicator interface. The command
+// line is run by cmd.exe, like in a WinRM shell; its standard output is
+// written to the standard output of rc, and the error records, including the
+// standard error of native commands, to its standard error.
+func (c *Communicator) Start(ctx context.Context, rc *packersdk.RemoteCmd) error {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	// The exit code is written by the script as a last output object,
+	// along with a random token.
+	marker := "__packer_exit_code_" + hex.EncodeToString(token)
+	script := fmt.Sprintf("cmd.exe /c --%% %s\n\"%s $LASTEXITCODE\"", rc.Command, marker)
+
+	c.l.Lock()
+	log.Printf("[INFO] starting remote command: %s", rc.Command)
+	pl, err := c.pool.newPipeline(script, true)
+	if err != nil {
+		c.l.Unlock()
+		return err
+	}
+
+	go func() {
+		defer c.l.Unlock()
+
+		code := packersdk.CmdDisconnect
+		err := pl.wait(ctx, func(m *message) {
+			if m.messageType == msgPipelineOutput {
+				if obj, err := parseCLIXML(m.data); err == nil {
+					if s := obj.String(); strings.HasPrefix(s, marker+" ") {
+						code, _ = strconv.Atoi(strings.TrimPrefix(s, marker+" "))
+						return
+					}
+				}
+			}
+			writeMessage(rc.Stdout, rc.Stderr, m)
+		})
+		if err != nil {
+			log.Printf("[ERROR] PowerShell pipeline of '%s' failed: %s", rc.Command, err)
+			if rc.Stderr != nil {
+				io.WriteString(rc.Stderr, err.Error()+"\r\n")
+			}
+		}
+		log.Printf("[INFO] command '%s' exited with code: %d", rc.Command, code)
+		rc.SetExited(code)
+	}()
+	return nil
+}
+
+// writeMessage writes the records of the streams of PowerShell, prefixed like
+// in the console for the warning, verbose and debug ones.
+func writeMessage(stdout, stderr io.Writer, m *message) {
+	switch m.messageType {
+	case msgPipelineOutput, msgInformationRecord:
+		writeRecord(stdout, "", m)
+	case msgErrorRecord:
+		writeRecord(stderr, "", m)
+	case msgWarningRecord:
+		writeRecord(stdout, "WARNING: ", m)
+	case msgVerboseRecord:
+		writeRecord(stdout, "VERBOSE: ", m)
+	case msgDebugRecord:
+		writeRecord(stdout, "DEBUG: ", m)
+	}
+}
+
+// run runs script, sending it the input objects returned by input until it
+// returns io.EOF, unless input is nil. The output objects are passed to
+// output, and the error records make it fail.
+func (c *Communicator) run(script string, input func() (string, error), output func(string) error) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	pl, err := c.pool.newPipeline(script, input == nil)
+	if err != nil {
+		return err
+	}
+	if input != nil {
+		for {
+			s, err := input()
+			if err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = pl.input(s)
+			}
+			if err != nil {
+				pl.pool.wsman.signal(pl.pool.shellID, pl.id)
+				return err
+			}
+		}
+		if err := pl.endInput(); err != nil {
+			return err
+		}
+	}
+
+	var errs []string
+	var outputErr error
+	err = pl.wait(context.Background(), func(m *message) {
+		obj, err := parseCLIXML(m.data)
+		if err != nil {
+			outputErr = err
+			return
+		}
+		switch m.messageType {
+		case msgPipelineOutput:
+			if outputErr == nil {
+				outputErr = output(obj.String())
+			}
+		case msgErrorRecord:
+			errs = append(errs, obj.String())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return outputErr
+}
+
+// psPath returns path in a PowerShell expression expanding its variables,
+// such as $env:TEMP, and resolving it against the current location.
+func psPath(path string) string {
+	path = strings.NewReplacer("`", "``", `"`, "`\"").Replace(path)
+	return fmt.Sprintf(`$ExecutionContext.SessionState.Path.GetUnresolvedProviderPathFromPSPath("%s")`, path)
+}
+
+// uploadScript writes the base64 encoded input objects to a file, creating
+// its directory.
+const uploadScript = `$ErrorActionPreference = 'Stop'
+$path = %s
+$dir = [System.IO.Path]::GetDirectoryName($path)
+if ($dir) { [void][System.IO.Directory]::CreateDirectory($dir) }
+$fs = [System.IO.File]::Create($path)
+try {
+	foreach ($chunk in $input) {
+		$bytes = [System.Convert]::FromBase64String($chunk)
+		$fs.Write($bytes, 0, $bytes.Length)
+	}
+} finally {
+	$fs.Dispose()
+}`
+
+// Upload implementation of communicator.Communicator interface
+func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) error {
+	if strings.HasSuffix(path, `\`) {
+		// path is a directory
+		if fi != nil {
+			path += filepath.Base((*fi).Name())
+		} else {
+			return fmt.Errorf("Was unable to infer file basename for upload.")
+		}
+	}
+	log.Printf("Uploading file to '%s'", path)
+
+	input = c.limiter.Reader(input)
+	buf := make([]byte, c.pool.maxInput()/4*3)
+	return c.run(fmt.Sprintf(uploadScript, psPath(path)), func() (string, error) {
+		n, err := io.ReadFull(input, buf)
+		if err == io.ErrUnexpectedEOF || (err == io.EOF && n > 0) {
+			err = nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(buf[:n]), nil
+	}, nil)
+}
+
+// UploadDir implementation of communicator.Communicator interface
+func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	if !strings.HasSuffix(src, "/") {
+		dst = fmt.Sprintf("%s\\%s", dst, filepath.Base(src))
+	}
+	log.Printf("Uploading dir '%s' to '%s'", src, dst)
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Like the WinRM communicator, skip the macOS Finder metadata.
+		if fi.IsDir() || fi.Name() == ".DS_Store" {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Couldn't read file %s: %v", path, err)
+		}
+		defer f.Close()
+		return c.Upload(dst+`\`+strings.ReplaceAll(filepath.ToSlash(rel), "/", `\`), f, nil)
+	})
+}
+
+// downloadScript outputs the content of a file as base64 encoded chunks.
+const downloadScript = `$ErrorActionPreference = 'Stop'
+$fs = [System.IO.File]::OpenRead(%s)
+try {
+	$buffer = New-Object byte[] %d
+	while (($n = $fs.Read($buffer, 0, $buffer.Length)) -gt 0) {
+		[System.Convert]::ToBase64String($buffer, 0, $n)
+	}
+} finally {
+	$fs.Dispose()
+}`
+
+func (c *Communicator) Download(src string, dst io.Writer) error {
+	log.Printf("Downloading file from '%s'", src)
+	dst = c.limiter.Writer(dst)
+	script := fmt.Sprintf(downloadScript, psPath(src), c.pool.maxInput()/4*3)
+	return c.run(script, nil, func(s string) error {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(b)
+		return err
+	})
+}
+
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	return fmt.Errorf("PSRP doesn't support download dir.")
+}
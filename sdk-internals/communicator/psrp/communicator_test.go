@@ -0,0 +1,301 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeServer is a WS-Management service hosting a PowerShell runspace pool,
+// which fakes the few scripts run by the communicator.
+type fakeServer struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	rpid      guid
+	defrag    *defragmenter
+	pipelines map[guid]*fakePipeline
+	files     map[string][]byte
+	objectID  uint64
+}
+
+type fakePipeline struct {
+	script string
+	input  []string
+}
+
+type fakeRequest struct {
+	Action string `xml:"Header>Action"`
+	Shell  struct {
+		ShellID     string `xml:"ShellId,attr"`
+		CreationXML string `xml:"creationXml"`
+	} `xml:"Body>Shell"`
+	CommandLine struct {
+		CommandID string `xml:"CommandId,attr"`
+		Arguments string `xml:"Arguments"`
+	} `xml:"Body>CommandLine"`
+	Send struct {
+		Data string `xml:",chardata"`
+	} `xml:"Body>Send>Stream"`
+	Receive struct {
+		CommandID string `xml:"CommandId,attr"`
+	} `xml:"Body>Receive>DesiredStream"`
+}
+
+const fakeEnvelope = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+	` xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+	` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"` +
+	` xmlns:x="http://schemas.xmlsoap.org/ws/2004/09/transfer"` +
+	` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">` +
+	`<s:Header></s:Header><s:Body>%s</s:Body></s:Envelope>`
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	s := &fakeServer{
+		t:         t,
+		defrag:    newDefragmenter(),
+		pipelines: map[guid]*fakePipeline{},
+		files:     map[string][]byte{},
+	}
+	return s, httptest.NewServer(s)
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var req fakeRequest
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := xml.Unmarshal(body, &req); err != nil {
+		s.t.Errorf("invalid request: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var response string
+	switch req.Action {
+	case actionCreate:
+		s.rpid = guid(uuid.MustParse(req.Shell.ShellID))
+		s.input(req.Shell.CreationXML)
+		response = `<x:ResourceCreated><a:ReferenceParameters><w:SelectorSet>` +
+			`<w:Selector Name="ShellId">` + s.rpid.String() + `</w:Selector>` +
+			`</w:SelectorSet></a:ReferenceParameters></x:ResourceCreated>`
+	case actionCommand:
+		s.input(req.CommandLine.Arguments)
+		response = `<rsp:CommandResponse><rsp:CommandId>` + req.CommandLine.CommandID +
+			`</rsp:CommandId></rsp:CommandResponse>`
+	case actionSend:
+		s.input(req.Send.Data)
+		response = `<rsp:SendResponse />`
+	case actionReceive:
+		if req.Receive.CommandID == "" {
+			state := s.message(guid{}, msgRunspacePoolState,
+				`<Obj RefId="0"><MS><I32 N="RunspaceState">2</I32></MS></Obj>`)
+			response = `<rsp:ReceiveResponse><rsp:Stream Name="stdout">` + state +
+				`</rsp:Stream></rsp:ReceiveResponse>`
+			break
+		}
+		pid := guid(uuid.MustParse(req.Receive.CommandID))
+		var streams strings.Builder
+		for _, m := range s.run(pid, s.pipelines[pid]) {
+			fmt.Fprintf(&streams, `<rsp:Stream Name="stdout" CommandId="%s">%s</rsp:Stream>`, pid, m)
+		}
+		response = `<rsp:ReceiveResponse>` + streams.String() +
+			`<rsp:CommandState CommandId="` + pid.String() + `" State="` + commandDone + `" />` +
+			`</rsp:ReceiveResponse>`
+	case actionSignal, actionDelete:
+	default:
+		s.t.Errorf("unexpected action %s", req.Action)
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	fmt.Fprintf(w, fakeEnvelope, response)
+}
+
+// input handles the base64 encoded fragments sent by the client.
+func (s *fakeServer) input(data string) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	messages, err := s.defrag.add(b)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for _, m := range messages {
+		if m.rpid != s.rpid {
+			s.t.Fatalf("unexpected runspace pool %s", m.rpid)
+		}
+		obj, err := parseCLIXML(m.data)
+		if m.messageType != msgEndOfPipelineInput && err != nil {
+			s.t.Fatal(err)
+		}
+		switch m.messageType {
+		case msgCreatePipeline:
+			cmd := obj.property("PowerShell").property("Cmds").child("LST", "").Children[0]
+			s.pipelines[m.pid] = &fakePipeline{script: cmd.property("Cmd").String()}
+		case msgPipelineInput:
+			pl := s.pipelines[m.pid]
+			pl.input = append(pl.input, obj.String())
+		}
+	}
+}
+
+// message returns the base64 encoded fragments of a message to the client.
+func (s *fakeServer) message(pid guid, messageType uint32, data string) string {
+	m := &message{destination: destinationClient, messageType: messageType, rpid: s.rpid, pid: pid, data: []byte(data)}
+	s.objectID++
+	return base64.StdEncoding.EncodeToString(fragment(s.objectID, m.marshal(), 1024))
+}
+
+var fakePathRe = regexp.MustCompile(`FromPSPath\("(.*)"\)`)
+
+// run runs the script of a pipeline, returning its messages.
+func (s *fakeServer) run(pid guid, pl *fakePipeline) []string {
+	var messages []string
+	output := func(str string) {
+		messages = append(messages, s.message(pid, msgPipelineOutput, "<S>"+clixmlString(str)+"</S>"))
+	}
+	errorRecord := func(str string) {
+		messages = append(messages, s.message(pid, msgErrorRecord, `<Obj RefId="0"><TN RefId="0">`+
+			`<T>System.Management.Automation.ErrorRecord</T><T>System.Object</T></TN>`+
+			`<ToString>`+clixmlString(str)+`</ToString><MS /></Obj>`))
+	}
+
+	var path string
+	if m := fakePathRe.FindStringSubmatch(pl.script); m != nil {
+		path = m[1]
+	}
+	switch {
+	case strings.HasPrefix(pl.script, "cmd.exe /c --% "):
+		lines := strings.SplitN(strings.TrimPrefix(pl.script, "cmd.exe /c --% "), "\n", 2)
+		marker := strings.TrimSuffix(strings.TrimPrefix(lines[1], `"`), ` $LASTEXITCODE"`)
+		code := 0
+		switch lines[0] {
+		case "echo hello":
+			output("hello")
+		case "fail":
+			errorRecord("oops")
+			code = 3
+		}
+		output(marker + " " + strconv.Itoa(code))
+	case strings.Contains(pl.script, "[System.IO.File]::Create("):
+		var b []byte
+		for _, chunk := range pl.input {
+			data, err := base64.StdEncoding.DecodeString(chunk)
+			if err != nil {
+				s.t.Fatal(err)
+			}
+			b = append(b, data...)
+		}
+		s.files[path] = b
+	case strings.Contains(pl.script, "[System.IO.File]::OpenRead("):
+		b, ok := s.files[path]
+		if !ok {
+			errorRecord("Could not find file '" + path + "'.")
+		}
+		for len(b) > 0 {
+			n := 64 * 1024
+			if n > len(b) {
+				n = len(b)
+			}
+			output(base64.StdEncoding.EncodeToString(b[:n]))
+			b = b[n:]
+		}
+	}
+	return append(messages, s.message(pid, msgPipelineState,
+		`<Obj RefId="0"><MS><I32 N="PipelineState">4</I32></MS></Obj>`))
+}
+
+func newTestCommunicator(t *testing.T) (*fakeServer, *Communicator) {
+	s, ts := newFakeServer(t)
+	t.Cleanup(ts.Close)
+
+	host, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	c, err := New(&Config{
+		Host:     host,
+		Port:     p,
+		Username: "user",
+		Password: "pass",
+		Timeout:  30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error creating communicator: %s", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return s, c
+}
+
+func TestStart(t *testing.T) {
+	_, c := newTestCommunicator(t)
+
+	for _, tc := range []struct {
+		command        string
+		stdout, stderr string
+		exitStatus     int
+	}{
+		{command: "echo hello", stdout: "hello\r\n"},
+		{command: "fail", stderr: "oops\r\n", exitStatus: 3},
+	} {
+		var stdout, stderr bytes.Buffer
+		cmd := &packersdk.RemoteCmd{Command: tc.command, Stdout: &stdout, Stderr: &stderr}
+		if err := c.Start(context.Background(), cmd); err != nil {
+			t.Fatalf("error executing remote command: %s", err)
+		}
+		cmd.Wait()
+
+		if stdout.String() != tc.stdout {
+			t.Errorf("%s: expected stdout %q, got %q", tc.command, tc.stdout, stdout.String())
+		}
+		if stderr.String() != tc.stderr {
+			t.Errorf("%s: expected stderr %q, got %q", tc.command, tc.stderr, stderr.String())
+		}
+		if cmd.ExitStatus() != tc.exitStatus {
+			t.Errorf("%s: expected exit status %d, got %d", tc.command, tc.exitStatus, cmd.ExitStatus())
+		}
+	}
+}
+
+func TestUploadDownload(t *testing.T) {
+	s, c := newTestCommunicator(t)
+
+	content := strings.Repeat("stuff", 100000)
+	if err := c.Upload(`C:\Temp\file.txt`, strings.NewReader(content), nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	if got := string(s.files[`C:\Temp\file.txt`]); got != content {
+		t.Fatalf("uploaded %d bytes, expected %d", len(got), len(content))
+	}
+
+	var b bytes.Buffer
+	if err := c.Download(`C:\Temp\file.txt`, &b); err != nil {
+		t.Fatalf("error downloading file: %s", err)
+	}
+	if b.String() != content {
+		t.Fatalf("downloaded %d bytes, expected %d", b.Len(), len(content))
+	}
+
+	err := c.Download(`C:\Temp\missing.txt`, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "Could not find file") {
+		t.Fatalf("expected an error downloading a missing file, got %v", err)
+	}
+}
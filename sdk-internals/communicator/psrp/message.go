@@ -0,0 +1,191 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The types of the PSRP messages used by the communicator.
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-psrp
+const (
+	msgSessionCapability      = 0x00010002
+	msgInitRunspacePool       = 0x00010004
+	msgRunspacePoolState      = 0x00021005
+	msgCreatePipeline         = 0x00021006
+	msgApplicationPrivateData = 0x00021009
+	msgRunspacePoolHostCall   = 0x00021100
+	msgPipelineInput          = 0x00041002
+	msgEndOfPipelineInput     = 0x00041003
+	msgPipelineOutput         = 0x00041004
+	msgErrorRecord            = 0x00041005
+	msgPipelineState          = 0x00041006
+	msgDebugRecord            = 0x00041007
+	msgVerboseRecord          = 0x00041008
+	msgWarningRecord          = 0x00041009
+	msgProgressRecord         = 0x00041010
+	msgInformationRecord      = 0x00041011
+	msgPipelineHostCall       = 0x00041100
+)
+
+const (
+	destinationClient = 1
+	destinationServer = 2
+)
+
+// utf8BOM precedes the data of the messages.
+const utf8BOM = "\xef\xbb\xbf"
+
+// guid is a runspace pool or pipeline identifier.
+type guid uuid.UUID
+
+func newGUID() guid {
+	return guid(uuid.New())
+}
+
+// String returns the upper-case form of g, used by WS-Management.
+func (g guid) String() string {
+	return strings.ToUpper(uuid.UUID(g).String())
+}
+
+// bytes returns g in the mixed-endian binary form of Windows, where the
+// first three fields are little-endian.
+func (g guid) bytes() []byte {
+	b := append([]byte{}, g[:]...)
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return b
+}
+
+// guidFromBytes returns the guid of its binary form.
+func guidFromBytes(b []byte) guid {
+	var g guid
+	copy(g[:], b)
+	// Swapping the fields again restores them.
+	copy(g[:], g.bytes())
+	return g
+}
+
+// message is a PSRP message.
+type message struct {
+	destination uint32
+	messageType uint32
+	rpid        guid
+	pid         guid
+	// data is the CLIXML of the message, without the byte order mark.
+	data []byte
+}
+
+const messageHeaderLen = 40
+
+func (m *message) marshal() []byte {
+	b := make([]byte, 8, messageHeaderLen+len(utf8BOM)+len(m.data))
+	binary.LittleEndian.PutUint32(b, m.destination)
+	binary.LittleEndian.PutUint32(b[4:], m.messageType)
+	b = append(b, m.rpid.bytes()...)
+	b = append(b, m.pid.bytes()...)
+	b = append(b, utf8BOM...)
+	return append(b, m.data...)
+}
+
+func parseMessage(b []byte) (*message, error) {
+	if len(b) < messageHeaderLen {
+		return nil, errors.New("PSRP message is too short")
+	}
+	return &message{
+		destination: binary.LittleEndian.Uint32(b),
+		messageType: binary.LittleEndian.Uint32(b[4:]),
+		rpid:        guidFromBytes(b[8:24]),
+		pid:         guidFromBytes(b[24:40]),
+		data:        bytes.TrimPrefix(b[messageHeaderLen:], []byte(utf8BOM)),
+	}, nil
+}
+
+const (
+	fragmentStart = 0x1
+	fragmentEnd   = 0x2
+
+	fragmentHeaderLen = 21
+)
+
+// fragment splits the message b, the object objectID, into fragments holding
+// at most maxBlob bytes of it.
+func fragment(objectID uint64, b []byte, maxBlob int) []byte {
+	var out []byte
+	for id := uint64(0); id == 0 || len(b) > 0; id++ {
+		n := len(b)
+		if n > maxBlob {
+			n = maxBlob
+		}
+		var flags byte
+		if id == 0 {
+			flags |= fragmentStart
+		}
+		if n == len(b) {
+			flags |= fragmentEnd
+		}
+
+		header := make([]byte, fragmentHeaderLen)
+		binary.BigEndian.PutUint64(header, objectID)
+		binary.BigEndian.PutUint64(header[8:], id)
+		header[16] = flags
+		binary.BigEndian.PutUint32(header[17:], uint32(n))
+		out = append(out, header...)
+		out = append(out, b[:n]...)
+		b = b[n:]
+	}
+	return out
+}
+
+// defragmenter reassembles the messages of the fragments received.
+type defragmenter struct {
+	objects map[uint64][]byte
+}
+
+func newDefragmenter() *defragmenter {
+	return &defragmenter{objects: make(map[uint64][]byte)}
+}
+
+// add reads the fragments of b, returning the messages they complete.
+func (d *defragmenter) add(b []byte) ([]*message, error) {
+	var messages []*message
+	for len(b) > 0 {
+		if len(b) < fragmentHeaderLen {
+			return nil, errors.New("PSRP fragment is too short")
+		}
+		objectID := binary.BigEndian.Uint64(b)
+		flags := b[16]
+		n := int(binary.BigEndian.Uint32(b[17:]))
+		if fragmentHeaderLen+n > len(b) {
+			return nil, errors.New("PSRP fragment is truncated")
+		}
+		blob := b[fragmentHeaderLen : fragmentHeaderLen+n]
+		b = b[fragmentHeaderLen+n:]
+
+		if flags&fragmentStart != 0 {
+			d.objects[objectID] = nil
+		} else if _, ok := d.objects[objectID]; !ok {
+			return nil, fmt.Errorf("PSRP fragment of unknown object %d", objectID)
+		}
+		d.objects[objectID] = append(d.objects[objectID], blob...)
+		if flags&fragmentEnd == 0 {
+			continue
+		}
+
+		m, err := parseMessage(d.objects[objectID])
+		delete(d.objects, objectID)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGUID_bytes(t *testing.T) {
+	g := guid(uuid.MustParse("00112233-4455-6677-8899-aabbccddeeff"))
+	expected := "33221100554477668899aabbccddeeff"
+	if got := hex.EncodeToString(g.bytes()); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if guidFromBytes(g.bytes()) != g {
+		t.Fatalf("guid doesn't round trip")
+	}
+	if g.String() != "00112233-4455-6677-8899-AABBCCDDEEFF" {
+		t.Fatalf("unexpected string %s", g)
+	}
+}
+
+func TestFragments(t *testing.T) {
+	rpid, pid := newGUID(), newGUID()
+	m1 := &message{destination: destinationServer, messageType: msgPipelineInput, rpid: rpid, pid: pid,
+		data: bytes.Repeat([]byte("a"), 100)}
+	m2 := &message{destination: destinationServer, messageType: msgEndOfPipelineInput, rpid: rpid, pid: pid}
+
+	b1 := fragment(1, m1.marshal(), 30)
+	b2 := fragment(2, m2.marshal(), 30)
+	if len(b1) != 5*fragmentHeaderLen+messageHeaderLen+len(utf8BOM)+100 {
+		t.Fatalf("unexpected fragments length %d", len(b1))
+	}
+
+	d := newDefragmenter()
+	// The fragments may be split across the receive responses.
+	messages, err := d.add(b1[:3*(fragmentHeaderLen+30)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("expected no complete message, got %d", len(messages))
+	}
+	messages, err = d.add(append(b1[3*(fragmentHeaderLen+30):], b2...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	for i, m := range []*message{m1, m2} {
+		got := messages[i]
+		if got.destination != m.destination || got.messageType != m.messageType ||
+			got.rpid != m.rpid || got.pid != m.pid || !bytes.Equal(got.data, m.data) {
+			t.Fatalf("message %d: expected %#v, got %#v", i, m, got)
+		}
+	}
+
+	if _, err := d.add(b1[fragmentHeaderLen+30:]); err == nil {
+		t.Fatal("expected an error for a fragment of an unknown object")
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+)
+
+// The states of runspace pools and pipelines.
+const (
+	runspacePoolOpened = 2
+	runspacePoolClosed = 3
+	runspacePoolBroken = 5
+
+	pipelineStopped   = 3
+	pipelineCompleted = 4
+	pipelineFailed    = 5
+)
+
+// runspacePool is a pool of PowerShell runspaces, in a WS-Management shell,
+// running the pipelines.
+type runspacePool struct {
+	wsman   *wsman
+	id      guid
+	shellID string
+	// maxBlob is the size of the fragments sent, so that they fit in the
+	// WS-Management envelopes.
+	maxBlob int
+
+	objectID uint64
+}
+
+// openRunspacePool creates the shell and waits for its runspace pool to
+// open.
+func openRunspacePool(w *wsman) (*runspacePool, error) {
+	// The fragments are base64 encoded in the envelopes, which also hold a
+	// header of about 1.5KB.
+	maxBlob := (w.params.EnvelopeSize-4096)/4*3 - fragmentHeaderLen
+	p := &runspacePool{wsman: w, id: newGUID(), maxBlob: maxBlob}
+
+	creation := p.fragments(&message{messageType: msgSessionCapability, data: sessionCapability()})
+	creation = append(creation, p.fragments(&message{messageType: msgInitRunspacePool, data: initRunspacePool()})...)
+	shellID, err := w.create(p.id, creation)
+	if err != nil {
+		return nil, err
+	}
+	p.shellID = shellID
+
+	defrag := newDefragmenter()
+	for {
+		fragments, _, err := w.receive(shellID, nil)
+		if err == errOperationTimeout {
+			continue
+		}
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		messages, err := defrag.add(fragments)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		for _, m := range messages {
+			if m.messageType != msgRunspacePoolState {
+				continue
+			}
+			state, err := parseState(m, "RunspaceState")
+			if err != nil {
+				p.close()
+				return nil, err
+			}
+			switch state {
+			case runspacePoolOpened:
+				return p, nil
+			case runspacePoolClosed, runspacePoolBroken:
+				p.close()
+				return nil, fmt.Errorf("PowerShell runspace pool couldn't open: state %d", state)
+			}
+		}
+	}
+}
+
+func (p *runspacePool) close() error {
+	return p.wsman.delete(p.shellID)
+}
+
+// fragments returns the fragments of m, a message of the pool or of its
+// pipeline pid.
+func (p *runspacePool) fragments(m *message) []byte {
+	m.destination = destinationServer
+	m.rpid = p.id
+	return fragment(atomic.AddUint64(&p.objectID, 1), m.marshal(), p.maxBlob)
+}
+
+// maxInput returns the length of the longest string sent as a pipeline input
+// object in a single fragment.
+func (p *runspacePool) maxInput() int {
+	return p.maxBlob - messageHeaderLen - len(utf8BOM) - len(pipelineInput(""))
+}
+
+// pipeline is a running pipeline of the pool.
+type pipeline struct {
+	pool *runspacePool
+	id   guid
+}
+
+// newPipeline starts script. Unless noInput is set, the input objects of
+// the script must be sent, followed by endInput.
+func (p *runspacePool) newPipeline(script string, noInput bool) (*pipeline, error) {
+	pl := &pipeline{pool: p, id: newGUID()}
+	fragments := pl.fragments(msgCreatePipeline, createPipeline(script, noInput))
+
+	// The fragments that don't fit in the command are sent as input.
+	n := len(fragments)
+	if n > p.maxBlob+fragmentHeaderLen {
+		n = p.maxBlob + fragmentHeaderLen
+	}
+	if err := p.wsman.command(p.shellID, pl.id, fragments[:n]); err != nil {
+		return nil, err
+	}
+	if n < len(fragments) {
+		if err := p.wsman.send(p.shellID, pl.id, fragments[n:]); err != nil {
+			return nil, err
+		}
+	}
+	return pl, nil
+}
+
+func (pl *pipeline) fragments(messageType uint32, data []byte) []byte {
+	return pl.pool.fragments(&message{messageType: messageType, pid: pl.id, data: data})
+}
+
+// input sends the string s as an input object of the pipeline.
+func (pl *pipeline) input(s string) error {
+	return pl.pool.wsman.send(pl.pool.shellID, pl.id, pl.fragments(msgPipelineInput, pipelineInput(s)))
+}
+
+// endInput tells the pipeline that all its input objects were sent.
+func (pl *pipeline) endInput() error {
+	return pl.pool.wsman.send(pl.pool.shellID, pl.id, pl.fragments(msgEndOfPipelineInput, nil))
+}
+
+// wait receives the messages of the pipeline until it is done, passing them
+// to handle. It returns the error of the pipeline if it failed. The pipeline
+// is terminated when ctx is cancelled.
+func (pl *pipeline) wait(ctx context.Context, handle func(*message)) error {
+	w := pl.pool.wsman
+	defer w.signal(pl.pool.shellID, pl.id)
+
+	defrag := newDefragmenter()
+	var pipelineErr error
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		fragments, done, err := w.receive(pl.pool.shellID, &pl.id)
+		if err == errOperationTimeout {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		messages, err := defrag.add(fragments)
+		if err != nil {
+			return err
+		}
+		for _, m := range messages {
+			switch m.messageType {
+			case msgPipelineState:
+				state, err := parseState(m, "PipelineState")
+				if err != nil {
+					return err
+				}
+				if state == pipelineFailed || state == pipelineStopped {
+					pipelineErr = stateError(m, state)
+				}
+			case msgPipelineHostCall, msgRunspacePoolHostCall:
+				log.Printf("[DEBUG] Ignoring PowerShell host call of a pipeline")
+			default:
+				handle(m)
+			}
+		}
+		if done {
+			return pipelineErr
+		}
+	}
+}
+
+// parseState returns the state property of a state message.
+func parseState(m *message, property string) (int, error) {
+	obj, err := parseCLIXML(m.data)
+	if err != nil {
+		return 0, err
+	}
+	state := obj.property(property)
+	if state == nil {
+		return 0, fmt.Errorf("PSRP message is missing the %s", property)
+	}
+	return state.int()
+}
+
+// stateError returns the error of a state message.
+func stateError(m *message, state int) error {
+	obj, err := parseCLIXML(m.data)
+	if err == nil {
+		if record := obj.property("ExceptionAsErrorRecord"); record != nil {
+			return errors.New(record.String())
+		}
+	}
+	return fmt.Errorf("PowerShell pipeline ended in state %d", state)
+}
+
+// writeRecord writes the string form of the object of m, followed by a new
+// line, to w.
+func writeRecord(w io.Writer, prefix string, m *message) {
+	if w == nil {
+		return
+	}
+	obj, err := parseCLIXML(m.data)
+	if err != nil {
+		log.Printf("[WARN] %s", err)
+		return
+	}
+	s := obj.String()
+	if m.messageType == msgInformationRecord {
+		if data := obj.property("MessageData"); data != nil {
+			s = data.String()
+		}
+	}
+	io.WriteString(w, prefix+s+"\r\n")
+}
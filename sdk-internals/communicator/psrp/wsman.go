@@ -0,0 +1,198 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package psrp
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/masterzen/simplexml/dom"
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// resourceURI is the WS-Management resource of the PowerShell remoting
+// shells, the default session configuration of the host.
+const resourceURI = "http://schemas.microsoft.com/powershell/Microsoft.PowerShell"
+
+const (
+	actionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	actionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	actionCommand = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	actionSend    = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Send"
+	actionReceive = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	actionSignal  = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+
+	signalTerminate = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/signal/terminate"
+	commandDone     = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done"
+)
+
+var domNSPowerShell = dom.Namespace{Prefix: "ps", Uri: "http://schemas.microsoft.com/powershell"}
+
+// errOperationTimeout is returned by receive when no output was available
+// within the operation timeout.
+var errOperationTimeout = errors.New("WS-Management operation timeout")
+
+// wsman sends the WS-Management requests of the PowerShell shells, through
+// the transport of a WinRM client.
+type wsman struct {
+	client    *winrm.Client
+	transport winrm.Transporter
+	url       string
+	params    *winrm.Parameters
+}
+
+func (w *wsman) message(action string, shellID string) (*soap.SoapMessage, *soap.SoapHeader) {
+	message := soap.NewMessage()
+	header := message.Header().
+		To(w.url).
+		ReplyTo("http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous").
+		MaxEnvelopeSize(w.params.EnvelopeSize).
+		Id("uuid:" + strings.ToUpper(uuid.New().String())).
+		Locale(w.params.Locale).
+		Timeout(w.params.Timeout).
+		Action(action).
+		ResourceURI(resourceURI)
+	if shellID != "" {
+		header.ShellId(shellID)
+	}
+	return message, header
+}
+
+func (w *wsman) post(message *soap.SoapMessage, response interface{}) error {
+	body, err := w.transport.Post(w.client, message)
+	if err != nil {
+		// The fault returned when no output is available, WSManFault
+		// 2150858793, tells about the OperationTimeout.
+		if strings.Contains(err.Error(), "2150858793") || strings.Contains(err.Error(), "OperationTimeout") {
+			return errOperationTimeout
+		}
+		return err
+	}
+	if response == nil {
+		return nil
+	}
+	return xml.Unmarshal([]byte(body), response)
+}
+
+// create creates the shell of the runspace pool rpid, with the messages
+// creating the pool, returning the shell ID.
+func (w *wsman) create(rpid guid, creationXML []byte) (string, error) {
+	message, header := w.message(actionCreate, "")
+	header.AddOption(soap.NewHeaderOption("protocolversion", protocolVersion)).Build()
+
+	shell := message.CreateBodyElement("Shell", soap.DOM_NS_WIN_SHELL)
+	shell.SetAttr("ShellId", rpid.String())
+	message.CreateElement(shell, "InputStreams", soap.DOM_NS_WIN_SHELL).SetContent("stdin pr")
+	message.CreateElement(shell, "OutputStreams", soap.DOM_NS_WIN_SHELL).SetContent("stdout")
+	message.CreateElement(shell, "creationXml", domNSPowerShell).
+		SetContent(base64.StdEncoding.EncodeToString(creationXML))
+
+	var response struct {
+		Selectors []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Body>ResourceCreated>ReferenceParameters>SelectorSet>Selector"`
+		ShellID string `xml:"Body>Shell>ShellId"`
+	}
+	if err := w.post(message, &response); err != nil {
+		return "", err
+	}
+	for _, s := range response.Selectors {
+		if s.Name == "ShellId" {
+			return s.Value, nil
+		}
+	}
+	if response.ShellID != "" {
+		return response.ShellID, nil
+	}
+	return "", errors.New("the WS-Management service didn't return the shell ID")
+}
+
+// command creates the pipeline pid in the shell, with the first fragments
+// of its creation.
+func (w *wsman) command(shellID string, pid guid, fragments []byte) error {
+	message, header := w.message(actionCommand, shellID)
+	header.Build()
+
+	cmd := message.CreateBodyElement("CommandLine", soap.DOM_NS_WIN_SHELL)
+	cmd.SetAttr("CommandId", pid.String())
+	message.CreateElement(cmd, "Command", soap.DOM_NS_WIN_SHELL)
+	message.CreateElement(cmd, "Arguments", soap.DOM_NS_WIN_SHELL).
+		SetContent(base64.StdEncoding.EncodeToString(fragments))
+	return w.post(message, nil)
+}
+
+// send sends fragments to the pipeline pid.
+func (w *wsman) send(shellID string, pid guid, fragments []byte) error {
+	message, header := w.message(actionSend, shellID)
+	header.Build()
+
+	send := message.CreateBodyElement("Send", soap.DOM_NS_WIN_SHELL)
+	stream := message.CreateElement(send, "Stream", soap.DOM_NS_WIN_SHELL)
+	stream.SetAttr("Name", "stdin")
+	stream.SetAttr("CommandId", pid.String())
+	stream.SetContent(base64.StdEncoding.EncodeToString(fragments))
+	return w.post(message, nil)
+}
+
+// receive returns the fragments sent by the pipeline pid, or by the
+// runspace pool if pid is nil, and whether the pipeline is done.
+func (w *wsman) receive(shellID string, pid *guid) ([]byte, bool, error) {
+	message, header := w.message(actionReceive, shellID)
+	header.AddOption(soap.NewHeaderOption("WSMAN_CMDSHELL_OPTION_KEEPALIVE", "TRUE")).Build()
+
+	receive := message.CreateBodyElement("Receive", soap.DOM_NS_WIN_SHELL)
+	stream := message.CreateElement(receive, "DesiredStream", soap.DOM_NS_WIN_SHELL)
+	if pid != nil {
+		stream.SetAttr("CommandId", pid.String())
+	}
+	stream.SetContent("stdout")
+
+	var response struct {
+		Streams []struct {
+			Name string `xml:"Name,attr"`
+			Data string `xml:",chardata"`
+		} `xml:"Body>ReceiveResponse>Stream"`
+		CommandState struct {
+			State string `xml:"State,attr"`
+		} `xml:"Body>ReceiveResponse>CommandState"`
+	}
+	if err := w.post(message, &response); err != nil {
+		return nil, false, err
+	}
+
+	var fragments []byte
+	for _, s := range response.Streams {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s.Data))
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid stream data: %w", err)
+		}
+		fragments = append(fragments, b...)
+	}
+	return fragments, response.CommandState.State == commandDone, nil
+}
+
+// signal terminates the pipeline pid.
+func (w *wsman) signal(shellID string, pid guid) error {
+	message, header := w.message(actionSignal, shellID)
+	header.Build()
+
+	signal := message.CreateBodyElement("Signal", soap.DOM_NS_WIN_SHELL)
+	signal.SetAttr("CommandId", pid.String())
+	message.CreateElement(signal, "Code", soap.DOM_NS_WIN_SHELL).SetContent(signalTerminate)
+	return w.post(message, nil)
+}
+
+// delete deletes the shell, closing its runspace pool.
+func (w *wsman) delete(shellID string) error {
+	message, header := w.message(actionDelete, shellID)
+	header.Build()
+	message.NewBody()
+	return w.post(message, nil)
+}
@@ -0,0 +1,254 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+)
+
+// StartBatch runs the commands in order in a single session. The commands are
+// joined into one POSIX shell script, each command running in a subshell
+// followed by a marker line on both output streams. The markers, which
+// include a random token, split the output between the commands and carry
+// their exit status.
+func (c *comm) StartBatch(ctx context.Context, cmds []*packersdk.RemoteCmd) error {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	marker := "__packer_batch_" + hex.EncodeToString(token)
+
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+
+	b := newBatch(cmds)
+	stdout := &batchDemux{marker: marker, writers: b.writers(true), end: b.stdoutEnd}
+	stderr := &batchDemux{marker: marker, writers: b.writers(false), end: b.stderrEnd}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	var script strings.Builder
+	for i, cmd := range cmds {
+		fmt.Fprintf(&script, "(\n%s\n) </dev/null\n", cmd.Command)
+		fmt.Fprintf(&script, "printf '\\n%s %d %%d\\n' \"$?\"\n", marker, i)
+		fmt.Fprintf(&script, "printf '\\n%s %d\\n' >&2\n", marker, i)
+	}
+
+	log.Printf("[DEBUG] starting batch of %d remote commands", len(cmds))
+	if err := session.Start(script.String()); err != nil {
+		session.Close()
+		return err
+	}
+
+	go func() {
+		defer session.Close()
+
+		err := session.Wait()
+		stdout.Close()
+		stderr.Close()
+		if err != nil {
+			if _, ok := err.(*ssh.ExitError); !ok {
+				log.Printf("[ERROR] Remote command batch interrupted: %s", c.connectionLost(err))
+			}
+		}
+		b.finish()
+	}()
+	return nil
+}
+
+// batch tracks the commands of a batch, marking each one as exited once both
+// of its output streams are complete.
+type batch struct {
+	cmds []*packersdk.RemoteCmd
+
+	l        sync.Mutex
+	statuses []int
+	stderrs  int
+	exited   int
+}
+
+func newBatch(cmds []*packersdk.RemoteCmd) *batch {
+	return &batch{cmds: cmds}
+}
+
+func (b *batch) writers(stdout bool) []io.Writer {
+	writers := make([]io.Writer, len(b.cmds))
+	for i, cmd := range b.cmds {
+		if stdout {
+			writers[i] = cmd.Stdout
+		} else {
+			writers[i] = cmd.Stderr
+		}
+	}
+	return writers
+}
+
+func (b *batch) stdoutEnd(status int) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.statuses = append(b.statuses, status)
+	b.update()
+}
+
+func (b *batch) stderrEnd(int) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.stderrs++
+	b.update()
+}
+
+func (b *batch) update() {
+	for b.exited < len(b.statuses) && b.exited < b.stderrs {
+		cmd := b.cmds[b.exited]
+		status := b.statuses[b.exited]
+		if status != 0 {
+			log.Printf("[ERROR] Remote command exited with '%d': %s", status, cmd.Command)
+		}
+		cmd.SetExited(status)
+		b.exited++
+	}
+}
+
+// finish marks the commands that did not complete, because the session ended
+// early, as disconnected.
+func (b *batch) finish() {
+	b.l.Lock()
+	defer b.l.Unlock()
+	for i := b.exited; i < len(b.cmds); i++ {
+		if i < len(b.statuses) {
+			// Only its standard error was cut short.
+			b.cmds[i].SetExited(b.statuses[i])
+			continue
+		}
+		b.cmds[i].SetExited(packersdk.CmdDisconnect)
+	}
+	b.exited = len(b.cmds)
+}
+
+// batchDemux splits an output stream of a batch between the writers of its
+// commands. Marker lines end the output of a command, and are preceded by a
+// newline that is not part of the output.
+type batchDemux struct {
+	marker  string
+	writers []io.Writer
+	end     func(status int)
+
+	cur int
+	// line holds the start of a line that may be a marker.
+	line []byte
+	// midLine is set once the current line is known not to be a marker.
+	midLine bool
+	// held is set when a newline, that may precede a marker, is pending.
+	held bool
+}
+
+func (d *batchDemux) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			d.partial(p)
+			break
+		}
+		d.partial(p[:i])
+		d.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Close writes what remains of an incomplete line.
+func (d *batchDemux) Close() error {
+	if len(d.line) > 0 {
+		d.flushHeld()
+		d.write(d.line)
+		d.line = nil
+	}
+	return nil
+}
+
+func (d *batchDemux) partial(b []byte) {
+	if d.midLine {
+		d.write(b)
+		return
+	}
+	d.line = append(d.line, b...)
+	if !d.mayBeMarker(d.line) {
+		d.flushHeld()
+		d.write(d.line)
+		d.line = d.line[:0]
+		d.midLine = true
+	}
+}
+
+func (d *batchDemux) endLine() {
+	if d.midLine {
+		d.midLine = false
+		d.held = true
+		return
+	}
+
+	line := string(d.line)
+	d.line = d.line[:0]
+	if status, ok := d.parseMarker(line); ok {
+		d.held = false
+		d.end(status)
+		d.cur++
+		return
+	}
+	d.flushHeld()
+	d.write([]byte(line))
+	d.held = true
+}
+
+func (d *batchDemux) mayBeMarker(line []byte) bool {
+	if len(line) <= len(d.marker) {
+		return strings.HasPrefix(d.marker, string(line))
+	}
+	return strings.HasPrefix(string(line), d.marker+" ")
+}
+
+// parseMarker parses the marker line of the current command, returning the
+// exit status it carries, if any.
+func (d *batchDemux) parseMarker(line string) (int, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != d.marker || fields[1] != strconv.Itoa(d.cur) {
+		return 0, false
+	}
+	if len(fields) == 3 {
+		status, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, false
+		}
+		return status, true
+	}
+	return 0, len(fields) == 2
+}
+
+func (d *batchDemux) flushHeld() {
+	if d.held {
+		d.held = false
+		d.write([]byte{'\n'})
+	}
+}
+
+func (d *batchDemux) write(b []byte) {
+	if d.cur < len(d.writers) && d.writers[d.cur] != nil {
+		d.writers[d.cur].Write(b)
+	}
+}
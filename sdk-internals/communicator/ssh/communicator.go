@@ -17,28 +17,57 @@ import (
 	"log"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	getter "github.com/hashicorp/go-getter/v2"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/pathmatch"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/throttle"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
 
-// ErrHandshakeTimeout is returned from New() whenever we're unable to establish
-// an ssh connection within a certain timeframe. By default the handshake time-
-// out period is 1 minute. You can change it with Config.HandshakeTimeout.
+// ErrHandshakeTimeout matches, with errors.Is, the errors returned from New()
+// whenever we're unable to establish an ssh connection within a certain
+// timeframe: HandshakeTimeoutError and BannerDelayError. By default the
+// handshake timeout period is 1 minute. You can change it with
+// Config.HandshakeTimeout.
 var ErrHandshakeTimeout = fmt.Errorf("Timeout during SSH handshake")
 
+var _ packersdk.SessionCommunicator = new(comm)
+
+// comm keeps a single authenticated connection for its whole lifetime, and
+// opens a channel on it for every command and file transfer. The connection
+// is transparently re-established when opening a channel fails.
 type comm struct {
-	client  *ssh.Client
 	config  *Config
-	conn    net.Conn
 	address string
+
+	// limiter limits the rate of file transfers, when set.
+	limiter *throttle.Limiter
+
+	// mu guards the connection, which is replaced on reconnect.
+	mu      sync.Mutex
+	client  *ssh.Client
+	conn    net.Conn
+	monitor *monitoredConn
+
+	// sftpMu guards the SFTP client shared by transfers, along with the
+	// SSH client it was opened on.
+	sftpMu     sync.Mutex
+	sftp       *sftp.Client
+	sftpClient *ssh.Client
+
+	rsyncOnce sync.Once
+	rsyncErr  error
 }
 
 // TunnelDirection is the supported tunnel directions
@@ -69,9 +98,18 @@ type Config struct {
 	// case an error occurs.
 	Connection func() (net.Conn, error)
 
-	// Pty, if true, will request a pty from the remote end.
+	// Pty, if true, will request a pty from the remote end. The Pty field of
+	// a RemoteCmd overrides it for the command.
 	Pty bool
 
+	// PtyTerm is the terminal type of the pty, "xterm" when empty.
+	PtyTerm string
+
+	// PtyWidth and PtyHeight are the size of the pty in characters, 80x40
+	// when zero.
+	PtyWidth  int
+	PtyHeight int
+
 	// DisableAgentForwarding, if true, will not forward the SSH agent.
 	DisableAgentForwarding bool
 
@@ -82,13 +120,54 @@ type Config struct {
 	// UseSftp, if true, sftp will be used instead of scp for file transfers
 	UseSftp bool
 
-	// KeepAliveInterval sets how often we send a channel request to the
+	// SftpMaxPacket sets the size of the data packets of sftp transfers. Zero
+	// uses the default of 32KiB, the largest size every server must accept.
+	SftpMaxPacket int
+
+	// SftpConcurrency sets how many sftp requests can be in flight for a
+	// single file. Values above 1 enable concurrent writes.
+	SftpConcurrency int
+
+	// SftpResume, if true, resumes sftp uploads of files that were
+	// partially uploaded before, instead of uploading them again.
+	SftpResume bool
+
+	// UseRsync, if true, rsync will be used for directory transfers when it
+	// is available on both ends, falling back to scp or sftp otherwise.
+	UseRsync bool
+
+	// RsyncDelete, if true, deletes files from the destination directory
+	// that are not in the source directory when using rsync.
+	RsyncDelete bool
+
+	// SymlinkPolicy controls how UploadDir handles symbolic links. It
+	// defaults to SymlinkFollow.
+	SymlinkPolicy SymlinkPolicy
+
+	// KeepAliveInterval sets how often we send a keepalive request to the
 	// server. A value < 0 disables.
 	KeepAliveInterval time.Duration
 
+	// KeepAliveCountMax sets how many keepalives can go unanswered before
+	// the connection is considered dead and closed. Zero never closes it.
+	KeepAliveCountMax int
+
+	// InactivityTimeout closes the connection when nothing was received from
+	// the server for that long. Keepalive replies count as activity. Zero
+	// disables it.
+	InactivityTimeout time.Duration
+
 	// Timeout is how long to wait for a read or write to succeed.
 	Timeout time.Duration
 
+	// ProgressTracker, if set, is given the progress of Upload and Download
+	// transfers. packersdk.Ui implements it to display a progress bar.
+	ProgressTracker getter.ProgressTracker
+
+	// TransferRateLimit limits file transfers to that many bytes per second,
+	// shared by all the transfers. Zero doesn't limit them.
+	TransferRateLimit int64
+
 	Tunnels []TunnelSpec
 }
 
@@ -99,9 +178,13 @@ func New(address string, config *Config) (result *comm, err error) {
 	result = &comm{
 		config:  config,
 		address: address,
+		limiter: throttle.New(config.TransferRateLimit),
 	}
 
-	if err = result.reconnect(); err != nil {
+	result.mu.Lock()
+	err = result.reconnect()
+	result.mu.Unlock()
+	if err != nil {
 		result = nil
 		return
 	}
@@ -120,7 +203,11 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 	session.Stdout = cmd.Stdout
 	session.Stderr = cmd.Stderr
 
-	if c.config.Pty {
+	pty := c.config.Pty
+	if cmd.Pty != nil {
+		pty = *cmd.Pty
+	}
+	if pty {
 		// Request a PTY
 		termModes := ssh.TerminalModes{
 			ssh.ECHO:          0,     // do not echo
@@ -128,7 +215,17 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 			ssh.TTY_OP_OSPEED: 14400, // output speed = 14.4kbaud
 		}
 
-		if err = session.RequestPty("xterm", 40, 80, termModes); err != nil {
+		term, width, height := c.config.PtyTerm, c.config.PtyWidth, c.config.PtyHeight
+		if term == "" {
+			term = "xterm"
+		}
+		if width == 0 {
+			width = 80
+		}
+		if height == 0 {
+			height = 40
+		}
+		if err = session.RequestPty(term, height, width, termModes); err != nil {
 			return
 		}
 	}
@@ -139,20 +236,6 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 		return
 	}
 
-	go func() {
-		if c.config.KeepAliveInterval <= 0 {
-			return
-		}
-		c := time.NewTicker(c.config.KeepAliveInterval)
-		defer c.Stop()
-		for range c.C {
-			_, err := session.SendRequest("keepalive@packer.io", true, nil)
-			if err != nil {
-				return
-			}
-		}
-	}()
-
 	// Start a goroutine to wait for the session to end and set the
 	// exit boolean and status.
 	go func() {
@@ -161,13 +244,16 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 		err := session.Wait()
 		exitStatus := 0
 		if err != nil {
-			switch err := err.(type) {
+			switch err := c.connectionLost(err).(type) {
 			case *ssh.ExitError:
 				exitStatus = err.ExitStatus()
 				log.Printf("[ERROR] Remote command exited with '%d': %s", exitStatus, cmd.Command)
 			case *ssh.ExitMissingError:
 				log.Printf("[ERROR] Remote command exited without exit status or exit signal.")
 				exitStatus = packersdk.CmdDisconnect
+			case *ConnectionLostError:
+				log.Printf("[ERROR] Remote command interrupted: %s", err)
+				exitStatus = packersdk.CmdDisconnect
 			default:
 				log.Printf("[ERROR] Error occurred waiting for ssh session: %s", err.Error())
 			}
@@ -179,124 +265,146 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 
 func (c *comm) Upload(path string, input io.Reader, fi *os.FileInfo) error {
 	if c.config.UseSftp {
-		return c.sftpUploadSession(path, input, fi)
+		return c.connectionLost(c.sftpUploadSession(path, input, fi))
 	} else {
-		return c.scpUploadSession(path, input, fi)
+		return c.connectionLost(c.scpUploadSession(path, input, fi))
 	}
 }
 
 func (c *comm) UploadDir(dst string, src string, excl []string) error {
 	log.Printf("[DEBUG] Upload dir '%s' to '%s'", src, dst)
+	if c.config.UseRsync {
+		err := c.rsyncAvailable()
+		if err == nil {
+			err = c.rsyncUploadDir(dst, src, excl)
+			if !errors.Is(err, errRsyncUnavailable) {
+				return c.connectionLost(err)
+			}
+		}
+		log.Printf("[WARN] %s, falling back to the default transfer method", err)
+	}
 	if c.config.UseSftp {
-		return c.sftpUploadDirSession(dst, src, excl)
+		return c.connectionLost(c.sftpUploadDirSession(dst, src, excl))
 	} else {
-		return c.scpUploadDirSession(dst, src, excl)
+		return c.connectionLost(c.scpUploadDirSession(dst, src, excl))
 	}
 }
 
 func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 	log.Printf("[DEBUG] Download dir '%s' to '%s'", src, dst)
-	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
-		dirStack := []string{dst}
-		for {
-			fmt.Fprint(w, "\x00")
-
-			// read file info
-			fi, err := stdoutR.ReadString('\n')
-			if err != nil {
-				return err
-			}
-
-			if len(fi) == 0 {
-				return fmt.Errorf("empty response from server")
+	if c.config.UseRsync {
+		err := c.rsyncAvailable()
+		if err == nil {
+			err = c.rsyncDownloadDir(src, dst, excl)
+			if !errors.Is(err, errRsyncUnavailable) {
+				return c.connectionLost(err)
 			}
+		}
+		log.Printf("[WARN] %s, falling back to the default transfer method", err)
+	}
+	if c.config.UseSftp {
+		return c.connectionLost(c.sftpDownloadDirSession(src, dst, excl))
+	}
+	return c.connectionLost(c.scpDownloadDirSession(src, dst, excl))
+}
 
-			switch fi[0] {
-			case '\x01', '\x02':
-				return fmt.Errorf("%s", fi[1:])
-			case 'C', 'D':
-				break
-			case 'E':
-				dirStack = dirStack[:len(dirStack)-1]
-				if len(dirStack) == 0 {
-					fmt.Fprint(w, "\x00")
-					return nil
-				}
-				continue
-			default:
-				return fmt.Errorf("unexpected server response (%x)", fi[0])
-			}
+func (c *comm) Download(path string, output io.Writer) error {
+	if c.config.UseSftp {
+		return c.connectionLost(c.sftpDownloadSession(path, output))
+	}
+	return c.connectionLost(c.scpDownloadSession(path, output))
+}
 
-			var mode int64
-			var size int64
-			var name string
-			log.Printf("[DEBUG] Download dir str:%s", fi)
-			n, err := fmt.Sscanf(fi[1:], "%o %d %s", &mode, &size, &name)
-			if err != nil || n != 3 {
-				return fmt.Errorf("can't parse server response (%s)", fi)
-			}
-			if size < 0 {
-				return fmt.Errorf("negative file size")
-			}
+// NewSession returns a session on the connection of c, for the provisioners
+// run concurrently. Every command and transfer already opens a channel of its
+// own on the shared connection, so the session only differs from c in that
+// closing it leaves the connection open.
+func (c *comm) NewSession() (packersdk.Communicator, error) {
+	return &session{c}, nil
+}
 
-			log.Printf("[DEBUG] Download dir mode:%0o size:%d name:%s", mode, size, name)
+// session is a communicator sharing the connection of comm.
+type session struct {
+	*comm
+}
 
-			dst = filepath.Join(dirStack...)
-			switch fi[0] {
-			case 'D':
-				err = os.MkdirAll(filepath.Join(dst, name), os.FileMode(mode))
-				if err != nil {
-					return err
-				}
-				dirStack = append(dirStack, name)
-				continue
-			case 'C':
-				fmt.Fprint(w, "\x00")
-				err = scpDownloadFile(filepath.Join(dst, name), stdoutR, size, os.FileMode(mode))
-				if err != nil {
-					return err
-				}
-			}
+// Close does nothing, the connection being closed with its comm.
+func (s *session) Close() error {
+	return nil
+}
 
-			if err := checkSCPStatus(stdoutR); err != nil {
-				return err
-			}
-		}
+// Close closes the connection.
+func (c *comm) Close() error {
+	c.sftpMu.Lock()
+	if c.sftp != nil {
+		c.sftp.Close()
+		c.sftp = nil
 	}
-	return c.scpSession("scp -vrf "+src, scpFunc)
-}
+	c.sftpMu.Unlock()
 
-func (c *comm) Download(path string, output io.Writer) error {
-	if c.config.UseSftp {
-		return c.sftpDownloadSession(path, output)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.client != nil {
+		err = c.client.Close()
+		c.client = nil
+	}
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
 	}
-	return c.scpDownloadSession(path, output)
+	return err
 }
 
-func (c *comm) newSession() (session *ssh.Session, err error) {
+func (c *comm) newSession() (*ssh.Session, error) {
 	log.Println("[DEBUG] Opening new ssh session")
-	if c.client == nil {
-		err = errors.New("client not available")
-	} else {
-		session, err = c.client.NewSession()
+	client := c.currentClient()
+
+	var session *ssh.Session
+	err := errors.New("client not available")
+	if client != nil {
+		session, err = client.NewSession()
+	}
+	if err == nil {
+		return session, nil
 	}
 
+	log.Printf("[ERROR] ssh session open error: '%s', attempting reconnect", err)
+	client, err = c.reconnectFrom(client)
 	if err != nil {
-		log.Printf("[ERROR] ssh session open error: '%s', attempting reconnect", err)
-		if err := c.reconnect(); err != nil {
-			return nil, err
-		}
-
-		if c.client == nil {
-			return nil, errors.New("client not available")
-		} else {
-			return c.client.NewSession()
-		}
+		return nil, err
 	}
+	return client.NewSession()
+}
+
+// currentClient returns the client of the current connection, which may be
+// nil if the last reconnection failed.
+func (c *comm) currentClient() *ssh.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
+}
+
+// reconnectFrom replaces the connection of client, which was found broken.
+// When several sessions fail at once, only the first one reconnects and the
+// others reuse the new connection.
+func (c *comm) reconnectFrom(client *ssh.Client) (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return session, nil
+	if c.client != nil && c.client != client {
+		return c.client, nil
+	}
+	if err := c.reconnect(); err != nil {
+		return nil, err
+	}
+	if c.client == nil {
+		return nil, errors.New("client not available")
+	}
+	return c.client, nil
 }
 
+// reconnect establishes a new connection. c.mu must be held.
 func (c *comm) reconnect() (err error) {
 	if c.conn != nil {
 		// Ignore errors here because we don't care if it fails
@@ -306,6 +414,7 @@ func (c *comm) reconnect() (err error) {
 	// Set the conn and client to nil since we'll recreate it
 	c.conn = nil
 	c.client = nil
+	c.monitor = nil
 
 	log.Printf("[DEBUG] reconnecting to TCP connection for SSH")
 	c.conn, err = c.config.Connection()
@@ -319,9 +428,12 @@ func (c *comm) reconnect() (err error) {
 		c.conn = nil
 
 		log.Printf("[ERROR] reconnection error: %s", err)
-		return
+		// The error may be an authentication failure on a bastion host.
+		return &HostUnreachableError{Address: c.address, Err: classifyHandshakeError(err)}
 	}
 
+	monitor := newMonitoredConn(c.conn)
+	c.conn = monitor
 	if c.config.Timeout > 0 {
 		c.conn = &timeoutConn{c.conn, c.config.Timeout, c.config.Timeout}
 	}
@@ -359,15 +471,20 @@ func (c *comm) reconnect() (err error) {
 		if sshConn != nil {
 			sshConn.Close()
 		}
-		return ErrHandshakeTimeout
+		if !monitor.hasReceived() {
+			return &BannerDelayError{Timeout: duration}
+		}
+		return &HandshakeTimeoutError{Timeout: duration}
 	}
 
 	if err != nil {
-		return
+		return classifyHandshakeError(err)
 	}
 	log.Printf("[DEBUG] handshake complete!")
 	if sshConn != nil {
 		c.client = ssh.NewClient(sshConn, sshChan, req)
+		c.monitor = monitor
+		go c.monitorConnection(c.client, monitor)
 	}
 	c.connectToAgent()
 	err = c.connectTunnels(sshConn)
@@ -415,9 +532,10 @@ func (c *comm) connectTunnels(sshConn ssh.Conn) (err error) {
 				return
 			}
 			log.Printf("[INFO] Tunnel: Local bound on %s forwarding to %s", v.ListenAddr, v.ForwardAddr)
+			client := c.client
 			connectFunc := func() (net.Conn, error) {
 				// This Dial occurs on the SSH server's side
-				return c.client.Dial(v.ForwardType, v.ForwardAddr)
+				return client.Dial(v.ForwardType, v.ForwardAddr)
 			}
 			go ProxyServe(listener, done, connectFunc)
 			// FIXME: Is there a better "on-shutdown" we can wait on?
@@ -477,8 +595,9 @@ func (c *comm) connectToAgent() {
 	agent.ForwardToAgent(c.client, forwardingAgent)
 
 	// Setup a session to request agent forwarding
-	session, err := c.newSession()
+	session, err := c.client.NewSession()
 	if err != nil {
+		log.Printf("[ERROR] could not open session for agent forwarding: %s", err)
 		return
 	}
 	defer session.Close()
@@ -494,21 +613,42 @@ func (c *comm) connectToAgent() {
 
 func (c *comm) sftpUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
 	sftpFunc := func(client *sftp.Client) error {
-		return c.sftpUploadFile(path, input, client, fi)
+		return c.sftpUploadFile(path, input, client, fi, c.config.ProgressTracker)
 	}
 
 	return c.sftpSession(sftpFunc)
 }
 
-func (c *comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo) error {
+func (c *comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo, tracker getter.ProgressTracker) error {
 	log.Printf("[DEBUG] sftp: uploading %s", path)
-	f, err := client.Create(path)
-	if err != nil {
-		return err
+	var f *sftp.File
+	var err error
+	offset := c.sftpResumeOffset(path, input, client, fi)
+	if offset > 0 {
+		log.Printf("[INFO] sftp: resuming upload of %s at byte %d", path, offset)
+		f, err = client.OpenFile(path, os.O_WRONLY)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err = input.(io.Seeker).Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	} else {
+		f, err = client.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
 	}
-	defer f.Close()
 
-	if _, err = io.Copy(f, input); err != nil {
+	tracked := packersdk.TrackedTransfer(tracker, path, offset, packersdk.TransferSize(fi), input)
+	defer tracked.Close()
+
+	if _, err = io.Copy(f, tracked); err != nil {
 		return err
 	}
 
@@ -523,6 +663,76 @@ func (c *comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client,
 	return nil
 }
 
+// sftpResumeChunk is the amount of data of a partial upload compared at once
+// before resuming it.
+const sftpResumeChunk = 32 * 1024
+
+// sftpResumeOffset returns the offset at which the upload of input to path
+// can resume, or 0 if it must start over. An upload is resumed when the remote
+// file is shorter than the local one, and its whole content matches the start
+// of the local file; this can only be checked when input is seekable.
+func (c *comm) sftpResumeOffset(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo) int64 {
+	if !c.config.SftpResume || fi == nil || !(*fi).Mode().IsRegular() {
+		return 0
+	}
+	local, ok := input.(io.ReadSeeker)
+	if !ok {
+		return 0
+	}
+
+	remoteFi, err := client.Stat(path)
+	if err != nil || !remoteFi.Mode().IsRegular() {
+		return 0
+	}
+	offset := remoteFi.Size()
+	if offset == 0 || offset >= (*fi).Size() {
+		return 0
+	}
+
+	// Reading the local part moves input, which must be rewound
+	// when starting over.
+	if !sftpPartialMatches(path, local, client, offset) {
+		log.Printf("[DEBUG] sftp: %s differs from the partial upload, starting over", path)
+		local.Seek(0, io.SeekStart)
+		return 0
+	}
+	return offset
+}
+
+// sftpPartialMatches reports whether the remote file at path has the same
+// first offset bytes as local. The whole partial upload is compared, as the
+// local file may have changed anywhere since.
+func sftpPartialMatches(path string, local io.ReadSeeker, client *sftp.Client, offset int64) bool {
+	if _, err := local.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	remote, err := client.Open(path)
+	if err != nil {
+		return false
+	}
+	defer remote.Close()
+
+	localChunk := make([]byte, sftpResumeChunk)
+	remoteChunk := make([]byte, sftpResumeChunk)
+	for n := int64(0); n < offset; {
+		chunk := int64(sftpResumeChunk)
+		if offset-n < chunk {
+			chunk = offset - n
+		}
+		if _, err := io.ReadFull(local, localChunk[:chunk]); err != nil {
+			return false
+		}
+		if _, err := io.ReadFull(remote, remoteChunk[:chunk]); err != nil {
+			return false
+		}
+		if !bytes.Equal(localChunk[:chunk], remoteChunk[:chunk]) {
+			return false
+		}
+		n += chunk
+	}
+	return true
+}
+
 func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error {
 	sftpFunc := func(client *sftp.Client) error {
 		rootDst := dst
@@ -530,37 +740,81 @@ func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error
 			log.Printf("[DEBUG] No trailing slash, creating the source directory name")
 			rootDst = filepath.Join(dst, filepath.Base(src))
 		}
-		walkFunc := func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			// Calculate the final destination using the
-			// base source and root destination
-			relSrc, err := filepath.Rel(src, path)
-			if err != nil {
-				return err
-			}
-			finalDst := filepath.Join(rootDst, relSrc)
 
+		var visit func(rel string, fi os.FileInfo) error
+		visit = func(rel string, fi os.FileInfo) error {
+			path := filepath.Join(src, rel)
 			// In Windows, Join uses backslashes which we don't want to get
 			// to the sftp server
-			finalDst = filepath.ToSlash(finalDst)
+			finalDst := filepath.ToSlash(filepath.Join(rootDst, rel))
+
+			if fi.Mode()&os.ModeSymlink != 0 {
+				return c.sftpSymlink(finalDst, path, client)
+			}
+			if !fi.IsDir() {
+				return c.sftpVisitFile(finalDst, path, fi, client)
+			}
 
 			// Skip the creation of the target destination directory since
 			// it should exist and we might not even own it
-			if finalDst == dst {
-				return nil
+			if finalDst != dst {
+				if err := c.sftpMkdir(finalDst, client, fi); err != nil {
+					return err
+				}
 			}
 
-			return c.sftpVisitFile(finalDst, path, info, client)
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			entries, err := f.Readdir(-1)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			sort.Slice(entries, func(i, j int) bool {
+				return entries[i].Name() < entries[j].Name()
+			})
+
+			for _, entry := range entries {
+				entryRel := filepath.Join(rel, entry.Name())
+				entry, err := uploadDirEntry(filepath.Join(src, entryRel), entryRel, entry, excl, c.config.SymlinkPolicy)
+				if err != nil {
+					return err
+				}
+				if entry == nil {
+					continue
+				}
+				if err := visit(entryRel, entry); err != nil {
+					return err
+				}
+			}
+			return nil
 		}
 
-		return filepath.Walk(src, walkFunc)
+		fi, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		return visit(".", fi)
 	}
 
 	return c.sftpSession(sftpFunc)
 }
 
+// sftpSymlink recreates the symbolic link src at dst.
+func (c *comm) sftpSymlink(dst string, src string, client *sftp.Client) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] sftp: creating symlink %s -> %s", dst, target)
+
+	// Replace what a previous upload left there
+	client.Remove(dst)
+	return client.Symlink(filepath.ToSlash(target), dst)
+}
+
 func (c *comm) sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
 	log.Printf("[DEBUG] sftp: creating dir %s", path)
 
@@ -586,7 +840,7 @@ func (c *comm) sftpVisitFile(dst string, src string, fi os.FileInfo, client *sft
 			return err
 		}
 		defer f.Close()
-		return c.sftpUploadFile(dst, f, client, &fi)
+		return c.sftpUploadFile(dst, f, client, &fi, nil)
 	} else {
 		err := c.sftpMkdir(dst, client, fi)
 		return err
@@ -595,13 +849,35 @@ func (c *comm) sftpVisitFile(dst string, src string, fi os.FileInfo, client *sft
 
 func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
 	sftpFunc := func(client *sftp.Client) error {
+		if pathmatch.HasGlobMeta(path) {
+			matches, err := client.Glob(path)
+			if err != nil {
+				return err
+			}
+			switch len(matches) {
+			case 0:
+				return fmt.Errorf("no file matches %s", path)
+			case 1:
+				path = matches[0]
+			default:
+				return fmt.Errorf("%s matches %d files, use DownloadDir to download them", path, len(matches))
+			}
+		}
+
 		f, err := client.Open(path)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 
-		if _, err = io.Copy(output, f); err != nil {
+		var size int64
+		if fi, err := f.Stat(); err == nil {
+			size = fi.Size()
+		}
+		tracked := packersdk.TrackedTransfer(c.config.ProgressTracker, path, 0, size, f)
+		defer tracked.Close()
+
+		if _, err = io.Copy(output, tracked); err != nil {
 			return err
 		}
 
@@ -611,14 +887,127 @@ func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
 	return c.sftpSession(sftpFunc)
 }
 
+// sftpDownloadDirSession downloads src into dst. src may be a glob pattern,
+// in which case every match is downloaded.
+func (c *comm) sftpDownloadDirSession(src string, dst string, excl []string) error {
+	sftpFunc := func(client *sftp.Client) error {
+		roots := []string{src}
+		if pathmatch.HasGlobMeta(src) {
+			matches, err := client.Glob(src)
+			if err != nil {
+				return err
+			}
+			if len(matches) == 0 {
+				return fmt.Errorf("no file matches %s", src)
+			}
+			roots = matches
+		}
+
+		for _, root := range roots {
+			root = path.Clean(root)
+			if pathmatch.Excluded(path.Base(root), excl) {
+				log.Printf("[DEBUG] Excluding: %s", root)
+				continue
+			}
+
+			walker := client.Walk(root)
+			for walker.Step() {
+				if err := walker.Err(); err != nil {
+					return err
+				}
+				remote, fi := walker.Path(), walker.Stat()
+				rel := strings.TrimPrefix(strings.TrimPrefix(remote, root), "/")
+				if rel != "" && pathmatch.Excluded(rel, excl) {
+					log.Printf("[DEBUG] Excluding: %s", remote)
+					if fi.IsDir() {
+						walker.SkipDir()
+					}
+					continue
+				}
+
+				local := filepath.Join(dst, path.Base(root), filepath.FromSlash(rel))
+				switch {
+				case fi.IsDir():
+					if err := os.MkdirAll(local, fi.Mode().Perm()); err != nil {
+						return err
+					}
+				case fi.Mode().IsRegular():
+					if err := sftpDownloadFile(client, remote, local, fi.Mode().Perm()); err != nil {
+						return err
+					}
+				default:
+					log.Printf("[WARN] sftp: skipping %s, which is not a regular file", remote)
+				}
+			}
+		}
+		return nil
+	}
+
+	return c.sftpSession(sftpFunc)
+}
+
+func sftpDownloadFile(client *sftp.Client, src string, dst string, mode os.FileMode) error {
+	log.Printf("[DEBUG] sftp: downloading %s", src)
+	f, err := client.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, f)
+	return err
+}
+
 func (c *comm) sftpSession(f func(*sftp.Client) error) error {
-	client, err := c.newSftpClient()
+	client, err := c.sharedSftpClient()
 	if err != nil {
 		return fmt.Errorf("sftpSession error: %s", err.Error())
 	}
-	defer client.Close()
 
-	return f(client)
+	err = f(client)
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, io.EOF) {
+		c.dropSftpClient(client)
+	}
+	return err
+}
+
+// sharedSftpClient returns the SFTP client shared by all transfers, opening a
+// new one when there is none yet or the connection was re-established.
+func (c *comm) sharedSftpClient() (*sftp.Client, error) {
+	c.sftpMu.Lock()
+	defer c.sftpMu.Unlock()
+
+	if c.sftp != nil && c.sftpClient == c.currentClient() {
+		return c.sftp, nil
+	}
+	if c.sftp != nil {
+		c.sftp.Close()
+		c.sftp = nil
+	}
+
+	client, err := c.newSftpClient()
+	if err != nil {
+		return nil, err
+	}
+	c.sftp = client
+	c.sftpClient = c.currentClient()
+	return client, nil
+}
+
+func (c *comm) dropSftpClient(client *sftp.Client) {
+	c.sftpMu.Lock()
+	defer c.sftpMu.Unlock()
+
+	if c.sftp == client {
+		c.sftp.Close()
+		c.sftp = nil
+	}
 }
 
 func (c *comm) newSftpClient() (*sftp.Client, error) {
@@ -643,7 +1032,16 @@ func (c *comm) newSftpClient() (*sftp.Client, error) {
 	// Capture stdout so we can return errors to the user
 	var stdout bytes.Buffer
 	tee := io.TeeReader(pr, &stdout)
-	client, err := sftp.NewClientPipe(tee, pw)
+	var opts []sftp.ClientOption
+	if c.config.SftpMaxPacket > 0 {
+		opts = append(opts, sftp.MaxPacketUnchecked(c.config.SftpMaxPacket))
+	}
+	if c.config.SftpConcurrency > 1 {
+		opts = append(opts,
+			sftp.MaxConcurrentRequestsPerFile(c.config.SftpConcurrency),
+			sftp.UseConcurrentWrites(true))
+	}
+	client, err := sftp.NewClientPipe(c.limiter.Reader(tee), c.limiter.WriteCloser(pw), opts...)
 	if err != nil && stdout.Len() > 0 {
 		log.Printf("[ERROR] Upload failed: %s", stdout.Bytes())
 	}
@@ -666,13 +1064,26 @@ func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) e
 	target_dir = strings.Replace(target_dir, " ", "\\ ", -1)
 
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
-		return scpUploadFile(target_file, input, w, stdoutR, fi)
+		return scpUploadFile(target_file, input, w, stdoutR, fi, c.config.ProgressTracker)
 	}
 
 	return c.scpSession("scp -vt "+target_dir, scpFunc)
 }
 
 func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error {
+	policy := c.config.SymlinkPolicy
+	if policy == SymlinkPreserve {
+		log.Printf("[WARN] scp can't upload symlinks, following them instead")
+		policy = SymlinkFollow
+	}
+	filter := func(path string, fi os.FileInfo) (os.FileInfo, error) {
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return nil, err
+		}
+		return uploadDirEntry(path, rel, fi, excl, policy)
+	}
+
 	scpFunc := func(w io.Writer, r *bufio.Reader) error {
 		uploadEntries := func() error {
 			f, err := os.Open(src)
@@ -686,7 +1097,7 @@ func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error
 				return err
 			}
 
-			return scpUploadDir(src, entries, w, r)
+			return scpUploadDir(src, entries, w, r, filter)
 		}
 
 		if src[len(src)-1] != '/' {
@@ -742,13 +1153,24 @@ func (c *comm) scpDownloadSession(path string, output io.Writer) error {
 
 		fmt.Fprint(w, "\x00")
 
-		if _, err := io.CopyN(output, stdoutR, size); err != nil {
+		tracked := packersdk.TrackedTransfer(c.config.ProgressTracker, path, 0, size, stdoutR)
+		defer tracked.Close()
+
+		if _, err := io.CopyN(output, tracked, size); err != nil {
 			return err
 		}
 
 		fmt.Fprint(w, "\x00")
 
-		return checkSCPStatus(stdoutR)
+		if err := checkSCPStatus(stdoutR); err != nil {
+			return err
+		}
+
+		// A glob pattern may have matched more files, which are sent next.
+		if next, err := stdoutR.ReadString('\n'); err == nil && len(next) > 0 && next[0] == 'C' {
+			return fmt.Errorf("%s matches several files, use DownloadDir to download them", path)
+		}
+		return nil
 	}
 
 	if !strings.Contains(path, " ") {
@@ -757,6 +1179,116 @@ func (c *comm) scpDownloadSession(path string, output io.Writer) error {
 	return c.scpSession("scp -vf "+strconv.Quote(path), scpFunc)
 }
 
+// scpDownloadDirSession downloads src into dst. src may be a glob pattern,
+// which is expanded by the remote shell, as long as it has no spaces.
+func (c *comm) scpDownloadDirSession(src string, dst string, excl []string) error {
+	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
+		dirStack := []string{dst}
+		// skipDepth is the depth of the excluded directory being skipped,
+		// or 0.
+		skipDepth := 0
+		for {
+			fmt.Fprint(w, "\x00")
+
+			// read file info
+			fi, err := stdoutR.ReadString('\n')
+			if err != nil {
+				return err
+			}
+
+			if len(fi) == 0 {
+				return fmt.Errorf("empty response from server")
+			}
+
+			switch fi[0] {
+			case '\x01', '\x02':
+				return fmt.Errorf("%s", fi[1:])
+			case 'C', 'D':
+				break
+			case 'E':
+				if skipDepth == len(dirStack) {
+					skipDepth = 0
+				}
+				dirStack = dirStack[:len(dirStack)-1]
+				if len(dirStack) == 0 {
+					fmt.Fprint(w, "\x00")
+					return nil
+				}
+				continue
+			default:
+				return fmt.Errorf("unexpected server response (%x)", fi[0])
+			}
+
+			var mode int64
+			var size int64
+			var name string
+			log.Printf("[DEBUG] Download dir str:%s", fi)
+			n, err := fmt.Sscanf(fi[1:], "%o %d %s", &mode, &size, &name)
+			if err != nil || n != 3 {
+				return fmt.Errorf("can't parse server response (%s)", fi)
+			}
+			if size < 0 {
+				return fmt.Errorf("negative file size")
+			}
+
+			log.Printf("[DEBUG] Download dir mode:%0o size:%d name:%s", mode, size, name)
+
+			// Paths are matched relative to the top-level entries, which
+			// are matched by their name.
+			rel := name
+			if len(dirStack) > 1 {
+				rel = filepath.Join(filepath.Join(dirStack[2:]...), name)
+			}
+			if skipDepth == 0 && pathmatch.Excluded(rel, excl) {
+				log.Printf("[DEBUG] Excluding: %s", rel)
+				skipDepth = len(dirStack) + 1
+			}
+			if skipDepth > 0 {
+				switch fi[0] {
+				case 'D':
+					dirStack = append(dirStack, name)
+					continue
+				case 'C':
+					fmt.Fprint(w, "\x00")
+					if _, err := io.CopyN(ioutil.Discard, stdoutR, size); err != nil {
+						return err
+					}
+					// A skipped file doesn't start skipping a directory.
+					if skipDepth > len(dirStack) {
+						skipDepth = 0
+					}
+				}
+				if err := checkSCPStatus(stdoutR); err != nil {
+					return err
+				}
+				continue
+			}
+
+			dst = filepath.Join(dirStack...)
+			switch fi[0] {
+			case 'D':
+				err = os.MkdirAll(filepath.Join(dst, name), os.FileMode(mode))
+				if err != nil {
+					return err
+				}
+				dirStack = append(dirStack, name)
+				continue
+			case 'C':
+				fmt.Fprint(w, "\x00")
+				err = scpDownloadFile(filepath.Join(dst, name), stdoutR, size, os.FileMode(mode))
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := checkSCPStatus(stdoutR); err != nil {
+				return err
+			}
+		}
+	}
+	return c.scpSession("scp -vrf "+src, scpFunc)
+}
+
 func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) error) error {
 	session, err := c.newSession()
 	if err != nil {
@@ -783,7 +1315,7 @@ func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	if err != nil {
 		return err
 	}
-	stdoutR := bufio.NewReader(stdoutPipe)
+	stdoutR := bufio.NewReader(c.limiter.Reader(stdoutPipe))
 
 	// Set stderr to a bytes buffer
 	stderr := new(bytes.Buffer)
@@ -800,7 +1332,7 @@ func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	// EOF errors if they occur because it usually means that SCP prematurely
 	// ended on the other side.
 	log.Println("[DEBUG] Started SCP session, beginning transfers...")
-	if err := f(stdinW, stdoutR); err != nil && err != io.EOF {
+	if err := f(c.limiter.Writer(stdinW), stdoutR); err != nil && err != io.EOF {
 		return err
 	}
 
@@ -876,7 +1408,7 @@ func scpDownloadFile(dst string, src io.Reader, size int64, mode os.FileMode) er
 	return nil
 }
 
-func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *os.FileInfo) error {
+func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *os.FileInfo, tracker getter.ProgressTracker) error {
 	var mode os.FileMode
 	var size int64
 
@@ -932,7 +1464,10 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 		return err
 	}
 
-	if _, err := io.CopyN(w, src, size); err != nil {
+	tracked := packersdk.TrackedTransfer(tracker, dst, 0, size, src)
+	defer tracked.Close()
+
+	if _, err := io.CopyN(w, tracked, size); err != nil {
 		return err
 	}
 
@@ -961,30 +1496,23 @@ func scpUploadDirProtocol(name string, w io.Writer, r *bufio.Reader, f func() er
 	return err
 }
 
-func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) error {
+// scpUploadDir uploads the entries fs of the directory root. filter returns
+// the info of what to upload for each entry, or nil to skip it.
+func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader, filter func(string, os.FileInfo) (os.FileInfo, error)) error {
 	for _, fi := range fs {
 		realPath := filepath.Join(root, fi.Name())
 
-		// Track if this is actually a symlink to a directory. If it is
-		// a symlink to a file we don't do any special behavior because uploading
-		// a file just works. If it is a directory, we need to know so we
-		// treat it as such.
-		isSymlinkToDir := false
-		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
-			symPath, err := filepath.EvalSymlinks(realPath)
-			if err != nil {
-				return err
-			}
-
-			symFi, err := os.Lstat(symPath)
-			if err != nil {
-				return err
-			}
-
-			isSymlinkToDir = symFi.IsDir()
+		// Symlinks are resolved by the filter, so that a symlink to a
+		// directory is uploaded as a directory.
+		fi, err := filter(realPath, fi)
+		if err != nil {
+			return err
+		}
+		if fi == nil {
+			continue
 		}
 
-		if !fi.IsDir() && !isSymlinkToDir {
+		if !fi.IsDir() {
 			// It is a regular file (or symlink to a file), just upload it
 			f, err := os.Open(realPath)
 			if err != nil {
@@ -993,7 +1521,7 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 
 			err = func() error {
 				defer f.Close()
-				return scpUploadFile(fi.Name(), f, w, r, &fi)
+				return scpUploadFile(fi.Name(), f, w, r, &fi, nil)
 			}()
 
 			if err != nil {
@@ -1004,7 +1532,7 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 		}
 
 		// It is a directory, recursively upload
-		err := scpUploadDirProtocol(fi.Name(), w, r, func() error {
+		err = scpUploadDirProtocol(fi.Name(), w, r, func() error {
 			f, err := os.Open(realPath)
 			if err != nil {
 				return err
@@ -1016,7 +1544,7 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 				return err
 			}
 
-			return scpUploadDir(realPath, entries, w, r)
+			return scpUploadDir(realPath, entries, w, r, filter)
 		}, fi)
 		if err != nil {
 			return err
@@ -9,12 +9,22 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -123,6 +133,246 @@ func newMockBrokenServer(t *testing.T) string {
 	return l.Addr().String()
 }
 
+// newMockReconnectServer accepts any number of SSH connections, accepting
+// and closing every channel, and counts the connections it accepted.
+func newMockReconnectServer(t *testing.T) (string, *int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen for connection: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var connections int32
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&connections, 1)
+			go func() {
+				defer c.Close()
+				_, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					channel, _, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					channel.Close()
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String(), &connections
+}
+
+// newMockExecServer accepts SSH connections and runs the commands of exec
+// requests locally with sh. It also serves port forwards.
+func newMockExecServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen for connection: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				conn, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+				if err != nil {
+					return
+				}
+				go serveMockForwardRequests(t, conn, reqs)
+				for newChannel := range chans {
+					if newChannel.ChannelType() == "direct-tcpip" {
+						go serveMockDirectTCPIP(newChannel)
+						continue
+					}
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go serveMockExec(channel, requests)
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// serveMockDirectTCPIP connects a local forward to its destination.
+func serveMockDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	upstream, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(channel, upstream)
+		channel.CloseWrite()
+	}()
+	io.Copy(upstream, channel)
+	upstream.Close()
+}
+
+// serveMockForwardRequests binds remote forwards locally, and replies to
+// every other global request with a failure.
+func serveMockForwardRequests(t *testing.T, conn ssh.Conn, reqs <-chan *ssh.Request) {
+	listeners := make(map[uint32]net.Listener)
+	for req := range reqs {
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		switch req.Type {
+		case "tcpip-forward":
+		case "cancel-tcpip-forward":
+			ssh.Unmarshal(req.Payload, &payload)
+			if l, ok := listeners[payload.Port]; ok {
+				l.Close()
+				delete(listeners, payload.Port)
+			}
+			req.Reply(true, nil)
+			continue
+		default:
+			req.Reply(false, nil)
+			continue
+		}
+		ssh.Unmarshal(req.Payload, &payload)
+		l, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		t.Cleanup(func() { l.Close() })
+		port := uint32(l.Addr().(*net.TCPAddr).Port)
+		listeners[port] = l
+		req.Reply(true, ssh.Marshal(&struct{ Port uint32 }{port}))
+
+		go func(addr string) {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				origin := c.RemoteAddr().(*net.TCPAddr)
+				channel, requests, err := conn.OpenChannel("forwarded-tcpip", ssh.Marshal(&struct {
+					Addr     string
+					Port     uint32
+					OrigAddr string
+					OrigPort uint32
+				}{addr, port, origin.IP.String(), uint32(origin.Port)}))
+				if err != nil {
+					c.Close()
+					continue
+				}
+				go ssh.DiscardRequests(requests)
+				go func() {
+					io.Copy(channel, c)
+					channel.CloseWrite()
+				}()
+				go func() {
+					io.Copy(c, channel)
+					c.Close()
+				}()
+			}
+		}(payload.Addr)
+	}
+}
+
+// serveMockExec runs the command of an exec request with sh. The settings of
+// a pty request, which does not allocate a terminal, are given to the command
+// as MOCK_PTY.
+func serveMockExec(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	var env []string
+	for req := range requests {
+		if req.Type == "pty-req" {
+			var pty struct {
+				Term                         string
+				Columns, Rows, Width, Height uint32
+				Modes                        string
+			}
+			ssh.Unmarshal(req.Payload, &pty)
+			env = append(env, fmt.Sprintf("MOCK_PTY=%s %dx%d", pty.Term, pty.Columns, pty.Rows))
+			req.Reply(true, nil)
+			continue
+		}
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		ssh.Unmarshal(req.Payload, &payload)
+		req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Env = append(os.Environ(), env...)
+		stdin, _ := cmd.StdinPipe()
+		go func() {
+			io.Copy(stdin, channel)
+			stdin.Close()
+		}()
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+
+		status := struct{ Status uint32 }{0}
+		if err := cmd.Run(); err != nil {
+			status.Status = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status.Status = uint32(exitErr.ExitCode())
+			}
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(&status))
+		return
+	}
+}
+
+func newMockExecComm(t *testing.T, config *Config) *comm {
+	address := newMockExecServer(t)
+	config.Connection = func() (net.Conn, error) {
+		return net.Dial("tcp", address)
+	}
+	config.SSHConfig = &ssh.ClientConfig{
+		User: "user",
+		Auth: []ssh.AuthMethod{
+			ssh.Password("pass"),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	config.DisableAgentForwarding = true
+
+	c, err := New(address, config)
+	if err != nil {
+		t.Fatalf("error connecting to SSH: %s", err)
+	}
+	return c
+}
+
 func TestCommIsCommunicator(t *testing.T) {
 	var raw interface{}
 	raw = &comm{}
@@ -197,6 +447,109 @@ func TestStart(t *testing.T) {
 	client.Start(ctx, cmd)
 }
 
+func TestStart_pty(t *testing.T) {
+	c := newMockExecComm(t, &Config{Pty: true, PtyTerm: "vt100", PtyWidth: 120})
+
+	yes, no := true, false
+	tests := []struct {
+		pty      *bool
+		expected string
+	}{
+		{nil, "vt100 120x40\n"},
+		{&yes, "vt100 120x40\n"},
+		{&no, "\n"},
+	}
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		cmd := &packersdk.RemoteCmd{
+			Command: `echo "$MOCK_PTY"`,
+			Stdout:  &stdout,
+			Pty:     tt.pty,
+		}
+		if err := c.Start(context.Background(), cmd); err != nil {
+			t.Fatal(err)
+		}
+		cmd.Wait()
+		if stdout.String() != tt.expected {
+			t.Fatalf("expected %q, got %q", tt.expected, stdout.String())
+		}
+	}
+}
+
+func TestStartBatch(t *testing.T) {
+	c := newMockExecComm(t, &Config{})
+
+	commands := []string{
+		"echo one; echo err >&2",
+		"printf partial; exit 3",
+		"true",
+		"printf 'three\n\n'",
+	}
+	var cmds []*packersdk.RemoteCmd
+	for _, command := range commands {
+		cmds = append(cmds, &packersdk.RemoteCmd{
+			Command: command,
+			Stdout:  new(bytes.Buffer),
+			Stderr:  new(bytes.Buffer),
+		})
+	}
+	if err := packersdk.RunBatch(context.Background(), c, cmds); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		stdout, stderr string
+		status         int
+	}{
+		{"one\n", "err\n", 0},
+		{"partial", "", 3},
+		{"", "", 0},
+		{"three\n\n", "", 0},
+	}
+	for i, e := range expected {
+		cmd := cmds[i]
+		if got := cmd.Stdout.(*bytes.Buffer).String(); got != e.stdout {
+			t.Errorf("command %d: expected stdout %q, got %q", i, e.stdout, got)
+		}
+		if got := cmd.Stderr.(*bytes.Buffer).String(); got != e.stderr {
+			t.Errorf("command %d: expected stderr %q, got %q", i, e.stderr, got)
+		}
+		if got := cmd.ExitStatus(); got != e.status {
+			t.Errorf("command %d: expected exit status %d, got %d", i, e.status, got)
+		}
+	}
+}
+
+func TestCommNewSession(t *testing.T) {
+	c := newMockExecComm(t, &Config{})
+
+	s, err := c.NewSession()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s.(*session).comm != c {
+		t.Fatal("the session should share the connection")
+	}
+	if err := s.(io.Closer).Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.currentClient() == nil {
+		t.Fatal("closing the session should leave the connection open")
+	}
+
+	cmd := &packersdk.RemoteCmd{
+		Command: "echo foo",
+		Stdout:  new(bytes.Buffer),
+	}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+	if got := cmd.Stdout.(*bytes.Buffer).String(); got != "foo\n" {
+		t.Fatalf("bad: %q", got)
+	}
+}
+
 func TestHandshakeTimeout(t *testing.T) {
 	clientConfig := &ssh.ClientConfig{
 		User: "user",
@@ -222,7 +575,8 @@ func TestHandshakeTimeout(t *testing.T) {
 	}
 
 	_, err := New(address, config)
-	if err != ErrHandshakeTimeout {
+	var bannerErr *BannerDelayError
+	if !errors.As(err, &bannerErr) || !errors.Is(err, ErrHandshakeTimeout) || !IsTransient(err) {
 		// Note: there's another error that can come back from this call:
 		//   ssh: handshake failed: EOF
 		// This should appear in cases where the handshake fails because of
@@ -231,3 +585,589 @@ func TestHandshakeTimeout(t *testing.T) {
 		t.Fatalf("Expected handshake timeout, got: %s", err)
 	}
 }
+
+func TestNew_errors(t *testing.T) {
+	address := newMockExecServer(t)
+	config := &Config{
+		Connection: func() (net.Conn, error) {
+			return net.Dial("tcp", address)
+		},
+		SSHConfig: &ssh.ClientConfig{
+			User: "user",
+			Auth: []ssh.AuthMethod{
+				ssh.Password("wrong"),
+			},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+	}
+
+	_, err := New(address, config)
+	var authErr *AuthFailedError
+	if !errors.As(err, &authErr) || IsTransient(err) {
+		t.Fatalf("expected a non-transient authentication error, got: %#v", err)
+	}
+
+	config.Connection = func() (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	_, err = New(address, config)
+	var unreachableErr *HostUnreachableError
+	if !errors.As(err, &unreachableErr) || !IsTransient(err) {
+		t.Fatalf("expected a transient unreachable host error, got: %#v", err)
+	}
+}
+
+func TestNewSession_reconnect(t *testing.T) {
+	address, connections := newMockReconnectServer(t)
+	config := &Config{
+		Connection: func() (net.Conn, error) {
+			return net.Dial("tcp", address)
+		},
+		SSHConfig: &ssh.ClientConfig{
+			User: "user",
+			Auth: []ssh.AuthMethod{
+				ssh.Password("pass"),
+			},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		DisableAgentForwarding: true,
+	}
+
+	c, err := New(address, config)
+	if err != nil {
+		t.Fatalf("error connecting to SSH: %s", err)
+	}
+
+	// Sessions share the connection
+	for i := 0; i < 3; i++ {
+		session, err := c.newSession()
+		if err != nil {
+			t.Fatalf("error opening session: %s", err)
+		}
+		session.Close()
+	}
+	if n := atomic.LoadInt32(connections); n != 1 {
+		t.Fatalf("expected 1 connection, got %d", n)
+	}
+
+	// Break the connection, concurrent sessions reconnect only once
+	c.conn.Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session, err := c.newSession()
+			if err != nil {
+				errs <- err
+				return
+			}
+			session.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("error opening session after reconnect: %s", err)
+	}
+	if n := atomic.LoadInt32(connections); n != 2 {
+		t.Fatalf("expected 2 connections, got %d", n)
+	}
+}
+
+// newMockSilentServer accepts SSH connections, and then never answers any
+// request, like a server that went away without closing the connection.
+func newMockSilentServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen for connection: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+				if err != nil {
+					return
+				}
+				go func() {
+					for range reqs {
+					}
+				}()
+				for range chans {
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestMonitorConnection(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"unanswered keepalives", Config{KeepAliveInterval: 10 * time.Millisecond, KeepAliveCountMax: 3}},
+		{"inactivity timeout", Config{KeepAliveInterval: -1, InactivityTimeout: 50 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := newMockSilentServer(t)
+			config := tt.config
+			config.Connection = func() (net.Conn, error) {
+				return net.Dial("tcp", address)
+			}
+			config.SSHConfig = &ssh.ClientConfig{
+				User: "user",
+				Auth: []ssh.AuthMethod{
+					ssh.Password("pass"),
+				},
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			}
+			config.DisableAgentForwarding = true
+
+			c, err := New(address, &config)
+			if err != nil {
+				t.Fatalf("error connecting to SSH: %s", err)
+			}
+
+			select {
+			case <-c.monitor.closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the connection to be closed")
+			}
+			err = c.connectionLost(io.EOF)
+			if _, ok := err.(*ConnectionLostError); !ok {
+				t.Fatalf("expected a ConnectionLostError, got %#v", err)
+			}
+		})
+	}
+}
+
+// newTestSftpClient returns an sftp client served in-process, over the local
+// filesystem.
+func newTestSftpClient(t *testing.T) *sftp.Client {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverR, serverW})
+	if err != nil {
+		t.Fatalf("error creating sftp server: %s", err)
+	}
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientR, clientW)
+	if err != nil {
+		t.Fatalf("error creating sftp client: %s", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return client
+}
+
+func TestSftpUploadFile_resume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 8*1024)
+
+	tests := []struct {
+		name    string
+		partial []byte
+		resumed bool
+	}{
+		{"partial upload", content[:50000], true},
+		{"different content", bytes.Repeat([]byte("x"), 50000), false},
+		{"changed early", append([]byte("X"), content[1:50000]...), false},
+		{"complete upload", content, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src")
+			dst := filepath.Join(dir, "dst")
+			if err := os.WriteFile(src, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(dst, tt.partial, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			fi, _ := f.Stat()
+
+			c := &comm{config: &Config{SftpResume: true}}
+			client := newTestSftpClient(t)
+			if offset := c.sftpResumeOffset(dst, f, client, &fi); (offset > 0) != tt.resumed {
+				t.Fatalf("unexpected resume offset %d", offset)
+			}
+			f.Seek(0, io.SeekStart)
+			if err := c.sftpUploadFile(dst, f, client, &fi, nil); err != nil {
+				t.Fatalf("error uploading: %s", err)
+			}
+
+			got, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("uploaded file differs from source (%d bytes, expected %d)", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestSftpUploadDir_filters(t *testing.T) {
+	src := t.TempDir()
+	for _, dir := range []string{"a", ".git"} {
+		if err := os.Mkdir(filepath.Join(src, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"a/file", ".git/config", "a/debug.log"} {
+		if err := os.WriteFile(filepath.Join(src, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"filelink": "a/file",
+		"dirlink":  "a",
+		"dangling": "missing",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(src, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		policy   SymlinkPolicy
+		expected map[string]string
+	}{
+		{"", map[string]string{
+			"a/file": "file", "filelink": "file", "dirlink/file": "file",
+		}},
+		{SymlinkPreserve, map[string]string{
+			"a/file": "file", "filelink": "symlink", "dirlink": "symlink", "dangling": "symlink",
+		}},
+		{SymlinkSkip, map[string]string{
+			"a/file": "file",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dst := t.TempDir()
+			c := &comm{
+				config: &Config{UseSftp: true, SymlinkPolicy: tt.policy},
+				sftp:   newTestSftpClient(t),
+			}
+			if err := c.UploadDir(dst, src+"/", []string{".git", "*.log"}); err != nil {
+				t.Fatalf("error uploading: %s", err)
+			}
+
+			got := map[string]string{}
+			err := filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+				if err != nil || fi.IsDir() {
+					return err
+				}
+				rel, _ := filepath.Rel(dst, path)
+				got[filepath.ToSlash(rel)] = "file"
+				if fi.Mode()&os.ModeSymlink != 0 {
+					got[filepath.ToSlash(rel)] = "symlink"
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDownloadDir_filters(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "logs")
+	if err := os.MkdirAll(filepath.Join(src, ".cache"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"a.log", "b.log", "c.gz", ".cache/x.log"} {
+		if err := os.WriteFile(filepath.Join(src, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, method := range []string{"scp", "sftp"} {
+		t.Run(method, func(t *testing.T) {
+			var c *comm
+			if method == "sftp" {
+				c = &comm{config: &Config{UseSftp: true}, sftp: newTestSftpClient(t)}
+			} else {
+				c = newMockExecComm(t, &Config{})
+			}
+
+			tests := []struct {
+				src      string
+				excl     []string
+				expected []string
+			}{
+				{src, []string{".cache", "*.gz"}, []string{"logs/a.log", "logs/b.log"}},
+				{src + "/*.log", []string{"b.log"}, []string{"a.log"}},
+			}
+			for _, tt := range tests {
+				dst := t.TempDir()
+				if err := c.DownloadDir(tt.src, dst, tt.excl); err != nil {
+					t.Fatalf("error downloading %s: %s", tt.src, err)
+				}
+
+				var got []string
+				filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+					if err == nil && !fi.IsDir() {
+						rel, _ := filepath.Rel(dst, path)
+						got = append(got, filepath.ToSlash(rel))
+					}
+					return err
+				})
+				if !reflect.DeepEqual(got, tt.expected) {
+					t.Fatalf("%s: expected %v, got %v", tt.src, tt.expected, got)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := c.Download(src+"/a.*", &buf); err != nil {
+				t.Fatalf("error downloading: %s", err)
+			}
+			if buf.String() != "a.log" {
+				t.Fatalf("unexpected content %q", buf.String())
+			}
+			if err := c.Download(src+"/*.log", io.Discard); err == nil {
+				t.Fatal("expected an error downloading several files")
+			}
+		})
+	}
+}
+
+// newEchoServer returns the address of a local server echoing what it reads.
+func newEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen for connection: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestPortForward(t *testing.T) {
+	c := newMockExecComm(t, &Config{})
+	echo := newEchoServer(t)
+
+	forwards := map[string]func() (packersdk.PortForward, error){
+		"local": func() (packersdk.PortForward, error) {
+			return c.ForwardLocal("127.0.0.1:0", echo)
+		},
+		"remote": func() (packersdk.PortForward, error) {
+			return c.ForwardRemote("127.0.0.1:0", echo)
+		},
+	}
+	for name, open := range forwards {
+		t.Run(name, func(t *testing.T) {
+			f, err := open()
+			if err != nil {
+				t.Fatalf("error opening forward: %s", err)
+			}
+
+			conn, err := net.Dial("tcp", f.Addr().String())
+			if err != nil {
+				t.Fatalf("error connecting to forward: %s", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := conn.Write([]byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				t.Fatalf("error reading through forward: %s", err)
+			}
+			if string(buf) != "hello" {
+				t.Fatalf("unexpected echo %q", buf)
+			}
+
+			if err := f.Close(); err != nil {
+				t.Fatalf("error closing forward: %s", err)
+			}
+			if _, err := net.Dial("tcp", f.Addr().String()); err == nil {
+				t.Fatal("expected the forward to stop listening")
+			}
+		})
+	}
+}
+
+// testProgressTracker records the transfers it tracks.
+type testProgressTracker struct {
+	src            string
+	current, total int64
+	read           int64
+	closed         bool
+}
+
+func (p *testProgressTracker) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	p.src, p.current, p.total = src, currentSize, totalSize
+	return p.wrap(stream)
+}
+
+func (p *testProgressTracker) wrap(stream io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: readerFunc(func(b []byte) (int, error) {
+			n, err := stream.Read(b)
+			p.read += int64(n)
+			return n, err
+		}),
+		Closer: closerFunc(func() error {
+			p.closed = true
+			return stream.Close()
+		}),
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) { return f(b) }
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestSftpUploadFile_progress(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 8*1024)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, content[:50000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, _ := f.Stat()
+
+	tracker := &testProgressTracker{}
+	c := &comm{config: &Config{SftpResume: true}}
+	if err := c.sftpUploadFile(dst, f, newTestSftpClient(t), &fi, tracker); err != nil {
+		t.Fatalf("error uploading: %s", err)
+	}
+
+	if tracker.src != dst {
+		t.Fatalf("expected progress of %q, got %q", dst, tracker.src)
+	}
+	if tracker.total != int64(len(content)) {
+		t.Fatalf("expected a total of %d bytes, got %d", len(content), tracker.total)
+	}
+	if tracker.current+tracker.read != tracker.total {
+		t.Fatalf("resumed at %d and read %d bytes, expected a total of %d",
+			tracker.current, tracker.read, tracker.total)
+	}
+	if tracker.current == 0 {
+		t.Fatal("expected the upload to resume")
+	}
+	if !tracker.closed {
+		t.Fatal("expected the tracked reader to be closed")
+	}
+}
+
+func TestRsync_relay(t *testing.T) {
+	// A fake rsync runs its remote shell like the real one does, and records
+	// what the remote command sent back.
+	bin := t.TempDir()
+	output := filepath.Join(bin, "output")
+	fakeRsync := "#!/bin/sh\n" +
+		"shell=$2\n" +
+		"echo hello | \"$shell\" packer tr a-z A-Z > " + output + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "rsync"), []byte(fakeRsync), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	c := newMockExecComm(t, &Config{UseRsync: true})
+	if err := c.rsyncAvailable(); err != nil {
+		t.Fatalf("rsync should be available: %s", err)
+	}
+	if err := c.rsync(nil); err != nil {
+		t.Fatalf("error running rsync: %s", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "HELLO\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestRsync_uploadDir(t *testing.T) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("rsync is not installed")
+	}
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	for _, name := range []string{"keep", "skip.log"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dst, "stale"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newMockExecComm(t, &Config{UseRsync: true, RsyncDelete: true})
+	if err := c.UploadDir(dst, src+"/", []string{"*.log"}); err != nil {
+		t.Fatalf("error uploading: %s", err)
+	}
+
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "keep" {
+		t.Fatalf("unexpected destination content: %v", entries)
+	}
+}
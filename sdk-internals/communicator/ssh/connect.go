@@ -4,31 +4,27 @@
 package ssh
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"net/url"
 	"time"
 
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/net/proxy"
 )
 
 // ConnectFunc is a convenience method for returning a function
-// that just uses net.Dial to communicate with the remote end that
-// is suitable for use with the SSH communicator configuration.
+// that just dials the remote end, suitable for use with the SSH communicator
+// configuration. Host names resolving to both IPv4 and IPv6 addresses are
+// connected to with happy eyeballs, see packernet.DialDualStack.
 func ConnectFunc(network, addr string) func() (net.Conn, error) {
 	return func() (net.Conn, error) {
-		c, err := net.DialTimeout(network, addr, 15*time.Second)
-		if err != nil {
-			return nil, err
-		}
-
-		if tcpConn, ok := c.(*net.TCPConn); ok {
-			tcpConn.SetKeepAlive(true)
-			tcpConn.SetKeepAlivePeriod(5 * time.Second)
-		}
-
-		return c, nil
+		return packernet.DialDualStack(context.Background(), network, addr, 15*time.Second)
 	}
 }
 
@@ -51,6 +47,64 @@ func ProxyConnectFunc(socksProxy string, socksAuth *proxy.Auth, network, addr st
 	}
 }
 
+// HTTPProxyConnectFunc is a convenience method for returning a function
+// that connects to a host through an HTTP proxy, using the CONNECT method.
+func HTTPProxyConnectFunc(httpProxy string, auth *proxy.Auth, network, addr string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		c, err := packernet.DialDualStack(context.Background(), "tcp", httpProxy, 15*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("Can't connect to the proxy: %s", err)
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if auth != nil {
+			req.SetBasicAuth(auth.User, auth.Password)
+			req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+			req.Header.Del("Authorization")
+		}
+
+		c.SetDeadline(time.Now().Add(15 * time.Second))
+		if err := req.Write(c); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("Error sending CONNECT request to the proxy: %s", err)
+		}
+
+		br := bufio.NewReader(c)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("Error reading CONNECT response from the proxy: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			c.Close()
+			return nil, fmt.Errorf("Proxy refused connection to %s: %s", addr, resp.Status)
+		}
+		c.SetDeadline(time.Time{})
+
+		if br.Buffered() > 0 {
+			// The proxy may already have relayed data from the host, which
+			// must not get lost in our buffer.
+			return &bufferedConn{Conn: c, r: br}, nil
+		}
+		return c, nil
+	}
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
 // BastionConnectFunc is a convenience method for returning a function
 // that connects to a host over a bastion connection.
 func BastionConnectFunc(
@@ -59,37 +113,87 @@ func BastionConnectFunc(
 	bConf *ssh.ClientConfig,
 	proto string,
 	addr string) func() (net.Conn, error) {
+	return BastionChainConnectFunc([]BastionHop{
+		{Proto: bProto, Addr: bAddr, Config: bConf},
+	}, proto, addr)
+}
+
+// BastionHop describes one of the jump hosts a connection goes through.
+type BastionHop struct {
+	Proto  string
+	Addr   string
+	Config *ssh.ClientConfig
+}
+
+// BastionChainConnectFunc is a convenience method for returning a function
+// that connects to a host through a chain of bastion hosts, each one being
+// reached through the previous one, like OpenSSH's ProxyJump.
+func BastionChainConnectFunc(hops []BastionHop, proto string, addr string) func() (net.Conn, error) {
 	return func() (net.Conn, error) {
-		// Connect to the bastion
-		bastion, err := ssh.Dial(bProto, bAddr, bConf)
-		if err != nil {
-			return nil, fmt.Errorf("Error connecting to bastion: %s", err)
+		var clients []*ssh.Client
+		closeAll := func() {
+			for i := len(clients) - 1; i >= 0; i-- {
+				clients[i].Close()
+			}
+		}
+
+		for i, hop := range hops {
+			var client *ssh.Client
+			if i == 0 {
+				// Connect to the first bastion
+				c, err := ssh.Dial(hop.Proto, hop.Addr, hop.Config)
+				if err != nil {
+					return nil, fmt.Errorf("Error connecting to bastion: %s", err)
+				}
+				client = c
+			} else {
+				// Connect to the next bastion through the previous one
+				conn, err := clients[i-1].Dial(hop.Proto, hop.Addr)
+				if err != nil {
+					closeAll()
+					return nil, fmt.Errorf("Error connecting to bastion %s: %s", hop.Addr, err)
+				}
+				sshConn, chans, reqs, err := ssh.NewClientConn(conn, hop.Addr, hop.Config)
+				if err != nil {
+					conn.Close()
+					closeAll()
+					return nil, fmt.Errorf("Error connecting to bastion %s: %s", hop.Addr, err)
+				}
+				client = ssh.NewClient(sshConn, chans, reqs)
+			}
+			clients = append(clients, client)
+			log.Printf("[DEBUG] connected to bastion host %s", hop.Addr)
 		}
 
-		log.Println("[DEBUG] connected to bastion host")
 		log.Println("[DEBUG] attempting connection to destination host")
 
 		// Connect through to the end host
-		conn, err := bastion.Dial(proto, addr)
+		conn, err := clients[len(clients)-1].Dial(proto, addr)
 		if err != nil {
-			bastion.Close()
+			closeAll()
 			return nil, err
 		}
 
-		// Wrap it up so we close both things properly
+		// Wrap it up so we close all the things properly
 		return &bastionConn{
-			Conn:    conn,
-			Bastion: bastion,
+			Conn:     conn,
+			Bastions: clients,
 		}, nil
 	}
 }
 
 type bastionConn struct {
 	net.Conn
-	Bastion *ssh.Client
+	Bastions []*ssh.Client
 }
 
 func (c *bastionConn) Close() error {
 	c.Conn.Close()
-	return c.Bastion.Close()
+	var err error
+	for i := len(c.Bastions) - 1; i >= 0; i-- {
+		if cerr := c.Bastions[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
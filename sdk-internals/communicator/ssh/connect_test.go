@@ -4,16 +4,22 @@
 package ssh_test
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/user"
 	"testing"
 	"time"
 
 	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	packerssh "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
 )
 
 func getIdentityCertFile() (certSigner ssh.Signer, err error) {
@@ -74,3 +80,72 @@ func TestConnectFunc(t *testing.T) {
 		fmt.Println(stdoutBuf.String())
 	}
 }
+
+func TestHTTPProxyConnectFunc(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect || req.Host != "10.0.0.1:22" {
+			fmt.Fprint(c, "HTTP/1.1 400 Bad Request\r\n\r\n")
+			return
+		}
+		if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			fmt.Fprint(c, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+		// Send the SSH banner along with the response, as a proxy may do
+		fmt.Fprint(c, "HTTP/1.1 200 Connection established\r\n\r\nSSH-2.0-test\r\n")
+	}()
+
+	auth := &proxy.Auth{User: "user", Password: "pass"}
+	c, err := packerssh.HTTPProxyConnectFunc(l.Addr().String(), auth, "tcp", "10.0.0.1:22")()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer c.Close()
+
+	banner, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(banner) != "SSH-2.0-test\r\n" {
+		t.Fatalf("unexpected data %q", banner)
+	}
+}
+
+func TestHTTPProxyConnectFunc_refused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, err := http.ReadRequest(bufio.NewReader(c)); err == nil {
+			fmt.Fprint(c, "HTTP/1.1 403 Forbidden\r\n\r\n")
+		}
+	}()
+
+	if _, err := packerssh.HTTPProxyConnectFunc(l.Addr().String(), nil, "tcp", "10.0.0.1:22")(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -5,6 +5,8 @@ package ssh
 
 import (
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -31,3 +33,76 @@ func (c *timeoutConn) Write(b []byte) (int, error) {
 	}
 	return c.Conn.Write(b)
 }
+
+// monitoredConn wraps a net.Conn, recording when data was last read from it.
+// It can be closed with the error explaining why, which is then returned by
+// every read in place of the error of the closed connection.
+type monitoredConn struct {
+	net.Conn
+
+	// lastRead is the time of the last read, in Unix nanoseconds. It must
+	// be accessed atomically.
+	lastRead int64
+	// received is set to 1 once data was read. It must be accessed
+	// atomically.
+	received int32
+
+	once   sync.Once
+	err    error
+	closed chan struct{}
+}
+
+func newMonitoredConn(conn net.Conn) *monitoredConn {
+	return &monitoredConn{
+		Conn:     conn,
+		lastRead: time.Now().UnixNano(),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (c *monitoredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+		atomic.StoreInt32(&c.received, 1)
+	}
+	if err != nil {
+		if lost := c.Err(); lost != nil {
+			err = lost
+		}
+	}
+	return n, err
+}
+
+func (c *monitoredConn) Close() error {
+	return c.kill(nil)
+}
+
+// kill closes the connection because of err.
+func (c *monitoredConn) kill(err error) error {
+	c.once.Do(func() {
+		c.err = err
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
+
+// Err returns the error the connection was closed with, if any.
+func (c *monitoredConn) Err() error {
+	select {
+	case <-c.closed:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// hasReceived reports whether any data was read.
+func (c *monitoredConn) hasReceived() bool {
+	return atomic.LoadInt32(&c.received) == 1
+}
+
+// idle returns how long ago data was last read.
+func (c *monitoredConn) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&c.lastRead)))
+}
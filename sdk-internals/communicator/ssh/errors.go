@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuthFailedError is returned from New() when the server rejected every
+// authentication method. This can be transient while the machine boots, for
+// example until cloud-init installed the authorized keys.
+type AuthFailedError struct {
+	Err error
+}
+
+func (e *AuthFailedError) Error() string {
+	return fmt.Sprintf("SSH authentication failed: %s", e.Err)
+}
+
+func (e *AuthFailedError) Unwrap() error { return e.Err }
+
+// HostUnreachableError is returned from New() when the connection to the
+// host, or to the bastion or proxy in front of it, could not be established.
+type HostUnreachableError struct {
+	Address string
+	Err     error
+}
+
+func (e *HostUnreachableError) Error() string {
+	return fmt.Sprintf("SSH host %s is unreachable: %s", e.Address, e.Err)
+}
+
+func (e *HostUnreachableError) Unwrap() error { return e.Err }
+
+// HandshakeTimeoutError is returned from New() when the server identified
+// itself, but the handshake did not complete in time.
+type HandshakeTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *HandshakeTimeoutError) Error() string {
+	return fmt.Sprintf("%s after %s", ErrHandshakeTimeout, e.Timeout)
+}
+
+func (e *HandshakeTimeoutError) Is(target error) bool { return target == ErrHandshakeTimeout }
+
+// BannerDelayError is returned from New() when the server accepted the
+// connection but did not identify itself in time, as happens while sshd
+// starts or when it throttles new connections.
+type BannerDelayError struct {
+	Timeout time.Duration
+}
+
+func (e *BannerDelayError) Error() string {
+	return fmt.Sprintf("%s: no SSH banner received after %s", ErrHandshakeTimeout, e.Timeout)
+}
+
+func (e *BannerDelayError) Is(target error) bool { return target == ErrHandshakeTimeout }
+
+// classifyHandshakeError returns the typed error for an error of the
+// handshake. golang.org/x/crypto/ssh has no type for authentication
+// failures, which are recognized from their message.
+func classifyHandshakeError(err error) error {
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return &AuthFailedError{Err: err}
+	}
+	return err
+}
+
+// IsTransient reports whether a connection error returned from New() is
+// likely to go away by itself, such as network errors while the machine
+// boots, in which case connecting should be retried until a timeout. Other
+// errors, such as authentication failures, should only be retried a limited
+// number of times.
+func IsTransient(err error) bool {
+	var authErr *AuthFailedError
+	return !errors.As(err, &authErr)
+}
@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"log"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/pathmatch"
+)
+
+// SymlinkPolicy controls how symbolic links are handled by UploadDir.
+type SymlinkPolicy string
+
+const (
+	// SymlinkFollow uploads the file or directory a symbolic link points to,
+	// skipping links whose target does not exist. This is the default.
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkPreserve uploads symbolic links as links. scp can't create
+	// links, so it follows them instead.
+	SymlinkPreserve SymlinkPolicy = "preserve"
+	// SymlinkSkip leaves symbolic links out of the upload.
+	SymlinkSkip SymlinkPolicy = "skip"
+)
+
+// uploadDirEntry applies the exclude patterns and the symlink policy to the
+// entry at path, rel being its path relative to the uploaded directory and fi
+// its Lstat info. It returns the info of what to upload, which is the info of
+// the target of followed links, or nil if the entry is skipped.
+func uploadDirEntry(path string, rel string, fi os.FileInfo, excl []string, policy SymlinkPolicy) (os.FileInfo, error) {
+	if pathmatch.Excluded(rel, excl) {
+		log.Printf("[DEBUG] Excluding: %s", path)
+		return nil, nil
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return fi, nil
+	}
+
+	switch policy {
+	case SymlinkSkip:
+		log.Printf("[DEBUG] Skipping symlink: %s", path)
+		return nil, nil
+	case SymlinkPreserve:
+		return fi, nil
+	}
+	target, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		log.Printf("[WARN] Skipping dangling symlink: %s", path)
+		return nil, nil
+	}
+	return target, err
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ packersdk.PortForwarder = new(comm)
+
+// portForward serves the connections accepted by its listener until it is
+// closed.
+type portForward struct {
+	listener net.Listener
+	done     chan struct{}
+	once     sync.Once
+}
+
+// startPortForward serves the connections accepted by listener with dialer,
+// until the forward is closed or client disconnects.
+func startPortForward(client *ssh.Client, listener net.Listener, dialer func() (net.Conn, error)) *portForward {
+	f := &portForward{
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go ProxyServe(listener, f.done, dialer)
+	go func() {
+		client.Wait()
+		f.Close()
+	}()
+	return f
+}
+
+func (f *portForward) Addr() net.Addr {
+	return f.listener.Addr()
+}
+
+func (f *portForward) Close() error {
+	var err error
+	f.once.Do(func() {
+		log.Printf("[INFO] Forward: closing listener on %s", f.listener.Addr())
+		close(f.done)
+		err = f.listener.Close()
+	})
+	return err
+}
+
+func (c *comm) ForwardLocal(localAddr string, remoteAddr string) (packersdk.PortForward, error) {
+	client := c.currentClient()
+	if client == nil {
+		return nil, errors.New("client not available")
+	}
+
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, fmt.Errorf("Forward: failed to bind local %s: %s", localAddr, err)
+	}
+	log.Printf("[INFO] Forward: local %s forwarding to remote %s", listener.Addr(), remoteAddr)
+	return startPortForward(client, listener, func() (net.Conn, error) {
+		// This Dial occurs on the SSH server's side
+		return client.Dial("tcp", remoteAddr)
+	}), nil
+}
+
+func (c *comm) ForwardRemote(remoteAddr string, localAddr string) (packersdk.PortForward, error) {
+	client := c.currentClient()
+	if client == nil {
+		return nil, errors.New("client not available")
+	}
+
+	// This requests the sshd host to bind a port and send traffic back to us
+	listener, err := client.Listen("tcp", remoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("Forward: failed to bind remote %s: %s", remoteAddr, err)
+	}
+	log.Printf("[INFO] Forward: remote %s forwarding to local %s", listener.Addr(), localAddr)
+	return startPortForward(client, listener, ConnectFunc("tcp", localAddr)), nil
+}
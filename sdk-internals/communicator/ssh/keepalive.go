@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ConnectionLostError is returned when the connection was found dead, either
+// because the server stopped answering keepalives or because nothing was
+// received from it within the inactivity timeout.
+type ConnectionLostError struct {
+	Reason string
+}
+
+func (e *ConnectionLostError) Error() string {
+	return fmt.Sprintf("SSH connection lost: %s", e.Reason)
+}
+
+// monitorConnection sends keepalives on the connection of client, and closes
+// conn with a ConnectionLostError once it is found dead. It returns when conn
+// is closed.
+func (c *comm) monitorConnection(client *ssh.Client, conn *monitoredConn) {
+	interval := c.config.KeepAliveInterval
+	if interval <= 0 {
+		if c.config.InactivityTimeout <= 0 {
+			return
+		}
+		// Without keepalives, only the inactivity timeout is checked.
+		interval = c.config.InactivityTimeout / 4
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending int32
+	missed := 0
+	for {
+		select {
+		case <-conn.closed:
+			return
+		case <-ticker.C:
+		}
+
+		if timeout := c.config.InactivityTimeout; timeout > 0 && conn.idle() >= timeout {
+			c.connectionDead(conn, fmt.Sprintf("nothing received for %s", timeout))
+			return
+		}
+		if c.config.KeepAliveInterval <= 0 {
+			continue
+		}
+
+		// Only one keepalive is in flight at a time; every interval it
+		// remains unanswered counts as a missed one.
+		if atomic.LoadInt32(&pending) == 1 {
+			missed++
+			log.Printf("[DEBUG] %d keepalives unanswered", missed)
+			if max := c.config.KeepAliveCountMax; max > 0 && missed >= max {
+				c.connectionDead(conn, fmt.Sprintf("%d keepalives went unanswered", missed))
+				return
+			}
+			continue
+		}
+		missed = 0
+		atomic.StoreInt32(&pending, 1)
+		go func() {
+			// Servers reply to unknown requests with a failure, which is
+			// as good a sign of life as a success.
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+				atomic.StoreInt32(&pending, 0)
+			}
+		}()
+	}
+}
+
+func (c *comm) connectionDead(conn *monitoredConn, reason string) {
+	err := &ConnectionLostError{Reason: reason}
+	log.Printf("[ERROR] %s, closing it", err)
+	conn.kill(err)
+}
+
+// connectionLost returns the ConnectionLostError of the current connection
+// in place of err, when the connection was found dead. Exit statuses are
+// always returned as is.
+func (c *comm) connectionLost(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ssh.ExitError); ok {
+		return err
+	}
+
+	c.mu.Lock()
+	conn := c.monitor
+	c.mu.Unlock()
+	if conn != nil {
+		if lost := conn.Err(); lost != nil {
+			return lost
+		}
+	}
+	return err
+}
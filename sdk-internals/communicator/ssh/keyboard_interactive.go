@@ -4,8 +4,10 @@
 package ssh
 
 import (
+	"fmt"
 	"io"
 	"log"
+	"regexp"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/terminal"
@@ -34,3 +36,40 @@ func KeyboardInteractive(c io.ReadWriter) ssh.KeyboardInteractiveChallenge {
 		return answers, nil
 	}
 }
+
+// KeyboardInteractiveAnswer is the answer to the keyboard-interactive
+// questions matching Prompt.
+type KeyboardInteractiveAnswer struct {
+	Prompt *regexp.Regexp
+	Answer string
+}
+
+// ScriptedKeyboardInteractive answers each keyboard-interactive question with
+// the first answer whose prompt matches it. Questions without a matching
+// answer are passed to ask, and fail the authentication when ask is nil.
+func ScriptedKeyboardInteractive(answers []KeyboardInteractiveAnswer, ask func(question string) (string, error)) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		log.Printf("[INFO] Keyboard interactive challenge for %s: %s", user, instruction)
+		replies := make([]string, len(questions))
+	Questions:
+		for i, question := range questions {
+			for _, answer := range answers {
+				if answer.Prompt.MatchString(question) {
+					log.Printf("[INFO] -- Question %d: %s (scripted)", i+1, question)
+					replies[i] = answer.Answer
+					continue Questions
+				}
+			}
+			if ask == nil {
+				return nil, fmt.Errorf("no answer for keyboard-interactive question %q", question)
+			}
+			log.Printf("[INFO] -- Question %d: %s", i+1, question)
+			reply, err := ask(question)
+			if err != nil {
+				return nil, fmt.Errorf("Error asking keyboard-interactive question %q: %s", question, err)
+			}
+			replies[i] = reply
+		}
+		return replies, nil
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -90,3 +91,31 @@ func TestKeyboardInteractive(t *testing.T) {
 		})
 	}
 }
+
+func TestScriptedKeyboardInteractive(t *testing.T) {
+	answers := []KeyboardInteractiveAnswer{
+		{Prompt: regexp.MustCompile(`(?i)^password`), Answer: "secret"},
+		{Prompt: regexp.MustCompile(`(?i)code`), Answer: "123456"},
+	}
+	questions := []string{"Password: ", "Verification code: ", "Favorite color? "}
+
+	var asked []string
+	ask := func(question string) (string, error) {
+		asked = append(asked, question)
+		return "blue", nil
+	}
+	got, err := ScriptedKeyboardInteractive(answers, ask)("user", "", questions, make([]bool, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"secret", "123456", "blue"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected answers %v, got %v", expected, got)
+	}
+	if !reflect.DeepEqual(asked, questions[2:]) {
+		t.Fatalf("expected to be asked %v, got %v", questions[2:], asked)
+	}
+
+	if _, err := ScriptedKeyboardInteractive(answers, nil)("user", "", questions, make([]bool, 3)); err == nil {
+		t.Fatal("expected an error for a question without answer")
+	}
+}
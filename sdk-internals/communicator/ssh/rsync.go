@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// rsyncHost is the host name given to the local rsync. It is never resolved,
+// as the remote shell relays to the established SSH connection instead.
+const rsyncHost = "packer"
+
+// rsyncShell is the remote shell run by the local rsync. Instead of opening a
+// new connection, it writes the remote command and then the data sent by
+// rsync to the "in" FIFO, and relays what is read from the "out" FIFO back to
+// rsync. The communicator runs the command in a session of its connection,
+// connecting the session to the FIFOs.
+const rsyncShell = `#!/bin/sh
+# The first argument is the host name, the others the remote command.
+shift
+exec 3>"$PACKER_RSYNC_DIR/in" 4<&0
+echo "$*" >&3
+cat <&4 >&3 &
+exec 3>&- 4<&-
+exec cat <"$PACKER_RSYNC_DIR/out"
+`
+
+// errRsyncUnavailable is returned when rsync can't be used for a transfer, in
+// which case scp or sftp is used instead.
+var errRsyncUnavailable = errors.New("rsync is not available")
+
+// rsyncUploadDir uploads src to dst with rsync. Like with the other transfer
+// methods, the directory src itself is created within dst unless src has a
+// trailing slash.
+func (c *comm) rsyncUploadDir(dst string, src string, excl []string) error {
+	args := c.rsyncArgs(excl)
+	switch c.config.SymlinkPolicy {
+	case SymlinkPreserve:
+		args = append(args, "--links")
+	case SymlinkSkip:
+		// Without --links, rsync skips symlinks.
+	default:
+		args = append(args, "--copy-links")
+	}
+	args = append(args, src, rsyncHost+":"+dst)
+	return c.rsync(args)
+}
+
+// rsyncDownloadDir downloads src into dst with rsync.
+func (c *comm) rsyncDownloadDir(src string, dst string, excl []string) error {
+	args := c.rsyncArgs(excl)
+	args = append(args, "--links", rsyncHost+":"+src, dst)
+	return c.rsync(args)
+}
+
+// rsyncArgs returns the options common to uploads and downloads. Ownership is
+// not preserved, matching scp and sftp.
+func (c *comm) rsyncArgs(excl []string) []string {
+	args := []string{"--recursive", "--perms", "--times", "--devices", "--specials", "--protect-args"}
+	if c.config.RsyncDelete {
+		args = append(args, "--delete")
+	}
+	for _, pattern := range excl {
+		args = append(args, "--exclude", pattern)
+	}
+	return args
+}
+
+// rsyncAvailable checks, once per communicator, that rsync exists both
+// locally and on the remote host, and that it can be relayed from this OS.
+func (c *comm) rsyncAvailable() error {
+	c.rsyncOnce.Do(func() {
+		if runtime.GOOS == "windows" {
+			c.rsyncErr = fmt.Errorf("%w on Windows", errRsyncUnavailable)
+			return
+		}
+		if _, err := exec.LookPath("rsync"); err != nil {
+			c.rsyncErr = fmt.Errorf("%w locally: %s", errRsyncUnavailable, err)
+			return
+		}
+
+		session, err := c.newSession()
+		if err != nil {
+			c.rsyncErr = err
+			return
+		}
+		defer session.Close()
+		if err := session.Run("command -v rsync"); err != nil {
+			c.rsyncErr = fmt.Errorf("%w on the remote host: %s", errRsyncUnavailable, err)
+		}
+	})
+	return c.rsyncErr
+}
+
+// rsync runs the local rsync with args, relaying its remote shell to a
+// session of the established connection.
+func (c *comm) rsync(args []string) error {
+	dir, err := tmp.Dir("packer-rsync")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	shell, err := rsyncTransport(dir)
+	if err != nil {
+		return err
+	}
+	inPath, outPath := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("rsync", append([]string{"--rsh", shell}, args...)...)
+	cmd.Env = append(os.Environ(), "PACKER_RSYNC_DIR="+dir)
+	cmd.Stderr = &stderr
+
+	log.Printf("[DEBUG] Starting local rsync process: %s", strings.Join(cmd.Args, " "))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	relayErr := make(chan error, 1)
+	go func() {
+		relayErr <- c.rsyncRelay(inPath, outPath)
+	}()
+
+	err = cmd.Wait()
+	// Opening a FIFO blocks until its other end is opened, which never
+	// happens if rsync exited before starting its remote shell.
+	for _, path := range []string{inPath, outPath} {
+		if f, err := os.OpenFile(path, os.O_RDWR, 0); err == nil {
+			f.Close()
+		}
+	}
+	if rerr := <-relayErr; rerr != nil && err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return fmt.Errorf("rsync failed: %s\nStderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// rsyncRelay runs the remote command written to the in FIFO by the remote
+// shell, and connects the session to the FIFOs.
+func (c *comm) rsyncRelay(inPath, outPath string) error {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	command, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("Error reading remote rsync command: %s", err)
+	}
+
+	out, err := os.OpenFile(outPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Stdin is copied apart from the session so that waiting on the session
+	// does not wait for the local rsync to close the remote shell.
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		io.Copy(stdin, c.limiter.Reader(r))
+		stdin.Close()
+	}()
+
+	var stderr bytes.Buffer
+	session.Stdout = c.limiter.Writer(out)
+	session.Stderr = &stderr
+
+	command = strings.TrimSuffix(command, "\n")
+	log.Printf("[DEBUG] Starting remote rsync process: %s", command)
+	if err := session.Run(command); err != nil {
+		return fmt.Errorf("remote rsync failed: %s\nStderr: %s", err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+// +build !windows
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// rsyncTransport creates the FIFOs and the remote shell script used to relay
+// rsync through the SSH connection in dir, and returns the script path.
+func rsyncTransport(dir string) (string, error) {
+	for _, name := range []string{"in", "out"} {
+		if err := syscall.Mkfifo(filepath.Join(dir, name), 0600); err != nil {
+			return "", err
+		}
+	}
+
+	shell := filepath.Join(dir, "rsh")
+	if err := os.WriteFile(shell, []byte(rsyncShell), 0700); err != nil {
+		return "", err
+	}
+	return shell, nil
+}
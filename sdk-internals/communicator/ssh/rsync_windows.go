@@ -0,0 +1,15 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+// +build windows
+
+package ssh
+
+import "fmt"
+
+// rsyncTransport is not supported on Windows, where the transfer falls back
+// to scp or sftp.
+func rsyncTransport(dir string) (string, error) {
+	return "", fmt.Errorf("%w on Windows", errRsyncUnavailable)
+}
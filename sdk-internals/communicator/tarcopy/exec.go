@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tarcopy
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// CopyIn runs cmd, which extracts the archive written by write from its
+// standard input. The error of cmd includes its standard error.
+func CopyIn(cmd *exec.Cmd, write func(*tar.Writer) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := write(tw)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	var stderr bytes.Buffer
+	cmd.Stdin = pr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s\nStderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// CopyOut runs cmd, which writes an archive to its standard output, passing
+// the archive to read. The error of cmd includes its standard error.
+func CopyOut(cmd *exec.Cmd, read func(*tar.Reader) error) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	readErr := read(tar.NewReader(stdout))
+	// Drain the archive so that cmd doesn't block writing it.
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s\nStderr: %s", err, stderr.String())
+	}
+	return readErr
+}
@@ -0,0 +1,271 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package tarcopy copies files and directories to and from a machine as tar
+// archives, for the communicators whose transport runs tar or accepts
+// archives, such as docker cp.
+package tarcopy
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/pathmatch"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// Name returns the name in the archives extracted at the root of the machine
+// of its path p.
+func Name(p string) string {
+	return strings.TrimPrefix(path.Join("/", p), "/")
+}
+
+// WriteFile writes the content of r to tw, as the file of the machine dst.
+// The content is spooled to a temporary file, as its size is written first.
+func WriteFile(tw *tar.Writer, dst string, r io.Reader, mode int64) error {
+	tf, err := tmp.File("packer-tarcopy")
+	if err != nil {
+		return fmt.Errorf("Error preparing upload: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	defer tf.Close()
+	size, err := io.Copy(tf, r)
+	if err != nil {
+		return err
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     Name(dst),
+		Mode:     mode,
+		Size:     size,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, tf)
+	return err
+}
+
+// ReadFile writes the content of the first entry of tr, the archive of the
+// file src, to w.
+func ReadFile(tr *tar.Reader, src string, w io.Writer) error {
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return fmt.Errorf("%s not found", src)
+	}
+	if err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+	case tar.TypeDir:
+		return fmt.Errorf("%s is a directory, use DownloadDir to download it", src)
+	default:
+		return fmt.Errorf("%s isn't a regular file", src)
+	}
+	_, err = io.Copy(w, tr)
+	return err
+}
+
+// WriteTree writes the directory src to tw for the directory dst of the
+// machine. Following rsync(1), the directory src itself is created within
+// dst unless src has a trailing slash. The files are owned by root.
+func WriteTree(tw *tar.Writer, src string, dst string, exclude []string) error {
+	prefix := dst
+	if !strings.HasSuffix(src, "/") {
+		prefix = path.Join(dst, filepath.Base(src))
+	}
+
+	return filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && pathmatch.Excluded(rel, exclude) {
+			log.Printf("Excluding: %s", p)
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := Name(path.Join(prefix, filepath.ToSlash(rel)))
+		if name == "" {
+			// The root of the machine already exists.
+			return nil
+		}
+
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// ExtractTree extracts the archive of a directory of the machine into dst,
+// skipping the directory itself when contents is set. Entries outside of the
+// directory, entries written through a symlink and symlinks pointing outside
+// of the directory are rejected.
+func ExtractTree(tr *tar.Reader, dst string, contents bool, exclude []string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	var excludedDirs []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("archive entry %s is outside of the directory", hdr.Name)
+		}
+		// Exclusions are relative to the downloaded directory.
+		root, rel := name, ""
+		if i := strings.Index(name, "/"); i >= 0 {
+			root, rel = name[:i], name[i+1:]
+		}
+		if contents {
+			if rel == "" {
+				continue
+			}
+			name = rel
+		}
+		if rel != "" && (pathmatch.Excluded(rel, exclude) || hasPrefix(rel, excludedDirs)) {
+			log.Printf("Excluding: %s", path.Join(root, rel))
+			if hdr.Typeflag == tar.TypeDir {
+				excludedDirs = append(excludedDirs, rel)
+			}
+			continue
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		mode := hdr.FileInfo().Mode().Perm()
+		if err := checkParents(dst, name); err != nil {
+			return fmt.Errorf("archive entry %s: %s", hdr.Name, err)
+		}
+		// An existing symlink is replaced rather than followed.
+		if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			link := filepath.ToSlash(hdr.Linkname)
+			resolved := path.Join(path.Dir(name), link)
+			if path.IsAbs(link) || filepath.IsAbs(hdr.Linkname) || resolved == ".." || strings.HasPrefix(resolved, "../") {
+				return fmt.Errorf("archive entry %s links to %s, outside of the directory", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			log.Printf("Skipping %s, which isn't a file, a directory or a symlink", hdr.Name)
+		}
+	}
+}
+
+// checkParents returns an error when a parent directory of name, relative to
+// dst, is a symlink, which would be followed to write the entry.
+func checkParents(dst string, name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	p := dst
+	for _, elem := range strings.Split(dir, "/") {
+		p = filepath.Join(p, elem)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink, which isn't followed", p)
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// hasPrefix reports whether p is within one of the directories dirs.
+func hasPrefix(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
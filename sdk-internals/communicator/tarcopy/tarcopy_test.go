@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tarcopy
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractTree_outside(t *testing.T) {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "dir/../../evil", Mode: 0644, Size: 4})
+	tw.Write([]byte("evil"))
+	tw.Close()
+
+	err := ExtractTree(tar.NewReader(&b), t.TempDir(), false, nil)
+	if err == nil || !strings.Contains(err.Error(), "outside of the directory") {
+		t.Fatalf("expected an error for an entry outside of the directory, got %v", err)
+	}
+}
+
+func TestName(t *testing.T) {
+	for p, expected := range map[string]string{
+		"/tmp/script.sh": "tmp/script.sh",
+		"tmp/script.sh":  "tmp/script.sh",
+		"/":              "",
+	} {
+		if got := Name(p); got != expected {
+			t.Errorf("%s: expected %q, got %q", p, expected, got)
+		}
+	}
+}
+
+func TestExtractTree_symlink(t *testing.T) {
+	for name, entries := range map[string][]tar.Header{
+		"written through a symlink": {
+			{Typeflag: tar.TypeDir, Name: "d/", Mode: 0755},
+			{Typeflag: tar.TypeSymlink, Name: "d/l", Linkname: "."},
+			{Typeflag: tar.TypeReg, Name: "d/l/x", Mode: 0644},
+		},
+		"absolute link": {
+			{Typeflag: tar.TypeSymlink, Name: "d/l", Linkname: "/etc"},
+		},
+		"link outside": {
+			{Typeflag: tar.TypeSymlink, Name: "d/l", Linkname: "../../etc"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			tw := tar.NewWriter(&b)
+			for _, hdr := range entries {
+				hdr := hdr
+				tw.WriteHeader(&hdr)
+			}
+			tw.Close()
+
+			root := t.TempDir()
+			dst := filepath.Join(root, "dst")
+			err := ExtractTree(tar.NewReader(&b), dst, false, nil)
+			if err == nil {
+				t.Fatal("expected an error for the hostile archive")
+			}
+			if _, err := os.Lstat(filepath.Join(root, "x")); !os.IsNotExist(err) {
+				t.Fatalf("a file was written outside of the directory: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractTree_link(t *testing.T) {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "d/f", Mode: 0644, Size: 2})
+	tw.Write([]byte("ok"))
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "d/sub/l", Linkname: "../f"})
+	tw.Close()
+
+	dst := t.TempDir()
+	if err := ExtractTree(tar.NewReader(&b), dst, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dst, "d", "sub", "l"))
+	if err != nil || string(content) != "ok" {
+		t.Fatalf("expected the link to be extracted, got %q, %v", content, err)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package throttle limits the rate of communicator file transfers.
+package throttle
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter limits transfers to a number of bytes per second. The rate is
+// shared by all the readers and writers it wraps, so concurrent transfers
+// don't add up past the limit.
+//
+// A nil Limiter doesn't limit anything.
+type Limiter struct {
+	limiter *rate.Limiter
+}
+
+// New returns a Limiter allowing bytesPerSecond, or nil when bytesPerSecond
+// isn't positive.
+func New(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	// The burst is a second worth of transfer, which also bounds the size of
+	// what is read or written at once.
+	burst := bytesPerSecond
+	if burst > 1<<30 {
+		burst = 1 << 30
+	}
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst)),
+	}
+}
+
+// Reader returns r, limited to the rate of l.
+func (l *Limiter) Reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{r: r, l: l}
+}
+
+// Writer returns w, limited to the rate of l.
+func (l *Limiter) Writer(w io.Writer) io.Writer {
+	if l == nil {
+		return w
+	}
+	return &writer{w: w, l: l}
+}
+
+// WriteCloser returns w, limited to the rate of l.
+func (l *Limiter) WriteCloser(w io.WriteCloser) io.WriteCloser {
+	if l == nil {
+		return w
+	}
+	return struct {
+		io.Writer
+		io.Closer
+	}{l.Writer(w), w}
+}
+
+// wait blocks until n bytes can be transferred. n must not exceed the burst.
+func (l *Limiter) wait(n int) {
+	if n > 0 {
+		// WaitN only fails when n exceeds the burst or the context is done,
+		// neither of which can happen here.
+		_ = l.limiter.WaitN(context.Background(), n)
+	}
+}
+
+func (l *Limiter) chunk(p []byte) []byte {
+	if burst := l.limiter.Burst(); len(p) > burst {
+		return p[:burst]
+	}
+	return p
+}
+
+type reader struct {
+	r io.Reader
+	l *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(r.l.chunk(p))
+	r.l.wait(n)
+	return n, err
+}
+
+type writer struct {
+	w io.Writer
+	l *Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := w.l.chunk(p)
+		w.l.wait(len(chunk))
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNew_unlimited(t *testing.T) {
+	if l := New(0); l != nil {
+		t.Fatalf("New(0) = %v, want nil", l)
+	}
+
+	var l *Limiter
+	r := bytes.NewReader(nil)
+	if l.Reader(r) != io.Reader(r) {
+		t.Fatal("nil Limiter wrapped the reader")
+	}
+	var b bytes.Buffer
+	if l.Writer(&b) != io.Writer(&b) {
+		t.Fatal("nil Limiter wrapped the writer")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	const rate = 4096
+	data := bytes.Repeat([]byte("x"), 3*rate)
+
+	tests := map[string]func(l *Limiter, dst io.Writer, src io.Reader) (int64, error){
+		"reader": func(l *Limiter, dst io.Writer, src io.Reader) (int64, error) {
+			return io.Copy(dst, l.Reader(src))
+		},
+		"writer": func(l *Limiter, dst io.Writer, src io.Reader) (int64, error) {
+			return io.Copy(l.Writer(dst), src)
+		},
+	}
+	for name, copyFunc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			start := time.Now()
+			n, err := copyFunc(New(rate), &out, bytes.NewReader(data))
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+				t.Fatalf("copied %d bytes, want %d", n, len(data))
+			}
+			// The first second is the burst, the rest is limited.
+			if elapsed < 1900*time.Millisecond {
+				t.Fatalf("copy took %s, want about 2s", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// CertificateSettings configures the client certificate authentication of a
+// WinRM client.
+type CertificateSettings struct {
+	// Cert and Key are the PEM encoded certificate of the client and its
+	// private key.
+	Cert []byte
+	Key  []byte
+	// Proxy selects the proxy of the requests, http.ProxyFromEnvironment if
+	// nil.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// ClientCertificate is a winrm.Transporter authenticating with a client
+// certificate, which the WinRM service maps to a local user. Unlike the
+// transport of the winrm package, it doesn't read the certificate from the
+// endpoint, so that the endpoints built by winrmcp can use it too.
+type ClientCertificate struct {
+	settings CertificateSettings
+	url      string
+	client   *http.Client
+}
+
+// certificateAuthorization is the Authorization header value asking the WinRM
+// service for certificate authentication.
+const certificateAuthorization = "http://schemas.dmtf.org/wbem/wsman/1/wsman/secprofile/https/mutual"
+
+// NewClientCertificate returns a client certificate transport using settings.
+func NewClientCertificate(settings *CertificateSettings) *ClientCertificate {
+	return &ClientCertificate{settings: *settings}
+}
+
+// Transport prepares the HTTP client of endpoint.
+func (c *ClientCertificate) Transport(endpoint *winrm.Endpoint) error {
+	if !endpoint.HTTPS {
+		return errors.New("client certificate authentication requires HTTPS")
+	}
+	cert, err := tls.X509KeyPair(c.settings.Cert, c.settings.Key)
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %w", err)
+	}
+
+	transport, err := newHTTPTransport(endpoint, c.settings.Proxy)
+	if err != nil {
+		return err
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	// The certificate may be requested once the request is known.
+	transport.TLSClientConfig.Renegotiation = tls.RenegotiateOnceAsClient
+
+	c.url = endpointURL(endpoint)
+	c.client = &http.Client{Transport: transport}
+	return nil
+}
+
+// Post sends request to the WinRM service.
+func (c *ClientCertificate) Post(_ *winrm.Client, request *soap.SoapMessage) (string, error) {
+	req, err := newSOAPRequest(c.url, request.String())
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", certificateAuthorization)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unknown error %w", err)
+	}
+	return soapResponse(resp)
+}
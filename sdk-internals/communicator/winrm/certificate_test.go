@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+func testClientCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "packer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestClientCertificate(t *testing.T) {
+	certPEM, keyPEM := testClientCertificate(t)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != certificateAuthorization {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if len(r.TLS.PeerCertificates) != 1 || r.TLS.PeerCertificates[0].Subject.CommonName != "packer" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		w.Write([]byte("<ok/>"))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	endpoint := &winrm.Endpoint{Host: u.Hostname(), Port: port, HTTPS: true, Insecure: true}
+
+	c := NewClientCertificate(&CertificateSettings{Cert: certPEM, Key: keyPEM})
+	if err := c.Transport(endpoint); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Post(nil, soap.NewMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "<ok/>" {
+		t.Fatalf("unexpected response %q", resp)
+	}
+
+	c = NewClientCertificate(&CertificateSettings{Cert: certPEM, Key: []byte("invalid")})
+	if err := c.Transport(endpoint); err == nil {
+		t.Fatal("expected an error with an invalid key")
+	}
+
+	c = NewClientCertificate(&CertificateSettings{Cert: certPEM, Key: keyPEM})
+	endpoint.HTTPS = false
+	if err := c.Transport(endpoint); err == nil {
+		t.Fatal("expected an error without HTTPS")
+	}
+}
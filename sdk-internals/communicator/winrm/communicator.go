@@ -7,6 +7,7 @@
 package winrm
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
@@ -18,22 +19,32 @@ import (
 	"strings"
 	"sync"
 
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/pathmatch"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/throttle"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/powershell"
 	"github.com/masterzen/winrm"
 	"github.com/packer-community/winrmcp/winrmcp"
 )
 
+var _ packersdk.SessionCommunicator = new(Communicator)
+
 // Communicator represents the WinRM communicator
 type Communicator struct {
 	config   *Config
 	client   *winrm.Client
 	endpoint *winrm.Endpoint
+	// limiter limits the rate of file transfers, when set.
+	limiter *throttle.Limiter
 }
 
 // New creates a new communicator implementation over WinRM.
 func New(config *Config) (*Communicator, error) {
+	// The host is used as is in the endpoint URL, which requires IPv6
+	// literals to be bracketed.
 	endpoint := &winrm.Endpoint{
-		Host:     config.Host,
+		Host:     packernet.URLHost(config.Host),
 		Port:     config.Port,
 		HTTPS:    config.Https,
 		Insecure: config.Insecure,
@@ -77,9 +88,21 @@ func New(config *Config) (*Communicator, error) {
 		config:   config,
 		client:   client,
 		endpoint: endpoint,
+		limiter:  throttle.New(config.TransferRateLimit),
 	}, nil
 }
 
+// NewSession returns a communicator with a client of its own, for the
+// provisioners run concurrently. The transfer rate limit is shared with c.
+func (c *Communicator) NewSession() (packersdk.Communicator, error) {
+	session, err := New(c.config)
+	if err != nil {
+		return nil, err
+	}
+	session.limiter = c.limiter
+	return session, nil
+}
+
 // Start implementation of communicator.Communicator interface
 func (c *Communicator) Start(ctx context.Context, rc *packersdk.RemoteCmd) error {
 	shell, err := c.client.CreateShell()
@@ -88,7 +111,12 @@ func (c *Communicator) Start(ctx context.Context, rc *packersdk.RemoteCmd) error
 	}
 
 	log.Printf("[INFO] starting remote command: %s", rc.Command)
-	cmd, err := shell.Execute(rc.Command)
+	command, err := c.command(rc.Command)
+	if err != nil {
+		shell.Close()
+		return err
+	}
+	cmd, err := shell.Execute(command)
 	if err != nil {
 		return err
 	}
@@ -97,6 +125,28 @@ func (c *Communicator) Start(ctx context.Context, rc *packersdk.RemoteCmd) error
 	return nil
 }
 
+// command returns the command line running command, as SYSTEM or in a
+// 64-bit process when configured to. The commands run as SYSTEM are run by
+// the Task Scheduler, which is a 64-bit process on 64-bit Windows.
+func (c *Communicator) command(command string) (string, error) {
+	switch {
+	case c.config.RunAsSystem:
+		return powershell.ElevatedRunner(scriptUploader{c}, c.config.Shell, powershell.SystemUser, "", command)
+	case c.config.Force64Bit:
+		return powershell.Native64BitCommand(c.config.Shell, command), nil
+	}
+	return command, nil
+}
+
+// scriptUploader uploads scripts without tracking their progress.
+type scriptUploader struct {
+	*Communicator
+}
+
+func (u scriptUploader) Upload(path string, input io.Reader, _ *os.FileInfo) error {
+	return u.upload(path, input)
+}
+
 func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packersdk.RemoteCmd) {
 	defer shell.Close()
 	var wg sync.WaitGroup
@@ -130,10 +180,6 @@ func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packersdk.RemoteCmd)
 
 // Upload implementation of communicator.Communicator interface
 func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) error {
-	wcp, err := c.newCopyClient()
-	if err != nil {
-		return fmt.Errorf("Was unable to create winrm client: %s", err)
-	}
 	if strings.HasSuffix(path, `\`) {
 		// path is a directory
 		if fi != nil {
@@ -143,7 +189,11 @@ func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) err
 		}
 	}
 	log.Printf("Uploading file to '%s'", path)
-	return wcp.Write(path, input)
+
+	tracked := packersdk.TrackedTransfer(c.config.ProgressTracker, path, 0, packersdk.TransferSize(fi), input)
+	defer tracked.Close()
+	input = tracked
+	return c.upload(path, c.limiter.Reader(input))
 }
 
 // UploadDir implementation of communicator.Communicator interface
@@ -152,31 +202,78 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 		dst = fmt.Sprintf("%s\\%s", dst, filepath.Base(src))
 	}
 	log.Printf("Uploading dir '%s' to '%s'", src, dst)
-	wcp, err := c.newCopyClient()
-	if err != nil {
-		return err
-	}
-	return wcp.Copy(src, dst)
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the macOS Finder metadata.
+		if fi.IsDir() || fi.Name() == ".DS_Store" {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Couldn't read file %s: %v", path, err)
+		}
+		defer f.Close()
+		return c.upload(filepath.Join(dst, rel), c.limiter.Reader(f))
+	})
 }
 
+// Download implementation of communicator.Communicator interface. src may be
+// a glob pattern matching a single file.
 func (c *Communicator) Download(src string, dst io.Writer) error {
-	client, err := c.newWinRMClient()
-	if err != nil {
-		return err
+	path := fmt.Sprintf(`"%s"`, src)
+	if pathmatch.HasGlobMeta(src) {
+		entries, err := c.list(src, false)
+		if err != nil {
+			return err
+		}
+		switch len(entries) {
+		case 0:
+			return fmt.Errorf("no file matches %s", src)
+		case 1:
+			if entries[0].dir {
+				return fmt.Errorf("%s matches the directory %s, use DownloadDir to download it", src, entries[0].root)
+			}
+			path = psQuote(entries[0].root)
+		default:
+			return fmt.Errorf("%s matches %d files, use DownloadDir to download them", src, len(entries))
+		}
 	}
+	return c.download(path, dst)
+}
 
-	encodeScript := `$file=[System.IO.File]::ReadAllBytes("%s"); Write-Output $([System.Convert]::ToBase64String($file))`
-
-	base64DecodePipe := &Base64Pipe{w: dst}
-
-	cmd := winrm.Powershell(fmt.Sprintf(encodeScript, src))
-	_, err = client.Run(cmd, base64DecodePipe, ioutil.Discard)
+// download writes the content of the file at path, a PowerShell expression,
+// to dst.
+func (c *Communicator) download(path string, dst io.Writer) error {
+	encodeScript := `$file=[System.IO.File]::ReadAllBytes(%s); Write-Output $([System.Convert]::ToBase64String($file))`
 
-	return err
+	base64DecodePipe := &Base64Pipe{w: c.limiter.Writer(dst)}
+	return c.runPowershell(fmt.Sprintf(encodeScript, path), base64DecodePipe)
 }
 
-func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
-	return fmt.Errorf("WinRM doesn't support download dir.")
+// runPowershell runs script, writing its output to stdout, and fails if the
+// script does.
+func (c *Communicator) runPowershell(script string, stdout io.Writer) error {
+	client, err := c.newWinRMClient()
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	code, err := client.Run(powershell.EncodedCommand(c.config.Shell, script), stdout, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("PowerShell exited with code %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	return nil
 }
 
 func (c *Communicator) getClientConfig() *winrmcp.Config {
@@ -193,12 +290,6 @@ func (c *Communicator) getClientConfig() *winrmcp.Config {
 	}
 }
 
-func (c *Communicator) newCopyClient() (*winrmcp.Winrmcp, error) {
-	addr := fmt.Sprintf("%s:%d", c.endpoint.Host, c.endpoint.Port)
-	clientConfig := c.getClientConfig()
-	return winrmcp.New(addr, clientConfig)
-}
-
 func (c *Communicator) newWinRMClient() (*winrm.Client, error) {
 	conf := c.getClientConfig()
 
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -93,22 +94,54 @@ func TestStart(t *testing.T) {
 	}
 }
 
-func TestUpload(t *testing.T) {
-	wrm := newMockWinRMServer(t)
-	defer wrm.Close()
+func TestStart_runAsSystem(t *testing.T) {
+	s, c := newFakeShellServer(t)
+	c.config.RunAsSystem = true
 
-	c, err := New(&Config{
-		Host:     wrm.Host,
-		Port:     wrm.Port,
-		Username: "user",
-		Password: "pass",
-		Timeout:  30 * time.Second,
-	})
-	if err != nil {
-		t.Fatalf("error creating communicator: %s", err)
+	cmd := packersdk.RemoteCmd{Command: "echo foo"}
+	if err := c.Start(context.Background(), &cmd); err != nil {
+		t.Fatalf("error executing remote command: %s", err)
+	}
+	cmd.Wait()
+
+	var script string
+	for path, b := range s.files {
+		if strings.HasPrefix(path, `C:\Windows\Temp\packer-elevated-shell-`) {
+			script = string(b)
+		}
 	}
+	if !strings.Contains(script, "<UserId>SYSTEM</UserId>") || !strings.Contains(script, "/c echo foo &gt;") {
+		t.Fatalf("unexpected elevated script:\n%s", script)
+	}
+	last := s.commands[strconv.Itoa(s.nextID)].line
+	if !strings.HasPrefix(last, `powershell -executionpolicy bypass -file "C:/Windows/Temp/packer-elevated-shell-`) {
+		t.Fatalf("unexpected command %s", last)
+	}
+}
+
+func TestStart_force64Bit(t *testing.T) {
+	s, c := newFakeShellServer(t)
+	c.config.Force64Bit = true
+
+	cmd := packersdk.RemoteCmd{Command: "echo 'foo'"}
+	if err := c.Start(context.Background(), &cmd); err != nil {
+		t.Fatalf("error executing remote command: %s", err)
+	}
+	cmd.Wait()
+
+	script := s.commands[strconv.Itoa(s.nextID)].script
+	if !strings.Contains(script, `$p.StartInfo.Arguments = '/c echo ''foo'''`) {
+		t.Fatalf("unexpected script:\n%s", script)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	// The uploads send the files on the standard input of their command,
+	// which winrmtest doesn't read.
+	_, c := newFakeShellServer(t)
+
 	file := "C:/Temp/packer.cmd"
-	err = c.Upload(file, strings.NewReader(PAYLOAD), nil)
+	err := c.Upload(file, strings.NewReader(PAYLOAD), nil)
 	if err != nil {
 		t.Fatalf("error uploading file: %s", err)
 	}
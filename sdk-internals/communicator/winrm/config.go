@@ -6,6 +6,7 @@ package winrm
 import (
 	"time"
 
+	getter "github.com/hashicorp/go-getter/v2"
 	"github.com/masterzen/winrm"
 )
 
@@ -19,4 +20,34 @@ type Config struct {
 	Https              bool
 	Insecure           bool
 	TransportDecorator func() winrm.Transporter
+
+	// ProgressTracker, if set, is given the progress of Upload transfers.
+	// packersdk.Ui implements it to display a progress bar.
+	ProgressTracker getter.ProgressTracker
+
+	// TransferRateLimit limits file transfers to that many bytes per second,
+	// shared by all the transfers. Zero doesn't limit them.
+	TransferRateLimit int64
+
+	// UploadChunkSize is the number of bytes of the files sent in each
+	// request of Upload. Zero uses the largest chunks fitting in the default
+	// envelope size.
+	UploadChunkSize int
+
+	// UploadCompression compresses the files with gzip before uploading
+	// them, decompressing them on the guest.
+	UploadCompression bool
+
+	// Shell is the PowerShell executable running the scripts of the file
+	// transfers, such as pwsh.exe or the path of a custom shell. Defaults to
+	// powershell.exe.
+	Shell string
+
+	// Force64Bit runs the commands of Start in a 64-bit process, even when
+	// Shell is a 32-bit PowerShell.
+	Force64Bit bool
+
+	// RunAsSystem runs the commands of Start as the local SYSTEM account, in
+	// a scheduled task.
+	RunAsSystem bool
 }
@@ -0,0 +1,403 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// CredSSPSettings configures the CredSSP authentication of a WinRM client.
+type CredSSPSettings struct {
+	// Username, which may be qualified by a domain as DOMAIN\user.
+	Username string
+	Password string
+	// Proxy selects the proxy of the requests, http.ProxyFromEnvironment if
+	// nil.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// ClientCredSSP is a winrm.Transporter authenticating with CredSSP, which
+// delegates the credentials to the remote host so that its commands can in
+// turn authenticate to other hosts of the domain.
+//
+// CredSSP runs a TLS session over the Authorization headers of the requests,
+// in which the client authenticates with NTLM and then sends the
+// credentials. The WinRM service only accepts CredSSP over HTTPS.
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-cssp
+type ClientCredSSP struct {
+	settings CredSSPSettings
+	url      string
+	client   *http.Client
+
+	// l serializes the requests, which must all go through the
+	// authenticated connection.
+	l             sync.Mutex
+	authenticated bool
+}
+
+// credSSPVersion is the version of the protocol spoken by the client, the
+// first one binding the public key of the server to a nonce.
+const credSSPVersion = 6
+
+const (
+	credSSPClientHashMagic = "CredSSP Client-To-Server Binding Hash\x00"
+	credSSPServerHashMagic = "CredSSP Server-To-Client Binding Hash\x00"
+)
+
+// NewClientCredSSP returns a CredSSP transport using settings.
+func NewClientCredSSP(settings *CredSSPSettings) *ClientCredSSP {
+	return &ClientCredSSP{settings: *settings}
+}
+
+// Transport prepares the HTTP client of endpoint.
+func (c *ClientCredSSP) Transport(endpoint *winrm.Endpoint) error {
+	if !endpoint.HTTPS {
+		return errors.New("CredSSP authentication requires HTTPS")
+	}
+	transport, err := newHTTPTransport(endpoint, c.settings.Proxy)
+	if err != nil {
+		return err
+	}
+	// The authentication is bound to the connection.
+	transport.MaxConnsPerHost = 1
+
+	c.url = endpointURL(endpoint)
+	c.client = &http.Client{Transport: transport}
+	return nil
+}
+
+// Post sends request to the WinRM service, authenticating first when the
+// connection isn't.
+func (c *ClientCredSSP) Post(_ *winrm.Client, request *soap.SoapMessage) (string, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	body := request.String()
+	if c.authenticated {
+		resp, err := c.do(body, nil)
+		if err != nil {
+			return "", fmt.Errorf("unknown error %w", err)
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			return soapResponse(resp)
+		}
+		// The connection was closed, and the new one must authenticate.
+		discardResponse(resp)
+		c.authenticated = false
+	}
+
+	resp, err := c.authenticate(body)
+	if err != nil {
+		return "", fmt.Errorf("CredSSP authentication failed: %w", err)
+	}
+	c.authenticated = true
+	return soapResponse(resp)
+}
+
+// do posts body, along with a CredSSP token unless it is nil.
+func (c *ClientCredSSP) do(body string, token []byte) (*http.Response, error) {
+	req, err := newSOAPRequest(c.url, body)
+	if err != nil {
+		return nil, err
+	}
+	if token != nil {
+		req.Header.Set("Authorization", "CredSSP "+base64.StdEncoding.EncodeToString(token))
+	}
+	return c.client.Do(req)
+}
+
+// authenticate runs the CredSSP exchange, returning the response to body which
+// is posted along with the last message.
+func (c *ClientCredSSP) authenticate(body string) (*http.Response, error) {
+	conn := &credSSPConn{
+		exchange: func(token []byte) (*http.Response, error) {
+			return c.do(body, token)
+		},
+	}
+	// The certificate of the service is authenticated by the public key
+	// binding below: like Windows, accept any.
+	tlsConn := tls.Client(conn, &tls.Config{
+		InsecureSkipVerify: true,
+		// The WinRM service doesn't support TLS 1.3 in CredSSP.
+		MaxVersion: tls.VersionTLS12,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	publicKey, err := subjectPublicKey(tlsConn.ConnectionState().PeerCertificates[0])
+	if err != nil {
+		return nil, err
+	}
+
+	n := newNTLM(c.settings.Username, c.settings.Password)
+	challenge, err := credSSPRoundTrip(tlsConn, &tsRequest{
+		Version:    credSSPVersion,
+		NegoTokens: []negoToken{{Token: n.negotiateMessage()}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(challenge.NegoTokens) == 0 {
+		return nil, errors.New("the server didn't send an NTLM challenge")
+	}
+	authenticate, err := n.authenticateMessage(challenge.NegoTokens[0].Token)
+	if err != nil {
+		return nil, err
+	}
+
+	version := challenge.Version
+	if version > credSSPVersion {
+		version = credSSPVersion
+	}
+	req := &tsRequest{
+		Version:    credSSPVersion,
+		NegoTokens: []negoToken{{Token: authenticate}},
+	}
+	var serverPubKeyAuth []byte
+	if version >= 5 {
+		nonce := make([]byte, 32)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		req.ClientNonce = nonce
+		req.PubKeyAuth = n.seal(sha256Sum(credSSPClientHashMagic, nonce, publicKey))
+		serverPubKeyAuth = sha256Sum(credSSPServerHashMagic, nonce, publicKey)
+	} else {
+		req.PubKeyAuth = n.seal(publicKey)
+		serverPubKeyAuth = append([]byte{}, publicKey...)
+		serverPubKeyAuth[0]++
+	}
+	resp, err := credSSPRoundTrip(tlsConn, req)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyAuth, err := n.unseal(resp.PubKeyAuth)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(pubKeyAuth, serverPubKeyAuth) {
+		return nil, errors.New("the server public key doesn't match the TLS certificate")
+	}
+
+	domain, user := "", c.settings.Username
+	if i := strings.Index(user, `\`); i != -1 {
+		domain, user = user[:i], user[i+1:]
+	}
+	passwordCreds, err := asn1.Marshal(tsPasswordCreds{
+		DomainName: ntlmUnicode(domain),
+		UserName:   ntlmUnicode(user),
+		Password:   ntlmUnicode(c.settings.Password),
+	})
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := asn1.Marshal(tsCredentials{CredType: 1, Credentials: passwordCreds})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTSRequest(tlsConn, &tsRequest{
+		Version:  credSSPVersion,
+		AuthInfo: n.seal(credentials),
+	}); err != nil {
+		return nil, err
+	}
+	return conn.flush()
+}
+
+// tsRequest is the message of the CredSSP protocol.
+type tsRequest struct {
+	Version     int         `asn1:"explicit,tag:0"`
+	NegoTokens  []negoToken `asn1:"explicit,optional,tag:1"`
+	AuthInfo    []byte      `asn1:"explicit,optional,tag:2"`
+	PubKeyAuth  []byte      `asn1:"explicit,optional,tag:3"`
+	ErrorCode   int         `asn1:"explicit,optional,tag:4"`
+	ClientNonce []byte      `asn1:"explicit,optional,tag:5"`
+}
+
+type negoToken struct {
+	Token []byte `asn1:"explicit,tag:0"`
+}
+
+type tsCredentials struct {
+	CredType    int    `asn1:"explicit,tag:0"`
+	Credentials []byte `asn1:"explicit,tag:1"`
+}
+
+type tsPasswordCreds struct {
+	DomainName []byte `asn1:"explicit,tag:0"`
+	UserName   []byte `asn1:"explicit,tag:1"`
+	Password   []byte `asn1:"explicit,tag:2"`
+}
+
+func writeTSRequest(w io.Writer, req *tsRequest) error {
+	b, err := asn1.Marshal(*req)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// maxTSRequest is the size of the largest TSRequest read from the servers.
+const maxTSRequest = 64 * 1024
+
+// readTSRequest reads a TSRequest from r, reading its DER header first, so
+// that the TSRequests split across several reads, or records of the TLS
+// session, are read whole.
+func readTSRequest(r io.Reader) (*tsRequest, error) {
+	header := make([]byte, 2, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		// The long form: the next length&0x7f bytes hold the length.
+		n := length & 0x7f
+		if n == 0 || n > 4 {
+			return nil, fmt.Errorf("invalid CredSSP message length")
+		}
+		header = header[:2+n]
+		if _, err := io.ReadFull(r, header[2:]); err != nil {
+			return nil, err
+		}
+		length = 0
+		for _, b := range header[2:] {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxTSRequest {
+		return nil, fmt.Errorf("CredSSP message of %d bytes is too long", length)
+	}
+	b := make([]byte, len(header)+length)
+	copy(b, header)
+	if _, err := io.ReadFull(r, b[len(header):]); err != nil {
+		return nil, err
+	}
+	var req tsRequest
+	if _, err := asn1.Unmarshal(b, &req); err != nil {
+		return nil, fmt.Errorf("invalid CredSSP message: %w", err)
+	}
+	if req.ErrorCode != 0 {
+		return nil, fmt.Errorf("the server returned the error 0x%08x", uint32(req.ErrorCode))
+	}
+	return &req, nil
+}
+
+// credSSPRoundTrip sends req over conn, returning the answer of the server.
+func credSSPRoundTrip(conn io.ReadWriter, req *tsRequest) (*tsRequest, error) {
+	if err := writeTSRequest(conn, req); err != nil {
+		return nil, err
+	}
+	return readTSRequest(conn)
+}
+
+// subjectPublicKey returns the public key of cert, without its algorithm.
+func subjectPublicKey(cert *x509.Certificate) ([]byte, error) {
+	var info struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &info); err != nil {
+		return nil, err
+	}
+	return info.PublicKey.Bytes, nil
+}
+
+func sha256Sum(magic string, data ...[]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(magic))
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// credSSPConn is the connection carrying the TLS session of CredSSP: writes
+// are buffered until the next read, which posts them as a token and returns
+// the token answered by the server.
+type credSSPConn struct {
+	exchange func(token []byte) (*http.Response, error)
+	out      bytes.Buffer
+	in       bytes.Buffer
+}
+
+func (c *credSSPConn) Read(b []byte) (int, error) {
+	if c.in.Len() == 0 {
+		resp, err := c.flush()
+		if err != nil {
+			return 0, err
+		}
+		token, err := credSSPToken(resp)
+		discardResponse(resp)
+		if err != nil {
+			return 0, err
+		}
+		c.in.Write(token)
+	}
+	return c.in.Read(b)
+}
+
+func (c *credSSPConn) Write(b []byte) (int, error) {
+	return c.out.Write(b)
+}
+
+// flush posts the buffered writes.
+func (c *credSSPConn) flush() (*http.Response, error) {
+	token := append([]byte{}, c.out.Bytes()...)
+	c.out.Reset()
+	return c.exchange(token)
+}
+
+func (c *credSSPConn) Close() error                       { return nil }
+func (c *credSSPConn) LocalAddr() net.Addr                { return credSSPAddr{} }
+func (c *credSSPConn) RemoteAddr() net.Addr               { return credSSPAddr{} }
+func (c *credSSPConn) SetDeadline(t time.Time) error      { return nil }
+func (c *credSSPConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *credSSPConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type credSSPAddr struct{}
+
+func (credSSPAddr) Network() string { return "credssp" }
+func (credSSPAddr) String() string  { return "credssp" }
+
+// credSSPToken returns the CredSSP token of the WWW-Authenticate header of
+// resp.
+func credSSPToken(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil, fmt.Errorf("unexpected http status %d", resp.StatusCode)
+	}
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(h, "CredSSP ") {
+			return base64.StdEncoding.DecodeString(strings.TrimPrefix(h, "CredSSP "))
+		}
+	}
+	return nil, errors.New("the server rejected the credentials, or doesn't allow CredSSP")
+}
+
+// discardResponse reads and closes the body of resp so that its connection
+// is reused.
+func discardResponse(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,365 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"crypto/tls"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// credSSPServer is a WinRM service authenticating with CredSSP, checking the
+// NTLM responses and the delegated credentials.
+type credSSPServer struct {
+	t                      *testing.T
+	user, domain, password string
+	tlsCert                tls.Certificate
+	publicKey              []byte
+
+	l     sync.Mutex
+	conns map[string]*credSSPServerConn
+	// requests counts the authenticated requests.
+	requests int
+}
+
+// credSSPServerConn runs the server side of the CredSSP exchange of a
+// connection in a goroutine. Tokens received are sent to in, and the tokens
+// to answer are received from out, until done is closed.
+type credSSPServerConn struct {
+	in      chan []byte
+	out     chan []byte
+	done    chan struct{}
+	err     error
+	pending []byte
+	written bytes.Buffer
+	started bool
+}
+
+func (c *credSSPServerConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if c.started {
+			c.out <- append([]byte{}, c.written.Bytes()...)
+			c.written.Reset()
+		}
+		c.started = true
+		c.pending = <-c.in
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *credSSPServerConn) Write(b []byte) (int, error) {
+	return c.written.Write(b)
+}
+
+func (c *credSSPServerConn) Close() error                       { return nil }
+func (c *credSSPServerConn) LocalAddr() net.Addr                { return credSSPAddr{} }
+func (c *credSSPServerConn) RemoteAddr() net.Addr               { return credSSPAddr{} }
+func (c *credSSPServerConn) SetDeadline(t time.Time) error      { return nil }
+func (c *credSSPServerConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *credSSPServerConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (s *credSSPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.l.Lock()
+	conn := s.conns[r.RemoteAddr]
+	s.l.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if conn != nil && conn.err == nil && auth == "" {
+		select {
+		case <-conn.done:
+			s.l.Lock()
+			s.requests++
+			s.l.Unlock()
+			w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+			w.Write(body)
+			return
+		default:
+		}
+	}
+	if !strings.HasPrefix(auth, "CredSSP ") {
+		w.Header().Set("WWW-Authenticate", "CredSSP")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	token, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "CredSSP "))
+	if err != nil {
+		s.t.Errorf("invalid token: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if conn == nil {
+		conn = &credSSPServerConn{
+			in:   make(chan []byte),
+			out:  make(chan []byte),
+			done: make(chan struct{}),
+		}
+		s.l.Lock()
+		s.conns[r.RemoteAddr] = conn
+		s.l.Unlock()
+		go func() {
+			conn.err = s.serve(conn)
+			close(conn.done)
+		}()
+	}
+
+	conn.in <- token
+	select {
+	case reply := <-conn.out:
+		w.Header().Set("WWW-Authenticate", "CredSSP "+base64.StdEncoding.EncodeToString(reply))
+		w.WriteHeader(http.StatusUnauthorized)
+	case <-conn.done:
+		if conn.err != nil {
+			s.t.Errorf("CredSSP exchange failed: %s", conn.err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.l.Lock()
+		s.requests++
+		s.l.Unlock()
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		w.Write(body)
+	}
+}
+
+func (s *credSSPServer) serve(conn *credSSPServerConn) error {
+	// The TLS session runs over the tokens rather than a network
+	// connection.
+	tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{s.tlsCert}})
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	publicKey := s.publicKey
+
+	req, err := readTSRequest(tlsConn)
+	if err != nil {
+		return err
+	}
+	negotiate := req.NegoTokens[0].Token
+
+	challenge := ntlmTestChallenge()
+	if err := writeTSRequest(tlsConn, &tsRequest{
+		Version:    credSSPVersion,
+		NegoTokens: []negoToken{{Token: challenge}},
+	}); err != nil {
+		return err
+	}
+
+	req, err = readTSRequest(tlsConn)
+	if err != nil {
+		return err
+	}
+	n, err := s.accept(negotiate, challenge, req.NegoTokens[0].Token)
+	if err != nil {
+		return err
+	}
+	clientHash, err := n.unseal(req.PubKeyAuth)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(clientHash, sha256Sum(credSSPClientHashMagic, req.ClientNonce, publicKey)) {
+		return errors.New("invalid client public key hash")
+	}
+	if err := writeTSRequest(tlsConn, &tsRequest{
+		Version:    credSSPVersion,
+		PubKeyAuth: n.seal(sha256Sum(credSSPServerHashMagic, req.ClientNonce, publicKey)),
+	}); err != nil {
+		return err
+	}
+
+	req, err = readTSRequest(tlsConn)
+	if err != nil {
+		return err
+	}
+	b, err := n.unseal(req.AuthInfo)
+	if err != nil {
+		return err
+	}
+	var creds tsCredentials
+	if _, err := asn1.Unmarshal(b, &creds); err != nil {
+		return err
+	}
+	var passwordCreds tsPasswordCreds
+	if _, err := asn1.Unmarshal(creds.Credentials, &passwordCreds); err != nil {
+		return err
+	}
+	if !bytes.Equal(passwordCreds.DomainName, ntlmUnicode(s.domain)) ||
+		!bytes.Equal(passwordCreds.UserName, ntlmUnicode(s.user)) ||
+		!bytes.Equal(passwordCreds.Password, ntlmUnicode(s.password)) {
+		return errors.New("invalid delegated credentials")
+	}
+	return nil
+}
+
+// accept checks the NTLM authenticate message, returning the server side of
+// the context.
+func (s *credSSPServer) accept(negotiate, challenge, authenticate []byte) (*ntlm, error) {
+	ntResponse, err := ntlmField(authenticate, 20)
+	if err != nil {
+		return nil, err
+	}
+	encryptedSessionKey, err := ntlmField(authenticate, 52)
+	if err != nil {
+		return nil, err
+	}
+	responseKey := ntowfv2(s.user, s.domain, s.password)
+	ntProofStr := hmacMD5(responseKey, challenge[24:32], ntResponse[16:])
+	if !bytes.Equal(ntProofStr, ntResponse[:16]) {
+		return nil, errors.New("invalid NTLM response")
+	}
+	exportedSessionKey := make([]byte, 16)
+	rc4K(hmacMD5(responseKey, ntProofStr), encryptedSessionKey, exportedSessionKey)
+
+	mic := append([]byte{}, authenticate[72:88]...)
+	copy(authenticate[72:88], make([]byte, 16))
+	if !bytes.Equal(mic, hmacMD5(exportedSessionKey, negotiate, challenge, authenticate)) {
+		return nil, errors.New("invalid NTLM MIC")
+	}
+
+	// The server seals with the server-to-client keys.
+	n := &ntlm{
+		clientSigningKey: md5Sum(exportedSessionKey, "session key to server-to-client signing key magic constant\x00"),
+		serverSigningKey: md5Sum(exportedSessionKey, "session key to client-to-server signing key magic constant\x00"),
+	}
+	n.clientSealing, _ = rc4.NewCipher(md5Sum(exportedSessionKey, "session key to server-to-client sealing key magic constant\x00"))
+	n.serverSealing, _ = rc4.NewCipher(md5Sum(exportedSessionKey, "session key to client-to-server sealing key magic constant\x00"))
+	return n, nil
+}
+
+// ntlmTestChallenge returns a challenge message with a timestamp, asking for
+// a MIC.
+func ntlmTestChallenge() []byte {
+	var targetInfo bytes.Buffer
+	binary.Write(&targetInfo, binary.LittleEndian, []uint16{2, 12})
+	targetInfo.Write(ntlmUnicode("Domain"))
+	binary.Write(&targetInfo, binary.LittleEndian, []uint16{ntlmAvTimestamp, 8})
+	binary.Write(&targetInfo, binary.LittleEndian, uint64(132500000000000000))
+	targetInfo.Write([]byte{0, 0, 0, 0})
+
+	b := make([]byte, 56)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], 2)
+	binary.LittleEndian.PutUint32(b[16:], 56)
+	binary.LittleEndian.PutUint32(b[20:], ntlmFlags)
+	copy(b[24:], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	binary.LittleEndian.PutUint16(b[40:], uint16(targetInfo.Len()))
+	binary.LittleEndian.PutUint16(b[42:], uint16(targetInfo.Len()))
+	binary.LittleEndian.PutUint32(b[44:], 56)
+	copy(b[48:], ntlmVersion)
+	return append(b, targetInfo.Bytes()...)
+}
+
+func TestClientCredSSP(t *testing.T) {
+	srv := &credSSPServer{
+		t:        t,
+		user:     "User",
+		domain:   "Domain",
+		password: "Password",
+		conns:    make(map[string]*credSSPServerConn),
+	}
+	ts := httptest.NewTLSServer(srv)
+	defer ts.Close()
+	srv.tlsCert = ts.TLS.Certificates[0]
+	var err error
+	srv.publicKey, err = subjectPublicKey(ts.Certificate())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	endpoint := &winrm.Endpoint{Host: u.Hostname(), Port: port, HTTPS: true, Insecure: true}
+
+	c := NewClientCredSSP(&CredSSPSettings{Username: `Domain\User`, Password: "Password"})
+	if err := c.Transport(endpoint); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		msg := soap.NewMessage()
+		msg.Header().To("test").Build()
+		resp, err := c.Post(nil, msg)
+		if err != nil {
+			t.Fatalf("request %d: %s", i, err)
+		}
+		if resp != msg.String() {
+			t.Fatalf("request %d: unexpected response %q", i, resp)
+		}
+	}
+	if srv.requests != 2 {
+		t.Fatalf("expected 2 authenticated requests, got %d", srv.requests)
+	}
+	if len(srv.conns) != 1 {
+		t.Fatalf("expected the requests to use 1 connection, got %d", len(srv.conns))
+	}
+
+	c = NewClientCredSSP(&CredSSPSettings{Username: `Domain\User`, Password: "Password"})
+	endpoint.HTTPS = false
+	if err := c.Transport(endpoint); err == nil {
+		t.Fatal("expected an error without HTTPS")
+	}
+}
+
+func TestTSRequest(t *testing.T) {
+	req := tsRequest{
+		Version:    credSSPVersion,
+		NegoTokens: []negoToken{{Token: []byte("NTLMSSP")}},
+	}
+	var buf bytes.Buffer
+	if err := writeTSRequest(&buf, &req); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readTSRequest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != credSSPVersion || len(got.NegoTokens) != 1 || string(got.NegoTokens[0].Token) != "NTLMSSP" ||
+		got.AuthInfo != nil || got.PubKeyAuth != nil || got.ClientNonce != nil {
+		t.Fatalf("unexpected request %#v", got)
+	}
+
+	// A request read in pieces, long enough for the long form of the length
+	long := tsRequest{Version: credSSPVersion, NegoTokens: []negoToken{{Token: bytes.Repeat([]byte("x"), 300)}}}
+	buf.Reset()
+	if err := writeTSRequest(&buf, &long); err != nil {
+		t.Fatal(err)
+	}
+	got, err = readTSRequest(iotest.OneByteReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.NegoTokens) != 1 || len(got.NegoTokens[0].Token) != 300 {
+		t.Fatalf("unexpected request %#v", got)
+	}
+
+	if _, err := readTSRequest(bytes.NewReader([]byte{0x30, 0x84, 0xff, 0xff, 0xff, 0xff})); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected a too long error, got %v", err)
+	}
+
+	b, _ := asn1.Marshal(tsRequest{Version: 6, ErrorCode: -1073741715})
+	if _, err := readTSRequest(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "0xc000006d") {
+		t.Fatalf("expected the error code of the server, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/pathmatch"
+)
+
+// listScript outputs the files and directories matching a path, and with
+// $recurse those of the matching directories, as a line per entry: its
+// type, D or F, then the base64 encoded full name of the match and path of
+// the entry relative to it, separated by tabs. The names are encoded so
+// that they survive the output encoding of the console.
+const listScript = `$ErrorActionPreference = 'Stop'
+$recurse = $%t
+function Encode($s) { [System.Convert]::ToBase64String([System.Text.Encoding]::UTF8.GetBytes($s)) }
+foreach ($root in @(Get-Item %s "%s" -Force)) {
+	$kind = 'F'
+	if ($root.PSIsContainer) { $kind = 'D' }
+	"$kind` + "`t$(Encode $root.FullName)`t" + `"
+	if ($recurse -and $root.PSIsContainer) {
+		$prefix = $root.FullName.TrimEnd('\').Length + 1
+		foreach ($item in @(Get-ChildItem -LiteralPath $root.FullName -Recurse -Force)) {
+			$kind = 'F'
+			if ($item.PSIsContainer) { $kind = 'D' }
+			"$kind` + "`t$(Encode $root.FullName)`t$(Encode $item.FullName.Substring($prefix))" + `"
+		}
+	}
+}`
+
+// remoteEntry is a file or directory listed by listScript.
+type remoteEntry struct {
+	dir bool
+	// root is the full name of the match of the listed path, and rel the
+	// path of the entry relative to it, with slashes, empty for the match
+	// itself.
+	root string
+	rel  string
+}
+
+// fullName returns the full name of the entry on the guest.
+func (e remoteEntry) fullName() string {
+	if e.rel == "" {
+		return e.root
+	}
+	return strings.TrimSuffix(e.root, `\`) + `\` + strings.ReplaceAll(e.rel, "/", `\`)
+}
+
+// list returns the entries matching src, a path or glob pattern, and with
+// recurse the entries of the matching directories.
+func (c *Communicator) list(src string, recurse bool) ([]remoteEntry, error) {
+	pathParam := "-LiteralPath"
+	if pathmatch.HasGlobMeta(src) {
+		pathParam = "-Path"
+	}
+	var stdout bytes.Buffer
+	if err := c.runPowershell(fmt.Sprintf(listScript, recurse, pathParam, src), &stdout); err != nil {
+		return nil, fmt.Errorf("Couldn't list %s: %s", src, err)
+	}
+
+	var entries []remoteEntry
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected listing of %s: %q", src, line)
+		}
+		root, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected listing of %s: %s", src, err)
+		}
+		rel, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected listing of %s: %s", src, err)
+		}
+		entries = append(entries, remoteEntry{
+			dir:  fields[0] == "D",
+			root: string(root),
+			rel:  strings.ReplaceAll(string(rel), `\`, "/"),
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// DownloadDir implementation of communicator.Communicator interface. src may
+// be a glob pattern, in which case every match is downloaded into dst.
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("Downloading dir '%s' to '%s'", src, dst)
+	entries, err := c.list(src, true)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no file matches %s", src)
+	}
+
+	// The entries of the excluded directories are skipped along with them.
+	var excluded []string
+	for _, e := range entries {
+		base := winBase(e.root)
+		id := e.root + "/" + e.rel
+		if pathmatch.Excluded(base, exclude) || (e.rel != "" && pathmatch.Excluded(e.rel, exclude)) {
+			log.Printf("[DEBUG] Excluding: %s", e.fullName())
+			excluded = append(excluded, id+"/")
+			continue
+		}
+		skip := false
+		for _, prefix := range excluded {
+			if strings.HasPrefix(id, prefix) {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+
+		local := filepath.Join(dst, base, filepath.FromSlash(e.rel))
+		if e.dir {
+			if err := os.MkdirAll(local, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.downloadFile(e.fullName(), local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Communicator) downloadFile(src string, dst string) error {
+	log.Printf("[DEBUG] Downloading %s", src)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := c.download(psQuote(src), f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// winBase returns the last element of the Windows path p.
+func winBase(p string) string {
+	p = strings.TrimRight(p, `\`)
+	return p[strings.LastIndex(p, `\`)+1:]
+}
+
+// psQuote returns s as a verbatim PowerShell string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
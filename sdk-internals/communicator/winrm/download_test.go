@@ -0,0 +1,170 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var fakeListRe = regexp.MustCompile(`(?s)\$recurse = \$(true|false).*Get-Item (-Path|-LiteralPath) "(.*)" -Force`)
+
+// list emulates listScript, matching the patterns like path.Match.
+func (s *fakeShellServer) list(recurse bool, src string, wildcard bool) ([]byte, int) {
+	// The directories are those of the files.
+	dirs := map[string]bool{}
+	for name := range s.files {
+		for d := path.Dir(slashPath(name)); d != "." && !strings.HasSuffix(d, ":"); d = path.Dir(d) {
+			dirs[d] = true
+		}
+	}
+	var names []string
+	for name := range s.files {
+		names = append(names, slashPath(name))
+	}
+	for d := range dirs {
+		names = append(names, d)
+	}
+	sort.Strings(names)
+
+	pattern := slashPath(src)
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	kind := func(name string) string {
+		if dirs[name] {
+			return "D"
+		}
+		return "F"
+	}
+	var out bytes.Buffer
+	matched := false
+	for _, root := range names {
+		if ok, _ := path.Match(pattern, root); !(ok && wildcard) && root != pattern {
+			continue
+		}
+		matched = true
+		fullName := strings.ReplaceAll(root, "/", `\`)
+		fmt.Fprintf(&out, "%s\t%s\t\r\n", kind(root), encode(fullName))
+		if !recurse || !dirs[root] {
+			continue
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, root+"/") {
+				rel := strings.ReplaceAll(strings.TrimPrefix(name, root+"/"), "/", `\`)
+				fmt.Fprintf(&out, "%s\t%s\t%s\r\n", kind(name), encode(fullName), encode(rel))
+			}
+		}
+	}
+	if !matched && !wildcard {
+		return nil, 1
+	}
+	return out.Bytes(), 0
+}
+
+// slashPath returns the Windows path p with slashes.
+func slashPath(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+func TestDownload_glob(t *testing.T) {
+	s, c := newFakeShellServer(t)
+	s.files[`C:\Temp\logs\setup.log`] = []byte("setup")
+	s.files[`C:\Temp\logs\install.log`] = []byte("install")
+	s.files[`C:\Temp\logs\old\setup.log`] = []byte("old")
+
+	var b bytes.Buffer
+	if err := c.Download(`C:\Temp\logs\set*.log`, &b); err != nil {
+		t.Fatalf("error downloading file: %s", err)
+	}
+	if b.String() != "setup" {
+		t.Fatalf("expected setup, got %q", b.String())
+	}
+
+	for pattern, expected := range map[string]string{
+		`C:\Temp\logs\*.log`: "matches 2 files",
+		`C:\Temp\logs\o*`:    "matches the directory",
+		`C:\Temp\*.txt`:      "no file matches",
+	} {
+		err := c.Download(pattern, ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected an error containing %q, got %v", pattern, expected, err)
+		}
+	}
+
+	if err := c.Download(`C:\Temp\missing.log`, ioutil.Discard); err == nil {
+		t.Fatalf("expected an error downloading a missing file")
+	}
+}
+
+func TestDownloadDir(t *testing.T) {
+	s, c := newFakeShellServer(t)
+	s.files[`C:\Temp\logs\setup.log`] = []byte("setup")
+	s.files[`C:\Temp\logs\install.tmp`] = []byte("install")
+	s.files[`C:\Temp\logs\old\setup.log`] = []byte("old")
+	s.files[`C:\Temp\results\report.xml`] = []byte("report")
+	s.files[`C:\Temp\results.txt`] = []byte("results")
+
+	for _, tc := range []struct {
+		src      string
+		exclude  []string
+		expected map[string]string
+	}{
+		{
+			src: `C:\Temp\logs`,
+			expected: map[string]string{
+				"logs/setup.log":     "setup",
+				"logs/install.tmp":   "install",
+				"logs/old/setup.log": "old",
+			},
+		},
+		{
+			src:     `C:\Temp\logs`,
+			exclude: []string{"*.tmp", "old/"},
+			expected: map[string]string{
+				"logs/setup.log": "setup",
+			},
+		},
+		{
+			src:     `C:\Temp\res*`,
+			exclude: []string{"*.txt"},
+			expected: map[string]string{
+				"results/report.xml": "report",
+			},
+		},
+	} {
+		dst := t.TempDir()
+		if err := c.DownloadDir(tc.src, dst, tc.exclude); err != nil {
+			t.Fatalf("%s: error downloading dir: %s", tc.src, err)
+		}
+
+		files := map[string]string{}
+		err := filepath.Walk(dst, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			b, err := ioutil.ReadFile(p)
+			rel, _ := filepath.Rel(dst, p)
+			files[filepath.ToSlash(rel)] = string(b)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(files) != fmt.Sprint(tc.expected) {
+			t.Fatalf("%s %v: expected %v, got %v", tc.src, tc.exclude, tc.expected, files)
+		}
+	}
+
+	if err := c.DownloadDir(`C:\Temp\*.iso`, t.TempDir(), nil); err == nil || !strings.Contains(err.Error(), "no file matches") {
+		t.Fatalf("expected an error for a pattern without match, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// KerberosSettings configures the Kerberos authentication of ClientKerberos.
+// The credentials are read from KeytabPath when set, then from Password, and
+// otherwise from the credential cache at CCachePath.
+type KerberosSettings struct {
+	// Username and Realm identify the principal authenticating with a keytab
+	// or a password. An empty Realm is the default realm of the krb5.conf.
+	Username string
+	Realm    string
+	Password string
+
+	KeytabPath string
+	// CCachePath defaults to the KRB5CCNAME environment variable, then to
+	// the default cache of the current user.
+	CCachePath string
+	// Krb5ConfPath defaults to the KRB5_CONFIG environment variable, then to
+	// /etc/krb5.conf.
+	Krb5ConfPath string
+
+	// SPN is the service principal of the WinRM service. It defaults to
+	// HTTP/<host>, the host being resolved to its canonical name.
+	SPN string
+
+	// Proxy returns the proxy of requests, http.ProxyFromEnvironment when
+	// nil.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// ClientKerberos is a winrm.Transporter authenticating with Kerberos, over
+// SPNEGO. Like the NTLM transport of the winrm package, messages are not
+// encrypted at the WinRM level: the service must either be reached over
+// HTTPS or allow unencrypted traffic.
+type ClientKerberos struct {
+	settings KerberosSettings
+
+	url    string
+	client *spnego.Client
+}
+
+// NewClientKerberos returns a Kerberos transport using settings.
+func NewClientKerberos(settings *KerberosSettings) *ClientKerberos {
+	return &ClientKerberos{settings: *settings}
+}
+
+// Transport logs in to the KDC and prepares the HTTP client of endpoint.
+func (c *ClientKerberos) Transport(endpoint *winrm.Endpoint) error {
+	krb, err := c.settings.login()
+	if err != nil {
+		return fmt.Errorf("Kerberos login failed: %w", err)
+	}
+
+	transport, err := newHTTPTransport(endpoint, c.settings.Proxy)
+	if err != nil {
+		return err
+	}
+	c.url = endpointURL(endpoint)
+	c.client = spnego.NewClient(krb, &http.Client{Transport: transport}, c.settings.SPN)
+	return nil
+}
+
+// Post sends request to the WinRM service, authenticating as needed.
+func (c *ClientKerberos) Post(_ *winrm.Client, request *soap.SoapMessage) (string, error) {
+	req, err := newSOAPRequest(c.url, request.String())
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unknown error %w", err)
+	}
+	return soapResponse(resp)
+}
+
+// login returns a Kerberos client holding a ticket granting ticket.
+func (s *KerberosSettings) login() (*client.Client, error) {
+	confPath := s.Krb5ConfPath
+	if confPath == "" {
+		confPath = os.Getenv("KRB5_CONFIG")
+	}
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := config.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", confPath, err)
+	}
+
+	// Active Directory doesn't support FAST pre-authentication.
+	disableFAST := client.DisablePAFXFAST(true)
+
+	var krb *client.Client
+	switch {
+	case s.KeytabPath != "":
+		kt, err := keytab.Load(s.KeytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading keytab %s: %w", s.KeytabPath, err)
+		}
+		krb = client.NewWithKeytab(s.Username, s.Realm, kt, conf, disableFAST)
+	case s.Password != "":
+		krb = client.NewWithPassword(s.Username, s.Realm, s.Password, conf, disableFAST)
+	default:
+		path := s.ccachePath()
+		ccache, err := credentials.LoadCCache(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading credential cache %s: %w", path, err)
+		}
+		// The tickets of the cache are used as is, they can't be renewed.
+		return client.NewFromCCache(ccache, conf, disableFAST)
+	}
+
+	if err := krb.AffirmLogin(); err != nil {
+		return nil, err
+	}
+	return krb, nil
+}
+
+func (s *KerberosSettings) ccachePath() string {
+	path := s.CCachePath
+	if path == "" {
+		path = os.Getenv("KRB5CCNAME")
+	}
+	if path == "" {
+		path = fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+	}
+	return strings.TrimPrefix(path, "FILE:")
+}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/masterzen/winrm"
+)
+
+func TestClientKerberos_Transport(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "krb5.conf")
+	err := os.WriteFile(conf, []byte(`[libdefaults]
+  default_realm = EXAMPLE.COM
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings KerberosSettings
+		err      string
+	}{
+		{
+			"missing configuration",
+			KerberosSettings{Krb5ConfPath: filepath.Join(dir, "missing.conf")},
+			"missing.conf",
+		},
+		{
+			"missing keytab",
+			KerberosSettings{Krb5ConfPath: conf, Username: "packer", KeytabPath: filepath.Join(dir, "packer.keytab")},
+			"packer.keytab",
+		},
+		{
+			"missing credential cache",
+			KerberosSettings{Krb5ConfPath: conf, CCachePath: "FILE:" + filepath.Join(dir, "krb5cc")},
+			"credential cache " + filepath.Join(dir, "krb5cc"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClientKerberos(&tt.settings)
+			err := c.Transport(&winrm.Endpoint{Host: "localhost", Port: 5985})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Transport() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestKerberosSettings_ccachePath(t *testing.T) {
+	t.Setenv("KRB5CCNAME", "")
+	s := &KerberosSettings{}
+	if got, want := s.ccachePath(), fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid()); got != want {
+		t.Fatalf("ccachePath() = %q, want %q", got, want)
+	}
+
+	t.Setenv("KRB5CCNAME", "FILE:/tmp/packer_cc")
+	if got := s.ccachePath(); got != "/tmp/packer_cc" {
+		t.Fatalf("ccachePath() = %q, want the KRB5CCNAME path", got)
+	}
+
+	s.CCachePath = "/tmp/configured_cc"
+	if got := s.ccachePath(); got != s.CCachePath {
+		t.Fatalf("ccachePath() = %q, want %q", got, s.CCachePath)
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// ntlm is the client side of an NTLMv2 security context, with the key
+// exchange and the message sealing used by CredSSP. The go-ntlmssp package
+// used for plain NTLM authentication only authenticates.
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp
+type ntlm struct {
+	user     string
+	domain   string
+	password string
+
+	// now and random are replaced by tests.
+	now    func() time.Time
+	random func([]byte) error
+
+	negotiate []byte
+	flags     uint32
+
+	clientSigningKey []byte
+	serverSigningKey []byte
+	clientSealing    *rc4.Cipher
+	serverSealing    *rc4.Cipher
+	clientSeqNum     uint32
+	serverSeqNum     uint32
+}
+
+const (
+	ntlmNegotiateUnicode                 = 1 << 0
+	ntlmRequestTarget                    = 1 << 2
+	ntlmNegotiateSign                    = 1 << 4
+	ntlmNegotiateSeal                    = 1 << 5
+	ntlmNegotiateNTLM                    = 1 << 9
+	ntlmNegotiateAlwaysSign              = 1 << 15
+	ntlmNegotiateExtendedSessionSecurity = 1 << 19
+	ntlmNegotiateTargetInfo              = 1 << 23
+	ntlmNegotiateVersion                 = 1 << 25
+	ntlmNegotiate128                     = 1 << 29
+	ntlmNegotiateKeyExch                 = 1 << 30
+	ntlmNegotiate56                      = 1 << 31
+
+	ntlmFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateSign |
+		ntlmNegotiateSeal | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateExtendedSessionSecurity | ntlmNegotiateTargetInfo |
+		ntlmNegotiateVersion | ntlmNegotiate128 | ntlmNegotiateKeyExch |
+		ntlmNegotiate56
+)
+
+const (
+	ntlmAvEOL       = 0
+	ntlmAvFlags     = 6
+	ntlmAvTimestamp = 7
+
+	// ntlmAvFlagMIC tells that the authenticate message holds a MIC.
+	ntlmAvFlagMIC = 0x2
+)
+
+const ntlmSignature = "NTLMSSP\x00"
+
+// ntlmVersion is the version sent with the messages, Windows 10 with the
+// NTLMSSP revision 15.
+var ntlmVersion = []byte{10, 0, 0x63, 0x45, 0, 0, 0, 15}
+
+// newNTLM returns the context of user, which may be qualified by a domain as
+// DOMAIN\user.
+func newNTLM(user, password string) *ntlm {
+	domain := ""
+	if i := strings.Index(user, `\`); i != -1 {
+		domain, user = user[:i], user[i+1:]
+	}
+	return &ntlm{
+		user:     user,
+		domain:   domain,
+		password: password,
+		now:      time.Now,
+		random: func(b []byte) error {
+			_, err := rand.Read(b)
+			return err
+		},
+	}
+}
+
+// negotiateMessage returns the first message of the context.
+func (n *ntlm) negotiateMessage() []byte {
+	b := make([]byte, 40)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], 1)
+	binary.LittleEndian.PutUint32(b[12:], ntlmFlags)
+	// The domain and workstation fields are empty, their offsets pointing
+	// to the end of the message.
+	binary.LittleEndian.PutUint32(b[20:], 40)
+	binary.LittleEndian.PutUint32(b[28:], 40)
+	copy(b[32:], ntlmVersion)
+	n.negotiate = b
+	return b
+}
+
+// authenticateMessage answers the challenge message of the server, and
+// derives the keys sealing the following messages.
+func (n *ntlm) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 48 || string(challenge[:8]) != ntlmSignature ||
+		binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge message")
+	}
+	n.flags = binary.LittleEndian.Uint32(challenge[20:]) & ntlmFlags
+	if n.flags&ntlmNegotiateExtendedSessionSecurity == 0 || n.flags&ntlmNegotiateKeyExch == 0 ||
+		n.flags&ntlmNegotiate128 == 0 {
+		return nil, fmt.Errorf("NTLM server doesn't support the required security, flags 0x%08x", n.flags)
+	}
+	serverChallenge := challenge[24:32]
+	targetInfo, err := ntlmField(challenge, 40)
+	if err != nil {
+		return nil, err
+	}
+
+	avPairs, err := parseAvPairs(targetInfo)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, withMIC := avPairs[ntlmAvTimestamp]
+	if !withMIC {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, ntlmFileTime(n.now()))
+	}
+	if withMIC {
+		targetInfo, err = withAvFlags(targetInfo, ntlmAvFlagMIC)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clientChallenge := make([]byte, 8)
+	exportedSessionKey := make([]byte, 16)
+	if err := n.random(clientChallenge); err != nil {
+		return nil, err
+	}
+	if err := n.random(exportedSessionKey); err != nil {
+		return nil, err
+	}
+
+	responseKey := ntowfv2(n.user, n.domain, n.password)
+	temp := ntlmv2Temp(timestamp, clientChallenge, targetInfo)
+	ntProofStr := hmacMD5(responseKey, serverChallenge, temp)
+	ntResponse := append(append([]byte{}, ntProofStr...), temp...)
+	lmResponse := make([]byte, 24)
+	if !withMIC {
+		lmResponse = append(hmacMD5(responseKey, serverChallenge, clientChallenge), clientChallenge...)
+	}
+
+	keyExchangeKey := hmacMD5(responseKey, ntProofStr)
+	encryptedSessionKey := make([]byte, 16)
+	rc4K(keyExchangeKey, exportedSessionKey, encryptedSessionKey)
+
+	domain, user, workstation := ntlmUnicode(n.domain), ntlmUnicode(n.user), []byte{}
+	const headerLen = 88
+	fields := [][]byte{lmResponse, ntResponse, domain, user, workstation, encryptedSessionKey}
+	b := make([]byte, headerLen)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], 3)
+	offset := headerLen
+	for i, field := range fields {
+		binary.LittleEndian.PutUint16(b[12+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(b[14+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(b[16+8*i:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(b[60:], n.flags)
+	copy(b[64:], ntlmVersion)
+	for _, field := range fields {
+		b = append(b, field...)
+	}
+	if withMIC {
+		mic := hmacMD5(exportedSessionKey, n.negotiate, challenge, b)
+		copy(b[72:], mic)
+	}
+
+	n.clientSigningKey = md5Sum(exportedSessionKey, "session key to client-to-server signing key magic constant\x00")
+	n.serverSigningKey = md5Sum(exportedSessionKey, "session key to server-to-client signing key magic constant\x00")
+	n.clientSealing, _ = rc4.NewCipher(md5Sum(exportedSessionKey, "session key to client-to-server sealing key magic constant\x00"))
+	n.serverSealing, _ = rc4.NewCipher(md5Sum(exportedSessionKey, "session key to server-to-client sealing key magic constant\x00"))
+	return b, nil
+}
+
+// seal encrypts msg, returning it preceded by its signature.
+func (n *ntlm) seal(msg []byte) []byte {
+	sealed := make([]byte, 16+len(msg))
+	n.clientSealing.XORKeyStream(sealed[16:], msg)
+	copy(sealed, ntlmMAC(n.clientSealing, n.clientSigningKey, n.clientSeqNum, msg))
+	n.clientSeqNum++
+	return sealed
+}
+
+// unseal decrypts a message of the server sealed along with its signature,
+// and checks the signature.
+func (n *ntlm) unseal(sealed []byte) ([]byte, error) {
+	if len(sealed) < 16 {
+		return nil, errors.New("sealed NTLM message is too short")
+	}
+	msg := make([]byte, len(sealed)-16)
+	n.serverSealing.XORKeyStream(msg, sealed[16:])
+	signature := ntlmMAC(n.serverSealing, n.serverSigningKey, n.serverSeqNum, msg)
+	n.serverSeqNum++
+	if !hmac.Equal(signature, sealed[:16]) {
+		return nil, errors.New("invalid NTLM message signature")
+	}
+	return msg, nil
+}
+
+// ntlmMAC returns the signature of a message with extended session
+// security and key exchange.
+func ntlmMAC(sealing *rc4.Cipher, signingKey []byte, seqNum uint32, msg []byte) []byte {
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, seqNum)
+	checksum := hmacMD5(signingKey, seq, msg)[:8]
+	sealing.XORKeyStream(checksum, checksum)
+
+	signature := []byte{1, 0, 0, 0}
+	signature = append(signature, checksum...)
+	return append(signature, seq...)
+}
+
+func ntowfv2(user, domain, password string) []byte {
+	h := md4.New()
+	h.Write(ntlmUnicode(password))
+	return hmacMD5(h.Sum(nil), ntlmUnicode(strings.ToUpper(user)+domain))
+}
+
+func ntlmv2Temp(timestamp, clientChallenge, targetInfo []byte) []byte {
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	return append(temp, 0, 0, 0, 0)
+}
+
+// ntlmField returns the payload of the field of msg described at offset.
+func ntlmField(msg []byte, offset int) ([]byte, error) {
+	length := int(binary.LittleEndian.Uint16(msg[offset:]))
+	start := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	if start+length > len(msg) {
+		return nil, errors.New("invalid NTLM message field")
+	}
+	return msg[start : start+length], nil
+}
+
+func parseAvPairs(b []byte) (map[uint16][]byte, error) {
+	pairs := make(map[uint16][]byte)
+	for len(b) >= 4 {
+		id := binary.LittleEndian.Uint16(b)
+		length := int(binary.LittleEndian.Uint16(b[2:]))
+		if id == ntlmAvEOL {
+			return pairs, nil
+		}
+		if 4+length > len(b) {
+			break
+		}
+		pairs[id] = b[4 : 4+length]
+		b = b[4+length:]
+	}
+	return nil, errors.New("invalid NTLM target info")
+}
+
+// withAvFlags returns targetInfo, with flags set in its MsvAvFlags pair.
+func withAvFlags(targetInfo []byte, flags uint32) ([]byte, error) {
+	var out bytes.Buffer
+	for b := targetInfo; len(b) >= 4; {
+		id := binary.LittleEndian.Uint16(b)
+		length := int(binary.LittleEndian.Uint16(b[2:]))
+		if 4+length > len(b) || id == ntlmAvFlags && length != 4 {
+			return nil, errors.New("invalid NTLM target info")
+		}
+		switch id {
+		case ntlmAvFlags:
+			flags |= binary.LittleEndian.Uint32(b[4:])
+		case ntlmAvEOL:
+			b = nil
+			continue
+		default:
+			out.Write(b[:4+length])
+		}
+		b = b[4+length:]
+	}
+	binary.Write(&out, binary.LittleEndian, []uint16{ntlmAvFlags, 4})
+	binary.Write(&out, binary.LittleEndian, flags)
+	out.Write([]byte{0, 0, 0, 0})
+	return out.Bytes(), nil
+}
+
+// ntlmFileTime returns t as a Windows FILETIME, in 100ns intervals since
+// 1601.
+func ntlmFileTime(t time.Time) uint64 {
+	return uint64(t.Unix()+11644473600)*1e7 + uint64(t.Nanosecond()/100)
+}
+
+func ntlmUnicode(s string) []byte {
+	b := make([]byte, 0, 2*len(s))
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func md5Sum(key []byte, constant string) []byte {
+	sum := md5.Sum(append(append([]byte{}, key...), constant...))
+	return sum[:]
+}
+
+func rc4K(key, src, dst []byte) {
+	c, _ := rc4.NewCipher(key)
+	c.XORKeyStream(dst, src)
+}
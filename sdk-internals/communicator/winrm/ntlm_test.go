@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestNTLM checks the context against the NTLMv2 example of MS-NLMP, section
+// 4.2.4.
+func TestNTLM(t *testing.T) {
+	challenge := unhex(t, "4e544c4d53535000 02000000 0c000c00 38000000 33828ae2"+
+		"0123456789abcdef 0000000000000000 24002400 44000000 060070170000000f"+
+		"53006500720076006500720002000c0044006f006d00610069006e0001000c005300650072007600650072000000000000")
+
+	n := newNTLM(`Domain\User`, "Password")
+	n.now = func() time.Time { return time.Unix(-11644473600, 0) }
+	random := [][]byte{
+		bytes.Repeat([]byte{0xaa}, 8),
+		bytes.Repeat([]byte{0x55}, 16),
+	}
+	n.random = func(b []byte) error {
+		copy(b, random[0])
+		random = random[1:]
+		return nil
+	}
+
+	n.negotiateMessage()
+	auth, err := n.authenticateMessage(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	field := func(offset int) []byte {
+		b, err := ntlmField(auth, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if want := unhex(t, "86c35097ac9cec102554764a57cccc19 aaaaaaaaaaaaaaaa"); !bytes.Equal(field(12), want) {
+		t.Fatalf("LMv2 response = %x, want %x", field(12), want)
+	}
+	if want := unhex(t, "68cd0ab851e51c96aabc927bebef6a1c"); !bytes.Equal(field(20)[:16], want) {
+		t.Fatalf("NTProofStr = %x, want %x", field(20)[:16], want)
+	}
+	if want := ntlmUnicode("Domain"); !bytes.Equal(field(28), want) {
+		t.Fatalf("domain = %x, want %x", field(28), want)
+	}
+	if want := ntlmUnicode("User"); !bytes.Equal(field(36), want) {
+		t.Fatalf("user = %x, want %x", field(36), want)
+	}
+	if want := unhex(t, "c5dad2544fc9799094ce1ce90bc9d03e"); !bytes.Equal(field(52), want) {
+		t.Fatalf("encrypted session key = %x, want %x", field(52), want)
+	}
+
+	sealed := n.seal(ntlmUnicode("Plaintext"))
+	if want := unhex(t, "010000007fb38ec5c55d497600000000"); !bytes.Equal(sealed[:16], want) {
+		t.Fatalf("signature = %x, want %x", sealed[:16], want)
+	}
+	if want := unhex(t, "54e50165bf1936dc996020c1811b0f06fb5f"); !bytes.Equal(sealed[16:], want) {
+		t.Fatalf("sealed message = %x, want %x", sealed[16:], want)
+	}
+}
+
+func TestNTLM_unseal(t *testing.T) {
+	client, server := &ntlm{}, &ntlm{}
+	key := bytes.Repeat([]byte{0x55}, 16)
+	client.serverSigningKey = md5Sum(key, "server")
+	server.clientSigningKey = client.serverSigningKey
+	client.serverSealing, _ = rc4.NewCipher(md5Sum(key, "seal"))
+	server.clientSealing, _ = rc4.NewCipher(md5Sum(key, "seal"))
+
+	for _, msg := range []string{"first", "second"} {
+		got, err := client.unseal(server.seal([]byte(msg)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Fatalf("unseal() = %q, want %q", got, msg)
+		}
+	}
+
+	sealed := server.seal([]byte("tampered"))
+	sealed[len(sealed)-1] ^= 1
+	if _, err := client.unseal(sealed); err == nil {
+		t.Fatal("unseal() of a tampered message should fail")
+	}
+}
+
+func TestWithAvFlags(t *testing.T) {
+	// MsvAvNbDomainName "D", MsvAvFlags 0x1, MsvAvEOL
+	targetInfo := unhex(t, "0200 0200 4400 0600 0400 01000000 0000 0000")
+	got, err := withAvFlags(targetInfo, ntlmAvFlagMIC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := unhex(t, "0200 0200 4400 0600 0400 03000000 0000 0000")
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected %x, got %x", expected, got)
+	}
+
+	for _, invalid := range []string{
+		"0200 0800 4400",
+		"0600 0200 0100 0000 0000",
+		"0600 0400 0100",
+	} {
+		if _, err := withAvFlags(unhex(t, invalid), ntlmAvFlagMIC); err == nil {
+			t.Errorf("%s: expected an error", invalid)
+		}
+	}
+}
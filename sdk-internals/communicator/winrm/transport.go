@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/masterzen/winrm"
+)
+
+// The helpers below are shared by the transports of this package, and follow
+// the default transport of the winrm package.
+
+// newHTTPTransport returns the HTTP transport to endpoint. proxy defaults to
+// http.ProxyFromEnvironment.
+func newHTTPTransport(endpoint *winrm.Endpoint, proxy func(*http.Request) (*url.URL, error)) (*http.Transport, error) {
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	transport := &http.Transport{
+		Proxy: proxy,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: endpoint.Insecure,
+			ServerName:         endpoint.TLSServerName,
+		},
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		ResponseHeaderTimeout: endpoint.Timeout,
+	}
+	if len(endpoint.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(endpoint.CACert) {
+			return nil, fmt.Errorf("Unable to read the CA certificates")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return transport, nil
+}
+
+// endpointURL returns the URL of the WinRM service of endpoint.
+func endpointURL(endpoint *winrm.Endpoint) string {
+	scheme := "http"
+	if endpoint.HTTPS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d/wsman", scheme, endpoint.Host, endpoint.Port)
+}
+
+// newSOAPRequest returns the request posting body to the WinRM service at
+// url.
+func newSOAPRequest(url string, body string) (*http.Request, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("impossible to create http request %w", err)
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	return req, nil
+}
+
+// soapResponse reads and closes the body of resp, the answer of the WinRM
+// service to a request.
+func soapResponse(resp *http.Response) (string, error) {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error while reading request body %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/soap+xml") {
+		return "", fmt.Errorf("http response error: %d - invalid content type", resp.StatusCode)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/powershell"
+	"github.com/masterzen/winrm"
+)
+
+// uploadScript is run by PowerShell to write the file sent on its standard
+// input, as a line of base64 per chunk. Compressed files are written to a
+// temporary file, then decompressed to their destination. The length of the
+// file is written last, as the failed requests of the input aren't reported
+// by the WinRM client. It only uses .NET 2.0 APIs, so that it runs with
+// PowerShell 2.0.
+const uploadScript = `begin {
+	$ErrorActionPreference = 'Stop'
+	$path = [System.IO.Path]::GetFullPath("%s")
+	$compressed = $%t
+	if (Test-Path -LiteralPath $path -PathType Container) {
+		throw "$path is a directory"
+	}
+	[void][System.IO.Directory]::CreateDirectory([System.IO.Path]::GetDirectoryName($path))
+	if ($compressed) {
+		$tmp = [System.IO.Path]::GetTempFileName()
+		$fs = [System.IO.File]::Create($tmp)
+	} else {
+		$fs = [System.IO.File]::Create($path)
+	}
+}
+process {
+	$bytes = [System.Convert]::FromBase64String($_)
+	$fs.Write($bytes, 0, $bytes.Length)
+}
+end {
+	$fs.Close()
+	if ($compressed) {
+		$in = [System.IO.File]::OpenRead($tmp)
+		try {
+			$gz = New-Object System.IO.Compression.GZipStream($in, [System.IO.Compression.CompressionMode]::Decompress)
+			$out = [System.IO.File]::Create($path)
+			try {
+				$buffer = New-Object byte[] 65536
+				while (($n = $gz.Read($buffer, 0, $buffer.Length)) -gt 0) {
+					$out.Write($buffer, 0, $n)
+				}
+			} finally {
+				$out.Close()
+			}
+		} finally {
+			$in.Close()
+			Remove-Item -LiteralPath $tmp -ErrorAction SilentlyContinue
+		}
+	}
+	(New-Object System.IO.FileInfo($path)).Length
+}`
+
+// defaultUploadChunkSize returns the largest chunk whose line, base64
+// encoded in a Send request, fits in an envelope of envelopeSize bytes,
+// keeping 2KB for the rest of the request.
+func defaultUploadChunkSize(envelopeSize int) int {
+	line := (envelopeSize-2048)/4*3 - len("\r\n")
+	return line / 4 * 3
+}
+
+// winPath returns path with Windows separators, unquoted, for a double
+// quoted PowerShell string. Variables such as $env:TEMP are expanded.
+func winPath(path string) string {
+	path = strings.Trim(path, `'"`)
+	path = strings.ReplaceAll(path, "/", `\`)
+	return strings.NewReplacer("`", "``", `"`, "`\"").Replace(path)
+}
+
+// upload writes input to path with a single PowerShell command, sending
+// the content on its standard input rather than running a command per
+// chunk.
+func (c *Communicator) upload(path string, input io.Reader) error {
+	shell, err := c.client.CreateShell()
+	if err != nil {
+		return fmt.Errorf("Couldn't create shell: %v", err)
+	}
+	defer shell.Close()
+
+	script := fmt.Sprintf(uploadScript, winPath(path), c.config.UploadCompression)
+	cmd, err := shell.Execute(powershell.EncodedCommand(c.config.Shell, script))
+	if err != nil {
+		return err
+	}
+	defer cmd.Close()
+
+	var stdout, stderr bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(&stdout, cmd.Stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(&stderr, cmd.Stderr)
+	}()
+
+	counter := &countingReader{r: input}
+	input = counter
+	content := input
+	if c.config.UploadCompression {
+		pr, pw := io.Pipe()
+		go func() {
+			gz := gzip.NewWriter(pw)
+			_, err := io.Copy(gz, input)
+			if err == nil {
+				err = gz.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		defer pr.Close()
+		content = pr
+	}
+	sendErr := sendChunks(cmd.Stdin, content, c.uploadChunkSize())
+	if sendErr == nil {
+		sendErr = cmd.Stdin.Close()
+	}
+
+	cmd.Wait()
+	wg.Wait()
+	if sendErr != nil {
+		return sendErr
+	}
+	if cmd.ExitCode() != 0 {
+		return fmt.Errorf("upload operation returned code=%d: %s", cmd.ExitCode(), strings.TrimSpace(stderr.String()))
+	}
+	if length := strings.TrimSpace(stdout.String()); length != strconv.FormatInt(counter.n, 10) {
+		return fmt.Errorf("upload of %s is incomplete: the file has %q bytes, %d were sent", path, length, counter.n)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (c *Communicator) uploadChunkSize() int {
+	if c.config.UploadChunkSize > 0 {
+		return c.config.UploadChunkSize
+	}
+	return defaultUploadChunkSize(winrm.DefaultParameters.EnvelopeSize)
+}
+
+// sendChunks writes the content of r to w, as a line of base64 per chunk of
+// chunkSize bytes.
+func sendChunks(w io.Writer, r io.Reader, chunkSize int) error {
+	chunk := make([]byte, chunkSize)
+	line := make([]byte, base64.StdEncoding.EncodedLen(chunkSize)+len("\r\n"))
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		l := base64.StdEncoding.EncodedLen(n)
+		base64.StdEncoding.Encode(line, chunk[:n])
+		copy(line[l:], "\r\n")
+		if _, err := w.Write(line[:l+2]); err != nil {
+			return err
+		}
+	}
+}
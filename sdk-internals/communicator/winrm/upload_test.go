@@ -0,0 +1,313 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// fakeShellServer is a WinRM service running the PowerShell scripts of the
+// uploads and downloads against an in-memory file system. Unlike winrmtest,
+// it reads the standard input of the commands.
+type fakeShellServer struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	commands map[string]*fakeCommand
+	files    map[string][]byte
+	sends    int
+	nextID   int
+	// shells are the executables of the commands run.
+	shells []string
+}
+
+type fakeCommand struct {
+	line string
+	// script is the script of the PowerShell commands.
+	script string
+	stdin  []byte
+	ended  bool
+}
+
+type fakeShellRequest struct {
+	Action      string `xml:"Header>Action"`
+	CommandLine struct {
+		Command   string   `xml:"Command"`
+		Arguments []string `xml:"Arguments"`
+	} `xml:"Body>CommandLine"`
+	Stdin struct {
+		CommandID string `xml:"CommandId,attr"`
+		End       string `xml:"End,attr"`
+		Data      string `xml:",chardata"`
+	} `xml:"Body>Send>Stream"`
+	Receive struct {
+		CommandID string `xml:"CommandId,attr"`
+	} `xml:"Body>Receive>DesiredStream"`
+}
+
+const fakeShellEnvelope = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+	` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"` +
+	` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">` +
+	`<s:Header></s:Header><s:Body>%s</s:Body></s:Envelope>`
+
+func newFakeShellServer(t *testing.T) (*fakeShellServer, *Communicator) {
+	s := &fakeShellServer{
+		t:        t,
+		commands: map[string]*fakeCommand{},
+		files:    map[string][]byte{},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	addr := strings.TrimPrefix(ts.URL, "http://")
+	host, port := addr[:strings.LastIndex(addr, ":")], addr[strings.LastIndex(addr, ":")+1:]
+	p, _ := strconv.Atoi(port)
+	c, err := New(&Config{
+		Host:     host,
+		Port:     p,
+		Username: "user",
+		Password: "pass",
+		Timeout:  30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error creating communicator: %s", err)
+	}
+	return s, c
+}
+
+func (s *fakeShellServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var req fakeShellRequest
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := xml.Unmarshal(body, &req); err != nil {
+		s.t.Errorf("invalid request: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var response string
+	switch {
+	case strings.HasSuffix(req.Action, "transfer/Create"):
+		response = `<w:SelectorSet><w:Selector Name="ShellId">123</w:Selector></w:SelectorSet>`
+	case strings.HasSuffix(req.Action, "shell/Command"):
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		line := strings.Join(append([]string{req.CommandLine.Command}, req.CommandLine.Arguments...), " ")
+		cmd := &fakeCommand{line: line}
+		if strings.Contains(line, " -EncodedCommand ") {
+			cmd.script = s.decodeScript(line)
+		}
+		s.commands[id] = cmd
+		response = `<rsp:CommandResponse><rsp:CommandId>` + id + `</rsp:CommandId></rsp:CommandResponse>`
+	case strings.HasSuffix(req.Action, "shell/Send"):
+		cmd := s.commands[req.Stdin.CommandID]
+		data, err := base64.StdEncoding.DecodeString(req.Stdin.Data)
+		if err != nil {
+			s.t.Errorf("invalid stdin: %s", err)
+		}
+		s.sends++
+		cmd.stdin = append(cmd.stdin, data...)
+		cmd.ended = req.Stdin.End == "true"
+		s.cond.Broadcast()
+		response = `<rsp:SendResponse />`
+	case strings.HasSuffix(req.Action, "shell/Receive"):
+		id := req.Receive.CommandID
+		cmd := s.commands[id]
+		// The client receives the output while it sends the input.
+		for !cmd.ended && fakeUploadRe.MatchString(cmd.script) {
+			s.cond.Wait()
+		}
+		stdout, code := s.run(cmd)
+		response = fmt.Sprintf(`<rsp:ReceiveResponse>`+
+			`<rsp:Stream Name="stdout" CommandId="%s">%s</rsp:Stream>`+
+			`<rsp:Stream Name="stdout" CommandId="%s" End="true"></rsp:Stream>`+
+			`<rsp:Stream Name="stderr" CommandId="%s" End="true"></rsp:Stream>`+
+			`<rsp:CommandState CommandId="%s" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">`+
+			`<rsp:ExitCode>%d</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`,
+			id, base64.StdEncoding.EncodeToString(stdout), id, id, id, code)
+	case strings.HasSuffix(req.Action, "shell/Signal"), strings.HasSuffix(req.Action, "transfer/Delete"):
+	default:
+		s.t.Errorf("unexpected action %s", req.Action)
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	fmt.Fprintf(w, fakeShellEnvelope, response)
+}
+
+// decodeScript returns the script of a PowerShell -EncodedCommand command
+// line.
+func (s *fakeShellServer) decodeScript(line string) string {
+	i := strings.LastIndex(line, " -EncodedCommand ")
+	s.shells = append(s.shells, line[:i])
+	b, err := base64.StdEncoding.DecodeString(line[i+len(" -EncodedCommand "):])
+	if err != nil {
+		s.t.Errorf("invalid encoded command: %s", err)
+	}
+	script, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder().Bytes(b)
+	if err != nil {
+		s.t.Errorf("invalid encoded command: %s", err)
+	}
+	return string(script)
+}
+
+var (
+	fakeUploadRe   = regexp.MustCompile(`GetFullPath\("(.*)"\)\s+\$compressed = \$(true|false)`)
+	fakeDownloadRe = regexp.MustCompile(`ReadAllBytes\(["'](.*)["']\)`)
+)
+
+// run runs the script of cmd, returning its standard output and exit code.
+func (s *fakeShellServer) run(cmd *fakeCommand) ([]byte, int) {
+	if cmd.script == "" || strings.Contains(cmd.script, "$p.StartInfo.Arguments = '/c ") {
+		// Other commands, and those run in a 64-bit process, succeed without
+		// output.
+		return nil, 0
+	}
+	if m := fakeListRe.FindStringSubmatch(cmd.script); m != nil {
+		return s.list(m[1] == "true", m[3], m[2] == "-Path")
+	}
+	if m := fakeDownloadRe.FindStringSubmatch(cmd.script); m != nil {
+		// Windows accepts both separators.
+		b, ok := s.files[strings.ReplaceAll(m[1], "/", `\`)]
+		if !ok {
+			return nil, 1
+		}
+		return []byte(base64.StdEncoding.EncodeToString(b)), 0
+	}
+
+	m := fakeUploadRe.FindStringSubmatch(cmd.script)
+	if m == nil {
+		s.t.Errorf("unexpected script %q", cmd.script)
+		return nil, 1
+	}
+	var b []byte
+	for _, line := range strings.Split(string(cmd.stdin), "\r\n") {
+		if line == "" {
+			continue
+		}
+		chunk, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			s.t.Errorf("invalid line: %s", err)
+			return nil, 1
+		}
+		b = append(b, chunk...)
+	}
+	if m[2] == "true" {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			s.t.Errorf("invalid gzip content: %s", err)
+			return nil, 1
+		}
+		if b, err = ioutil.ReadAll(gz); err != nil {
+			s.t.Errorf("invalid gzip content: %s", err)
+			return nil, 1
+		}
+	}
+	s.files[m[1]] = b
+	return []byte(strconv.Itoa(len(b)) + "\r\n"), 0
+}
+
+func TestUpload_chunks(t *testing.T) {
+	content := make([]byte, 300000)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	for _, tc := range []struct {
+		name        string
+		chunkSize   int
+		compression bool
+		sends       int
+	}{
+		{name: "default", sends: 4 + 1},
+		{name: "small chunks", chunkSize: 30000, sends: 10 + 1},
+		{name: "compression", chunkSize: 30000, compression: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, c := newFakeShellServer(t)
+			c.config.UploadChunkSize = tc.chunkSize
+			c.config.UploadCompression = tc.compression
+
+			if err := c.Upload("C:/Temp/file.bin", bytes.NewReader(content), nil); err != nil {
+				t.Fatalf("error uploading file: %s", err)
+			}
+			if !bytes.Equal(s.files[`C:\Temp\file.bin`], content) {
+				t.Fatalf("uploaded %d bytes, expected %d", len(s.files[`C:\Temp\file.bin`]), len(content))
+			}
+			if tc.sends != 0 && s.sends != tc.sends {
+				t.Fatalf("expected %d requests, got %d", tc.sends, s.sends)
+			}
+		})
+	}
+}
+
+func TestUpload_compressionEmpty(t *testing.T) {
+	s, c := newFakeShellServer(t)
+	c.config.UploadCompression = true
+
+	if err := c.Upload("C:/Temp/empty", strings.NewReader(""), nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	if b, ok := s.files[`C:\Temp\empty`]; !ok || len(b) != 0 {
+		t.Fatalf("expected an empty file, got %q", b)
+	}
+}
+
+func TestUpload_shell(t *testing.T) {
+	for _, tc := range []struct {
+		shell    string
+		expected string
+	}{
+		{shell: "", expected: "powershell.exe"},
+		{shell: "pwsh.exe", expected: "pwsh.exe"},
+		{shell: `C:\Program Files\PowerShell\7\pwsh.exe`, expected: `"C:\Program Files\PowerShell\7\pwsh.exe"`},
+	} {
+		s, c := newFakeShellServer(t)
+		c.config.Shell = tc.shell
+
+		if err := c.Upload("C:/Temp/file.txt", strings.NewReader("stuff"), nil); err != nil {
+			t.Fatalf("error uploading file: %s", err)
+		}
+		if err := c.Download("C:/Temp/file.txt", ioutil.Discard); err != nil {
+			t.Fatalf("error downloading file: %s", err)
+		}
+		if len(s.shells) != 2 {
+			t.Fatalf("expected 2 commands, got %d", len(s.shells))
+		}
+		for _, shell := range s.shells {
+			if shell != tc.expected {
+				t.Fatalf("expected commands run with %s, got %s", tc.expected, shell)
+			}
+		}
+	}
+}
+
+func TestDefaultUploadChunkSize(t *testing.T) {
+	envelopeSize := 153600
+	chunkSize := defaultUploadChunkSize(envelopeSize)
+	if chunkSize%3 != 0 {
+		t.Fatalf("chunk size %d isn't a multiple of 3, so lines would be padded", chunkSize)
+	}
+	line := base64.StdEncoding.EncodedLen(chunkSize) + 2
+	if request := base64.StdEncoding.EncodedLen(line) + 2048; request > envelopeSize {
+		t.Fatalf("a chunk of %d bytes makes a request of %d bytes", chunkSize, request)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package powershell builds the command lines running PowerShell on Windows
+// guests, shared by the WinRM communicator and the guestexec package.
+package powershell
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// The executables of Windows PowerShell, installed with Windows, and of
+// PowerShell 7.
+const (
+	WindowsPowerShell = "powershell.exe"
+	PowerShellCore    = "pwsh.exe"
+)
+
+// Quote returns the executable shell, powershell.exe when empty,
+// double quoted for a command line if its path has spaces.
+func Quote(shell string) string {
+	if shell == "" {
+		return WindowsPowerShell
+	}
+	if strings.ContainsAny(shell, " \t") && !strings.HasPrefix(shell, `"`) {
+		return `"` + shell + `"`
+	}
+	return shell
+}
+
+// EncodedCommand returns the command line running script with the
+// executable shell, powershell.exe when empty. The script is passed as
+// base64 encoded UTF-16LE with -EncodedCommand, the encoding expected by
+// both Windows PowerShell and PowerShell 7, so that it needs no quoting.
+// Progress bars are disabled, as they are written to the standard error.
+func EncodedCommand(shell string, script string) string {
+	script = "$ProgressPreference = 'SilentlyContinue';" + script
+
+	units := utf16.Encode([]rune(script))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		b[2*i] = byte(u)
+		b[2*i+1] = byte(u >> 8)
+	}
+	return Quote(shell) + " -EncodedCommand " + base64.StdEncoding.EncodeToString(b)
+}
+
+// native64BitScript runs a command with the 64-bit cmd.exe, reached through
+// the Sysnative alias when PowerShell is a 32-bit process on a 64-bit
+// Windows, where System32 is redirected to SysWOW64. The command is passed
+// as is to cmd.exe, with the output and exit code of the process. It only
+// uses .NET 2.0 APIs, so that it runs with PowerShell 2.0.
+const native64BitScript = `$cmd = "$env:SystemRoot\System32\cmd.exe"
+if ([IntPtr]::Size -eq 4 -and (Test-Path "$env:SystemRoot\Sysnative\cmd.exe")) {
+	$cmd = "$env:SystemRoot\Sysnative\cmd.exe"
+}
+$p = New-Object System.Diagnostics.Process
+$p.StartInfo.FileName = $cmd
+$p.StartInfo.Arguments = '/c %s'
+$p.StartInfo.UseShellExecute = $false
+[void]$p.Start()
+$p.WaitForExit()
+exit $p.ExitCode`
+
+// Native64BitCommand returns the command line running command in a 64-bit
+// process on 64-bit Windows, even when shell, the PowerShell executable, is
+// a 32-bit one. This avoids the redirection of the System32 folder and of
+// the SOFTWARE registry hive that applies to 32-bit processes.
+func Native64BitCommand(shell string, command string) string {
+	return EncodedCommand(shell, fmt.Sprintf(native64BitScript, strings.ReplaceAll(command, "'", "''")))
+}
@@ -0,0 +1,36 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package powershell
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEncodedCommand(t *testing.T) {
+	// "$ProgressPreference = 'SilentlyContinue';echo é" in UTF-16LE
+	encoded := "JABQAHIAbwBnAHIAZQBzAHMAUAByAGUAZgBlAHIAZQBuAGMAZQAgAD0AIAAnAFMAaQBsAGUAbgB0AGwAeQBDAG8AbgB0AGkAbgB1AGUAJwA7AGUAYwBoAG8AIADpAA=="
+
+	for _, tc := range []struct {
+		shell    string
+		expected string
+	}{
+		{shell: "", expected: "powershell.exe -EncodedCommand " + encoded},
+		{shell: PowerShellCore, expected: "pwsh.exe -EncodedCommand " + encoded},
+		{shell: `C:\Program Files\PowerShell\7\pwsh.exe`, expected: `"C:\Program Files\PowerShell\7\pwsh.exe" -EncodedCommand ` + encoded},
+		{shell: `"C:\Program Files\PowerShell\7\pwsh.exe"`, expected: `"C:\Program Files\PowerShell\7\pwsh.exe" -EncodedCommand ` + encoded},
+	} {
+		if cmd := EncodedCommand(tc.shell, "echo é"); cmd != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.shell, tc.expected, cmd)
+		}
+	}
+}
+
+func TestNative64BitCommand(t *testing.T) {
+	cmd := Native64BitCommand(PowerShellCore, "echo 'foo'")
+	expected := EncodedCommand(PowerShellCore, fmt.Sprintf(native64BitScript, "echo ''foo''"))
+	if cmd != expected {
+		t.Fatalf("expected %s, got %s", expected, cmd)
+	}
+}
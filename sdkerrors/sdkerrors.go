@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package sdkerrors classifies the errors of the SDK and of the plugins, so that
+callers can decide programmatically whether to retry an operation, to fail
+the build, or to blame the template.
+
+An error is classified by wrapping it:
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return sdkerrors.WrapRetryable(err)
+	}
+	if config.Region == "" {
+		return sdkerrors.UserErrorf("region must be set")
+	}
+
+and Classify, or IsRetryable, IsUserError and IsFatalInfra, tell the class of
+an error, looking through the errors it wraps. The outermost classification
+wins, so that a caller can reclassify the errors it gets.
+*/
+package sdkerrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Class is the class of an error.
+type Class int
+
+const (
+	// ClassUnknown errors are not classified.
+	ClassUnknown Class = iota
+	// ClassRetryable errors may not happen again when retrying.
+	ClassRetryable
+	// ClassUser errors come from the template or the input, and happen
+	// again until they are fixed.
+	ClassUser
+	// ClassFatalInfra errors come from the infrastructure, and are not worth
+	// retrying, such as a missing quota or permission.
+	ClassFatalInfra
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassRetryable:
+		return "retryable"
+	case ClassUser:
+		return "user"
+	case ClassFatalInfra:
+		return "fatal_infra"
+	default:
+		return "unknown"
+	}
+}
+
+// Retryable is an error that may not happen again when retrying.
+type Retryable struct {
+	Err error
+}
+
+func (e *Retryable) Error() string { return e.Err.Error() }
+func (e *Retryable) Unwrap() error { return e.Err }
+
+// UserError is an error caused by the template or the input.
+type UserError struct {
+	Err error
+}
+
+func (e *UserError) Error() string { return e.Err.Error() }
+func (e *UserError) Unwrap() error { return e.Err }
+
+// FatalInfra is an error of the infrastructure that is not worth retrying.
+type FatalInfra struct {
+	Err error
+}
+
+func (e *FatalInfra) Error() string { return e.Err.Error() }
+func (e *FatalInfra) Unwrap() error { return e.Err }
+
+// WrapRetryable returns err classified as Retryable, or nil if err is nil.
+func WrapRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Retryable{Err: err}
+}
+
+// WrapUser returns err classified as a UserError, or nil if err is nil.
+func WrapUser(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &UserError{Err: err}
+}
+
+// WrapFatalInfra returns err classified as FatalInfra, or nil if err is nil.
+func WrapFatalInfra(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FatalInfra{Err: err}
+}
+
+// UserErrorf returns a UserError formatted like fmt.Errorf.
+func UserErrorf(format string, a ...interface{}) error {
+	return &UserError{Err: fmt.Errorf(format, a...)}
+}
+
+// Classify returns the class of the outermost classified error of the chain
+// of err, or ClassUnknown if none is.
+func Classify(err error) Class {
+	for err != nil {
+		switch err.(type) {
+		case *Retryable:
+			return ClassRetryable
+		case *UserError:
+			return ClassUser
+		case *FatalInfra:
+			return ClassFatalInfra
+		}
+		err = errors.Unwrap(err)
+	}
+	return ClassUnknown
+}
+
+// IsRetryable tells whether err is classified as Retryable. It can be used as
+// the ShouldRetry of a retry.Config.
+func IsRetryable(err error) bool {
+	return Classify(err) == ClassRetryable
+}
+
+// IsUserError tells whether err is classified as a UserError.
+func IsUserError(err error) bool {
+	return Classify(err) == ClassUser
+}
+
+// IsFatalInfra tells whether err is classified as FatalInfra.
+func IsFatalInfra(err error) bool {
+	return Classify(err) == ClassFatalInfra
+}
+
+// IsPermanent tells whether err is classified as a UserError or FatalInfra,
+// which happen again when retrying.
+func IsPermanent(err error) bool {
+	c := Classify(err)
+	return c == ClassUser || c == ClassFatalInfra
+}
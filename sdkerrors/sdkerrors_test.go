@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdkerrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	base := errors.New("oops")
+	for _, tc := range []struct {
+		err      error
+		expected Class
+	}{
+		{nil, ClassUnknown},
+		{base, ClassUnknown},
+		{WrapRetryable(base), ClassRetryable},
+		{fmt.Errorf("step: %w", WrapUser(base)), ClassUser},
+		{UserErrorf("region must be set"), ClassUser},
+		{WrapFatalInfra(base), ClassFatalInfra},
+		{WrapUser(WrapRetryable(base)), ClassUser},
+	} {
+		if class := Classify(tc.err); class != tc.expected {
+			t.Errorf("%v: expected %s, got %s", tc.err, tc.expected, class)
+		}
+	}
+
+	if WrapRetryable(nil) != nil || WrapUser(nil) != nil || WrapFatalInfra(nil) != nil {
+		t.Fatal("expected nil errors not to be wrapped")
+	}
+	err := WrapFatalInfra(base)
+	if !errors.Is(err, base) || err.Error() != "oops" {
+		t.Fatalf("expected the wrapped error, got %v", err)
+	}
+	if !IsPermanent(err) || IsPermanent(WrapRetryable(base)) {
+		t.Fatal("unexpected permanent errors")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package tracing is an optional tracing integration, recording spans for each
+multistep step, communicator operation and RPC call, to see where the time of
+builds goes.
+
+Tracing is disabled until a TracerProvider is set with SetTracerProvider. The
+interfaces are shaped after the ones of OpenTelemetry, so that an
+OpenTelemetry TracerProvider is adapted in a few lines:
+
+	type otelProvider struct{ tp trace.TracerProvider }
+
+	func (p otelProvider) Tracer(name string) tracing.Tracer {
+		return otelTracer{p.tp.Tracer(name)}
+	}
+
+	type otelTracer struct{ t trace.Tracer }
+
+	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+		ctx, span := t.t.Start(ctx, name)
+		return ctx, otelSpan{span}
+	}
+
+	...
+
+	tracing.SetTracerProvider(otelProvider{otel.GetTracerProvider()})
+*/
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// InstrumentationName is the name of the tracer of the SDK.
+const InstrumentationName = "github.com/hashicorp/packer-plugin-sdk"
+
+// TracerProvider provides the tracers.
+type TracerProvider interface {
+	Tracer(instrumentationName string) Tracer
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span, child of the span of ctx if any, and returns a
+	// context holding it.
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is an operation being traced.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err as an error of the operation.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// Attribute is a key and value describing a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+var (
+	mu     sync.RWMutex
+	tracer Tracer
+)
+
+// SetTracerProvider enables tracing with tp, or disables it when tp is nil.
+// The components of the SDK check whether tracing is enabled when they are
+// created, so it should be set first thing.
+func SetTracerProvider(tp TracerProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	if tp == nil {
+		tracer = nil
+		return
+	}
+	tracer = tp.Tracer(InstrumentationName)
+}
+
+// Enabled tells whether a TracerProvider is set.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tracer != nil
+}
+
+// Start starts a span with attrs using the TracerProvider, or a span doing
+// nothing when tracing is disabled.
+func Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span) {
+	mu.RLock()
+	t := tracer
+	mu.RUnlock()
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+
+	ctx, span := t.Start(ctx, spanName)
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+	return ctx, span
+}
+
+// End records err in span, if any, and ends it.
+func End(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
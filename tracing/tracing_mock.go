@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// MockTracerProvider is a TracerProvider recording the spans, that can be
+// used for tests.
+type MockTracerProvider struct {
+	l     sync.Mutex
+	spans []*MockSpan
+}
+
+// MockSpan is a span recorded by a MockTracerProvider.
+type MockSpan struct {
+	Name       string
+	Parent     *MockSpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Ended      bool
+
+	l    *sync.Mutex
+	done chan struct{}
+}
+
+type mockSpanKey struct{}
+
+func (p *MockTracerProvider) Tracer(string) Tracer {
+	return p
+}
+
+func (p *MockTracerProvider) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	parent, _ := ctx.Value(mockSpanKey{}).(*MockSpan)
+	span := &MockSpan{Name: spanName, Parent: parent, Attributes: map[string]interface{}{}, l: &p.l, done: make(chan struct{})}
+
+	p.l.Lock()
+	p.spans = append(p.spans, span)
+	p.l.Unlock()
+	return context.WithValue(ctx, mockSpanKey{}, span), span
+}
+
+// Spans returns the spans started, in order.
+func (p *MockTracerProvider) Spans() []*MockSpan {
+	p.l.Lock()
+	defer p.l.Unlock()
+	return append([]*MockSpan(nil), p.spans...)
+}
+
+func (s *MockSpan) SetAttributes(attrs ...Attribute) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	for _, a := range attrs {
+		s.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *MockSpan) RecordError(err error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *MockSpan) End() {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if !s.Ended {
+		s.Ended = true
+		close(s.done)
+	}
+}
+
+// Done returns a channel closed once the span is ended, for the spans ended
+// asynchronously.
+func (s *MockSpan) Done() <-chan struct{} {
+	return s.done
+}
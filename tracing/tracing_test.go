@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStart_disabled(t *testing.T) {
+	if Enabled() {
+		t.Fatal("tracing should be disabled by default")
+	}
+	ctx := context.Background()
+	spanCtx, span := Start(ctx, "span")
+	if spanCtx != ctx {
+		t.Fatal("expected the context to be unchanged")
+	}
+	if _, ok := span.(noopSpan); !ok {
+		t.Fatalf("expected a noop span, got %#v", span)
+	}
+	End(span, errors.New("oops"))
+}
+
+func TestStart(t *testing.T) {
+	tp := new(MockTracerProvider)
+	SetTracerProvider(tp)
+	defer SetTracerProvider(nil)
+
+	if !Enabled() {
+		t.Fatal("tracing should be enabled")
+	}
+	ctx, parent := Start(context.Background(), "parent", Attribute{Key: "key", Value: "value"})
+	_, child := Start(ctx, "child")
+	End(child, errors.New("oops"))
+	End(parent, nil)
+
+	spans := tp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name != "parent" || spans[0].Attributes["key"] != "value" || len(spans[0].Errors) != 0 || !spans[0].Ended {
+		t.Fatalf("unexpected parent span %#v", spans[0])
+	}
+	if spans[1].Name != "child" || spans[1].Parent != spans[0] || len(spans[1].Errors) != 1 || !spans[1].Ended {
+		t.Fatalf("unexpected child span %#v", spans[1])
+	}
+}